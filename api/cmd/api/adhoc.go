@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// createAdhocScan accepts a multipart upload (field "file") holding a .tar,
+// .tar.gz or .zip archive, stores it until its scan succeeds, and enqueues
+// a job that runs the regular scanner pipeline over the extracted tree.
+func (a *App) createAdhocScan(w http.ResponseWriter, r *http.Request) {
+	maxBytes := int64(a.cfg.AdhocMaxMB) * 1024 * 1024
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1024*1024)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": fmt.Sprintf("archive exceeds %d MB", a.cfg.AdhocMaxMB)})
+			return
+		}
+		badRequest(w, "expected multipart form with a file field")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	priority, err := normalizePriority(r.FormValue("priority"), priorityHigh)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		badRequest(w, "file is required")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": fmt.Sprintf("archive exceeds %d MB", a.cfg.AdhocMaxMB)})
+		return
+	}
+	format := detectArchiveFormat(data)
+	if format == "" {
+		badRequest(w, "unsupported archive: expected .tar, .tar.gz or .zip")
+		return
+	}
+
+	tx, err := a.db.Begin(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var jobID string
+	if err := tx.QueryRow(r.Context(), `INSERT INTO jobs (repo_id, status, source, priority) VALUES (NULL,'queued','upload',$1) RETURNING id::text`, priority).Scan(&jobID); err != nil {
+		serverError(w, err)
+		return
+	}
+	name := filepath.Base(header.Filename)
+	if _, err := tx.Exec(r.Context(), `INSERT INTO uploads (job_id, filename, format, size_bytes, data) VALUES ($1,$2,$3,$4,$5)`, jobID, name, format, len(data), data); err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	if err := a.pushJob(r.Context(), priority, map[string]string{"job_id": jobID, "source": "upload", "request_id": middleware.GetReqID(r.Context())}); err != nil {
+		serverError(w, err)
+		return
+	}
+	a.metrics.scansTriggered.Inc()
+	slog.InfoContext(withJobID(r.Context(), jobID), "ad-hoc scan enqueued", "format", format, "size_bytes", len(data))
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": jobID, "format": format, "size_bytes": len(data)})
+}
+
+// detectArchiveFormat sniffs an upload's format from its magic numbers. A
+// gzip stream only counts as tar.gz when what it decompresses to starts with
+// a tar header, so a gzipped file that is not an archive is refused here
+// rather than failing the job.
+func detectArchiveFormat(b []byte) string {
+	switch {
+	case len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b:
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return ""
+		}
+		defer zr.Close()
+		hdr := make([]byte, 262)
+		if _, err := io.ReadFull(zr, hdr); err != nil || !isTarHeader(hdr) {
+			return ""
+		}
+		return "tar.gz"
+	case len(b) >= 4 && bytes.Equal(b[:4], []byte("PK\x03\x04")):
+		return "zip"
+	case isTarHeader(b):
+		return "tar"
+	}
+	return ""
+}
+
+// isTarHeader reports whether b starts with a POSIX or GNU tar header.
+func isTarHeader(b []byte) bool {
+	return len(b) >= 262 && bytes.Equal(b[257:262], []byte("ustar"))
+}
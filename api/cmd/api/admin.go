@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	defaultRequeueLimit = 100
+	maxRequeueLimit     = 1000
+)
+
+func (a *App) adminRoutes(r chi.Router) {
+	r.Use(a.adminAuthz)
+	r.Post("/jobs/requeue", a.requeueJobs)
+	r.Post("/gc", a.runGC)
+	r.Get("/workers", a.listWorkers)
+	r.Get("/queue", a.getQueueStatus)
+	r.Get("/org-weights", a.listOrgWeights)
+	r.Put("/org-weights/{org}", a.setOrgWeight)
+	r.Get("/dead-letters", a.listDeadLetters)
+	r.Post("/dead-letters/{id}/replay", a.replayDeadLetter)
+	r.Delete("/dead-letters/{id}", a.deleteDeadLetter)
+	r.Get("/connectivity", a.connectivity)
+	r.Get("/flags", a.listFlags)
+	r.Get("/flags/resolve", a.resolveFlags)
+	r.Put("/flags/{name}", a.putFlag)
+	r.Delete("/flags/{name}", a.deleteFlag)
+	r.Put("/flags/{name}/overrides/{scope}/{target}", a.putFlagOverride)
+	r.Delete("/flags/{name}/overrides/{scope}/{target}", a.deleteFlagOverride)
+}
+
+type requeueReq struct {
+	Status           []string `json:"status"`
+	OlderThanMinutes int      `json:"older_than_minutes"`
+	Limit            int      `json:"limit"`
+}
+
+// requeueJobs resets matching jobs to queued, drops partial findings and
+// artifacts, and pushes fresh queue messages. Age is measured from the job's
+// last state change, so "running for over an hour" selects jobs whose worker
+// died.
+func (a *App) requeueJobs(w http.ResponseWriter, r *http.Request) {
+	var req requeueReq
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, "invalid json")
+			return
+		}
+	}
+	if len(req.Status) == 0 {
+		req.Status = []string{"failed"}
+	}
+	for _, s := range req.Status {
+		switch s {
+		case "failed":
+		case "queued", "running":
+			// These may still be in a worker's hands; only touch them once
+			// they are clearly stale.
+			if req.OlderThanMinutes <= 0 {
+				badRequest(w, "older_than_minutes is required when requeueing queued or running jobs")
+				return
+			}
+		default:
+			badRequest(w, "status must be failed, queued or running")
+			return
+		}
+	}
+	if req.OlderThanMinutes < 0 {
+		badRequest(w, "older_than_minutes must be >= 0")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultRequeueLimit
+	}
+	if req.Limit > maxRequeueLimit {
+		req.Limit = maxRequeueLimit
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Upload jobs can only run again while their archive is still stored.
+	rows, err := tx.Query(ctx, `
+		WITH picked AS (
+			SELECT j.id FROM jobs j
+			WHERE j.status::text = ANY($1)
+			  AND COALESCE(j.finished_at, j.started_at, j.created_at) < now() - make_interval(mins => $2)
+			  AND (j.source <> 'upload' OR EXISTS (SELECT 1 FROM uploads u WHERE u.job_id = j.id))
+			ORDER BY j.created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE jobs SET status='queued', started_at=NULL, finished_at=NULL, error=NULL, clone_strategy=NULL, commit_sha=NULL, rule_packs=NULL,
+			worker=NULL, stage=NULL, progress=0, stage_timings='{}'
+		FROM picked WHERE jobs.id = picked.id
+		RETURNING jobs.id::text, COALESCE(jobs.repo_id::text, ''), jobs.source, jobs.priority`,
+		req.Status, req.OlderThanMinutes, req.Limit)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	type requeued struct{ id, repoID, source, priority string }
+	var jobs []requeued
+	ids := []string{}
+	for rows.Next() {
+		var j requeued
+		if err := rows.Scan(&j.id, &j.repoID, &j.source, &j.priority); err != nil {
+			rows.Close()
+			serverError(w, err)
+			return
+		}
+		jobs = append(jobs, j)
+		ids = append(ids, j.id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM findings WHERE job_id::text = ANY($1) AND (repo_id IS NULL OR first_job_id::text = ANY($1))`, ids); err != nil {
+		serverError(w, err)
+		return
+	}
+	objects, err := discardJobObjects(ctx, tx, ids)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		serverError(w, err)
+		return
+	}
+	a.deleteObjects(ctx, objects)
+
+	reqID := middleware.GetReqID(ctx)
+	for _, j := range jobs {
+		if err := a.pushJob(ctx, j.priority, map[string]string{"job_id": j.id, "repo_id": j.repoID, "source": j.source, "request_id": reqID}); err != nil {
+			// Rows are already queued; a retry with status "queued" picks
+			// up whatever was not pushed.
+			serverError(w, err)
+			return
+		}
+		slog.InfoContext(withJobID(ctx, j.id), "job requeued", "repo_id", j.repoID, "source", j.source)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"requeued": len(ids), "job_ids": ids})
+}
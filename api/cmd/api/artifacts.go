@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/internal/jobsql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// artifactURLTTL bounds how long a listed download URL stays valid.
+const artifactURLTTL = 15 * time.Minute
+
+// objectURLTTL bounds the artifact store URL a download is redirected to;
+// it is only needed to start the download.
+const objectURLTTL = 5 * time.Minute
+
+// discardJobObjects deletes the artifact rows of jobIDs in tx and clears
+// their archived logs, returning the artifact store keys both pointed to for
+// deleteObjects once tx commits. Jobs being reset for another attempt call
+// it so nothing of the attempt before outlives the reset.
+func discardJobObjects(ctx context.Context, tx pgx.Tx, jobIDs []string) ([]string, error) {
+	rows, err := tx.Query(ctx, jobsql.DiscardObjects, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// deleteObjects removes keys from the artifact store once no row points at
+// them. A failure only orphans the object, so it is logged, not returned.
+func (a *App) deleteObjects(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if a.objects == nil {
+		slog.WarnContext(ctx, "artifact objects not deleted: artifacts.s3 is not configured", "keys", len(keys))
+		return
+	}
+	for _, key := range keys {
+		if err := a.objects.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "artifact object not deleted", "key", key, "err", err)
+		}
+	}
+}
+
+type Artifact struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (a *App) listJobArtifacts(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "id")
+	var exists bool
+	if err := a.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM jobs WHERE id::text=$1)`, jobID).Scan(&exists); err != nil {
+		serverError(w, err)
+		return
+	}
+	if !exists {
+		notFound(w)
+		return
+	}
+
+	rows, err := a.db.Query(r.Context(), `SELECT id::text, kind, name, content_type, size_bytes, sha256, created_at FROM job_artifacts WHERE job_id=$1 ORDER BY kind, name`, jobID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	base := a.publicBaseURL(r)
+	out := make([]Artifact, 0)
+	for rows.Next() {
+		var art Artifact
+		if err := rows.Scan(&art.ID, &art.Kind, &art.Name, &art.ContentType, &art.SizeBytes, &art.SHA256, &art.CreatedAt); err != nil {
+			serverError(w, err)
+			return
+		}
+		art.URL, art.ExpiresAt = a.artifactURL(base, art.ID, now)
+		out = append(out, art)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// artifactURL presigns the download URL of artifact id.
+func (a *App) artifactURL(base, id string, now time.Time) (string, time.Time) {
+	path := "/artifacts/" + id
+	q := a.presigner.Query(path, now, artifactURLTTL)
+	return base + path + "?" + q.Encode(), now.Add(artifactURLTTL).UTC().Truncate(time.Second)
+}
+
+// downloadArtifact serves an artifact to anyone holding a valid presigned
+// URL; the signature replaces bearer auth for this route. Artifacts in the
+// artifact store are redirected to a URL presigned by the store.
+func (a *App) downloadArtifact(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := a.presigner.Verify("/artifacts/"+id, r.URL.Query(), time.Now()); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": err.Error()})
+		return
+	}
+	var name, contentType, sum string
+	var data []byte
+	var object *string
+	err := a.db.QueryRow(r.Context(), `SELECT name, content_type, sha256, data, object_key FROM job_artifacts WHERE id::text=$1`, id).Scan(&name, &contentType, &sum, &data, &object)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	if object != nil {
+		if a.objects == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "artifact is in the artifact store, which is not configured"})
+			return
+		}
+		http.Redirect(w, r, a.objects.PresignGet(*object, name, contentType, time.Now(), objectURLTTL), http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(name, `"`, "")+`"`)
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// publicBaseURL is server.public_url when configured, otherwise derived from
+// the request (honouring X-Forwarded-Proto from a TLS-terminating proxy).
+func (a *App) publicBaseURL(r *http.Request) string {
+	if a.cfg.PublicURL != "" {
+		return strings.TrimSuffix(a.cfg.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return (&url.URL{Scheme: scheme, Host: r.Host}).String()
+}
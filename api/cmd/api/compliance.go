@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"argus/api/internal/compliance"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// complianceFinding reads what the compliance mapping needs from a finding's
+// evidence: its rule, whether it is a dependency vulnerability (trivy
+// records the package) and any CWEs the scanner reported, which semgrep
+// keeps in its rule metadata.
+func complianceFinding(tool string, evidence json.RawMessage) compliance.Finding {
+	f := compliance.Finding{Tool: tool, RuleID: findingRuleID(evidence)}
+	var ev struct {
+		Pkg      string          `json:"pkg"`
+		CWE      []string        `json:"cwe"`
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if len(evidence) == 0 || json.Unmarshal(evidence, &ev) != nil {
+		return f
+	}
+	f.Vulnerability = ev.Pkg != ""
+	f.CWEs = ev.CWE
+	var meta struct {
+		CWE json.RawMessage `json:"cwe"`
+	}
+	if json.Unmarshal(ev.Metadata, &meta) == nil && len(meta.CWE) > 0 {
+		var one string
+		var many []string
+		if json.Unmarshal(meta.CWE, &many) == nil {
+			f.CWEs = append(f.CWEs, many...)
+		} else if json.Unmarshal(meta.CWE, &one) == nil {
+			f.CWEs = append(f.CWEs, one)
+		}
+	}
+	return f
+}
+
+type complianceControl struct {
+	compliance.Control
+	// Status is findings when the scan found issues mapped to the control
+	// and no_findings otherwise. No findings is evidence for an audit, not
+	// proof the control is met.
+	Status     string         `json:"status"`
+	Findings   int            `json:"findings"`
+	BySeverity map[string]int `json:"by_severity"`
+	// PublicIDs lists up to maxReportFindings of the findings.
+	PublicIDs []string `json:"public_ids"`
+}
+
+const maxReportFindings = 50
+
+// complianceReport maps the findings of the repo's latest successful scan to
+// a framework's controls.
+func (a *App) complianceReport(w http.ResponseWriter, r *http.Request) {
+	repoID := chi.URLParam(r, "id")
+	fw, ok := compliance.Lookup(strings.ToLower(chi.URLParam(r, "framework")))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown framework", "frameworks": compliance.Frameworks()})
+		return
+	}
+	var exists bool
+	if err := a.db.QueryRow(r.Context(), `SELECT EXISTS (SELECT 1 FROM repos WHERE id=$1)`, repoID).Scan(&exists); err != nil || !exists {
+		notFound(w)
+		return
+	}
+
+	controls := make([]*complianceControl, len(fw.Controls))
+	byID := map[string]*complianceControl{}
+	for i, c := range fw.Controls {
+		controls[i] = &complianceControl{Control: c, Status: "no_findings", BySeverity: map[string]int{}, PublicIDs: []string{}}
+		byID[c.ID] = controls[i]
+	}
+	out := map[string]any{"repo_id": repoID, "framework": fw.ID, "framework_name": fw.Name, "job_id": nil, "controls": controls}
+
+	var jobID string
+	var scannedAt time.Time
+	err := a.db.QueryRow(r.Context(), `SELECT id::text, finished_at FROM jobs WHERE repo_id=$1 AND status='succeeded' ORDER BY finished_at DESC LIMIT 1`, repoID).Scan(&jobID, &scannedAt)
+	if err != nil {
+		// Never scanned: every control reads no_findings, which job_id null
+		// qualifies.
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	rows, err := a.db.Query(r.Context(), `SELECT tool::text, severity, COALESCE(public_id,''), evidence_json FROM findings WHERE job_id=$1 ORDER BY created_at`, jobID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	total, unmapped := 0, 0
+	for rows.Next() {
+		var tool, sev, publicID string
+		var evidence json.RawMessage
+		if err := rows.Scan(&tool, &sev, &publicID, &evidence); err != nil {
+			serverError(w, err)
+			return
+		}
+		total++
+		mapped := compliance.Controls(complianceFinding(tool, evidence), fw.ID)
+		if len(mapped) == 0 {
+			unmapped++
+		}
+		for _, m := range mapped {
+			c := byID[m.ID]
+			c.Status = "findings"
+			c.Findings++
+			c.BySeverity[sev]++
+			if len(c.PublicIDs) < maxReportFindings {
+				c.PublicIDs = append(c.PublicIDs, publicID)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+	out["job_id"], out["scanned_at"] = jobID, scannedAt
+	out["findings"], out["unmapped_findings"] = total, unmapped
+	writeJSON(w, http.StatusOK, out)
+}
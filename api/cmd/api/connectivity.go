@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+// Connectivity self-tests reach each outbound destination the way real
+// traffic does, through its configured proxy, so a proxy setup can be
+// checked without waiting for a PR or webhook to fail.
+const (
+	connectivityTimeout = 10 * time.Second
+	// maxWebhookTargets bounds the webhook hosts one self-test probes.
+	maxWebhookTargets = 10
+)
+
+type connectivityCheck struct {
+	Destination string `json:"destination"`
+	Target      string `json:"target"`
+	Proxy       string `json:"proxy"`
+	Status      string `json:"status"` // ok, fail or skipped
+	HTTPStatus  int    `json:"http_status,omitempty"`
+	LatencyMS   int64  `json:"latency_ms,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// probe sends a HEAD request to target. Any HTTP response, whatever its
+// status, shows the destination is reachable.
+func probe(ctx context.Context, client *http.Client, destination, target string, p proxy.Setting) connectivityCheck {
+	c := connectivityCheck{Destination: destination, Target: target, Proxy: p.Redacted()}
+	ctx, cancel := context.WithTimeout(ctx, connectivityTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		c.Status, c.Error = "fail", err.Error()
+		return c
+	}
+	req.Header.Set("User-Agent", "Argus-Connectivity/1")
+	start := time.Now()
+	resp, err := client.Do(req)
+	c.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		c.Status, c.Error = "fail", err.Error()
+		return c
+	}
+	resp.Body.Close()
+	c.Status, c.HTTPStatus = "ok", resp.StatusCode
+	return c
+}
+
+// connectivity runs the self-tests: the GitHub API, and the host of every
+// enabled webhook. It answers 200 even when a check fails; "ok" summarises.
+func (a *App) connectivity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	gh, githubAPIURL := a.cfg.GitHubProxySetting(), forge.GitHub(a.cfg.GitHubURL).APIURL()
+	checks := []connectivityCheck{}
+	if a.cfg.Offline {
+		checks = append(checks, connectivityCheck{Destination: "github", Target: githubAPIURL, Proxy: gh.Redacted(), Status: "skipped", Error: "offline mode"})
+	} else {
+		checks = append(checks, probe(ctx, &http.Client{Transport: gh.Transport()}, "github", githubAPIURL, gh))
+	}
+
+	targets, err := a.webhookTargets(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	wp := a.cfg.WebhookProxySetting()
+	client := deliveryClient(wp)
+	results := make([]connectivityCheck, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probe(ctx, client, "webhooks", t, wp)
+		}()
+	}
+	wg.Wait()
+	checks = append(checks, results...)
+
+	ok := true
+	for _, c := range checks {
+		ok = ok && c.Status != "fail"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": ok, "checks": checks})
+}
+
+// webhookTargets returns the distinct scheme://host of enabled webhooks.
+func (a *App) webhookTargets(ctx context.Context) ([]string, error) {
+	rows, err := a.db.Query(ctx, `SELECT url FROM webhooks WHERE enabled ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	seen := map[string]bool{}
+	for rows.Next() && len(out) < maxWebhookTargets {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		target := u.Scheme + "://" + u.Host + "/"
+		if !seen[target] {
+			seen[target] = true
+			out = append(out, target)
+		}
+	}
+	return out, rows.Err()
+}
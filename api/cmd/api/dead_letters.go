@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
+)
+
+// Workers park messages they give up on in the queue's dead letters: payloads
+// that do not parse and jobs that exhausted their retries.
+const (
+	defaultDeadLetterLimit = 100
+	maxDeadLetterLimit     = 1000
+)
+
+type DeadLetter struct {
+	ID       string `json:"id"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error"`
+	Payload  string `json:"payload"`
+	JobID    string `json:"job_id,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+	Worker   string `json:"worker"`
+	FailedAt string `json:"failed_at"`
+	raw      string
+}
+
+// deadLetters returns the parsed entries, newest first.
+func (a *App) deadLetters(r *http.Request) ([]DeadLetter, error) {
+	raws, err := a.queue.Messages(r.Context(), queueDead)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DeadLetter, 0, len(raws))
+	for _, raw := range raws {
+		var d DeadLetter
+		if json.Unmarshal([]byte(raw), &d) != nil {
+			continue
+		}
+		d.raw = raw
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (a *App) findDeadLetter(r *http.Request) (DeadLetter, error) {
+	all, err := a.deadLetters(r)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	id := chi.URLParam(r, "id")
+	for _, d := range all {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	return DeadLetter{}, errNotFound
+}
+
+// takeDeadLetter removes the entry in the URL. It fails with errNotFound if
+// the entry is gone, including when a concurrent request took it first.
+func (a *App) takeDeadLetter(r *http.Request) (DeadLetter, error) {
+	d, err := a.findDeadLetter(r)
+	if err != nil {
+		return d, err
+	}
+	n, err := a.queue.Remove(r.Context(), queueDead, d.raw)
+	if err != nil {
+		return d, err
+	}
+	if n == 0 {
+		return d, errNotFound
+	}
+	return d, nil
+}
+
+func (a *App) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			badRequest(w, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxDeadLetterLimit)
+	}
+	all, err := a.deadLetters(r)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"total": len(all), "dead_letters": all[:min(limit, len(all))]})
+}
+
+// replayDeadLetter resets the entry's job to queued, dropping partial
+// results, and pushes a fresh message with the retry count reset.
+func (a *App) replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	d, err := a.findDeadLetter(r)
+	if errors.Is(err, errNotFound) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	var msg struct {
+		JobID  string `json:"job_id"`
+		RepoID string `json:"repo_id"`
+		Source string `json:"source"`
+	}
+	if json.Unmarshal([]byte(d.Payload), &msg) != nil || msg.JobID == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "payload is not a job message; delete it instead"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	var priority string
+	err = tx.QueryRow(ctx, `UPDATE jobs SET status='queued', started_at=NULL, finished_at=NULL, error=NULL, clone_strategy=NULL, commit_sha=NULL, rule_packs=NULL,
+			worker=NULL, stage=NULL, progress=0, stage_timings='{}'
+		WHERE id=$1 AND (source <> 'upload' OR EXISTS (SELECT 1 FROM uploads u WHERE u.job_id = jobs.id))
+			AND (NOT image_authenticated OR EXISTS (SELECT 1 FROM image_credentials c WHERE c.job_id = jobs.id))
+		RETURNING priority`, msg.JobID).Scan(&priority)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": "job no longer exists, or its upload or registry credentials are gone"})
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM findings WHERE job_id=$1 AND (repo_id IS NULL OR first_job_id=$1)`, msg.JobID); err != nil {
+		serverError(w, err)
+		return
+	}
+	objects, err := discardJobObjects(ctx, tx, []string{msg.JobID})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	// Taking the entry before committing makes concurrent replays of the
+	// same entry push at most one message.
+	if _, err := a.takeDeadLetter(r); err != nil {
+		if errors.Is(err, errNotFound) {
+			notFound(w)
+			return
+		}
+		serverError(w, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err == nil {
+		a.deleteObjects(ctx, objects)
+		err = a.pushJob(ctx, priority, map[string]string{"job_id": msg.JobID, "repo_id": msg.RepoID, "source": msg.Source, "request_id": middleware.GetReqID(ctx)})
+	}
+	if err != nil {
+		// Put the entry back so the replay can be retried.
+		_ = a.queue.RestoreDeadLetter(ctx, d.raw)
+		serverError(w, err)
+		return
+	}
+	slog.InfoContext(withJobID(ctx, msg.JobID), "dead letter replayed", "dead_letter_id", d.ID, "reason", d.Reason)
+	writeJSON(w, http.StatusOK, map[string]any{"replayed": d.ID, "job_id": msg.JobID})
+}
+
+func (a *App) deleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	d, err := a.takeDeadLetter(r)
+	if errors.Is(err, errNotFound) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "dead letter deleted", "dead_letter_id", d.ID, "reason", d.Reason)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var startedAt = time.Now()
+
+// adminAuthz admits only the admin token. Admin routes are disabled entirely
+// when SSAO_ADMIN_TOKEN is unset.
+func (a *App) adminAuthz(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.cfg.AdminToken == "" {
+			notFound(w)
+			return
+		}
+		if !a.isAdmin(r) {
+			writeJSON(w, http.StatusForbidden, map[string]any{"error": "admin scope required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) isAdmin(r *http.Request) bool {
+	tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return a.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(tok), []byte(a.cfg.AdminToken)) == 1
+}
+
+// exceptDebug applies mw to every route but /debug, whose pprof profile and
+// trace run for as long as their seconds parameter asks.
+func exceptDebug(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/debug/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (a *App) debugRoutes(r chi.Router) {
+	r.Use(a.adminAuthz)
+	r.Get("/status", a.debugStatus)
+	r.HandleFunc("/pprof/", pprof.Index)
+	r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/pprof/profile", pprof.Profile)
+	r.HandleFunc("/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/pprof/trace", pprof.Trace)
+	r.HandleFunc("/pprof/{name}", func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(chi.URLParam(r, "name")).ServeHTTP(w, r)
+	})
+}
+
+func (a *App) debugStatus(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	st := a.db.Stat()
+
+	queue := map[string]any{"name": a.cfg.JobQueue}
+	if depths, err := a.queue.Depths(r.Context()); err == nil {
+		queue["depths"] = depths
+	} else {
+		queue["error"] = err.Error()
+	}
+	queue["backend"] = a.queue.Name()
+
+	status := map[string]any{
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+		"go_version":     runtime.Version(),
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"memory": map[string]any{
+			"heap_alloc_bytes": ms.HeapAlloc,
+			"heap_inuse_bytes": ms.HeapInuse,
+			"sys_bytes":        ms.Sys,
+			"num_gc":           ms.NumGC,
+			"last_gc":          time.Unix(0, int64(ms.LastGC)).UTC(),
+		},
+		"db_pool": map[string]any{
+			"acquired":            st.AcquiredConns(),
+			"idle":                st.IdleConns(),
+			"total":               st.TotalConns(),
+			"max":                 st.MaxConns(),
+			"acquire_count":       st.AcquireCount(),
+			"empty_acquire_count": st.EmptyAcquireCount(),
+			"acquire_wait":        st.AcquireDuration().String(),
+		},
+		"queue": queue,
+	}
+	if rq, ok := a.queue.(*redisQueue); ok {
+		rs := rq.rdb.PoolStats()
+		status["redis_pool"] = map[string]any{
+			"hits":        rs.Hits,
+			"misses":      rs.Misses,
+			"timeouts":    rs.Timeouts,
+			"total_conns": rs.TotalConns,
+			"idle_conns":  rs.IdleConns,
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type digestRepo struct {
+	RepoID   string         `json:"repo_id"`
+	Name     string         `json:"name"`
+	Findings int            `json:"findings"`
+	BySev    map[string]int `json:"by_severity"`
+}
+
+// orgDigest summarizes findings for one local calendar period of the org.
+// ?period=daily (default) covers the previous local day, weekly the seven
+// local days before today; ?date=YYYY-MM-DD picks the period's last day.
+func (a *App) orgDigest(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	var tz string
+	if err := a.db.QueryRow(r.Context(), `SELECT timezone FROM orgs WHERE name=$1`, org).Scan(&tz); err != nil {
+		notFound(w)
+		return
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	period := r.URL.Query().Get("period")
+	days := 1
+	switch period {
+	case "", "daily":
+		period = "daily"
+	case "weekly":
+		days = 7
+	default:
+		badRequest(w, "period must be daily or weekly")
+		return
+	}
+
+	nowLocal := time.Now().In(loc)
+	end := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), 0, 0, 0, 0, loc)
+	if d := r.URL.Query().Get("date"); d != "" {
+		day, err := time.ParseInLocation("2006-01-02", d, loc)
+		if err != nil {
+			badRequest(w, "date must be YYYY-MM-DD")
+			return
+		}
+		end = day.AddDate(0, 0, 1)
+	}
+	start := end.AddDate(0, 0, -days)
+
+	rows, err := a.db.Query(r.Context(), `SELECT rp.id::text, rp.name, f.severity, count(*)
+		FROM findings f JOIN repos rp ON rp.id = f.repo_id
+		WHERE rp.org=$1 AND f.created_at >= $2 AND f.created_at < $3
+		GROUP BY rp.id, rp.name, f.severity ORDER BY rp.name`, org, start, end)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	repos := make([]*digestRepo, 0)
+	byID := map[string]*digestRepo{}
+	totals := map[string]int{}
+	total := 0
+	for rows.Next() {
+		var id, name, sev string
+		var n int
+		if err := rows.Scan(&id, &name, &sev, &n); err != nil {
+			serverError(w, err)
+			return
+		}
+		dr, ok := byID[id]
+		if !ok {
+			dr = &digestRepo{RepoID: id, Name: name, BySev: map[string]int{}}
+			byID[id] = dr
+			repos = append(repos, dr)
+		}
+		dr.BySev[sev] += n
+		dr.Findings += n
+		totals[sev] += n
+		total += n
+	}
+
+	// Findings fixed in the window, and their mean time to resolve.
+	var fixed int
+	var mttr *float64
+	if err := a.db.QueryRow(r.Context(), `SELECT count(*), avg(extract(epoch FROM f.resolved_at - f.created_at) / 3600)
+		FROM findings f JOIN repos rp ON rp.id = f.repo_id
+		WHERE rp.org=$1 AND f.status='fixed' AND f.resolved_at >= $2 AND f.resolved_at < $3`, org, start, end).Scan(&fixed, &mttr); err != nil {
+		serverError(w, err)
+		return
+	}
+	var open int
+	if err := a.db.QueryRow(r.Context(), `SELECT count(*) FROM findings f JOIN repos rp ON rp.id = f.repo_id
+		WHERE rp.org=$1 AND f.status='open'`, org).Scan(&open); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	var scans int
+	if err := a.db.QueryRow(r.Context(), `SELECT count(*) FROM jobs j JOIN repos rp ON rp.id = j.repo_id
+		WHERE rp.org=$1 AND j.created_at >= $2 AND j.created_at < $3`, org, start, end).Scan(&scans); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"org":          org,
+		"period":       period,
+		"timezone":     loc.String(),
+		"window_start": start.Format(time.RFC3339),
+		"window_end":   end.Format(time.RFC3339),
+		"label":        digestLabel(start, end, loc),
+		"scans":        scans,
+		"findings":     total,
+		"by_severity":  totals,
+		"fixed":        fixed,
+		"mttr_hours":   mttr,
+		"open":         open,
+		"repos":        repos,
+	})
+}
+
+func digestLabel(start, end time.Time, loc *time.Location) string {
+	last := end.AddDate(0, 0, -1)
+	if start.Equal(last) {
+		return start.Format("Mon 2 Jan 2006") + " (" + loc.String() + ", " + start.Format("MST") + ")"
+	}
+	return start.Format("2 Jan") + " – " + last.Format("2 Jan 2006") + " (" + loc.String() + ")"
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// External refs name a repo in other systems, such as a service catalog ID,
+// a Backstage entity ref or a cost center, so that findings exported through
+// webhooks and feeds can be joined to ownership data downstream. Argus only
+// stores and passes them on.
+
+const (
+	maxExternalRefs     = 20
+	maxExternalRefValue = 256
+)
+
+var externalRefKey = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// normalizeExternalRefs validates refs set on a new repo.
+func normalizeExternalRefs(in map[string]string) (map[string]string, error) {
+	if len(in) > maxExternalRefs {
+		return nil, fmt.Errorf("a repo can have at most %d external_refs", maxExternalRefs)
+	}
+	out := map[string]string{}
+	for k, v := range in {
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		if err := validExternalRef(k, v); err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// externalRefsPatch splits an update into the refs to set and the keys to
+// remove: a null value removes its key, others are set.
+func externalRefsPatch(in map[string]*string) (set map[string]string, remove []string, err error) {
+	set, remove = map[string]string{}, []string{}
+	for k, v := range in {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if v == nil {
+			if !externalRefKey.MatchString(k) {
+				return nil, nil, fmt.Errorf("invalid external_refs key %q", k)
+			}
+			remove = append(remove, k)
+			continue
+		}
+		val := strings.TrimSpace(*v)
+		if err := validExternalRef(k, val); err != nil {
+			return nil, nil, err
+		}
+		set[k] = val
+	}
+	return set, remove, nil
+}
+
+func validExternalRef(k, v string) error {
+	if !externalRefKey.MatchString(k) {
+		return fmt.Errorf("invalid external_refs key %q (lowercase letters, digits, ., _ or -, at most 63)", k)
+	}
+	if v == "" || len(v) > maxExternalRefValue {
+		return fmt.Errorf("external_refs.%s must be 1 to %d characters", k, maxExternalRefValue)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"argus/api/internal/flags"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FeatureFlag is a flag with its default and overrides.
+type FeatureFlag struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Enabled     bool           `json:"enabled"`
+	Overrides   []FlagOverride `json:"overrides"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// FlagOverride turns a flag on or off for one org or repo.
+type FlagOverride struct {
+	Scope   string `json:"scope"`
+	Target  string `json:"target"`
+	Enabled bool   `json:"enabled"`
+}
+
+type putFlagReq struct {
+	Enabled     *bool   `json:"enabled"`
+	Description *string `json:"description"`
+}
+
+// listFlags returns every flag with its overrides.
+func (a *App) listFlags(w http.ResponseWriter, r *http.Request) {
+	rows, err := a.db.Query(r.Context(), `SELECT f.name, f.description, f.enabled, f.updated_at,
+		COALESCE(jsonb_agg(jsonb_build_object('scope', o.scope, 'target', o.target, 'enabled', o.enabled) ORDER BY o.scope, o.target) FILTER (WHERE o.flag IS NOT NULL), '[]')
+		FROM feature_flags f LEFT JOIN feature_flag_overrides o ON o.flag = f.name
+		GROUP BY f.name ORDER BY f.name`)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	out := make([]FeatureFlag, 0)
+	for rows.Next() {
+		var f FeatureFlag
+		if err := rows.Scan(&f.Name, &f.Description, &f.Enabled, &f.UpdatedAt, &f.Overrides); err != nil {
+			serverError(w, err)
+			return
+		}
+		out = append(out, f)
+	}
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"flags": out})
+}
+
+// putFlag creates a flag or updates the fields present in the body. A new
+// flag is off unless the body says otherwise.
+func (a *App) putFlag(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !flags.ValidName(name) {
+		badRequest(w, "invalid flag name (lowercase dotted words, such as scanner.trufflehog)")
+		return
+	}
+	var req putFlagReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	var f FeatureFlag
+	err := a.db.QueryRow(r.Context(), `INSERT INTO feature_flags (name, enabled, description) VALUES ($1, COALESCE($2, false), COALESCE($3, ''))
+		ON CONFLICT (name) DO UPDATE SET enabled=COALESCE($2, feature_flags.enabled), description=COALESCE($3, feature_flags.description), updated_at=now()
+		RETURNING name, description, enabled, updated_at`, name, req.Enabled, req.Description).
+		Scan(&f.Name, &f.Description, &f.Enabled, &f.UpdatedAt)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "feature flag set", "flag", name, "enabled", f.Enabled)
+	writeJSON(w, http.StatusOK, f)
+}
+
+// deleteFlag removes a flag and its overrides, which ungates what it
+// gated.
+func (a *App) deleteFlag(w http.ResponseWriter, r *http.Request) {
+	tag, err := a.db.Exec(r.Context(), `DELETE FROM feature_flags WHERE name=$1`, chi.URLParam(r, "name"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// flagOverrideTarget reads and checks an override's scope and target. Org
+// names are lowercased like everywhere else; repos must exist.
+func (a *App) flagOverrideTarget(w http.ResponseWriter, r *http.Request) (scope, target string, ok bool) {
+	scope, target = chi.URLParam(r, "scope"), chi.URLParam(r, "target")
+	switch scope {
+	case flags.ScopeOrg:
+		target = strings.ToLower(strings.TrimSpace(target))
+		if target == "" {
+			badRequest(w, "org is required")
+			return "", "", false
+		}
+	case flags.ScopeRepo:
+		var exists bool
+		if err := a.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM repos WHERE id::text=$1)`, target).Scan(&exists); err != nil {
+			serverError(w, err)
+			return "", "", false
+		}
+		if !exists {
+			notFound(w)
+			return "", "", false
+		}
+	default:
+		badRequest(w, "scope must be org or repo")
+		return "", "", false
+	}
+	return scope, target, true
+}
+
+// putFlagOverride turns a flag on or off for one org or repo.
+func (a *App) putFlagOverride(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	scope, target, ok := a.flagOverrideTarget(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		badRequest(w, "enabled is required")
+		return
+	}
+	tag, err := a.db.Exec(r.Context(), `INSERT INTO feature_flag_overrides (flag, scope, target, enabled)
+		SELECT name, $2, $3, $4 FROM feature_flags WHERE name=$1
+		ON CONFLICT (flag, scope, target) DO UPDATE SET enabled=EXCLUDED.enabled, updated_at=now()`, name, scope, target, *req.Enabled)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	slog.InfoContext(r.Context(), "feature flag override set", "flag", name, "scope", scope, "target", target, "enabled", *req.Enabled)
+	writeJSON(w, http.StatusOK, FlagOverride{Scope: scope, Target: target, Enabled: *req.Enabled})
+}
+
+// deleteFlagOverride returns an org or repo to the flag's next level.
+func (a *App) deleteFlagOverride(w http.ResponseWriter, r *http.Request) {
+	scope, target := chi.URLParam(r, "scope"), chi.URLParam(r, "target")
+	if scope == flags.ScopeOrg {
+		target = strings.ToLower(target)
+	}
+	tag, err := a.db.Exec(r.Context(), `DELETE FROM feature_flag_overrides WHERE flag=$1 AND scope=$2 AND target=$3`, chi.URLParam(r, "name"), scope, target)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveFlags shows every flag as ?repo_id= sees it, or the defaults
+// without one.
+func (a *App) resolveFlags(w http.ResponseWriter, r *http.Request) {
+	repoID := r.URL.Query().Get("repo_id")
+	if repoID != "" {
+		var exists bool
+		if err := a.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM repos WHERE id::text=$1)`, repoID).Scan(&exists); err != nil {
+			serverError(w, err)
+			return
+		}
+		if !exists {
+			notFound(w)
+			return
+		}
+	}
+	set, err := flags.ForRepo(r.Context(), a.db, repoID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"repo_id": nullIfEmpty(repoID), "flags": set})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"argus/api/internal/compliance"
+	"argus/api/internal/githubapp"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const feedLimit = 100
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	// Categories are the severity, then the compliance controls the
+	// finding maps to, each under its framework's scheme, then the repo's
+	// external refs, each under its system's.
+	Categories []atomCategory `xml:"category"`
+	Links      []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term   string `xml:"term,attr"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+}
+
+// complianceScheme prefixes a framework ID in feed category schemes, and
+// externalRefScheme the system of one of the repo's external refs.
+const (
+	complianceScheme  = "urn:argus:compliance:"
+	externalRefScheme = "urn:argus:external-ref:"
+)
+
+// feedAuthz accepts the bearer token as usual, or the feed token as a
+// ?token= query parameter because most feed readers cannot send custom
+// headers. Feed URLs end up in reader configs and proxy logs, so the query
+// parameter never takes the API token, and the feed token opens nothing but
+// the feeds.
+func (a *App) feedAuthz(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := a.cfg.Token
+		tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tok == "" {
+			want, tok = a.cfg.FeedToken, r.URL.Query().Get("token")
+		}
+		if want == "" || subtle.ConstantTimeCompare([]byte(tok), []byte(want)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) repoFindingsFeed(w http.ResponseWriter, r *http.Request) {
+	repoID := strings.TrimSuffix(chi.URLParam(r, "id"), ".atom")
+	var name, repoURL string
+	if err := a.db.QueryRow(r.Context(), `SELECT name, url FROM repos WHERE id=$1`, repoID).Scan(&name, &repoURL); err != nil {
+		notFound(w)
+		return
+	}
+	a.writeFindingsFeed(w, r, atomFeed{
+		ID:    "urn:argus:repo:" + repoID,
+		Title: "Argus findings: " + name,
+		Links: []atomLink{{Href: strings.TrimSuffix(repoURL, ".git"), Rel: "related"}},
+	}, `f.repo_id=$1`, repoID)
+}
+
+func (a *App) orgFindingsFeed(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(strings.TrimSuffix(chi.URLParam(r, "org"), ".atom"))
+	var exists bool
+	if err := a.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM repos WHERE org=$1)`, org).Scan(&exists); err != nil {
+		serverError(w, err)
+		return
+	}
+	if !exists {
+		notFound(w)
+		return
+	}
+	a.writeFindingsFeed(w, r, atomFeed{
+		ID:    "urn:argus:org:" + org,
+		Title: "Argus findings: " + org,
+		Links: []atomLink{{Href: a.cfg.GitHubURL + "/" + org, Rel: "related"}},
+	}, `rp.org=$1`, org)
+}
+
+func (a *App) writeFindingsFeed(w http.ResponseWriter, r *http.Request, feed atomFeed, where, arg string) {
+	rows, err := a.db.Query(r.Context(), `SELECT f.id::text, f.tool::text, f.severity, f.title, COALESCE(f.file_path,''), COALESCE(f.line_start,0), COALESCE(f.line_end,0), COALESCE(f.description,''), f.created_at, rp.name, rp.url, COALESCE(j.commit_sha,''), f.evidence_json, rp.external_refs
+		FROM findings f JOIN repos rp ON rp.id = f.repo_id JOIN jobs j ON j.id = f.job_id
+		WHERE `+where+` AND f.status='open' ORDER BY f.created_at DESC LIMIT $2`, arg, feedLimit)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	updated := time.Unix(0, 0).UTC()
+	feed.Entries = make([]atomEntry, 0)
+	for rows.Next() {
+		var id, tool, sev, title, file, desc, repoName, repoURL, sha string
+		var line, lineEnd int
+		var created time.Time
+		var evidence json.RawMessage
+		var refs map[string]string
+		if err := rows.Scan(&id, &tool, &sev, &title, &file, &line, &lineEnd, &desc, &created, &repoName, &repoURL, &sha, &evidence, &refs); err != nil {
+			serverError(w, err)
+			return
+		}
+		if created.After(updated) {
+			updated = created
+		}
+		location := file
+		if line > 0 {
+			location = fmt.Sprintf("%s:%d", file, line)
+		}
+		summary := fmt.Sprintf("%s %s finding in %s", sev, tool, repoName)
+		if location != "" {
+			summary += " at " + location
+		}
+		if desc != "" {
+			summary += "\n\n" + desc
+		}
+		links := []atomLink{{Href: strings.TrimSuffix(repoURL, ".git"), Rel: "related"}}
+		if link := githubapp.Permalink(repoURL, sha, file, line, lineEnd); link != "" {
+			links = append([]atomLink{{Href: link, Rel: "alternate"}}, links...)
+		}
+		categories := []atomCategory{{Term: strings.ToLower(sev)}}
+		for _, c := range compliance.Controls(complianceFinding(tool, evidence), "") {
+			categories = append(categories, atomCategory{Term: c.ID, Scheme: complianceScheme + c.Framework})
+		}
+		systems := make([]string, 0, len(refs))
+		for k := range refs {
+			systems = append(systems, k)
+		}
+		sort.Strings(systems)
+		for _, k := range systems {
+			categories = append(categories, atomCategory{Term: refs[k], Scheme: externalRefScheme + k})
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:         "urn:uuid:" + id,
+			Title:      fmt.Sprintf("[%s] %s", sev, title),
+			Updated:    created.UTC().Format(time.RFC3339),
+			Author:     atomAuthor{Name: "Argus (" + tool + ")"},
+			Categories: categories,
+			Links:      links,
+			Summary:    summary,
+		})
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(feed)
+}
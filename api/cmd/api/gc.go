@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+const defaultUploadRetentionHours = 7 * 24
+
+// gcTarget is one class of orphaned rows. where is evaluated against table
+// and may use $1, the upload retention in hours.
+type gcTarget struct {
+	name, table, where string
+}
+
+var gcTargets = []gcTarget{
+	{"findings", "findings", `NOT EXISTS (SELECT 1 FROM jobs j WHERE j.id = findings.job_id)
+		OR (findings.repo_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM repos r WHERE r.id = findings.repo_id))`},
+	{"job_artifacts", "job_artifacts", `NOT EXISTS (SELECT 1 FROM jobs j WHERE j.id = job_artifacts.job_id)`},
+	{"prs", "prs", `NOT EXISTS (SELECT 1 FROM repos r WHERE r.id = prs.repo_id)`},
+	// Archives of finished upload jobs are kept for a while so the jobs can
+	// still be requeued or replayed.
+	{"uploads", "uploads", `NOT EXISTS (SELECT 1 FROM jobs j WHERE j.id = uploads.job_id)
+		OR EXISTS (SELECT 1 FROM jobs j WHERE j.id = uploads.job_id AND j.status IN ('succeeded','failed')
+			AND j.finished_at < now() - make_interval(hours => $1))`},
+	// Workers delete an image scan's registry credentials when it finishes;
+	// those of scans whose worker died go with the upload archives.
+	{"image_credentials", "image_credentials", `NOT EXISTS (SELECT 1 FROM jobs j WHERE j.id = image_credentials.job_id AND (j.status IN ('queued','running')
+		OR j.finished_at >= now() - make_interval(hours => $1)))`},
+	// Delivery IDs of inbound webhooks, once replay protection has lapsed.
+	{"webhook_nonces", "webhook_nonces", `expires_at < now()`},
+}
+
+// gcObjects are the columns of gcTargets' tables that hold an artifact
+// store key; the object goes with the row.
+var gcObjects = map[string]string{"job_artifacts": "object_key"}
+
+type gcReq struct {
+	DryRun bool `json:"dry_run"`
+	// UploadRetentionHours is how long archives of finished upload jobs
+	// are kept.
+	UploadRetentionHours int `json:"upload_retention_hours"`
+}
+
+// runGC removes orphaned rows and queue messages that reference jobs which
+// no longer exist, and reports what it removed (or would remove, with
+// dry_run). Workers clean up their own leftover workspaces.
+func (a *App) runGC(w http.ResponseWriter, r *http.Request) {
+	var req gcReq
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, "invalid json")
+			return
+		}
+	}
+	if req.UploadRetentionHours < 0 {
+		badRequest(w, "upload_retention_hours must be >= 0")
+		return
+	}
+	if req.UploadRetentionHours == 0 {
+		req.UploadRetentionHours = defaultUploadRetentionHours
+	}
+
+	ctx := r.Context()
+	removed := map[string]int64{}
+	for _, t := range gcTargets {
+		sql := `DELETE FROM ` + t.table + ` WHERE ` + t.where
+		if req.DryRun {
+			sql = `SELECT count(*) FROM ` + t.table + ` WHERE ` + t.where
+		}
+		args := []any{}
+		if t.name == "uploads" || t.name == "image_credentials" {
+			args = append(args, req.UploadRetentionHours)
+		}
+		var n int64
+		if req.DryRun {
+			if err := a.db.QueryRow(ctx, sql, args...).Scan(&n); err != nil {
+				serverError(w, err)
+				return
+			}
+		} else if col := gcObjects[t.table]; col != "" {
+			var objects []string
+			sql = `WITH d AS (` + sql + ` RETURNING ` + col + `)
+				SELECT count(*), COALESCE(array_agg(` + col + `) FILTER (WHERE ` + col + ` IS NOT NULL), '{}') FROM d`
+			if err := a.db.QueryRow(ctx, sql, args...).Scan(&n, &objects); err != nil {
+				serverError(w, err)
+				return
+			}
+			a.deleteObjects(ctx, objects)
+		} else {
+			tag, err := a.db.Exec(ctx, sql, args...)
+			if err != nil {
+				serverError(w, err)
+				return
+			}
+			n = tag.RowsAffected()
+		}
+		removed[t.name] = n
+	}
+
+	for name, set := range map[string]string{
+		"queue_messages":   queueWaiting,
+		"delayed_messages": queueDelayed,
+		"dead_letters":     queueDead,
+	} {
+		n, err := a.gcQueue(ctx, set, req.DryRun)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		removed[name] = n
+	}
+
+	slog.InfoContext(ctx, "garbage collection finished", "dry_run", req.DryRun, "removed", removed)
+	writeJSON(w, http.StatusOK, map[string]any{"dry_run": req.DryRun, "removed": removed})
+}
+
+// missingJobs returns the subset of ids without a jobs row.
+func (a *App) missingJobs(ctx context.Context, ids []string) (map[string]bool, error) {
+	missing := map[string]bool{}
+	for _, id := range ids {
+		missing[id] = true
+	}
+	if len(ids) == 0 {
+		return missing, nil
+	}
+	rows, err := a.db.Query(ctx, `SELECT id::text FROM jobs WHERE id::text = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		delete(missing, id)
+	}
+	return missing, rows.Err()
+}
+
+// staleMessages picks the raw messages whose job no longer exists. Messages
+// without a job ID are left for the worker, which dead-letters them.
+func (a *App) staleMessages(ctx context.Context, raws []string) ([]string, error) {
+	ids := make([]string, 0, len(raws))
+	for _, raw := range raws {
+		if id := messageJobID(raw); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	missing, err := a.missingJobs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, raw := range raws {
+		if id := messageJobID(raw); id != "" && missing[id] {
+			stale = append(stale, raw)
+		}
+	}
+	return stale, nil
+}
+
+func messageJobID(raw string) string {
+	var m struct {
+		JobID string `json:"job_id"`
+	}
+	_ = json.Unmarshal([]byte(raw), &m)
+	return m.JobID
+}
+
+// gcQueue removes the messages of a queue set whose job no longer exists.
+func (a *App) gcQueue(ctx context.Context, set string, dryRun bool) (int64, error) {
+	raws, err := a.queue.Messages(ctx, set)
+	if err != nil {
+		return 0, err
+	}
+	stale, err := a.staleMessages(ctx, raws)
+	if err != nil || dryRun {
+		return int64(len(stale)), err
+	}
+	var n int64
+	for _, raw := range stale {
+		c, err := a.queue.Remove(ctx, set, raw)
+		if err != nil {
+			return n, err
+		}
+		n += c
+	}
+	return n, nil
+}
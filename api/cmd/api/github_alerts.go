@@ -0,0 +1,344 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/api/internal/githubapp"
+	"argus/internal/forge"
+	"argus/internal/jobsql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Alerts GitHub raises on its own are imported as findings, so a repo's
+// findings cover its code and dependency risk in one place: Dependabot's
+// as the dependabot tool and secret scanning's as secret-scanning, next to
+// gitleaks and trufflehog. Each import is recorded as an 'imported' job of
+// the repo holding the alerts it found; open findings of the tool that it no
+// longer lists are fixed, as after a default-branch scan. Secret scanning
+// alerts are imported in every state, resolved ones as fixed findings.
+// POST /repos/{id}/github-alerts/sync imports a repo's alerts, and
+// github.alerts_sync_interval imports every repo's periodically.
+
+const (
+	toolDependabot     = "dependabot"
+	toolSecretScanning = "secret-scanning"
+)
+
+const (
+	// alertSyncTick is how often the periodic sync looks for due repos.
+	alertSyncTick = time.Minute
+	// alertSyncBatch bounds the repos one tick imports.
+	alertSyncBatch = 20
+)
+
+// alertImport is the outcome of importing one tool's alerts. Error is set
+// when they could not be imported, such as when the repo has the feature
+// off or the app lacks its permission.
+type alertImport struct {
+	Tool     string `json:"tool"`
+	JobID    string `json:"job_id,omitempty"`
+	Imported int    `json:"imported"`
+	Resolved int64  `json:"resolved"`
+	Error    string `json:"error,omitempty"`
+}
+
+// importedFinding is an alert as a finding; ResolvedAt is set for alerts
+// GitHub lists as resolved, which are stored fixed.
+type importedFinding struct {
+	internalFinding
+	ResolvedAt *time.Time
+}
+
+// syncGitHubAlerts serves POST /repos/{id}/github-alerts/sync.
+func (a *App) syncGitHubAlerts(w http.ResponseWriter, r *http.Request) {
+	if a.cfg.Offline {
+		badRequest(w, "importing GitHub alerts needs the GitHub API and is disabled in offline mode")
+		return
+	}
+	ctx, repoID := r.Context(), chi.URLParam(r, "id")
+	var url string
+	if err := a.db.QueryRow(ctx, `SELECT url FROM repos WHERE id=$1`, repoID).Scan(&url); err != nil {
+		notFound(w)
+		return
+	}
+	if !forge.GitHub(a.cfg.GitHubURL).Owns(url) {
+		badRequest(w, "repo is not on "+forge.GitHub(a.cfg.GitHubURL).URL())
+		return
+	}
+	imports, err := a.importGitHubAlerts(ctx, repoID, url)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": "import github alerts: " + err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"repo_id": repoID, "imports": imports})
+}
+
+// importGitHubAlerts imports the repo's open Dependabot alerts and all its
+// secret scanning alerts. A tool whose alerts cannot be listed is reported
+// in its import and leaves its findings as they were.
+func (a *App) importGitHubAlerts(ctx context.Context, repoID, repoURL string) ([]alertImport, error) {
+	owner, name, err := githubapp.ParseGitHubURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	gh, err := a.githubApp(ctx, repoURL, 0)
+	if err != nil {
+		return nil, err
+	}
+	token, err := gh.InstallationToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var salt string
+	if err := a.db.QueryRow(ctx, `SELECT COALESCE(o.fingerprint_salt,'') FROM repos r LEFT JOIN orgs o ON o.name = r.org WHERE r.id=$1`, repoID).Scan(&salt); err != nil {
+		return nil, err
+	}
+
+	imports := []alertImport{
+		a.importAlerts(ctx, repoID, toolDependabot, func() ([]importedFinding, error) {
+			alerts, err := gh.ListDependabotAlerts(ctx, owner, name, "open", token)
+			if err != nil {
+				return nil, err
+			}
+			findings := make([]importedFinding, 0, len(alerts))
+			for _, al := range alerts {
+				findings = append(findings, importedFinding{internalFinding: dependabotFinding(salt, al)})
+			}
+			return findings, nil
+		}),
+		a.importAlerts(ctx, repoID, toolSecretScanning, func() ([]importedFinding, error) {
+			alerts, err := gh.ListSecretScanningAlerts(ctx, owner, name, "", token)
+			if err != nil {
+				return nil, err
+			}
+			findings := make([]importedFinding, 0, len(alerts))
+			for _, al := range alerts {
+				var loc *githubapp.SecretLocation
+				// Only open secrets are located, which takes a request each.
+				if al.State == "open" {
+					if loc, err = gh.FirstSecretLocation(ctx, owner, name, al.Number, token); err != nil {
+						return nil, err
+					}
+				}
+				findings = append(findings, secretScanningFinding(salt, al, loc))
+			}
+			return findings, nil
+		}),
+	}
+	return imports, nil
+}
+
+// importAlerts imports the findings list returns as tool's.
+func (a *App) importAlerts(ctx context.Context, repoID, tool string, list func() ([]importedFinding, error)) alertImport {
+	findings, err := list()
+	imp := alertImport{Tool: tool}
+	if err == nil {
+		imp, err = a.importFindings(ctx, repoID, tool, findings)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "github alerts not imported", "repo_id", repoID, "tool", tool, "err", err)
+		return alertImport{Tool: tool, Error: err.Error()}
+	}
+	slog.InfoContext(ctx, "github alerts imported", "repo_id", repoID, "tool", tool, "job_id", imp.JobID, "imported", imp.Imported, "resolved", imp.Resolved)
+	return imp
+}
+
+// importFindings records an import of tool's findings as a job of the repo
+// and fixes the tool's open findings it does not include.
+func (a *App) importFindings(ctx context.Context, repoID, tool string, findings []importedFinding) (alertImport, error) {
+	imp := alertImport{Tool: tool, Imported: len(findings)}
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return imp, err
+	}
+	defer tx.Rollback(ctx)
+	if err := tx.QueryRow(ctx, `INSERT INTO jobs (repo_id, status, source, scanners, started_at, finished_at)
+		VALUES ($1,'imported',$2,ARRAY[$2],now(),now()) RETURNING id::text`, repoID, tool).Scan(&imp.JobID); err != nil {
+		return imp, err
+	}
+	if len(findings) > 0 {
+		b := &pgx.Batch{}
+		for _, f := range findings {
+			b.Queue(jobsql.InsertFinding,
+				repoID, imp.JobID, f.Tool, f.Severity, f.Title, f.FilePath, f.LineStart, f.LineEnd, f.Fingerprint, f.Description, []byte(f.Evidence))
+			if f.ResolvedAt != nil {
+				b.Queue(`UPDATE findings SET status='fixed', resolved_at=$3 WHERE repo_id=$1 AND fingerprint=$2`, repoID, f.Fingerprint, *f.ResolvedAt)
+			}
+		}
+		if err := tx.SendBatch(ctx, b).Close(); err != nil {
+			return imp, err
+		}
+	}
+	tag, err := tx.Exec(ctx, jobsql.ResolveFindings, repoID, imp.JobID, []string{tool})
+	if err != nil {
+		return imp, err
+	}
+	imp.Resolved = tag.RowsAffected()
+	return imp, tx.Commit(ctx)
+}
+
+// dependabotFinding maps an alert onto a finding fingerprinted by its
+// number, which GitHub keeps for the alert's lifetime.
+func dependabotFinding(salt string, al githubapp.DependabotAlert) internalFinding {
+	adv, pkg := al.SecurityAdvisory, al.Dependency.Package
+	advisory := cmp.Or(adv.CVEID, adv.GHSAID)
+	fp := alertFingerprint(salt, toolDependabot, strconv.Itoa(al.Number))
+	f := internalFinding{
+		Tool:        toolDependabot,
+		Severity:    alertSeverity(adv.Severity),
+		Title:       advisory + " in " + pkg.Name,
+		Fingerprint: &fp,
+		Description: &adv.Summary,
+	}
+	if al.Dependency.ManifestPath != "" {
+		f.FilePath = &al.Dependency.ManifestPath
+	}
+	ev := map[string]any{
+		"id": adv.GHSAID, "alert_number": al.Number, "url": al.HTMLURL, "pkg": pkg.Name, "ecosystem": pkg.Ecosystem,
+		"vulnerable_range": al.SecurityVulnerability.VulnerableVersionRange, "scope": al.Dependency.Scope,
+	}
+	if adv.CVEID != "" {
+		ev["cve"] = adv.CVEID
+	}
+	if p := al.SecurityVulnerability.FirstPatchedVersion; p != nil {
+		ev["fixed"] = p.Identifier
+	}
+	if adv.CVSS.VectorString != "" {
+		ev["cvss"] = adv.CVSS.VectorString
+	}
+	var cwes []string
+	for _, c := range adv.CWEs {
+		cwes = append(cwes, c.CWEID)
+	}
+	if len(cwes) > 0 {
+		ev["cwes"] = cwes
+	}
+	f.Evidence, _ = json.Marshal(ev)
+	return f
+}
+
+// secretScanningFinding maps an alert onto a finding fingerprinted by its
+// number, at loc when the secret was found in a file. The secret is not
+// kept. Resolved alerts are fixed as of their resolution.
+func secretScanningFinding(salt string, al githubapp.SecretScanningAlert, loc *githubapp.SecretLocation) importedFinding {
+	fp := alertFingerprint(salt, toolSecretScanning, strconv.Itoa(al.Number))
+	kind := cmp.Or(al.SecretTypeDisplayName, al.SecretType)
+	desc := kind + " found by GitHub secret scanning."
+	f := importedFinding{internalFinding: internalFinding{
+		Tool:        toolSecretScanning,
+		Severity:    "HIGH",
+		Title:       "Secret detected: " + kind,
+		Fingerprint: &fp,
+		Description: &desc,
+	}}
+	ev := map[string]any{
+		"id": al.SecretType, "alert_number": al.Number, "url": al.HTMLURL, "state": al.State, "validity": al.Validity,
+		"push_protection_bypassed": al.PushProtectionBypassed, "redacted": true,
+	}
+	if loc != nil && loc.Type == "commit" && loc.Details.Path != "" {
+		f.FilePath = &loc.Details.Path
+		if loc.Details.StartLine > 0 {
+			f.LineStart, f.LineEnd = &loc.Details.StartLine, &loc.Details.EndLine
+		}
+		ev["commit_sha"] = loc.Details.CommitSHA
+	}
+	if al.State == "resolved" {
+		ev["resolution"] = al.Resolution
+		if al.ResolvedBy != nil {
+			ev["resolved_by"] = al.ResolvedBy.Login
+		}
+		f.ResolvedAt = al.ResolvedAt
+		if f.ResolvedAt == nil {
+			now := time.Now()
+			f.ResolvedAt = &now
+		}
+	}
+	f.Evidence, _ = json.Marshal(ev)
+	return f
+}
+
+// alertSeverity maps GitHub's lowercase severities onto Argus's; anything
+// unrated is MEDIUM.
+func alertSeverity(sev string) string {
+	switch sev = strings.ToUpper(sev); sev {
+	case "CRITICAL", "HIGH", "MEDIUM", "LOW":
+		return sev
+	}
+	return "MEDIUM"
+}
+
+// alertFingerprint matches the worker's fingerprints: the SHA-256 of parts,
+// keyed with the org's salt when it has one.
+func alertFingerprint(salt string, parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	fp := hex.EncodeToString(h.Sum(nil))
+	if salt == "" {
+		return fp
+	}
+	m := hmac.New(sha256.New, []byte(salt))
+	m.Write([]byte(fp))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// runAlertSync imports the alerts of each repo on github.url once per
+// interval until ctx is cancelled. Repos are claimed with SKIP LOCKED, so
+// several API replicas can run it safely.
+func (a *App) runAlertSync(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(alertSyncTick)
+	defer t.Stop()
+	for {
+		if err := a.syncDueAlerts(ctx, interval); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "github alert sync failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (a *App) syncDueAlerts(ctx context.Context, interval time.Duration) error {
+	rows, err := a.db.Query(ctx, `UPDATE repos SET alerts_synced_at=now() WHERE id IN (
+			SELECT id FROM repos WHERE alerts_synced_at IS NULL OR alerts_synced_at < now() - make_interval(secs => $1)
+			ORDER BY alerts_synced_at NULLS FIRST LIMIT $2 FOR UPDATE SKIP LOCKED)
+		RETURNING id::text, url`, interval.Seconds(), alertSyncBatch)
+	if err != nil {
+		return err
+	}
+	type due struct{ id, url string }
+	repos, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (due, error) {
+		var d due
+		err := row.Scan(&d.id, &d.url)
+		return d, err
+	})
+	if err != nil {
+		return err
+	}
+	host := forge.GitHub(a.cfg.GitHubURL)
+	for _, d := range repos {
+		if !host.Owns(d.url) {
+			continue
+		}
+		if _, err := a.importGitHubAlerts(ctx, d.id, d.url); err != nil {
+			slog.WarnContext(ctx, "github alerts not imported", "repo_id", d.id, "err", err)
+		}
+	}
+	return nil
+}
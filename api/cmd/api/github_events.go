@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"argus/api/internal/changescope"
+	"argus/api/internal/webhookverify"
+	"argus/internal/forge"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GitHub App event deliveries arrive at POST /webhooks/github, verified
+// against github.webhook_secret. Installation events register the repos the
+// app can read; pushes to a repo's default branch and pull request updates
+// scan them, so repos need no schedule or CI step to be scanned.
+
+// githubPushCommitLimit is how many commits GitHub lists in a push event;
+// a push with that many may have changed paths that are not listed.
+const githubPushCommitLimit = 20
+
+// githubScanActions are the pull_request actions that change what the head
+// of the pull request holds.
+var githubScanActions = []string{"opened", "synchronize", "reopened"}
+
+type githubEventRepo struct {
+	FullName string `json:"full_name"`
+}
+
+// githubEvent is the part of an event payload Argus reads; which fields are
+// set depends on the event.
+type githubEvent struct {
+	Action       string `json:"action"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+	// installation and installation_repositories events.
+	Repositories        []githubEventRepo `json:"repositories"`
+	RepositoriesAdded   []githubEventRepo `json:"repositories_added"`
+	RepositoriesRemoved []githubEventRepo `json:"repositories_removed"`
+	// push and pull_request events.
+	Repository struct {
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Ref     string `json:"ref"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+	Number      int `json:"number"`
+	PullRequest struct {
+		Head struct {
+			Ref  string          `json:"ref"`
+			SHA  string          `json:"sha"`
+			Repo githubEventRepo `json:"repo"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+// eventResult tells the code host's delivery log what came of an event.
+type eventResult struct {
+	Status string   `json:"status"` // registered, unregistered, queued, skipped or ignored
+	Reason string   `json:"reason,omitempty"`
+	JobID  string   `json:"job_id,omitempty"`
+	Repos  []string `json:"repos,omitempty"`
+}
+
+func eventIgnored(reason string) eventResult {
+	return eventResult{Status: "ignored", Reason: reason}
+}
+
+// webhookVerifier checks deliveries of scheme against secrets, remembering
+// delivery IDs in Redis when the queue uses it and in Postgres otherwise.
+func (a *App) webhookVerifier(scheme webhookverify.Scheme, secrets []string) *webhookverify.Verifier {
+	var nonces webhookverify.NonceStore = webhookverify.PostgresNonces{DB: a.db}
+	if q, ok := a.queue.(*redisQueue); ok {
+		nonces = webhookverify.RedisNonces{Client: q.rdb}
+	}
+	return webhookverify.New(scheme, secrets, nonces)
+}
+
+// receiveGitHubEvent serves POST /webhooks/github.
+func (a *App) receiveGitHubEvent(w http.ResponseWriter, r *http.Request) {
+	var ev githubEvent
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	ctx := r.Context()
+	name := r.Header.Get("X-GitHub-Event")
+	var res eventResult
+	var err error
+	switch name {
+	case "ping":
+		res = eventResult{Status: "ok"}
+	case "installation":
+		res, err = a.githubInstallation(ctx, ev)
+	case "installation_repositories":
+		res, err = a.githubInstallationRepos(ctx, ev)
+	case "push":
+		res, err = a.githubPush(ctx, ev)
+	case "pull_request":
+		res, err = a.githubPullRequest(ctx, ev)
+	default:
+		res = eventIgnored("event " + name + " is not handled")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "github event failed", "event", name, "action", ev.Action, "delivery", r.Header.Get("X-GitHub-Delivery"), "err", err)
+		serverError(w, err)
+		return
+	}
+	slog.InfoContext(ctx, "github event", "event", name, "action", ev.Action, "delivery", r.Header.Get("X-GitHub-Delivery"), "status", res.Status)
+	status := http.StatusOK
+	if res.Status == "queued" {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, res)
+}
+
+// githubInstallation registers the repos of a new installation and drops a
+// deleted one from its repos.
+func (a *App) githubInstallation(ctx context.Context, ev githubEvent) (eventResult, error) {
+	switch ev.Action {
+	case "created":
+		return a.registerGitHubRepos(ctx, ev.Installation.ID, ev.Repositories)
+	case "deleted":
+		if _, err := a.db.Exec(ctx, `UPDATE repos SET installation_id=NULL WHERE installation_id=$1`, ev.Installation.ID); err != nil {
+			return eventResult{}, err
+		}
+		return eventResult{Status: "unregistered"}, nil
+	}
+	return eventIgnored("installation action " + ev.Action + " is not handled"), nil
+}
+
+// githubInstallationRepos follows repos being added to or removed from an
+// installation.
+func (a *App) githubInstallationRepos(ctx context.Context, ev githubEvent) (eventResult, error) {
+	switch ev.Action {
+	case "added":
+		return a.registerGitHubRepos(ctx, ev.Installation.ID, ev.RepositoriesAdded)
+	case "removed":
+		res := eventResult{Status: "unregistered"}
+		for _, repo := range ev.RepositoriesRemoved {
+			if _, err := a.db.Exec(ctx, `UPDATE repos SET installation_id=NULL WHERE installation_id=$1 AND lower(url)=lower($2)`,
+				ev.Installation.ID, a.githubCloneURL(repo.FullName)); err != nil {
+				return eventResult{}, err
+			}
+			res.Repos = append(res.Repos, repo.FullName)
+		}
+		return res, nil
+	}
+	return eventIgnored("installation_repositories action " + ev.Action + " is not handled"), nil
+}
+
+func (a *App) registerGitHubRepos(ctx context.Context, installationID int64, repos []githubEventRepo) (eventResult, error) {
+	res := eventResult{Status: "registered"}
+	for _, repo := range repos {
+		if _, err := a.registerGitHubRepo(ctx, repo.FullName, installationID); err != nil {
+			return eventResult{}, err
+		}
+		res.Repos = append(res.Repos, repo.FullName)
+	}
+	return res, nil
+}
+
+// registerGitHubRepo returns the repo for owner/name, recording that the
+// installation can read it.
+func (a *App) registerGitHubRepo(ctx context.Context, fullName string, installationID int64) (string, error) {
+	url := a.githubCloneURL(fullName)
+	return a.registerRepo(ctx, fullName, url, repoOwner(url), installationID)
+}
+
+// registerRepo returns the repo cloned from url, recording the GitHub App
+// installation that can read it unless installationID is 0. A repo Argus
+// does not know yet is added as name in owner's org.
+func (a *App) registerRepo(ctx context.Context, name, url, owner string, installationID int64) (string, error) {
+	if owner == "" || !a.isAllowedGitURL(url) {
+		return "", errors.New("repository " + name + " is not on an allowed git host")
+	}
+	var id string
+	err := a.db.QueryRow(ctx, `UPDATE repos SET installation_id=COALESCE(NULLIF($2::bigint, 0), installation_id)
+		WHERE lower(url)=lower($1) RETURNING id::text`, url, installationID).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+	if err := a.db.QueryRow(ctx, `INSERT INTO repos (name, url, org, installation_id) VALUES ($1,$2,$3,NULLIF($4::bigint, 0)) RETURNING id::text`,
+		name, url, owner, installationID).Scan(&id); err != nil {
+		return "", err
+	}
+	if _, err := a.db.Exec(ctx, `INSERT INTO orgs (name) VALUES ($1) ON CONFLICT DO NOTHING`, owner); err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "repo registered from webhook", "repo_id", id, "repo", name, "installation_id", installationID)
+	return id, nil
+}
+
+// githubPush scans pushes to the repo's default branch; other branches are
+// scanned through their pull requests.
+func (a *App) githubPush(ctx context.Context, ev githubEvent) (eventResult, error) {
+	branch, ok := strings.CutPrefix(ev.Ref, "refs/heads/")
+	switch {
+	case !ok:
+		return eventIgnored("not a branch push"), nil
+	case ev.Deleted:
+		return eventIgnored("branch deleted"), nil
+	case branch != ev.Repository.DefaultBranch:
+		return eventIgnored("push to " + branch + ", not the default branch"), nil
+	}
+	repoID, err := a.registerGitHubRepo(ctx, ev.Repository.FullName, ev.Installation.ID)
+	if err != nil {
+		return eventResult{}, err
+	}
+	var changed []string
+	if len(ev.Commits) < githubPushCommitLimit {
+		for _, c := range ev.Commits {
+			changed = append(changed, c.Added...)
+			changed = append(changed, c.Modified...)
+			changed = append(changed, c.Removed...)
+		}
+	}
+	return a.scanPush(ctx, repoID, branch, ev.After, changed)
+}
+
+// scanPush scans a push of sha to branch, the code host's default branch.
+// changed are the paths the push changed, nil when they are not known; they
+// go through skip.paths as for any scan request.
+//
+// A repo without a default_ref takes branch as its default_ref. A push to
+// the default_ref is a full scan, not pinned to sha, so it resolves the
+// findings it no longer sees and counts for posture and group gates; the
+// worker scans the branch's tip, which is sha or a later push. A repo
+// pinned to another default_ref gets a scan of sha on branch.
+func (a *App) scanPush(ctx context.Context, repoID, branch, sha string, changed []string) (eventResult, error) {
+	var defaultRef string
+	if err := a.db.QueryRow(ctx, `UPDATE repos SET default_ref=COALESCE(default_ref, $2) WHERE id=$1 RETURNING default_ref`, repoID, branch).Scan(&defaultRef); err != nil {
+		return eventResult{}, err
+	}
+	opts := scanOptions{Priority: priorityNormal}
+	if defaultRef != branch {
+		opts.Ref, opts.CommitSHA = branch, sha
+	}
+	scope := changescope.Policy{SkipPaths: a.cfg.SkipPaths, Scanners: a.cfg.SkipScanners}.Decide(changed, nil)
+	if scope.Skip {
+		jobID, err := a.recordSkippedScan(ctx, repoID, opts, scope.Reason)
+		return eventResult{Status: "skipped", Reason: scope.Reason, JobID: jobID}, err
+	}
+	opts.Scanners = scope.Scanners
+	jobID, err := a.enqueueScan(ctx, repoID, opts)
+	if err != nil {
+		return eventResult{}, err
+	}
+	return eventResult{Status: "queued", JobID: jobID}, nil
+}
+
+// githubPullRequest scans the head of a pull request when it is opened or
+// updated. Heads in forks are not scanned: workers clone the base repo,
+// which does not have the fork's branch.
+func (a *App) githubPullRequest(ctx context.Context, ev githubEvent) (eventResult, error) {
+	if !contains(githubScanActions, ev.Action) {
+		return eventIgnored("pull_request action " + ev.Action + " is not handled"), nil
+	}
+	head := ev.PullRequest.Head
+	if !strings.EqualFold(head.Repo.FullName, ev.Repository.FullName) {
+		return eventIgnored("head is in a fork"), nil
+	}
+	repoID, err := a.registerGitHubRepo(ctx, ev.Repository.FullName, ev.Installation.ID)
+	if err != nil {
+		return eventResult{}, err
+	}
+	jobID, err := a.enqueueScan(ctx, repoID, scanOptions{Priority: priorityHigh, Ref: head.Ref, CommitSHA: head.SHA, PullRequest: ev.Number})
+	if err != nil {
+		return eventResult{}, err
+	}
+	return eventResult{Status: "queued", JobID: jobID}, nil
+}
+
+// githubCloneURL is the HTTPS clone URL of owner/name on github.url.
+func (a *App) githubCloneURL(fullName string) string {
+	return forge.GitHub(a.cfg.GitHubURL).CloneURL(fullName)
+}
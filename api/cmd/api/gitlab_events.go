@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"argus/internal/forge"
+)
+
+// GitLab webhook deliveries arrive at POST /webhooks/gitlab, verified
+// against gitlab.webhook_secret. Like GitHub's, pushes to a project's
+// default branch and merge request updates register the project and scan
+// it. GitLab has no app installations, so the hook is added to each project
+// or group to be scanned.
+
+// gitlabScanActions are the merge request actions that can change what the
+// source branch holds; updates only count when they push commits.
+var gitlabScanActions = []string{"open", "reopen", "update"}
+
+type gitlabEventProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+}
+
+// gitlabEvent is the part of a Push Hook or Merge Request Hook payload
+// Argus reads.
+type gitlabEvent struct {
+	ObjectKind string             `json:"object_kind"`
+	Project    gitlabEventProject `json:"project"`
+	// Push Hook.
+	Ref               string `json:"ref"`
+	After             string `json:"after"`
+	CheckoutSHA       string `json:"checkout_sha"` // empty when the branch was deleted
+	TotalCommitsCount int    `json:"total_commits_count"`
+	Commits           []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+	// Merge Request Hook.
+	ObjectAttributes struct {
+		IID             int    `json:"iid"`
+		Action          string `json:"action"`
+		SourceBranch    string `json:"source_branch"`
+		SourceProjectID int64  `json:"source_project_id"`
+		TargetProjectID int64  `json:"target_project_id"`
+		OldRev          string `json:"oldrev"` // set on updates that push commits
+		LastCommit      struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// receiveGitLabEvent serves POST /webhooks/gitlab.
+func (a *App) receiveGitLabEvent(w http.ResponseWriter, r *http.Request) {
+	var ev gitlabEvent
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	ctx := r.Context()
+	name := r.Header.Get("X-Gitlab-Event")
+	var res eventResult
+	var err error
+	switch name {
+	case "Push Hook":
+		res, err = a.gitlabPush(ctx, ev)
+	case "Merge Request Hook":
+		res, err = a.gitlabMergeRequest(ctx, ev)
+	default:
+		res = eventIgnored("event " + name + " is not handled")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "gitlab event failed", "event", name, "delivery", r.Header.Get("X-Gitlab-Event-UUID"), "err", err)
+		serverError(w, err)
+		return
+	}
+	slog.InfoContext(ctx, "gitlab event", "event", name, "delivery", r.Header.Get("X-Gitlab-Event-UUID"), "status", res.Status)
+	status := http.StatusOK
+	if res.Status == "queued" {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, res)
+}
+
+// gitlabPush scans pushes to the project's default branch as scanPush does
+// for GitHub; other branches are scanned through their merge requests.
+func (a *App) gitlabPush(ctx context.Context, ev gitlabEvent) (eventResult, error) {
+	branch, ok := strings.CutPrefix(ev.Ref, "refs/heads/")
+	switch {
+	case !ok:
+		return eventIgnored("not a branch push"), nil
+	case ev.CheckoutSHA == "":
+		return eventIgnored("branch deleted"), nil
+	case branch != ev.Project.DefaultBranch:
+		return eventIgnored("push to " + branch + ", not the default branch"), nil
+	}
+	repoID, err := a.registerGitLabProject(ctx, ev.Project.PathWithNamespace)
+	if err != nil {
+		return eventResult{}, err
+	}
+	// GitLab lists at most 20 commits; total_commits_count says whether
+	// that is all of them.
+	var changed []string
+	if len(ev.Commits) == ev.TotalCommitsCount {
+		for _, c := range ev.Commits {
+			changed = append(changed, c.Added...)
+			changed = append(changed, c.Modified...)
+			changed = append(changed, c.Removed...)
+		}
+	}
+	return a.scanPush(ctx, repoID, branch, ev.After, changed)
+}
+
+// gitlabMergeRequest scans the source branch of a merge request when it is
+// opened or pushed to, recording the merge request's IID as the job's
+// pull_request so workers comment on it. Source branches in forks are not
+// scanned: workers clone the target project, which does not have them.
+func (a *App) gitlabMergeRequest(ctx context.Context, ev gitlabEvent) (eventResult, error) {
+	mr := ev.ObjectAttributes
+	switch {
+	case !contains(gitlabScanActions, mr.Action):
+		return eventIgnored("merge request action " + mr.Action + " is not handled"), nil
+	case mr.Action == "update" && mr.OldRev == "":
+		return eventIgnored("merge request update without new commits"), nil
+	case mr.SourceProjectID != mr.TargetProjectID:
+		return eventIgnored("source branch is in a fork"), nil
+	}
+	repoID, err := a.registerGitLabProject(ctx, ev.Project.PathWithNamespace)
+	if err != nil {
+		return eventResult{}, err
+	}
+	jobID, err := a.enqueueScan(ctx, repoID, scanOptions{Priority: priorityHigh, Ref: mr.SourceBranch, CommitSHA: mr.LastCommit.ID, PullRequest: mr.IID})
+	if err != nil {
+		return eventResult{}, err
+	}
+	return eventResult{Status: "queued", JobID: jobID}, nil
+}
+
+// registerGitLabProject returns the repo for the project at path on
+// gitlab.url, adding it to the org named by its top-level group.
+func (a *App) registerGitLabProject(ctx context.Context, path string) (string, error) {
+	group, _, _ := strings.Cut(path, "/")
+	return a.registerRepo(ctx, path, forge.GitLab(a.cfg.GitLabURL).CloneURL(path), strings.ToLower(group), 0)
+}
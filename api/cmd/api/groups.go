@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"argus/api/internal/patch"
+	"argus/internal/jobsql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// A repo group is every repo carrying a tag, such as the repos released
+// together by one release train. Its gate passes only when each member's
+// latest full scan of its default branch meets the group's policy.
+
+const maxRepoTags = 20
+
+var repoTag = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// GroupPolicy is what every member's latest scan must meet.
+type GroupPolicy struct {
+	// FailOn blocks the gate on findings at or above this severity.
+	FailOn string `json:"fail_on"`
+	// MaxScanAgeHours fails members whose latest scan is older.
+	MaxScanAgeHours int `json:"max_scan_age_hours"`
+}
+
+var defaultGroupPolicy = GroupPolicy{FailOn: jobsql.DefaultFailOn, MaxScanAgeHours: 168}
+
+type updateGroupReq struct {
+	FailOn          *string `json:"fail_on"`
+	MaxScanAgeHours *int    `json:"max_scan_age_hours"`
+}
+
+// GroupGateRepo is one member's part of the gate.
+type GroupGateRepo struct {
+	RepoID    string         `json:"repo_id"`
+	Name      string         `json:"name"`
+	Pass      bool           `json:"pass"`
+	Reason    string         `json:"reason,omitempty"`
+	JobID     *string        `json:"job_id"`
+	CommitSHA *string        `json:"commit_sha"`
+	ScannedAt *time.Time     `json:"scanned_at"`
+	Blocking  map[string]int `json:"blocking"`
+	// Counts are all of the scan's findings by severity.
+	Counts map[string]int `json:"counts"`
+}
+
+// normalizeTags lowercases and de-duplicates repo tags.
+func normalizeTags(in []string) ([]string, error) {
+	if len(in) > maxRepoTags {
+		return nil, fmt.Errorf("a repo can have at most %d tags", maxRepoTags)
+	}
+	out := []string{}
+	for _, t := range in {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !repoTag.MatchString(t) {
+			return nil, fmt.Errorf("invalid tag %q (lowercase letters, digits, ., _ or -, at most 63)", t)
+		}
+		if !contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (a *App) groupPolicy(r *http.Request, tag string) (GroupPolicy, error) {
+	p := defaultGroupPolicy
+	err := a.db.QueryRow(r.Context(), `SELECT fail_on, max_scan_age_hours FROM repo_groups WHERE tag=$1`, tag).Scan(&p.FailOn, &p.MaxScanAgeHours)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return defaultGroupPolicy, nil
+	}
+	return p, err
+}
+
+// getGroup returns a group's policy and members.
+func (a *App) getGroup(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(chi.URLParam(r, "tag"))
+	policy, err := a.groupPolicy(r, tag)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	var repos []string
+	if err := a.db.QueryRow(r.Context(), `SELECT COALESCE(array_agg(id::text ORDER BY name), '{}') FROM repos WHERE tags @> ARRAY[$1]::text[]`, tag).Scan(&repos); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tag": tag, "policy": policy, "repo_ids": repos})
+}
+
+// updateGroup sets the fields of a group's policy present in the body. A
+// group exists through its members' tags; the policy may be set first.
+func (a *App) updateGroup(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(chi.URLParam(r, "tag"))
+	if !repoTag.MatchString(tag) {
+		badRequest(w, "invalid tag")
+		return
+	}
+	var req updateGroupReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	policy, err := a.groupPolicy(r, tag)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if req.FailOn != nil {
+		sev := strings.ToUpper(strings.TrimSpace(*req.FailOn))
+		if !patch.ValidSeverity(sev) {
+			badRequest(w, "fail_on must be one of CRITICAL, HIGH, MEDIUM, LOW")
+			return
+		}
+		policy.FailOn = sev
+	}
+	if req.MaxScanAgeHours != nil {
+		if *req.MaxScanAgeHours <= 0 {
+			badRequest(w, "max_scan_age_hours must be positive")
+			return
+		}
+		policy.MaxScanAgeHours = *req.MaxScanAgeHours
+	}
+	if _, err := a.db.Exec(r.Context(), `INSERT INTO repo_groups (tag, fail_on, max_scan_age_hours) VALUES ($1,$2,$3)
+		ON CONFLICT (tag) DO UPDATE SET fail_on=EXCLUDED.fail_on, max_scan_age_hours=EXCLUDED.max_scan_age_hours, updated_at=now()`,
+		tag, policy.FailOn, policy.MaxScanAgeHours); err != nil {
+		serverError(w, err)
+		return
+	}
+	a.getGroup(w, r)
+}
+
+// groupGate evaluates the group's gate. Only full scans of the default
+// branch count: jobs with a ref, a pinned commit or a scanner selection
+// say nothing about what would be released.
+func (a *App) groupGate(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(chi.URLParam(r, "tag"))
+	policy, err := a.groupPolicy(r, tag)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	members, err := a.latestFullScans(r.Context(), `rp.tags @> ARRAY[$1]::text[]`, tag)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if len(members) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "no repo has tag " + tag})
+		return
+	}
+	pass, now := true, time.Now()
+	for _, m := range members {
+		policy.judge(m, now)
+		pass = pass && m.Pass
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tag": tag, "pass": pass, "policy": policy, "repos": members})
+}
+
+// latestFullScans returns each repo matching where, with its latest full
+// scan of the default branch and that scan's finding counts, ordered by
+// name. A later full scan skipped because the commit had not moved vouches
+// for it, so it counts as the scan time. The verdict is left to a policy's
+// judge.
+func (a *App) latestFullScans(ctx context.Context, where string, arg any) ([]*GroupGateRepo, error) {
+	rows, err := a.db.Query(ctx, `SELECT DISTINCT ON (rp.name, rp.id) rp.id::text, rp.name, j.id::text, j.commit_sha, j.started_at,
+			GREATEST(j.finished_at, (SELECT max(s.finished_at) FROM jobs s WHERE s.repo_id = rp.id AND s.status='skipped_unchanged'
+				AND s.ref IS NULL AND s.target_sha IS NULL AND COALESCE(cardinality(s.scanners), 0) = 0 AND s.commit_sha = j.commit_sha))
+		FROM repos rp LEFT JOIN jobs j ON j.repo_id = rp.id AND j.status='succeeded' AND j.ref IS NULL AND j.target_sha IS NULL
+			AND COALESCE(cardinality(j.scanners), 0) = 0
+		WHERE `+where+` ORDER BY rp.name, rp.id, j.finished_at DESC NULLS LAST`, arg)
+	if err != nil {
+		return nil, err
+	}
+	var members []*GroupGateRepo
+	var repoIDs []string
+	var since []time.Time
+	byRepo := map[string]*GroupGateRepo{}
+	for rows.Next() {
+		m := &GroupGateRepo{Pass: true, Blocking: map[string]int{}, Counts: map[string]int{}}
+		var startedAt *time.Time
+		if err := rows.Scan(&m.RepoID, &m.Name, &m.JobID, &m.CommitSHA, &startedAt, &m.ScannedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		members = append(members, m)
+		if m.JobID != nil && startedAt != nil {
+			repoIDs = append(repoIDs, m.RepoID)
+			since = append(since, *startedAt)
+			byRepo[m.RepoID] = m
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(repoIDs) == 0 {
+		return members, nil
+	}
+
+	// A finding row moves on to the latest scan that reports it, so the
+	// full scan's findings are those seen since it started.
+	rows, err = a.db.Query(ctx, `SELECT f.repo_id::text, f.severity, count(*)
+		FROM findings f JOIN unnest($1::uuid[], $2::timestamptz[]) AS s(repo_id, since) ON s.repo_id = f.repo_id
+		WHERE f.last_seen_at >= s.since GROUP BY 1, 2`, repoIDs, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var repoID, sev string
+		var n int
+		if err := rows.Scan(&repoID, &sev, &n); err != nil {
+			return nil, err
+		}
+		byRepo[repoID].Counts[strings.ToUpper(sev)] += n
+	}
+	return members, rows.Err()
+}
+
+// judge sets a member's verdict: it fails without a scan, with a scan older
+// than MaxScanAgeHours, or with findings at or above FailOn.
+func (p GroupPolicy) judge(m *GroupGateRepo, now time.Time) {
+	threshold := patch.SeverityRank(p.FailOn)
+	blocking := 0
+	for sev, n := range m.Counts {
+		if patch.SeverityRank(sev) >= threshold {
+			m.Blocking[sev] += n
+			blocking += n
+		}
+	}
+	m.Pass, m.Reason = true, ""
+	switch {
+	case m.JobID == nil:
+		m.Pass, m.Reason = false, "no full scan of the default branch"
+	case m.ScannedAt.Before(now.Add(-time.Duration(p.MaxScanAgeHours) * time.Hour)):
+		m.Pass, m.Reason = false, fmt.Sprintf("latest scan is older than %d hours", p.MaxScanAgeHours)
+	case blocking > 0:
+		m.Pass, m.Reason = false, fmt.Sprintf("%d findings at or above %s", blocking, p.FailOn)
+	}
+}
+
+// repoPolicy combines the policies of the repo's groups into the strictest:
+// the lowest fail_on and the shortest max_scan_age_hours. A repo in no group
+// gets the default policy.
+func (a *App) repoPolicy(ctx context.Context, repoID string) (GroupPolicy, error) {
+	rows, err := a.db.Query(ctx, `SELECT g.fail_on, g.max_scan_age_hours FROM repo_groups g JOIN repos r ON g.tag = ANY(r.tags) WHERE r.id=$1`, repoID)
+	if err != nil {
+		return GroupPolicy{}, err
+	}
+	defer rows.Close()
+	p, first := defaultGroupPolicy, true
+	for rows.Next() {
+		var g GroupPolicy
+		if err := rows.Scan(&g.FailOn, &g.MaxScanAgeHours); err != nil {
+			return GroupPolicy{}, err
+		}
+		if first || patch.SeverityRank(g.FailOn) < patch.SeverityRank(p.FailOn) {
+			p.FailOn = g.FailOn
+		}
+		if first || g.MaxScanAgeHours < p.MaxScanAgeHours {
+			p.MaxScanAgeHours = g.MaxScanAgeHours
+		}
+		first = false
+	}
+	return p, rows.Err()
+}
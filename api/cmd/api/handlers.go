@@ -1,40 +1,105 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"argus/api/internal/changescope"
+	"argus/api/internal/compliance"
+	"argus/api/internal/githubapp"
+	"argus/api/internal/localize"
+	"argus/internal/forge"
+
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
 )
 
 type Repo struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	URL       string    `json:"url"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Org        *string  `json:"org,omitempty"`
+	DefaultRef *string  `json:"default_ref,omitempty"`
+	Tags       []string `json:"tags"`
+	// ExternalRefs are the repo's IDs in other systems, keyed by system.
+	ExternalRefs map[string]string `json:"external_refs"`
+	// InstallationID is the GitHub App installation workers clone the repo
+	// with.
+	InstallationID *int64    `json:"installation_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type createRepoReq struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	DefaultRef   string            `json:"default_ref"`
+	Tags         []string          `json:"tags"`
+	ExternalRefs map[string]string `json:"external_refs"`
+	// InstallationID is optional; without one workers clone with
+	// git.token or github.installation_id.
+	InstallationID int64 `json:"installation_id"`
+}
+
+type updateRepoReq struct {
+	DefaultRef *string `json:"default_ref"`
+	// Tags replaces the repo's tags, and so its groups, when present.
+	Tags *[]string `json:"tags"`
+	// ExternalRefs merges into the repo's refs; a null value removes one.
+	ExternalRefs map[string]*string `json:"external_refs"`
+	// InstallationID sets the repo's GitHub App installation; 0 clears it.
+	InstallationID *int64 `json:"installation_id"`
 }
 
 type Job struct {
-	ID         string     `json:"id"`
-	RepoID     string     `json:"repo_id"`
-	Status     string     `json:"status"`
-	StartedAt  *time.Time `json:"started_at,omitempty"`
-	FinishedAt *time.Time `json:"finished_at,omitempty"`
-	Error      *string    `json:"error,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
+	ID             string           `json:"id"`
+	RepoID         *string          `json:"repo_id,omitempty"`
+	Source         string           `json:"source"`
+	Status         string           `json:"status"`
+	CloneStrategy  *string          `json:"clone_strategy,omitempty"`
+	CommitSHA      *string          `json:"commit_sha,omitempty"`
+	Scanners       []string         `json:"scanners,omitempty"`
+	RulePacks      []string         `json:"rule_packs,omitempty"`
+	Attempt        int              `json:"attempt"`
+	Priority       string           `json:"priority"`
+	SizeClass      *string          `json:"size_class,omitempty"`
+	Ref            *string          `json:"ref,omitempty"`
+	TargetSHA      *string          `json:"target_sha,omitempty"`
+	BaseSHA        *string          `json:"base_sha,omitempty"`
+	DeploymentURL  *string          `json:"deployment_url,omitempty"`
+	PullRequest    *int             `json:"pull_request,omitempty"`
+	Image          *string          `json:"image,omitempty"`
+	RerunOf        *string          `json:"rerun_of,omitempty"`
+	Reruns         []string         `json:"reruns,omitempty"`
+	Worker         *string          `json:"worker,omitempty"`
+	Stage          *string          `json:"stage,omitempty"`
+	Progress       int              `json:"progress"`
+	StageTimings   map[string]int64 `json:"stage_timings"`
+	FailedScanners []string         `json:"failed_scanners"`
+	StartedAt      *time.Time       `json:"started_at,omitempty"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+	Error          *string          `json:"error,omitempty"`
+	SkipReason     *string          `json:"skip_reason,omitempty"`
+	DiagnosticsURL *string          `json:"diagnostics_url,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+
+	// ToolVersions are the versions of the scanner tools the job ran.
+	ToolVersions map[string]string `json:"tool_versions"`
 }
 
 type Finding struct {
-	ID          string          `json:"id"`
+	ID string `json:"id"`
+	// PublicID identifies the issue across rescans; unlike the fingerprint
+	// it is random, so it reveals nothing about the finding.
+	PublicID    string          `json:"public_id"`
 	Tool        string          `json:"tool"`
 	Severity    string          `json:"severity"`
 	Title       string          `json:"title"`
@@ -43,12 +108,25 @@ type Finding struct {
 	LineEnd     *int            `json:"line_end,omitempty"`
 	Fingerprint *string         `json:"fingerprint,omitempty"`
 	Description *string         `json:"description,omitempty"`
+	Remediation *string         `json:"remediation,omitempty"`
+	Lang        string          `json:"lang,omitempty"` // of a localized description or remediation
+	CWE         []string        `json:"cwe,omitempty"`
 	Evidence    json.RawMessage `json:"evidence_json,omitempty"`
-	CreatedAt   time.Time       `json:"created_at"`
+	Permalink   string          `json:"permalink,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"` // first seen
+	LastSeenAt  time.Time       `json:"last_seen_at"`
+	// Status is fixed once a default-branch scan no longer reports the
+	// finding, at ResolvedAt.
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// Compliance lists the framework controls the finding bears on.
+	Compliance []compliance.Control `json:"compliance,omitempty"`
 }
 
+// listRepos lists every repo, or with ?tag= the members of that group.
 func (a *App) listRepos(w http.ResponseWriter, r *http.Request) {
-	rows, err := a.db.Query(r.Context(), `SELECT id::text, name, url, created_at FROM repos ORDER BY created_at DESC`)
+	tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+	rows, err := a.db.Query(r.Context(), `SELECT id::text, name, url, org, default_ref, tags, external_refs, installation_id, created_at FROM repos WHERE $1='' OR tags @> ARRAY[$1]::text[] ORDER BY created_at DESC`, tag)
 	if err != nil {
 		serverError(w, err)
 		return
@@ -58,7 +136,7 @@ func (a *App) listRepos(w http.ResponseWriter, r *http.Request) {
 	out := make([]Repo, 0)
 	for rows.Next() {
 		var rp Repo
-		if err := rows.Scan(&rp.ID, &rp.Name, &rp.URL, &rp.CreatedAt); err != nil {
+		if err := rows.Scan(&rp.ID, &rp.Name, &rp.URL, &rp.Org, &rp.DefaultRef, &rp.Tags, &rp.ExternalRefs, &rp.InstallationID, &rp.CreatedAt); err != nil {
 			serverError(w, err)
 			return
 		}
@@ -76,21 +154,53 @@ func (a *App) createRepo(w http.ResponseWriter, r *http.Request) {
 
 	req.Name = strings.TrimSpace(req.Name)
 	req.URL = strings.TrimSpace(req.URL)
+	req.DefaultRef = strings.TrimSpace(req.DefaultRef)
 	if req.Name == "" || req.URL == "" {
 		badRequest(w, "name and url are required")
 		return
 	}
-	if !isAllowedGitURL(req.URL) {
-		badRequest(w, "url must be https://.../.git and non-localhost")
+	if !a.isAllowedGitURL(req.URL) {
+		badRequest(w, "url must be https://<allowed host>/.../.git or an Azure Repos URL")
+		return
+	}
+	if req.DefaultRef != "" {
+		if err := a.validateRemoteRef(r.Context(), "default_ref", req.URL, req.DefaultRef, req.InstallationID); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	refs, err := normalizeExternalRefs(req.ExternalRefs)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if req.InstallationID < 0 {
+		badRequest(w, "installation_id must be a GitHub App installation ID")
+		return
+	}
+
+	owner := repoOwner(req.URL)
+	if owner == "" {
+		badRequest(w, "url must include an owner and repository")
 		return
 	}
 
 	var id string
-	err := a.db.QueryRow(r.Context(), `INSERT INTO repos (name, url) VALUES ($1,$2) RETURNING id::text`, req.Name, req.URL).Scan(&id)
+	err = a.db.QueryRow(r.Context(), `INSERT INTO repos (name, url, org, default_ref, tags, external_refs, installation_id) VALUES ($1,$2,$3,$4,$5,$6,NULLIF($7::bigint, 0)) RETURNING id::text`,
+		req.Name, req.URL, strings.ToLower(owner), nullIfEmpty(req.DefaultRef), tags, refs, req.InstallationID).Scan(&id)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
+	if _, err := a.db.Exec(r.Context(), `INSERT INTO orgs (name) VALUES ($1) ON CONFLICT DO NOTHING`, strings.ToLower(owner)); err != nil {
+		serverError(w, err)
+		return
+	}
 
 	writeJSON(w, http.StatusCreated, map[string]any{"id": id})
 }
@@ -98,8 +208,8 @@ func (a *App) createRepo(w http.ResponseWriter, r *http.Request) {
 func (a *App) getRepo(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	var rp Repo
-	err := a.db.QueryRow(r.Context(), `SELECT id::text, name, url, created_at FROM repos WHERE id=$1`, id).
-		Scan(&rp.ID, &rp.Name, &rp.URL, &rp.CreatedAt)
+	err := a.db.QueryRow(r.Context(), `SELECT id::text, name, url, org, default_ref, tags, external_refs, installation_id, created_at FROM repos WHERE id=$1`, id).
+		Scan(&rp.ID, &rp.Name, &rp.URL, &rp.Org, &rp.DefaultRef, &rp.Tags, &rp.ExternalRefs, &rp.InstallationID, &rp.CreatedAt)
 	if err != nil {
 		notFound(w)
 		return
@@ -107,27 +217,196 @@ func (a *App) getRepo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, rp)
 }
 
+func (a *App) updateRepo(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req updateRepoReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+
+	var repoURL string
+	if err := a.db.QueryRow(r.Context(), `SELECT url FROM repos WHERE id=$1`, id).Scan(&repoURL); err != nil {
+		notFound(w)
+		return
+	}
+	refSet, refRemove, err := externalRefsPatch(req.ExternalRefs)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	if req.DefaultRef != nil {
+		ref := strings.TrimSpace(*req.DefaultRef)
+		if ref != "" {
+			var installationID int64
+			if req.InstallationID != nil {
+				installationID = max(*req.InstallationID, 0)
+			}
+			if err := a.validateRemoteRef(r.Context(), "default_ref", repoURL, ref, installationID); err != nil {
+				badRequest(w, err.Error())
+				return
+			}
+		}
+		if _, err := a.db.Exec(r.Context(), `UPDATE repos SET default_ref=$2 WHERE id=$1`, id, nullIfEmpty(ref)); err != nil {
+			serverError(w, err)
+			return
+		}
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		if _, err := a.db.Exec(r.Context(), `UPDATE repos SET tags=$2 WHERE id=$1`, id, tags); err != nil {
+			serverError(w, err)
+			return
+		}
+	}
+	if req.InstallationID != nil {
+		if *req.InstallationID < 0 {
+			badRequest(w, "installation_id must be a GitHub App installation ID, or 0 to clear it")
+			return
+		}
+		if _, err := a.db.Exec(r.Context(), `UPDATE repos SET installation_id=NULLIF($2::bigint, 0) WHERE id=$1`, id, *req.InstallationID); err != nil {
+			serverError(w, err)
+			return
+		}
+	}
+	if len(req.ExternalRefs) > 0 {
+		// The limit applies to the refs after the merge.
+		tag, err := a.db.Exec(r.Context(), `UPDATE repos SET external_refs=(external_refs || $2::jsonb) - $3::text[]
+			WHERE id=$1 AND (SELECT count(*) FROM jsonb_object_keys((external_refs || $2::jsonb) - $3::text[])) <= $4`, id, refSet, refRemove, maxExternalRefs)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			badRequest(w, fmt.Sprintf("a repo can have at most %d external_refs", maxExternalRefs))
+			return
+		}
+	}
+
+	a.getRepo(w, r)
+}
+
+// scannerNames are the scanners a scan request may select.
+var scannerNames = []string{"semgrep", "gitleaks", "trufflehog", "trivy", "terraform", "hadolint", "kube-linter", "govulncheck", "npm-audit", "pip-audit", "bandit", "grype", "syft", "nuclei"}
+
+type triggerScanReq struct {
+	// Scanners limits this job to a subset of the worker's scanners.
+	Scanners []string `json:"scanners"`
+	// Priority defaults to high: someone is waiting for the result.
+	Priority string `json:"priority"`
+	// Ref scans a branch or tag other than the repo's default_ref.
+	Ref string `json:"ref"`
+	// CommitSHA pins the scan to a commit, such as the one a preview
+	// environment was built from.
+	CommitSHA string `json:"commit_sha"`
+	// DeploymentURL is a preview deployment of the commit for nuclei to
+	// probe in the same job; see preview.go.
+	DeploymentURL string `json:"deployment_url"`
+	// Mode is "full" (the default) or "incremental", which scans only the
+	// files changed since the last scan of the ref; see incremental.go.
+	Mode string `json:"mode"`
+	// ChangedPaths are the paths a push changed, as sent by webhook
+	// integrations. When they are all non-code paths (skip.paths) the scan
+	// is skipped or narrowed to skip.scanners.
+	ChangedPaths []string `json:"changed_paths"`
+	// Force scans even when the ref's head is the commit its last
+	// successful scan saw.
+	Force bool `json:"force"`
+}
+
 func (a *App) triggerScan(w http.ResponseWriter, r *http.Request) {
 	repoID := chi.URLParam(r, "id")
 
-	var exists bool
-	if err := a.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM repos WHERE id=$1)`, repoID).Scan(&exists); err != nil {
-		serverError(w, err)
+	// The body is optional; an empty one runs every enabled scanner.
+	var req triggerScanReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(w, "invalid json")
 		return
 	}
-	if !exists {
-		notFound(w)
+	scanners, err := normalizeScanners(req.Scanners)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	priority, err := normalizePriority(req.Priority, priorityHigh)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	opts := scanOptions{Scanners: scanners, Priority: priority, Ref: strings.TrimSpace(req.Ref), Force: req.Force}
+	if opts.CommitSHA, err = normalizeCommitSHA(req.CommitSHA); err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if opts.DeploymentURL, err = a.validateDeploymentURL(req.DeploymentURL); err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if contains(scanners, "nuclei") && opts.DeploymentURL == "" {
+		badRequest(w, "nuclei needs a deployment_url")
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode != "" && mode != scanModeFull && mode != scanModeIncremental {
+		badRequest(w, "mode must be full or incremental")
 		return
 	}
 
-	var jobID string
-	if err := a.db.QueryRow(r.Context(), `INSERT INTO jobs (repo_id, status) VALUES ($1,'queued') RETURNING id::text`, repoID).Scan(&jobID); err != nil {
+	var repoURL string
+	err = a.db.QueryRow(r.Context(), `SELECT url FROM repos WHERE id=$1`, repoID).Scan(&repoURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		notFound(w)
+		return
+	}
+	if err != nil {
 		serverError(w, err)
 		return
 	}
+	if opts.Ref != "" {
+		if err := a.validateRemoteRef(r.Context(), "ref", repoURL, opts.Ref, 0); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+
+	scope := changescope.Policy{SkipPaths: a.cfg.SkipPaths, Scanners: a.cfg.SkipScanners}.Decide(req.ChangedPaths, opts.Scanners)
+	if opts.DeploymentURL != "" {
+		// The deployment still needs probing whatever the push changed.
+		scope = changescope.Decision{}
+	}
+	if scope.Skip {
+		jobID, err := a.recordSkippedScan(r.Context(), repoID, opts, scope.Reason)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"job_id": jobID, "status": "skipped", "skip_reason": scope.Reason})
+		return
+	}
+	if scope.Scanners != nil {
+		opts.Scanners = scope.Scanners
+		slog.InfoContext(r.Context(), "scan narrowed to non-code scanners", "repo_id", repoID, "reason", scope.Reason)
+	}
+	if mode == scanModeIncremental {
+		if opts.Scanners == nil {
+			opts.Scanners = diffScanners
+		}
+		if opts.BaseSHA, err = a.incrementalBase(r.Context(), repoID, opts.Ref, opts.Scanners); err != nil {
+			serverError(w, err)
+			return
+		}
+		if opts.BaseSHA == "" {
+			slog.InfoContext(r.Context(), "no earlier scan of the ref; incremental scan covers the whole tree", "repo_id", repoID)
+		}
+	}
 
-	payload, _ := json.Marshal(map[string]string{"job_id": jobID, "repo_id": repoID})
-	if err := a.redis.LPush(r.Context(), "ssao:jobs", payload).Err(); err != nil {
+	jobID, err := a.enqueueScan(r.Context(), repoID, opts)
+	if err != nil {
 		serverError(w, err)
 		return
 	}
@@ -135,21 +414,159 @@ func (a *App) triggerScan(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": jobID})
 }
 
+// recordSkippedScan records a scan that was not needed as a finished job
+// with status skipped, so the push still shows up in the repo's history.
+func (a *App) recordSkippedScan(ctx context.Context, repoID string, opts scanOptions, reason string) (string, error) {
+	var jobID string
+	err := a.db.QueryRow(ctx, `INSERT INTO jobs (repo_id, status, scanners, priority, ref, target_sha, skip_reason, finished_at)
+		VALUES ($1,'skipped',$2,$3,$4,$5,$6,now()) RETURNING id::text`,
+		repoID, opts.Scanners, opts.Priority, nullIfEmpty(opts.Ref), nullIfEmpty(opts.CommitSHA), reason).Scan(&jobID)
+	if err != nil {
+		return "", err
+	}
+	a.metrics.scansSkipped.Inc()
+	slog.InfoContext(withJobID(ctx, jobID), "scan skipped", "repo_id", repoID, "reason", reason)
+	if err := a.writeJobSummary(ctx, jobID); err != nil {
+		slog.WarnContext(withJobID(ctx, jobID), "job summary not written", "err", err)
+	}
+	return jobID, nil
+}
+
+// normalizeScanners lowercases and de-duplicates a scanner selection. An
+// empty selection yields nil, meaning no restriction.
+func normalizeScanners(in []string) ([]string, error) {
+	var out []string
+	for _, s := range in {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !contains(scannerNames, s) {
+			return nil, fmt.Errorf("unknown scanner %q (want one of %s)", s, strings.Join(scannerNames, ", "))
+		}
+		if !contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// scanOptions are the parameters a repo scan job runs with.
+type scanOptions struct {
+	// Scanners nil runs everything the worker has enabled.
+	Scanners []string
+	Priority string
+	// Ref empty scans the repo's default_ref.
+	Ref string
+	// RerunOf is the job this one reruns, if any.
+	RerunOf string
+	// CommitSHA pins the scan to a commit on Ref; empty scans its head.
+	CommitSHA string
+	// DeploymentURL adds a DAST scan of a preview deployment to the job.
+	DeploymentURL string
+	// BaseSHA makes the scan incremental: semgrep and gitleaks only see
+	// the files changed since this commit.
+	BaseSHA string
+	// Force stops the worker skipping the scan when the commit is
+	// unchanged since the last successful scan.
+	Force bool
+	// PullRequest is the pull request whose head is scanned, for scans
+	// started by a pull_request event.
+	PullRequest int
+}
+
+// enqueueScan records a queued job for repoID and pushes it onto the worker
+// queue for its priority. It is shared by user-triggered, scheduled and
+// rerun scans.
+func (a *App) enqueueScan(ctx context.Context, repoID string, opts scanOptions) (string, error) {
+	var jobID string
+	if err := a.db.QueryRow(ctx, `INSERT INTO jobs (repo_id, status, scanners, priority, ref, rerun_of, target_sha, deployment_url, base_sha, force, pull_request) VALUES ($1,'queued',$2,$3,$4,$5,$6,$7,$8,$9,NULLIF($10::int, 0)) RETURNING id::text`,
+		repoID, opts.Scanners, opts.Priority, nullIfEmpty(opts.Ref), nullIfEmpty(opts.RerunOf), nullIfEmpty(opts.CommitSHA), nullIfEmpty(opts.DeploymentURL), nullIfEmpty(opts.BaseSHA), opts.Force, opts.PullRequest).Scan(&jobID); err != nil {
+		return "", err
+	}
+
+	if err := a.pushJob(ctx, opts.Priority, map[string]string{"job_id": jobID, "repo_id": repoID, "request_id": middleware.GetReqID(ctx)}); err != nil {
+		return "", err
+	}
+	a.metrics.scansTriggered.Inc()
+	attrs := []any{"repo_id", repoID, "scanners", opts.Scanners, "priority", opts.Priority}
+	if opts.Ref != "" {
+		attrs = append(attrs, "ref", opts.Ref)
+	}
+	if opts.RerunOf != "" {
+		attrs = append(attrs, "rerun_of", opts.RerunOf)
+	}
+	if opts.CommitSHA != "" {
+		attrs = append(attrs, "target_sha", opts.CommitSHA)
+	}
+	if opts.DeploymentURL != "" {
+		attrs = append(attrs, "deployment_url", opts.DeploymentURL)
+	}
+	if opts.BaseSHA != "" {
+		attrs = append(attrs, "base_sha", opts.BaseSHA)
+	}
+	if opts.Force {
+		attrs = append(attrs, "force", true)
+	}
+	if opts.PullRequest != 0 {
+		attrs = append(attrs, "pull_request", opts.PullRequest)
+	}
+	slog.InfoContext(withJobID(ctx, jobID), "scan enqueued", attrs...)
+	return jobID, nil
+}
+
 func (a *App) getJob(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	var jb Job
-	err := a.db.QueryRow(r.Context(), `SELECT id::text, repo_id::text, status::text, started_at, finished_at, error, created_at FROM jobs WHERE id=$1`, id).
-		Scan(&jb.ID, &jb.RepoID, &jb.Status, &jb.StartedAt, &jb.FinishedAt, &jb.Error, &jb.CreatedAt)
+	err := a.db.QueryRow(r.Context(), `SELECT id::text, repo_id::text, source, status::text, clone_strategy, commit_sha, scanners, rule_packs, attempt, priority, size_class, ref, target_sha, base_sha, deployment_url, pull_request, image, rerun_of::text, worker, stage, progress, stage_timings, tool_versions, failed_scanners, started_at, finished_at, error, skip_reason, created_at FROM jobs WHERE id=$1`, id).
+		Scan(&jb.ID, &jb.RepoID, &jb.Source, &jb.Status, &jb.CloneStrategy, &jb.CommitSHA, &jb.Scanners, &jb.RulePacks, &jb.Attempt, &jb.Priority, &jb.SizeClass, &jb.Ref, &jb.TargetSHA, &jb.BaseSHA, &jb.DeploymentURL, &jb.PullRequest, &jb.Image, &jb.RerunOf, &jb.Worker, &jb.Stage, &jb.Progress, &jb.StageTimings, &jb.ToolVersions, &jb.FailedScanners, &jb.StartedAt, &jb.FinishedAt, &jb.Error, &jb.SkipReason, &jb.CreatedAt)
 	if err != nil {
 		notFound(w)
 		return
 	}
+	err = a.db.QueryRow(r.Context(), `SELECT COALESCE(array_agg(id::text ORDER BY created_at), '{}') FROM jobs WHERE rerun_of=$1`, id).Scan(&jb.Reruns)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	var artifactID string
+	err = a.db.QueryRow(r.Context(), `SELECT id::text FROM job_artifacts WHERE job_id=$1 AND kind='diagnostics' ORDER BY created_at DESC LIMIT 1`, id).Scan(&artifactID)
+	if err == nil {
+		u, _ := a.artifactURL(a.publicBaseURL(r), artifactID, time.Now())
+		jb.DiagnosticsURL = &u
+	}
 	writeJSON(w, http.StatusOK, jb)
 }
 
+// Finding statuses.
+const (
+	findingOpen  = "open"
+	findingFixed = "fixed"
+)
+
+// listFindings lists the repo's findings, or with ?status= only the open or
+// fixed ones.
 func (a *App) listFindings(w http.ResponseWriter, r *http.Request) {
-	repoID := chi.URLParam(r, "id")
-	rows, err := a.db.Query(r.Context(), `SELECT id::text, tool::text, severity, title, file_path, line_start, line_end, fingerprint, description, evidence_json, created_at FROM findings WHERE repo_id=$1 ORDER BY created_at DESC LIMIT 500`, repoID)
+	where := `f.repo_id=$1`
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case findingOpen, findingFixed:
+		where += ` AND f.status='` + status + `'`
+	default:
+		badRequest(w, "status must be open or fixed")
+		return
+	}
+	a.writeFindings(w, r, where, chi.URLParam(r, "id"))
+}
+
+func (a *App) listJobFindings(w http.ResponseWriter, r *http.Request) {
+	a.writeFindings(w, r, `f.job_id=$1`, chi.URLParam(r, "id"))
+}
+
+// writeFindings lists findings with descriptions and remediation in the
+// language the Accept-Language header asks for, or else the org's locale.
+func (a *App) writeFindings(w http.ResponseWriter, r *http.Request, where string, arg string) {
+	lang := localize.Negotiate(r.Header.Get("Accept-Language"), a.translator.Languages())
+	rows, err := a.db.Query(r.Context(), `SELECT f.id::text, COALESCE(f.public_id,''), f.tool::text, f.severity, f.title, f.file_path, f.line_start, f.line_end, f.fingerprint, f.description, f.evidence_json, f.created_at, f.last_seen_at, f.status, f.resolved_at, COALESCE(rp.url,''), COALESCE(j.commit_sha,''), COALESCE(o.locale,'')
+		FROM findings f JOIN jobs j ON j.id = f.job_id LEFT JOIN repos rp ON rp.id = f.repo_id LEFT JOIN orgs o ON o.name = rp.org
+		WHERE `+where+` ORDER BY f.created_at DESC LIMIT 500`, arg)
 	if err != nil {
 		serverError(w, err)
 		return
@@ -159,18 +576,27 @@ func (a *App) listFindings(w http.ResponseWriter, r *http.Request) {
 	out := make([]Finding, 0)
 	for rows.Next() {
 		var f Finding
-		if err := rows.Scan(&f.ID, &f.Tool, &f.Severity, &f.Title, &f.FilePath, &f.LineStart, &f.LineEnd, &f.Fingerprint, &f.Description, &f.Evidence, &f.CreatedAt); err != nil {
+		var repoURL, sha, locale string
+		if err := rows.Scan(&f.ID, &f.PublicID, &f.Tool, &f.Severity, &f.Title, &f.FilePath, &f.LineStart, &f.LineEnd, &f.Fingerprint, &f.Description, &f.Evidence, &f.CreatedAt, &f.LastSeenAt, &f.Status, &f.ResolvedAt, &repoURL, &sha, &locale); err != nil {
 			serverError(w, err)
 			return
 		}
+		f.Permalink = findingPermalink(repoURL, sha, f.FilePath, f.LineStart, f.LineEnd)
+		if lang != "" {
+			locale = lang
+		}
+		a.localizeFinding(&f, locale)
+		cf := complianceFinding(f.Tool, f.Evidence)
+		f.CWE, f.Compliance = compliance.CWEs(cf), compliance.Controls(cf, "")
 		out = append(out, f)
 	}
+	w.Header().Set("Vary", "Accept-Language")
 	writeJSON(w, http.StatusOK, out)
 }
 
 func (a *App) prSuggestions(w http.ResponseWriter, r *http.Request) {
 	repoID := chi.URLParam(r, "id")
-	rows, err := a.db.Query(r.Context(), `SELECT tool::text, severity, title, COALESCE(file_path,''), COALESCE(description,'') FROM findings WHERE repo_id=$1 ORDER BY created_at DESC LIMIT 20`, repoID)
+	rows, err := a.db.Query(r.Context(), `SELECT tool::text, severity, title, COALESCE(file_path,''), COALESCE(description,'') FROM findings WHERE repo_id=$1 AND status='open' ORDER BY created_at DESC LIMIT 20`, repoID)
 	if err != nil {
 		serverError(w, err)
 		return
@@ -211,7 +637,7 @@ func (a *App) prSuggestions(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func isAllowedGitURL(raw string) bool {
+func (a *App) isAllowedGitURL(raw string) bool {
 	u, err := url.Parse(raw)
 	if err != nil {
 		return false
@@ -219,14 +645,47 @@ func isAllowedGitURL(raw string) bool {
 	if u.Scheme != "https" || u.Host == "" || u.User != nil {
 		return false
 	}
-	if !strings.HasSuffix(strings.ToLower(u.Path), ".git") {
+	if _, azure := forge.ParseAzureRepo(raw); !azure && !strings.HasSuffix(strings.ToLower(u.Path), ".git") {
 		return false
 	}
-	host := strings.ToLower(u.Hostname())
-	if host != "github.com" {
-		return false
+	return a.cfg.HostAllowed(u.Hostname())
+}
+
+// repoOwner returns the lowercased first path segment of a repository URL
+// (the GitHub owner, or the top-level group on other hosts).
+func repoOwner(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || strings.TrimSuffix(parts[len(parts)-1], ".git") == "" {
+		return ""
+	}
+	return strings.ToLower(parts[0])
+}
+
+// findingPermalink pins a finding to the commit its job scanned; findings
+// without a file or from jobs that predate commit tracking get none.
+func findingPermalink(repoURL, sha string, file *string, start, end *int) string {
+	if file == nil {
+		return ""
+	}
+	var ls, le int
+	if start != nil {
+		ls = *start
+	}
+	if end != nil {
+		le = *end
+	}
+	return githubapp.Permalink(repoURL, sha, *file, ls, le)
+}
+
+func nullIfEmpty(v string) any {
+	if strings.TrimSpace(v) == "" {
+		return nil
 	}
-	return true
+	return v
 }
 
 func formatErr(prefix string, err error) error {
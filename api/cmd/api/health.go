@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"argus/api/internal/config"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthFail     = "fail"
+)
+
+type componentHealth struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Depth     *int64 `json:"depth,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+}
+
+// healthz reports per-component status for readiness probes. A failing
+// dependency returns 503; a queue past health.queue_warn_depth only marks the
+// response degraded, since taking the API out of rotation would not drain it.
+func (a *App) healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	components := map[string]componentHealth{
+		"database": timedCheck(func() error { return a.db.Ping(ctx) }),
+	}
+	// A Postgres queue lives in the database checked above.
+	if a.queue.Name() != config.QueuePostgres {
+		components[a.queue.Name()] = timedCheck(func() error { return a.queue.Ping(ctx) })
+	}
+
+	var depth int64
+	queue := timedCheck(func() error {
+		depths, err := a.queue.Depths(ctx)
+		for _, n := range depths {
+			depth += n
+		}
+		return err
+	})
+	if queue.Status == healthOK {
+		queue.Depth = &depth
+		queue.Threshold = a.cfg.QueueWarnDepth
+		if depth > int64(a.cfg.QueueWarnDepth) {
+			queue.Status = healthDegraded
+		}
+	}
+	components["queue"] = queue
+
+	status := healthOK
+	for _, c := range components {
+		if c.Status == healthFail {
+			status = healthFail
+			break
+		}
+		if c.Status == healthDegraded {
+			status = healthDegraded
+		}
+	}
+	code := http.StatusOK
+	if status == healthFail {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"ok": status != healthFail, "status": status, "components": components})
+}
+
+func timedCheck(check func() error) componentHealth {
+	start := time.Now()
+	err := check()
+	c := componentHealth{Status: healthOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		c.Status = healthFail
+		c.Error = err.Error()
+	}
+	return c
+}
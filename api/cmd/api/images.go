@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"argus/api/internal/imageref"
+	"argus/internal/imagecreds"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// sourceImage marks jobs that scan a container image instead of a checkout.
+const sourceImage = "image"
+
+type imageScanReq struct {
+	Image string `json:"image"`
+	// Username and Password authenticate to the image's registry; leave
+	// both empty for public images.
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Priority string `json:"priority"`
+}
+
+// createImageScan enqueues a job that runs trivy against an image in one of
+// images.allowed_registries. Like an upload it has no repo; its findings
+// are read through the job.
+func (a *App) createImageScan(w http.ResponseWriter, r *http.Request) {
+	if len(a.cfg.ImageRegistries) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "image scans are not enabled on this server (images.allowed_registries)"})
+		return
+	}
+	var req imageScanReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	ref, err := imageref.Parse(req.Image)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if !a.cfg.ImageRegistryAllowed(ref.Registry) {
+		badRequest(w, fmt.Sprintf("registry %q is not in images.allowed_registries", ref.Registry))
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if (req.Username == "") != (req.Password == "") {
+		badRequest(w, "username and password go together")
+		return
+	}
+	if req.Username != "" && a.cfg.ImageCredentialsKey == nil {
+		badRequest(w, "registry credentials need images.credentials_key to be set")
+		return
+	}
+	priority, err := normalizePriority(req.Priority, priorityHigh)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	var jobID string
+	if err := tx.QueryRow(ctx, `INSERT INTO jobs (repo_id, status, source, image, scanners, priority, image_authenticated) VALUES (NULL,'queued',$1,$2,'{trivy}',$3,$4) RETURNING id::text`,
+		sourceImage, ref.String(), priority, req.Username != "").Scan(&jobID); err != nil {
+		serverError(w, err)
+		return
+	}
+	if req.Username != "" {
+		sealed, err := a.cfg.ImageCredentialsKey.Seal(jobID, imagecreds.Credentials{Username: req.Username, Password: req.Password})
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO image_credentials (job_id, sealed) VALUES ($1,$2)`, jobID, sealed); err != nil {
+			serverError(w, err)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	if err := a.pushJob(ctx, priority, map[string]string{"job_id": jobID, "source": sourceImage, "request_id": middleware.GetReqID(ctx)}); err != nil {
+		serverError(w, err)
+		return
+	}
+	a.metrics.scansTriggered.Inc()
+	slog.InfoContext(withJobID(ctx, jobID), "image scan enqueued", "image", ref.String(), "authenticated", req.Username != "")
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": jobID, "image": ref.String()})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Incremental scans diff the checkout against the commit of the repo's
+// last successful scan of the same ref (its base_sha) and run semgrep and
+// gitleaks over the changed files only. Other selected scanners still see
+// the whole tree.
+const (
+	scanModeFull        = "full"
+	scanModeIncremental = "incremental"
+)
+
+// diffScanners are the scanners an incremental scan restricts to changed
+// files, and its selection when the request makes none.
+var diffScanners = []string{"semgrep", "gitleaks"}
+
+// incrementalBase returns the commit an incremental scan of ref diffs
+// against: that of the latest successful scan of the same ref which ran
+// every diff scanner in scanners. It is empty when there is none, and the
+// scan then covers the whole tree.
+func (a *App) incrementalBase(ctx context.Context, repoID, ref string, scanners []string) (string, error) {
+	covered := []string{}
+	for _, s := range scanners {
+		if contains(diffScanners, s) {
+			covered = append(covered, s)
+		}
+	}
+	var sha string
+	err := a.db.QueryRow(ctx, `SELECT commit_sha FROM jobs
+		WHERE repo_id=$1 AND status='succeeded' AND commit_sha IS NOT NULL AND ref IS NOT DISTINCT FROM $2
+			AND (COALESCE(cardinality(scanners), 0) = 0 OR scanners @> $3::text[])
+		ORDER BY finished_at DESC LIMIT 1`, repoID, nullIfEmpty(ref), covered).Scan(&sha)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return sha, err
+}
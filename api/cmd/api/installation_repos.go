@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"argus/api/internal/githubapp"
+	"argus/internal/forge"
+)
+
+// Onboarding an org through the GitHub App: GET /github/repositories lists
+// every repo an installation can access and whether Argus knows it, and
+// POST /github/repositories/register registers them in one go instead of
+// one POST /repos each. Both take the installation_id of the org to
+// onboard, defaulting to GITHUB_INSTALLATION_ID.
+
+// githubApp returns the GitHub App client for repoURL: for installationID
+// when set, else for the installation recorded on the repo, else for
+// GITHUB_INSTALLATION_ID.
+func (a *App) githubApp(ctx context.Context, repoURL string, installationID int64) (*githubapp.Client, error) {
+	if installationID == 0 && repoURL != "" {
+		err := a.db.QueryRow(ctx, `SELECT COALESCE(installation_id, 0) FROM repos WHERE lower(url)=lower($1) ORDER BY installation_id IS NULL LIMIT 1`, repoURL).Scan(&installationID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return githubapp.NewForInstallation(forge.GitHub(a.cfg.GitHubURL), a.cfg.GitHubProxySetting(), installationID)
+}
+
+// installationRepo is a repo the installation can access; RepoID is set
+// once it is registered.
+type installationRepo struct {
+	FullName      string  `json:"full_name"`
+	URL           string  `json:"url"`
+	DefaultBranch string  `json:"default_branch,omitempty"`
+	Private       bool    `json:"private"`
+	Archived      bool    `json:"archived"`
+	RepoID        *string `json:"repo_id"`
+}
+
+type registerInstallationReposReq struct {
+	// InstallationID is the installation whose repos to register; 0 means
+	// GITHUB_INSTALLATION_ID.
+	InstallationID int64 `json:"installation_id"`
+	// Repos are the owner/name of the repos to register; empty means all
+	// of them.
+	Repos []string `json:"repos"`
+	// IncludeArchived registers archived repos along with the rest; a repo
+	// named in Repos is registered either way.
+	IncludeArchived bool `json:"include_archived"`
+}
+
+// installationRepos lists installation installationID's repos with the ID
+// of those already registered.
+func (a *App) installationRepos(r *http.Request, installationID int64) (int64, []installationRepo, error) {
+	gh, err := a.githubApp(r.Context(), "", installationID)
+	if err != nil {
+		return 0, nil, err
+	}
+	token, err := gh.InstallationToken(r.Context())
+	if err != nil {
+		return 0, nil, err
+	}
+	listed, err := gh.ListInstallationRepos(r.Context(), token)
+	if err != nil {
+		return 0, nil, err
+	}
+	repos := make([]installationRepo, len(listed))
+	urls := make([]string, len(listed))
+	for i, l := range listed {
+		repos[i] = installationRepo{FullName: l.FullName, URL: a.githubCloneURL(l.FullName), DefaultBranch: l.DefaultBranch, Private: l.Private, Archived: l.Archived}
+		urls[i] = strings.ToLower(repos[i].URL)
+	}
+	rows, err := a.db.Query(r.Context(), `SELECT lower(url), id::text FROM repos WHERE lower(url) = ANY($1)`, urls)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	known := map[string]string{}
+	for rows.Next() {
+		var url, id string
+		if err := rows.Scan(&url, &id); err != nil {
+			return 0, nil, err
+		}
+		known[url] = id
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	for i := range repos {
+		if id, ok := known[urls[i]]; ok {
+			repos[i].RepoID = &id
+		}
+	}
+	return gh.InstallationID(), repos, nil
+}
+
+func (a *App) listInstallationRepos(w http.ResponseWriter, r *http.Request) {
+	if a.cfg.Offline {
+		badRequest(w, "listing installation repos needs the GitHub API and is disabled in offline mode")
+		return
+	}
+	var installationID int64
+	if v := r.URL.Query().Get("installation_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			badRequest(w, "installation_id must be a GitHub App installation ID")
+			return
+		}
+		installationID = id
+	}
+	id, repos, err := a.installationRepos(r, installationID)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": "list installation repos: " + err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"installation_id": id, "repositories": repos})
+}
+
+// registerInstallationRepos registers the installation's repos, recording
+// the installation on each so workers clone them with its token.
+func (a *App) registerInstallationRepos(w http.ResponseWriter, r *http.Request) {
+	if a.cfg.Offline {
+		badRequest(w, "registering installation repos needs the GitHub API and is disabled in offline mode")
+		return
+	}
+	var req registerInstallationReposReq
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, "invalid json")
+			return
+		}
+	}
+	if req.InstallationID < 0 {
+		badRequest(w, "installation_id must be a GitHub App installation ID")
+		return
+	}
+	id, repos, err := a.installationRepos(r, req.InstallationID)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": "list installation repos: " + err.Error()})
+		return
+	}
+	wanted := map[string]bool{}
+	for _, name := range req.Repos {
+		wanted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	byName := map[string]bool{}
+	for _, repo := range repos {
+		byName[strings.ToLower(repo.FullName)] = true
+	}
+	for name := range wanted {
+		if !byName[name] {
+			badRequest(w, "the installation cannot access "+name)
+			return
+		}
+	}
+
+	registered := []installationRepo{}
+	for _, repo := range repos {
+		switch {
+		case len(wanted) > 0 && !wanted[strings.ToLower(repo.FullName)]:
+			continue
+		case len(wanted) == 0 && repo.Archived && !req.IncludeArchived:
+			continue
+		}
+		repoID, err := a.registerGitHubRepo(r.Context(), repo.FullName, id)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		repo.RepoID = &repoID
+		registered = append(registered, repo)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"installation_id": id, "registered": registered})
+}
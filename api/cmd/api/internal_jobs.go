@@ -0,0 +1,682 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/api/internal/flags"
+	"argus/internal/imagecreds"
+	"argus/internal/jobsql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Workers running with results.mode api submit job state and results through
+// these endpoints instead of writing to Postgres. They mirror the worker's
+// dbStore statement for statement; the statements both run are in jobsql.
+const (
+	maxInternalBody     = 64 << 20
+	maxInternalFindings = 1000
+	maxInternalLogLines = 1000
+	maxInternalPackages = 20000
+	uploadFormatHeader  = "X-Argus-Upload-Format"
+)
+
+// workerAuthz admits only the worker token. The internal routes are disabled
+// entirely when WORKER_API_TOKEN is unset.
+func (a *App) workerAuthz(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.cfg.WorkerToken == "" {
+			notFound(w)
+			return
+		}
+		tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(a.cfg.WorkerToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxInternalBody)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) internalRoutes(r chi.Router) {
+	r.Use(a.workerAuthz)
+	r.Get("/ping", a.internalPing)
+	r.Get("/repos/{id}", a.internalRepo)
+	r.Post("/jobs/{id}/start", a.internalStartJob)
+	r.Post("/jobs/{id}/finish", a.internalFinishJob)
+	r.Get("/jobs/{id}/previous-scan", a.internalPreviousScan)
+	r.Post("/jobs/{id}/skip", a.internalSkipJob)
+	r.Post("/jobs/{id}/fail", a.internalFailJob)
+	r.Post("/jobs/{id}/requeue", a.internalRequeueJob)
+	r.Get("/jobs/{id}/upload", a.internalUpload)
+	r.Delete("/jobs/{id}/upload", a.internalDeleteUpload)
+	r.Get("/jobs/{id}/image-credentials", a.internalImageCredentials)
+	r.Delete("/jobs/{id}/image-credentials", a.internalDeleteImageCredentials)
+	r.Post("/jobs/{id}/clone", a.internalCloneResult)
+	r.Post("/jobs/{id}/rule-packs", a.internalRulePacks)
+	r.Post("/jobs/{id}/stage", a.internalStage)
+	r.Post("/jobs/{id}/tool-versions", a.internalToolVersions)
+	r.Post("/jobs/{id}/profile", a.internalProfile)
+	r.Put("/jobs/{id}/packages", a.internalPackages)
+	r.Post("/jobs/{id}/findings", a.internalAddFindings)
+	r.Get("/jobs/{id}/new-findings", a.internalNewFindings)
+	r.Get("/jobs/{id}/branch-findings", a.internalBranchFindings)
+	r.Post("/jobs/{id}/resolve-findings", a.internalResolveFindings)
+	r.Put("/jobs/{id}/artifacts/{name}", a.internalSaveArtifact)
+	r.Post("/jobs/{id}/logs", a.internalAppendJobLog)
+	r.Get("/jobs/{id}/logs", a.internalJobLog)
+	r.Post("/jobs/{id}/logs/archive", a.internalArchiveJobLog)
+	r.Post("/jobs/{id}/summary", a.internalJobSummary)
+	r.Post("/jobs/{id}/events", a.internalJobEvents)
+}
+
+func (a *App) internalPing(w http.ResponseWriter, r *http.Request) {
+	if err := a.db.Ping(r.Context()); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// execJob runs a single-row update of the job in the URL.
+func (a *App) execJob(w http.ResponseWriter, r *http.Request, sql string, args ...any) {
+	tag, err := a.db.Exec(r.Context(), sql, append([]any{chi.URLParam(r, "id")}, args...)...)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeInternal(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(w, "invalid json")
+		return false
+	}
+	return true
+}
+
+func (a *App) internalStartJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Attempt int    `json:"attempt"`
+		Worker  string `json:"worker"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	// The job is only marked running once its flags are resolved too, so a
+	// failure leaves it as it was.
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	var scanners []string
+	var ref, targetSHA, deploymentURL, image, baseSHA, repoID string
+	var force bool
+	var pullRequest int
+	err = tx.QueryRow(ctx, `UPDATE jobs SET status='running', started_at=now(), error=NULL, attempt=$2, worker=$3, stage=NULL, progress=0, stage_timings='{}' WHERE id=$1
+		RETURNING scanners, COALESCE(ref,''), COALESCE(target_sha,''), COALESCE(deployment_url,''), COALESCE(image,''), COALESCE(base_sha,''), force, COALESCE(pull_request,0), COALESCE(repo_id::text,'')`, chi.URLParam(r, "id"), req.Attempt, nullIfEmpty(req.Worker)).
+		Scan(&scanners, &ref, &targetSHA, &deploymentURL, &image, &baseSHA, &force, &pullRequest, &repoID)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	set, err := flags.ForRepo(ctx, tx, repoID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"scanners": scanners, "ref": ref, "commit_sha": targetSHA, "deployment_url": deploymentURL, "image": image, "base_sha": baseSHA, "force": force, "flags": set, "pull_request": pullRequest})
+}
+
+func (a *App) internalFinishJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FailedScanners []string `json:"failed_scanners"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if req.FailedScanners == nil {
+		req.FailedScanners = []string{}
+	}
+	a.execJob(w, r, `UPDATE jobs SET status='succeeded', finished_at=now(), failed_scanners=$2 WHERE id=$1`, req.FailedScanners)
+}
+
+func (a *App) internalPreviousScan(w http.ResponseWriter, r *http.Request) {
+	var id, sha string
+	err := a.db.QueryRow(r.Context(), jobsql.PreviousScan, chi.URLParam(r, "id")).Scan(&id, &sha)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"job_id": id, "commit_sha": sha})
+}
+
+// internalSkipJob finishes a job the worker found nothing new to scan in.
+func (a *App) internalSkipJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	a.execJob(w, r, `UPDATE jobs SET status='skipped_unchanged', finished_at=now(), skip_reason=$2 WHERE id=$1`, req.Reason)
+}
+
+func (a *App) internalFailJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Error string `json:"error"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	a.execJob(w, r, `UPDATE jobs SET status='failed', finished_at=now(), error=$2 WHERE id=$1`, req.Error)
+}
+
+// internalRequeueJob resets a job for another attempt, discarding partial
+// findings and artifacts.
+func (a *App) internalRequeueJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Note string `json:"note"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	ctx, id := r.Context(), chi.URLParam(r, "id")
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `UPDATE jobs SET status='queued', started_at=NULL, finished_at=NULL, error=$2, worker=NULL, stage=NULL, progress=0, stage_timings='{}' WHERE id=$1`, id, req.Note)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM findings WHERE job_id=$1 AND (repo_id IS NULL OR first_job_id=$1)`, id); err != nil {
+		serverError(w, err)
+		return
+	}
+	objects, err := discardJobObjects(ctx, tx, []string{id})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		serverError(w, err)
+		return
+	}
+	a.deleteObjects(ctx, objects)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) internalRepo(w http.ResponseWriter, r *http.Request) {
+	var out struct {
+		URL            string          `json:"url"`
+		Name           string          `json:"name"`
+		DefaultRef     string          `json:"default_ref"`
+		TitleTemplates json.RawMessage `json:"title_templates,omitempty"`
+		// FingerprintSalt is secret to the org; workers need it to compute
+		// fingerprints that match the stored ones.
+		FingerprintSalt  string          `json:"fingerprint_salt,omitempty"`
+		Settings         json.RawMessage `json:"settings"`
+		SemgrepRules     []orgRuleFile   `json:"semgrep_rules"`
+		SemgrepRulesMode string          `json:"semgrep_rules_mode,omitempty"`
+		InstallationID   int64           `json:"installation_id,omitempty"`
+	}
+	var templates []byte
+	var org string
+	err := a.db.QueryRow(r.Context(), `SELECT r.url, r.name, COALESCE(r.default_ref,''), o.title_templates, COALESCE(o.fingerprint_salt,''), r.scan_settings,
+		COALESCE(o.name,''), COALESCE(o.semgrep_rules_mode,''), COALESCE(r.installation_id, 0)
+		FROM repos r LEFT JOIN orgs o ON o.name = r.org WHERE r.id=$1`, chi.URLParam(r, "id")).
+		Scan(&out.URL, &out.Name, &out.DefaultRef, &templates, &out.FingerprintSalt, &out.Settings, &org, &out.SemgrepRulesMode, &out.InstallationID)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	if json.Valid(templates) {
+		out.TitleTemplates = templates
+	}
+	if out.SemgrepRules, err = a.enabledSemgrepRules(r.Context(), org); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *App) internalUpload(w http.ResponseWriter, r *http.Request) {
+	var format string
+	var data []byte
+	if err := a.db.QueryRow(r.Context(), `SELECT format, data FROM uploads WHERE job_id=$1`, chi.URLParam(r, "id")).Scan(&format, &data); err != nil {
+		notFound(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(uploadFormatHeader, format)
+	_, _ = w.Write(data)
+}
+
+func (a *App) internalDeleteUpload(w http.ResponseWriter, r *http.Request) {
+	if _, err := a.db.Exec(r.Context(), `DELETE FROM uploads WHERE job_id=$1`, chi.URLParam(r, "id")); err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// internalImageCredentials returns an image scan's registry credentials,
+// empty when it has none.
+func (a *App) internalImageCredentials(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "id")
+	var sealed []byte
+	err := a.db.QueryRow(r.Context(), `SELECT sealed FROM image_credentials WHERE job_id=$1`, jobID).Scan(&sealed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusOK, map[string]string{"username": "", "password": ""})
+		return
+	}
+	if err == nil && a.cfg.ImageCredentialsKey == nil {
+		err = errors.New("image credentials are sealed but images.credentials_key is not set")
+	}
+	var c imagecreds.Credentials
+	if err == nil {
+		c, err = a.cfg.ImageCredentialsKey.Open(jobID, sealed)
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"username": c.Username, "password": c.Password})
+}
+
+func (a *App) internalDeleteImageCredentials(w http.ResponseWriter, r *http.Request) {
+	if _, err := a.db.Exec(r.Context(), `DELETE FROM image_credentials WHERE job_id=$1`, chi.URLParam(r, "id")); err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) internalCloneResult(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Strategy  string `json:"strategy"`
+		CommitSHA string `json:"commit_sha"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	a.execJob(w, r, `UPDATE jobs SET clone_strategy=$2, commit_sha=$3 WHERE id=$1`, req.Strategy, nullIfEmpty(req.CommitSHA))
+}
+
+func (a *App) internalRulePacks(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Packs []string `json:"packs"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	a.execJob(w, r, `UPDATE jobs SET rule_packs=$2 WHERE id=$1`, req.Packs)
+}
+
+func (a *App) internalStage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Stage    string           `json:"stage"`
+		Progress int              `json:"progress"`
+		Timings  map[string]int64 `json:"timings"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if req.Timings == nil {
+		req.Timings = map[string]int64{}
+	}
+	a.execJob(w, r, `UPDATE jobs SET stage=$2, progress=$3, stage_timings=$4 WHERE id=$1`, req.Stage, min(max(req.Progress, 0), 100), req.Timings)
+}
+
+func (a *App) internalToolVersions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Versions map[string]string `json:"versions"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if req.Versions == nil {
+		req.Versions = map[string]string{}
+	}
+	a.execJob(w, r, `UPDATE jobs SET tool_versions=$2 WHERE id=$1`, req.Versions)
+}
+
+func (a *App) internalProfile(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SizeKB    int64    `json:"size_kb"`
+		FileCount int      `json:"file_count"`
+		Languages []string `json:"languages"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if req.Languages == nil {
+		req.Languages = []string{}
+	}
+	a.execJob(w, r, `UPDATE repos SET size_kb=$2, file_count=$3, languages=$4, profiled_at=now()
+		WHERE id=(SELECT repo_id FROM jobs WHERE id=$1)`, req.SizeKB, req.FileCount, req.Languages)
+}
+
+// internalPackages replaces the packages recorded for the job.
+func (a *App) internalPackages(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Packages []struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+			Version   string `json:"version"`
+			PURL      string `json:"purl"`
+			Path      string `json:"path"`
+		} `json:"packages"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if len(req.Packages) > maxInternalPackages {
+		badRequest(w, "too many packages")
+		return
+	}
+	cols := make([][]string, 5)
+	for _, p := range req.Packages {
+		if p.Name == "" {
+			badRequest(w, "name is required")
+			return
+		}
+		for i, v := range []string{p.Ecosystem, p.Name, p.Version, p.PURL, p.Path} {
+			cols[i] = append(cols[i], v)
+		}
+	}
+
+	ctx, jobID := r.Context(), chi.URLParam(r, "id")
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id::text=$1)`, jobID).Scan(&exists); err != nil {
+		serverError(w, err)
+		return
+	}
+	if !exists {
+		notFound(w)
+		return
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM packages WHERE job_id=$1`, jobID); err != nil {
+		serverError(w, err)
+		return
+	}
+	if _, err := tx.Exec(ctx, jobsql.SetPackages, jobID, cols[0], cols[1], cols[2], cols[3], cols[4]); err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type internalFinding struct {
+	Tool        string          `json:"tool"`
+	Severity    string          `json:"severity"`
+	Title       string          `json:"title"`
+	FilePath    *string         `json:"file_path"`
+	LineStart   *int            `json:"line_start"`
+	LineEnd     *int            `json:"line_end"`
+	Fingerprint *string         `json:"fingerprint"`
+	Description *string         `json:"description"`
+	Evidence    json.RawMessage `json:"evidence"`
+}
+
+// internalAddFindings stores a batch of findings. The repo comes from the
+// job, not the request.
+func (a *App) internalAddFindings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Findings []internalFinding `json:"findings"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if len(req.Findings) > maxInternalFindings {
+		badRequest(w, "too many findings in one batch")
+		return
+	}
+	for _, f := range req.Findings {
+		if f.Tool == "" || f.Severity == "" || f.Title == "" {
+			badRequest(w, "tool, severity and title are required")
+			return
+		}
+	}
+
+	ctx, jobID := r.Context(), chi.URLParam(r, "id")
+	var repoID *string
+	if err := a.db.QueryRow(ctx, `SELECT repo_id::text FROM jobs WHERE id=$1`, jobID).Scan(&repoID); err != nil {
+		notFound(w)
+		return
+	}
+	b := &pgx.Batch{}
+	for _, f := range req.Findings {
+		ev, _ := json.Marshal(f.Evidence)
+		b.Queue(jobsql.InsertFinding,
+			repoID, jobID, f.Tool, f.Severity, f.Title, f.FilePath, f.LineStart, f.LineEnd, f.Fingerprint, f.Description, ev)
+	}
+	if err := a.db.SendBatch(ctx, b).Close(); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"stored": len(req.Findings)})
+}
+
+// internalNewFindings lists what the job found first, for the review
+// comments the worker posts on a pull request.
+func (a *App) internalNewFindings(w http.ResponseWriter, r *http.Request) {
+	a.writeJobFindings(w, r, jobsql.NewFindings)
+}
+
+// internalBranchFindings lists what the job found that the default branch
+// does not have, for the check run and commit status the worker posts.
+func (a *App) internalBranchFindings(w http.ResponseWriter, r *http.Request) {
+	a.writeJobFindings(w, r, jobsql.BranchFindings)
+}
+
+// writeJobFindings writes the findings sql selects for the job.
+func (a *App) writeJobFindings(w http.ResponseWriter, r *http.Request, sql string) {
+	rows, err := a.db.Query(r.Context(), sql, chi.URLParam(r, "id"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	findings, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (internalFinding, error) {
+		var f internalFinding
+		err := row.Scan(&f.Tool, &f.Severity, &f.Title, &f.FilePath, &f.LineStart, &f.LineEnd, &f.Fingerprint, &f.Description)
+		return f, err
+	})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"findings": findings})
+}
+
+// internalResolveFindings resolves what a default-branch scan no longer
+// reports. Like findings, the repo comes from the job.
+func (a *App) internalResolveFindings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Tools []string `json:"tools"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	ctx, jobID := r.Context(), chi.URLParam(r, "id")
+	var repoID *string
+	if err := a.db.QueryRow(ctx, `SELECT repo_id::text FROM jobs WHERE id=$1`, jobID).Scan(&repoID); err != nil {
+		notFound(w)
+		return
+	}
+	if repoID == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"resolved": 0})
+		return
+	}
+	tag, err := a.db.Exec(ctx, jobsql.ResolveFindings, *repoID, jobID, req.Tools)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"resolved": tag.RowsAffected()})
+}
+
+func (a *App) internalSaveArtifact(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	name, err := url.PathUnescape(chi.URLParam(r, "name"))
+	if kind == "" || err != nil || name == "" {
+		badRequest(w, "kind and name are required")
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": "artifact too large"})
+		return
+	}
+	digest := sha256.Sum256(data)
+	size, sum := int64(len(data)), hex.EncodeToString(digest[:])
+	// A worker that uploaded the artifact to the artifact store sends
+	// only where it is, with its size and digest.
+	var object *string
+	if q := r.URL.Query(); q.Get("object") != "" {
+		o := q.Get("object")
+		size, err = strconv.ParseInt(q.Get("size"), 10, 64)
+		if len(data) != 0 || err != nil || size < 0 || len(q.Get("sha256")) != sha256.Size*2 {
+			badRequest(w, "an artifact store object needs size and sha256 and no body")
+			return
+		}
+		object, sum, data = &o, q.Get("sha256"), nil
+	}
+	_, err = a.db.Exec(r.Context(), `INSERT INTO job_artifacts (job_id, kind, name, content_type, size_bytes, sha256, data, object_key) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT (job_id, name) DO UPDATE SET kind=EXCLUDED.kind, content_type=EXCLUDED.content_type, size_bytes=EXCLUDED.size_bytes, sha256=EXCLUDED.sha256, data=EXCLUDED.data, object_key=EXCLUDED.object_key, created_at=now()`,
+		chi.URLParam(r, "id"), kind, name, r.Header.Get("Content-Type"), size, sum, data, object)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) internalAppendJobLog(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Lines []JobLogLine `json:"lines"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if len(req.Lines) > maxInternalLogLines {
+		badRequest(w, "too many log lines in one batch")
+		return
+	}
+	attempts, times, streams, texts := make([]int, len(req.Lines)), make([]time.Time, len(req.Lines)), make([]string, len(req.Lines)), make([]string, len(req.Lines))
+	for i, l := range req.Lines {
+		attempts[i], times[i], streams[i], texts[i] = l.Attempt, l.Time, l.Stream, l.Text
+	}
+	ctx, jobID := r.Context(), chi.URLParam(r, "id")
+	var exists bool
+	if err := a.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id::text=$1)`, jobID).Scan(&exists); err != nil || !exists {
+		notFound(w)
+		return
+	}
+	if _, err := a.db.Exec(ctx, jobsql.AppendJobLog, jobID, attempts, times, streams, texts); err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) internalJobLog(w http.ResponseWriter, r *http.Request) {
+	lines, err := a.jobLogLines(r.Context(), chi.URLParam(r, "id"), 0)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"lines": lines})
+}
+
+func (a *App) internalArchiveJobLog(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Object string `json:"object"`
+	}
+	if !decodeInternal(w, r, &req) {
+		return
+	}
+	if req.Object == "" {
+		badRequest(w, "object is required")
+		return
+	}
+	ctx, jobID := r.Context(), chi.URLParam(r, "id")
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `UPDATE jobs SET log_object=$2 WHERE id::text=$1`, jobID, req.Object)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM job_logs WHERE job_id::text=$1`, jobID); err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) internalJobEvents(w http.ResponseWriter, r *http.Request) {
+	if err := a.publishJobEvents(r.Context(), chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			notFound(w)
+			return
+		}
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
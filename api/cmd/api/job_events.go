@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"argus/internal/jobsql"
+)
+
+// publishJobEvents writes webhook deliveries for a finished job to the
+// outbox, for workers that submit results through the internal API; the
+// dispatcher signs and sends them.
+func (a *App) publishJobEvents(ctx context.Context, jobID string) error {
+	events, err := jobsql.BuildJobEvents(ctx, func(ctx context.Context, sql string, args ...any) jobsql.Row {
+		return a.db.QueryRow(ctx, sql, args...)
+	}, jobID)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		b, err := json.Marshal(e.Payload)
+		if err != nil {
+			return err
+		}
+		tag, err := a.db.Exec(ctx, jobsql.EnqueueEvent, e.Name, b, e.Org)
+		if err != nil {
+			return fmt.Errorf("enqueue %s: %w", e.Name, err)
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			slog.InfoContext(ctx, "webhook event queued", "event", e.Name, "deliveries", n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Job logs are written by workers while a job runs (see the worker's
+// joblog.go) and served as NDJSON, one line per entry. With follow=true the
+// response stays open and streams new lines until the attempt's "end" line
+// of a finished job, or until just before the request deadline; clients
+// resume with after=<last id>.
+
+const (
+	logFollowPoll = time.Second
+	// logFollowGrace ends a follow once a finished job's log has been quiet
+	// this long, in case its worker died before writing the end line.
+	logFollowGrace = 10 * time.Second
+)
+
+type JobLogLine struct {
+	ID      int64     `json:"id,omitempty"`
+	Attempt int       `json:"attempt"`
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Text    string    `json:"text"`
+}
+
+func (a *App) getJobLogs(w http.ResponseWriter, r *http.Request) {
+	ctx, jobID := r.Context(), chi.URLParam(r, "id")
+	q := r.URL.Query()
+	var after int64
+	if v := q.Get("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			badRequest(w, "after must be a line id")
+			return
+		}
+		after = n
+	}
+	follow, _ := strconv.ParseBool(q.Get("follow"))
+
+	var object *string
+	if err := a.db.QueryRow(ctx, `SELECT log_object FROM jobs WHERE id::text=$1`, jobID).Scan(&object); err != nil {
+		notFound(w)
+		return
+	}
+	if object != nil {
+		if a.objects == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "job log is in the artifact store, which is not configured"})
+			return
+		}
+		http.Redirect(w, r, a.objects.PresignGet(*object, "", "application/x-ndjson", time.Now(), objectURLTTL), http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	write := func() error {
+		lines, err := a.jobLogLines(ctx, jobID, after)
+		if err != nil {
+			return err
+		}
+		for _, l := range lines {
+			if err := enc.Encode(l); err != nil {
+				return err
+			}
+			after = l.ID
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	if err := write(); err != nil || !follow {
+		return
+	}
+	for {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < 2*logFollowPoll {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logFollowPoll):
+		}
+		done, err := a.jobLogDone(ctx, jobID)
+		if err != nil {
+			return
+		}
+		// Lines written before the job was seen done are still sent.
+		if err := write(); err != nil || done {
+			return
+		}
+	}
+}
+
+func (a *App) jobLogLines(ctx context.Context, jobID string, after int64) ([]JobLogLine, error) {
+	rows, err := a.db.Query(ctx, `SELECT id, attempt, logged_at, stream, line FROM job_logs WHERE job_id::text=$1 AND id > $2 ORDER BY id`, jobID, after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]JobLogLine, 0)
+	for rows.Next() {
+		var l JobLogLine
+		if err := rows.Scan(&l.ID, &l.Attempt, &l.Time, &l.Stream, &l.Text); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// jobLogDone reports whether the job's log will not grow: the job has
+// finished and its last line ends an attempt, or it has been finished for
+// logFollowGrace, or its log was archived.
+func (a *App) jobLogDone(ctx context.Context, jobID string) (bool, error) {
+	var done bool
+	err := a.db.QueryRow(ctx, `SELECT j.status::text NOT IN ('queued','running') AND (
+			j.log_object IS NOT NULL OR j.finished_at < now() - make_interval(secs => $2)
+			OR COALESCE((SELECT l.stream = 'end' FROM job_logs l WHERE l.job_id = j.id ORDER BY l.id DESC LIMIT 1), true))
+		FROM jobs j WHERE j.id::text=$1`, jobID, logFollowGrace.Seconds()).Scan(&done)
+	return done, err
+}
@@ -0,0 +1,51 @@
+package main
+
+import "encoding/json"
+
+// localizeFinding replaces f's description and adds remediation guidance
+// from the translator's entry for its rule in lang ("" means English).
+// Findings without an entry keep the scanner's text.
+func (a *App) localizeFinding(f *Finding, lang string) {
+	if lang == "" {
+		lang = "en"
+	}
+	rule := findingRuleID(f.Evidence)
+	if rule == "" {
+		return
+	}
+	t, ok := a.translator.Translate(lang, f.Tool, rule)
+	if !ok {
+		return
+	}
+	if t.Description != "" {
+		f.Description = &t.Description
+	}
+	if t.Remediation != "" {
+		f.Remediation = &t.Remediation
+	}
+	f.Lang = lang
+}
+
+// findingRuleID reads the scanner's rule ID from a finding's evidence: the
+// check ID for semgrep, the rule ID for gitleaks and the check or
+// vulnerability ID for trivy.
+func findingRuleID(evidence json.RawMessage) string {
+	var ev struct {
+		TitleParts struct {
+			RuleID string `json:"rule_id"`
+			ID     string `json:"id"`
+		} `json:"title_parts"`
+		CheckID string `json:"check_id"`
+		RuleID  string `json:"rule_id"`
+		ID      string `json:"id"`
+	}
+	if len(evidence) == 0 || json.Unmarshal(evidence, &ev) != nil {
+		return ""
+	}
+	for _, id := range []string{ev.TitleParts.RuleID, ev.TitleParts.ID, ev.CheckID, ev.RuleID, ev.ID} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
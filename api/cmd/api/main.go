@@ -4,68 +4,140 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
+	_ "time/tzdata"
+
+	"argus/api/internal/config"
+	"argus/api/internal/localize"
+	"argus/api/internal/presign"
+	"argus/api/internal/webhooksign"
+	"argus/api/internal/webhookverify"
+	"argus/internal/objstore"
+	"argus/internal/proxy"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
 )
 
-type Config struct {
-	Token       string
-	DatabaseURL string
-	RedisAddr   string
-}
-
 type App struct {
-	cfg   Config
-	db    *pgxpool.Pool
-	redis *redis.Client
+	cfg       config.Config
+	db        *pgxpool.Pool
+	queue     jobQueue
+	signer    *webhooksign.Keyring
+	presigner *presign.Signer
+	// objects presigns downloads of artifacts in the artifact store; nil
+	// when artifacts.s3.bucket is unset.
+	objects *objstore.Client
+	metrics *apiMetrics
+	// translator localizes finding descriptions and remediation guidance.
+	translator localize.Translator
 }
 
 var errNotFound = errors.New("not found")
 
+// shutdownGrace is slightly longer than the per-request timeout so requests
+// that were accepted before SIGTERM can run to completion.
+const shutdownGrace = 35 * time.Second
+
 func main() {
-	cfg := Config{
-		Token:       os.Getenv("SSAO_TOKEN"),
-		DatabaseURL: os.Getenv("DATABASE_URL"),
-		RedisAddr:   os.Getenv("REDIS_ADDR"),
-	}
-	if cfg.Token == "" {
-		cfg.Token = "change-me-super-long-random"
+	configPath := flag.String("config", os.Getenv("ARGUS_CONFIG"), "path to a YAML or TOML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("invalid configuration", err)
 	}
-	if cfg.DatabaseURL == "" || cfg.RedisAddr == "" {
-		log.Fatal("DATABASE_URL and REDIS_ADDR are required")
+	setupLogging(cfg.LogLevel, cfg.LogFormat)
+	if cfg.Token == config.DefaultToken {
+		slog.Warn("using the built-in API token; set auth.token or SSAO_TOKEN")
 	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	db, err := pgxpool.New(ctx, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal(err)
+		fatal("connect postgres", err)
 	}
 	defer db.Close()
 
-	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatal(err)
+	signer := webhooksign.Ephemeral()
+	if cfg.SigningKeys != "" {
+		signer, err = webhooksign.Parse(cfg.SigningKeys)
+		if err != nil {
+			fatal("parse WEBHOOK_SIGNING_KEYS", err)
+		}
+	} else {
+		slog.Warn("WEBHOOK_SIGNING_KEYS not set; using an ephemeral webhook signing key")
 	}
 
-	app := &App{cfg: cfg, db: db, redis: rdb}
+	if cfg.Offline {
+		slog.Info("offline mode: GitHub API calls are disabled")
+	}
+	if cfg.ArtifactURLSecret == "" {
+		slog.Warn("ARTIFACT_URL_SECRET not set; artifact URLs are only valid on this process until it restarts")
+	}
+
+	catalog := localize.Builtin()
+	if cfg.CatalogDir != "" {
+		if err := catalog.LoadDir(cfg.CatalogDir); err != nil {
+			fatal("load localization catalog", err)
+		}
+	}
+
+	if _, err := normalizeScanners(cfg.SkipScanners); err != nil {
+		fatal("invalid skip.scanners", err)
+	}
+
+	app := &App{cfg: cfg, db: db, signer: signer, presigner: presign.New(cfg.ArtifactURLSecret), translator: catalog}
+	if cfg.ArtifactStore.Enabled() {
+		if app.objects, err = objstore.New(cfg.ArtifactStore, proxy.Setting{NoProxy: cfg.NoProxy}); err != nil {
+			fatal("artifact store", err)
+		}
+	}
+	if app.queue, err = app.newJobQueue(ctx); err != nil {
+		fatal("connect "+cfg.QueueBackend, err)
+	}
+	defer app.queue.Close()
+	app.metrics = newAPIMetrics(app)
+	if err := app.syncOrgWeights(ctx); err != nil {
+		slog.Warn("org queue weights not synced to the queue; workers use the default weight", "err", err)
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
-	r.Use(middleware.Logger)
+	r.Use(exceptDebug(middleware.Timeout(30 * time.Second)))
+	r.Use(requestLogger)
+	r.Use(app.metrics.instrument)
+
+	r.Get("/healthz", app.healthz)
+
+	r.Method(http.MethodGet, "/metrics", app.metrics.registry.Handler())
+	r.Get("/webhooks/signing-keys", app.webhookSigningKeys)
+	if len(cfg.GitHubWebhookSecrets) > 0 {
+		r.With(app.webhookVerifier(webhookverify.GitHub, cfg.GitHubWebhookSecrets).Middleware).Post("/webhooks/github", app.receiveGitHubEvent)
+	}
+	if len(cfg.GitLabWebhookSecrets) > 0 {
+		r.With(app.webhookVerifier(webhookverify.GitLab, cfg.GitLabWebhookSecrets).Middleware).Post("/webhooks/gitlab", app.receiveGitLabEvent)
+	}
+	r.Get("/artifacts/{id}", app.downloadArtifact)
 
-	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	r.Route("/debug", app.debugRoutes)
+	r.Route("/internal", app.internalRoutes)
+
+	r.Route("/feeds", func(r chi.Router) {
+		r.Use(app.feedAuthz)
+		r.Get("/repos/{id}", app.repoFindingsFeed)
+		r.Get("/orgs/{org}", app.orgFindingsFeed)
 	})
 
 	r.Route("/api", func(r chi.Router) {
@@ -73,22 +145,86 @@ func main() {
 		r.Get("/repos", app.listRepos)
 		r.Post("/repos", app.createRepo)
 		r.Get("/repos/{id}", app.getRepo)
+		r.Get("/repos/{id}/settings", app.getRepoSettings)
+		r.Patch("/repos/{id}/settings", app.updateRepoSettings)
+		r.Patch("/repos/{id}", app.updateRepo)
 		r.Post("/repos/{id}/scans", app.triggerScan)
+		r.Post("/scans/adhoc", app.createAdhocScan)
+		r.Post("/images", app.createImageScan)
 		r.Get("/jobs/{id}", app.getJob)
+		r.Post("/jobs/{id}/rerun", app.rerunJob)
+		r.Get("/jobs/{id}/findings", app.listJobFindings)
+		r.Get("/jobs/{id}/artifacts", app.listJobArtifacts)
+		r.Get("/jobs/{id}/summary", app.getJobSummary)
+		r.Get("/jobs/{id}/logs", app.getJobLogs)
 		r.Get("/repos/{id}/findings", app.listFindings)
+		r.Get("/repos/{id}/compliance/{framework}", app.complianceReport)
+		r.Get("/repos/{id}/sbom", app.repoSBOM)
 		r.Post("/repos/{id}/pr-suggestions", app.prSuggestions)
 		r.Post("/repos/{id}/pull-requests", app.createPullRequest)
+		r.Post("/repos/{id}/github-alerts/sync", app.syncGitHubAlerts)
+		r.Get("/github/repositories", app.listInstallationRepos)
+		r.Post("/github/repositories/register", app.registerInstallationRepos)
+		r.Get("/repos/{id}/schedules", app.listSchedules)
+		r.Post("/repos/{id}/schedules", app.createSchedule)
+		r.Patch("/schedules/{id}", app.updateSchedule)
+		r.Delete("/schedules/{id}", app.deleteSchedule)
+		r.Get("/orgs/{org}", app.getOrg)
+		r.Put("/orgs/{org}", app.updateOrg)
+		r.Get("/groups/{tag}", app.getGroup)
+		r.Put("/groups/{tag}", app.updateGroup)
+		r.Get("/groups/{tag}/gate", app.groupGate)
+		r.Get("/posture", app.posture)
+		r.Get("/packages", app.listPackages)
+		r.Get("/orgs/{org}/digest", app.orgDigest)
+		r.Get("/orgs/{org}/semgrep-rules", app.listSemgrepRules)
+		r.Get("/orgs/{org}/semgrep-rules/{name}", app.getSemgrepRule)
+		r.Put("/orgs/{org}/semgrep-rules/{name}", app.putSemgrepRule)
+		r.Patch("/orgs/{org}/semgrep-rules/{name}", app.updateSemgrepRule)
+		r.Delete("/orgs/{org}/semgrep-rules/{name}", app.deleteSemgrepRule)
+		r.Get("/webhooks", app.listWebhooks)
+		r.Post("/webhooks", app.createWebhook)
+		r.Delete("/webhooks/{id}", app.deleteWebhook)
+		r.Get("/webhooks/{id}/deliveries", app.listWebhookDeliveries)
+		r.Route("/admin", app.adminRoutes)
 	})
 
-	log.Println("API listening on :8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		log.Fatal(err)
+	go app.runScheduler(ctx)
+	go app.runWebhookDispatcher(ctx)
+	go app.runJobReconciler(ctx)
+	if interval, _ := time.ParseDuration(cfg.GitHubAlertsSyncInterval); interval > 0 && !cfg.Offline {
+		go app.runAlertSync(ctx, interval)
+	}
+
+	srv := &http.Server{Addr: cfg.ListenAddr, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("API listening", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("http server", err)
+		}
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutdown signal received; draining in-flight requests")
+		// In-flight handlers must not observe the cancelled signal context;
+		// they finish on their own request contexts within the grace period.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("graceful shutdown incomplete", "err", err)
+		}
 	}
+	slog.Info("API stopped")
 }
 
 func (a *App) authz(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Authorization") != "Bearer "+a.cfg.Token {
+		if r.Header.Get("Authorization") != "Bearer "+a.cfg.Token && !a.isAdmin(r) {
 			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
 			return
 		}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"argus/api/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type apiMetrics struct {
+	registry       *metrics.Registry
+	requests       *metrics.CounterVec
+	latency        *metrics.HistogramVec
+	scansTriggered *metrics.CounterVec
+	scansSkipped   *metrics.CounterVec
+}
+
+func newAPIMetrics(a *App) *apiMetrics {
+	reg := metrics.NewRegistry()
+	m := &apiMetrics{
+		registry:       reg,
+		requests:       reg.NewCounterVec("argus_http_requests_total", "HTTP requests by route and status.", "method", "route", "status"),
+		latency:        reg.NewHistogramVec("argus_http_request_duration_seconds", "HTTP request latency by route and status.", nil, "method", "route", "status"),
+		scansTriggered: reg.NewCounterVec("argus_scans_triggered_total", "Scan jobs enqueued."),
+		scansSkipped:   reg.NewCounterVec("argus_scans_skipped_total", "Scans skipped because only non-code paths changed."),
+	}
+
+	reg.NewGaugeFunc("argus_db_pool_connections", "Postgres pool connections by state.", []string{"state"}, func(context.Context) []metrics.Sample {
+		st := a.db.Stat()
+		return []metrics.Sample{
+			{LabelValues: []string{"acquired"}, Value: float64(st.AcquiredConns())},
+			{LabelValues: []string{"idle"}, Value: float64(st.IdleConns())},
+			{LabelValues: []string{"constructing"}, Value: float64(st.ConstructingConns())},
+			{LabelValues: []string{"total"}, Value: float64(st.TotalConns())},
+			{LabelValues: []string{"max"}, Value: float64(st.MaxConns())},
+		}
+	})
+	reg.NewGaugeFunc("argus_db_pool_acquire_wait_seconds_total", "Cumulative time spent waiting for a pool connection.", nil, func(context.Context) []metrics.Sample {
+		return []metrics.Sample{{Value: a.db.Stat().AcquireDuration().Seconds()}}
+	})
+	reg.NewGaugeFunc("argus_db_pool_empty_acquire_total", "Acquires that had to wait because the pool was empty.", nil, func(context.Context) []metrics.Sample {
+		return []metrics.Sample{{Value: float64(a.db.Stat().EmptyAcquireCount())}}
+	})
+	reg.NewGaugeFunc("argus_queue_depth", "Messages waiting in each priority queue.", []string{"queue"}, func(ctx context.Context) []metrics.Sample {
+		depths, err := a.queue.Depths(ctx)
+		if err != nil {
+			return nil
+		}
+		out := make([]metrics.Sample, 0, len(depths))
+		for _, k := range a.queueKeys() {
+			out = append(out, metrics.Sample{LabelValues: []string{k}, Value: float64(depths[k])})
+		}
+		return out
+	})
+	return m
+}
+
+// instrument records latency and status per chi route pattern, so path
+// parameters such as repo IDs do not explode label cardinality.
+func (m *apiMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := strconv.Itoa(status)
+		m.requests.Inc(r.Method, route, code)
+		m.latency.Observe(time.Since(start).Seconds(), r.Method, route, code)
+	})
+}
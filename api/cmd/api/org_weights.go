@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxQueueWeight caps an org's share: at 100 it takes 100 jobs for every one
+// of a default-weight org.
+const maxQueueWeight = 100
+
+// syncOrgWeights publishes the non-default weights from Postgres to the job
+// queue, replacing what workers read. The API runs it at startup so a
+// flushed or new Redis picks up the configured weights.
+func (a *App) syncOrgWeights(ctx context.Context) error {
+	rows, err := a.db.Query(ctx, `SELECT name, queue_weight FROM orgs WHERE queue_weight <> 1`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	weights := map[string]int{}
+	for rows.Next() {
+		var org string
+		var weight int
+		if err := rows.Scan(&org, &weight); err != nil {
+			return err
+		}
+		weights[org] = weight
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return a.queue.SyncOrgWeights(ctx, weights)
+}
+
+// listOrgWeights returns every org with its queue weight.
+func (a *App) listOrgWeights(w http.ResponseWriter, r *http.Request) {
+	rows, err := a.db.Query(r.Context(), `SELECT name, queue_weight FROM orgs ORDER BY name`)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	out := make([]map[string]any, 0)
+	for rows.Next() {
+		var org string
+		var weight int
+		if err := rows.Scan(&org, &weight); err != nil {
+			serverError(w, err)
+			return
+		}
+		out = append(out, map[string]any{"org": org, "weight": weight})
+	}
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"default_weight": 1, "orgs": out})
+}
+
+// setOrgWeight stores an org's weight and publishes it to workers. Weight 1
+// restores the default.
+func (a *App) setOrgWeight(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	var req struct {
+		Weight int `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	if req.Weight < 1 || req.Weight > maxQueueWeight {
+		badRequest(w, "weight must be between 1 and "+strconv.Itoa(maxQueueWeight))
+		return
+	}
+	ctx := r.Context()
+	tag, err := a.db.Exec(ctx, `UPDATE orgs SET queue_weight=$2, updated_at=now() WHERE name=$1`, org, req.Weight)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	if err := a.queue.SetOrgWeight(ctx, org, req.Weight); err != nil {
+		serverError(w, err)
+		return
+	}
+	slog.InfoContext(ctx, "org queue weight set", "org", org, "weight", req.Weight)
+	writeJSON(w, http.StatusOK, map[string]any{"org": org, "weight": req.Weight})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Org struct {
+	Name           string            `json:"name"`
+	Timezone       string            `json:"timezone"`
+	Locale         string            `json:"locale"`
+	TitleTemplates map[string]string `json:"title_templates"`
+	// SaltedFingerprints reports whether findings are fingerprinted with
+	// the org's secret salt. The salt itself is never returned.
+	SaltedFingerprints bool `json:"salted_fingerprints"`
+	// QueueWeight is the org's share of workers under contention; admins
+	// set it through /api/admin/org-weights.
+	QueueWeight int `json:"queue_weight"`
+	// SemgrepRulesMode is add or replace: whether the org's Semgrep rule
+	// files run alongside the selected packs or instead of them.
+	SemgrepRulesMode string    `json:"semgrep_rules_mode"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type updateOrgReq struct {
+	Timezone *string `json:"timezone"`
+	Locale   *string `json:"locale"`
+	// TitleTemplates replaces the org's templates as a whole when present.
+	TitleTemplates *map[string]string `json:"title_templates"`
+	// SaltedFingerprints can only be turned on.
+	SaltedFingerprints *bool   `json:"salted_fingerprints"`
+	SemgrepRulesMode   *string `json:"semgrep_rules_mode"`
+}
+
+func (a *App) getOrg(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	var o Org
+	err := a.db.QueryRow(r.Context(), `SELECT name, timezone, locale, title_templates, fingerprint_salt IS NOT NULL, queue_weight, semgrep_rules_mode, updated_at FROM orgs WHERE name=$1`, org).
+		Scan(&o.Name, &o.Timezone, &o.Locale, &o.TitleTemplates, &o.SaltedFingerprints, &o.QueueWeight, &o.SemgrepRulesMode, &o.UpdatedAt)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (a *App) updateOrg(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	var req updateOrgReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(strings.TrimSpace(*req.Timezone)); err != nil || strings.TrimSpace(*req.Timezone) == "" {
+			badRequest(w, "timezone must be an IANA zone name such as Australia/Sydney")
+			return
+		}
+	}
+	if req.Locale != nil && strings.TrimSpace(*req.Locale) == "" {
+		badRequest(w, "locale must not be empty")
+		return
+	}
+	if req.SemgrepRulesMode != nil && *req.SemgrepRulesMode != semgrepRulesAdd && *req.SemgrepRulesMode != semgrepRulesReplace {
+		badRequest(w, "semgrep_rules_mode must be add or replace")
+		return
+	}
+	var templates []byte
+	if req.TitleTemplates != nil {
+		t, err := normalizeTitleTemplates(*req.TitleTemplates)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		templates, _ = json.Marshal(t)
+	}
+	if req.SaltedFingerprints != nil && !*req.SaltedFingerprints {
+		var salted bool
+		if err := a.db.QueryRow(r.Context(), `SELECT fingerprint_salt IS NOT NULL FROM orgs WHERE name=$1`, org).Scan(&salted); err == nil && salted {
+			badRequest(w, "salted fingerprints cannot be turned off")
+			return
+		}
+	}
+
+	tag, err := a.db.Exec(r.Context(), `UPDATE orgs SET
+		timezone = COALESCE($2, timezone),
+		locale = COALESCE($3, locale),
+		title_templates = COALESCE($4::jsonb, title_templates),
+		semgrep_rules_mode = COALESCE($5, semgrep_rules_mode),
+		updated_at = now()
+		WHERE name=$1`, org, trimPtr(req.Timezone), trimPtr(req.Locale), templates, req.SemgrepRulesMode)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	if req.Timezone != nil {
+		if err := a.rescheduleOrg(r.Context(), org); err != nil {
+			serverError(w, err)
+			return
+		}
+	}
+	if req.SaltedFingerprints != nil && *req.SaltedFingerprints {
+		if err := a.saltOrgFingerprints(r.Context(), org); err != nil {
+			serverError(w, err)
+			return
+		}
+	}
+	a.getOrg(w, r)
+}
+
+// saltOrgFingerprints gives org a random fingerprint salt and rekeys its
+// stored findings with HMAC-SHA256(salt, fingerprint), the same function the
+// worker applies to new findings, so deduplication against earlier scans
+// carries on. Public IDs are untouched. It is a no-op for salted orgs.
+func (a *App) saltOrgFingerprints(ctx context.Context, org string) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	salt := hex.EncodeToString(b)
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `UPDATE orgs SET fingerprint_salt=$2, updated_at=now() WHERE name=$1 AND fingerprint_salt IS NULL`, org, salt)
+	if err != nil || tag.RowsAffected() == 0 {
+		return err
+	}
+	tag, err = tx.Exec(ctx, `UPDATE findings f SET fingerprint = encode(hmac(f.fingerprint, $2, 'sha256'), 'hex')
+		FROM repos r WHERE r.id = f.repo_id AND r.org = $1 AND f.fingerprint IS NOT NULL`, org, salt)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "org fingerprints salted", "org", org, "findings", tag.RowsAffected())
+	return nil
+}
+
+// orgLocation resolves the org's configured time zone, falling back to UTC.
+func (a *App) orgLocation(ctx context.Context, org string) *time.Location {
+	var tz string
+	if err := a.db.QueryRow(ctx, `SELECT timezone FROM orgs WHERE name=$1`, org).Scan(&tz); err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func trimPtr(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*v)
+	return &t
+}
+
+// localLabel renders t in loc with both the abbreviation and the zone name,
+// e.g. "2024-06-11 02:00 AEST (Australia/Sydney)".
+func localLabel(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02 15:04 MST") + " (" + loc.String() + ")"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PackageUse is a repo whose latest scan found a package.
+type PackageUse struct {
+	RepoID    string    `json:"repo_id"`
+	RepoName  string    `json:"repo_name"`
+	Org       *string   `json:"org"`
+	Ecosystem string    `json:"ecosystem"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	PURL      *string   `json:"purl"`
+	Path      string    `json:"path"`
+	JobID     string    `json:"job_id"`
+	Ref       *string   `json:"ref"`
+	CommitSHA *string   `json:"commit_sha"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// listPackages answers "which repos pin this package?" from each repo's
+// latest successful scan that recorded packages. ?name= matches the package
+// name without case, such as log4j-core; ?version= and ?ecosystem= narrow it.
+func (a *App) listPackages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	name := strings.TrimSpace(q.Get("name"))
+	if name == "" {
+		badRequest(w, "name is required")
+		return
+	}
+	rows, err := a.db.Query(r.Context(), `WITH latest AS (
+			SELECT DISTINCT ON (j.repo_id) j.id, j.repo_id, j.ref, j.commit_sha, j.finished_at FROM jobs j
+			WHERE j.status='succeeded' AND EXISTS (SELECT 1 FROM packages p WHERE p.job_id=j.id)
+			ORDER BY j.repo_id, j.finished_at DESC)
+		SELECT rp.id::text, rp.name, rp.org, p.ecosystem, p.name, p.version, p.purl, p.path, l.id::text, l.ref, l.commit_sha, l.finished_at
+		FROM packages p JOIN latest l ON l.id = p.job_id JOIN repos rp ON rp.id = l.repo_id
+		WHERE lower(p.name) = lower($1) AND ($2 = '' OR p.version = $2) AND ($3 = '' OR p.ecosystem = lower($3))
+		ORDER BY rp.name, p.version, p.path`, name, strings.TrimSpace(q.Get("version")), strings.TrimSpace(q.Get("ecosystem")))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	out := make([]PackageUse, 0)
+	repos := map[string]bool{}
+	for rows.Next() {
+		var u PackageUse
+		if err := rows.Scan(&u.RepoID, &u.RepoName, &u.Org, &u.Ecosystem, &u.Name, &u.Version, &u.PURL, &u.Path, &u.JobID, &u.Ref, &u.CommitSHA, &u.ScannedAt); err != nil {
+			serverError(w, err)
+			return
+		}
+		repos[u.RepoID] = true
+		out = append(out, u)
+	}
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"name": name, "repos": len(repos), "packages": out})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The posture endpoint answers a service catalog card in one request: the
+// repos behind a catalog entity, found by external ref or repo URL, with
+// their gate verdict, latest scan, finding counts and Argus pull requests.
+
+// maxPosturePRs caps the pull requests listed per repo.
+const maxPosturePRs = 10
+
+type postureRepo struct {
+	*GroupGateRepo
+	URL          string            `json:"url"`
+	ExternalRefs map[string]string `json:"external_refs"`
+	// Policy is the strictest policy of the repo's groups, or the default.
+	Policy       GroupPolicy `json:"policy"`
+	PullRequests []posturePR `json:"pull_requests"`
+}
+
+type posturePR struct {
+	URL       string    `json:"url"`
+	Branch    *string   `json:"branch"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// posture looks repos up by ?external_ref=<key>:<value> or ?repo_url=, and
+// passes only when every one of them passes its gate.
+func (a *App) posture(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	ref, repoURL := strings.TrimSpace(q.Get("external_ref")), strings.TrimSpace(q.Get("repo_url"))
+	var where string
+	var arg any
+	switch {
+	case ref != "" && repoURL != "":
+		badRequest(w, "pass one of external_ref and repo_url")
+		return
+	case ref != "":
+		key, value, ok := strings.Cut(ref, ":")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if !ok || validExternalRef(key, value) != nil {
+			badRequest(w, "external_ref must be <key>:<value>")
+			return
+		}
+		where, arg = `rp.external_refs @> $1::jsonb`, map[string]string{key: value}
+	case repoURL != "":
+		// Catalogs often record the URL without .git.
+		where, arg = `lower(rp.url) IN (lower($1), lower($1) || '.git')`, strings.TrimSuffix(repoURL, "/")
+	default:
+		badRequest(w, "external_ref or repo_url is required")
+		return
+	}
+
+	members, err := a.latestFullScans(ctx, where, arg)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if len(members) == 0 {
+		notFound(w)
+		return
+	}
+	pass, now := true, time.Now()
+	out := make([]postureRepo, 0, len(members))
+	for _, m := range members {
+		p := postureRepo{GroupGateRepo: m, PullRequests: []posturePR{}}
+		if err := a.db.QueryRow(ctx, `SELECT url, external_refs FROM repos WHERE id=$1`, m.RepoID).Scan(&p.URL, &p.ExternalRefs); err != nil {
+			serverError(w, err)
+			return
+		}
+		if p.Policy, err = a.repoPolicy(ctx, m.RepoID); err != nil {
+			serverError(w, err)
+			return
+		}
+		p.Policy.judge(m, now)
+		pass = pass && m.Pass
+
+		rows, err := a.db.Query(ctx, `SELECT pr_url, branch, created_at FROM prs WHERE repo_id=$1 AND status='created' AND pr_url IS NOT NULL
+			ORDER BY created_at DESC LIMIT $2`, m.RepoID, maxPosturePRs)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		for rows.Next() {
+			var pr posturePR
+			if err := rows.Scan(&pr.URL, &pr.Branch, &pr.CreatedAt); err != nil {
+				rows.Close()
+				serverError(w, err)
+				return
+			}
+			p.PullRequests = append(p.PullRequests, pr)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			serverError(w, err)
+			return
+		}
+		out = append(out, p)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"pass": pass, "repos": out})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Preview environments call POST /api/repos/{id}/scans with the commit they
+// deployed and the deployment's URL. The job then scans that commit
+// statically and runs nuclei against the deployment, so static and dynamic
+// findings for the change land on one job record.
+
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// normalizeCommitSHA lowercases a full commit SHA. Abbreviated SHAs are
+// rejected: the worker fetches the commit by name, which needs all of it.
+func normalizeCommitSHA(sha string) (string, error) {
+	sha = strings.ToLower(strings.TrimSpace(sha))
+	if sha != "" && !commitSHA.MatchString(sha) {
+		return "", errors.New("commit_sha must be a full 40-character hex SHA")
+	}
+	return sha, nil
+}
+
+// validateDeploymentURL checks a deployment URL against dast.allowed_hosts,
+// so a scan request cannot point nuclei at arbitrary hosts.
+func (a *App) validateDeploymentURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if len(a.cfg.DASTAllowedHosts) == 0 {
+		return "", errors.New("deployment_url is not enabled on this server (dast.allowed_hosts)")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return "", errors.New("deployment_url must be an http(s) URL")
+	}
+	if u.User != nil {
+		return "", errors.New("deployment_url must not contain credentials")
+	}
+	if !a.cfg.DASTHostAllowed(u.Hostname()) {
+		return "", fmt.Errorf("deployment_url host %q is not in dast.allowed_hosts", u.Hostname())
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
@@ -2,19 +2,64 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
+	"argus/api/internal/commitsign"
+	"argus/api/internal/patch"
 	"argus/api/internal/pr"
+	"argus/internal/forge"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type createPRReq struct {
-	Title      string `json:"title"`
-	BaseBranch string `json:"base_branch"`
-	Confirm    bool   `json:"confirm"`
-	MaxFixes   int    `json:"max_fixes"`
+	Title       string `json:"title"`
+	BaseBranch  string `json:"base_branch"`
+	Confirm     bool   `json:"confirm"`
+	MaxFixes    int    `json:"max_fixes"`
+	MinSeverity string `json:"min_severity"`
+	// Draft, Labels, Reviewers and TeamReviewers shape the pull request
+	// opened on confirm.
+	Draft         bool     `json:"draft"`
+	Labels        []string `json:"labels"`
+	Reviewers     []string `json:"reviewers"`
+	TeamReviewers []string `json:"team_reviewers"`
+}
+
+// maxPROptions caps the labels, and the reviewers, one request can add.
+const maxPROptions = 20
+
+var (
+	// githubLogin is a GitHub user name: alphanumerics and single hyphens.
+	githubLogin = regexp.MustCompile(`^[A-Za-z0-9](?:-?[A-Za-z0-9]){0,38}$`)
+	// teamSlug is the slug of a GitHub team, as its URL shows it.
+	teamSlug = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,99}$`)
+)
+
+// prNames trims and dedupes the labels or reviewers field lists, checking
+// each against valid when set; GitHub caps label names at 50 characters.
+func prNames(field string, names []string, valid *regexp.Regexp) ([]string, error) {
+	if len(names) > maxPROptions {
+		return nil, fmt.Errorf("%s can name at most %d", field, maxPROptions)
+	}
+	var out []string
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		switch {
+		case n == "" || utf8.RuneCountInString(n) > 50:
+			return nil, fmt.Errorf("%s must be 1 to 50 characters each", field)
+		case valid != nil && !valid.MatchString(n):
+			return nil, fmt.Errorf("invalid %s entry %q", field, n)
+		}
+		if !contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	return out, nil
 }
 
 func (a *App) createPullRequest(w http.ResponseWriter, r *http.Request) {
@@ -30,15 +75,54 @@ func (a *App) createPullRequest(w http.ResponseWriter, r *http.Request) {
 	if req.MaxFixes <= 0 {
 		req.MaxFixes = 10
 	}
+	req.MinSeverity = strings.ToUpper(strings.TrimSpace(req.MinSeverity))
+	if req.MinSeverity != "" && !patch.ValidSeverity(req.MinSeverity) {
+		badRequest(w, "min_severity must be one of CRITICAL, HIGH, MEDIUM, LOW")
+		return
+	}
+
+	opts := pr.Options{Draft: req.Draft}
+	for _, f := range []struct {
+		name  string
+		in    []string
+		valid *regexp.Regexp
+		out   *[]string
+	}{
+		{"labels", req.Labels, nil, &opts.Labels},
+		{"reviewers", req.Reviewers, githubLogin, &opts.Reviewers},
+		{"team_reviewers", req.TeamReviewers, teamSlug, &opts.TeamReviewers},
+	} {
+		names, err := prNames(f.name, f.in, f.valid)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		*f.out = names
+	}
 
-	svc := pr.NewService(a.db)
+	if req.Confirm && a.cfg.Offline {
+		badRequest(w, "opening pull requests needs the code host's API and is disabled in offline mode; use confirm=false for a dry run")
+		return
+	}
+
+	hosts := pr.Hosts{GitHub: forge.GitHub(a.cfg.GitHubURL), GitLab: forge.GitLab(a.cfg.GitLabURL), GitLabToken: a.cfg.GitLabToken,
+		AzureDevOpsToken: a.cfg.AzureDevOpsToken}
+	committer := pr.Committer{Name: a.cfg.PRCommitterName, Email: a.cfg.PRCommitterEmail}
+	if a.cfg.PRSigningKey != "" {
+		// Load has checked the key parses.
+		key, _ := commitsign.Parse(a.cfg.PRSigningKey)
+		committer.Key = &key
+	}
+	svc := pr.NewService(a.db, a.cfg.MaxCloneMB, hosts, committer, a.cfg.GitHubProxySetting())
 	res, err := svc.Create(r.Context(), pr.Request{
 		RepoID:      repoID,
 		Title:       req.Title,
 		BaseBranch:  req.BaseBranch,
 		Confirm:     req.Confirm,
 		MaxFixes:    req.MaxFixes,
+		MinSeverity: req.MinSeverity,
 		RequestedBy: r.Header.Get("Authorization"),
+		Options:     opts,
 	})
 	if err != nil {
 		badRequest(w, err.Error())
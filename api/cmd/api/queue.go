@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"argus/api/internal/config"
+	"argus/api/internal/sizing"
+)
+
+// jobQueue is where jobs wait for workers; queue.backend picks Redis or
+// Postgres. Messages are JSON job messages, routed to a queue key by
+// priority and size class; the worker's queue.go takes them. Besides
+// waiting messages, a backend keeps those deferred for a retry, those in
+// flight on a worker, dead letters and the worker registry.
+type jobQueue interface {
+	// Push adds payload to the back of org's share of the queue key.
+	Push(ctx context.Context, key, org string, payload []byte) error
+	// Depths returns the number of waiting messages per queue key.
+	Depths(ctx context.Context) (map[string]int64, error)
+	// Messages returns the raw messages in a set; dead letters come
+	// newest first.
+	Messages(ctx context.Context, set string) ([]string, error)
+	// Remove drops every copy of raw from a set and reports how many.
+	Remove(ctx context.Context, set, raw string) (int64, error)
+	// RestoreDeadLetter puts back a dead letter that was removed.
+	RestoreDeadLetter(ctx context.Context, raw string) error
+	// Workers returns the registered workers by name, with whether their
+	// heartbeat is current.
+	Workers(ctx context.Context) (map[string]*WorkerInfo, error)
+	// SyncOrgWeights replaces the weights workers read with the
+	// non-default ones given; SetOrgWeight changes one.
+	SyncOrgWeights(ctx context.Context, weights map[string]int) error
+	SetOrgWeight(ctx context.Context, org string, weight int) error
+	Ping(ctx context.Context) error
+	// Close releases the backend's connections.
+	Close() error
+	// Name is the backend's name, as in queue.backend.
+	Name() string
+}
+
+// Message sets of a jobQueue.
+const (
+	queueWaiting  = "waiting"
+	queueDelayed  = "delayed"
+	queueInFlight = "in_flight"
+	queueDead     = "dead"
+)
+
+// newJobQueue connects the configured backend.
+func (a *App) newJobQueue(ctx context.Context) (jobQueue, error) {
+	if a.cfg.QueueBackend == config.QueuePostgres {
+		return &pgQueue{db: a.db, base: a.cfg.JobQueue, keys: a.queueKeys()}, nil
+	}
+	q := newRedisQueue(a.cfg.RedisAddr, a.cfg.JobQueue, a.queueKeys())
+	return q, q.Ping(ctx)
+}
+
+// Job priorities. Workers drain high, then normal, then low. normal uses
+// queue.jobs itself, so workers from before priorities still see it.
+const (
+	priorityHigh   = "high"
+	priorityNormal = "normal"
+	priorityLow    = "low"
+)
+
+var priorities = []string{priorityHigh, priorityNormal, priorityLow}
+
+// normalizePriority validates p, returning def when it is empty.
+func normalizePriority(p, def string) (string, error) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	if p == "" {
+		return def, nil
+	}
+	if !contains(priorities, p) {
+		return "", fmt.Errorf("priority must be one of %s", strings.Join(priorities, ", "))
+	}
+	return p, nil
+}
+
+// queueKey is the queue for jobs of a size class and priority. Small and
+// large jobs have their own queues, <queue>:small and <queue>:large, so they
+// can be served by separate worker pools; medium jobs use queue.jobs.
+func (a *App) queueKey(class, priority string) string {
+	key := a.cfg.JobQueue
+	if class == sizing.Small || class == sizing.Large {
+		key += ":" + class
+	}
+	if priority == priorityHigh || priority == priorityLow {
+		key += ":" + priority
+	}
+	return key
+}
+
+// queueKeys returns every queue, by priority and then size class.
+func (a *App) queueKeys() []string {
+	var keys []string
+	for _, p := range priorities {
+		for _, c := range sizing.Classes {
+			keys = append(keys, a.queueKey(c, p))
+		}
+	}
+	return keys
+}
+
+// Within a priority, jobs wait per org and workers take from the active orgs
+// in weighted order; see the worker's queue.go. Jobs without an org are
+// queued under unassignedOrg.
+const unassignedOrg = "_none"
+
+// pushJob classifies msg's job, records its size class and adds msg to the
+// back of its org's share of the queue for that class and priority. The org
+// comes from msg's repo unless msg already names one.
+func (a *App) pushJob(ctx context.Context, priority string, msg map[string]string) error {
+	msg["priority"] = priority
+	var profile sizing.Profile
+	if msg["repo_id"] != "" {
+		var org *string
+		if err := a.db.QueryRow(ctx, `SELECT org, profiled_at IS NOT NULL, COALESCE(size_kb, 0), COALESCE(cardinality(languages), 0)
+			FROM repos WHERE id::text=$1`, msg["repo_id"]).Scan(&org, &profile.Known, &profile.SizeKB, &profile.Languages); err == nil && org != nil && msg["org"] == "" {
+			msg["org"] = *org
+		}
+	} else if msg["source"] == "upload" {
+		// The archive's size stands in for the tree's; its languages are
+		// not known until it is extracted.
+		err := a.db.QueryRow(ctx, `SELECT size_bytes/1024 FROM uploads WHERE job_id::text=$1`, msg["job_id"]).Scan(&profile.SizeKB)
+		profile.Known = err == nil
+	}
+	if msg["org"] == "" {
+		msg["org"] = unassignedOrg
+	}
+	msg["size"] = sizing.Classify(profile, a.sizingThresholds())
+	if _, err := a.db.Exec(ctx, `UPDATE jobs SET size_class=$2 WHERE id::text=$1`, msg["job_id"], msg["size"]); err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(msg)
+	return a.queue.Push(ctx, a.queueKey(msg["size"], priority), msg["org"], payload)
+}
+
+func (a *App) sizingThresholds() sizing.Thresholds {
+	return sizing.Thresholds{
+		SmallMaxMB:        a.cfg.SizingSmallMaxMB,
+		SmallMaxLanguages: a.cfg.SizingSmallMaxLanguages,
+		LargeMinMB:        a.cfg.SizingLargeMinMB,
+		LargeMinLanguages: a.cfg.SizingLargeMinLanguages,
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"argus/api/internal/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgQueue keeps messages in the queue_messages table, for deployments
+// without Redis. Waiting, deferred and in-flight messages share the table:
+// a worker that takes a message sets owner, and a retry waits with
+// available_at in the future. Dead letters are in queue_dead_letters and the
+// worker registry in queue_workers. Org weights are read from the orgs table
+// directly.
+type pgQueue struct {
+	db   *pgxpool.Pool
+	base string   // queue.jobs
+	keys []string // every queue key
+}
+
+// pgQueueChannel is the LISTEN/NOTIFY channel that wakes idle workers; it
+// matches the worker's queueChannel.
+const pgQueueChannel = "argus_queue"
+
+// workerAliveTTL is how recent a worker's heartbeat must be for it to count
+// as alive; it matches the worker's aliveTTL.
+const workerAliveTTL = 30 * time.Second
+
+func (q *pgQueue) Name() string { return config.QueuePostgres }
+
+// Close does nothing: the pool is the API's and main closes it.
+func (q *pgQueue) Close() error { return nil }
+
+func (q *pgQueue) Ping(ctx context.Context) error {
+	return q.db.Ping(ctx)
+}
+
+func (q *pgQueue) Push(ctx context.Context, key, org string, payload []byte) error {
+	_, err := q.db.Exec(ctx, `WITH m AS (INSERT INTO queue_messages (queue, org, payload) VALUES ($1, $2, $3) RETURNING queue)
+		SELECT pg_notify($4, queue) FROM m`, key, org, string(payload), pgQueueChannel)
+	return err
+}
+
+func (q *pgQueue) Depths(ctx context.Context) (map[string]int64, error) {
+	rows, err := q.db.Query(ctx, `SELECT queue, count(*) FROM queue_messages
+		WHERE queue = ANY($1) AND owner IS NULL AND available_at <= now() GROUP BY queue`, q.keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int64{}
+	for rows.Next() {
+		var key string
+		var n int64
+		if err := rows.Scan(&key, &n); err != nil {
+			return nil, err
+		}
+		out[key] = n
+	}
+	return out, rows.Err()
+}
+
+// setCondition selects a set's rows in queue_messages.
+func setCondition(set string) (string, error) {
+	switch set {
+	case queueWaiting:
+		return `owner IS NULL AND available_at <= now()`, nil
+	case queueDelayed:
+		return `owner IS NULL AND available_at > now()`, nil
+	case queueInFlight:
+		return `owner IS NOT NULL`, nil
+	}
+	return "", fmt.Errorf("unknown queue set %q", set)
+}
+
+func (q *pgQueue) Messages(ctx context.Context, set string) ([]string, error) {
+	sql, args := `SELECT entry FROM queue_dead_letters WHERE queue=$1 ORDER BY id DESC`, []any{q.base}
+	if set != queueDead {
+		cond, err := setCondition(set)
+		if err != nil {
+			return nil, err
+		}
+		sql, args = `SELECT payload FROM queue_messages WHERE queue = ANY($1) AND `+cond+` ORDER BY id`, []any{q.keys}
+	}
+	rows, err := q.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		out = append(out, raw)
+	}
+	return out, rows.Err()
+}
+
+func (q *pgQueue) Remove(ctx context.Context, set, raw string) (int64, error) {
+	sql, args := `DELETE FROM queue_dead_letters WHERE queue=$1 AND entry=$2`, []any{q.base, raw}
+	if set != queueDead {
+		cond, err := setCondition(set)
+		if err != nil {
+			return 0, err
+		}
+		sql, args = `DELETE FROM queue_messages WHERE queue = ANY($1) AND payload=$2 AND `+cond, []any{q.keys, raw}
+	}
+	tag, err := q.db.Exec(ctx, sql, args...)
+	return tag.RowsAffected(), err
+}
+
+func (q *pgQueue) RestoreDeadLetter(ctx context.Context, raw string) error {
+	_, err := q.db.Exec(ctx, `INSERT INTO queue_dead_letters (queue, entry) VALUES ($1, $2)`, q.base, raw)
+	return err
+}
+
+func (q *pgQueue) Workers(ctx context.Context) (map[string]*WorkerInfo, error) {
+	rows, err := q.db.Query(ctx, `SELECT name, info, heartbeat_at > now() - make_interval(secs => $2)
+		FROM queue_workers WHERE queue=$1`, q.base, workerAliveTTL.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]*WorkerInfo{}
+	for rows.Next() {
+		var name string
+		var raw []byte
+		var alive bool
+		if err := rows.Scan(&name, &raw, &alive); err != nil {
+			return nil, err
+		}
+		info := &WorkerInfo{Name: name}
+		_ = json.Unmarshal(raw, info)
+		info.Alive = alive
+		out[name] = info
+	}
+	return out, rows.Err()
+}
+
+// SyncOrgWeights and SetOrgWeight have nothing to publish: workers read
+// orgs.queue_weight.
+func (q *pgQueue) SyncOrgWeights(context.Context, map[string]int) error { return nil }
+
+func (q *pgQueue) SetOrgWeight(context.Context, string, int) error { return nil }
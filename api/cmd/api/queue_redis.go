@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"argus/api/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisQueue keeps each queue key as a Redis list with one list per org,
+// <key>:org:<org>, and the active orgs in <key>:orgs. Workers move taken
+// messages to <queue.jobs>:processing:<worker>; retries wait in the
+// <queue.jobs>:delayed sorted set and dead letters in the <queue.jobs>:dead
+// list. Workers register in <queue.jobs>:workers and keep
+// <queue.jobs>:alive:<worker> alive while they run.
+type redisQueue struct {
+	rdb  *redis.Client
+	base string   // queue.jobs
+	keys []string // every queue key
+}
+
+func newRedisQueue(addr, base string, keys []string) *redisQueue {
+	return &redisQueue{rdb: redis.NewClient(&redis.Options{Addr: addr}), base: base, keys: keys}
+}
+
+func (q *redisQueue) Name() string { return config.QueueRedis }
+
+func (q *redisQueue) Close() error { return q.rdb.Close() }
+
+func (q *redisQueue) Ping(ctx context.Context) error {
+	return q.rdb.Ping(ctx).Err()
+}
+
+func (q *redisQueue) weightsKey() string {
+	return q.base + ":org_weights"
+}
+
+func (q *redisQueue) registryKey() string {
+	return q.base + ":workers"
+}
+
+func (q *redisQueue) aliveKey(name string) string {
+	return q.base + ":alive:" + name
+}
+
+func (q *redisQueue) processingPrefix() string {
+	return q.base + ":processing:"
+}
+
+func (q *redisQueue) delayedKey() string {
+	return q.base + ":delayed"
+}
+
+func (q *redisQueue) deadKey() string {
+	return q.base + ":dead"
+}
+
+// enqueueFair pushes ARGV[2] onto the back of org ARGV[1]'s list in the
+// priority queue KEYS[1]. An org with no waiting jobs is activated at the
+// current virtual time, the score of the org a worker served last.
+var enqueueFair = redis.NewScript(`
+redis.call('ZADD', KEYS[1] .. ':orgs', 'NX', tonumber(redis.call('GET', KEYS[1] .. ':vclock') or '0'), ARGV[1])
+return redis.call('LPUSH', KEYS[1] .. ':org:' .. ARGV[1], ARGV[2])
+`)
+
+func (q *redisQueue) Push(ctx context.Context, key, org string, payload []byte) error {
+	return enqueueFair.Run(ctx, q.rdb, []string{key}, org, payload).Err()
+}
+
+// queueLists returns every list holding waiting messages for a queue key:
+// the key itself and the lists of its active orgs.
+func (q *redisQueue) queueLists(ctx context.Context, key string) ([]string, error) {
+	orgs, err := q.rdb.ZRange(ctx, key+":orgs", 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	lists := []string{key}
+	for _, org := range orgs {
+		lists = append(lists, key+":org:"+org)
+	}
+	return lists, nil
+}
+
+// lists returns the lists making up set; the delayed set is not a list.
+func (q *redisQueue) lists(ctx context.Context, set string) ([]string, error) {
+	switch set {
+	case queueWaiting:
+		var out []string
+		for _, k := range q.keys {
+			lists, err := q.queueLists(ctx, k)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, lists...)
+		}
+		return out, nil
+	case queueInFlight:
+		var out []string
+		iter := q.rdb.Scan(ctx, 0, q.processingPrefix()+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			out = append(out, iter.Val())
+		}
+		return out, iter.Err()
+	case queueDead:
+		return []string{q.deadKey()}, nil
+	}
+	return nil, fmt.Errorf("unknown queue set %q", set)
+}
+
+func (q *redisQueue) Depths(ctx context.Context) (map[string]int64, error) {
+	out := map[string]int64{}
+	for _, k := range q.keys {
+		lists, err := q.queueLists(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lists {
+			n, err := q.rdb.LLen(ctx, l).Result()
+			if err != nil {
+				return nil, err
+			}
+			out[k] += n
+		}
+	}
+	return out, nil
+}
+
+func (q *redisQueue) Messages(ctx context.Context, set string) ([]string, error) {
+	if set == queueDelayed {
+		return q.rdb.ZRange(ctx, q.delayedKey(), 0, -1).Result()
+	}
+	lists, err := q.lists(ctx, set)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, l := range lists {
+		msgs, err := q.rdb.LRange(ctx, l, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msgs...)
+	}
+	return out, nil
+}
+
+func (q *redisQueue) Remove(ctx context.Context, set, raw string) (int64, error) {
+	if set == queueDelayed {
+		return q.rdb.ZRem(ctx, q.delayedKey(), raw).Result()
+	}
+	lists, err := q.lists(ctx, set)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, l := range lists {
+		c, err := q.rdb.LRem(ctx, l, 0, raw).Result()
+		if err != nil {
+			return n, err
+		}
+		n += c
+	}
+	return n, nil
+}
+
+func (q *redisQueue) RestoreDeadLetter(ctx context.Context, raw string) error {
+	return q.rdb.LPush(ctx, q.deadKey(), raw).Err()
+}
+
+func (q *redisQueue) Workers(ctx context.Context) (map[string]*WorkerInfo, error) {
+	entries, err := q.rdb.HGetAll(ctx, q.registryKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]*WorkerInfo{}
+	for name, raw := range entries {
+		info := &WorkerInfo{Name: name}
+		_ = json.Unmarshal([]byte(raw), info)
+		n, err := q.rdb.Exists(ctx, q.aliveKey(name)).Result()
+		if err != nil {
+			return nil, err
+		}
+		info.Alive = n > 0
+		out[name] = info
+	}
+	return out, nil
+}
+
+// SyncOrgWeights rewrites <queue.jobs>:org_weights, so a flushed or new
+// Redis picks up the configured weights.
+func (q *redisQueue) SyncOrgWeights(ctx context.Context, weights map[string]int) error {
+	fields := make(map[string]any, len(weights))
+	for org, w := range weights {
+		fields[org] = w
+	}
+	_, err := q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, q.weightsKey())
+		if len(fields) > 0 {
+			p.HSet(ctx, q.weightsKey(), fields)
+		}
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) SetOrgWeight(ctx context.Context, org string, weight int) error {
+	if weight == 1 {
+		return q.rdb.HDel(ctx, q.weightsKey(), org).Err()
+	}
+	return q.rdb.HSet(ctx, q.weightsKey(), org, weight).Err()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"argus/api/internal/sizing"
+)
+
+// queueWorker is a worker's entry in the queue status.
+type queueWorker struct {
+	Name                string    `json:"name"`
+	Alive               bool      `json:"alive"`
+	HeartbeatAt         time.Time `json:"heartbeat_at"`
+	HeartbeatAgeSeconds float64   `json:"heartbeat_age_seconds"`
+	Concurrency         int       `json:"concurrency"`
+	Running             int       `json:"running"`
+}
+
+// queueStatus is the snapshot an autoscaler polls. Waiting counts are split
+// by priority and by size class, so each worker pool can scale on the
+// classes it takes; capacity and busy add up the live workers' slots.
+type queueStatus struct {
+	Backend     string           `json:"backend"`
+	Queue       string           `json:"queue"`
+	Waiting     int64            `json:"waiting"`
+	Priorities  map[string]int64 `json:"priorities"`
+	SizeClasses map[string]int64 `json:"size_classes"`
+	Queues      map[string]int64 `json:"queues"`
+	Delayed     int              `json:"delayed"`
+	InFlight    int              `json:"in_flight"`
+	Running     int64            `json:"running_jobs"`
+	Capacity    int              `json:"capacity"`
+	Busy        int              `json:"busy"`
+	Workers     []queueWorker    `json:"workers"`
+}
+
+// getQueueStatus serves GET /api/admin/queue.
+func (a *App) getQueueStatus(w http.ResponseWriter, r *http.Request) {
+	st, err := a.queueStatus(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (a *App) queueStatus(ctx context.Context) (queueStatus, error) {
+	st := queueStatus{
+		Backend:     a.queue.Name(),
+		Queue:       a.cfg.JobQueue,
+		Priorities:  map[string]int64{},
+		SizeClasses: map[string]int64{},
+		Workers:     []queueWorker{},
+	}
+	depths, err := a.queue.Depths(ctx)
+	if err != nil {
+		return st, err
+	}
+	st.Queues = map[string]int64{}
+	for _, p := range priorities {
+		for _, c := range sizing.Classes {
+			n := depths[a.queueKey(c, p)]
+			st.Queues[a.queueKey(c, p)] = n
+			st.Priorities[p] += n
+			st.SizeClasses[c] += n
+			st.Waiting += n
+		}
+	}
+	delayed, err := a.queue.Messages(ctx, queueDelayed)
+	if err != nil {
+		return st, err
+	}
+	st.Delayed = len(delayed)
+	inFlight, err := a.queue.Messages(ctx, queueInFlight)
+	if err != nil {
+		return st, err
+	}
+	st.InFlight = len(inFlight)
+	if err := a.db.QueryRow(ctx, `SELECT count(*) FROM jobs WHERE status='running'`).Scan(&st.Running); err != nil {
+		return st, err
+	}
+
+	workers, err := a.workerStatus(ctx)
+	if err != nil {
+		return st, err
+	}
+	now := time.Now()
+	for _, info := range workers {
+		qw := queueWorker{
+			Name:                info.Name,
+			Alive:               info.Alive,
+			HeartbeatAt:         info.HeartbeatAt,
+			HeartbeatAgeSeconds: now.Sub(info.HeartbeatAt).Seconds(),
+			Concurrency:         info.Concurrency,
+			Running:             len(info.Jobs),
+		}
+		if qw.Alive {
+			st.Capacity += qw.Concurrency
+			st.Busy += qw.Running
+		}
+		st.Workers = append(st.Workers, qw)
+	}
+	return st, nil
+}
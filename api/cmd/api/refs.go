@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"argus/internal/forge"
+)
+
+// validateRemoteRef checks that ref names an existing branch or tag on the
+// remote; field names it in errors. Private repositories are checked with
+// the forge's credential: on github.url a GitHub App installation token for
+// installationID, or for the repo's own installation when installationID is
+// 0; on gitlab.url gitlab.token; and on Azure Repos azure_devops.token.
+// Offline mode skips the installation token and checks GitHub anonymously.
+func (a *App) validateRemoteRef(ctx context.Context, field, repoURL, ref string, installationID int64) error {
+	if !isValidRefName(ref) {
+		return fmt.Errorf("%s is not a valid ref name", field)
+	}
+
+	remote := repoURL
+	// Installation tokens are only valid for github.url; never hand them to
+	// another allowed host.
+	if host := forge.GitHub(a.cfg.GitHubURL); !a.cfg.Offline && host.Owns(repoURL) {
+		if gh, err := a.githubApp(ctx, repoURL, installationID); err == nil {
+			if token, err := gh.InstallationToken(ctx); err == nil {
+				remote = strings.Replace(repoURL, "https://", "https://x-access-token:"+token+"@", 1)
+			}
+		}
+	}
+	if a.cfg.GitLabToken != "" && forge.GitLab(a.cfg.GitLabURL).Owns(repoURL) {
+		remote = strings.Replace(repoURL, "https://", "https://oauth2:"+a.cfg.GitLabToken+"@", 1)
+	}
+	if _, ok := forge.ParseAzureRepo(repoURL); ok && a.cfg.AzureDevOpsToken != "" {
+		remote = strings.Replace(repoURL, "https://", "https://argus:"+a.cfg.AzureDevOpsToken+"@", 1)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/")
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", remote, "refs/heads/"+ref, "refs/tags/"+ref)
+	cmd.Env = append(a.cfg.GitHubProxySetting().Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 2 {
+			return fmt.Errorf("%s %q does not exist on the remote", field, ref)
+		}
+		return fmt.Errorf("could not verify %s against the remote", field)
+	}
+	return nil
+}
+
+func isValidRefName(ref string) bool {
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.HasPrefix(ref, "/") || strings.HasSuffix(ref, "/") {
+		return false
+	}
+	if strings.Contains(ref, "..") || strings.Contains(ref, "@{") || strings.HasSuffix(ref, ".lock") {
+		return false
+	}
+	for _, c := range ref {
+		if c <= ' ' || c == 0x7f || strings.ContainsRune("~^:?*[\\", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"argus/api/internal/patch"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RepoSettings tune how a repo is scanned without redeploying workers. Zero
+// values leave the worker's own config in charge.
+type RepoSettings struct {
+	// Scanners limits the repo's scans to these scanners; a scan request's
+	// own selection is narrowed further by it.
+	Scanners []string `json:"scanners"`
+	// ScannerEnabled turns scanners on or off for the repo, keyed by
+	// scanner or "*" for the rest. An entry wins over Scanners; a scanner
+	// the worker has not enabled stays off.
+	ScannerEnabled map[string]bool `json:"scanner_enabled"`
+	// SemgrepConfig replaces scanners.semgrep.config: detect, auto or a
+	// registry ruleset such as p/ci.
+	SemgrepConfig string `json:"semgrep_config"`
+	// Include limits a scan to matching paths, for a team that owns one
+	// part of a monorepo.
+	Include []string `json:"include"`
+	// Exclude drops findings in matching paths.
+	Exclude []string `json:"exclude"`
+	// MinSeverity drops findings below it.
+	MinSeverity string `json:"min_severity"`
+	// ScannerTimeouts bounds each scanner's run, keyed by scanner or "*"
+	// for the rest, replacing the worker's scanners.timeouts.
+	ScannerTimeouts map[string]string `json:"scanner_timeouts"`
+	// ScannerOnFailure says whether a scanner's failure fails the job
+	// ("fail") or is recorded and passed over ("continue"), keyed like
+	// ScannerTimeouts.
+	ScannerOnFailure map[string]string `json:"scanner_on_failure"`
+	// Clone narrows what workers fetch, for repos near the clone size cap.
+	Clone CloneSettings `json:"clone"`
+}
+
+// CloneSettings choose how a repo is cloned.
+type CloneSettings struct {
+	// Filter is the partial-clone filter: blob:none (the default) or
+	// tree:0, which also defers trees.
+	Filter string `json:"filter"`
+	// Sparse checks out only what the include and exclude globs select,
+	// plus SparsePaths, such as root lockfiles an included service needs.
+	Sparse      bool     `json:"sparse"`
+	SparsePaths []string `json:"sparse_paths"`
+	// SingleBranch keeps a full clone to the scanned ref's history.
+	SingleBranch bool `json:"single_branch"`
+}
+
+type updateRepoSettingsReq struct {
+	Scanners       *[]string        `json:"scanners"`
+	ScannerEnabled *map[string]bool `json:"scanner_enabled"`
+	SemgrepConfig  *string          `json:"semgrep_config"`
+	Include        *[]string        `json:"include"`
+	Exclude        *[]string        `json:"exclude"`
+	MinSeverity    *string          `json:"min_severity"`
+
+	ScannerTimeouts  *map[string]string `json:"scanner_timeouts"`
+	ScannerOnFailure *map[string]string `json:"scanner_on_failure"`
+	// Clone replaces the clone settings as a whole.
+	Clone *CloneSettings `json:"clone"`
+}
+
+const maxPathGlobs = 50
+
+// semgrepRuleset matches registry rulesets (p/..., r/...); local paths are
+// not accepted because they would name files on the worker.
+var semgrepRuleset = regexp.MustCompile(`^[pr]/[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*$`)
+
+func (a *App) loadRepoSettings(r *http.Request, id string) (RepoSettings, error) {
+	var s RepoSettings
+	var raw []byte
+	if err := a.db.QueryRow(r.Context(), `SELECT scan_settings FROM repos WHERE id=$1`, id).Scan(&raw); err != nil {
+		return s, err
+	}
+	_ = json.Unmarshal(raw, &s)
+	if s.Scanners == nil {
+		s.Scanners = []string{}
+	}
+	if s.ScannerEnabled == nil {
+		s.ScannerEnabled = map[string]bool{}
+	}
+	if s.Include == nil {
+		s.Include = []string{}
+	}
+	if s.Exclude == nil {
+		s.Exclude = []string{}
+	}
+	if s.ScannerTimeouts == nil {
+		s.ScannerTimeouts = map[string]string{}
+	}
+	if s.ScannerOnFailure == nil {
+		s.ScannerOnFailure = map[string]string{}
+	}
+	if s.Clone.SparsePaths == nil {
+		s.Clone.SparsePaths = []string{}
+	}
+	return s, nil
+}
+
+func (a *App) getRepoSettings(w http.ResponseWriter, r *http.Request) {
+	s, err := a.loadRepoSettings(r, chi.URLParam(r, "id"))
+	if err != nil {
+		notFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// updateRepoSettings changes the fields present in the body; an empty value
+// clears a setting.
+func (a *App) updateRepoSettings(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req updateRepoSettingsReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	s, err := a.loadRepoSettings(r, id)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	if req.Scanners != nil {
+		if s.Scanners, err = normalizeScanners(*req.Scanners); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if req.ScannerEnabled != nil {
+		if s.ScannerEnabled, err = normalizeScannerEnabled(*req.ScannerEnabled); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if req.SemgrepConfig != nil {
+		c := strings.TrimSpace(*req.SemgrepConfig)
+		if c != "" && c != "detect" && c != "auto" && !semgrepRuleset.MatchString(c) {
+			badRequest(w, "semgrep_config must be detect, auto or a registry ruleset such as p/ci")
+			return
+		}
+		s.SemgrepConfig = c
+	}
+	if req.Include != nil {
+		if s.Include, err = normalizeGlobs("include", *req.Include); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if req.Exclude != nil {
+		if s.Exclude, err = normalizeGlobs("exclude", *req.Exclude); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if req.MinSeverity != nil {
+		sev := strings.ToUpper(strings.TrimSpace(*req.MinSeverity))
+		if sev != "" && !patch.ValidSeverity(sev) {
+			badRequest(w, "min_severity must be one of CRITICAL, HIGH, MEDIUM, LOW")
+			return
+		}
+		s.MinSeverity = sev
+	}
+	if req.ScannerTimeouts != nil {
+		if s.ScannerTimeouts, err = normalizePerScanner("scanner_timeouts", *req.ScannerTimeouts, validScannerTimeout); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if req.ScannerOnFailure != nil {
+		if s.ScannerOnFailure, err = normalizePerScanner("scanner_on_failure", *req.ScannerOnFailure, validFailurePolicy); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if req.Clone != nil {
+		c := *req.Clone
+		if c.Filter = strings.TrimSpace(c.Filter); c.Filter != "" && c.Filter != "blob:none" && c.Filter != "tree:0" {
+			badRequest(w, "clone.filter must be blob:none or tree:0")
+			return
+		}
+		if c.SparsePaths, err = normalizeGlobs("clone.sparse_paths", c.SparsePaths); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		for _, g := range c.SparsePaths {
+			if strings.HasPrefix(g, "!") {
+				badRequest(w, "clone.sparse_paths must not be negated; use exclude")
+				return
+			}
+		}
+		s.Clone = c
+	}
+	if s.Scanners == nil {
+		s.Scanners = []string{}
+	}
+
+	raw, _ := json.Marshal(s)
+	if _, err := a.db.Exec(r.Context(), `UPDATE repos SET scan_settings=$2, scan_settings_updated_at=now() WHERE id=$1`, id, raw); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// normalizeGlobs trims and de-duplicates include or exclude globs and
+// rejects malformed ones. Globs are passed to scanners as flag values, so
+// they must not start with a dash, and to git's sparse checkout one per
+// line.
+func normalizeGlobs(kind string, in []string) ([]string, error) {
+	if len(in) > maxPathGlobs {
+		return nil, fmt.Errorf("at most %d %s globs are allowed", maxPathGlobs, kind)
+	}
+	out := []string{}
+	for _, g := range in {
+		g = strings.TrimPrefix(strings.TrimSpace(g), "./")
+		if g == "" {
+			return nil, fmt.Errorf("%s globs must not be empty", kind)
+		}
+		if _, err := path.Match(strings.TrimSuffix(g, "/**"), ""); err != nil || strings.HasPrefix(g, "-") || strings.ContainsAny(g, "\r\n") {
+			return nil, fmt.Errorf("invalid %s glob %q", kind, g)
+		}
+		if !contains(out, g) {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+// maxScannerTimeout keeps a repo's scanner timeouts sane; the worker's
+// limits.scan_timeout_min still bounds the job as a whole.
+const maxScannerTimeout = 24 * time.Hour
+
+// normalizePerScanner checks a map keyed by scanner name or "*".
+func normalizePerScanner(field string, in map[string]string, valid func(string) bool) (map[string]string, error) {
+	out := map[string]string{}
+	for name, v := range in {
+		name, v = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(v)
+		if name != "*" && !contains(scannerNames, name) {
+			return nil, fmt.Errorf("%s: unknown scanner %q (want * or one of %s)", field, name, strings.Join(scannerNames, ", "))
+		}
+		if !valid(v) {
+			return nil, fmt.Errorf("%s: invalid value %q for %s", field, v, name)
+		}
+		out[name] = v
+	}
+	return out, nil
+}
+
+// normalizeScannerEnabled checks the per-repo scanner toggles, keyed like
+// normalizePerScanner.
+func normalizeScannerEnabled(in map[string]bool) (map[string]bool, error) {
+	out := map[string]bool{}
+	for name, on := range in {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "*" && !contains(scannerNames, name) {
+			return nil, fmt.Errorf("scanner_enabled: unknown scanner %q (want * or one of %s)", name, strings.Join(scannerNames, ", "))
+		}
+		out[name] = on
+	}
+	return out, nil
+}
+
+func validScannerTimeout(v string) bool {
+	d, err := time.ParseDuration(v)
+	return err == nil && d > 0 && d <= maxScannerTimeout
+}
+
+func validFailurePolicy(v string) bool {
+	return v == "continue" || v == "fail"
+}
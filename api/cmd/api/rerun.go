@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// rerunReq overrides parameters of the job being rerun; anything left out is
+// copied from it.
+type rerunReq struct {
+	// Ref scans another branch or tag; "" goes back to the repo's
+	// default_ref. Either drops the original's commit_sha,
+	// deployment_url and base_sha.
+	Ref *string `json:"ref"`
+	// Scanners selects a subset; [] runs every enabled scanner.
+	Scanners *[]string `json:"scanners"`
+	Priority string    `json:"priority"`
+}
+
+// rerunJob queues a new scan of a job's repo with the job's parameters and
+// any overrides, linked to it through rerun_of. A rerun is forced: it scans
+// even when the commit has not moved since the last successful scan.
+func (a *App) rerunJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req rerunReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(w, "invalid json")
+		return
+	}
+
+	ctx := r.Context()
+	var repoID, ref, targetSHA, deploymentURL, baseSHA *string
+	var repoURL, source string
+	opts := scanOptions{RerunOf: id, Force: true}
+	err := a.db.QueryRow(ctx, `SELECT j.repo_id::text, COALESCE(rp.url,''), j.source, j.scanners, j.priority, j.ref, j.target_sha, j.deployment_url, j.base_sha, COALESCE(j.pull_request, 0)
+		FROM jobs j LEFT JOIN repos rp ON rp.id = j.repo_id WHERE j.id=$1`, id).Scan(&repoID, &repoURL, &source, &opts.Scanners, &opts.Priority, &ref, &targetSHA, &deploymentURL, &baseSHA, &opts.PullRequest)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	if source == sourceImage {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": "registry credentials are deleted when an image scan finishes; post the image again instead"})
+		return
+	}
+	if repoID == nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": "uploaded archives are not kept after a scan; upload it again instead"})
+		return
+	}
+	if ref != nil {
+		opts.Ref = *ref
+	}
+	if targetSHA != nil {
+		opts.CommitSHA = *targetSHA
+	}
+	if deploymentURL != nil {
+		opts.DeploymentURL = *deploymentURL
+	}
+	if baseSHA != nil {
+		opts.BaseSHA = *baseSHA
+	}
+
+	if req.Scanners != nil {
+		if opts.Scanners, err = normalizeScanners(*req.Scanners); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if opts.Priority, err = normalizePriority(req.Priority, opts.Priority); err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if req.Ref != nil {
+		// The pinned commit, its deployment and the incremental base belong
+		// to the old ref.
+		opts.Ref, opts.CommitSHA, opts.DeploymentURL, opts.BaseSHA = strings.TrimSpace(*req.Ref), "", "", ""
+		if opts.Ref != "" {
+			if err := a.validateRemoteRef(ctx, "ref", repoURL, opts.Ref, 0); err != nil {
+				badRequest(w, err.Error())
+				return
+			}
+		}
+	}
+
+	if contains(opts.Scanners, "nuclei") && opts.DeploymentURL == "" {
+		badRequest(w, "nuclei needs a deployment_url")
+		return
+	}
+
+	jobID, err := a.enqueueScan(ctx, *repoID, opts)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": jobID, "rerun_of": id})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// artifactSBOM is the kind of the SBOM workers store for each scan. Only
+// CycloneDX JSON is produced today; format leaves room for SPDX.
+const artifactSBOM = "sbom"
+
+var sbomFormats = map[string]string{"cyclonedx": "application/vnd.cyclonedx+json"}
+
+// repoSBOM serves the SBOM of the repo's latest successful scan that
+// produced one. The job it came from is in the X-Argus-Job-ID header.
+func (a *App) repoSBOM(w http.ResponseWriter, r *http.Request) {
+	repoID := chi.URLParam(r, "id")
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "cyclonedx"
+	}
+	contentType, ok := sbomFormats[format]
+	if !ok {
+		badRequest(w, "format must be cyclonedx")
+		return
+	}
+	var exists bool
+	if err := a.db.QueryRow(r.Context(), `SELECT EXISTS (SELECT 1 FROM repos WHERE id=$1)`, repoID).Scan(&exists); err != nil || !exists {
+		notFound(w)
+		return
+	}
+
+	var jobID, sum string
+	var data []byte
+	err := a.db.QueryRow(r.Context(), `SELECT j.id::text, a.sha256, a.data FROM job_artifacts a JOIN jobs j ON j.id = a.job_id
+		WHERE j.repo_id=$1 AND j.status='succeeded' AND a.kind=$2 AND a.content_type=$3 ORDER BY j.finished_at DESC LIMIT 1`,
+		repoID, artifactSBOM, contentType).Scan(&jobID, &sum, &data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "no scan of this repo has produced an SBOM yet"})
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.Header().Set("X-Argus-Job-ID", jobID)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
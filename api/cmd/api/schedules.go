@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"argus/api/internal/schedule"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const schedulerInterval = 30 * time.Second
+
+// maxScheduleRefs caps the refs one schedule scans per run.
+const maxScheduleRefs = 20
+
+type Schedule struct {
+	ID      string `json:"id"`
+	RepoID  string `json:"repo_id"`
+	Cron    string `json:"cron"`
+	Enabled bool   `json:"enabled"`
+	// Refs are scanned on every run, one job each; empty scans the repo's
+	// default_ref.
+	Refs         []string   `json:"refs"`
+	Timezone     string     `json:"timezone"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`
+	NextRunLocal string     `json:"next_run_local,omitempty"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastJobID    *string    `json:"last_job_id,omitempty"`
+	// LastJobIDs are the jobs of the last run, one per ref enqueued.
+	LastJobIDs []string  `json:"last_job_ids"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type createScheduleReq struct {
+	Cron    string   `json:"cron"`
+	Enabled *bool    `json:"enabled"`
+	Refs    []string `json:"refs"`
+}
+
+const scheduleColumns = `s.id::text, s.repo_id::text, s.cron, s.enabled, s.refs, COALESCE(o.timezone,'UTC'), s.next_run_at, s.last_run_at, s.last_job_id::text, s.last_job_ids::text[], s.created_at
+	FROM schedules s JOIN repos rp ON rp.id = s.repo_id LEFT JOIN orgs o ON o.name = rp.org`
+
+func scanSchedule(row pgx.Row) (Schedule, error) {
+	var s Schedule
+	if err := row.Scan(&s.ID, &s.RepoID, &s.Cron, &s.Enabled, &s.Refs, &s.Timezone, &s.NextRunAt, &s.LastRunAt, &s.LastJobID, &s.LastJobIDs, &s.CreatedAt); err != nil {
+		return s, err
+	}
+	if loc, err := time.LoadLocation(s.Timezone); err == nil && s.NextRunAt != nil {
+		s.NextRunLocal = localLabel(*s.NextRunAt, loc)
+	}
+	return s, nil
+}
+
+func (a *App) listSchedules(w http.ResponseWriter, r *http.Request) {
+	repoID := chi.URLParam(r, "id")
+	rows, err := a.db.Query(r.Context(), `SELECT `+scheduleColumns+` WHERE s.repo_id=$1 ORDER BY s.created_at`, repoID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := make([]Schedule, 0)
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		out = append(out, s)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// scheduleRefs trims and de-duplicates refs and checks that each exists on
+// the remote.
+func (a *App) scheduleRefs(ctx context.Context, repoURL string, refs []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			return nil, errors.New("refs must not contain empty refs")
+		}
+		if !seen[ref] {
+			seen[ref] = true
+			out = append(out, ref)
+		}
+	}
+	if len(out) > maxScheduleRefs {
+		return nil, fmt.Errorf("refs allows at most %d refs", maxScheduleRefs)
+	}
+	for _, ref := range out {
+		if err := a.validateRemoteRef(ctx, "refs", repoURL, ref, 0); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (a *App) createSchedule(w http.ResponseWriter, r *http.Request) {
+	repoID := chi.URLParam(r, "id")
+	var req createScheduleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	spec, err := schedule.Parse(req.Cron)
+	if err != nil {
+		badRequest(w, "invalid cron: "+err.Error())
+		return
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	var org, repoURL string
+	if err := a.db.QueryRow(r.Context(), `SELECT COALESCE(org,''), url FROM repos WHERE id=$1`, repoID).Scan(&org, &repoURL); err != nil {
+		notFound(w)
+		return
+	}
+	refs, err := a.scheduleRefs(r.Context(), repoURL, req.Refs)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	next := spec.Next(time.Now(), a.orgLocation(r.Context(), org))
+	if next.IsZero() {
+		badRequest(w, "invalid cron: it never matches a date")
+		return
+	}
+
+	var id string
+	if err := a.db.QueryRow(r.Context(), `INSERT INTO schedules (repo_id, cron, enabled, refs, next_run_at) VALUES ($1,$2,$3,$4,$5) RETURNING id::text`,
+		repoID, strings.TrimSpace(req.Cron), enabled, refs, next).Scan(&id); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"id": id, "next_run_at": next, "refs": refs})
+}
+
+// updateSchedule changes a schedule's cron, enabled flag or refs, as release
+// lines come and go. A new cron recomputes next_run_at.
+func (a *App) updateSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req struct {
+		Cron    *string   `json:"cron"`
+		Enabled *bool     `json:"enabled"`
+		Refs    *[]string `json:"refs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	var org, repoURL string
+	err := a.db.QueryRow(r.Context(), `SELECT COALESCE(rp.org,''), rp.url FROM schedules s JOIN repos rp ON rp.id = s.repo_id WHERE s.id::text=$1`, id).Scan(&org, &repoURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	var cron *string
+	var next *time.Time
+	if req.Cron != nil {
+		spec, err := schedule.Parse(*req.Cron)
+		if err != nil {
+			badRequest(w, "invalid cron: "+err.Error())
+			return
+		}
+		c, n := strings.TrimSpace(*req.Cron), spec.Next(time.Now(), a.orgLocation(r.Context(), org))
+		if n.IsZero() {
+			badRequest(w, "invalid cron: it never matches a date")
+			return
+		}
+		cron, next = &c, &n
+	}
+	var refs []string
+	if req.Refs != nil {
+		if refs, err = a.scheduleRefs(r.Context(), repoURL, *req.Refs); err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+	}
+	if _, err := a.db.Exec(r.Context(), `UPDATE schedules SET cron=COALESCE($2, cron), next_run_at=COALESCE($3, next_run_at),
+		enabled=COALESCE($4, enabled), refs=COALESCE($5, refs) WHERE id=$1`, id, cron, next, req.Enabled, refs); err != nil {
+		serverError(w, err)
+		return
+	}
+	s, err := scanSchedule(a.db.QueryRow(r.Context(), `SELECT `+scheduleColumns+` WHERE s.id=$1`, id))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (a *App) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	tag, err := a.db.Exec(r.Context(), `DELETE FROM schedules WHERE id=$1`, chi.URLParam(r, "id"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runScheduler enqueues scans for due schedules until ctx is cancelled. Rows
+// are claimed with SKIP LOCKED so several API replicas can run it safely.
+func (a *App) runScheduler(ctx context.Context) {
+	t := time.NewTicker(schedulerInterval)
+	defer t.Stop()
+	for {
+		if err := a.runDueSchedules(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "scheduler run failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// runDueSchedules runs the schedules that are due, each on its own so one
+// that fails does not hold up the rest.
+func (a *App) runDueSchedules(ctx context.Context) error {
+	rows, err := a.db.Query(ctx, `SELECT id::text FROM schedules WHERE enabled AND next_run_at <= now() ORDER BY next_run_at LIMIT 50`)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range ids {
+		if err := a.runSchedule(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runSchedule runs schedule id if it is still due and no other replica
+// holds it. Its next_run_at moves on in a transaction of its own that
+// commits before any job is enqueued, so a failure later loses the run
+// rather than enqueueing it twice. A schedule whose cron no longer parses
+// or never matches again is disabled.
+func (a *App) runSchedule(ctx context.Context, id string) error {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var repoID, cron, tz string
+	var refs []string
+	err = tx.QueryRow(ctx, `SELECT s.repo_id::text, s.cron, s.refs, COALESCE(o.timezone,'UTC')
+		FROM schedules s JOIN repos rp ON rp.id = s.repo_id LEFT JOIN orgs o ON o.name = rp.org
+		WHERE s.id=$1 AND s.enabled AND s.next_run_at <= now()
+		FOR UPDATE OF s SKIP LOCKED`, id).Scan(&repoID, &cron, &refs, &tz)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now()
+	spec, err := schedule.Parse(cron)
+	var next time.Time
+	if err == nil {
+		if next = spec.Next(now, loc); next.IsZero() {
+			err = errors.New("cron never matches a date")
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "disabling schedule with invalid cron", "schedule_id", id, "err", err)
+		if _, err := tx.Exec(ctx, `UPDATE schedules SET enabled=false WHERE id=$1`, id); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE schedules SET last_run_at=$2, next_run_at=$3 WHERE id=$1`, id, now, next); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	jobIDs, err := a.enqueueScheduledRefs(ctx, id, repoID, refs)
+	var lastJobID *string
+	if len(jobIDs) > 0 {
+		lastJobID = &jobIDs[0]
+	}
+	if _, uerr := a.db.Exec(ctx, `UPDATE schedules SET last_job_id=COALESCE($2::uuid, last_job_id), last_job_ids=$3::uuid[] WHERE id=$1`,
+		id, lastJobID, jobIDs); uerr != nil {
+		return errors.Join(err, uerr)
+	}
+	return err
+}
+
+// enqueueScheduledRefs enqueues one job per ref, or one for the default ref
+// when refs is empty. A ref that still has a queued or running job is
+// skipped rather than stacked behind it, so a slow release-line scan cannot
+// pile up runs. The jobs share the repo's fingerprints, so a finding on
+// several refs keeps one public_id. On error, the jobs enqueued so far are
+// returned with it.
+func (a *App) enqueueScheduledRefs(ctx context.Context, scheduleID, repoID string, refs []string) ([]string, error) {
+	if len(refs) == 0 {
+		refs = []string{""}
+	}
+	jobIDs := []string{}
+	for _, ref := range refs {
+		var busy bool
+		if err := a.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM jobs WHERE repo_id=$1 AND ref IS NOT DISTINCT FROM $2 AND status IN ('queued','running'))`,
+			repoID, nullIfEmpty(ref)).Scan(&busy); err != nil {
+			return jobIDs, err
+		}
+		if busy {
+			slog.InfoContext(ctx, "scheduled scan skipped: ref already has a scan in flight", "schedule_id", scheduleID, "repo_id", repoID, "ref", ref)
+			continue
+		}
+		jobID, err := a.enqueueScan(ctx, repoID, scanOptions{Priority: priorityLow, Ref: ref})
+		if err != nil {
+			return jobIDs, err
+		}
+		slog.InfoContext(withJobID(ctx, jobID), "scheduled scan enqueued", "schedule_id", scheduleID, "repo_id", repoID, "ref", ref)
+		jobIDs = append(jobIDs, jobID)
+	}
+	return jobIDs, nil
+}
+
+// rescheduleOrg recomputes next_run_at for every schedule in org after its
+// time zone changes.
+func (a *App) rescheduleOrg(ctx context.Context, org string) error {
+	loc := a.orgLocation(ctx, org)
+	rows, err := a.db.Query(ctx, `SELECT s.id::text, s.cron FROM schedules s JOIN repos rp ON rp.id = s.repo_id WHERE rp.org=$1`, org)
+	if err != nil {
+		return err
+	}
+	type item struct{ id, cron string }
+	var items []item
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.id, &it.cron); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, it := range items {
+		spec, err := schedule.Parse(it.cron)
+		if err != nil {
+			continue
+		}
+		next := spec.Next(now, loc)
+		if next.IsZero() {
+			continue
+		}
+		if _, err := a.db.Exec(ctx, `UPDATE schedules SET next_run_at=$2 WHERE id=$1`, it.id, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
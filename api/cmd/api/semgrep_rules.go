@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Org Semgrep rules are in-house rule files that run on every scan of the
+// org's repos. semgrep_rules_mode decides whether they run alongside the
+// selected packs ("add") or instead of them ("replace").
+const (
+	semgrepRulesAdd     = "add"
+	semgrepRulesReplace = "replace"
+
+	maxSemgrepRuleBytes = 256 * 1024
+	maxSemgrepRuleFiles = 50
+)
+
+// semgrepRuleName keeps names usable as file names on the worker.
+var semgrepRuleName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type SemgrepRule struct {
+	Name      string    `json:"name"`
+	Enabled   bool      `json:"enabled"`
+	SizeBytes int       `json:"size_bytes"`
+	SHA256    string    `json:"sha256"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// withContent fills in the size and digest of the rule file.
+func (rule SemgrepRule) withContent(content string) SemgrepRule {
+	sum := sha256.Sum256([]byte(content))
+	rule.SizeBytes, rule.SHA256 = len(content), hex.EncodeToString(sum[:])
+	return rule
+}
+
+// validateSemgrepRules does the checks that need no YAML parser; semgrep
+// reports anything else when the rules first run.
+func validateSemgrepRules(content []byte) error {
+	if len(content) > maxSemgrepRuleBytes {
+		return fmt.Errorf("rule file exceeds %d KB", maxSemgrepRuleBytes/1024)
+	}
+	if !utf8.Valid(content) {
+		return errors.New("rule file must be UTF-8 YAML")
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "rules:") {
+			return nil
+		}
+	}
+	return errors.New("rule file must have a top-level rules: key")
+}
+
+func (a *App) listSemgrepRules(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	var mode string
+	if err := a.db.QueryRow(r.Context(), `SELECT semgrep_rules_mode FROM orgs WHERE name=$1`, org).Scan(&mode); err != nil {
+		notFound(w)
+		return
+	}
+	rows, err := a.db.Query(r.Context(), `SELECT name, enabled, content, updated_at FROM semgrep_rules WHERE org=$1 ORDER BY name`, org)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	rules := []SemgrepRule{}
+	for rows.Next() {
+		var rule SemgrepRule
+		var content string
+		if err := rows.Scan(&rule.Name, &rule.Enabled, &content, &rule.UpdatedAt); err != nil {
+			serverError(w, err)
+			return
+		}
+		rules = append(rules, rule.withContent(content))
+	}
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"mode": mode, "rules": rules})
+}
+
+// getSemgrepRule returns the rule file itself.
+func (a *App) getSemgrepRule(w http.ResponseWriter, r *http.Request) {
+	var content string
+	err := a.db.QueryRow(r.Context(), `SELECT content FROM semgrep_rules WHERE org=$1 AND name=$2`,
+		strings.ToLower(chi.URLParam(r, "org")), chi.URLParam(r, "name")).Scan(&content)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = io.WriteString(w, content)
+}
+
+// putSemgrepRule creates or replaces a rule file from the raw YAML request
+// body. Replacing a file keeps its enabled flag.
+func (a *App) putSemgrepRule(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	name := chi.URLParam(r, "name")
+	if !semgrepRuleName.MatchString(name) {
+		badRequest(w, "name must be lowercase letters, digits, - or _ (at most 63)")
+		return
+	}
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSemgrepRuleBytes+1))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": fmt.Sprintf("rule file exceeds %d KB", maxSemgrepRuleBytes/1024)})
+			return
+		}
+		badRequest(w, "could not read body")
+		return
+	}
+	if err := validateSemgrepRules(content); err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	tx, err := a.db.Begin(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+	// Lock the org row so concurrent uploads cannot both pass the limit.
+	if err := tx.QueryRow(r.Context(), `SELECT name FROM orgs WHERE name=$1 FOR UPDATE`, org).Scan(&org); err != nil {
+		notFound(w)
+		return
+	}
+	var others int
+	if err := tx.QueryRow(r.Context(), `SELECT count(*) FROM semgrep_rules WHERE org=$1 AND name<>$2`, org, name).Scan(&others); err != nil {
+		serverError(w, err)
+		return
+	}
+	if others >= maxSemgrepRuleFiles {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": fmt.Sprintf("an org can have at most %d rule files", maxSemgrepRuleFiles)})
+		return
+	}
+	if _, err := tx.Exec(r.Context(), `INSERT INTO semgrep_rules (org, name, content) VALUES ($1,$2,$3)
+		ON CONFLICT (org, name) DO UPDATE SET content=EXCLUDED.content, updated_at=now()`, org, name, string(content)); err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		serverError(w, err)
+		return
+	}
+	a.writeSemgrepRule(w, r, org, name)
+}
+
+func (a *App) updateSemgrepRule(w http.ResponseWriter, r *http.Request) {
+	org := strings.ToLower(chi.URLParam(r, "org"))
+	name := chi.URLParam(r, "name")
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		badRequest(w, "enabled is required")
+		return
+	}
+	tag, err := a.db.Exec(r.Context(), `UPDATE semgrep_rules SET enabled=$3, updated_at=now() WHERE org=$1 AND name=$2`, org, name, *req.Enabled)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	a.writeSemgrepRule(w, r, org, name)
+}
+
+func (a *App) deleteSemgrepRule(w http.ResponseWriter, r *http.Request) {
+	tag, err := a.db.Exec(r.Context(), `DELETE FROM semgrep_rules WHERE org=$1 AND name=$2`,
+		strings.ToLower(chi.URLParam(r, "org")), chi.URLParam(r, "name"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) writeSemgrepRule(w http.ResponseWriter, r *http.Request, org, name string) {
+	var rule SemgrepRule
+	var content string
+	err := a.db.QueryRow(r.Context(), `SELECT name, enabled, content, updated_at FROM semgrep_rules WHERE org=$1 AND name=$2`, org, name).
+		Scan(&rule.Name, &rule.Enabled, &content, &rule.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule.withContent(content))
+}
+
+// orgRuleFile is an enabled org Semgrep rule file as workers receive it.
+type orgRuleFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+func (a *App) enabledSemgrepRules(ctx context.Context, org string) ([]orgRuleFile, error) {
+	out := []orgRuleFile{}
+	if org == "" {
+		return out, nil
+	}
+	rows, err := a.db.Query(ctx, `SELECT name, content FROM semgrep_rules WHERE org=$1 AND enabled ORDER BY name`, org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f orgRuleFile
+		if err := rows.Scan(&f.Name, &f.Content); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
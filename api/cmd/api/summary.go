@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"argus/internal/jobsql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Job summaries for workers that submit results through the internal API,
+// and for scans the API skips itself.
+
+// writeJobSummary builds a finished job's summary and stores it as an
+// artifact.
+func (a *App) writeJobSummary(ctx context.Context, jobID string) error {
+	sum, err := jobsql.BuildSummary(ctx, func(ctx context.Context, sql string, args ...any) jobsql.Row {
+		return a.db.QueryRow(ctx, sql, args...)
+	}, jobID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(b)
+	_, err = a.db.Exec(ctx, `INSERT INTO job_artifacts (job_id, kind, name, content_type, size_bytes, sha256, data) VALUES ($1,$2,$3,'application/json',$4,$5,$6)
+		ON CONFLICT (job_id, name) DO UPDATE SET kind=EXCLUDED.kind, content_type=EXCLUDED.content_type, size_bytes=EXCLUDED.size_bytes, sha256=EXCLUDED.sha256, data=EXCLUDED.data, created_at=now()`,
+		jobID, jobsql.SummaryKind, jobsql.SummaryName, len(b), hex.EncodeToString(digest[:]), b)
+	return err
+}
+
+// getJobSummary serves the summary written when the job finished.
+func (a *App) getJobSummary(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "id")
+	var status string
+	if err := a.db.QueryRow(r.Context(), `SELECT status::text FROM jobs WHERE id::text=$1`, jobID).Scan(&status); err != nil {
+		notFound(w)
+		return
+	}
+	var sum string
+	var data []byte
+	err := a.db.QueryRow(r.Context(), `SELECT sha256, data FROM job_artifacts WHERE job_id=$1 AND kind=$2 AND name=$3`, jobID, jobsql.SummaryKind, jobsql.SummaryName).Scan(&sum, &data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if status == "queued" || status == "running" {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": "job has not finished", "status": status})
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "no summary was written for this job"})
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func (a *App) internalJobSummary(w http.ResponseWriter, r *http.Request) {
+	if err := a.writeJobSummary(r.Context(), chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			notFound(w)
+			return
+		}
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
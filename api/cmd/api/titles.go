@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// titleFields lists the placeholders the worker fills for each title
+// template kind. Keep in sync with worker/cmd/worker/scanners.go.
+var titleFields = map[string][]string{
+	"semgrep":         {"rule_id", "message", "path", "line", "severity"},
+	"gitleaks":        {"rule_id", "description", "path", "line", "severity"},
+	"trufflehog":      {"detector", "verification", "path", "line", "severity"},
+	"trivy_vuln":      {"id", "package", "installed", "fixed", "title", "target", "severity"},
+	"trivy_misconfig": {"id", "title", "target", "line", "resource", "severity"},
+	"grype":           {"id", "package", "installed", "fixed", "type", "target", "severity"},
+	"nuclei":          {"rule_id", "name", "matched_at", "severity"},
+	"terraform":       {"id", "title", "address", "target", "line", "severity"},
+	"hadolint":        {"rule_id", "message", "path", "line", "severity"},
+	"kube-linter":     {"check", "message", "object", "path", "severity"},
+	"govulncheck":     {"id", "module", "installed", "fixed", "reachability", "path", "severity"},
+	"npm-audit":       {"id", "package", "installed", "fixed", "manager", "target", "severity"},
+	"pip-audit":       {"id", "package", "installed", "fixed", "target", "severity"},
+	"bandit":          {"rule_id", "test_name", "message", "path", "line", "severity"},
+}
+
+// normalizeTitleTemplates validates org title templates and drops blank
+// entries, which restore the built-in title for that kind.
+func normalizeTitleTemplates(in map[string]string) (map[string]string, error) {
+	out := map[string]string{}
+	for kind, tmpl := range in {
+		fields, ok := titleFields[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown title template kind %q (want one of %s)", kind, strings.Join(titleKinds(), ", "))
+		}
+		tmpl = strings.TrimSpace(tmpl)
+		if tmpl == "" {
+			continue
+		}
+		if len(tmpl) > 200 {
+			return nil, fmt.Errorf("%s: template must be at most 200 characters", kind)
+		}
+		rest := tmpl
+		for {
+			open := strings.IndexByte(rest, '{')
+			if open < 0 {
+				break
+			}
+			end := strings.IndexByte(rest[open:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("%s: unterminated placeholder", kind)
+			}
+			name := rest[open+1 : open+end]
+			if !contains(fields, name) {
+				return nil, fmt.Errorf("%s: unknown placeholder {%s} (want one of %s)", kind, name, strings.Join(fields, ", "))
+			}
+			rest = rest[open+end+1:]
+		}
+		out[kind] = tmpl
+	}
+	return out, nil
+}
+
+func titleKinds() []string {
+	kinds := make([]string, 0, len(titleFields))
+	for k := range titleFields {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
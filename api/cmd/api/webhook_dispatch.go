@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"argus/api/sdk/webhook"
+	"argus/internal/proxy"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	dispatchInterval    = 5 * time.Second
+	dispatchBatch       = 20
+	deliveryTimeout     = 10 * time.Second
+	maxDeliveryAttempts = 8
+	// deliveryLease is how long a claimed batch has to be delivered before
+	// another dispatcher may claim it; it outlasts dispatchBatch deliveries
+	// at deliveryTimeout each.
+	deliveryLease = dispatchBatch*deliveryTimeout + time.Minute
+	// retryBase doubles per attempt: 30s, 1m, 2m ... about an hour in total.
+	retryBase = 30 * time.Second
+)
+
+type pendingDelivery struct {
+	id, event, url string
+	secret         *string
+	payload        []byte
+	attempts       int
+}
+
+// runWebhookDispatcher drains the webhook_deliveries outbox until ctx is
+// cancelled. Rows are leased with SKIP LOCKED so several API replicas can
+// run it without double-sending.
+func (a *App) runWebhookDispatcher(ctx context.Context) {
+	client := deliveryClient(a.cfg.WebhookProxySetting())
+	t := time.NewTicker(dispatchInterval)
+	defer t.Stop()
+	for {
+		if err := a.dispatchDueDeliveries(ctx, client); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "webhook dispatch failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (a *App) dispatchDueDeliveries(ctx context.Context, client *http.Client) error {
+	items, err := a.leaseDueDeliveries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range items {
+		code, derr := a.deliver(ctx, client, d)
+		attempts := d.attempts + 1
+		if derr == nil {
+			_, err = a.db.Exec(ctx, `UPDATE webhook_deliveries SET status='delivered', attempts=$2, last_status_code=$3, last_error=NULL, delivered_at=now() WHERE id=$1`,
+				d.id, attempts, code)
+		} else {
+			status := "pending"
+			if attempts >= maxDeliveryAttempts {
+				status = "failed"
+			}
+			slog.WarnContext(ctx, "webhook delivery failed", "delivery_id", d.id, "event", d.event, "attempt", attempts, "err", derr)
+			_, err = a.db.Exec(ctx, `UPDATE webhook_deliveries SET status=$2, attempts=$3, last_status_code=$4, last_error=$5, next_attempt_at=now() + $6::interval WHERE id=$1`,
+				d.id, status, attempts, nullIfZero(code), derr.Error(), fmt.Sprintf("%d seconds", int(retryDelay(attempts).Seconds())))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaseDueDeliveries claims a batch of due deliveries by moving their next
+// attempt deliveryLease ahead, and commits before anything is sent so no
+// row stays locked while a receiver is slow. A delivery whose dispatcher
+// dies before recording the outcome is sent again once the lease lapses.
+func (a *App) leaseDueDeliveries(ctx context.Context) ([]pendingDelivery, error) {
+	rows, err := a.db.Query(ctx, `UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM webhooks h
+		WHERE h.id = d.webhook_id AND d.id IN (
+			SELECT p.id FROM webhook_deliveries p JOIN webhooks ph ON ph.id = p.webhook_id
+			WHERE p.status='pending' AND p.next_attempt_at <= now() AND ph.enabled
+			ORDER BY p.next_attempt_at LIMIT $1
+			FOR UPDATE OF p SKIP LOCKED)
+		RETURNING d.id::text, d.event, h.url, h.secret, d.payload, d.attempts`, dispatchBatch, deliveryLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (pendingDelivery, error) {
+		var d pendingDelivery
+		err := row.Scan(&d.id, &d.event, &d.url, &d.secret, &d.payload, &d.attempts)
+		return d, err
+	})
+}
+
+// deliver POSTs one delivery. Any 2xx response counts as delivered; the
+// returned status code is zero when no response was received.
+func (a *App) deliver(ctx context.Context, client *http.Client, d pendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Argus-Webhooks/1")
+	req.Header.Set("X-Argus-Event", d.event)
+	req.Header.Set("X-Argus-Delivery", d.id)
+	req.Header.Set(webhook.SignatureHeader, a.signer.Sign(d.payload, time.Now()))
+	if d.secret != nil && *d.secret != "" {
+		mac := hmac.New(sha256.New, []byte(*d.secret))
+		mac.Write(d.payload)
+		req.Header.Set("X-Argus-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func retryDelay(attempts int) time.Duration {
+	return retryBase << (attempts - 1)
+}
+
+func nullIfZero(v int) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+var errPrivateAddress = errors.New("webhook target resolves to a private address")
+
+func privateIP(ip net.IP) bool {
+	return ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// deliveryTransport refuses to connect to loopback, private and link-local
+// addresses, so a webhook URL cannot be used to probe the internal network.
+// The check runs on the resolved IP at dial time, which also covers DNS
+// rebinding and redirects.
+func deliveryTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if privateIP(net.ParseIP(host)) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// deliveryClient sends webhooks directly unless proxy.webhooks names a proxy;
+// the environment's proxy is never used for them. Through a proxy, which
+// usually sits on a private address, only the proxy itself may be dialled
+// unguarded, and each request's target is resolved and checked before it is
+// handed over, redirects included.
+func deliveryClient(p proxy.Setting) *http.Client {
+	t := deliveryTransport()
+	client := &http.Client{Timeout: deliveryTimeout, Transport: t}
+	if p.URL == proxy.Direct {
+		return client
+	}
+	proxyAddr := canonicalAddr(p.URL)
+	guarded := t.DialContext
+	direct := (&net.Dialer{Timeout: 5 * time.Second}).DialContext
+	t.Proxy = p.Func()
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == proxyAddr {
+			return direct(ctx, network, addr)
+		}
+		return guarded(ctx, network, addr)
+	}
+	client.Transport = targetGuard{t}
+	return client
+}
+
+// targetGuard rejects requests whose host resolves to a private address
+// before they reach a proxy, which would otherwise dial it for us.
+type targetGuard struct{ next http.RoundTripper }
+
+func (g targetGuard) RoundTrip(r *http.Request) (*http.Response, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(r.Context(), r.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if privateIP(a.IP) {
+			return nil, errPrivateAddress
+		}
+	}
+	return g.next.RoundTrip(r)
+}
+
+// canonicalAddr is a proxy URL's host:port, with the scheme's default port.
+func canonicalAddr(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := map[string]string{"http": "80", "https": "443", "socks5": "1080"}[u.Scheme]
+	return net.JoinHostPort(u.Hostname(), port)
+}
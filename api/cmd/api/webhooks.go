@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"argus/internal/jobsql"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// webhookEvents are the outbound events a webhook can subscribe to.
+var webhookEvents = []string{jobsql.EventScanCompleted, jobsql.EventFindingCritical}
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	HasSecret bool      `json:"has_secret"`
+	Events    []string  `json:"events"`
+	Org       *string   `json:"org,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type createWebhookReq struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Org    string   `json:"org"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+}
+
+// webhookSigningKeys publishes the public keys outbound webhook deliveries are
+// signed with. It is unauthenticated on purpose: integrators fetch it with the
+// sdk/webhook package to verify X-Argus-Signature headers.
+func (a *App) webhookSigningKeys(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, a.signer.PublicKeys())
+}
+
+func (a *App) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	rows, err := a.db.Query(r.Context(), `SELECT id::text, url, secret IS NOT NULL, events, org, enabled, created_at FROM webhooks ORDER BY created_at DESC`)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := make([]Webhook, 0)
+	for rows.Next() {
+		var h Webhook
+		if err := rows.Scan(&h.ID, &h.URL, &h.HasSecret, &h.Events, &h.Org, &h.Enabled, &h.CreatedAt); err != nil {
+			serverError(w, err)
+			return
+		}
+		out = append(out, h)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *App) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid json")
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		badRequest(w, "url must be an https URL without credentials")
+		return
+	}
+	if len(req.Events) == 0 {
+		badRequest(w, "events is required (scan.completed, finding.critical)")
+		return
+	}
+	for _, ev := range req.Events {
+		if !contains(webhookEvents, ev) {
+			badRequest(w, "unknown event "+ev+" (want scan.completed or finding.critical)")
+			return
+		}
+	}
+
+	var id string
+	err = a.db.QueryRow(r.Context(), `INSERT INTO webhooks (url, secret, events, org) VALUES ($1,$2,$3,$4) RETURNING id::text`,
+		req.URL, nullIfEmpty(req.Secret), req.Events, nullIfEmpty(strings.ToLower(strings.TrimSpace(req.Org)))).Scan(&id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"id": id})
+}
+
+func (a *App) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	tag, err := a.db.Exec(r.Context(), `DELETE FROM webhooks WHERE id::text=$1`, chi.URLParam(r, "id"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		notFound(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries is the delivery log: the most recent 100 attempts for
+// a webhook, newest first. ?payload=true includes the request bodies.
+func (a *App) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var exists bool
+	if err := a.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM webhooks WHERE id::text=$1)`, id).Scan(&exists); err != nil {
+		serverError(w, err)
+		return
+	}
+	if !exists {
+		notFound(w)
+		return
+	}
+	withPayload := r.URL.Query().Get("payload") == "true"
+
+	rows, err := a.db.Query(r.Context(), `SELECT id::text, event, status, attempts, CASE WHEN status='pending' THEN next_attempt_at END, last_status_code, last_error, delivered_at, created_at, CASE WHEN $2 THEN payload END
+		FROM webhook_deliveries WHERE webhook_id::text=$1 ORDER BY created_at DESC LIMIT 100`, id, withPayload)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.Event, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt, &payload); err != nil {
+			serverError(w, err)
+			return
+		}
+		if payload != nil {
+			d.Payload = payload
+		}
+		out = append(out, d)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Workers register with the job queue and keep a heartbeat while they run. The reconciler recovers jobs left running by a worker
+// whose heartbeat has gone stale.
+const (
+	reconcileInterval = time.Minute
+	// staleGrace is how long a job must have been running before the
+	// reconciler looks at it; it covers a worker's first heartbeat and clock
+	// skew between workers and Postgres.
+	staleGrace = time.Minute
+)
+
+// WorkerInfo is a worker's registry entry plus whether its heartbeat is
+// current and the jobs it is running.
+type WorkerInfo struct {
+	Name        string    `json:"name"`
+	Host        string    `json:"host"`
+	PID         int       `json:"pid"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	Concurrency int       `json:"concurrency"`
+	Scanners    []string  `json:"scanners"`
+	ResultsMode string    `json:"results_mode"`
+	Alive       bool      `json:"alive"`
+	Jobs        []string  `json:"jobs"`
+}
+
+// registeredWorkers reads the registry and checks each worker's heartbeat.
+func (a *App) registeredWorkers(ctx context.Context) (map[string]*WorkerInfo, error) {
+	out, err := a.queue.Workers(ctx)
+	for _, info := range out {
+		info.Jobs = []string{}
+	}
+	return out, err
+}
+
+// workerStatus returns the registered workers sorted by name, each with the
+// jobs it is running.
+func (a *App) workerStatus(ctx context.Context) ([]*WorkerInfo, error) {
+	workers, err := a.registeredWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := a.db.Query(ctx, `SELECT id::text, worker FROM jobs WHERE status='running' AND worker IS NOT NULL ORDER BY started_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		if info, ok := workers[name]; ok {
+			info.Jobs = append(info.Jobs, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]*WorkerInfo, 0, len(workers))
+	for _, info := range workers {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (a *App) listWorkers(w http.ResponseWriter, r *http.Request) {
+	out, err := a.workerStatus(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"workers": out})
+}
+
+// runJobReconciler recovers stale running jobs until ctx is cancelled. Each
+// update is conditional on the job still being on the stale worker, so
+// several API replicas can run it at once.
+func (a *App) runJobReconciler(ctx context.Context) {
+	t := time.NewTicker(reconcileInterval)
+	defer t.Stop()
+	for {
+		if err := a.reconcileStaleJobs(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "job reconciler failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type staleJob struct {
+	id, worker string
+	startedAt  time.Time
+}
+
+// reconcileStaleJobs looks at jobs running on a worker that is no longer
+// alive, or that has restarted since it took the job. A job whose message is
+// still queued (workers hand a dead worker's messages back to the queue) is
+// reset to queued so it shows as waiting and runs again; any other is marked
+// failed.
+func (a *App) reconcileStaleJobs(ctx context.Context) error {
+	rows, err := a.db.Query(ctx, `SELECT id::text, worker, started_at FROM jobs
+		WHERE status='running' AND worker IS NOT NULL AND started_at < now() - make_interval(secs => $1)`, staleGrace.Seconds())
+	if err != nil {
+		return err
+	}
+	var running []staleJob
+	for rows.Next() {
+		var j staleJob
+		if err := rows.Scan(&j.id, &j.worker, &j.startedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		running = append(running, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(running) == 0 {
+		return err
+	}
+
+	workers, err := a.registeredWorkers(ctx)
+	if err != nil {
+		return err
+	}
+	var stale []staleJob
+	for _, j := range running {
+		info, ok := workers[j.worker]
+		if !ok || !info.Alive || info.StartedAt.After(j.startedAt.Add(staleGrace)) {
+			stale = append(stale, j)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	pending, err := a.pendingJobIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, j := range stale {
+		jctx := withJobID(ctx, j.id)
+		if pending[j.id] {
+			ok, err := a.resetStaleJob(ctx, j)
+			if err != nil {
+				return err
+			}
+			if ok {
+				slog.WarnContext(jctx, "stale running job requeued", "worker", j.worker)
+			}
+			continue
+		}
+		tag, err := a.db.Exec(ctx, `UPDATE jobs SET status='failed', finished_at=now(), error=$3
+			WHERE id=$1 AND status='running' AND worker=$2`, j.id, j.worker, "worker "+j.worker+" stopped heartbeating")
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		slog.WarnContext(jctx, "stale running job marked failed", "worker", j.worker)
+		if err := a.publishJobEvents(ctx, j.id); err != nil {
+			slog.WarnContext(jctx, "webhook events skipped", "err", err)
+		}
+	}
+	return nil
+}
+
+// resetStaleJob puts a stale job back to queued, dropping what the dead run
+// stored. It reports false if the job moved on in the meantime.
+func (a *App) resetStaleJob(ctx context.Context, j staleJob) (bool, error) {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	var id string
+	err = tx.QueryRow(ctx, `UPDATE jobs SET status='queued', started_at=NULL, finished_at=NULL, error=$3, worker=NULL,
+			stage=NULL, progress=0, stage_timings='{}'
+		WHERE id=$1 AND status='running' AND worker=$2 RETURNING id::text`, j.id, j.worker, "worker "+j.worker+" stopped heartbeating; requeued").Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM findings WHERE job_id=$1 AND (repo_id IS NULL OR first_job_id=$1)`, j.id); err != nil {
+		return false, err
+	}
+	objects, err := discardJobObjects(ctx, tx, []string{j.id})
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	a.deleteObjects(ctx, objects)
+	return true, nil
+}
+
+// pendingJobIDs returns the jobs that still have a message waiting, delayed
+// for a retry, or held in flight by any worker.
+func (a *App) pendingJobIDs(ctx context.Context) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, set := range []string{queueWaiting, queueDelayed, queueInFlight} {
+		raws, err := a.queue.Messages(ctx, set)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range raws {
+			if id := messageJobID(raw); id != "" {
+				out[id] = true
+			}
+		}
+	}
+	return out, nil
+}
@@ -3,6 +3,7 @@ module argus/api
 go 1.22
 
 require (
+	argus/internal v0.0.0
 	github.com/go-chi/chi/v5 v5.1.0
 	github.com/jackc/pgx/v5 v5.6.0
 	github.com/redis/go-redis/v9 v9.6.1
@@ -18,3 +19,5 @@ require (
 	golang.org/x/sync v0.1.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
+
+replace argus/internal => ../internal
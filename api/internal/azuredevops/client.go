@@ -0,0 +1,207 @@
+// Package azuredevops opens the pull requests fixes are proposed in on
+// Azure Repos, through the Azure DevOps Services REST API and a personal
+// access token.
+package azuredevops
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+// apiVersion is the REST API version every request asks for.
+const apiVersion = "7.1"
+
+// MaxDescription is the longest pull request description Azure DevOps
+// accepts.
+const MaxDescription = 4000
+
+// cutDescription cuts description to MaxDescription.
+func cutDescription(description string) string {
+	if len(description) <= MaxDescription {
+		return description
+	}
+	const more = "\n\n..."
+	return strings.ToValidUTF8(description[:MaxDescription-len(more)], "") + more
+}
+
+// zeroSHA as a ref's old object ID creates the ref.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// Client calls the REST API with a personal access token. The token needs
+// the Code (Read & write) scope.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// New returns a client that authenticates with token and reaches Azure
+// DevOps through p.
+func New(token string, p proxy.Setting) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 25 * time.Second, Transport: p.Transport()},
+		baseURL:    "https://" + forge.AzureDevOps,
+		token:      token,
+	}
+}
+
+// DefaultBranch returns the name of r's default branch.
+func (c *Client) DefaultBranch(ctx context.Context, r forge.AzureRepo) (string, error) {
+	var out struct {
+		DefaultBranch string `json:"defaultBranch"`
+	}
+	if err := c.call(ctx, http.MethodGet, r.APIPath(), nil, &out); err != nil {
+		return "", err
+	}
+	if out.DefaultBranch == "" {
+		return "", errors.New("default branch missing")
+	}
+	return strings.TrimPrefix(out.DefaultBranch, "refs/heads/"), nil
+}
+
+// BranchSHA returns the commit branch of r points at.
+func (c *Client) BranchSHA(ctx context.Context, r forge.AzureRepo, branch string) (string, error) {
+	var out struct {
+		Value []struct {
+			Name     string `json:"name"`
+			ObjectID string `json:"objectId"`
+		} `json:"value"`
+	}
+	// filter matches ref names by prefix, so look for the exact name.
+	if err := c.call(ctx, http.MethodGet, r.APIPath()+"/refs?filter=heads/"+url.QueryEscape(branch), nil, &out); err != nil {
+		return "", err
+	}
+	for _, ref := range out.Value {
+		if ref.Name == "refs/heads/"+branch {
+			return ref.ObjectID, nil
+		}
+	}
+	return "", fmt.Errorf("branch %s not found", branch)
+}
+
+// CreateBranch creates branch in r at commit sha.
+func (c *Client) CreateBranch(ctx context.Context, r forge.AzureRepo, branch, sha string) error {
+	in := []map[string]string{{"name": "refs/heads/" + branch, "oldObjectId": zeroSHA, "newObjectId": sha}}
+	var out struct {
+		Value []struct {
+			Success      bool   `json:"success"`
+			UpdateStatus string `json:"updateStatus"`
+		} `json:"value"`
+	}
+	if err := c.call(ctx, http.MethodPost, r.APIPath()+"/refs", in, &out); err != nil {
+		return err
+	}
+	if len(out.Value) != 1 || !out.Value[0].Success {
+		status := ""
+		if len(out.Value) > 0 {
+			status = out.Value[0].UpdateStatus
+		}
+		return fmt.Errorf("create branch %s failed: %s", branch, status)
+	}
+	return nil
+}
+
+// CreatePullRequest opens a pull request of source into target, as a
+// draft when draft is set, and returns its web URL. description is cut to
+// MaxDescription.
+func (c *Client) CreatePullRequest(ctx context.Context, r forge.AzureRepo, title, source, target, description string, draft bool) (string, error) {
+	in := map[string]any{
+		"sourceRefName": "refs/heads/" + source,
+		"targetRefName": "refs/heads/" + target,
+		"title":         title,
+		"description":   cutDescription(description),
+		"isDraft":       draft,
+	}
+	var out struct {
+		PullRequestID int `json:"pullRequestId"`
+	}
+	if err := c.call(ctx, http.MethodPost, r.APIPath()+"/pullrequests", in, &out); err != nil {
+		return "", err
+	}
+	return r.PullRequestURL(out.PullRequestID), nil
+}
+
+// PullRequest is a pull request as Azure DevOps lists them.
+type PullRequest struct {
+	ID            int    `json:"pullRequestId"`
+	SourceRefName string `json:"sourceRefName"`
+	TargetRefName string `json:"targetRefName"`
+	Description   string `json:"description"`
+}
+
+// ActivePullRequest returns the active pull request of source, or nil when
+// there is none.
+func (c *Client) ActivePullRequest(ctx context.Context, r forge.AzureRepo, source string) (*PullRequest, error) {
+	q := url.Values{"searchCriteria.status": {"active"}, "searchCriteria.sourceRefName": {"refs/heads/" + source}}
+	var out struct {
+		Value []PullRequest `json:"value"`
+	}
+	if err := c.call(ctx, http.MethodGet, r.APIPath()+"/pullrequests?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Value) == 0 {
+		return nil, nil
+	}
+	return &out.Value[0], nil
+}
+
+// EditPullRequestDescription replaces the description of pull request id,
+// cut to MaxDescription.
+func (c *Client) EditPullRequestDescription(ctx context.Context, r forge.AzureRepo, id int, description string) error {
+	in := map[string]string{"description": cutDescription(description)}
+	return c.call(ctx, http.MethodPatch, fmt.Sprintf("%s/pullrequests/%d", r.APIPath(), id), in, nil)
+}
+
+// call sends in, when non-nil, as JSON to the REST API and decodes the
+// response into out when non-nil.
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path+sep+"api-version="+apiVersion, body)
+	if err != nil {
+		return err
+	}
+	// A PAT is the password of basic auth; the user name is ignored.
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(":"+c.token)))
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	// An expired or wrong PAT gets a 203 with the sign-in page.
+	if resp.StatusCode >= 300 || resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		return fmt.Errorf("azure devops %s %s failed: status=%d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
@@ -0,0 +1,93 @@
+package azuredevops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestCreatePullRequest(t *testing.T) {
+	var got struct {
+		SourceRefName, TargetRefName, Description string
+		IsDraft                                   bool
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/acme/Platform%20Team/_apis/git/repositories/api/pullrequests" || r.URL.Query().Get("api-version") != apiVersion {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "" || pass != "pat" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"pullRequestId":42}`))
+	}))
+	defer srv.Close()
+
+	c := New("pat", proxy.Setting{URL: proxy.Direct})
+	c.baseURL = srv.URL
+	prURL, err := c.CreatePullRequest(context.Background(), forge.AzureRepo{Org: "acme", Project: "Platform Team", Name: "api"}, "Argus: Fix findings", "argus/fix-1", "main", strings.Repeat("x", 5000), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prURL != "https://dev.azure.com/acme/Platform%20Team/_git/api/pullrequest/42" {
+		t.Errorf("pr url = %q", prURL)
+	}
+	if got.SourceRefName != "refs/heads/argus/fix-1" || got.TargetRefName != "refs/heads/main" || len(got.Description) != MaxDescription || !got.IsDraft {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestCreateBranchConflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"value":[{"success":false,"updateStatus":"failedToCreate"}]}`))
+	}))
+	defer srv.Close()
+
+	c := New("pat", proxy.Setting{URL: proxy.Direct})
+	c.baseURL = srv.URL
+	err := c.CreateBranch(context.Background(), forge.AzureRepo{Org: "acme", Project: "Platform", Name: "api"}, "argus/fix-1", "abc123")
+	if err == nil || !strings.Contains(err.Error(), "failedToCreate") {
+		t.Fatalf("CreateBranch = %v, want the update status", err)
+	}
+}
+
+func TestActivePullRequest(t *testing.T) {
+	var description string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/acme/Platform/_apis/git/repositories/api/pullrequests":
+			if r.URL.Query().Get("searchCriteria.status") != "active" || r.URL.Query().Get("searchCriteria.sourceRefName") != "refs/heads/argus/fix-1" {
+				t.Errorf("query = %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"value":[{"pullRequestId":42,"sourceRefName":"refs/heads/argus/fix-1","targetRefName":"refs/heads/main"}],"count":1}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/acme/Platform/_apis/git/repositories/api/pullrequests/42":
+			var in map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&in)
+			description = in["description"]
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New("pat", proxy.Setting{URL: proxy.Direct})
+	c.baseURL = srv.URL
+	repo := forge.AzureRepo{Org: "acme", Project: "Platform", Name: "api"}
+	pr, err := c.ActivePullRequest(context.Background(), repo, "argus/fix-1")
+	if err != nil || pr == nil || pr.ID != 42 || pr.TargetRefName != "refs/heads/main" {
+		t.Fatalf("ActivePullRequest = %+v, %v", pr, err)
+	}
+	if err := c.EditPullRequestDescription(context.Background(), repo, 42, strings.Repeat("x", 5000)); err != nil || len(description) != MaxDescription {
+		t.Errorf("EditPullRequestDescription = %v, description of %d bytes", err, len(description))
+	}
+}
@@ -0,0 +1,116 @@
+// Package changescope decides how much of a scan a push needs from the
+// paths it changed. Pushes that only touch documentation or images can skip
+// the scan, or run a narrower set of scanners (secrets can still leak in a
+// README), instead of occupying a worker for a full scan.
+package changescope
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultSkipPaths are the non-code paths used when none are configured.
+// Plain-text files are only skipped under docs/: elsewhere *.txt names
+// requirements.txt and CMakeLists.txt.
+var DefaultSkipPaths = []string{
+	"docs/**", "*.md", "*.rst", "*.adoc", "LICENSE", "CODEOWNERS", ".github/ISSUE_TEMPLATE/**",
+	"*.png", "*.jpg", "*.jpeg", "*.gif", "*.svg", "*.ico", "*.webp",
+}
+
+// manifests are dependency manifests, lockfiles and build files. A change
+// to one is never skipped, whatever SkipPaths says: pip-audit, npm audit,
+// govulncheck, grype and trivy scan what they declare.
+var manifests = []string{
+	"requirements*.txt", "constraints*.txt", "Pipfile", "Pipfile.lock", "pyproject.toml", "poetry.lock", "uv.lock", "setup.py", "setup.cfg",
+	"package.json", "package-lock.json", "npm-shrinkwrap.json", "pnpm-lock.yaml", "yarn.lock",
+	"go.mod", "go.sum", "Cargo.toml", "Cargo.lock", "Gemfile", "Gemfile.lock", "composer.json", "composer.lock",
+	"pom.xml", "build.gradle", "build.gradle.kts", "gradle.lockfile", "*.csproj", "packages.lock.json",
+	"CMakeLists.txt", "Dockerfile", "*.tf",
+}
+
+// Policy says which changes are non-code and what still runs for them.
+type Policy struct {
+	// SkipPaths are globs of paths that need no full scan.
+	SkipPaths []string
+	// Scanners still run when only SkipPaths changed; empty skips the scan.
+	Scanners []string
+}
+
+// Decision is the outcome for one push.
+type Decision struct {
+	// Skip means no scan is needed at all.
+	Skip bool
+	// Scanners, when not nil, replaces the scan's scanner selection.
+	Scanners []string
+	// Reason explains a skip or narrowed selection; empty otherwise.
+	Reason string
+}
+
+// Decide applies p to a push that changed the given paths, for a scan that
+// selected the given scanners (empty meaning all). A push with no known
+// changes, or any change outside SkipPaths, gets the scan as requested.
+func (p Policy) Decide(changed, selected []string) Decision {
+	if len(changed) == 0 || len(p.SkipPaths) == 0 {
+		return Decision{}
+	}
+	for _, c := range changed {
+		if !p.skippable(c) {
+			return Decision{}
+		}
+	}
+	scope := fmt.Sprintf("all %d changed paths match skip paths", len(changed))
+	var keep []string
+	for _, s := range p.Scanners {
+		if len(selected) == 0 || contains(selected, s) {
+			keep = append(keep, s)
+		}
+	}
+	if len(keep) == 0 {
+		return Decision{Skip: true, Reason: scope}
+	}
+	return Decision{Scanners: keep, Reason: scope + "; running " + strings.Join(keep, ", ") + " only"}
+}
+
+func (p Policy) skippable(changed string) bool {
+	for _, g := range manifests {
+		if Match(g, changed) {
+			return false
+		}
+	}
+	for _, g := range p.SkipPaths {
+		if Match(g, changed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match matches a repo-relative path against a glob the way repo exclude
+// globs do: "dir/**" matches everything under dir, a glob with a slash
+// matches the whole path, and one without matches any path element.
+func Match(glob, p string) bool {
+	p = strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(p, "./")), "/")
+	if dir, ok := strings.CutSuffix(glob, "/**"); ok {
+		return p == dir || strings.HasPrefix(p, dir+"/")
+	}
+	if strings.Contains(glob, "/") {
+		ok, _ := path.Match(glob, p)
+		return ok
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if ok, _ := path.Match(glob, elem); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package changescope
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	skip := Policy{SkipPaths: DefaultSkipPaths}
+	narrow := Policy{SkipPaths: DefaultSkipPaths, Scanners: []string{"gitleaks"}}
+	docs := []string{"README.md", "docs/setup/install.md", "assets/logo.png"}
+	for _, tc := range []struct {
+		name     string
+		p        Policy
+		changed  []string
+		selected []string
+		skip     bool
+		scanners []string
+	}{
+		{"docs only", skip, docs, nil, true, nil},
+		{"code too", skip, append(docs, "main.go"), nil, false, nil},
+		{"no change list", skip, nil, nil, false, nil},
+		{"narrowed", narrow, docs, nil, false, []string{"gitleaks"}},
+		{"narrowed within selection", narrow, docs, []string{"semgrep", "gitleaks"}, false, []string{"gitleaks"}},
+		{"selection excludes narrowed", narrow, docs, []string{"semgrep"}, true, nil},
+		{"no skip paths", Policy{}, docs, nil, false, nil},
+		{"requirements", skip, append(docs, "requirements.txt"), nil, false, nil},
+		{"manifest under docs", Policy{SkipPaths: []string{"docs/**", "*.txt"}}, []string{"docs/requirements.txt"}, nil, false, nil},
+		{"text under docs", skip, []string{"docs/notes.txt"}, nil, true, nil},
+	} {
+		d := tc.p.Decide(tc.changed, tc.selected)
+		if d.Skip != tc.skip || !reflect.DeepEqual(d.Scanners, tc.scanners) {
+			t.Errorf("%s: got %+v", tc.name, d)
+		}
+		if (d.Skip || d.Scanners != nil) == (d.Reason == "") {
+			t.Errorf("%s: reason %q", tc.name, d.Reason)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		glob, path string
+		want       bool
+	}{
+		{"docs/**", "docs/a/b.md", true},
+		{"docs/**", "src/docs/a.md", false},
+		{"*.md", "pkg/README.md", true},
+		{"LICENSE", "LICENSE", true},
+		{".github/ISSUE_TEMPLATE/**", ".github/ISSUE_TEMPLATE/bug.yml", true},
+		{".github/ISSUE_TEMPLATE/**", ".github/workflows/ci.yml", false},
+		{"*.png", "./img/x.png", true},
+	} {
+		if got := Match(tc.glob, tc.path); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v", tc.glob, tc.path, got)
+		}
+	}
+}
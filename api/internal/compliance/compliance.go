@@ -0,0 +1,214 @@
+// Package compliance maps findings to the compliance framework controls
+// (SOC 2, ISO/IEC 27001, PCI DSS) they bear on. Findings are first reduced
+// to CWE weaknesses, from the scanner's metadata or from defaults per tool
+// and rule, and each CWE maps to controls. The mapping is deliberately
+// coarse: it points auditors at the controls to review, it does not assess
+// them.
+package compliance
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Control is one framework requirement.
+type Control struct {
+	Framework string `json:"framework"`
+	ID        string `json:"control"`
+	Name      string `json:"name"`
+}
+
+// Framework is a set of controls the mapping can point at.
+type Framework struct {
+	ID       string
+	Name     string
+	Controls []Control
+}
+
+// Finding is what the mapping needs to know about a finding.
+type Finding struct {
+	Tool   string
+	RuleID string
+	// Vulnerability marks a known vulnerability in a dependency, as opposed
+	// to a weakness in the repo's own code or configuration.
+	Vulnerability bool
+	// CWEs are the scanner's own CWE references, in any of the forms
+	// scanners use: "CWE-79", "CWE-79: Improper Neutralization ...".
+	CWEs []string
+}
+
+var frameworks = []Framework{
+	{ID: "soc2", Name: "SOC 2 (2017 Trust Services Criteria)", Controls: []Control{
+		{ID: "CC6.1", Name: "Logical access security software, infrastructure and architectures"},
+		{ID: "CC6.3", Name: "Role-based access and least privilege"},
+		{ID: "CC6.7", Name: "Restriction of data transmission and movement"},
+		{ID: "CC7.1", Name: "Detection of configuration changes and newly discovered vulnerabilities"},
+		{ID: "CC8.1", Name: "Change management: design, development and testing of changes"},
+	}},
+	{ID: "iso27001", Name: "ISO/IEC 27001:2022 Annex A", Controls: []Control{
+		{ID: "A.5.17", Name: "Authentication information"},
+		{ID: "A.8.2", Name: "Privileged access rights"},
+		{ID: "A.8.8", Name: "Management of technical vulnerabilities"},
+		{ID: "A.8.9", Name: "Configuration management"},
+		{ID: "A.8.24", Name: "Use of cryptography"},
+		{ID: "A.8.28", Name: "Secure coding"},
+	}},
+	{ID: "pci-dss", Name: "PCI DSS v4.0", Controls: []Control{
+		{ID: "2.2.6", Name: "System security parameters are configured to prevent misuse"},
+		{ID: "4.2.1", Name: "Strong cryptography protects data during transmission"},
+		{ID: "6.2.4", Name: "Software engineering techniques prevent common software attacks"},
+		{ID: "6.3.3", Name: "Known security vulnerabilities are fixed by installing patches"},
+		{ID: "7.2.5", Name: "Application and system accounts have least privileges"},
+		{ID: "8.6.2", Name: "Passwords for application and system accounts are not hard coded"},
+	}},
+}
+
+// weaknessGroups assigns CWEs to controls per framework. CWEs outside every
+// group map to nothing.
+var weaknessGroups = []struct {
+	cwes     []int
+	controls map[string][]string // framework -> control IDs
+}{
+	{ // hard-coded and poorly protected credentials
+		[]int{259, 321, 522, 798},
+		map[string][]string{"soc2": {"CC6.1"}, "iso27001": {"A.5.17"}, "pci-dss": {"8.6.2"}},
+	},
+	{ // injection and other common coding flaws
+		[]int{20, 22, 77, 78, 79, 89, 90, 94, 352, 434, 502, 601, 611, 915, 917, 918, 1321},
+		map[string][]string{"soc2": {"CC8.1"}, "iso27001": {"A.8.28"}, "pci-dss": {"6.2.4"}},
+	},
+	{ // weak cryptography
+		[]int{295, 326, 327, 328, 330, 338, 916},
+		map[string][]string{"soc2": {"CC6.1"}, "iso27001": {"A.8.24"}, "pci-dss": {"6.2.4"}},
+	},
+	{ // cleartext transmission
+		[]int{319},
+		map[string][]string{"soc2": {"CC6.7"}, "iso27001": {"A.8.24"}, "pci-dss": {"4.2.1"}},
+	},
+	{ // vulnerable or unmaintained dependencies
+		[]int{937, 1035, 1104, 1395},
+		map[string][]string{"soc2": {"CC7.1"}, "iso27001": {"A.8.8"}, "pci-dss": {"6.3.3"}},
+	},
+	{ // insecure configuration
+		[]int{16, 1357},
+		map[string][]string{"soc2": {"CC7.1"}, "iso27001": {"A.8.9"}, "pci-dss": {"2.2.6"}},
+	},
+	{ // excessive privileges
+		[]int{250, 269},
+		map[string][]string{"soc2": {"CC6.3"}, "iso27001": {"A.8.2"}, "pci-dss": {"7.2.5"}},
+	},
+}
+
+// ruleCWEs are CWEs for rules whose scanner reports none.
+var ruleCWEs = map[string][]string{
+	"trivy:DS001":                                {"CWE-1357"}, // base image uses :latest
+	"trivy:DS002":                                {"CWE-250"},  // container runs as root
+	"hadolint:DL3002":                            {"CWE-250"},  // last USER is root
+	"kube-linter:privileged-container":           {"CWE-250"},
+	"kube-linter:run-as-non-root":                {"CWE-250"},
+	"kube-linter:privilege-escalation-container": {"CWE-269"},
+}
+
+// Frameworks lists the supported framework IDs.
+func Frameworks() []string {
+	out := make([]string, len(frameworks))
+	for i, f := range frameworks {
+		out[i] = f.ID
+	}
+	return out
+}
+
+// Lookup returns the framework with id.
+func Lookup(id string) (Framework, bool) {
+	for _, f := range frameworks {
+		if f.ID == id {
+			out := f
+			out.Controls = make([]Control, len(f.Controls))
+			for i, c := range f.Controls {
+				c.Framework = f.ID
+				out.Controls[i] = c
+			}
+			return out, true
+		}
+	}
+	return Framework{}, false
+}
+
+var cweRef = regexp.MustCompile(`(?i)^\s*CWE-(\d+)`)
+
+// CWEs returns f's weaknesses as sorted, de-duplicated "CWE-n" IDs: the
+// scanner's own, or else the defaults for its rule and tool.
+func CWEs(f Finding) []string {
+	seen := map[string]bool{}
+	for _, ref := range f.CWEs {
+		if m := cweRef.FindStringSubmatch(ref); m != nil {
+			seen["CWE-"+strings.TrimLeft(m[1], "0")] = true
+		}
+	}
+	if len(seen) == 0 {
+		defaults := ruleCWEs[f.Tool+":"+f.RuleID]
+		switch {
+		case defaults != nil:
+		case f.Tool == "gitleaks", f.Tool == "trufflehog":
+			defaults = []string{"CWE-798"}
+		case f.Vulnerability:
+			defaults = []string{"CWE-1395"}
+		case f.Tool == "trivy", f.Tool == "hadolint", f.Tool == "kube-linter":
+			defaults = []string{"CWE-16"}
+		}
+		for _, c := range defaults {
+			seen[c] = true
+		}
+	}
+	if f.Vulnerability {
+		seen["CWE-1395"] = true
+	}
+	out := make([]string, 0, len(seen))
+	for c := range seen {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Controls returns the controls f maps to, in framework then control order;
+// framework "" means every framework.
+func Controls(f Finding, framework string) []Control {
+	cwes := map[int]bool{}
+	for _, c := range CWEs(f) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(c, "CWE-"))
+		cwes[n] = true
+	}
+	ids := map[string]map[string]bool{}
+	for _, g := range weaknessGroups {
+		for _, n := range g.cwes {
+			if !cwes[n] {
+				continue
+			}
+			for fw, controls := range g.controls {
+				if ids[fw] == nil {
+					ids[fw] = map[string]bool{}
+				}
+				for _, id := range controls {
+					ids[fw][id] = true
+				}
+			}
+			break
+		}
+	}
+	var out []Control
+	for _, fw := range frameworks {
+		if framework != "" && framework != fw.ID {
+			continue
+		}
+		for _, c := range fw.Controls {
+			if ids[fw.ID][c.ID] {
+				c.Framework = fw.ID
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package compliance
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCWEs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    Finding
+		want []string
+	}{
+		{"scanner refs", Finding{Tool: "semgrep", CWEs: []string{"CWE-89: Improper Neutralization of Special Elements", "cwe-079", "CWE-89"}}, []string{"CWE-79", "CWE-89"}},
+		{"gitleaks default", Finding{Tool: "gitleaks", RuleID: "aws-access-token"}, []string{"CWE-798"}},
+		{"trufflehog default", Finding{Tool: "trufflehog", RuleID: "AWS"}, []string{"CWE-798"}},
+		{"rule default", Finding{Tool: "trivy", RuleID: "DS002"}, []string{"CWE-250"}},
+		{"misconfig default", Finding{Tool: "trivy", RuleID: "AVD-AWS-0086"}, []string{"CWE-16"}},
+		{"dockerfile lint", Finding{Tool: "hadolint", RuleID: "DL3008"}, []string{"CWE-16"}},
+		{"privileged pod", Finding{Tool: "kube-linter", RuleID: "privileged-container"}, []string{"CWE-250"}},
+		{"vulnerability", Finding{Tool: "trivy", RuleID: "CVE-2024-0001", Vulnerability: true, CWEs: []string{"CWE-79"}}, []string{"CWE-1395", "CWE-79"}},
+		{"unknown", Finding{Tool: "semgrep", RuleID: "custom"}, []string{}},
+	} {
+		if got := CWEs(tc.f); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: CWEs = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestControls(t *testing.T) {
+	f := Finding{Tool: "trivy", RuleID: "CVE-2024-0001", Vulnerability: true, CWEs: []string{"CWE-79"}}
+	var got []string
+	for _, c := range Controls(f, "") {
+		got = append(got, c.Framework+" "+c.ID)
+	}
+	want := []string{"soc2 CC7.1", "soc2 CC8.1", "iso27001 A.8.8", "iso27001 A.8.28", "pci-dss 6.2.4", "pci-dss 6.3.3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Controls = %v, want %v", got, want)
+	}
+	if got := Controls(Finding{Tool: "gitleaks"}, "pci-dss"); len(got) != 1 || got[0].ID != "8.6.2" {
+		t.Errorf("gitleaks pci-dss controls = %+v", got)
+	}
+	if got := Controls(Finding{Tool: "semgrep", RuleID: "custom"}, ""); len(got) != 0 {
+		t.Errorf("unmapped finding got %+v", got)
+	}
+}
+
+// Every control a weakness group names must exist in its framework, or it
+// would never show up in a coverage report.
+func TestGroupsReferenceKnownControls(t *testing.T) {
+	for _, g := range weaknessGroups {
+		for fw, ids := range g.controls {
+			f, ok := Lookup(fw)
+			if !ok {
+				t.Fatalf("unknown framework %q", fw)
+			}
+			for _, id := range ids {
+				found := false
+				for _, c := range f.Controls {
+					found = found || c.ID == id
+				}
+				if !found {
+					t.Errorf("%s has no control %s", fw, id)
+				}
+			}
+		}
+	}
+}
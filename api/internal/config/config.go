@@ -0,0 +1,448 @@
+// Package config loads API settings from an optional YAML or TOML file and
+// the environment. Precedence, lowest first: built-in defaults, the config
+// file, ARGUS_<SECTION>_<KEY> variables, then the legacy variable names
+// (SSAO_TOKEN, DATABASE_URL, ...) that existing deployments already set.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/api/internal/changescope"
+	"argus/api/internal/commitsign"
+	"argus/internal/configfile"
+	"argus/internal/forge"
+	"argus/internal/imagecreds"
+	"argus/internal/objstore"
+	"argus/internal/proxy"
+)
+
+// DefaultToken is the API token used when none is configured. It exists so a
+// fresh docker compose stack works out of the box; main warns when it is used.
+const DefaultToken = "change-me-super-long-random"
+
+type Config struct {
+	ListenAddr   string
+	Token        string
+	AdminToken   string
+	DatabaseURL  string
+	RedisAddr    string
+	JobQueue     string
+	QueueBackend string // QueueRedis, or QueuePostgres for deployments without Redis
+	AllowedHosts []string
+	AdhocMaxMB   int
+	MaxCloneMB   int
+	SigningKeys  string
+	LogLevel     string
+	LogFormat    string
+	// QueueWarnDepth marks /healthz degraded once the job queue is deeper.
+	QueueWarnDepth int
+	// PublicURL is the externally reachable base URL used in links the API
+	// hands out; derived from the request when empty.
+	PublicURL string
+	// ArtifactURLSecret keys presigned artifact URLs. Replicas must share it.
+	ArtifactURLSecret string
+	// ArtifactStore is the S3-compatible bucket workers upload scanner
+	// output to; downloads of it are redirected there.
+	ArtifactStore objstore.Config
+	// Offline stops the API from calling the GitHub API.
+	Offline bool
+	// GitHubURL is the web URL of the GitHub instance repos are on:
+	// github.com, or a GitHub Enterprise Server.
+	GitHubURL string
+	// GitHubWebhookSecrets verify GitHub App event deliveries to
+	// /webhooks/github, tried in order so the secret can be rotated; empty
+	// leaves the endpoint unmounted.
+	GitHubWebhookSecrets []string
+	// GitHubAlertsSyncInterval is how often the API imports each GitHub
+	// repo's Dependabot and secret scanning alerts as findings; empty
+	// leaves it to POST /repos/{id}/github-alerts/sync.
+	GitHubAlertsSyncInterval string
+	// GitLabURL is the web URL of gitlab.com or a self-managed GitLab, and
+	// GitLabToken the access token merge requests are opened with.
+	// GitLabWebhookSecrets verify deliveries to /webhooks/gitlab; empty
+	// leaves the endpoint unmounted.
+	GitLabURL            string
+	GitLabToken          string
+	GitLabWebhookSecrets []string
+	// AzureDevOpsToken is a personal access token that opens pull requests
+	// on Azure Repos and pushes their branches.
+	AzureDevOpsToken string
+	// PRSigningKey signs the commits of fix PRs: an OpenSSH or
+	// ASCII-armored OpenPGP private key without a passphrase, set inline or
+	// read from PRSigningKeyFile, such as a mounted secret. PRCommitterName
+	// and PRCommitterEmail make the commits; GitHub shows signed ones as
+	// verified when the key is registered to that email's account.
+	PRSigningKey     string
+	PRSigningKeyFile string
+	PRCommitterName  string
+	PRCommitterEmail string
+	// WorkerToken authenticates workers submitting results through the
+	// /internal endpoints; empty leaves them unmounted.
+	WorkerToken string
+	// FeedToken is accepted as ?token= on the Atom feeds and nowhere else,
+	// for feed readers that cannot send headers; empty accepts none.
+	FeedToken string
+	// Sizing thresholds classify jobs as small, medium or large.
+	SizingSmallMaxMB        int
+	SizingSmallMaxLanguages int
+	SizingLargeMinMB        int
+	SizingLargeMinLanguages int
+	// Outbound proxies per destination: "" uses HTTPS_PROXY and friends,
+	// "direct" none, anything else is the proxy URL.
+	GitHubProxy  string // GitHub and GitLab APIs and git remotes
+	WebhookProxy string // webhook deliveries; never taken from the environment
+	NoProxy      []string
+	// CatalogDir holds <lang>.json finding translations merged over the
+	// built-in catalog.
+	CatalogDir string
+	// DASTAllowedHosts are the deployment hosts scans may probe; empty
+	// disables deployment URLs on scan requests.
+	DASTAllowedHosts []string
+	// ImageRegistries are the registries image scans may pull from; empty
+	// disables image scans.
+	ImageRegistries []string
+	// ImageCredentialsKey seals image scans' registry credentials in the
+	// database; nil refuses scans that come with credentials.
+	ImageCredentialsKey *imagecreds.Key
+	// SkipPaths are non-code path globs: a scan request whose changed_paths
+	// all match is skipped, or narrowed to SkipScanners.
+	SkipPaths    []string
+	SkipScanners []string
+}
+
+// GitHubProxySetting is the proxy for the GitHub and GitLab APIs and git
+// remotes.
+func (c Config) GitHubProxySetting() proxy.Setting {
+	return proxy.Setting{URL: c.GitHubProxy, NoProxy: c.NoProxy}
+}
+
+// WebhookProxySetting is the proxy for webhook deliveries. Unset means
+// direct: deliveries ignore the environment's proxy.
+func (c Config) WebhookProxySetting() proxy.Setting {
+	if c.WebhookProxy == "" {
+		return proxy.Setting{URL: proxy.Direct}
+	}
+	return proxy.Setting{URL: c.WebhookProxy, NoProxy: c.NoProxy}
+}
+
+// Queue backends for queue.backend.
+const (
+	QueueRedis    = "redis"
+	QueuePostgres = "postgres"
+)
+
+func Defaults() Config {
+	return Config{
+		ListenAddr:     ":8080",
+		Token:          DefaultToken,
+		JobQueue:       "ssao:jobs",
+		QueueBackend:   QueueRedis,
+		AllowedHosts:   []string{"github.com"},
+		GitHubURL:      "https://github.com",
+		GitLabURL:      "https://gitlab.com",
+		AdhocMaxMB:     50,
+		MaxCloneMB:     350,
+		LogLevel:       "info",
+		LogFormat:      "text",
+		QueueWarnDepth: 1000,
+		ArtifactStore:  objstore.Config{Region: "us-east-1"},
+
+		PRCommitterName:  "argus[bot]",
+		PRCommitterEmail: "argus[bot]@users.noreply.github.com",
+
+		SizingSmallMaxMB:        25,
+		SizingSmallMaxLanguages: 2,
+		SizingLargeMinMB:        500,
+		SizingLargeMinLanguages: 6,
+
+		SkipPaths: changescope.DefaultSkipPaths,
+	}
+}
+
+// setters bind each of configfile.APIKeys to the field it sets.
+func (c *Config) setters() map[string]func(string) error {
+	return map[string]func(string) error{
+		"server.listen":                  str(&c.ListenAddr),
+		"auth.token":                     str(&c.Token),
+		"auth.admin_token":               str(&c.AdminToken),
+		"auth.feed_token":                str(&c.FeedToken),
+		"database.url":                   str(&c.DatabaseURL),
+		"redis.addr":                     str(&c.RedisAddr),
+		"queue.jobs":                     str(&c.JobQueue),
+		"queue.backend":                  str(&c.QueueBackend),
+		"git.allowed_hosts":              list(&c.AllowedHosts),
+		"limits.adhoc_max_mb":            positive(&c.AdhocMaxMB),
+		"limits.max_clone_mb":            positive(&c.MaxCloneMB),
+		"webhooks.signing_keys":          str(&c.SigningKeys),
+		"log.level":                      str(&c.LogLevel),
+		"log.format":                     str(&c.LogFormat),
+		"health.queue_warn_depth":        positive(&c.QueueWarnDepth),
+		"server.public_url":              str(&c.PublicURL),
+		"artifacts.url_secret":           str(&c.ArtifactURLSecret),
+		"artifacts.s3.endpoint":          str(&c.ArtifactStore.Endpoint),
+		"artifacts.s3.bucket":            str(&c.ArtifactStore.Bucket),
+		"artifacts.s3.region":            str(&c.ArtifactStore.Region),
+		"artifacts.s3.access_key_id":     str(&c.ArtifactStore.AccessKeyID),
+		"artifacts.s3.secret_access_key": str(&c.ArtifactStore.SecretAccessKey),
+		"artifacts.s3.path_style":        boolean(&c.ArtifactStore.PathStyle),
+		"offline.enabled":                boolean(&c.Offline),
+		"auth.worker_token":              str(&c.WorkerToken),
+		"github.url":                     str(&c.GitHubURL),
+		"github.webhook_secret":          list(&c.GitHubWebhookSecrets),
+		"github.alerts_sync_interval":    duration(&c.GitHubAlertsSyncInterval),
+		"gitlab.url":                     str(&c.GitLabURL),
+		"gitlab.token":                   str(&c.GitLabToken),
+		"gitlab.webhook_secret":          list(&c.GitLabWebhookSecrets),
+		"azure_devops.token":             str(&c.AzureDevOpsToken),
+		"pr.signing_key":                 str(&c.PRSigningKey),
+		"pr.signing_key_file":            str(&c.PRSigningKeyFile),
+		"pr.committer_name":              str(&c.PRCommitterName),
+		"pr.committer_email":             str(&c.PRCommitterEmail),
+		"sizing.small_max_mb":            positive(&c.SizingSmallMaxMB),
+		"sizing.small_max_languages":     positive(&c.SizingSmallMaxLanguages),
+		"sizing.large_min_mb":            positive(&c.SizingLargeMinMB),
+		"sizing.large_min_languages":     positive(&c.SizingLargeMinLanguages),
+		"proxy.github":                   proxyURL(&c.GitHubProxy),
+		"proxy.webhooks":                 proxyURL(&c.WebhookProxy),
+		"proxy.no_proxy":                 list(&c.NoProxy),
+		"localization.catalog_dir":       str(&c.CatalogDir),
+		"dast.allowed_hosts":             list(&c.DASTAllowedHosts),
+		"images.allowed_registries":      list(&c.ImageRegistries),
+		"images.credentials_key":         credentialsKey(&c.ImageCredentialsKey),
+		"skip.paths":                     globs(&c.SkipPaths),
+		"skip.scanners":                  list(&c.SkipScanners),
+	}
+}
+
+// Load reads path (if non-empty), applies environment overrides and
+// validates the result; see configfile.Load.
+func Load(path string) (Config, error) {
+	c := Defaults()
+	if err := configfile.Load(path, configfile.APIKeys, configfile.WorkerKeys, c.setters()); err != nil {
+		return Config{}, err
+	}
+	if c.DatabaseURL == "" {
+		return Config{}, fmt.Errorf("database.url (DATABASE_URL) is required")
+	}
+	switch c.QueueBackend {
+	case QueueRedis:
+		if c.RedisAddr == "" {
+			return Config{}, fmt.Errorf("redis.addr (REDIS_ADDR) is required")
+		}
+	case QueuePostgres:
+	default:
+		return Config{}, fmt.Errorf("queue.backend must be %s or %s", QueueRedis, QueuePostgres)
+	}
+	if c.Token == "" {
+		return Config{}, fmt.Errorf("auth.token must not be empty")
+	}
+	if c.FeedToken != "" && c.FeedToken == c.Token {
+		return Config{}, fmt.Errorf("auth.feed_token must differ from auth.token")
+	}
+	if len(c.AllowedHosts) == 0 {
+		return Config{}, fmt.Errorf("git.allowed_hosts must list at least one host")
+	}
+	// The GitHub instance's repos are always allowed, so a GitHub
+	// Enterprise Server needs only github.url.
+	host, err := githubHost(c.GitHubURL)
+	if err != nil {
+		return Config{}, err
+	}
+	c.GitHubURL = strings.TrimSuffix(c.GitHubURL, "/")
+	if !c.HostAllowed(host) {
+		c.AllowedHosts = append(c.AllowedHosts, host)
+	}
+	// Likewise the GitLab instance's, once it is configured.
+	if host, err = gitlabHost(c.GitLabURL); err != nil {
+		return Config{}, err
+	}
+	c.GitLabURL = strings.TrimSuffix(c.GitLabURL, "/")
+	if (c.GitLabToken != "" || len(c.GitLabWebhookSecrets) > 0) && !c.HostAllowed(host) {
+		c.AllowedHosts = append(c.AllowedHosts, host)
+	}
+	if c.AzureDevOpsToken != "" && !c.HostAllowed(forge.AzureDevOps) {
+		c.AllowedHosts = append(c.AllowedHosts, forge.AzureDevOps)
+	}
+	if s := &c.ArtifactStore; s.Enabled() {
+		if s.Endpoint == "" {
+			s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+		}
+		if u, err := url.Parse(s.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("artifacts.s3.endpoint must be an http(s) URL")
+		}
+		if s.Region == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return Config{}, fmt.Errorf("artifacts.s3.bucket needs artifacts.s3.region, artifacts.s3.access_key_id (AWS_ACCESS_KEY_ID) and artifacts.s3.secret_access_key (AWS_SECRET_ACCESS_KEY)")
+		}
+	}
+	if d, _ := time.ParseDuration(c.GitHubAlertsSyncInterval); c.GitHubAlertsSyncInterval != "" && d <= 0 {
+		return Config{}, fmt.Errorf("github.alerts_sync_interval must be positive")
+	}
+	if c.PRSigningKeyFile != "" {
+		if c.PRSigningKey != "" {
+			return Config{}, fmt.Errorf("set pr.signing_key or pr.signing_key_file, not both")
+		}
+		b, err := os.ReadFile(c.PRSigningKeyFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("pr.signing_key_file: %w", err)
+		}
+		c.PRSigningKey = string(b)
+	}
+	if c.PRSigningKey != "" {
+		if _, err := commitsign.Parse(c.PRSigningKey); err != nil {
+			return Config{}, fmt.Errorf("pr.signing_key: %w", err)
+		}
+	}
+	if c.PRCommitterName == "" || c.PRCommitterEmail == "" {
+		return Config{}, fmt.Errorf("pr.committer_name and pr.committer_email must not be empty")
+	}
+	if c.SizingSmallMaxMB > c.SizingLargeMinMB {
+		return Config{}, fmt.Errorf("sizing.small_max_mb must not exceed sizing.large_min_mb")
+	}
+	return c, nil
+}
+
+// githubHost checks github.url and returns its host.
+func githubHost(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil || strings.Trim(u.Path, "/") != "" {
+		return "", fmt.Errorf("github.url must be an https URL without a path, such as https://github.example.com")
+	}
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// gitlabHost checks gitlab.url and returns its host. Unlike github.url it
+// may have a path, for an instance under a relative URL root.
+func gitlabHost(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("gitlab.url must be an https URL, such as https://gitlab.example.com")
+	}
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// HostAllowed reports whether host is one of the configured git hosts.
+func (c Config) HostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range c.AllowedHosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// DASTHostAllowed reports whether host may be scanned as a deployment. An
+// entry with a leading dot matches every subdomain of the rest.
+func (c Config) DASTHostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range c.DASTAllowedHosts {
+		p = strings.ToLower(p)
+		if host == p || strings.HasPrefix(p, ".") && strings.HasSuffix(host, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageRegistryAllowed reports whether image scans may pull from registry,
+// a host with an optional port.
+func (c Config) ImageRegistryAllowed(registry string) bool {
+	registry = strings.ToLower(registry)
+	for _, r := range c.ImageRegistries {
+		if strings.ToLower(r) == registry {
+			return true
+		}
+	}
+	return false
+}
+
+func str(p *string) func(string) error {
+	return func(v string) error {
+		*p = strings.TrimSpace(v)
+		return nil
+	}
+}
+
+func credentialsKey(p **imagecreds.Key) func(string) error {
+	return func(v string) error {
+		k, err := imagecreds.ParseKey(strings.TrimSpace(v))
+		*p = k
+		return err
+	}
+}
+
+func proxyURL(p *string) func(string) error {
+	return func(v string) error {
+		v = strings.TrimSpace(v)
+		if err := proxy.Validate(v); err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	}
+}
+
+func positive(p *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive integer, got %q", v)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func boolean(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", v)
+		}
+		*p = b
+		return nil
+	}
+}
+
+// globs is list without lowercasing, for case-sensitive path globs.
+func globs(p *[]string) func(string) error {
+	return func(v string) error {
+		out := []string{}
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		*p = out
+		return nil
+	}
+}
+
+func duration(p *string) func(string) error {
+	return func(v string) error {
+		v = strings.TrimSpace(v)
+		if _, err := time.ParseDuration(v); err != nil {
+			return fmt.Errorf("must be a duration such as 8m, got %q", v)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func list(p *[]string) func(string) error {
+	return func(v string) error {
+		var out []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+				out = append(out, item)
+			}
+		}
+		*p = out
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+// Package flags resolves feature flags, which gate new scanners and fix
+// types per org and repo so they can be rolled out gradually and rolled
+// back without a redeploy.
+//
+// A flag has a default and optional overrides for orgs and repos; a repo's
+// override wins over its org's, which wins over the default. Flags are
+// named after what they gate: scanner.<name> for a scanner the worker runs
+// and fix.<type> for a fix type the patch engine applies. Something without
+// a flag is not gated.
+package flags
+
+import (
+	"context"
+	"regexp"
+
+	"argus/internal/jobsql"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Prefixes of the flag names the worker and the patch engine consult.
+const (
+	Scanner = "scanner."
+	Fix     = "fix."
+)
+
+// Override scopes.
+const (
+	ScopeOrg  = "org"
+	ScopeRepo = "repo"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*(\.[a-z0-9][a-z0-9_-]*)*$`)
+
+// ValidName reports whether name is a well-formed flag name.
+func ValidName(name string) bool {
+	return len(name) <= 100 && namePattern.MatchString(name)
+}
+
+// Set holds resolved flags.
+type Set map[string]bool
+
+// Enabled reports whether name is on; a flag that does not exist is.
+func (s Set) Enabled(name string) bool {
+	on, ok := s[name]
+	return !ok || on
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ForRepo resolves the flags of repoID; an empty ID gets the defaults.
+func ForRepo(ctx context.Context, db querier, repoID string) (Set, error) {
+	var id any
+	if repoID != "" {
+		id = repoID
+	}
+	s := Set{}
+	err := db.QueryRow(ctx, jobsql.ResolveFlags, id).Scan(&s)
+	return s, err
+}
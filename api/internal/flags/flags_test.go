@@ -0,0 +1,32 @@
+package flags
+
+import "testing"
+
+func TestValidName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"scanner.trufflehog":   true,
+		"fix.secret_redaction": true,
+		"new-ui":               true,
+		"":                     false,
+		"Scanner.semgrep":      false,
+		"scanner.":             false,
+		".fix":                 false,
+		"fix..x":               false,
+		"fix/x":                false,
+	} {
+		if got := ValidName(name); got != want {
+			t.Errorf("ValidName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	s := Set{"scanner.trufflehog": false, "fix.gitignore_env": true}
+	if s.Enabled("scanner.trufflehog") || !s.Enabled("fix.gitignore_env") || !s.Enabled("scanner.semgrep") {
+		t.Fatalf("unexpected resolution for %v", s)
+	}
+	var none Set
+	if !none.Enabled("scanner.semgrep") {
+		t.Fatal("a nil set should gate nothing")
+	}
+}
@@ -0,0 +1,105 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// DependabotAlert is a Dependabot alert on a vulnerable dependency.
+type DependabotAlert struct {
+	Number     int    `json:"number"`
+	State      string `json:"state"` // open, dismissed, fixed or auto_dismissed
+	HTMLURL    string `json:"html_url"`
+	Dependency struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		ManifestPath string `json:"manifest_path"`
+		Scope        string `json:"scope"` // development or runtime
+	} `json:"dependency"`
+	SecurityAdvisory struct {
+		GHSAID      string `json:"ghsa_id"`
+		CVEID       string `json:"cve_id"`
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Severity    string `json:"severity"` // low, medium, high or critical
+		CVSS        struct {
+			VectorString string `json:"vector_string"`
+		} `json:"cvss"`
+		CWEs []struct {
+			CWEID string `json:"cwe_id"`
+		} `json:"cwes"`
+	} `json:"security_advisory"`
+	SecurityVulnerability struct {
+		VulnerableVersionRange string `json:"vulnerable_version_range"`
+		FirstPatchedVersion    *struct {
+			Identifier string `json:"identifier"`
+		} `json:"first_patched_version"`
+	} `json:"security_vulnerability"`
+}
+
+// ListDependabotAlerts lists owner/repo's Dependabot alerts in state, or in
+// every state when state is empty. The installation needs the Dependabot
+// alerts: Read permission.
+func (c *Client) ListDependabotAlerts(ctx context.Context, owner, repo, state, token string) ([]DependabotAlert, error) {
+	q := url.Values{}
+	if state != "" {
+		q.Set("state", state)
+	}
+	return listAll[DependabotAlert](ctx, c, fmt.Sprintf("/repos/%s/%s/dependabot/alerts?%s", owner, repo, q.Encode()), token)
+}
+
+// SecretScanningAlert is a secret GitHub's secret scanning found. The
+// secret itself is never requested.
+type SecretScanningAlert struct {
+	Number     int        `json:"number"`
+	State      string     `json:"state"`      // open or resolved
+	Resolution string     `json:"resolution"` // false_positive, wont_fix, revoked, used_in_tests, ...
+	ResolvedAt *time.Time `json:"resolved_at"`
+	ResolvedBy *struct {
+		Login string `json:"login"`
+	} `json:"resolved_by"`
+	HTMLURL                string `json:"html_url"`
+	SecretType             string `json:"secret_type"`
+	SecretTypeDisplayName  string `json:"secret_type_display_name"`
+	Validity               string `json:"validity"` // active, inactive or unknown
+	PushProtectionBypassed bool   `json:"push_protection_bypassed"`
+}
+
+// SecretLocation is where a secret scanning alert's secret was found.
+type SecretLocation struct {
+	Type    string `json:"type"` // commit for secrets in files; others are issues, wikis and the like
+	Details struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+		CommitSHA string `json:"commit_sha"`
+	} `json:"details"`
+}
+
+// ListSecretScanningAlerts lists owner/repo's secret scanning alerts in
+// state, or in every state when state is empty. The installation needs the
+// Secret scanning alerts: Read permission.
+func (c *Client) ListSecretScanningAlerts(ctx context.Context, owner, repo, state, token string) ([]SecretScanningAlert, error) {
+	q := url.Values{"hide_secret": {"true"}}
+	if state != "" {
+		q.Set("state", state)
+	}
+	return listAll[SecretScanningAlert](ctx, c, fmt.Sprintf("/repos/%s/%s/secret-scanning/alerts?%s", owner, repo, q.Encode()), token)
+}
+
+// FirstSecretLocation returns the first place alert number's secret was
+// found, or nil when GitHub lists none.
+func (c *Client) FirstSecretLocation(ctx context.Context, owner, repo string, number int, token string) (*SecretLocation, error) {
+	var locs []SecretLocation
+	if _, err := c.getPage(ctx, fmt.Sprintf("/repos/%s/%s/secret-scanning/alerts/%d/locations?per_page=1", owner, repo, number), token, &locs); err != nil {
+		return nil, err
+	}
+	if len(locs) == 0 {
+		return nil, nil
+	}
+	return &locs[0], nil
+}
@@ -0,0 +1,85 @@
+package githubapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListDependabotAlertsFollowsLinks(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/web/dependabot/alerts" || r.Header.Get("Authorization") != "Bearer ghs_token" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("state") != "open" || r.URL.Query().Get("per_page") != "100" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("after") == "" {
+			w.Header().Set("Link", `<`+srv.URL+`/repos/acme/web/dependabot/alerts?state=open&per_page=100&after=Y3Vy>; rel="next"`)
+			_, _ = w.Write([]byte(`[{"number":1,"state":"open","dependency":{"package":{"name":"lodash"}}}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"number":2,"state":"open","security_advisory":{"ghsa_id":"GHSA-xxxx","severity":"high"}}]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	alerts, err := c.ListDependabotAlerts(context.Background(), "acme", "web", "open", "ghs_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Dependency.Package.Name != "lodash" || alerts[1].SecurityAdvisory.GHSAID != "GHSA-xxxx" {
+		t.Errorf("alerts = %+v", alerts)
+	}
+}
+
+func TestGetPageStaysOnAPI(t *testing.T) {
+	c := &Client{httpClient: http.DefaultClient, baseURL: "https://api.github.com"}
+	if _, err := c.getPage(context.Background(), "https://evil.test/repos/acme/web/dependabot/alerts", "ghs_token", nil); err == nil {
+		t.Error("getPage followed a link off the API")
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	link := `<https://api.github.com/r?page=1>; rel="prev", <https://api.github.com/r?page=3>; rel="next", <https://api.github.com/r?page=9>; rel="last"`
+	if got := nextLink(link); got != "https://api.github.com/r?page=3" {
+		t.Errorf("nextLink = %q", got)
+	}
+	if got := nextLink(`<https://api.github.com/r?page=1>; rel="prev"`); got != "" {
+		t.Errorf("nextLink on the last page = %q", got)
+	}
+}
+
+func TestListSecretScanningAlerts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/web/secret-scanning/alerts":
+			if r.URL.Query().Get("hide_secret") != "true" || r.URL.Query().Has("state") {
+				t.Errorf("query = %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`[{"number":3,"state":"resolved","resolution":"revoked","resolved_at":"2024-05-01T10:00:00Z","resolved_by":{"login":"octocat"},
+				"secret_type":"github_personal_access_token","secret_type_display_name":"GitHub Personal Access Token"}]`))
+		case "/repos/acme/web/secret-scanning/alerts/3/locations":
+			_, _ = w.Write([]byte(`[{"type":"commit","details":{"path":"config/app.yml","start_line":4,"end_line":4,"commit_sha":"abc123"}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	alerts, err := c.ListSecretScanningAlerts(context.Background(), "acme", "web", "", "ghs_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Resolution != "revoked" || alerts[0].ResolvedAt == nil || alerts[0].ResolvedBy.Login != "octocat" {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	loc, err := c.FirstSecretLocation(context.Background(), "acme", "web", 3, "ghs_token")
+	if err != nil || loc == nil || loc.Details.Path != "config/app.yml" || loc.Details.StartLine != 4 {
+		t.Errorf("FirstSecretLocation = %+v, %v", loc, err)
+	}
+}
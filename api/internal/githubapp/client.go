@@ -2,21 +2,21 @@ package githubapp
 
 import (
 	"bytes"
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
+	"context"
 	"encoding/json"
-	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"argus/internal/apptoken"
+	"argus/internal/forge"
+	"argus/internal/proxy"
 )
 
 type Config struct {
@@ -31,62 +31,60 @@ type Client struct {
 	baseURL    string
 }
 
-func NewFromEnv() (*Client, error) {
+// NewForInstallation builds a client for installation installationID of
+// the app in GITHUB_APP_ID and GITHUB_PRIVATE_KEY_PEM on host, reaching it
+// through p. An installationID of 0 means GITHUB_INSTALLATION_ID, the
+// installation of repos registered without one.
+func NewForInstallation(host forge.GitHub, p proxy.Setting, installationID int64) (*Client, error) {
 	cfg := Config{
 		AppID:          strings.TrimSpace(os.Getenv("GITHUB_APP_ID")),
 		InstallationID: strings.TrimSpace(os.Getenv("GITHUB_INSTALLATION_ID")),
 		PrivateKeyPEM:  os.Getenv("GITHUB_PRIVATE_KEY_PEM"),
 	}
+	if installationID != 0 {
+		cfg.InstallationID = strconv.FormatInt(installationID, 10)
+	}
 	if cfg.AppID == "" || cfg.InstallationID == "" || strings.TrimSpace(cfg.PrivateKeyPEM) == "" {
 		return nil, fmt.Errorf("missing github app env vars")
 	}
+	if err := ValidateGitHubAppIDs(cfg.AppID, cfg.InstallationID); err != nil {
+		return nil, err
+	}
 	return &Client{
-		httpClient: &http.Client{Timeout: 25 * time.Second},
+		httpClient: &http.Client{Timeout: 25 * time.Second, Transport: p.Transport()},
 		cfg:        cfg,
-		baseURL:    "https://api.github.com",
+		baseURL:    host.APIURL(),
 	}, nil
 }
 
-func (c *Client) InstallationToken() (string, error) {
-	jwtToken, err := c.appJWT()
-	if err != nil {
-		return "", err
+// InstallationToken returns a token for the installation, reusing the last
+// one minted until it is about to expire.
+func (c *Client) InstallationToken(ctx context.Context) (string, error) {
+	if token, ok := installationTokens.Get(c.tokenKey(), time.Now()); ok {
+		return token, nil
 	}
-	url := fmt.Sprintf("%s/app/installations/%s/access_tokens", c.baseURL, c.cfg.InstallationID)
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(`{}`)))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Authorization", "Bearer "+jwtToken)
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-
-	resp, err := c.httpClient.Do(req)
+	key, err := apptoken.ParseKey(c.cfg.PrivateKeyPEM)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 300 {
-		return "", fmt.Errorf("token exchange failed: status=%d", resp.StatusCode)
+	appID, _ := strconv.ParseInt(c.cfg.AppID, 10, 64)
+	tok, err := apptoken.Mint(ctx, c.httpClient, c.baseURL, appID, key, c.InstallationID())
+	var xe *apptoken.ExchangeError
+	if errors.As(err, &xe) {
+		return "", fmt.Errorf("token exchange failed: %w", newAPIError(xe.StatusCode, xe.Header, xe.Body, time.Now()))
 	}
-	var out struct {
-		Token string `json:"token"`
-	}
-	if err := json.Unmarshal(body, &out); err != nil {
+	if err != nil {
 		return "", err
 	}
-	if out.Token == "" {
-		return "", fmt.Errorf("empty installation token")
-	}
-	return out.Token, nil
+	installationTokens.Put(c.tokenKey(), tok)
+	return tok.Value, nil
 }
 
-func (c *Client) GetDefaultBranch(owner, repo, token string) (string, error) {
+func (c *Client) GetDefaultBranch(ctx context.Context, owner, repo, token string) (string, error) {
 	var out struct {
 		DefaultBranch string `json:"default_branch"`
 	}
-	if err := c.getJSON(fmt.Sprintf("/repos/%s/%s", owner, repo), token, &out); err != nil {
+	if err := c.getJSON(ctx, fmt.Sprintf("/repos/%s/%s", owner, repo), token, &out); err != nil {
 		return "", err
 	}
 	if out.DefaultBranch == "" {
@@ -95,13 +93,13 @@ func (c *Client) GetDefaultBranch(owner, repo, token string) (string, error) {
 	return out.DefaultBranch, nil
 }
 
-func (c *Client) GetBranchSHA(owner, repo, branch, token string) (string, error) {
+func (c *Client) GetBranchSHA(ctx context.Context, owner, repo, branch, token string) (string, error) {
 	var out struct {
 		Object struct {
 			SHA string `json:"sha"`
 		} `json:"object"`
 	}
-	if err := c.getJSON(fmt.Sprintf("/repos/%s/%s/git/ref/heads/%s", owner, repo, branch), token, &out); err != nil {
+	if err := c.getJSON(ctx, fmt.Sprintf("/repos/%s/%s/git/ref/heads/%s", owner, repo, branch), token, &out); err != nil {
 		return "", err
 	}
 	if out.Object.SHA == "" {
@@ -110,87 +108,94 @@ func (c *Client) GetBranchSHA(owner, repo, branch, token string) (string, error)
 	return out.Object.SHA, nil
 }
 
-func (c *Client) CreateRef(owner, repo, ref, sha, token string) error {
+func (c *Client) CreateRef(ctx context.Context, owner, repo, ref, sha, token string) error {
 	payload := map[string]string{"ref": ref, "sha": sha}
-	return c.postJSON(fmt.Sprintf("/repos/%s/%s/git/refs", owner, repo), token, payload, nil)
+	return c.postJSON(ctx, fmt.Sprintf("/repos/%s/%s/git/refs", owner, repo), token, payload, nil)
 }
 
-func (c *Client) CreateOrUpdateContent(owner, repo, path, message, contentB64, branch, token string) error {
+func (c *Client) CreateOrUpdateContent(ctx context.Context, owner, repo, path, message, contentB64, branch, token string) error {
 	payload := map[string]string{
 		"message": message,
 		"content": contentB64,
 		"branch":  branch,
 	}
-	return c.putJSON(fmt.Sprintf("/repos/%s/%s/contents/%s", owner, repo, path), token, payload, nil)
+	return c.putJSON(ctx, fmt.Sprintf("/repos/%s/%s/contents/%s", owner, repo, path), token, payload, nil)
 }
 
-func (c *Client) CreatePullRequest(owner, repo, title, head, base, body, token string) (string, error) {
-	payload := map[string]string{"title": title, "head": head, "base": base, "body": body}
-	var out struct {
-		HTMLURL string `json:"html_url"`
-	}
-	if err := c.postJSON(fmt.Sprintf("/repos/%s/%s/pulls", owner, repo), token, payload, &out); err != nil {
-		return "", err
+// NewPullRequest is a pull request to open.
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Body  string `json:"body"`
+	// Draft opens it as a draft, which cannot be merged until marked ready
+	// for review.
+	Draft bool `json:"draft,omitempty"`
+}
+
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo string, pr NewPullRequest, token string) (PullRequest, error) {
+	var out PullRequest
+	if err := c.postJSON(ctx, fmt.Sprintf("/repos/%s/%s/pulls", owner, repo), token, pr, &out); err != nil {
+		return PullRequest{}, err
 	}
-	return out.HTMLURL, nil
+	return out, nil
 }
 
-func (c *Client) CreateIssueComment(owner, repo string, number int, comment, token string) error {
-	payload := map[string]string{"body": comment}
-	return c.postJSON(fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, number), token, payload, nil)
+// EditPullRequestBody replaces the description of pull request number.
+func (c *Client) EditPullRequestBody(ctx context.Context, owner, repo string, number int, body, token string) error {
+	return c.patchJSON(ctx, fmt.Sprintf("/repos/%s/%s/pulls/%d", owner, repo, number), token, map[string]string{"body": body}, nil)
 }
 
-func (c *Client) appJWT() (string, error) {
-	block, _ := pem.Decode([]byte(c.cfg.PrivateKeyPEM))
-	if block == nil {
-		return "", fmt.Errorf("invalid private key pem")
-	}
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		pkcs8, err2 := x509.ParsePKCS8PrivateKey(block.Bytes)
-		if err2 != nil {
-			return "", err
-		}
-		k, ok := pkcs8.(*rsa.PrivateKey)
-		if !ok {
-			return "", fmt.Errorf("private key is not RSA")
-		}
-		key = k
-	}
+// AddLabels adds labels to issue or pull request number, creating those
+// the repo does not have yet.
+func (c *Client) AddLabels(ctx context.Context, owner, repo string, number int, labels []string, token string) error {
+	return c.postJSON(ctx, fmt.Sprintf("/repos/%s/%s/issues/%d/labels", owner, repo, number), token, map[string][]string{"labels": labels}, nil)
+}
 
-	now := time.Now().Unix()
-	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
-	payload := fmt.Sprintf(`{"iat":%d,"exp":%d,"iss":"%s"}`, now-30, now+540, c.cfg.AppID)
-	payloadEnc := base64.RawURLEncoding.EncodeToString([]byte(payload))
-	signingInput := header + "." + payloadEnc
-	h := sha256.Sum256([]byte(signingInput))
-	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+// RequestReviewers requests reviews of pull request number from users and
+// from teams, given by slug. Both must be able to access the repo.
+func (c *Client) RequestReviewers(ctx context.Context, owner, repo string, number int, users, teams []string, token string) error {
+	payload := struct {
+		Reviewers     []string `json:"reviewers,omitempty"`
+		TeamReviewers []string `json:"team_reviewers,omitempty"`
+	}{users, teams}
+	return c.postJSON(ctx, fmt.Sprintf("/repos/%s/%s/pulls/%d/requested_reviewers", owner, repo, number), token, payload, nil)
+}
+
+func (c *Client) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment, token string) error {
+	payload := map[string]string{"body": comment}
+	return c.postJSON(ctx, fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, number), token, payload, nil)
+}
+
+func (c *Client) getJSON(ctx context.Context, path, token string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+	return c.do(req, token, out)
 }
 
-func (c *Client) getJSON(path, token string, out any) error {
-	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+func (c *Client) postJSON(ctx context.Context, path, token string, payload, out any) error {
+	b, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
 	return c.do(req, token, out)
 }
 
-func (c *Client) postJSON(path, token string, payload, out any) error {
+func (c *Client) putJSON(ctx context.Context, path, token string, payload, out any) error {
 	b, _ := json.Marshal(payload)
-	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(b))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL+path, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
 	return c.do(req, token, out)
 }
 
-func (c *Client) putJSON(path, token string, payload, out any) error {
+func (c *Client) patchJSON(ctx context.Context, path, token string, payload, out any) error {
 	b, _ := json.Marshal(payload)
-	req, err := http.NewRequest(http.MethodPut, c.baseURL+path, bytes.NewReader(b))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.baseURL+path, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
@@ -198,6 +203,96 @@ func (c *Client) putJSON(path, token string, payload, out any) error {
 }
 
 func (c *Client) do(req *http.Request, token string, out any) error {
+	_, err := c.send(req, token, out)
+	return err
+}
+
+// getPage gets one page of a list endpoint at path, or at the absolute URL
+// a previous page linked to, and returns the URL of the next page, if any.
+func (c *Client) getPage(ctx context.Context, path, token string, out any) (string, error) {
+	u := path
+	if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		u = c.baseURL + path
+	} else if !strings.HasPrefix(u, c.baseURL+"/") {
+		// Never hand the token to another host.
+		return "", fmt.Errorf("next page %s is not on the github api", u)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	h, err := c.send(req, token, out)
+	if err != nil {
+		return "", err
+	}
+	return nextLink(h.Get("Link")), nil
+}
+
+// nextLink returns the rel="next" URL of a Link header.
+func nextLink(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// send sends req with token and decodes the response into out when
+// non-nil, returning the response headers. A cached token GitHub refuses,
+// say because it was revoked, is dropped and the call retried once with a
+// fresh one; rate limits, server errors and network failures are retried
+// as retryWait allows, unless the request's context ends first.
+func (c *Client) send(req *http.Request, token string, out any) (http.Header, error) {
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		resp, body, err := c.roundTrip(req, token)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !refreshed && c.dropToken(token) {
+			if token, err = c.InstallationToken(req.Context()); err != nil {
+				return nil, err
+			}
+			refreshed = true
+			continue
+		}
+		if err == nil && resp.StatusCode >= 300 {
+			err = newAPIError(resp.StatusCode, resp.Header, body, time.Now())
+		}
+		if err != nil {
+			wait, ok := retryWait(req, attempt, err)
+			if !ok {
+				return nil, err
+			}
+			if err := sleep(req.Context(), wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if out != nil && len(body) > 0 {
+			if err := json.Unmarshal(body, out); err != nil {
+				return nil, err
+			}
+		}
+		return resp.Header, nil
+	}
+}
+
+// dropToken forgets token if it is the installation's cached one.
+func (c *Client) dropToken(token string) bool {
+	key, ok := installationTokens.Drop(token)
+	return ok && key == c.tokenKey()
+}
+
+// roundTrip sends req with token and reads the whole response. A request
+// with a body is sent with a fresh copy of it, so it can be sent again.
+func (c *Client) roundTrip(req *http.Request, token string) (*http.Response, []byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Body = body
+	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
@@ -205,25 +300,24 @@ func (c *Client) do(req *http.Request, token string, out any) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("github api call failed status=%d", resp.StatusCode)
-	}
-	if out != nil && len(body) > 0 {
-		if err := json.Unmarshal(body, out); err != nil {
-			return err
-		}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	return resp, body, nil
 }
 
+// ParseGitHubURL splits a repo URL on github.com or an Enterprise Server
+// into owner and name.
 func ParseGitHubURL(raw string) (owner, repo string, err error) {
-	u := strings.TrimSpace(raw)
-	u = strings.TrimPrefix(u, "https://github.com/")
-	parts := strings.Split(u, "/")
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid github url")
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
 	if len(parts) < 2 {
 		return "", "", fmt.Errorf("invalid github url")
 	}
@@ -0,0 +1,122 @@
+package githubapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is a GitHub API call GitHub answered with an error status. It
+// carries the message and validation errors of GitHub's error body so
+// callers see why a call failed, not just that it did.
+type APIError struct {
+	StatusCode       int
+	Message          string
+	DocumentationURL string
+	// Errors are GitHub's validation errors, such as "A pull request
+	// already exists for acme:argus/fix".
+	Errors []string
+	// RetryAfter is how long GitHub asked to wait before trying again, when
+	// the call was rate limited. A limit that names no wait, as secondary
+	// limits often do not, asks for secondaryRateLimitWait.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("github api call failed status=%d", e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if len(e.Errors) > 0 {
+		msg += " (" + strings.Join(e.Errors, "; ") + ")"
+	}
+	return msg
+}
+
+// RateLimited reports whether GitHub refused the call for exceeding its
+// primary or secondary rate limit rather than for the call itself.
+func (e *APIError) RateLimited() bool {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return e.StatusCode == http.StatusForbidden && (e.RetryAfter > 0 || strings.Contains(strings.ToLower(e.Message), "rate limit"))
+}
+
+// secondaryRateLimitWait is the wait GitHub's docs ask for after a rate
+// limited response that names none.
+const secondaryRateLimitWait = time.Minute
+
+// maxErrorMessage bounds the message kept from a body that is not GitHub's
+// JSON error, such as a proxy's HTML page.
+const maxErrorMessage = 200
+
+// newAPIError builds the error for a response with status, header and body.
+func newAPIError(status int, header http.Header, body []byte, now time.Time) *APIError {
+	e := &APIError{StatusCode: status, RetryAfter: retryAfter(header, now)}
+	e.decodeBody(body)
+	if e.RetryAfter == 0 && e.RateLimited() {
+		e.RetryAfter = secondaryRateLimitWait
+	}
+	return e
+}
+
+// decodeBody fills in the message and validation errors of GitHub's error
+// body, or keeps the start of a body that is not one as the message.
+func (e *APIError) decodeBody(body []byte) {
+	var out struct {
+		Message          string            `json:"message"`
+		DocumentationURL string            `json:"documentation_url"`
+		Errors           []json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		e.Message = strings.TrimSpace(string(body))
+		if len(e.Message) > maxErrorMessage {
+			e.Message = e.Message[:maxErrorMessage] + "..."
+		}
+		return
+	}
+	e.Message, e.DocumentationURL = out.Message, out.DocumentationURL
+	for _, raw := range out.Errors {
+		// Errors are objects on most endpoints and plain strings on a few.
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			e.Errors = append(e.Errors, s)
+			continue
+		}
+		var d struct {
+			Resource string `json:"resource"`
+			Field    string `json:"field"`
+			Code     string `json:"code"`
+			Message  string `json:"message"`
+		}
+		if json.Unmarshal(raw, &d) != nil {
+			continue
+		}
+		switch {
+		case d.Message != "":
+			e.Errors = append(e.Errors, d.Message)
+		case d.Code != "":
+			e.Errors = append(e.Errors, strings.Join(strings.Fields(d.Resource+" "+d.Field+" "+d.Code), " "))
+		}
+	}
+}
+
+// retryAfter is the wait a rate-limited response asks for: its Retry-After
+// seconds, or the time until X-RateLimit-Reset once X-RateLimit-Remaining
+// is spent. It is 0 when the response asks for neither.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(strings.TrimSpace(header.Get("Retry-After"))); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return 0
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return max(time.Unix(reset, 0).Sub(now), 0)
+}
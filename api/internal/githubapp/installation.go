@@ -0,0 +1,32 @@
+package githubapp
+
+import (
+	"context"
+	"strconv"
+)
+
+// InstallationRepo is a repo the app installation can access.
+type InstallationRepo struct {
+	FullName      string `json:"full_name"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	Archived      bool   `json:"archived"`
+}
+
+// InstallationID is the installation the client mints tokens for.
+func (c *Client) InstallationID() int64 {
+	id, _ := strconv.ParseInt(c.cfg.InstallationID, 10, 64)
+	return id
+}
+
+// installationReposPage is a page of /installation/repositories.
+type installationReposPage struct {
+	Repositories []InstallationRepo `json:"repositories"`
+}
+
+// ListInstallationRepos lists every repo the installation token can
+// access.
+func (c *Client) ListInstallationRepos(ctx context.Context, token string) ([]InstallationRepo, error) {
+	return listPages(ctx, c, "/installation/repositories", token, func(p installationReposPage) []InstallationRepo { return p.Repositories })
+}
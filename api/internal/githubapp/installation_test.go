@@ -0,0 +1,61 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestListInstallationRepos(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/installation/repositories" || r.Header.Get("Authorization") != "Bearer ghs_token" {
+			http.NotFound(w, r)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/installation/repositories?per_page=100&page=2>; rel="next", <%[1]s/installation/repositories?per_page=100&page=2>; rel="last"`, srv.URL))
+			_, _ = w.Write([]byte(`{"total_count":2,"repositories":[{"full_name":"acme/web","default_branch":"main"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"total_count":2,"repositories":[{"full_name":"acme/old","archived":true}]}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL, cfg: Config{InstallationID: "42"}}
+	repos, err := c.ListInstallationRepos(context.Background(), "ghs_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].FullName != "acme/web" || repos[0].DefaultBranch != "main" || !repos[1].Archived {
+		t.Errorf("repos = %+v", repos)
+	}
+	if c.InstallationID() != 42 {
+		t.Errorf("InstallationID = %d", c.InstallationID())
+	}
+}
+
+func TestNewForInstallation(t *testing.T) {
+	t.Setenv("GITHUB_APP_ID", "7")
+	t.Setenv("GITHUB_PRIVATE_KEY_PEM", "pem")
+	t.Setenv("GITHUB_INSTALLATION_ID", "42")
+	for id, want := range map[int64]int64{0: 42, 99: 99} {
+		c, err := NewForInstallation(forge.GitHubDotCom, proxy.Setting{URL: proxy.Direct}, id)
+		if err != nil || c.InstallationID() != want {
+			t.Errorf("NewForInstallation(%d) = %+v, %v", id, c, err)
+		}
+	}
+	t.Setenv("GITHUB_INSTALLATION_ID", "")
+	if _, err := NewForInstallation(forge.GitHubDotCom, proxy.Setting{URL: proxy.Direct}, 0); err == nil {
+		t.Error("expected a repo without an installation to need GITHUB_INSTALLATION_ID")
+	}
+	if c, err := NewForInstallation(forge.GitHubDotCom, proxy.Setting{URL: proxy.Direct}, 99); err != nil || c.InstallationID() != 99 {
+		t.Errorf("NewForInstallation without GITHUB_INSTALLATION_ID = %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,106 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// perPage is the largest page GitHub's list endpoints return.
+const perPage = 100
+
+// listAll reads every page of list endpoint path, whose pages are JSON
+// arrays, following the Link headers GitHub sends.
+func listAll[T any](ctx context.Context, c *Client, path, token string) ([]T, error) {
+	return listPages(ctx, c, path, token, func(page []T) []T { return page })
+}
+
+// listPages reads every page of list endpoint path, following the Link
+// headers GitHub sends, and collects the items of each page P. It is for
+// endpoints that wrap the list, such as {"total_count":..,"repositories":[..]}.
+func listPages[P, T any](ctx context.Context, c *Client, path, token string, items func(P) []T) ([]T, error) {
+	var all []T
+	next := withPerPage(path)
+	for next != "" {
+		var page P
+		var err error
+		if next, err = c.getPage(ctx, next, token, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, items(page)...)
+	}
+	return all, nil
+}
+
+// withPerPage asks for full pages unless path already sets a page size.
+func withPerPage(path string) string {
+	base, query, _ := strings.Cut(path, "?")
+	q, err := url.ParseQuery(query)
+	if err != nil || q.Has("per_page") {
+		return path
+	}
+	q.Set("per_page", fmt.Sprint(perPage))
+	return base + "?" + q.Encode()
+}
+
+// Branch is a repo branch and the commit it points at.
+type Branch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+	Protected bool `json:"protected"`
+}
+
+// ListBranches lists owner/repo's branches.
+func (c *Client) ListBranches(ctx context.Context, owner, repo, token string) ([]Branch, error) {
+	return listAll[Branch](ctx, c, fmt.Sprintf("/repos/%s/%s/branches", owner, repo), token)
+}
+
+// PullRequest is a pull request as GitHub lists them.
+type PullRequest struct {
+	Number  int    `json:"number"`
+	State   string `json:"state"` // open or closed
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	Draft   bool   `json:"draft"`
+	Head    struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// ListPullRequests lists owner/repo's pull requests in state (open, closed
+// or all; GitHub defaults to open), only those from branch head when head
+// is set as owner:branch.
+func (c *Client) ListPullRequests(ctx context.Context, owner, repo, state, head, token string) ([]PullRequest, error) {
+	q := url.Values{}
+	if state != "" {
+		q.Set("state", state)
+	}
+	if head != "" {
+		q.Set("head", head)
+	}
+	return listAll[PullRequest](ctx, c, fmt.Sprintf("/repos/%s/%s/pulls?%s", owner, repo, q.Encode()), token)
+}
+
+// IssueComment is a comment on an issue or on a pull request's
+// conversation.
+type IssueComment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// ListIssueComments lists the comments on issue or pull request number.
+func (c *Client) ListIssueComments(ctx context.Context, owner, repo string, number int, token string) ([]IssueComment, error) {
+	return listAll[IssueComment](ctx, c, fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, number), token)
+}
@@ -0,0 +1,52 @@
+package githubapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListPullRequestsFollowsLinks(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/web/pulls" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("head") != "acme:argus/fix" || q.Get("state") != "open" || q.Get("per_page") != "100" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		switch q.Get("page") {
+		case "":
+			w.Header().Set("Link", `<`+srv.URL+`/repos/acme/web/pulls?head=acme%3Aargus%2Ffix&state=open&per_page=100&page=2>; rel="next"`)
+			_, _ = w.Write([]byte(`[{"number":1,"head":{"ref":"argus/fix"}}]`))
+		case "2":
+			w.Header().Set("Link", `<`+srv.URL+`/repos/acme/web/pulls?head=acme%3Aargus%2Ffix&state=open&per_page=100&page=1>; rel="prev"`)
+			_, _ = w.Write([]byte(`[{"number":2,"head":{"ref":"argus/fix"}}]`))
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	prs, err := c.ListPullRequests(context.Background(), "acme", "web", "open", "acme:argus/fix", "ghs_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 2 || prs[0].Number != 1 || prs[1].Number != 2 || prs[1].Head.Ref != "argus/fix" {
+		t.Errorf("prs = %+v", prs)
+	}
+}
+
+func TestWithPerPage(t *testing.T) {
+	for path, want := range map[string]string{
+		"/repos/acme/web/branches":         "/repos/acme/web/branches?per_page=100",
+		"/repos/acme/web/pulls?state=all":  "/repos/acme/web/pulls?per_page=100&state=all",
+		"/repos/acme/web/pulls?per_page=5": "/repos/acme/web/pulls?per_page=5",
+	} {
+		if got := withPerPage(path); got != want {
+			t.Errorf("withPerPage(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
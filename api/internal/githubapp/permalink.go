@@ -0,0 +1,36 @@
+package githubapp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Permalink returns a blob URL pinned to commit sha, e.g.
+// https://github.com/o/r/blob/<sha>/src/app.go#L10-L12. It works for any
+// GitHub-compatible host (including Enterprise Server) and returns "" when
+// the repo URL, sha or path is missing.
+func Permalink(repoURL, sha, path string, lineStart, lineEnd int) string {
+	repoURL = strings.TrimSpace(repoURL)
+	sha = strings.TrimSpace(sha)
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if repoURL == "" || sha == "" || path == "" {
+		return ""
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return ""
+	}
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	link := fmt.Sprintf("%s://%s%s/blob/%s/%s", u.Scheme, u.Host, strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git"), url.PathEscape(sha), strings.Join(segs, "/"))
+	switch {
+	case lineStart > 0 && lineEnd > lineStart:
+		link += fmt.Sprintf("#L%d-L%d", lineStart, lineEnd)
+	case lineStart > 0:
+		link += fmt.Sprintf("#L%d", lineStart)
+	}
+	return link
+}
@@ -0,0 +1,25 @@
+package githubapp
+
+import "testing"
+
+func TestPermalink(t *testing.T) {
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	cases := []struct {
+		url, path  string
+		start, end int
+		want       string
+	}{
+		{"https://github.com/acme/api.git", "cmd/main.go", 10, 12, "https://github.com/acme/api/blob/" + sha + "/cmd/main.go#L10-L12"},
+		{"https://github.com/acme/api.git", "cmd/main.go", 7, 7, "https://github.com/acme/api/blob/" + sha + "/cmd/main.go#L7"},
+		{"https://ghe.example.com/acme/api.git", "dir with space/a#b.go", 0, 0, "https://ghe.example.com/acme/api/blob/" + sha + "/dir%20with%20space/a%23b.go"},
+		{"https://github.com/acme/api.git", "", 1, 1, ""},
+	}
+	for _, c := range cases {
+		if got := Permalink(c.url, sha, c.path, c.start, c.end); got != c.want {
+			t.Fatalf("Permalink(%q, %q) = %q, want %q", c.url, c.path, got, c.want)
+		}
+	}
+	if got := Permalink("https://github.com/acme/api.git", "", "a.go", 1, 1); got != "" {
+		t.Fatalf("expected empty permalink without sha, got %q", got)
+	}
+}
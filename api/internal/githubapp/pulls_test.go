@@ -0,0 +1,57 @@
+package githubapp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateDraftPullRequestWithReviewers(t *testing.T) {
+	got := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got[r.URL.Path] = body
+		switch r.URL.Path {
+		case "/repos/acme/web/pulls":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"number":12,"html_url":"https://github.com/acme/web/pull/12","draft":true}`))
+		case "/repos/acme/web/issues/12/labels", "/repos/acme/web/pulls/12/requested_reviewers":
+			w.WriteHeader(http.StatusCreated)
+		case "/repos/acme/web/pulls/12":
+			if r.Method != http.MethodPatch {
+				http.NotFound(w, r)
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	pr, err := c.CreatePullRequest(context.Background(), "acme", "web", NewPullRequest{Title: "Argus: Fix findings", Head: "argus/fix", Base: "main", Draft: true}, "ghs_token")
+	if err != nil || pr.Number != 12 || pr.HTMLURL != "https://github.com/acme/web/pull/12" {
+		t.Fatalf("CreatePullRequest = %+v, %v", pr, err)
+	}
+	if body := got["/repos/acme/web/pulls"]; body["draft"] != true || body["head"] != "argus/fix" {
+		t.Errorf("pull request = %v", body)
+	}
+	if err := c.AddLabels(context.Background(), "acme", "web", 12, []string{"security", "automated"}, "ghs_token"); err != nil {
+		t.Fatal(err)
+	}
+	if labels, _ := got["/repos/acme/web/issues/12/labels"]["labels"].([]any); len(labels) != 2 {
+		t.Errorf("labels = %v", got["/repos/acme/web/issues/12/labels"])
+	}
+	if err := c.RequestReviewers(context.Background(), "acme", "web", 12, nil, []string{"appsec"}, "ghs_token"); err != nil {
+		t.Fatal(err)
+	}
+	if body := got["/repos/acme/web/pulls/12/requested_reviewers"]; body["reviewers"] != nil || body["team_reviewers"] == nil {
+		t.Errorf("review request = %v", body)
+	}
+	if err := c.EditPullRequestBody(context.Background(), "acme", "web", 12, "new body", "ghs_token"); err != nil || got["/repos/acme/web/pulls/12"]["body"] != "new body" {
+		t.Errorf("EditPullRequestBody = %v, request %v", err, got["/repos/acme/web/pulls/12"])
+	}
+}
@@ -0,0 +1,73 @@
+package githubapp
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxAttempts bounds how often one call is sent.
+	maxAttempts = 4
+	// retryBase is the backoff before the first retry; it doubles after
+	// each further attempt.
+	retryBase = 500 * time.Millisecond
+	// maxRetryWait is the longest the client waits before a retry. Calls
+	// run inside API requests, so a rate limit that resets later than this
+	// is returned to the caller instead.
+	maxRetryWait = 30 * time.Second
+)
+
+// sleep waits d between attempts, or until ctx is done; tests replace it.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// idempotent reports whether sending a request with method twice has the
+// effect of sending it once, so one that may have reached GitHub can be
+// sent again.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryWait returns how long to wait before sending req again after
+// attempt failed with err, or false when it should not be retried. A rate
+// limited call is retried whatever its method, since GitHub did not act on
+// it; server errors and network failures only for idempotent methods. A
+// secondary limit that names no wait asks for a minute, more than
+// maxRetryWait, so it goes back to the caller rather than being retried
+// early.
+func retryWait(req *http.Request, attempt int, err error) (time.Duration, bool) {
+	if attempt >= maxAttempts || (req.Body != nil && req.GetBody == nil) {
+		return 0, false
+	}
+	backoff := retryBase << (attempt - 1)
+	// Jitter keeps clients that failed together from retrying together.
+	jittered := backoff/2 + rand.N(backoff/2+1)
+	apiErr, ok := err.(*APIError)
+	switch {
+	case ok && apiErr.RateLimited():
+		if apiErr.RetryAfter > maxRetryWait {
+			return 0, false
+		}
+		return max(apiErr.RetryAfter, jittered), true
+	case !idempotent(req.Method):
+		return 0, false
+	case ok:
+		return jittered, apiErr.StatusCode >= 500
+	default:
+		return jittered, true
+	}
+}
@@ -0,0 +1,148 @@
+package githubapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// noSleep records the waits send asks for instead of sleeping.
+func noSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+func TestSendRetriesServerErrors(t *testing.T) {
+	waits := noSleep(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"default_branch":"main"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	branch, err := c.GetDefaultBranch(context.Background(), "acme", "web", "ghs_token")
+	if err != nil || branch != "main" {
+		t.Fatalf("GetDefaultBranch = %q, %v", branch, err)
+	}
+	if len(*waits) != 2 || (*waits)[0] < retryBase/2 || (*waits)[1] < retryBase {
+		t.Errorf("waits = %v", *waits)
+	}
+
+	// A POST that may have reached GitHub is not sent twice.
+	calls.Store(0)
+	err = c.CreateIssueComment(context.Background(), "acme", "web", 1, "hi", "ghs_token")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Errorf("CreateIssueComment = %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestSendHonorsRateLimits(t *testing.T) {
+	waits := noSleep(t)
+	var calls atomic.Int32
+	retryAfterSecs := 3
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSecs))
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"You have exceeded a secondary rate limit."}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	if err := c.CreateIssueComment(context.Background(), "acme", "web", 1, "hi", "ghs_token"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*waits) != 1 || (*waits)[0] < 3*time.Second {
+		t.Errorf("waits = %v", *waits)
+	}
+
+	// A limit that resets too far out is left to the caller.
+	calls.Store(0)
+	retryAfterSecs = 600
+	err := c.CreateIssueComment(context.Background(), "acme", "web", 1, "hi", "ghs_token")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.RateLimited() || apiErr.RetryAfter != 10*time.Minute || calls.Load() != 1 {
+		t.Errorf("CreateIssueComment = %v after %d calls", err, calls.Load())
+	}
+
+	// A secondary limit that names no wait asks for a minute, so it is not
+	// retried early either.
+	calls.Store(0)
+	retryAfterSecs = 0
+	err = c.CreateIssueComment(context.Background(), "acme", "web", 1, "hi", "ghs_token")
+	if !errors.As(err, &apiErr) || !apiErr.RateLimited() || apiErr.RetryAfter != time.Minute || calls.Load() != 1 {
+		t.Errorf("CreateIssueComment = %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestSendStopsWaitingWhenCanceled(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL}
+	start := time.Now()
+	_, err := c.GetDefaultBranch(ctx, "acme", "web", "ghs_token")
+	if !errors.Is(err, context.DeadlineExceeded) || calls.Load() != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("GetDefaultBranch = %v after %d calls and %v", err, calls.Load(), time.Since(start))
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	body := []byte(`{"message":"Validation Failed","errors":[{"resource":"PullRequest","code":"custom","message":"A pull request already exists for acme:argus/fix."},
+		{"resource":"Issue","field":"title","code":"missing_field"},"plain"],"documentation_url":"https://docs.github.com/rest"}`)
+	e := newAPIError(http.StatusUnprocessableEntity, http.Header{}, body, time.Now())
+	want := "github api call failed status=422: Validation Failed (A pull request already exists for acme:argus/fix.; Issue title missing_field; plain)"
+	if e.Error() != want || e.DocumentationURL != "https://docs.github.com/rest" || e.RateLimited() {
+		t.Errorf("error = %q, %+v", e.Error(), e)
+	}
+	if e := newAPIError(http.StatusBadGateway, http.Header{}, []byte("<html>bad gateway</html>"), time.Now()); e.Message != "<html>bad gateway</html>" {
+		t.Errorf("message from a non-JSON body = %q", e.Message)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	h := http.Header{}
+	h.Set("X-RateLimit-Remaining", "0")
+	h.Set("X-RateLimit-Reset", "1700000042")
+	if got := retryAfter(h, now); got != 42*time.Second {
+		t.Errorf("retryAfter at the reset = %v", got)
+	}
+	h.Set("Retry-After", "5")
+	if got := retryAfter(h, now); got != 5*time.Second {
+		t.Errorf("retryAfter with Retry-After = %v", got)
+	}
+	h = http.Header{}
+	h.Set("X-RateLimit-Remaining", "12")
+	h.Set("X-RateLimit-Reset", "1700000042")
+	if got := retryAfter(h, now); got != 0 {
+		t.Errorf("retryAfter with calls left = %v", got)
+	}
+}
@@ -0,0 +1,21 @@
+package githubapp
+
+import (
+	"time"
+
+	"argus/internal/apptoken"
+)
+
+// tokenMargin is how long before it expires a cached installation token
+// is replaced, so a call started with it does not outlive it.
+const tokenMargin = 5 * time.Minute
+
+// installationTokens caches tokens across clients, which handlers build per
+// request, so the PR path and the alert sync mint one an hour rather than
+// one per call. They are keyed by API URL, app and installation.
+var installationTokens = apptoken.Cache[string]{Margin: tokenMargin}
+
+// tokenKey is the client's installation's key in installationTokens.
+func (c *Client) tokenKey() string {
+	return c.baseURL + "|" + c.cfg.AppID + "|" + c.cfg.InstallationID
+}
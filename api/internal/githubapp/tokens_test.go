@@ -0,0 +1,61 @@
+package githubapp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInstallationTokenIsCached(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	var minted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			n := minted.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, n, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case "/repos/acme/web":
+			// The first token has been revoked.
+			if r.Header.Get("Authorization") == "Bearer ghs_1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"default_branch":"main"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: srv.Client(), baseURL: srv.URL, cfg: Config{AppID: "7", InstallationID: "42", PrivateKeyPEM: keyPEM}}
+	for range 2 {
+		if token, err := c.InstallationToken(context.Background()); err != nil || token != "ghs_1" {
+			t.Fatalf("InstallationToken = %q, %v", token, err)
+		}
+	}
+	if minted.Load() != 1 {
+		t.Errorf("minted %d tokens, want 1", minted.Load())
+	}
+
+	branch, err := c.GetDefaultBranch(context.Background(), "acme", "web", "ghs_1")
+	if err != nil || branch != "main" {
+		t.Fatalf("GetDefaultBranch = %q, %v", branch, err)
+	}
+	if token, _ := c.InstallationToken(context.Background()); token != "ghs_2" || minted.Load() != 2 {
+		t.Errorf("after a 401 the cached token is %q after %d mints", token, minted.Load())
+	}
+}
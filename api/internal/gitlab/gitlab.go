@@ -0,0 +1,150 @@
+// Package gitlab calls the REST API of gitlab.com or a self-managed GitLab
+// with an access token: it looks up projects and opens the merge requests
+// fixes are proposed in.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+// Client calls one instance's REST API with a personal, group or project
+// access token.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// New returns a client for host that authenticates with token and reaches
+// the instance through p.
+func New(host forge.GitLab, token string, p proxy.Setting) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 25 * time.Second, Transport: p.Transport()},
+		baseURL:    host.APIURL(),
+		token:      token,
+	}
+}
+
+// DefaultBranch returns the default branch of project, a full path such as
+// group/project.
+func (c *Client) DefaultBranch(ctx context.Context, project string) (string, error) {
+	var out struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.call(ctx, http.MethodGet, projectPath(project), nil, &out); err != nil {
+		return "", err
+	}
+	if out.DefaultBranch == "" {
+		return "", errors.New("default branch missing")
+	}
+	return out.DefaultBranch, nil
+}
+
+// CreateBranch creates branch in project from ref, a branch name or commit.
+func (c *Client) CreateBranch(ctx context.Context, project, branch, ref string) error {
+	in := map[string]string{"branch": branch, "ref": ref}
+	return c.call(ctx, http.MethodPost, projectPath(project)+"/repository/branches", in, nil)
+}
+
+// CreateMergeRequest opens a merge request of source into target and
+// returns its web URL. Merging it deletes the source branch.
+func (c *Client) CreateMergeRequest(ctx context.Context, project, title, source, target, description string) (string, error) {
+	in := map[string]any{
+		"title":                title,
+		"source_branch":        source,
+		"target_branch":        target,
+		"description":          description,
+		"remove_source_branch": true,
+	}
+	var out struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := c.call(ctx, http.MethodPost, projectPath(project)+"/merge_requests", in, &out); err != nil {
+		return "", err
+	}
+	return out.WebURL, nil
+}
+
+// MergeRequest is a merge request as GitLab lists them.
+type MergeRequest struct {
+	// IID is its number within the project.
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Description  string `json:"description"`
+}
+
+// OpenMergeRequest returns the open merge request of source, or nil when
+// there is none.
+func (c *Client) OpenMergeRequest(ctx context.Context, project, source string) (*MergeRequest, error) {
+	q := url.Values{"state": {"opened"}, "source_branch": {source}}
+	var out []MergeRequest
+	if err := c.call(ctx, http.MethodGet, projectPath(project)+"/merge_requests?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return &out[0], nil
+}
+
+// EditMergeRequestDescription replaces the description of merge request
+// iid.
+func (c *Client) EditMergeRequestDescription(ctx context.Context, project string, iid int, description string) error {
+	in := map[string]string{"description": description}
+	return c.call(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d", projectPath(project), iid), in, nil)
+}
+
+// projectPath is the API path of a project, which GitLab takes as its
+// URL-encoded full path.
+func projectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+// call sends in, when non-nil, as JSON to the REST API and decodes the
+// response into out when non-nil.
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gitlab %s %s failed: status=%d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
@@ -0,0 +1,85 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestCreateMergeRequest(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/v4/projects/acme%2Fplatform%2Fapi/merge_requests" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer glpat-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"iid":3,"web_url":"https://gitlab.example.com/acme/platform/api/-/merge_requests/3"}`))
+	}))
+	defer srv.Close()
+
+	c := New(forge.GitLab(srv.URL), "glpat-token", proxy.Setting{URL: proxy.Direct})
+	mrURL, err := c.CreateMergeRequest(context.Background(), "acme/platform/api", "Argus: Fix findings", "argus/fix-1", "main", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mrURL != "https://gitlab.example.com/acme/platform/api/-/merge_requests/3" {
+		t.Errorf("web url = %q", mrURL)
+	}
+	if got["source_branch"] != "argus/fix-1" || got["target_branch"] != "main" || got["remove_source_branch"] != true {
+		t.Errorf("request = %v", got)
+	}
+}
+
+func TestDefaultBranchError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	c := New(forge.GitLab(srv.URL), "glpat-token", proxy.Setting{URL: proxy.Direct})
+	if _, err := c.DefaultBranch(context.Background(), "acme/api"); err == nil {
+		t.Fatal("expected a missing project to fail")
+	}
+}
+
+func TestOpenMergeRequest(t *testing.T) {
+	var description string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/api/v4/projects/acme%2Fapi/merge_requests":
+			if r.URL.Query().Get("state") != "opened" || r.URL.Query().Get("source_branch") != "argus/fix-1" {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"iid":3,"web_url":"https://gitlab.example.com/acme/api/-/merge_requests/3","source_branch":"argus/fix-1","target_branch":"main"}]`))
+		case r.Method == http.MethodPut && r.URL.EscapedPath() == "/api/v4/projects/acme%2Fapi/merge_requests/3":
+			var in map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&in)
+			description = in["description"]
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(forge.GitLab(srv.URL), "glpat-token", proxy.Setting{URL: proxy.Direct})
+	mr, err := c.OpenMergeRequest(context.Background(), "acme/api", "argus/fix-1")
+	if err != nil || mr == nil || mr.IID != 3 || mr.TargetBranch != "main" {
+		t.Fatalf("OpenMergeRequest = %+v, %v", mr, err)
+	}
+	if mr, err := c.OpenMergeRequest(context.Background(), "acme/api", "argus/fix-2"); err != nil || mr != nil {
+		t.Errorf("OpenMergeRequest without one = %+v, %v", mr, err)
+	}
+	if err := c.EditMergeRequestDescription(context.Background(), "acme/api", 3, "new body"); err != nil || description != "new body" {
+		t.Errorf("EditMergeRequestDescription = %v, description %q", err, description)
+	}
+}
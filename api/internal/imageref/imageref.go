@@ -0,0 +1,91 @@
+// Package imageref parses container image references such as
+// ghcr.io/acme/api:1.4.2 or alpine@sha256:<digest>, so image scan requests
+// can be checked against the registries workers may pull from.
+package imageref
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DockerHub is the registry of references that name none.
+const DockerHub = "docker.io"
+
+// maxLen bounds a reference; registries reject much longer names anyway.
+const maxLen = 512
+
+var (
+	component = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	host      = regexp.MustCompile(`^[A-Za-z0-9]+(?:[.-][A-Za-z0-9]+)*(?::[0-9]+)?$`)
+	tag       = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digest    = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Ref is a parsed image reference.
+type Ref struct {
+	// Registry is the host, with its port if any; DockerHub when the
+	// reference names none.
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Parse reads a reference. A reference without a tag or digest gets
+// "latest", as docker pull would.
+func Parse(s string) (Ref, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > maxLen {
+		return Ref{}, errors.New("image must be a reference such as ghcr.io/acme/api:1.4.2")
+	}
+	var r Ref
+	if name, d, ok := strings.Cut(s, "@"); ok {
+		if !digest.MatchString(d) {
+			return Ref{}, errors.New("image digest must be sha256:<64 hex characters>")
+		}
+		s, r.Digest = name, d
+	}
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		if !tag.MatchString(s[i+1:]) {
+			return Ref{}, errors.New("image tag is invalid")
+		}
+		s, r.Tag = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, "/")
+	// The first part is a registry when it looks like a host, as in the
+	// docker CLI; otherwise the image is on Docker Hub.
+	if len(parts) > 1 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if !host.MatchString(parts[0]) {
+			return Ref{}, errors.New("image registry is invalid")
+		}
+		r.Registry, parts = strings.ToLower(parts[0]), parts[1:]
+	} else {
+		r.Registry = DockerHub
+		if len(parts) == 1 {
+			parts = append([]string{"library"}, parts...)
+		}
+	}
+	for _, p := range parts {
+		if !component.MatchString(p) {
+			return Ref{}, errors.New("image name must be lowercase letters, digits and separators")
+		}
+	}
+	r.Repository = strings.Join(parts, "/")
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	return r, nil
+}
+
+// String is the reference in full, with its registry.
+func (r Ref) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
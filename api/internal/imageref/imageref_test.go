@@ -0,0 +1,33 @@
+package imageref
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	const sum = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	for _, tc := range []struct {
+		in, want, registry string
+	}{
+		{"alpine", "docker.io/library/alpine:latest", "docker.io"},
+		{"alpine:3.19", "docker.io/library/alpine:3.19", "docker.io"},
+		{"acme/api:1.0", "docker.io/acme/api:1.0", "docker.io"},
+		{"ghcr.io/acme/api:1.4.2", "ghcr.io/acme/api:1.4.2", "ghcr.io"},
+		{"Registry.Example.com:5000/team/app", "registry.example.com:5000/team/app:latest", "registry.example.com:5000"},
+		{"localhost/app:dev", "localhost/app:dev", "localhost"},
+		{"ghcr.io/acme/api@" + sum, "ghcr.io/acme/api@" + sum, "ghcr.io"},
+		{"ghcr.io/acme/api:1.4.2@" + sum, "ghcr.io/acme/api:1.4.2@" + sum, "ghcr.io"},
+	} {
+		r, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if r.String() != tc.want || r.Registry != tc.registry {
+			t.Errorf("Parse(%q) = %q on %q, want %q on %q", tc.in, r.String(), r.Registry, tc.want, tc.registry)
+		}
+	}
+	for _, bad := range []string{"", "Alpine", "ghcr.io/acme/api:", "ghcr.io/acme/api@sha256:abc", "-rf/x", "acme/api:1.0 --insecure", "ghcr.io//api", "ghcr.io/acme/api:!"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package localize translates finding descriptions and remediation guidance.
+// Translations are keyed by tool and rule ID ("gitleaks:private-key") and
+// looked up through a Translator, so other sources can be plugged in later;
+// Catalog, a static set of JSON files, is the one in use.
+package localize
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Text is the localized text for one rule. Empty fields leave the scanner's
+// own text in place.
+type Text struct {
+	Description string `json:"description,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Translator finds the Text for a rule in a language.
+type Translator interface {
+	Translate(lang, tool, ruleID string) (Text, bool)
+	// Languages lists the languages with translations, lowercased.
+	Languages() []string
+}
+
+//go:embed catalog/*.json
+var builtin embed.FS
+
+// Catalog is a static Translator: one JSON object per language, mapping
+// "tool:rule" to a Text.
+type Catalog struct {
+	entries map[string]map[string]Text // lang -> key -> text
+}
+
+// Builtin returns the catalog of rules curated with Argus.
+func Builtin() *Catalog {
+	c := &Catalog{entries: map[string]map[string]Text{}}
+	sub, _ := fs.Sub(builtin, "catalog")
+	if err := c.load(sub); err != nil {
+		panic(err) // the embedded files are checked by the tests
+	}
+	return c
+}
+
+// LoadDir merges the <lang>.json files in dir over the catalog, entry by
+// entry, so deployments can add languages and rules or reword ours.
+func (c *Catalog) LoadDir(dir string) error {
+	return c.load(os.DirFS(dir))
+}
+
+func (c *Catalog) load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		var entries map[string]Text
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		lang := strings.ToLower(strings.TrimSuffix(filepath.Base(name), ".json"))
+		if c.entries[lang] == nil {
+			c.entries[lang] = map[string]Text{}
+		}
+		for key, t := range entries {
+			c.entries[lang][key] = t
+		}
+	}
+	return nil
+}
+
+// Translate looks lang up as given, then by its base language, so "es-MX"
+// falls back to "es".
+func (c *Catalog) Translate(lang, tool, ruleID string) (Text, bool) {
+	key := tool + ":" + ruleID
+	lang = strings.ToLower(lang)
+	if t, ok := c.entries[lang][key]; ok {
+		return t, true
+	}
+	t, ok := c.entries[base(lang)][key]
+	return t, ok
+}
+
+func (c *Catalog) Languages() []string {
+	out := make([]string, 0, len(c.entries))
+	for lang := range c.entries {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Negotiate picks the supported language the Accept-Language header prefers
+// most, matching "fr-CH" to "fr" when only the base language is supported.
+// It returns "" when nothing matches, leaving the choice to the caller.
+func Negotiate(header string, supported []string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{tag, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		for _, try := range []string{p.tag, base(p.tag)} {
+			for _, s := range supported {
+				if s == try {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func base(tag string) string {
+	b, _, _ := strings.Cut(tag, "-")
+	return b
+}
@@ -0,0 +1,74 @@
+package localize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinCatalog(t *testing.T) {
+	c := Builtin()
+	en := c.entries["en"]
+	if len(en) == 0 {
+		t.Fatal("builtin catalog has no English entries")
+	}
+	for lang, entries := range c.entries {
+		for key, text := range entries {
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s: %s has no English entry", lang, key)
+			}
+			if text.Remediation == "" {
+				t.Errorf("%s: %s has no remediation", lang, key)
+			}
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	c := Builtin()
+	if got, ok := c.Translate("es-MX", "gitleaks", "private-key"); !ok || got.Description == "" {
+		t.Errorf("es-MX fell back to %+v, %v", got, ok)
+	}
+	if _, ok := c.Translate("es", "gitleaks", "no-such-rule"); ok {
+		t.Error("unknown rule translated")
+	}
+	if _, ok := c.Translate("xx", "gitleaks", "private-key"); ok {
+		t.Error("unknown language translated")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pt-BR.json"), []byte(`{"gitleaks:private-key":{"remediation":"Revogue a chave."}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := Builtin()
+	if err := c.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.Translate("pt-br", "gitleaks", "private-key"); !ok || got.Remediation != "Revogue a chave." {
+		t.Errorf("got %+v, %v", got, ok)
+	}
+	if _, ok := c.Translate("es", "gitleaks", "private-key"); !ok {
+		t.Error("LoadDir dropped builtin entries")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{"de", "en", "es", "fr"}
+	for _, tc := range []struct {
+		header, want string
+	}{
+		{"fr-CH, fr;q=0.9, en;q=0.8", "fr"},
+		{"ja, es;q=0.5", "es"},
+		{"en;q=0.2, de;q=0.9", "de"},
+		{"es;q=0, en", "en"},
+		{"*", ""},
+		{"", ""},
+		{"ja", ""},
+	} {
+		if got := Negotiate(tc.header, supported); got != tc.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	reqs := reg.NewCounterVec("argus_http_requests_total", "Requests.", "method", "status")
+	lat := reg.NewHistogramVec("argus_http_request_duration_seconds", "Latency.", []float64{0.1, 1}, "method")
+	reg.NewGaugeFunc("argus_queue_depth", "Depth.", []string{"queue"}, func(context.Context) []Sample {
+		return []Sample{{LabelValues: []string{"ssao:jobs"}, Value: 3}}
+	})
+
+	reqs.Inc("GET", "200")
+	reqs.Inc("GET", "200")
+	lat.Observe(0.05, "GET")
+	lat.Observe(0.5, "GET")
+
+	var b strings.Builder
+	if err := reg.Write(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE argus_http_requests_total counter",
+		`argus_http_requests_total{method="GET",status="200"} 2`,
+		`argus_http_request_duration_seconds_bucket{method="GET",le="0.1"} 1`,
+		`argus_http_request_duration_seconds_bucket{method="GET",le="1"} 2`,
+		`argus_http_request_duration_seconds_bucket{method="GET",le="+Inf"} 2`,
+		`argus_http_request_duration_seconds_count{method="GET"} 2`,
+		`argus_queue_depth{queue="ssao:jobs"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package patch
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FindingRef identifies a finding a diff hunk addresses.
+type FindingRef struct {
+	ID        string `json:"id,omitempty"`
+	Tool      string `json:"tool"`
+	Severity  string `json:"severity,omitempty"`
+	Title     string `json:"title"`
+	Permalink string `json:"permalink,omitempty"`
+}
+
+// Hunk is one hunk of a generated diff with the fixes that produced it and
+// the findings they address, so a reviewer can check each change against
+// its justification.
+type Hunk struct {
+	File     string       `json:"file"`
+	Header   string       `json:"header"`
+	Diff     string       `json:"diff"`
+	Fixes    []string     `json:"fixes"`
+	Findings []FindingRef `json:"findings"`
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// AnnotateHunks splits a git diff into hunks and attaches to each the applied
+// actions that touch it: actions on the same file whose line falls inside
+// the hunk's new range, or that have no line.
+func AnnotateHunks(diff string, applied []FixAction) []Hunk {
+	hunks := make([]Hunk, 0)
+	var file string
+	var cur *Hunk
+	var start, count int
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		annotate(cur, start, count, applied)
+		hunks = append(hunks, *cur)
+		cur = nil
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			file = ""
+		case cur == nil && strings.HasPrefix(line, "--- a/"):
+			file = strings.TrimSpace(strings.TrimPrefix(line, "--- a/"))
+		case cur == nil && strings.HasPrefix(line, "+++ b/"):
+			file = strings.TrimSpace(strings.TrimPrefix(line, "+++ b/"))
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			start, _ = strconv.Atoi(m[1])
+			count = 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			cur = &Hunk{File: file, Header: strings.TrimRight(line, "\n"), Fixes: []string{}, Findings: []FindingRef{}}
+			cur.Diff = line
+		case cur != nil:
+			cur.Diff += line
+		}
+	}
+	flush()
+	return hunks
+}
+
+func annotate(h *Hunk, start, count int, applied []FixAction) {
+	seen := map[FindingRef]bool{}
+	for _, a := range applied {
+		if filepath.ToSlash(filepath.Clean(a.FilePath)) != h.File {
+			continue
+		}
+		if a.LineStart > 0 && (a.LineStart < start || a.LineStart >= start+count) {
+			continue
+		}
+		if !contains(h.Fixes, a.Description) {
+			h.Fixes = append(h.Fixes, a.Description)
+		}
+		for _, f := range a.Findings {
+			ref := FindingRef{ID: f.ID, Tool: f.Tool, Severity: f.Severity, Title: f.Title, Permalink: f.Permalink}
+			if !seen[ref] {
+				seen[ref] = true
+				h.Findings = append(h.Findings, ref)
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
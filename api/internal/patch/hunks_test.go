@@ -0,0 +1,49 @@
+package patch
+
+import "testing"
+
+const sampleDiff = `diff --git a/.gitignore b/.gitignore
+index 3c3629e..b1a3b3c 100644
+--- a/.gitignore
++++ b/.gitignore
+@@ -1 +1,2 @@
+ node_modules/
++.env
+diff --git a/config/app.env b/config/app.env
+index 1111111..2222222 100644
+--- a/config/app.env
++++ b/config/app.env
+@@ -1,3 +1,3 @@
+ NAME=demo
+-API_TOKEN='supersecretvalue'
++API_TOKEN="${SECRET_FROM_ENV}"
+ PORT=8080
+@@ -40,3 +40,3 @@
+ # tail
+-DB_PASSWORD=hunter2hunter2
++DB_PASSWORD="${SECRET_FROM_ENV}"
+ # end
+`
+
+func TestAnnotateHunks(t *testing.T) {
+	secret := Finding{ID: "abc123", Tool: "gitleaks", Severity: "HIGH", Title: "Secret detected", FilePath: "config/app.env", LineStart: 2}
+	other := Finding{ID: "def456", Tool: "semgrep", Title: "Weak TLS", FilePath: "main.go"}
+	applied := []FixAction{
+		{Type: FixGitIgnoreEnv, FilePath: ".gitignore", Description: "Ensure .env is ignored", Findings: []Finding{other}},
+		{Type: FixSecretRedaction, FilePath: "config/app.env", LineStart: 2, Description: "Replace hardcoded credential", Findings: []Finding{secret}},
+	}
+
+	hunks := AnnotateHunks(sampleDiff, applied)
+	if len(hunks) != 3 {
+		t.Fatalf("got %d hunks, want 3", len(hunks))
+	}
+	if h := hunks[0]; h.File != ".gitignore" || len(h.Findings) != 1 || h.Findings[0].ID != "def456" {
+		t.Errorf(".gitignore hunk = %+v", h)
+	}
+	if h := hunks[1]; h.File != "config/app.env" || len(h.Findings) != 1 || h.Findings[0].ID != "abc123" || h.Header != "@@ -1,3 +1,3 @@" {
+		t.Errorf("redaction hunk = %+v", h)
+	}
+	if h := hunks[2]; len(h.Findings) != 0 || len(h.Fixes) != 0 {
+		t.Errorf("hunk outside the finding's line was annotated: %+v", h)
+	}
+}
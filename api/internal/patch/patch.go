@@ -10,10 +10,18 @@ import (
 )
 
 type Finding struct {
+	// ID is the finding's public ID, when it has one.
+	ID        string
 	Tool      string
+	Severity  string
 	Title     string
 	FilePath  string
 	LineStart int
+	// Permalink points at the finding in the scanned commit, when known.
+	Permalink string
+	// IssueRefs are the issues tracking the finding: #123, owner/repo#123
+	// or Jira keys.
+	IssueRefs []string
 }
 
 type FixActionType string
@@ -28,12 +36,17 @@ type FixAction struct {
 	FilePath    string
 	LineStart   int
 	Description string
+	// Findings are what the action addresses; the .gitignore hardening
+	// added when no finding asked for it has none.
+	Findings []Finding
 }
 
 type ManualItem struct {
-	Reason string `json:"reason"`
-	Title  string `json:"title"`
-	File   string `json:"file"`
+	Reason    string `json:"reason"`
+	Title     string `json:"title"`
+	File      string `json:"file"`
+	Severity  string `json:"severity,omitempty"`
+	Permalink string `json:"permalink,omitempty"`
 }
 
 type Plan struct {
@@ -56,12 +69,13 @@ func BuildPlan(findings []Finding, maxFixes int) Plan {
 		title := strings.ToLower(strings.TrimSpace(f.Title))
 		tool := strings.ToLower(strings.TrimSpace(f.Tool))
 
-		if (tool == "gitleaks" || strings.Contains(title, "secret")) && filePath != "" {
+		if (tool == "gitleaks" || tool == "trufflehog" || strings.Contains(title, "secret")) && filePath != "" {
 			plan.Actions = append(plan.Actions, FixAction{
 				Type:        FixSecretRedaction,
 				FilePath:    filePath,
 				LineStart:   f.LineStart,
 				Description: "Replace hardcoded credential-like value with environment placeholder",
+				Findings:    []Finding{f},
 			})
 			continue
 		}
@@ -71,15 +85,18 @@ func BuildPlan(findings []Finding, maxFixes int) Plan {
 				Type:        FixGitIgnoreEnv,
 				FilePath:    ".gitignore",
 				Description: "Ensure .env is ignored",
+				Findings:    []Finding{f},
 			})
 			seenGitignore = true
 			continue
 		}
 
 		plan.Manual = append(plan.Manual, ManualItem{
-			Reason: "manual fix required: ambiguous or potentially unsafe automatic change",
-			Title:  f.Title,
-			File:   f.FilePath,
+			Reason:    "manual fix required: ambiguous or potentially unsafe automatic change",
+			Title:     f.Title,
+			File:      f.FilePath,
+			Severity:  f.Severity,
+			Permalink: f.Permalink,
 		})
 	}
 
@@ -94,6 +111,32 @@ func BuildPlan(findings []Finding, maxFixes int) Plan {
 	return plan
 }
 
+// Gate moves the actions of fix types that enabled rejects to manual
+// items, so a fix type can be turned off without touching the rest.
+func (p Plan) Gate(enabled func(FixActionType) bool) Plan {
+	out := Plan{Actions: make([]FixAction, 0, len(p.Actions)), Manual: p.Manual}
+	for _, a := range p.Actions {
+		if enabled(a.Type) {
+			out.Actions = append(out.Actions, a)
+			continue
+		}
+		if len(a.Findings) == 0 {
+			// Hardening nobody asked for is simply left out.
+			continue
+		}
+		for _, f := range a.Findings {
+			out.Manual = append(out.Manual, ManualItem{
+				Reason:    "manual fix required: automatic " + string(a.Type) + " fixes are turned off",
+				Title:     f.Title,
+				File:      f.FilePath,
+				Severity:  f.Severity,
+				Permalink: f.Permalink,
+			})
+		}
+	}
+	return out
+}
+
 type ApplyResult struct {
 	Applied []FixAction
 	Manual  []ManualItem
@@ -122,11 +165,14 @@ func ApplyPlan(repoDir string, plan Plan) (ApplyResult, error) {
 				result.Manual = append(result.Manual, ManualItem{Reason: "manual fix required: invalid target path", Title: action.Description, File: action.FilePath})
 				continue
 			}
-			applied, err := redactSecretLine(target, action.LineStart)
+			line, err := redactSecretLine(target, action.LineStart)
 			if err != nil {
 				return result, err
 			}
-			if applied {
+			if line > 0 {
+				// The redaction may land on another line than reported;
+				// record where it did so the diff hunk can be matched.
+				action.LineStart = line
 				result.Applied = append(result.Applied, action)
 			} else {
 				result.Manual = append(result.Manual, ManualItem{Reason: "manual fix required: no safe redaction match found", Title: action.Description, File: action.FilePath})
@@ -159,28 +205,31 @@ func ensureEnvIgnored(path string) (bool, error) {
 	return true, os.WriteFile(path, []byte(s), 0o644)
 }
 
-func redactSecretLine(path string, lineStart int) (bool, error) {
+// redactSecretLine redacts the credential on lineStart, or failing that the
+// first one in the file, and returns the 1-based line it changed; 0 means
+// nothing was redacted.
+func redactSecretLine(path string, lineStart int) (int, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return false, nil
+			return 0, nil
 		}
-		return false, err
+		return 0, err
 	}
 	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
 	if lineStart > 0 && lineStart <= len(lines) {
 		if repl, ok := redactLine(lines[lineStart-1]); ok {
 			lines[lineStart-1] = repl
-			return true, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+			return lineStart, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
 		}
 	}
 	for i, line := range lines {
 		if repl, ok := redactLine(line); ok {
 			lines[i] = repl
-			return true, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+			return i + 1, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
 		}
 	}
-	return false, nil
+	return 0, nil
 }
 
 func redactLine(line string) (string, bool) {
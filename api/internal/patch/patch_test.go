@@ -72,3 +72,38 @@ func TestApplyPlanDryRunPath(t *testing.T) {
 		t.Fatal("expected secret placeholder replacement")
 	}
 }
+
+func TestSplitBySeverity(t *testing.T) {
+	findings := []Finding{
+		{Tool: "gitleaks", Severity: "HIGH", Title: "Secret detected: aws", FilePath: "a.env"},
+		{Tool: "semgrep", Severity: "WARNING", Title: "weak hash", FilePath: "b.go"},
+		{Tool: "trivy", Severity: "CRITICAL", Title: "CVE-1 in pkg", FilePath: "go.mod"},
+		{Tool: "semgrep", Severity: "INFO", Title: "style", FilePath: "c.go"},
+	}
+	kept, deferred := SplitBySeverity(findings, "high")
+	if len(kept) != 2 || kept[0].Severity != "HIGH" || kept[1].Severity != "CRITICAL" {
+		t.Fatalf("unexpected kept findings: %+v", kept)
+	}
+	if len(deferred) != 2 || deferred[0].File != "b.go" {
+		t.Fatalf("expected lower-severity items as manual, got %+v", deferred)
+	}
+
+	all, none := SplitBySeverity(findings, "")
+	if len(all) != len(findings) || len(none) != 0 {
+		t.Fatal("empty threshold should keep everything")
+	}
+}
+
+func TestPlanGate(t *testing.T) {
+	findings := []Finding{{Tool: "gitleaks", Title: "Secret detected: github-pat", FilePath: "config/app.env", LineStart: 2, Severity: "HIGH"}}
+	plan := BuildPlan(findings, 10).Gate(func(t FixActionType) bool { return t != FixSecretRedaction })
+	if len(plan.Actions) != 1 || plan.Actions[0].Type != FixGitIgnoreEnv {
+		t.Fatalf("actions = %+v", plan.Actions)
+	}
+	if len(plan.Manual) != 1 || plan.Manual[0].File != "config/app.env" || !strings.Contains(plan.Manual[0].Reason, "secret_redaction") {
+		t.Fatalf("manual = %+v", plan.Manual)
+	}
+	if plan := BuildPlan(nil, 10).Gate(func(FixActionType) bool { return false }); len(plan.Actions) != 0 || len(plan.Manual) != 0 {
+		t.Fatalf("plan = %+v", plan)
+	}
+}
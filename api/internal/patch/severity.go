@@ -0,0 +1,50 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SeverityRank orders scanner severities on one scale. Semgrep's
+// ERROR/WARNING/INFO are folded onto HIGH/MEDIUM/LOW; unknown values rank 0.
+func SeverityRank(sev string) int {
+	switch strings.ToUpper(strings.TrimSpace(sev)) {
+	case "CRITICAL":
+		return 4
+	case "HIGH", "ERROR":
+		return 3
+	case "MEDIUM", "WARNING", "MODERATE":
+		return 2
+	case "LOW", "INFO", "NOTE":
+		return 1
+	}
+	return 0
+}
+
+func ValidSeverity(sev string) bool { return SeverityRank(sev) > 0 }
+
+// SplitBySeverity keeps findings at or above min for automatic fixing and
+// turns the rest into manual items so reviewers still see them. An empty min
+// keeps everything.
+func SplitBySeverity(findings []Finding, min string) ([]Finding, []ManualItem) {
+	if strings.TrimSpace(min) == "" {
+		return findings, nil
+	}
+	threshold := SeverityRank(min)
+	kept := make([]Finding, 0, len(findings))
+	deferred := make([]ManualItem, 0)
+	for _, f := range findings {
+		if SeverityRank(f.Severity) >= threshold {
+			kept = append(kept, f)
+			continue
+		}
+		deferred = append(deferred, ManualItem{
+			Reason:    fmt.Sprintf("below min_severity %s: not auto-fixed in this PR", strings.ToUpper(min)),
+			Title:     f.Title,
+			File:      f.FilePath,
+			Severity:  f.Severity,
+			Permalink: f.Permalink,
+		})
+	}
+	return kept, deferred
+}
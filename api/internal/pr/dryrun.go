@@ -1,9 +1,16 @@
 package pr
 
-import "argus/api/internal/patch"
+import (
+	"argus/api/internal/flags"
+	"argus/api/internal/patch"
+)
 
-func GenerateDryRunDiff(repoDir string, findings []patch.Finding, maxFixes int) (string, patch.Plan, patch.ApplyResult, error) {
-	plan := patch.BuildPlan(findings, maxFixes)
+// GenerateDryRunDiff applies the plan for findings to repoDir and returns
+// the diff. Fix types whose fix.<type> flag is off become manual items.
+func GenerateDryRunDiff(repoDir string, findings []patch.Finding, maxFixes int, enabled flags.Set) (string, patch.Plan, patch.ApplyResult, error) {
+	plan := patch.BuildPlan(findings, maxFixes).Gate(func(t patch.FixActionType) bool {
+		return enabled.Enabled(flags.Fix + string(t))
+	})
 	applied, err := patch.ApplyPlan(repoDir, plan)
 	if err != nil {
 		return "", plan, applied, err
@@ -19,7 +19,7 @@ func TestGenerateDryRunDiff_NoGitHubCredsRequired(t *testing.T) {
 	must(t, exec.Command("git", "-C", repo, "-c", "user.email=test@example.com", "-c", "user.name=test", "commit", "-m", "init").Run())
 
 	findings := []patch.Finding{{Tool: "gitleaks", Title: "Secret detected", FilePath: "app.env", LineStart: 1}}
-	diff, _, _, err := GenerateDryRunDiff(repo, findings, 5)
+	diff, _, _, err := GenerateDryRunDiff(repo, findings, 5, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,272 @@
+package pr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"argus/api/internal/azuredevops"
+	"argus/api/internal/githubapp"
+	"argus/api/internal/gitlab"
+	"argus/internal/forge"
+)
+
+// Hosts are the code hosts PRs can be opened on.
+type Hosts struct {
+	GitHub forge.GitHub
+	GitLab forge.GitLab
+	// GitLabToken opens merge requests and pushes their branches.
+	GitLabToken string
+	// AzureDevOpsToken does the same for pull requests on Azure Repos.
+	AzureDevOpsToken string
+}
+
+// Provider is the API of the code host a repo is on, through which a fix
+// branch is proposed: a GitHub or Azure Repos pull request, or a GitLab
+// merge request.
+// A Provider is bound to one repo and holds the token to reach it with.
+type Provider interface {
+	// DefaultBranch is the branch fixes go to when neither the request
+	// nor the repo names one.
+	DefaultBranch(ctx context.Context) (string, error)
+	// CreateBranch creates branch from base for the fix to be pushed to.
+	CreateBranch(ctx context.Context, branch, base string) error
+	// PushAuth is the user name and token git pushes the fix with.
+	PushAuth() (user, token string)
+	// OpenPullRequest proposes merging head into base and returns the web
+	// URL of the pull or merge request, with a warning for each of opts
+	// that could not be applied to it.
+	OpenPullRequest(ctx context.Context, title, head, base, body string, opts Options) (string, []string, error)
+	// FindPullRequest returns the open pull or merge request of head, or
+	// nil when there is none.
+	FindPullRequest(ctx context.Context, head string) (*PullRequest, error)
+	// EditPullRequest replaces the description of pr with body.
+	EditPullRequest(ctx context.Context, pr PullRequest, body string) error
+}
+
+// PullRequest is an open pull or merge request of a fix branch.
+type PullRequest struct {
+	// ID is its number: GitLab's iid, or Azure Repos' pull request ID.
+	ID   int
+	URL  string
+	Head string
+	Base string
+	Body string
+}
+
+// Options shape the pull request opened so it fits the repo's review
+// workflow. GitLab and Azure Repos only take Draft.
+type Options struct {
+	// Draft opens it as a draft.
+	Draft bool
+	// Labels are added to it, such as "security" or "automated".
+	Labels []string
+	// Reviewers are the users, and TeamReviewers the teams by slug, asked
+	// to review it.
+	Reviewers     []string
+	TeamReviewers []string
+}
+
+// empty reports whether no option is set.
+func (o Options) empty() bool {
+	return !o.Draft && len(o.Labels)+len(o.Reviewers)+len(o.TeamReviewers) == 0
+}
+
+// onlyDraft warns about the options a host that only takes Draft ignores.
+func (o Options) onlyDraft(host string) []string {
+	var warnings []string
+	if len(o.Labels) > 0 {
+		warnings = append(warnings, "labels are not added on "+host)
+	}
+	if len(o.Reviewers)+len(o.TeamReviewers) > 0 {
+		warnings = append(warnings, "reviewers are not requested on "+host)
+	}
+	return warnings
+}
+
+// supports reports whether repoURL is a .git repo on GitHub or GitLab, or
+// an Azure Repos repo, whose URLs have no .git.
+func (h Hosts) supports(repoURL string) bool {
+	if _, ok := forge.ParseAzureRepo(repoURL); ok {
+		return true
+	}
+	return (h.GitHub.Owns(repoURL) || h.GitLab.Owns(repoURL)) && strings.HasSuffix(strings.ToLower(repoURL), ".git")
+}
+
+// provider returns the Provider of repoURL. GitHub repos use the app
+// installation installationID, the one recorded on the repo, or
+// GITHUB_INSTALLATION_ID when it is 0.
+func (s *Service) provider(ctx context.Context, repoURL string, installationID int64) (Provider, error) {
+	if project, ok := s.hosts.GitLab.ProjectPath(repoURL); ok {
+		if s.hosts.GitLabToken == "" {
+			return nil, errors.New("gitlab.token (GITLAB_TOKEN) is required to open merge requests")
+		}
+		return gitlabProvider{gitlab.New(s.hosts.GitLab, s.hosts.GitLabToken, s.proxy), project, s.hosts.GitLabToken}, nil
+	}
+	if repo, ok := forge.ParseAzureRepo(repoURL); ok {
+		if s.hosts.AzureDevOpsToken == "" {
+			return nil, errors.New("azure_devops.token (AZURE_DEVOPS_TOKEN) is required to open pull requests on Azure Repos")
+		}
+		return azureProvider{azuredevops.New(s.hosts.AzureDevOpsToken, s.proxy), repo, s.hosts.AzureDevOpsToken}, nil
+	}
+	if !s.hosts.GitHub.Owns(repoURL) {
+		return nil, fmt.Errorf("no provider for %s", repoURL)
+	}
+	gh, err := githubapp.NewForInstallation(s.hosts.GitHub, s.proxy, installationID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := gh.InstallationToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owner, repo, err := githubapp.ParseGitHubURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return githubProvider{gh, owner, repo, token}, nil
+}
+
+type githubProvider struct {
+	gh                 *githubapp.Client
+	owner, repo, token string
+}
+
+func (p githubProvider) DefaultBranch(ctx context.Context) (string, error) {
+	return p.gh.GetDefaultBranch(ctx, p.owner, p.repo, p.token)
+}
+
+func (p githubProvider) CreateBranch(ctx context.Context, branch, base string) error {
+	sha, err := p.gh.GetBranchSHA(ctx, p.owner, p.repo, base, p.token)
+	if err != nil {
+		return err
+	}
+	return p.gh.CreateRef(ctx, p.owner, p.repo, "refs/heads/"+branch, sha, p.token)
+}
+
+func (p githubProvider) PushAuth() (string, string) {
+	return "x-access-token", p.token
+}
+
+// OpenPullRequest opens the pull request, then labels it and requests its
+// reviews. The pull request stands if those fail; the failures come back
+// as warnings.
+func (p githubProvider) OpenPullRequest(ctx context.Context, title, head, base, body string, opts Options) (string, []string, error) {
+	pr, err := p.gh.CreatePullRequest(ctx, p.owner, p.repo, githubapp.NewPullRequest{Title: title, Head: head, Base: base, Body: body, Draft: opts.Draft}, p.token)
+	if err != nil {
+		return "", nil, err
+	}
+	var warnings []string
+	if len(opts.Labels) > 0 {
+		if err := p.gh.AddLabels(ctx, p.owner, p.repo, pr.Number, opts.Labels, p.token); err != nil {
+			warnings = append(warnings, "labels not added: "+err.Error())
+		}
+	}
+	if len(opts.Reviewers)+len(opts.TeamReviewers) > 0 {
+		if err := p.gh.RequestReviewers(ctx, p.owner, p.repo, pr.Number, opts.Reviewers, opts.TeamReviewers, p.token); err != nil {
+			warnings = append(warnings, "reviewers not requested: "+err.Error())
+		}
+	}
+	return pr.HTMLURL, warnings, nil
+}
+
+func (p githubProvider) FindPullRequest(ctx context.Context, head string) (*PullRequest, error) {
+	prs, err := p.gh.ListPullRequests(ctx, p.owner, p.repo, "open", p.owner+":"+head, p.token)
+	if err != nil || len(prs) == 0 {
+		return nil, err
+	}
+	return &PullRequest{ID: prs[0].Number, URL: prs[0].HTMLURL, Head: head, Base: prs[0].Base.Ref, Body: prs[0].Body}, nil
+}
+
+func (p githubProvider) EditPullRequest(ctx context.Context, pr PullRequest, body string) error {
+	return p.gh.EditPullRequestBody(ctx, p.owner, p.repo, pr.ID, body, p.token)
+}
+
+type gitlabProvider struct {
+	gl             *gitlab.Client
+	project, token string
+}
+
+func (p gitlabProvider) DefaultBranch(ctx context.Context) (string, error) {
+	return p.gl.DefaultBranch(ctx, p.project)
+}
+
+func (p gitlabProvider) CreateBranch(ctx context.Context, branch, base string) error {
+	return p.gl.CreateBranch(ctx, p.project, branch, base)
+}
+
+// PushAuth uses the user name GitLab documents for access tokens; it
+// accepts any.
+func (p gitlabProvider) PushAuth() (string, string) {
+	return "oauth2", p.token
+}
+
+// OpenPullRequest marks a draft the way GitLab does, by its title.
+func (p gitlabProvider) OpenPullRequest(ctx context.Context, title, head, base, body string, opts Options) (string, []string, error) {
+	if opts.Draft {
+		title = "Draft: " + title
+	}
+	mrURL, err := p.gl.CreateMergeRequest(ctx, p.project, title, head, base, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return mrURL, opts.onlyDraft("GitLab"), nil
+}
+
+func (p gitlabProvider) FindPullRequest(ctx context.Context, head string) (*PullRequest, error) {
+	mr, err := p.gl.OpenMergeRequest(ctx, p.project, head)
+	if err != nil || mr == nil {
+		return nil, err
+	}
+	return &PullRequest{ID: mr.IID, URL: mr.WebURL, Head: head, Base: mr.TargetBranch, Body: mr.Description}, nil
+}
+
+func (p gitlabProvider) EditPullRequest(ctx context.Context, pr PullRequest, body string) error {
+	return p.gl.EditMergeRequestDescription(ctx, p.project, pr.ID, body)
+}
+
+type azureProvider struct {
+	ado   *azuredevops.Client
+	repo  forge.AzureRepo
+	token string
+}
+
+func (p azureProvider) DefaultBranch(ctx context.Context) (string, error) {
+	return p.ado.DefaultBranch(ctx, p.repo)
+}
+
+func (p azureProvider) CreateBranch(ctx context.Context, branch, base string) error {
+	sha, err := p.ado.BranchSHA(ctx, p.repo, base)
+	if err != nil {
+		return err
+	}
+	return p.ado.CreateBranch(ctx, p.repo, branch, sha)
+}
+
+// PushAuth sends the personal access token as the password; Azure DevOps
+// ignores the user name.
+func (p azureProvider) PushAuth() (string, string) {
+	return "argus", p.token
+}
+
+func (p azureProvider) OpenPullRequest(ctx context.Context, title, head, base, body string, opts Options) (string, []string, error) {
+	prURL, err := p.ado.CreatePullRequest(ctx, p.repo, title, head, base, body, opts.Draft)
+	if err != nil {
+		return "", nil, err
+	}
+	return prURL, opts.onlyDraft("Azure Repos"), nil
+}
+
+func (p azureProvider) FindPullRequest(ctx context.Context, head string) (*PullRequest, error) {
+	pr, err := p.ado.ActivePullRequest(ctx, p.repo, head)
+	if err != nil || pr == nil {
+		return nil, err
+	}
+	base := strings.TrimPrefix(pr.TargetRefName, "refs/heads/")
+	return &PullRequest{ID: pr.ID, URL: p.repo.PullRequestURL(pr.ID), Head: head, Base: base, Body: pr.Description}, nil
+}
+
+func (p azureProvider) EditPullRequest(ctx context.Context, pr PullRequest, body string) error {
+	return p.ado.EditPullRequestDescription(ctx, p.repo, pr.ID, body)
+}
@@ -0,0 +1,57 @@
+package pr
+
+import (
+	"context"
+	"testing"
+
+	"argus/internal/proxy"
+)
+
+func TestProvider(t *testing.T) {
+	s := NewService(nil, 0, Hosts{GitLab: "https://gitlab.example.com"}, Committer{}, proxy.Setting{URL: proxy.Direct})
+	for u, want := range map[string]bool{
+		"https://github.com/acme/api.git":                  true,
+		"https://gitlab.example.com/acme/platform/api.git": true,
+		"https://gitlab.example.com/acme/platform/api":     false,
+		"https://dev.azure.com/acme/Platform/_git/api":     true,
+		"https://gitlab.com/acme/api.git":                  false,
+		"https://bitbucket.org/acme/api.git":               false,
+	} {
+		if got := s.hosts.supports(u); got != want {
+			t.Errorf("supports(%s) = %v, want %v", u, got, want)
+		}
+	}
+
+	const repo = "https://gitlab.example.com/acme/platform/api.git"
+	if _, err := s.provider(context.Background(), repo, 0); err == nil {
+		t.Error("expected a GitLab repo without gitlab.token to fail")
+	}
+	s.hosts.GitLabToken = "glpat-token"
+	p, err := s.provider(context.Background(), repo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gl, ok := p.(gitlabProvider)
+	if user, token := p.PushAuth(); !ok || gl.project != "acme/platform/api" || user != "oauth2" || token != "glpat-token" {
+		t.Errorf("provider = %+v", p)
+	}
+
+	const adoRepo = "https://dev.azure.com/acme/Platform/_git/api"
+	if _, err := s.provider(context.Background(), adoRepo, 0); err == nil {
+		t.Error("expected an Azure Repos repo without azure_devops.token to fail")
+	}
+	s.hosts.AzureDevOpsToken = "ado-pat"
+	if p, err := s.provider(context.Background(), adoRepo, 0); err != nil || p.(azureProvider).repo.Name != "api" {
+		t.Errorf("provider = %+v, %v", p, err)
+	}
+}
+
+func TestOptionsOnlyDraft(t *testing.T) {
+	if w := (Options{Draft: true}).onlyDraft("GitLab"); len(w) != 0 {
+		t.Errorf("warnings for a draft = %v", w)
+	}
+	w := Options{Labels: []string{"security"}, TeamReviewers: []string{"appsec"}}.onlyDraft("GitLab")
+	if len(w) != 2 || w[0] != "labels are not added on GitLab" || w[1] != "reviewers are not requested on GitLab" {
+		t.Errorf("warnings = %v", w)
+	}
+}
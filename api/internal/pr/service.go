@@ -7,20 +7,43 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"argus/api/internal/commitsign"
+	"argus/api/internal/flags"
 	"argus/api/internal/githubapp"
 	"argus/api/internal/patch"
+	"argus/internal/proxy"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Service struct {
-	db *pgxpool.Pool
+	db         *pgxpool.Pool
+	maxCloneMB int
+	// hosts are the code hosts PRs are opened on.
+	hosts Hosts
+	// committer makes, and may sign, the fix commits.
+	committer Committer
+	// proxy routes the code hosts' API and git traffic.
+	proxy proxy.Setting
 }
 
-func NewService(db *pgxpool.Pool) *Service { return &Service{db: db} }
+// Committer is who fix commits are made as. With a Key they are signed;
+// code hosts show them as verified when the key is registered to Email's
+// account.
+type Committer struct {
+	Name, Email string
+	Key         *commitsign.Key
+}
+
+func NewService(db *pgxpool.Pool, maxCloneMB int, hosts Hosts, committer Committer, p proxy.Setting) *Service {
+	return &Service{db: db, maxCloneMB: maxCloneMB, hosts: hosts, committer: committer, proxy: p}
+}
 
 type Request struct {
 	RepoID      string
@@ -28,120 +51,187 @@ type Request struct {
 	BaseBranch  string
 	Confirm     bool
 	MaxFixes    int
+	MinSeverity string
 	RequestedBy string
+	// Options apply to the pull request opened on confirm.
+	Options Options
 }
 
 type Response struct {
-	Mode   string `json:"mode"`
-	Diff   string `json:"diff"`
-	PRURL  string `json:"pr_url,omitempty"`
-	Branch string `json:"branch,omitempty"`
+	Mode string `json:"mode"`
+	Diff string `json:"diff"`
+	// Hunks splits Diff by hunk, each with the findings it addresses.
+	Hunks  []patch.Hunk `json:"hunks"`
+	PRURL  string       `json:"pr_url,omitempty"`
+	Branch string       `json:"branch,omitempty"`
+	// Issues are the closing keywords and issue keys added to the PR
+	// description and commit message.
+	Issues []string `json:"issues,omitempty"`
+	// Warnings are the options that could not be applied to the PR opened.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type repoRow struct {
-	URL string
+	URL            string
+	DefaultRef     string
+	InstallationID int64
 }
 
 func (s *Service) Create(ctx context.Context, req Request) (Response, error) {
 	var repo repoRow
-	if err := s.db.QueryRow(ctx, `SELECT url FROM repos WHERE id=$1`, req.RepoID).Scan(&repo.URL); err != nil {
+	if err := s.db.QueryRow(ctx, `SELECT url, COALESCE(default_ref,''), COALESCE(installation_id, 0) FROM repos WHERE id=$1`, req.RepoID).
+		Scan(&repo.URL, &repo.DefaultRef, &repo.InstallationID); err != nil {
 		return Response{}, fmt.Errorf("repo not found")
 	}
-	if !strings.HasPrefix(strings.ToLower(repo.URL), "https://github.com/") || !strings.HasSuffix(strings.ToLower(repo.URL), ".git") {
-		return Response{}, fmt.Errorf("only github.com .git repos are supported")
+	if !s.hosts.supports(repo.URL) {
+		return Response{}, fmt.Errorf("only .git repos on %s or %s, and Azure Repos, are supported", s.hosts.GitHub.URL(), s.hosts.GitLab.URL())
 	}
 
-	findings, err := s.loadFindings(ctx, req.RepoID, req.MaxFixes)
+	findings, err := s.loadFindings(ctx, req.RepoID, req.MaxFixes, req.MinSeverity != "")
 	if err != nil {
 		return Response{}, err
 	}
+	findings, belowThreshold := patch.SplitBySeverity(findings, req.MinSeverity)
 	workDir := filepath.Join(os.TempDir(), "argus-pr", fmt.Sprintf("%d", time.Now().UnixNano()))
 	if err := os.MkdirAll(workDir, 0o755); err != nil {
 		return Response{}, err
 	}
 	defer os.RemoveAll(workDir)
 
+	// An explicit base branch wins, then the repo's pinned default_ref; only
+	// when neither is set do we ask the code host for the default branch.
+	base := strings.TrimSpace(req.BaseBranch)
+	if base == "" {
+		base = repo.DefaultRef
+	}
+
+	// Fixes go on top of the repo's open fix PR into base when there is
+	// one, so they are cloned from its branch. A dry run previews the same
+	// push when it can ask the code host, and otherwise diffs against base.
+	var host Provider
+	var open *PullRequest
+	var warnings []string
+	if req.Confirm {
+		if host, err = s.provider(ctx, repo.URL, repo.InstallationID); err != nil {
+			return Response{}, err
+		}
+		if base == "" {
+			if base, err = host.DefaultBranch(ctx); err != nil {
+				return Response{}, err
+			}
+		}
+		if open, err = s.openFixPR(ctx, req.RepoID, host, base); err != nil {
+			return Response{}, err
+		}
+	} else if host, err = s.provider(ctx, repo.URL, repo.InstallationID); err == nil {
+		if open, err = s.previewFixPR(ctx, req.RepoID, host, base); err != nil {
+			warnings = append(warnings, "open fix PR not checked; previewed against the base branch: "+err.Error())
+		}
+	}
+	cloneRef := base
+	if open != nil {
+		cloneRef = open.Head
+	}
+
 	repoDir := filepath.Join(workDir, "repo")
 	cloneCtx, cancel := context.WithTimeout(ctx, 3*time.Minute)
 	defer cancel()
-	if err := cloneRepo(cloneCtx, repo.URL, repoDir); err != nil {
+	if err := cloneRepo(cloneCtx, repo.URL, cloneRef, repoDir, s.proxy); err != nil {
 		return Response{}, err
 	}
-	if err := enforceSizeCap(repoDir, 350); err != nil {
+	if err := enforceSizeCap(repoDir, s.maxCloneMB); err != nil {
 		return Response{}, err
 	}
 
-	diffText, plan, _, err := GenerateDryRunDiff(repoDir, findings, req.MaxFixes)
+	enabled, err := flags.ForRepo(ctx, s.db, req.RepoID)
 	if err != nil {
 		return Response{}, err
 	}
+	diffText, plan, applied, err := GenerateDryRunDiff(repoDir, findings, req.MaxFixes, enabled)
+	if err != nil {
+		return Response{}, err
+	}
+	plan.Manual = append(plan.Manual, belowThreshold...)
+	hunks := patch.AnnotateHunks(diffText, applied.Applied)
 	if strings.TrimSpace(diffText) == "" {
 		diffText = "# No safe automatic changes available\n"
 	}
 
+	issues := issueLines(applied.Applied)
 	mode := "dry-run"
 	prURL := ""
 	branch := ""
-	if req.Confirm {
-		gh, err := githubapp.NewFromEnv()
-		if err != nil {
-			return Response{}, err
+	switch {
+	case !req.Confirm:
+		if open != nil {
+			prURL, branch = open.URL, open.Head
 		}
-		if err := githubapp.ValidateGitHubAppIDs(os.Getenv("GITHUB_APP_ID"), os.Getenv("GITHUB_INSTALLATION_ID")); err != nil {
+	case open != nil && len(hunks) == 0:
+		// Nothing new to fix: the open PR stays as it is.
+		mode, prURL, branch = "unchanged", open.URL, open.Head
+	case open != nil:
+		branch = open.Head
+		user, token := host.PushAuth()
+		if err := commitAndPush(ctx, repoDir, workDir, repo.URL, branch, commitMessage(issues), user, token, s.committer, s.proxy); err != nil {
 			return Response{}, err
 		}
-		token, err := gh.InstallationToken()
-		if err != nil {
+		body := updatedPRBody(open.Body, hunks, plan.Manual, issues, time.Now())
+		if err := host.EditPullRequest(ctx, *open, body); err != nil {
 			return Response{}, err
 		}
-		owner, repoName, err := githubapp.ParseGitHubURL(repo.URL)
-		if err != nil {
-			return Response{}, err
-		}
-		base := strings.TrimSpace(req.BaseBranch)
-		if base == "" {
-			base, err = gh.GetDefaultBranch(owner, repoName, token)
-			if err != nil {
-				return Response{}, err
-			}
-		}
-		sha, err := gh.GetBranchSHA(owner, repoName, base, token)
-		if err != nil {
-			return Response{}, err
+		if !req.Options.empty() {
+			warnings = append(warnings, "draft, labels and reviewers are only set on new pull requests")
 		}
+		mode, prURL = "updated", open.URL
+	case req.Confirm:
 		branch = fmt.Sprintf("argus/fix-%d", time.Now().Unix())
-		if err := gh.CreateRef(owner, repoName, "refs/heads/"+branch, sha, token); err != nil {
+		if err := host.CreateBranch(ctx, branch, base); err != nil {
 			return Response{}, err
 		}
 
-		if err := commitAndPush(ctx, repoDir, repo.URL, branch, token); err != nil {
+		user, token := host.PushAuth()
+		if err := commitAndPush(ctx, repoDir, workDir, repo.URL, branch, commitMessage(issues), user, token, s.committer, s.proxy); err != nil {
 			return Response{}, err
 		}
 
-		body := buildPRBody(diffText, plan.Manual)
+		body := buildPRBody(hunks, findings, plan.Manual, issues)
 		title := req.Title
 		if strings.TrimSpace(title) == "" {
 			title = "Argus: Fix findings"
 		}
-		prURL, err = gh.CreatePullRequest(owner, repoName, title, branch, base, body, token)
+		prURL, warnings, err = host.OpenPullRequest(ctx, title, branch, base, body, req.Options)
 		if err != nil {
 			return Response{}, err
 		}
 		mode = "created"
 	}
 
-	if err := s.recordPR(ctx, req, mode, branch, prURL, diffText); err != nil {
+	if err := s.recordPR(ctx, req, mode, branch, prURL, diffText, hunks); err != nil {
 		return Response{}, err
 	}
 
-	return Response{Mode: mode, Diff: diffText, PRURL: prURL, Branch: branch}, nil
+	return Response{Mode: mode, Diff: diffText, Hunks: hunks, PRURL: prURL, Branch: branch, Issues: issues, Warnings: warnings}, nil
 }
 
-func (s *Service) loadFindings(ctx context.Context, repoID string, max int) ([]patch.Finding, error) {
+// severityOrder ranks findings the same way as patch.SeverityRank.
+const severityOrder = `CASE upper(severity) WHEN 'CRITICAL' THEN 4 WHEN 'HIGH' THEN 3 WHEN 'ERROR' THEN 3 WHEN 'MEDIUM' THEN 2 WHEN 'WARNING' THEN 2 WHEN 'MODERATE' THEN 2 WHEN 'LOW' THEN 1 WHEN 'INFO' THEN 1 WHEN 'NOTE' THEN 1 ELSE 0 END`
+
+// loadFindings loads up to max of the repo's open findings to fix, the
+// latest first or, with bySeverity, the most severe.
+func (s *Service) loadFindings(ctx context.Context, repoID string, max int, bySeverity bool) ([]patch.Finding, error) {
 	if max <= 0 {
 		max = 10
 	}
-	rows, err := s.db.Query(ctx, `SELECT tool::text, title, COALESCE(file_path,''), COALESCE(line_start,0) FROM findings WHERE repo_id=$1 ORDER BY created_at DESC LIMIT $2`, repoID, max)
+	order := `created_at DESC`
+	if bySeverity {
+		// With a threshold, the most severe findings must fill the plan
+		// first instead of whatever was reported most recently.
+		order = severityOrder + ` DESC, created_at DESC`
+	}
+	rows, err := s.db.Query(ctx, `SELECT COALESCE(f.public_id,''), f.tool::text, f.severity, f.title, COALESCE(f.file_path,''), COALESCE(f.line_start,0), COALESCE(f.line_end,0), rp.url, COALESCE(j.commit_sha,''),
+			CASE WHEN jsonb_typeof(f.evidence_json->'issue_refs') = 'array' THEN ARRAY(SELECT jsonb_array_elements_text(f.evidence_json->'issue_refs')) ELSE '{}' END
+		FROM findings f JOIN repos rp ON rp.id = f.repo_id JOIN jobs j ON j.id = f.job_id
+		WHERE f.repo_id=$1 AND f.status='open' ORDER BY `+order+` LIMIT $2`, repoID, max)
 	if err != nil {
 		return nil, err
 	}
@@ -149,9 +239,12 @@ func (s *Service) loadFindings(ctx context.Context, repoID string, max int) ([]p
 	out := make([]patch.Finding, 0)
 	for rows.Next() {
 		var f patch.Finding
-		if err := rows.Scan(&f.Tool, &f.Title, &f.FilePath, &f.LineStart); err != nil {
+		var lineEnd int
+		var repoURL, sha string
+		if err := rows.Scan(&f.ID, &f.Tool, &f.Severity, &f.Title, &f.FilePath, &f.LineStart, &lineEnd, &repoURL, &sha, &f.IssueRefs); err != nil {
 			return nil, err
 		}
+		f.Permalink = githubapp.Permalink(repoURL, sha, f.FilePath, f.LineStart, lineEnd)
 		out = append(out, f)
 	}
 	if len(out) == 0 {
@@ -160,8 +253,47 @@ func (s *Service) loadFindings(ctx context.Context, repoID string, max int) ([]p
 	return out, nil
 }
 
-func (s *Service) recordPR(ctx context.Context, req Request, status, branch, prURL, diffText string) error {
-	_, err := s.db.Exec(ctx, `INSERT INTO prs (repo_id, job_id, status, branch, pr_url, diff_text) VALUES ($1, NULL, $2, $3, $4, $5)`, req.RepoID, status, nullIfEmpty(branch), nullIfEmpty(prURL), diffText)
+// maxTrackedPRs caps the fix PRs openFixPR asks the code host about.
+const maxTrackedPRs = 5
+
+// openFixPR returns the repo's most recent fix PR into base that is still
+// open, or nil. The fix PRs are those recorded as created; the code host
+// tells which are open and what they merge into.
+func (s *Service) openFixPR(ctx context.Context, repoID string, host Provider, base string) (*PullRequest, error) {
+	rows, err := s.db.Query(ctx, `SELECT branch FROM prs WHERE repo_id=$1 AND status='created' AND branch IS NOT NULL
+		GROUP BY branch ORDER BY max(created_at) DESC LIMIT $2`, repoID, maxTrackedPRs)
+	if err != nil {
+		return nil, err
+	}
+	branches, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range branches {
+		pr, err := host.FindPullRequest(ctx, branch)
+		if err != nil {
+			return nil, err
+		}
+		if pr != nil && pr.Base == base {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// previewFixPR is openFixPR for a dry run, which may leave base empty.
+func (s *Service) previewFixPR(ctx context.Context, repoID string, host Provider, base string) (*PullRequest, error) {
+	if base == "" {
+		var err error
+		if base, err = host.DefaultBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s.openFixPR(ctx, repoID, host, base)
+}
+
+func (s *Service) recordPR(ctx context.Context, req Request, status, branch, prURL, diffText string, hunks []patch.Hunk) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO prs (repo_id, job_id, status, branch, pr_url, diff_text, hunks) VALUES ($1, NULL, $2, $3, $4, $5, $6)`, req.RepoID, status, nullIfEmpty(branch), nullIfEmpty(prURL), diffText, hunks)
 	return err
 }
 
@@ -172,9 +304,14 @@ func nullIfEmpty(v string) any {
 	return v
 }
 
-func cloneRepo(ctx context.Context, repoURL, repoDir string) error {
-	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--filter=blob:none", "--no-tags", repoURL, repoDir)
-	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+func cloneRepo(ctx context.Context, repoURL, ref, repoDir string, p proxy.Setting) error {
+	args := []string{"clone", "--depth", "1", "--filter=blob:none", "--no-tags"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, repoURL, repoDir)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("git clone failed: %w: %s", err, string(out))
@@ -202,19 +339,44 @@ func enforceSizeCap(repoDir string, maxMB int) error {
 	return nil
 }
 
-func commitAndPush(ctx context.Context, repoDir, repoURL, branch, token string) error {
-	authURL := strings.Replace(repoURL, "https://", "https://x-access-token:"+token+"@", 1)
+// commitAndPush commits the fixes in repoDir to branch as c and pushes it.
+// A signing key is kept under workDir, outside the repo.
+func commitAndPush(ctx context.Context, repoDir, workDir, repoURL, branch, message, user, token string, c Committer, p proxy.Setting) error {
+	authURL := strings.Replace(repoURL, "https://", "https://"+user+":"+token+"@", 1)
+	env := append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
 	cmds := [][]string{
-		{"git", "-C", repoDir, "checkout", "-b", branch},
-		{"git", "-C", repoDir, "config", "user.email", "argus[bot]@users.noreply.github.com"},
-		{"git", "-C", repoDir, "config", "user.name", "argus[bot]"},
+		{"git", "-C", repoDir, "checkout", "-B", branch},
+		{"git", "-C", repoDir, "config", "user.email", c.Email},
+		{"git", "-C", repoDir, "config", "user.name", c.Name},
 		{"git", "-C", repoDir, "add", "-A"},
-		{"git", "-C", repoDir, "commit", "-m", "Argus: apply safe automatic fixes"},
-		{"git", "-C", repoDir, "push", authURL, "HEAD:" + branch},
 	}
+	if err := runGit(ctx, env, cmds); err != nil {
+		return err
+	}
+	if c.Key != nil {
+		keyDir := filepath.Join(workDir, "signing")
+		if err := os.Mkdir(keyDir, 0o700); err != nil {
+			return err
+		}
+		signEnv, cleanup, err := c.Key.Setup(ctx, repoDir, keyDir)
+		defer cleanup()
+		if err != nil {
+			return fmt.Errorf("commit signing: %w", err)
+		}
+		env = append(env, signEnv...)
+	}
+	return runGit(ctx, env, [][]string{
+		{"git", "-C", repoDir, "commit", "-m", message},
+		{"git", "-C", repoDir, "push", authURL, "HEAD:" + branch},
+	})
+}
+
+// runGit runs cmds in order with env. Their output is not returned, as it
+// may hold the push URL's token.
+func runGit(ctx context.Context, env []string, cmds [][]string) error {
 	for _, args := range cmds {
 		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
-		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		cmd.Env = env
 		out, err := cmd.CombinedOutput()
 		if err != nil {
 			if strings.Contains(string(out), "nothing to commit") {
@@ -226,14 +388,181 @@ func commitAndPush(ctx context.Context, repoDir, repoURL, branch, token string)
 	return nil
 }
 
-func buildPRBody(diff string, manual []patch.ManualItem) string {
+// maxBodyDiff caps the diff text in a PR body; the full diff is on the
+// branch.
+const maxBodyDiff = 8000
+
+// issueRef matches the GitHub issue references GitHub closes on merge;
+// anything else is a Jira key.
+var issueRef = regexp.MustCompile(`^(?:[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)?#[0-9]+$`)
+
+// issueLines lists the issues tracking the findings the applied fixes
+// address, in order of first appearance: "Fixes #123" for GitHub issues,
+// so merging the PR closes them, and the bare key for Jira issues, which
+// Jira's GitHub integration links by key.
+func issueLines(applied []patch.FixAction) []string {
+	var lines []string
+	seen := map[string]bool{}
+	for _, a := range applied {
+		for _, f := range a.Findings {
+			for _, ref := range f.IssueRefs {
+				if seen[ref] {
+					continue
+				}
+				seen[ref] = true
+				if issueRef.MatchString(ref) {
+					ref = "Fixes " + ref
+				}
+				lines = append(lines, ref)
+			}
+		}
+	}
+	return lines
+}
+
+// maxPRBody is the longest PR description GitHub accepts.
+const maxPRBody = 65536
+
+// updatedPRBody is the body of a fix PR Argus pushed more fixes to: its
+// previous body with the new linked issues added, followed by a section for
+// the push. Each push adds its own section, so the body keeps describing
+// every fix on the branch. When the body would grow too long, the section
+// leaves out its diffs.
+func updatedPRBody(prev string, hunks []patch.Hunk, manual []patch.ManualItem, issues []string, now time.Time) string {
+	body := setLinkedIssues(prev, mergeIssues(linkedIssues(prev), issues))
+	section := "\n\n## Update " + now.UTC().Format("2006-01-02 15:04 UTC") + "\n\nArgus pushed further fixes to this branch."
+	if len(manual) > 0 {
+		b, _ := json.MarshalIndent(manual, "", "  ")
+		section += "\n\n### Manual items\n```json\n" + string(b) + "\n```"
+	}
+	if changes := changesText(hunks); len(body)+len(section)+len(changes) <= maxPRBody {
+		section += changes
+	} else {
+		section += fmt.Sprintf(" It changed %d hunks; see the branch for them.", len(hunks))
+	}
+	return body + section
+}
+
+// setLinkedIssues replaces the linked issues section of a body buildPRBody
+// wrote with issues, adding one after its first paragraph when it has none.
+func setLinkedIssues(body string, issues []string) string {
+	if len(issues) == 0 {
+		return body
+	}
+	section := "\n\n## Linked issues\n" + strings.Join(issues, "\n")
+	if head, rest, ok := strings.Cut(body, "\n\n## Linked issues\n"); ok {
+		_, tail, _ := strings.Cut(rest, "\n\n")
+		if tail != "" {
+			tail = "\n\n" + tail
+		}
+		return head + section + tail
+	}
+	head, tail, _ := strings.Cut(body, "\n\n")
+	if tail != "" {
+		tail = "\n\n" + tail
+	}
+	return head + section + tail
+}
+
+// linkedIssues returns the issue lines of a PR body buildPRBody wrote.
+func linkedIssues(body string) []string {
+	_, section, ok := strings.Cut(body, "\n\n## Linked issues\n")
+	if !ok {
+		return nil
+	}
+	section, _, _ = strings.Cut(section, "\n\n")
+	return strings.Split(section, "\n")
+}
+
+// mergeIssues appends the lines of more that issues lacks.
+func mergeIssues(issues, more []string) []string {
+	for _, line := range more {
+		if !slices.Contains(issues, line) {
+			issues = append(issues, line)
+		}
+	}
+	return issues
+}
+
+func commitMessage(issues []string) string {
+	msg := "Argus: apply safe automatic fixes"
+	if len(issues) > 0 {
+		msg += "\n\n" + strings.Join(issues, "\n")
+	}
+	return msg
+}
+
+func buildPRBody(hunks []patch.Hunk, findings []patch.Finding, manual []patch.ManualItem, issues []string) string {
+	findingsText := ""
+	for i, f := range findings {
+		if i == 0 {
+			findingsText = "\n\n## Findings"
+		}
+		if i == 25 {
+			findingsText += fmt.Sprintf("\n- ... and %d more", len(findings)-i)
+			break
+		}
+		loc := f.FilePath
+		if f.LineStart > 0 {
+			loc = fmt.Sprintf("%s:%d", f.FilePath, f.LineStart)
+		}
+		if f.Permalink != "" {
+			loc = fmt.Sprintf("[%s](%s)", loc, f.Permalink)
+		}
+		line := fmt.Sprintf("%s (%s) %s", f.Title, f.Tool, loc)
+		if f.Severity != "" {
+			line = "**" + f.Severity + "** " + line
+		}
+		findingsText += "\n- " + line
+	}
 	manualText := ""
 	if len(manual) > 0 {
 		b, _ := json.MarshalIndent(manual, "", "  ")
 		manualText = "\n\n## Manual items\n```json\n" + string(b) + "\n```"
 	}
-	if len(diff) > 8000 {
-		diff = diff[:8000] + "\n... (truncated)"
+	issuesText := ""
+	if len(issues) > 0 {
+		issuesText = "\n\n## Linked issues\n" + strings.Join(issues, "\n")
+	}
+	return "Automated safe fixes generated by Argus." + issuesText + findingsText + manualText + "\n\n## Changes" + changesText(hunks)
+}
+
+// changesText lists each diff hunk under the findings it addresses.
+func changesText(hunks []patch.Hunk) string {
+	if len(hunks) == 0 {
+		return "\n\nNo safe automatic changes available."
+	}
+	out, size := "", 0
+	for i, h := range hunks {
+		if size+len(h.Diff) > maxBodyDiff {
+			out += fmt.Sprintf("\n\n... and %d more hunks; see the branch for the full diff.", len(hunks)-i)
+			break
+		}
+		size += len(h.Diff)
+		out += fmt.Sprintf("\n\n### `%s` %s", h.File, h.Header)
+		for _, fix := range h.Fixes {
+			out += "\n- Fix: " + fix
+		}
+		for _, f := range h.Findings {
+			line := fmt.Sprintf("%s (%s)", f.Title, f.Tool)
+			if f.Permalink != "" {
+				line = fmt.Sprintf("[%s](%s) (%s)", f.Title, f.Permalink, f.Tool)
+			}
+			if f.Severity != "" {
+				line = "**" + f.Severity + "** " + line
+			}
+			if f.ID != "" {
+				line += " `" + f.ID + "`"
+			}
+			out += "\n- Addresses: " + line
+		}
+		switch {
+		case len(h.Fixes) == 0:
+			out += "\n- Not produced by a planned fix; review it by hand"
+		case len(h.Findings) == 0:
+			out += "\n- Addresses: no specific finding (hardening)"
+		}
+		out += "\n```diff\n" + strings.TrimRight(h.Diff, "\n") + "\n```"
 	}
-	return "Automated safe fixes generated by Argus." + manualText + "\n\n## Diff preview\n```diff\n" + diff + "\n```"
+	return out
 }
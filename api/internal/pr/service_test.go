@@ -0,0 +1,166 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"argus/api/internal/patch"
+	"argus/internal/jobsql"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestIssueLines(t *testing.T) {
+	applied := []patch.FixAction{
+		{Findings: []patch.Finding{{Title: "a", IssueRefs: []string{"#123", "PROJ-42"}}}},
+		{Findings: []patch.Finding{{Title: "b", IssueRefs: []string{"acme/api#7", "#123"}}, {Title: "c"}}},
+	}
+	issues := issueLines(applied)
+	if want := []string{"Fixes #123", "PROJ-42", "Fixes acme/api#7"}; !reflect.DeepEqual(issues, want) {
+		t.Fatalf("issues = %v", issues)
+	}
+	if msg := commitMessage(issues); msg != "Argus: apply safe automatic fixes\n\nFixes #123\nPROJ-42\nFixes acme/api#7" {
+		t.Errorf("message = %q", msg)
+	}
+	if msg := commitMessage(nil); msg != "Argus: apply safe automatic fixes" {
+		t.Errorf("message = %q", msg)
+	}
+	body := buildPRBody(nil, nil, nil, issues)
+	if !strings.Contains(body, "## Linked issues\nFixes #123\nPROJ-42\nFixes acme/api#7") {
+		t.Errorf("body = %s", body)
+	}
+	if body := buildPRBody(nil, nil, nil, nil); strings.Contains(body, "Linked issues") {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestLinkedIssuesKeptOnUpdate(t *testing.T) {
+	body := buildPRBody(nil, []patch.Finding{{Title: "a", Tool: "semgrep"}}, nil, []string{"Fixes #123", "PROJ-42"})
+	prev := linkedIssues(body)
+	if want := []string{"Fixes #123", "PROJ-42"}; !reflect.DeepEqual(prev, want) {
+		t.Fatalf("linkedIssues = %v", prev)
+	}
+	if issues := mergeIssues(prev, []string{"PROJ-42", "Fixes #9"}); !reflect.DeepEqual(issues, []string{"Fixes #123", "PROJ-42", "Fixes #9"}) {
+		t.Errorf("mergeIssues = %v", issues)
+	}
+	if issues := linkedIssues(buildPRBody(nil, nil, nil, nil)); issues != nil {
+		t.Errorf("linkedIssues without a section = %v", issues)
+	}
+}
+
+func TestUpdatedPRBody(t *testing.T) {
+	first := buildPRBody([]patch.Hunk{{File: "a.go", Header: "@@ -1 +1 @@", Diff: "-old\n+new\n"}}, nil, nil, []string{"Fixes #1"})
+	now := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	body := updatedPRBody(first, []patch.Hunk{{File: "b.go", Header: "@@ -2 +2 @@", Diff: "-x\n+y\n"}}, nil, []string{"Fixes #2"}, now)
+	if !strings.Contains(body, "### `a.go`") || !strings.Contains(body, "## Update 2026-10-17 09:30 UTC") || !strings.Contains(body, "### `b.go`") {
+		t.Errorf("body lost a push:\n%s", body)
+	}
+	if issues := linkedIssues(body); !reflect.DeepEqual(issues, []string{"Fixes #1", "Fixes #2"}) {
+		t.Errorf("linkedIssues = %v", issues)
+	}
+	body = updatedPRBody(body, nil, nil, []string{"PROJ-7"}, now.Add(time.Hour))
+	if strings.Count(body, "## Update ") != 2 || !strings.Contains(body, "### `b.go`") {
+		t.Errorf("second update lost a push:\n%s", body)
+	}
+
+	// A body with no linked issues gets them after its first paragraph.
+	body = updatedPRBody(buildPRBody(nil, nil, nil, nil), nil, nil, []string{"Fixes #3"}, now)
+	if !strings.HasPrefix(body, "Automated safe fixes generated by Argus.\n\n## Linked issues\nFixes #3\n\n## Changes") {
+		t.Errorf("body = %s", body)
+	}
+
+	// Past the size GitHub accepts, a push leaves its diffs out.
+	big := buildPRBody(nil, nil, nil, nil) + strings.Repeat("x", maxPRBody)
+	if body := updatedPRBody(big, []patch.Hunk{{File: "c.go", Diff: "-a\n+b\n"}}, nil, nil, now); strings.Contains(body, "c.go") {
+		t.Error("diff added past the body limit")
+	}
+}
+
+// testDB connects to ARGUS_TEST_DATABASE_URL and applies the migrations in
+// a schema of the test's own, which is dropped afterwards. The database
+// needs the pgcrypto and vector extensions.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("ARGUS_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("ARGUS_TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	schema := fmt.Sprintf("argus_test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	db, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	migrations, err := filepath.Glob("../../../db/init/*.sql")
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("migrations: %v", err)
+	}
+	sort.Strings(migrations)
+	for _, m := range migrations {
+		sql, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(m), err)
+		}
+	}
+	return db
+}
+
+func TestLoadFindingsSkipsFixed(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	var repoID, first, second string
+	if err := db.QueryRow(ctx, `INSERT INTO repos (name, url) VALUES ('web', 'https://github.com/acme/web.git') RETURNING id::text`).Scan(&repoID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []*string{&first, &second} {
+		if err := db.QueryRow(ctx, `INSERT INTO jobs (repo_id, status) VALUES ($1, 'succeeded') RETURNING id::text`, repoID).Scan(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert := func(jobID, title, fingerprint string) {
+		if _, err := db.Exec(ctx, jobsql.InsertFinding, repoID, jobID, "semgrep", "HIGH", title, "app.go", 1, 1, fingerprint, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert(first, "gone", "fp-gone")
+	insert(second, "still there", "fp-kept")
+	// The second scan no longer reports the first finding, so it is fixed.
+	if _, err := db.Exec(ctx, jobsql.ResolveFindings, repoID, second, []string{"semgrep"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{db: db}
+	findings, err := s.loadFindings(ctx, repoID, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Title != "still there" {
+		t.Errorf("findings = %+v", findings)
+	}
+}
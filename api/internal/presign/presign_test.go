@@ -0,0 +1,30 @@
+package presign
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	s := New("secret")
+	now := time.Unix(1_700_000_000, 0)
+	q := s.Query("/artifacts/a1", now, 15*time.Minute)
+
+	if err := s.Verify("/artifacts/a1", q, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("/artifacts/a2", q, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("other path: %v", err)
+	}
+	if err := s.Verify("/artifacts/a1", q, now.Add(16*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expired: %v", err)
+	}
+	if err := New("other").Verify("/artifacts/a1", q, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("other key: %v", err)
+	}
+	q.Set("expires", "9999999999")
+	if err := s.Verify("/artifacts/a1", q, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered expiry: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package schedule parses five-field cron expressions and evaluates them in a
+// caller-supplied time zone.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type field uint64
+
+func (f field) has(v int) bool { return f&(1<<uint(v)) != 0 }
+
+type Spec struct {
+	raw    string
+	minute field
+	hour   field
+	dom    field
+	month  field
+	dow    field
+	// Standard cron semantics: when both day-of-month and day-of-week are
+	// restricted, a day matches if either does.
+	domStar bool
+	dowStar bool
+}
+
+var aliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// Parse accepts "minute hour day-of-month month day-of-week" with *, lists,
+// ranges and steps, plus the @hourly/@daily/@weekly/@monthly aliases.
+func Parse(expr string) (Spec, error) {
+	raw := strings.TrimSpace(expr)
+	if a, ok := aliases[strings.ToLower(raw)]; ok {
+		raw = a
+	}
+	parts := strings.Fields(raw)
+	if len(parts) != 5 {
+		return Spec{}, fmt.Errorf("cron expression must have 5 fields")
+	}
+	s := Spec{raw: strings.TrimSpace(expr)}
+	var err error
+	if s.minute, err = parseField(parts[0], 0, 59); err != nil {
+		return Spec{}, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseField(parts[1], 0, 23); err != nil {
+		return Spec{}, fmt.Errorf("hour: %w", err)
+	}
+	if s.dom, err = parseField(parts[2], 1, 31); err != nil {
+		return Spec{}, fmt.Errorf("day-of-month: %w", err)
+	}
+	if s.month, err = parseField(parts[3], 1, 12); err != nil {
+		return Spec{}, fmt.Errorf("month: %w", err)
+	}
+	if s.dow, err = parseField(parts[4], 0, 7); err != nil {
+		return Spec{}, fmt.Errorf("day-of-week: %w", err)
+	}
+	if s.dow.has(7) {
+		s.dow |= 1 // 7 is an alias for Sunday
+	}
+	s.domStar = parts[2] == "*"
+	s.dowStar = parts[4] == "*"
+	return s, nil
+}
+
+func (s Spec) String() string { return s.raw }
+
+func parseField(expr string, min, max int) (field, error) {
+	var f field
+	for _, item := range strings.Split(expr, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			if hi, err = strconv.Atoi(b); err != nil {
+				return 0, fmt.Errorf("invalid value %q", b)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rng)
+			}
+			lo, hi = n, n
+			if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func (s Spec) dayMatches(t time.Time) bool {
+	dom := s.dom.has(t.Day())
+	dow := s.dow.has(int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first activation strictly after `after`, evaluating the
+// expression in loc (so "0 2 * * *" means 02:00 local wall-clock time). The
+// zero time is returned if nothing matches within five years.
+func (s Spec) Next(after time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t := after.In(loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+		case !s.dayMatches(t):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+		case !s.hour.has(t.Hour()):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// advance guards against wall-clock arithmetic going backwards across DST
+// transitions.
+func advance(cur, next time.Time) time.Time {
+	if !next.After(cur) {
+		return cur.Add(time.Hour).Truncate(time.Hour)
+	}
+	return next
+}
@@ -0,0 +1,74 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextEvaluatesInLocalTime(t *testing.T) {
+	spec, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	after := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC) // 22:00 in Sydney
+	got := spec.Next(after, sydney)
+	want := time.Date(2024, 6, 11, 2, 0, 0, 0, sydney)
+	if !got.Equal(want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got.UTC().Hour() != 16 {
+		t.Fatalf("expected 16:00 UTC, got %s", got.UTC())
+	}
+}
+
+func TestNextFieldCombinations(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // Monday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 6 15 * 5", time.Date(2024, 1, 5, 6, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		spec, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := spec.Next(base, time.UTC); !got.Equal(c.want) {
+			t.Fatalf("%s: got %s, want %s", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestNextSkipsNonexistentLocalTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	spec, _ := Parse("30 2 * * *")
+	// 2024-03-10 02:30 does not exist in New York; the run moves forward
+	// rather than looping or firing twice.
+	after := time.Date(2024, 3, 9, 12, 0, 0, 0, ny)
+	got := spec.Next(after, ny)
+	if !got.After(after) || got.Sub(after) > 48*time.Hour {
+		t.Fatalf("unexpected next run %s", got)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package sizing classifies scan jobs as small, medium or large from the size
+// and language mix of the tree they scan, so they can be routed to worker
+// pools sized for them.
+package sizing
+
+const (
+	Small  = "small"
+	Medium = "medium"
+	Large  = "large"
+)
+
+// Classes lists the size classes from smallest to largest.
+var Classes = []string{Small, Medium, Large}
+
+// Valid reports whether class is a known size class.
+func Valid(class string) bool {
+	for _, c := range Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+type Thresholds struct {
+	SmallMaxMB        int // trees under this size may be small
+	SmallMaxLanguages int // ... if they use at most this many languages
+	LargeMinMB        int // trees of at least this size are large
+	LargeMinLanguages int // so are trees using at least this many languages
+}
+
+// Profile describes the scanned tree. Known is false before the repo's first
+// clone; such jobs are medium.
+type Profile struct {
+	Known     bool
+	SizeKB    int64
+	Languages int
+}
+
+// Classify returns the size class for a job scanning p.
+func Classify(p Profile, t Thresholds) string {
+	if !p.Known {
+		return Medium
+	}
+	sizeMB := p.SizeKB / 1024
+	switch {
+	case sizeMB >= int64(t.LargeMinMB) || p.Languages >= t.LargeMinLanguages:
+		return Large
+	case sizeMB < int64(t.SmallMaxMB) && p.Languages <= t.SmallMaxLanguages:
+		return Small
+	}
+	return Medium
+}
@@ -0,0 +1,23 @@
+package sizing
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	th := Thresholds{SmallMaxMB: 25, SmallMaxLanguages: 2, LargeMinMB: 500, LargeMinLanguages: 6}
+	for _, tc := range []struct {
+		name string
+		p    Profile
+		want string
+	}{
+		{"never cloned", Profile{}, Medium},
+		{"small service", Profile{Known: true, SizeKB: 4 * 1024, Languages: 1}, Small},
+		{"small but polyglot", Profile{Known: true, SizeKB: 4 * 1024, Languages: 3}, Medium},
+		{"at the small limit", Profile{Known: true, SizeKB: 25 * 1024, Languages: 1}, Medium},
+		{"monorepo by size", Profile{Known: true, SizeKB: 800 * 1024, Languages: 2}, Large},
+		{"monorepo by languages", Profile{Known: true, SizeKB: 60 * 1024, Languages: 7}, Large},
+	} {
+		if got := Classify(tc.p, th); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
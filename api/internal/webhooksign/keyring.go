@@ -0,0 +1,104 @@
+package webhooksign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"argus/api/sdk/webhook"
+)
+
+type key struct {
+	id   string
+	priv ed25519.PrivateKey
+}
+
+// Keyring holds the Ed25519 keys used to sign outbound webhook deliveries.
+// The first key signs; the rest stay published so deliveries signed before a
+// rotation still verify.
+type Keyring struct {
+	keys []key
+}
+
+// Parse reads a comma-separated list of "kid:base64-seed" entries, newest
+// (active) first, as found in WEBHOOK_SIGNING_KEYS.
+func Parse(spec string) (*Keyring, error) {
+	kr := &Keyring{}
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, seedB64, ok := strings.Cut(entry, ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("signing key entry must be kid:seed")
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate signing key id %q", id)
+		}
+		seed, err := decodeSeed(strings.TrimSpace(seedB64))
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", id, err)
+		}
+		seen[id] = true
+		kr.keys = append(kr.keys, key{id: id, priv: ed25519.NewKeyFromSeed(seed)})
+	}
+	if len(kr.keys) == 0 {
+		return nil, fmt.Errorf("no signing keys configured")
+	}
+	return kr, nil
+}
+
+// Ephemeral returns a single random key; deliveries signed with it cannot be
+// verified after a restart, so it is only suitable for local development.
+func Ephemeral() *Keyring {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	return &Keyring{keys: []key{{id: fmt.Sprintf("ephemeral-%d", time.Now().Unix()), priv: priv}}}
+}
+
+// GenerateSeed returns a new base64 seed suitable for WEBHOOK_SIGNING_KEYS.
+func GenerateSeed() string {
+	seed := make([]byte, ed25519.SeedSize)
+	_, _ = rand.Read(seed)
+	return base64.StdEncoding.EncodeToString(seed)
+}
+
+func (k *Keyring) ActiveKeyID() string { return k.keys[0].id }
+
+// Sign returns the X-Argus-Signature header value for body.
+func (k *Keyring) Sign(body []byte, now time.Time) string {
+	active := k.keys[0]
+	ts := now.Unix()
+	sig := ed25519.Sign(active.priv, webhook.SigningInput(ts, body))
+	return webhook.FormatHeader(ts, active.id, sig)
+}
+
+func (k *Keyring) PublicKeys() webhook.KeySet {
+	ks := webhook.KeySet{Keys: make([]webhook.PublicKey, 0, len(k.keys))}
+	for i, kk := range k.keys {
+		ks.Keys = append(ks.Keys, webhook.PublicKey{
+			ID:        kk.id,
+			Algorithm: webhook.Algorithm,
+			Key:       base64.RawURLEncoding.EncodeToString(kk.priv.Public().(ed25519.PublicKey)),
+			Active:    i == 0,
+		})
+	}
+	return ks
+}
+
+func decodeSeed(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			if len(b) != ed25519.SeedSize {
+				return nil, fmt.Errorf("seed must be %d bytes", ed25519.SeedSize)
+			}
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("seed is not valid base64")
+}
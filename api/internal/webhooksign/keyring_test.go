@@ -0,0 +1,52 @@
+package webhooksign
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"argus/api/sdk/webhook"
+)
+
+func TestSignVerifyAcrossRotation(t *testing.T) {
+	oldSeed, newSeed := GenerateSeed(), GenerateSeed()
+	before, err := Parse("k1:" + oldSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := Parse("k2:" + newSeed + ",k1:" + oldSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.ActiveKeyID() != "k2" {
+		t.Fatalf("expected newest key to be active, got %s", after.ActiveKeyID())
+	}
+
+	now := time.Now()
+	body := []byte(`{"event":"scan.completed"}`)
+	published := after.PublicKeys()
+
+	if err := webhook.Verify(body, before.Sign(body, now), published, 0, now); err != nil {
+		t.Fatalf("delivery signed before rotation should verify: %v", err)
+	}
+	if err := webhook.Verify(body, after.Sign(body, now), published, 0, now); err != nil {
+		t.Fatalf("delivery signed with active key should verify: %v", err)
+	}
+	if err := webhook.Verify([]byte(`{"event":"tampered"}`), after.Sign(body, now), published, 0, now); !errors.Is(err, webhook.ErrBadSignature) {
+		t.Fatalf("expected bad signature, got %v", err)
+	}
+	if err := webhook.Verify(body, after.Sign(body, now.Add(-time.Hour)), published, 0, now); !errors.Is(err, webhook.ErrExpired) {
+		t.Fatalf("expected expired signature, got %v", err)
+	}
+	if err := webhook.Verify(body, after.Sign(body, now), before.PublicKeys(), 0, now); !errors.Is(err, webhook.ErrUnknownKey) {
+		t.Fatalf("expected unknown key, got %v", err)
+	}
+}
+
+func TestParseRejectsBadSpecs(t *testing.T) {
+	for _, spec := range []string{"", "nokid", "k1:not-base64!!", "k1:" + GenerateSeed() + ",k1:" + GenerateSeed()} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package webhookverify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisNoncePrefix = "argus:webhook-nonce:"
+
+// RedisNonces shares the nonce cache between API replicas using SET NX.
+type RedisNonces struct {
+	Client *redis.Client
+}
+
+func (n RedisNonces) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	fresh, err := n.Client.SetNX(ctx, redisNoncePrefix+key, 1, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return !fresh, nil
+}
+
+// PostgresNonces shares the nonce cache through the webhook_nonces table,
+// for deployments without Redis. An expired key is taken over in place;
+// the API's garbage collection deletes the rest.
+type PostgresNonces struct {
+	DB *pgxpool.Pool
+}
+
+func (n PostgresNonces) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	tag, err := n.DB.Exec(ctx, `INSERT INTO webhook_nonces (key, expires_at) VALUES ($1, now() + make_interval(secs => $2))
+		ON CONFLICT (key) DO UPDATE SET expires_at = EXCLUDED.expires_at WHERE webhook_nonces.expires_at < now()`, key, ttl.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 0, nil
+}
+
+// MemoryNonces is a single-process store for tests and local development.
+type MemoryNonces struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	Now  func() time.Time
+}
+
+func (n *MemoryNonces) Seen(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	if n.Now != nil {
+		now = n.Now()
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.seen == nil {
+		n.seen = map[string]time.Time{}
+	}
+	for k, exp := range n.seen {
+		if now.After(exp) {
+			delete(n.seen, k)
+		}
+	}
+	if _, ok := n.seen[key]; ok {
+		return true, nil
+	}
+	n.seen[key] = now.Add(ttl)
+	return false, nil
+}
@@ -0,0 +1,263 @@
+// Package webhookverify authenticates inbound webhooks: it checks an HMAC
+// signature or shared secret, rejects stale timestamps and remembers
+// delivery nonces so a captured request cannot be replayed. Each integration
+// describes its header layout with a Scheme; the checks themselves are
+// shared.
+package webhookverify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTolerance = 5 * time.Minute
+	// DefaultNonceTTL bounds replay protection for schemes without a
+	// timestamp, where a nonce would otherwise have to be kept forever.
+	DefaultNonceTTL = 24 * time.Hour
+	DefaultMaxBody  = 5 << 20
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrBadSignature     = errors.New("webhook signature mismatch")
+	ErrMissingTimestamp = errors.New("missing webhook timestamp")
+	ErrExpired          = errors.New("webhook timestamp outside tolerance")
+	ErrMissingNonce     = errors.New("missing webhook delivery id")
+	ErrReplay           = errors.New("webhook delivery already processed")
+	ErrBodyTooLarge     = errors.New("webhook body too large")
+)
+
+// Scheme describes where an integration puts its signature, timestamp and
+// nonce. Empty TimestampHeader or NonceHeader disables that check.
+type Scheme struct {
+	Name            string
+	SignatureHeader string
+	// SignaturePrefix is stripped from the header before hex decoding.
+	SignaturePrefix string
+	TimestampHeader string
+	NonceHeader     string
+	// SignedTimestamp includes "<timestamp>." ahead of the body in the MAC
+	// input, binding the timestamp to the signature.
+	SignedTimestamp bool
+	// PlainSecret means SignatureHeader carries the secret itself rather
+	// than a signature of the body.
+	PlainSecret bool
+}
+
+// GitHub matches github.com and GHES webhook deliveries. GitHub does not
+// send a timestamp, so replay protection rests on X-GitHub-Delivery.
+var GitHub = Scheme{
+	Name:            "github",
+	SignatureHeader: "X-Hub-Signature-256",
+	SignaturePrefix: "sha256=",
+	NonceHeader:     "X-GitHub-Delivery",
+}
+
+// GitLab matches gitlab.com and self-managed webhook deliveries, which send
+// the configured secret token as is. There is no timestamp, so replay
+// protection rests on X-Gitlab-Event-UUID.
+var GitLab = Scheme{
+	Name:            "gitlab",
+	SignatureHeader: "X-Gitlab-Token",
+	NonceHeader:     "X-Gitlab-Event-UUID",
+	PlainSecret:     true,
+}
+
+// Generic is the scheme Argus asks other integrations to use: HMAC-SHA256
+// over "<unix-ts>.<body>".
+var Generic = Scheme{
+	Name:            "generic",
+	SignatureHeader: "X-Webhook-Signature",
+	SignaturePrefix: "sha256=",
+	TimestampHeader: "X-Webhook-Timestamp",
+	NonceHeader:     "X-Webhook-Id",
+	SignedTimestamp: true,
+}
+
+// NonceStore records nonces. Seen reports whether key was already recorded,
+// recording it if not; it must be atomic across API replicas.
+type NonceStore interface {
+	Seen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type Verifier struct {
+	Scheme Scheme
+	// Secrets are tried in order so a secret can be rotated without a gap.
+	Secrets   []string
+	Nonces    NonceStore
+	Tolerance time.Duration
+	NonceTTL  time.Duration
+	MaxBody   int64
+	Now       func() time.Time
+}
+
+// New returns a Verifier with default limits. A nil store disables the nonce
+// check.
+func New(scheme Scheme, secrets []string, nonces NonceStore) *Verifier {
+	return &Verifier{Scheme: scheme, Secrets: secrets, Nonces: nonces}
+}
+
+// Verify reads and authenticates r's body, returning it on success. The
+// nonce is only recorded once the signature has been checked, so forged
+// requests cannot burn legitimate delivery ids.
+func (v *Verifier) Verify(r *http.Request) ([]byte, error) {
+	maxBody := v.MaxBody
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBody
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBody {
+		return nil, ErrBodyTooLarge
+	}
+
+	s := v.Scheme
+	sigHex := strings.TrimSpace(r.Header.Get(s.SignatureHeader))
+	if sigHex == "" {
+		return nil, ErrMissingSignature
+	}
+	var sig []byte
+	if !s.PlainSecret {
+		if sig, err = hex.DecodeString(strings.TrimPrefix(sigHex, s.SignaturePrefix)); err != nil {
+			return nil, ErrBadSignature
+		}
+	}
+
+	var ts string
+	if s.TimestampHeader != "" {
+		ts = strings.TrimSpace(r.Header.Get(s.TimestampHeader))
+		if ts == "" {
+			return nil, ErrMissingTimestamp
+		}
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, ErrMissingTimestamp
+		}
+		if d := v.now().Sub(time.Unix(unix, 0)); d > v.tolerance() || d < -v.tolerance() {
+			return nil, ErrExpired
+		}
+	}
+
+	if s.PlainSecret {
+		if !v.isSecret(sigHex) {
+			return nil, ErrBadSignature
+		}
+	} else {
+		input := body
+		if s.SignedTimestamp {
+			input = append([]byte(ts+"."), body...)
+		}
+		if !v.matches(input, sig) {
+			return nil, ErrBadSignature
+		}
+	}
+
+	if s.NonceHeader != "" && v.Nonces != nil {
+		nonce := strings.TrimSpace(r.Header.Get(s.NonceHeader))
+		if nonce == "" {
+			return nil, ErrMissingNonce
+		}
+		seen, err := v.Nonces.Seen(r.Context(), s.Name+":"+nonce, v.nonceTTL())
+		if err != nil {
+			return nil, fmt.Errorf("nonce store: %w", err)
+		}
+		if seen {
+			return nil, ErrReplay
+		}
+	}
+	return body, nil
+}
+
+// Middleware verifies each request before next runs and replaces the body
+// with the verified bytes. Failures get 401, or 500 if the nonce store is
+// unreachable.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := v.Verify(r)
+		if err != nil {
+			code := http.StatusUnauthorized
+			switch {
+			case errors.Is(err, ErrBodyTooLarge):
+				code = http.StatusRequestEntityTooLarge
+			case !isVerifyError(err):
+				code = http.StatusInternalServerError
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *Verifier) matches(input, sig []byte) bool {
+	for _, secret := range v.Secrets {
+		if secret == "" {
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(input)
+		if hmac.Equal(mac.Sum(nil), sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecret compares token with each secret in constant time.
+func (v *Verifier) isSecret(token string) bool {
+	for _, secret := range v.Secrets {
+		if secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+func (v *Verifier) tolerance() time.Duration {
+	if v.Tolerance > 0 {
+		return v.Tolerance
+	}
+	return DefaultTolerance
+}
+
+// nonceTTL keeps nonces at least as long as a timestamp would be accepted.
+func (v *Verifier) nonceTTL() time.Duration {
+	if v.NonceTTL > 0 {
+		return v.NonceTTL
+	}
+	if v.Scheme.TimestampHeader != "" {
+		return 2 * v.tolerance()
+	}
+	return DefaultNonceTTL
+}
+
+func isVerifyError(err error) bool {
+	for _, e := range []error{ErrMissingSignature, ErrBadSignature, ErrMissingTimestamp, ErrExpired, ErrMissingNonce, ErrReplay} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package webhookverify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret, input string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubSignatureAndReplay(t *testing.T) {
+	body := `{"action":"opened"}`
+	v := New(GitHub, []string{"new-secret", "old-secret"}, &MemoryNonces{})
+
+	req := func(sig, delivery string) error {
+		r := httptest.NewRequest("POST", "/hooks/github", strings.NewReader(body))
+		r.Header.Set("X-Hub-Signature-256", sig)
+		r.Header.Set("X-GitHub-Delivery", delivery)
+		_, err := v.Verify(r)
+		return err
+	}
+
+	if err := req(sign("old-secret", body), "d1"); err != nil {
+		t.Fatalf("rotated-out secret should still verify: %v", err)
+	}
+	if err := req(sign("new-secret", body), "d1"); !errors.Is(err, ErrReplay) {
+		t.Fatalf("expected replay, got %v", err)
+	}
+	if err := req(sign("wrong", body), "d2"); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected bad signature, got %v", err)
+	}
+	// The forged attempt must not have consumed d2.
+	if err := req(sign("new-secret", body), "d2"); err != nil {
+		t.Fatalf("expected d2 to verify after a forged attempt: %v", err)
+	}
+	if err := req("", "d3"); !errors.Is(err, ErrMissingSignature) {
+		t.Fatalf("expected missing signature, got %v", err)
+	}
+	if err := req(sign("new-secret", body), ""); !errors.Is(err, ErrMissingNonce) {
+		t.Fatalf("expected missing nonce, got %v", err)
+	}
+}
+
+func TestGitLabToken(t *testing.T) {
+	v := New(GitLab, []string{"new-secret", "old-secret"}, &MemoryNonces{})
+	req := func(token, uuid string) error {
+		r := httptest.NewRequest("POST", "/webhooks/gitlab", strings.NewReader(`{"object_kind":"push"}`))
+		r.Header.Set("X-Gitlab-Token", token)
+		r.Header.Set("X-Gitlab-Event-UUID", uuid)
+		_, err := v.Verify(r)
+		return err
+	}
+	if err := req("old-secret", "u1"); err != nil {
+		t.Fatalf("rotated-out secret should still verify: %v", err)
+	}
+	if err := req("new-secret", "u1"); !errors.Is(err, ErrReplay) {
+		t.Fatalf("expected replay, got %v", err)
+	}
+	if err := req("new-secret-", "u2"); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected bad token, got %v", err)
+	}
+	if err := req(sign("new-secret", `{"object_kind":"push"}`), "u2"); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected a signature in place of the token to be rejected, got %v", err)
+	}
+}
+
+func TestGenericTimestampTolerance(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := `{"ok":true}`
+	v := New(Generic, []string{"s"}, &MemoryNonces{Now: func() time.Time { return now }})
+	v.Now = func() time.Time { return now }
+
+	req := func(ts time.Time, signedTS, id string) error {
+		r := httptest.NewRequest("POST", "/hooks/generic", strings.NewReader(body))
+		unix := strconv.FormatInt(ts.Unix(), 10)
+		r.Header.Set("X-Webhook-Timestamp", unix)
+		r.Header.Set("X-Webhook-Signature", sign("s", signedTS+"."+body))
+		r.Header.Set("X-Webhook-Id", id)
+		_, err := v.Verify(r)
+		return err
+	}
+
+	unix := strconv.FormatInt(now.Unix(), 10)
+	if err := req(now, unix, "a"); err != nil {
+		t.Fatalf("fresh delivery should verify: %v", err)
+	}
+	old := now.Add(-10 * time.Minute)
+	if err := req(old, strconv.FormatInt(old.Unix(), 10), "b"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expired, got %v", err)
+	}
+	// A timestamp swapped in after signing breaks the signature.
+	if err := req(now.Add(time.Minute), unix, "c"); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected bad signature for altered timestamp, got %v", err)
+	}
+}
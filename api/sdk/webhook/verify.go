@@ -0,0 +1,160 @@
+// Package webhook lets integrators verify deliveries sent by Argus outbound
+// webhooks. Deliveries are signed with Ed25519; the public half of every key
+// Argus may sign with is published at GET /webhooks/signing-keys, so no shared
+// secret has to be exchanged to validate a payload.
+package webhook
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader  = "X-Argus-Signature"
+	Algorithm        = "ed25519"
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrMalformedHeader = errors.New("malformed signature header")
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrBadSignature    = errors.New("signature mismatch")
+	ErrExpired         = errors.New("signature timestamp outside tolerance")
+)
+
+type PublicKey struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Key       string `json:"key"`
+	Active    bool   `json:"active"`
+}
+
+type KeySet struct {
+	Keys []PublicKey `json:"keys"`
+}
+
+// SigningInput is the exact byte sequence that gets signed: "<unix-ts>.<body>".
+func SigningInput(ts int64, body []byte) []byte {
+	out := make([]byte, 0, len(body)+24)
+	out = strconv.AppendInt(out, ts, 10)
+	out = append(out, '.')
+	return append(out, body...)
+}
+
+// FormatHeader renders the X-Argus-Signature value for a signature.
+func FormatHeader(ts int64, keyID string, sig []byte) string {
+	return fmt.Sprintf("t=%d,kid=%s,%s=%s", ts, keyID, Algorithm, base64.RawURLEncoding.EncodeToString(sig))
+}
+
+type Signature struct {
+	Timestamp int64
+	KeyID     string
+	Sig       []byte
+}
+
+func ParseHeader(header string) (Signature, error) {
+	var s Signature
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Signature{}, ErrMalformedHeader
+		}
+		switch k {
+		case "t":
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return Signature{}, ErrMalformedHeader
+			}
+			s.Timestamp = ts
+		case "kid":
+			s.KeyID = v
+		case Algorithm:
+			sig, err := base64.RawURLEncoding.DecodeString(v)
+			if err != nil {
+				return Signature{}, ErrMalformedHeader
+			}
+			s.Sig = sig
+		}
+	}
+	if s.Timestamp == 0 || s.KeyID == "" || len(s.Sig) == 0 {
+		return Signature{}, ErrMalformedHeader
+	}
+	return s, nil
+}
+
+// Verify checks a delivery body against its X-Argus-Signature header using the
+// published key set. A tolerance <= 0 uses DefaultTolerance.
+func Verify(body []byte, header string, keys KeySet, tolerance time.Duration, now time.Time) error {
+	s, err := ParseHeader(header)
+	if err != nil {
+		return err
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	age := now.Sub(time.Unix(s.Timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+	for _, k := range keys.Keys {
+		if k.ID != s.KeyID || k.Algorithm != Algorithm {
+			continue
+		}
+		pub, err := base64.RawURLEncoding.DecodeString(k.Key)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key %q", k.ID)
+		}
+		if !ed25519.Verify(ed25519.PublicKey(pub), SigningInput(s.Timestamp, body), s.Sig) {
+			return ErrBadSignature
+		}
+		return nil
+	}
+	return ErrUnknownKey
+}
+
+// VerifyRequest reads and verifies an incoming delivery, returning the body.
+func VerifyRequest(r *http.Request, keys KeySet, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := Verify(body, r.Header.Get(SignatureHeader), keys, tolerance, time.Now()); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// FetchKeySet downloads the published key set, e.g. from
+// https://argus.example.com/webhooks/signing-keys. Callers should cache the
+// result and refetch when Verify returns ErrUnknownKey (a key was rotated in).
+func FetchKeySet(ctx context.Context, client *http.Client, url string) (KeySet, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return KeySet{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return KeySet{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return KeySet{}, fmt.Errorf("fetch signing keys failed status=%d", resp.StatusCode)
+	}
+	var ks KeySet
+	if err := json.NewDecoder(resp.Body).Decode(&ks); err != nil {
+		return KeySet{}, err
+	}
+	return ks, nil
+}
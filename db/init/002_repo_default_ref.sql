@@ -0,0 +1 @@
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS default_ref TEXT;
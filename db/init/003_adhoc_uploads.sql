@@ -0,0 +1,16 @@
+-- Ad-hoc archive scans have no registered repo behind them.
+ALTER TABLE jobs ALTER COLUMN repo_id DROP NOT NULL;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'git';
+ALTER TABLE findings ALTER COLUMN repo_id DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS uploads (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+  filename TEXT NOT NULL,
+  format TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  data BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_job ON uploads(job_id);
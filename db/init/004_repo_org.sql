@@ -0,0 +1,4 @@
+-- The GitHub owner a repo belongs to; org-scoped features key off this value.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS org TEXT;
+UPDATE repos SET org = lower(split_part(url, '/', 4)) WHERE org IS NULL;
+CREATE INDEX IF NOT EXISTS idx_repos_org ON repos(org);
@@ -0,0 +1,2 @@
+-- The commit a git job actually scanned; findings permalinks pin to it.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS commit_sha TEXT;
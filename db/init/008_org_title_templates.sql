@@ -0,0 +1,2 @@
+-- Per-tool finding title templates, e.g. {"trivy_vuln": "[{severity}] {package}: {id}"}.
+ALTER TABLE orgs ADD COLUMN IF NOT EXISTS title_templates JSONB NOT NULL DEFAULT '{}';
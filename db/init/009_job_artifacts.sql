@@ -0,0 +1,15 @@
+-- Files a job produced: raw scanner output, SBOMs, logs, diffs.
+CREATE TABLE IF NOT EXISTS job_artifacts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  name TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  sha256 TEXT NOT NULL,
+  data BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (job_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_artifacts_job ON job_artifacts(job_id);
@@ -0,0 +1,3 @@
+-- Per-job scanner selection from the scan request. NULL runs every scanner
+-- the worker has enabled.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS scanners TEXT[];
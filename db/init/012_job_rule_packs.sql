@@ -0,0 +1,2 @@
+-- Exact semgrep rule packs (or --config value) a job ran with.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS rule_packs TEXT[];
@@ -0,0 +1,2 @@
+-- Which run of the job this is; transient failures are retried by the worker.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 0;
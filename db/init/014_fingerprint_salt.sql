@@ -0,0 +1,22 @@
+-- Per-org fingerprint salt. NULL keeps plain SHA-256 fingerprints; once set,
+-- new fingerprints are HMAC-SHA256(salt, plain fingerprint) and the API
+-- rekeys the org's stored findings the same way.
+ALTER TABLE orgs ADD COLUMN IF NOT EXISTS fingerprint_salt TEXT;
+
+-- Stable public finding ID, independent of the fingerprint. Findings with the
+-- same fingerprint in a repo share one ID, so it survives rescans and salting.
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS public_id TEXT;
+
+UPDATE findings f SET public_id = g.public_id
+FROM (
+  SELECT repo_id, fingerprint, encode(gen_random_bytes(8), 'hex') AS public_id
+  FROM findings
+  WHERE public_id IS NULL AND repo_id IS NOT NULL AND fingerprint IS NOT NULL
+  GROUP BY repo_id, fingerprint
+) g
+WHERE f.public_id IS NULL AND f.repo_id = g.repo_id AND f.fingerprint = g.fingerprint;
+
+UPDATE findings SET public_id = encode(gen_random_bytes(8), 'hex') WHERE public_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_findings_repo_fingerprint ON findings(repo_id, fingerprint);
+CREATE INDEX IF NOT EXISTS idx_findings_public_id ON findings(public_id);
@@ -0,0 +1,3 @@
+-- Queue the job was pushed to: high (user-triggered), normal or low
+-- (scheduled). Requeues and dead-letter replays keep it.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal';
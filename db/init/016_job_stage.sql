@@ -0,0 +1,6 @@
+-- Where a running job is (cloning, a scanner, persisting), a rough percentage
+-- and the milliseconds spent in each finished stage. Reset when the job
+-- starts or is requeued.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS stage TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS progress INT NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS stage_timings JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,3 @@
+-- Worker process (<worker.id>-<pid>) running the job. The API's reconciler
+-- uses it to recover jobs whose worker stopped heartbeating.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS worker TEXT;
@@ -0,0 +1,4 @@
+-- Share of worker capacity an org gets when several orgs have jobs waiting
+-- at the same priority, relative to the default of 1. Admins set it; the API
+-- mirrors non-default weights into <queue.jobs>:org_weights for workers.
+ALTER TABLE orgs ADD COLUMN IF NOT EXISTS queue_weight INT NOT NULL DEFAULT 1;
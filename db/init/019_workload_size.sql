@@ -0,0 +1,7 @@
+-- Profile of each repo's tree from its last clone, used to classify its
+-- scans, and the size class (small, medium, large) a job was queued with.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS size_kb BIGINT;
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS file_count INT;
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS languages TEXT[];
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS profiled_at TIMESTAMPTZ;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS size_class TEXT;
@@ -0,0 +1,5 @@
+-- Ref a job scans instead of its repo's default_ref, and the job it reruns
+-- (POST /api/jobs/{id}/rerun), so the two can be compared.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS ref TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS rerun_of UUID REFERENCES jobs(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS jobs_rerun_of_idx ON jobs(rerun_of) WHERE rerun_of IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Each hunk of a PR's diff with the fixes and findings behind it.
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS hunks JSONB NOT NULL DEFAULT '[]';
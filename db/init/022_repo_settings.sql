@@ -0,0 +1,3 @@
+-- Per-repo scan settings (scanners, semgrep config, exclude globs, severity
+-- threshold) applied by workers on top of their own config.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS scan_settings JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,14 @@
+-- Organization Semgrep rule files. Workers pass each enabled file to semgrep
+-- with --config, alongside the selected packs or, with semgrep_rules_mode
+-- 'replace', instead of them.
+CREATE TABLE IF NOT EXISTS semgrep_rules (
+  org TEXT NOT NULL REFERENCES orgs(name) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  content TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (org, name)
+);
+
+ALTER TABLE orgs ADD COLUMN IF NOT EXISTS semgrep_rules_mode TEXT NOT NULL DEFAULT 'add';
@@ -0,0 +1,2 @@
+-- grype, an alternative dependency vulnerability scanner.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'grype';
@@ -0,0 +1,6 @@
+-- Preview-environment scans: the deployment nuclei probes alongside the
+-- static scan, and the commit the deployment was built from.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS deployment_url TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS target_sha TEXT;
+
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'nuclei';
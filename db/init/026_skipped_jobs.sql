@@ -0,0 +1,4 @@
+-- Scans skipped because a push only changed non-code paths are recorded as
+-- finished jobs with the reason.
+ALTER TYPE job_status ADD VALUE IF NOT EXISTS 'skipped';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS skip_reason TEXT;
@@ -0,0 +1,12 @@
+-- Repo tags form groups, such as the repos of one release train; a group's
+-- gate passes when every member's latest default-branch scan meets its
+-- policy. Groups without a row use the default policy.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_repos_tags ON repos USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS repo_groups (
+  tag TEXT PRIMARY KEY,
+  fail_on TEXT NOT NULL DEFAULT 'HIGH',
+  max_scan_age_hours INT NOT NULL DEFAULT 168,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
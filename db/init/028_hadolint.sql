@@ -0,0 +1,2 @@
+-- hadolint, the Dockerfile linter.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'hadolint';
@@ -0,0 +1,3 @@
+-- IDs of a repo in other systems (service catalog, Backstage entity ref,
+-- cost center), keyed by system, so exports can be joined to them.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS external_refs JSONB NOT NULL DEFAULT '{}';
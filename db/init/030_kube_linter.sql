@@ -0,0 +1,2 @@
+-- kube-linter, for Kubernetes manifests and rendered Helm charts.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'kube-linter';
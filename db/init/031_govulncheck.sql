@@ -0,0 +1,2 @@
+-- govulncheck, for Go modules.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'govulncheck';
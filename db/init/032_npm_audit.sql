@@ -0,0 +1,2 @@
+-- npm-audit, for JavaScript lockfiles.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'npm-audit';
@@ -0,0 +1,5 @@
+-- Schedules can scan several refs per run, such as the default branch and
+-- maintained release branches. Empty scans the repo's default_ref.
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS refs TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS last_job_ids UUID[] NOT NULL DEFAULT '{}';
+UPDATE schedules SET last_job_ids = ARRAY[last_job_id] WHERE last_job_id IS NOT NULL AND last_job_ids = '{}';
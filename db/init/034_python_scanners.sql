@@ -0,0 +1,3 @@
+-- pip-audit and bandit, for Python repos.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'pip-audit';
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'bandit';
@@ -0,0 +1,2 @@
+-- trufflehog, a secret scanner that verifies what it finds.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'trufflehog';
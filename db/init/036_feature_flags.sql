@@ -0,0 +1,20 @@
+-- Feature flags gate new scanners (scanner.<name>) and fix types
+-- (fix.<type>) per org and repo. A repo override wins over its org's, which
+-- wins over the flag's default; anything without a flag is not gated.
+CREATE TABLE IF NOT EXISTS feature_flags (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  enabled BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- target is an org name or a repo ID.
+CREATE TABLE IF NOT EXISTS feature_flag_overrides (
+  flag TEXT NOT NULL REFERENCES feature_flags(name) ON DELETE CASCADE,
+  scope TEXT NOT NULL CHECK (scope IN ('org', 'repo')),
+  target TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (flag, scope, target)
+);
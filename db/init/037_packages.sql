@@ -0,0 +1,13 @@
+-- The packages syft found in each scan, for questions like "which repos pin
+-- log4j-core?". path is where syft found the package, such as a lockfile.
+CREATE TABLE IF NOT EXISTS packages (
+  job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+  repo_id UUID NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+  ecosystem TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL,
+  version TEXT NOT NULL DEFAULT '',
+  purl TEXT,
+  path TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (job_id, ecosystem, name, version, path)
+);
+CREATE INDEX IF NOT EXISTS idx_packages_name ON packages (lower(name));
@@ -0,0 +1,12 @@
+-- Container image scans run trivy against a registry reference instead of a
+-- checkout. Like upload jobs they have no repo.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS image TEXT;
+
+-- Registry credentials for an image scan, deleted once the job succeeds and
+-- otherwise kept as long as an upload's archive so the job can be retried.
+CREATE TABLE IF NOT EXISTS image_credentials (
+  job_id UUID PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+  username TEXT NOT NULL,
+  password TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- Incremental scans record the commit they diff against; semgrep and
+-- gitleaks only see the files changed since.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS base_sha TEXT;
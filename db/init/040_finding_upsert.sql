@@ -0,0 +1,31 @@
+-- Findings are one row per issue: a rescan that reports the same fingerprint
+-- in a repo updates the row instead of adding another. job_id is the latest
+-- scan that reported it, first_job_id the one that found it, created_at
+-- when it was first seen and last_seen_at when it was last seen.
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS first_job_id UUID REFERENCES jobs(id) ON DELETE SET NULL;
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+
+-- Keep the newest row of each repo and fingerprint, dated from the oldest.
+WITH ranked AS (
+  SELECT id,
+    row_number() OVER (PARTITION BY repo_id, fingerprint ORDER BY created_at DESC, id DESC) AS rn,
+    min(created_at) OVER (PARTITION BY repo_id, fingerprint) AS first_seen,
+    max(created_at) OVER (PARTITION BY repo_id, fingerprint) AS last_seen,
+    first_value(job_id) OVER (PARTITION BY repo_id, fingerprint ORDER BY created_at, id) AS first_job
+  FROM findings
+  WHERE repo_id IS NOT NULL AND fingerprint IS NOT NULL
+), kept AS (
+  UPDATE findings f SET created_at = r.first_seen, last_seen_at = r.last_seen, first_job_id = r.first_job
+  FROM ranked r WHERE f.id = r.id AND r.rn = 1
+)
+DELETE FROM findings f USING ranked r WHERE f.id = r.id AND r.rn > 1;
+
+UPDATE findings SET last_seen_at = created_at WHERE last_seen_at IS NULL;
+UPDATE findings SET first_job_id = job_id WHERE first_job_id IS NULL;
+ALTER TABLE findings ALTER COLUMN last_seen_at SET DEFAULT now();
+ALTER TABLE findings ALTER COLUMN last_seen_at SET NOT NULL;
+
+-- Uploads (no repo) and findings without a fingerprint stay one row per
+-- report: NULLs never conflict.
+DROP INDEX IF EXISTS idx_findings_repo_fingerprint;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_findings_repo_fingerprint_unique ON findings(repo_id, fingerprint);
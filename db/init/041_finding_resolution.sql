@@ -0,0 +1,8 @@
+-- A successful scan of a repo's default branch marks the open findings it
+-- no longer reports as fixed; a later scan that reports one reopens it.
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ;
+ALTER TABLE findings DROP CONSTRAINT IF EXISTS findings_status_check;
+ALTER TABLE findings ADD CONSTRAINT findings_status_check CHECK (status IN ('open', 'fixed'));
+
+CREATE INDEX IF NOT EXISTS idx_findings_repo_open ON findings(repo_id) WHERE status = 'open';
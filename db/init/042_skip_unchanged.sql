@@ -0,0 +1,4 @@
+-- Scans of a ref whose head has not moved since its last successful scan
+-- are skipped by the worker unless the request forced them.
+ALTER TYPE job_status ADD VALUE IF NOT EXISTS 'skipped_unchanged';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS force BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+-- The versions of the scanner tools a job ran, keyed by tool, so changes in
+-- findings can be told apart from tool upgrades.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tool_versions JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- Scanners that failed in a job that still succeeded under the continue
+-- failure policy.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS failed_scanners TEXT[] NOT NULL DEFAULT '{}';
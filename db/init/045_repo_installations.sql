@@ -0,0 +1,3 @@
+-- The GitHub App installation that can read each repo. Workers mint a
+-- short-lived installation token from it to clone private repos.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS installation_id BIGINT CHECK (installation_id > 0);
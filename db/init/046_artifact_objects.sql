@@ -0,0 +1,6 @@
+-- Scanner output may live in an S3-compatible artifact store instead of the
+-- database; the row then records the object key and has no data.
+ALTER TABLE job_artifacts ADD COLUMN IF NOT EXISTS object_key TEXT;
+ALTER TABLE job_artifacts ALTER COLUMN data DROP NOT NULL;
+ALTER TABLE job_artifacts DROP CONSTRAINT IF EXISTS job_artifacts_data_or_object;
+ALTER TABLE job_artifacts ADD CONSTRAINT job_artifacts_data_or_object CHECK (data IS NOT NULL OR object_key IS NOT NULL);
@@ -0,0 +1,16 @@
+-- Per-job logs: the worker's log records, the commands a job ran and their
+-- output, kept per attempt so /api/jobs/{id}/logs can be followed live.
+CREATE TABLE IF NOT EXISTS job_logs (
+  id BIGSERIAL PRIMARY KEY,
+  job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+  attempt INT NOT NULL,
+  logged_at TIMESTAMPTZ NOT NULL,
+  stream TEXT NOT NULL,
+  line TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_logs_job ON job_logs(job_id, id);
+
+-- With an artifact store, a finished job's log is moved there and its rows
+-- dropped.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS log_object TEXT;
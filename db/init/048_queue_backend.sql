@@ -0,0 +1,34 @@
+-- Tables of the Postgres job queue (queue.backend: postgres), for
+-- deployments without Redis. queue is the queue key a message waits in,
+-- derived from queue.jobs; owner is the worker holding it in flight.
+-- Deferred retries wait with available_at in the future.
+CREATE TABLE IF NOT EXISTS queue_messages (
+  id BIGSERIAL PRIMARY KEY,
+  queue TEXT NOT NULL,
+  org TEXT NOT NULL DEFAULT '',
+  payload TEXT NOT NULL,
+  available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  owner TEXT,
+  taken_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_messages_waiting ON queue_messages(queue, available_at) WHERE owner IS NULL;
+CREATE INDEX IF NOT EXISTS idx_queue_messages_owner ON queue_messages(owner) WHERE owner IS NOT NULL;
+
+-- Messages workers gave up on; here queue is queue.jobs.
+CREATE TABLE IF NOT EXISTS queue_dead_letters (
+  id BIGSERIAL PRIMARY KEY,
+  queue TEXT NOT NULL,
+  entry TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_dead_letters_queue ON queue_dead_letters(queue, id);
+
+-- Worker registry; a worker is alive while heartbeat_at is under 30s old.
+CREATE TABLE IF NOT EXISTS queue_workers (
+  queue TEXT NOT NULL,
+  name TEXT NOT NULL,
+  info JSONB NOT NULL,
+  heartbeat_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (queue, name)
+);
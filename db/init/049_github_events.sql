@@ -0,0 +1,13 @@
+-- GitHub App event webhooks. A job started by a pull_request event records
+-- the pull request it scans. Delivery IDs are kept until expires_at so a
+-- captured delivery cannot be replayed (the Postgres nonce store; Redis
+-- deployments keep them in Redis).
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS pull_request INT;
+
+CREATE TABLE IF NOT EXISTS webhook_nonces (
+  key TEXT PRIMARY KEY,
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Events name repos by URL.
+CREATE INDEX IF NOT EXISTS idx_repos_url_lower ON repos(lower(url));
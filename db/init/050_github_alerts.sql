@@ -0,0 +1,7 @@
+-- GitHub's Dependabot alerts are imported as findings of their own tool.
+-- Each import is recorded as an 'imported' job of the repo, which the
+-- findings point at like a scan's. alerts_synced_at is when the periodic
+-- sync last claimed the repo.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'dependabot';
+ALTER TYPE job_status ADD VALUE IF NOT EXISTS 'imported';
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS alerts_synced_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+-- GitHub's secret scanning alerts are imported as findings of their own
+-- tool, resolved alerts as fixed findings.
+ALTER TYPE finding_tool ADD VALUE IF NOT EXISTS 'secret-scanning';
@@ -0,0 +1,10 @@
+-- When a full scan of the repo's default branch last reported the finding;
+-- NULL when the default branch does not have it. Pull request checks judge
+-- a head by the findings the default branch does not have, whichever job
+-- saw them first.
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS default_seen_at TIMESTAMPTZ;
+
+UPDATE findings f SET default_seen_at = f.last_seen_at
+FROM jobs j
+WHERE j.id = f.job_id AND f.status = 'open' AND f.default_seen_at IS NULL
+  AND j.ref IS NULL AND j.target_sha IS NULL AND j.base_sha IS NULL AND j.pull_request IS NULL;
@@ -0,0 +1,4 @@
+-- When a repo's scan settings last changed. A scan started before then ran
+-- with other scanners or paths, so an unchanged commit is not skipped
+-- against it.
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS scan_settings_updated_at TIMESTAMPTZ;
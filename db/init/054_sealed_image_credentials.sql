@@ -0,0 +1,11 @@
+-- Registry credentials are sealed with images.credentials_key instead of
+-- stored in plaintext. Plaintext ones left from before cannot be sealed
+-- here, so they are dropped; their scans fail to pull and are posted again.
+-- image_authenticated remembers a scan came with credentials after they are
+-- deleted, so it is not replayed without them.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS image_authenticated BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE image_credentials ADD COLUMN IF NOT EXISTS sealed BYTEA;
+UPDATE jobs SET image_authenticated = true WHERE id IN (SELECT job_id FROM image_credentials);
+DELETE FROM image_credentials WHERE sealed IS NULL;
+ALTER TABLE image_credentials DROP COLUMN IF EXISTS username, DROP COLUMN IF EXISTS password;
+ALTER TABLE image_credentials ALTER COLUMN sealed SET NOT NULL;
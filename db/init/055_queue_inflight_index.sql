@@ -0,0 +1,3 @@
+-- Workers count the messages in flight per org once per take, grouping the
+-- taken rows by org.
+CREATE INDEX IF NOT EXISTS idx_queue_messages_inflight ON queue_messages(org) WHERE owner IS NOT NULL;
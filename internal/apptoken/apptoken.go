@@ -0,0 +1,159 @@
+// Package apptoken mints GitHub App installation tokens and caches them
+// until shortly before they expire. The API's and the worker's GitHub
+// clients both authenticate through it.
+package apptoken
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ParseKey parses an app's PEM private key, PKCS #1 or PKCS #8.
+func ParseKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	pkcs8, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	key, ok := pkcs8.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return key, nil
+}
+
+// JWT signs the short-lived JWT that authenticates as app appID. It is
+// backdated a little against clock drift.
+func JWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := fmt.Sprintf(`{"iat":%d,"exp":%d,"iss":"%d"}`, now.Unix()-30, now.Unix()+540, appID)
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+	h := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Token is an installation token and when GitHub expires it.
+type Token struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// ExchangeError is a token exchange GitHub answered with an error status.
+type ExchangeError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *ExchangeError) Error() string {
+	return "token exchange failed: status=" + strconv.Itoa(e.StatusCode)
+}
+
+// Mint exchanges a JWT of app appID for a token of installation
+// installationID at the REST API apiURL.
+func Mint(ctx context.Context, hc *http.Client, apiURL string, appID int64, key *rsa.PrivateKey, installationID int64) (Token, error) {
+	jwt, err := JWT(appID, key, time.Now())
+	if err != nil {
+		return Token{}, err
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", apiURL, installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte(`{}`)))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return Token{}, &ExchangeError{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	}
+	var out struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return Token{}, err
+	}
+	if out.Token == "" {
+		return Token{}, errors.New("empty installation token")
+	}
+	return Token{Value: out.Token, ExpiresAt: out.ExpiresAt}, nil
+}
+
+// Cache holds installation tokens by K, reusing each until it is within
+// Margin of expiring so a call started with it does not outlive it.
+type Cache[K comparable] struct {
+	Margin time.Duration
+
+	mu     sync.Mutex
+	tokens map[K]Token
+}
+
+// Get returns key's token while it is good for at least Margin.
+func (c *Cache[K]) Get(key K, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[key]
+	if !ok || now.Add(c.Margin).After(t.ExpiresAt) {
+		return "", false
+	}
+	return t.Value, true
+}
+
+// Put caches t as key's token.
+func (c *Cache[K]) Put(key K, t Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = map[K]Token{}
+	}
+	c.tokens[key] = t
+}
+
+// Drop forgets token, say because GitHub refused it, and returns the key it
+// was cached under. It reports false when token is not cached, so a token
+// the caller did not mint is never replaced.
+func (c *Cache[K]) Drop(token string) (K, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, t := range c.tokens {
+		if t.Value == token {
+			delete(c.tokens, k)
+			return k, true
+		}
+	}
+	var zero K
+	return zero, false
+}
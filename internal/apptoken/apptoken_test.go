@@ -0,0 +1,98 @@
+package apptoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMint(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			t.Errorf("authorization is not a JWT: %q", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, h[:], sig); err != nil || !strings.Contains(string(payload), `"iss":"7"`) {
+			t.Errorf("bad JWT %s: %v", payload, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"token":"ghs_minted","expires_at":"2030-01-01T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+
+	tok, err := Mint(context.Background(), srv.Client(), srv.URL, 7, key, 42)
+	if err != nil || tok.Value != "ghs_minted" || tok.ExpiresAt.Year() != 2030 {
+		t.Fatalf("Mint = %+v, %v", tok, err)
+	}
+	_, err = Mint(context.Background(), srv.Client(), srv.URL, 7, key, 43)
+	var xe *ExchangeError
+	if !errors.As(err, &xe) || xe.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown installation: %v", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		if _, err := ParseKey(string(pem.EncodeToMemory(block))); err != nil {
+			t.Errorf("%s: %v", block.Type, err)
+		}
+	}
+	if _, err := ParseKey("not a key"); err == nil {
+		t.Error("expected an invalid key to be rejected")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := Cache[string]{Margin: 5 * time.Minute}
+	now := time.Now()
+	c.Put("k", Token{Value: "ghs_a", ExpiresAt: now.Add(time.Hour)})
+	if token, ok := c.Get("k", now); !ok || token != "ghs_a" {
+		t.Errorf("Get = %q, %v", token, ok)
+	}
+	if _, ok := c.Get("k", now.Add(time.Hour-c.Margin+time.Second)); ok {
+		t.Error("a token about to expire was reused")
+	}
+	if _, ok := c.Drop("ghs_other"); ok {
+		t.Error("dropped a token that was not cached")
+	}
+	if k, ok := c.Drop("ghs_a"); !ok || k != "k" {
+		t.Errorf("Drop = %q, %v", k, ok)
+	}
+}
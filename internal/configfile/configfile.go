@@ -0,0 +1,208 @@
+// Package configfile reads the config file the API and the worker share.
+// Each service keeps its own Config and validation; the keys both read, the
+// file formats and the precedence of file and environment live here so the
+// two cannot drift apart.
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Key is a dotted file key and the environment variable existing
+// deployments set it with before config files, if any.
+type Key struct {
+	Name   string
+	Legacy string
+}
+
+// APIKeys are the keys the API reads.
+var APIKeys = []Key{
+	{"server.listen", "LISTEN_ADDR"},
+	{"auth.token", "SSAO_TOKEN"},
+	{"auth.admin_token", "SSAO_ADMIN_TOKEN"},
+	{"auth.feed_token", ""},
+	{"database.url", "DATABASE_URL"},
+	{"redis.addr", "REDIS_ADDR"},
+	{"queue.jobs", ""},
+	{"queue.backend", ""},
+	{"git.allowed_hosts", ""},
+	{"limits.adhoc_max_mb", "ADHOC_MAX_MB"},
+	{"limits.max_clone_mb", "MAX_CLONE_MB"},
+	{"webhooks.signing_keys", "WEBHOOK_SIGNING_KEYS"},
+	{"log.level", "LOG_LEVEL"},
+	{"log.format", "LOG_FORMAT"},
+	{"health.queue_warn_depth", ""},
+	{"server.public_url", "PUBLIC_URL"},
+	{"artifacts.url_secret", "ARTIFACT_URL_SECRET"},
+	{"artifacts.s3.endpoint", ""},
+	{"artifacts.s3.bucket", ""},
+	{"artifacts.s3.region", "AWS_REGION"},
+	{"artifacts.s3.access_key_id", "AWS_ACCESS_KEY_ID"},
+	{"artifacts.s3.secret_access_key", "AWS_SECRET_ACCESS_KEY"},
+	{"artifacts.s3.path_style", ""},
+	{"offline.enabled", ""},
+	{"auth.worker_token", "WORKER_API_TOKEN"},
+	{"github.url", "GITHUB_URL"},
+	{"github.webhook_secret", "GITHUB_WEBHOOK_SECRET"},
+	{"github.alerts_sync_interval", ""},
+	{"gitlab.url", "GITLAB_URL"},
+	{"gitlab.token", "GITLAB_TOKEN"},
+	{"gitlab.webhook_secret", "GITLAB_WEBHOOK_SECRET"},
+	{"azure_devops.token", "AZURE_DEVOPS_TOKEN"},
+	{"pr.signing_key", "PR_SIGNING_KEY"},
+	{"pr.signing_key_file", "PR_SIGNING_KEY_FILE"},
+	{"pr.committer_name", ""},
+	{"pr.committer_email", ""},
+	{"sizing.small_max_mb", ""},
+	{"sizing.small_max_languages", ""},
+	{"sizing.large_min_mb", ""},
+	{"sizing.large_min_languages", ""},
+	{"proxy.github", ""},
+	{"proxy.webhooks", ""},
+	{"proxy.no_proxy", ""},
+	{"localization.catalog_dir", ""},
+	{"dast.allowed_hosts", ""},
+	{"images.allowed_registries", ""},
+	{"images.credentials_key", ""},
+	{"skip.paths", ""},
+	{"skip.scanners", ""},
+}
+
+// WorkerKeys are the keys the worker reads.
+var WorkerKeys = []Key{
+	{"database.url", "DATABASE_URL"},
+	{"redis.addr", "REDIS_ADDR"},
+	{"queue.jobs", ""},
+	{"queue.backend", ""},
+	{"git.allowed_hosts", ""},
+	{"git.token", "GIT_TOKEN"},
+	{"github.app_id", "GITHUB_APP_ID"},
+	{"github.private_key", "GITHUB_PRIVATE_KEY_PEM"},
+	{"github.installation_id", "GITHUB_INSTALLATION_ID"},
+	{"github.url", "GITHUB_URL"},
+	{"github.check_runs", ""},
+	{"github.review_comments", ""},
+	{"github.code_scanning", ""},
+	{"github.commit_statuses", ""},
+	{"gitlab.url", "GITLAB_URL"},
+	{"gitlab.token", "GITLAB_TOKEN"},
+	{"gitlab.review_comments", ""},
+	{"azure_devops.token", "AZURE_DEVOPS_TOKEN"},
+	{"server.public_url", "PUBLIC_URL"},
+	{"limits.max_clone_mb", "MAX_CLONE_MB"},
+	{"limits.scan_timeout_min", "SCAN_TIMEOUT_MIN"},
+	{"scanners.enabled", ""},
+	{"scanners.parallelism", ""},
+	{"scanners.semgrep.config", ""},
+	{"scanners.semgrep.timeout_sec", ""},
+	{"scanners.trivy.timeout", ""},
+	{"scanners.timeouts", ""},
+	{"scanners.on_failure", ""},
+	{"scanners.terraform.download_modules", ""},
+	{"scanners.trufflehog.verify", ""},
+	{"log.level", "LOG_LEVEL"},
+	{"log.format", "LOG_FORMAT"},
+	{"health.listen", ""},
+	{"worker.id", "WORKER_ID"},
+	{"worker.concurrency", "WORKER_CONCURRENCY"},
+	{"worker.shutdown_grace", ""},
+	{"worker.size_classes", ""},
+	{"workspace.root", ""},
+	{"limits.artifact_max_mb", ""},
+	{"artifacts.s3.endpoint", ""},
+	{"artifacts.s3.bucket", ""},
+	{"artifacts.s3.region", "AWS_REGION"},
+	{"artifacts.s3.access_key_id", "AWS_ACCESS_KEY_ID"},
+	{"artifacts.s3.secret_access_key", "AWS_SECRET_ACCESS_KEY"},
+	{"artifacts.s3.path_style", ""},
+	{"artifacts.s3.prefix", ""},
+	{"retries.max_attempts", ""},
+	{"retries.backoff", ""},
+	{"cache.dir", ""},
+	{"cache.refresh_interval", ""},
+	{"cache.clones_max_mb", ""},
+	{"offline.enabled", ""},
+	{"offline.semgrep_rules", ""},
+	{"offline.trivy_cache_dir", ""},
+	{"offline.trivy_db_repository", ""},
+	{"offline.grype_db_dir", ""},
+	{"offline.govulncheck_db_dir", ""},
+	{"offline.npm_registry", ""},
+	{"offline.max_bundle_age", ""},
+	{"results.mode", ""},
+	{"results.api_url", ""},
+	{"proxy.github", ""},
+	{"proxy.scanners", ""},
+	{"proxy.no_proxy", ""},
+	{"auth.worker_token", "WORKER_API_TOKEN"},
+	{"dast.allowed_hosts", ""},
+	{"scanners.nuclei.templates", ""},
+	{"scanners.nuclei.rate_limit", ""},
+	{"images.allowed_registries", ""},
+	{"images.credentials_key", ""},
+}
+
+// Load applies the file at path (if non-empty) and then the environment to
+// one service's settings: own are the keys it reads, set their setters.
+// Keys only other reads are skipped so one file can configure both
+// services; any other unknown key is rejected so typos surface at startup
+// instead of being silently ignored.
+func Load(path string, own, other []Key, set map[string]func(string) error) error {
+	for _, k := range own {
+		if set[k.Name] == nil {
+			return fmt.Errorf("config: no setter for %q", k.Name)
+		}
+	}
+	if len(set) != len(own) {
+		return fmt.Errorf("config: %d setters for %d keys", len(set), len(own))
+	}
+	if path != "" {
+		vals, err := ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+		foreign := map[string]bool{}
+		for _, k := range other {
+			foreign[k.Name] = true
+		}
+		keys := make([]string, 0, len(vals))
+		for k := range vals {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s, ok := set[k]
+			if !ok && foreign[k] {
+				continue
+			}
+			if !ok {
+				return fmt.Errorf("config %s: unknown key %q", path, k)
+			}
+			if err := s(vals[k]); err != nil {
+				return fmt.Errorf("config %s: %s: %w", path, k, err)
+			}
+		}
+	}
+	for _, k := range own {
+		for _, env := range []string{EnvName(k.Name), k.Legacy} {
+			if env == "" {
+				continue
+			}
+			if v, ok := os.LookupEnv(env); ok && v != "" {
+				if err := set[k.Name](v); err != nil {
+					return fmt.Errorf("%s: %w", env, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EnvName maps a dotted key to its ARGUS_ environment variable, e.g.
+// "limits.adhoc_max_mb" -> "ARGUS_LIMITS_ADHOC_MAX_MB".
+func EnvName(key string) string {
+	return "ARGUS_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,102 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sampleYAML = `
+# Argus API
+server:
+  listen: "127.0.0.1:9090"   # loopback only
+database:
+  url: postgres://argus@db/argus
+redis:
+  addr: redis:6379
+git:
+  allowed_hosts:
+    - github.com
+    - GHE.example.com
+limits:
+  adhoc_max_mb: 25
+`
+
+const sampleTOML = `
+# Argus API
+[server]
+listen = "127.0.0.1:9090" # loopback only
+
+[database]
+url = "postgres://argus@db/argus"
+
+[redis]
+addr = 'redis:6379'
+
+[git]
+allowed_hosts = ["github.com", "GHE.example.com"]
+
+[limits]
+adhoc_max_mb = 25
+`
+
+func TestParsersAgree(t *testing.T) {
+	y, err := parseYAML(sampleYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm, err := parseTOML(sampleTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Values{
+		"server.listen":       "127.0.0.1:9090",
+		"database.url":        "postgres://argus@db/argus",
+		"redis.addr":          "redis:6379",
+		"git.allowed_hosts":   "github.com,GHE.example.com",
+		"limits.adhoc_max_mb": "25",
+	}
+	if !reflect.DeepEqual(y, want) {
+		t.Fatalf("yaml: got %v", y)
+	}
+	if !reflect.DeepEqual(tm, want) {
+		t.Fatalf("toml: got %v", tm)
+	}
+}
+
+func TestLoadSkipsOtherServiceKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "argus.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  listen: \":1\"\nworker:\n  id: w1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LISTEN_ADDR", ":2")
+	var listen string
+	set := map[string]func(string) error{"server.listen": func(v string) error { listen = v; return nil }}
+	own := []Key{{"server.listen", "LISTEN_ADDR"}}
+
+	if err := Load(path, own, WorkerKeys, set); err != nil {
+		t.Fatal(err)
+	}
+	if listen != ":2" {
+		t.Fatalf("listen = %q, want the environment's", listen)
+	}
+	if err := Load(path, own, nil, set); err == nil {
+		t.Fatal("expected worker.id to be rejected without the worker's keys")
+	}
+	if err := Load("", append(own, Key{Name: "log.level"}), nil, set); err == nil {
+		t.Fatal("expected a key without a setter to be rejected")
+	}
+}
+
+func TestKeysAreUnique(t *testing.T) {
+	for name, keys := range map[string][]Key{"api": APIKeys, "worker": WorkerKeys} {
+		seen := map[string]bool{}
+		for _, k := range keys {
+			if seen[k.Name] {
+				t.Errorf("%s: %q listed twice", name, k.Name)
+			}
+			seen[k.Name] = true
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Values is a parsed config file flattened to dotted keys ("server.listen").
+// List values are joined with commas so every setting can also be expressed
+// as a single environment variable.
+type Values map[string]string
+
+// ReadFile parses a .yaml/.yml or .toml file. Only the subset of each format
+// that config files need is supported: nested tables/mappings, scalars,
+// quoted strings and flat lists.
+func ReadFile(path string) (Values, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return parseTOML(string(data))
+	case ".yaml", ".yml":
+		return parseYAML(string(data))
+	}
+	return nil, fmt.Errorf("unsupported config format %q (use .yaml or .toml)", filepath.Ext(path))
+}
+
+func parseTOML(src string) (Values, error) {
+	out := Values{}
+	section := ""
+	for i, raw := range strings.Split(src, "\n") {
+		line := strings.TrimSpace(stripComment(raw))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: unsupported table header", i+1)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key := unquote(strings.TrimSpace(k))
+		if section != "" {
+			key = section + "." + key
+		}
+		val, err := parseValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		out[key] = val
+	}
+	return out, nil
+}
+
+type yamlFrame struct {
+	indent int
+	prefix string
+}
+
+func parseYAML(src string) (Values, error) {
+	out := Values{}
+	stack := []yamlFrame{{indent: -1}}
+	listKey := ""
+	listIndent := -1
+	var list []string
+
+	flush := func() {
+		if len(list) > 0 {
+			out[listKey] = strings.Join(list, ",")
+		}
+		listKey, list, listIndent = "", nil, -1
+	}
+
+	for i, raw := range strings.Split(src, "\n") {
+		if strings.TrimSpace(raw) == "---" {
+			continue
+		}
+		line := strings.TrimRight(stripComment(raw), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.Contains(line, "\t") && strings.TrimLeft(line, " \t") != strings.TrimLeft(line, " ") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		content := strings.TrimSpace(line)
+
+		if strings.HasPrefix(content, "- ") || content == "-" {
+			if listKey == "" || indent < listIndent {
+				return nil, fmt.Errorf("line %d: list item without a key", i+1)
+			}
+			v, err := parseValue(strings.TrimSpace(strings.TrimPrefix(content, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			list = append(list, v)
+			continue
+		}
+		flush()
+
+		for len(stack) > 1 && indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		k, v, ok := strings.Cut(content, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		key := unquote(strings.TrimSpace(k))
+		if p := stack[len(stack)-1].prefix; p != "" {
+			key = p + "." + key
+		}
+		v = strings.TrimSpace(v)
+		if v == "" {
+			// Either a nested mapping or a block list follows.
+			stack = append(stack, yamlFrame{indent: indent, prefix: key})
+			listKey, listIndent = key, indent
+			continue
+		}
+		val, err := parseValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		out[key] = val
+	}
+	flush()
+	return out, nil
+}
+
+// parseValue handles quoted strings, bare scalars and flat [a, b] lists.
+func parseValue(v string) (string, error) {
+	if strings.HasPrefix(v, "[") {
+		if !strings.HasSuffix(v, "]") {
+			return "", fmt.Errorf("unterminated list")
+		}
+		inner := strings.TrimSpace(v[1 : len(v)-1])
+		if inner == "" {
+			return "", nil
+		}
+		items := splitList(inner)
+		for i, it := range items {
+			items[i] = unquote(strings.TrimSpace(it))
+		}
+		return strings.Join(items, ","), nil
+	}
+	if (strings.HasPrefix(v, `"`) && !strings.HasSuffix(v, `"`)) || (strings.HasPrefix(v, "'") && !strings.HasSuffix(v, "'")) || v == `"` || v == "'" {
+		return "", fmt.Errorf("unterminated string")
+	}
+	return unquote(v), nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	var cur strings.Builder
+	var quote rune
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			cur.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			cur.WriteRune(c)
+		case c == ',':
+			out = append(out, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	return append(out, cur.String())
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// stripComment drops a trailing "# ..." that is not inside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,51 @@
+package forge
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// AzureDevOps is where Azure DevOps Services keeps its repos.
+const AzureDevOps = "dev.azure.com"
+
+// AzureRepo is an Azure Repos Git repository.
+type AzureRepo struct {
+	Org, Project, Name string
+}
+
+// ParseAzureRepo splits a clone URL of the form
+// https://dev.azure.com/{org}/{project}/_git/{repo}. URLs with user info,
+// as Azure DevOps shows them for copying, are rejected like any other.
+func ParseAzureRepo(raw string) (AzureRepo, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "https" || u.User != nil || !strings.EqualFold(u.Host, AzureDevOps) {
+		return AzureRepo{}, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "_git" {
+		return AzureRepo{}, false
+	}
+	r := AzureRepo{Org: parts[0], Project: parts[1], Name: parts[3]}
+	for _, p := range []string{r.Org, r.Project, r.Name} {
+		if p == "" || p == "." || p == ".." {
+			return AzureRepo{}, false
+		}
+	}
+	return r, true
+}
+
+// APIPath is the repo's REST API path under the organization's project.
+func (r AzureRepo) APIPath() string {
+	return "/" + url.PathEscape(r.Org) + "/" + url.PathEscape(r.Project) + "/_apis/git/repositories/" + url.PathEscape(r.Name)
+}
+
+// WebURL is the repo's page, which pull request links hang off.
+func (r AzureRepo) WebURL() string {
+	return "https://" + AzureDevOps + "/" + url.PathEscape(r.Org) + "/" + url.PathEscape(r.Project) + "/_git/" + url.PathEscape(r.Name)
+}
+
+// PullRequestURL is the page of pull request id.
+func (r AzureRepo) PullRequestURL(id int) string {
+	return r.WebURL() + "/pullrequest/" + strconv.Itoa(id)
+}
@@ -0,0 +1,24 @@
+package forge
+
+import "testing"
+
+func TestParseURL(t *testing.T) {
+	for raw, want := range map[string]AzureRepo{
+		"https://dev.azure.com/acme/Platform/_git/api":         {"acme", "Platform", "api"},
+		"https://dev.azure.com/acme/Platform%20Team/_git/api/": {"acme", "Platform Team", "api"},
+		"https://acme@dev.azure.com/acme/Platform/_git/api":    {},
+		"http://dev.azure.com/acme/Platform/_git/api":          {},
+		"https://dev.azure.com/acme/Platform/api.git":          {},
+		"https://dev.azure.com/acme/Platform/_git/api/commits": {},
+		"https://github.com/acme/Platform/_git/api":            {},
+	} {
+		got, ok := ParseAzureRepo(raw)
+		if got != want || ok != (want != AzureRepo{}) {
+			t.Errorf("ParseAzureRepo(%q) = %+v, %v, want %+v", raw, got, ok, want)
+		}
+	}
+	r := AzureRepo{"acme", "Platform Team", "api"}
+	if got := r.WebURL(); got != "https://dev.azure.com/acme/Platform%20Team/_git/api" {
+		t.Errorf("webURL = %q", got)
+	}
+}
@@ -0,0 +1,4 @@
+// Package forge knows the git hosts Argus clones from and opens pull
+// requests on: which repo URLs belong to a GitHub or GitLab instance or to
+// Azure Repos, and where their APIs and pages are.
+package forge
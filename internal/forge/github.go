@@ -0,0 +1,47 @@
+package forge
+
+import (
+	"net/url"
+	"strings"
+)
+
+// GitHubDotCom is github.com's web URL.
+const GitHubDotCom GitHub = "https://github.com"
+
+// GitHub is the web URL of the GitHub instance repos are on: github.com, or a
+// GitHub Enterprise Server such as https://github.example.com. Empty means
+// github.com.
+type GitHub string
+
+// URL is the instance's web URL, without a trailing slash.
+func (h GitHub) URL() string {
+	if h == "" {
+		return string(GitHubDotCom)
+	}
+	return strings.TrimSuffix(string(h), "/")
+}
+
+// APIURL is the instance's REST API: api.github.com for github.com, and
+// under /api/v3 on an Enterprise Server.
+func (h GitHub) APIURL() string {
+	if strings.EqualFold(h.URL(), string(GitHubDotCom)) {
+		return "https://api.github.com"
+	}
+	return h.URL() + "/api/v3"
+}
+
+// Owns reports whether repoURL is an https URL on the instance;
+// installation tokens are never handed to other hosts.
+func (h GitHub) Owns(repoURL string) bool {
+	base, err := url.Parse(h.URL())
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(strings.TrimSpace(repoURL))
+	return err == nil && u.Scheme == "https" && u.User == nil && strings.EqualFold(u.Host, base.Host)
+}
+
+// CloneURL is the HTTPS clone URL of owner/name on the instance.
+func (h GitHub) CloneURL(fullName string) string {
+	return h.URL() + "/" + fullName + ".git"
+}
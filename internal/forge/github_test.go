@@ -0,0 +1,37 @@
+package forge
+
+import "testing"
+
+func TestHost(t *testing.T) {
+	for _, tc := range []struct {
+		host GitHub
+		api  string
+	}{
+		{"", "https://api.github.com"},
+		{GitHubDotCom, "https://api.github.com"},
+		{"https://ghe.acme.test/", "https://ghe.acme.test/api/v3"},
+	} {
+		if got := tc.host.APIURL(); got != tc.api {
+			t.Errorf("GitHub(%q).APIURL() = %q, want %q", tc.host, got, tc.api)
+		}
+	}
+
+	for u, want := range map[string]bool{
+		"https://github.com/acme/api.git":           true,
+		"https://GitHub.com/acme/api.git":           true,
+		"http://github.com/acme/api.git":            false,
+		"https://github.com.evil.test/acme/api.git": false,
+		"https://ghe.acme.test/acme/api.git":        false,
+	} {
+		if got := GitHubDotCom.Owns(u); got != want {
+			t.Errorf("GitHubDotCom.Owns(%q) = %v, want %v", u, got, want)
+		}
+	}
+	ghe := GitHub("https://ghe.acme.test")
+	if !ghe.Owns("https://ghe.acme.test/acme/api.git") || ghe.Owns("https://github.com/acme/api.git") {
+		t.Error("an Enterprise Server host owns the wrong repos")
+	}
+	if got := ghe.CloneURL("acme/api"); got != "https://ghe.acme.test/acme/api.git" {
+		t.Errorf("CloneURL = %q", got)
+	}
+}
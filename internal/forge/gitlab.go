@@ -0,0 +1,68 @@
+package forge
+
+import (
+	"net/url"
+	"strings"
+)
+
+// GitLabDotCom is gitlab.com's web URL.
+const GitLabDotCom GitLab = "https://gitlab.com"
+
+// GitLab is the web URL of the GitLab instance repos are on: gitlab.com, or a
+// self-managed instance, possibly under a relative URL root such as
+// https://git.example.com/gitlab. Empty means gitlab.com.
+type GitLab string
+
+// URL is the instance's web URL, without a trailing slash.
+func (h GitLab) URL() string {
+	if h == "" {
+		return string(GitLabDotCom)
+	}
+	return strings.TrimSuffix(string(h), "/")
+}
+
+// APIURL is the instance's REST API.
+func (h GitLab) APIURL() string {
+	return h.URL() + "/api/v4"
+}
+
+// ProjectPath returns the full path of the project repoURL clones, such as
+// group/subgroup/project, if repoURL is an https URL on the instance. The
+// token is never handed to other hosts.
+func (h GitLab) ProjectPath(repoURL string) (string, bool) {
+	base, err := url.Parse(h.URL())
+	if err != nil {
+		return "", false
+	}
+	u, err := url.Parse(strings.TrimSpace(repoURL))
+	if err != nil || u.Scheme != "https" || u.User != nil || !strings.EqualFold(u.Host, base.Host) {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(base.Path, "/")+"/")
+	if !ok {
+		return "", false
+	}
+	path := strings.TrimSuffix(strings.TrimSuffix(rest, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		return "", false
+	}
+	for _, p := range parts {
+		// "-" separates a project's path from its pages, as in /-/tree.
+		if p == "" || p == "-" || p == "." || p == ".." {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// Owns reports whether repoURL is a project on the instance.
+func (h GitLab) Owns(repoURL string) bool {
+	_, ok := h.ProjectPath(repoURL)
+	return ok
+}
+
+// CloneURL is the HTTPS clone URL of the project at path on the instance.
+func (h GitLab) CloneURL(path string) string {
+	return h.URL() + "/" + path + ".git"
+}
@@ -0,0 +1,3 @@
+module argus/internal
+
+go 1.22
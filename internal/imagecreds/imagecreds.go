@@ -0,0 +1,76 @@
+// Package imagecreds seals the registry credentials of an image scan for the
+// database. The API seals them when the scan is posted and opens them for
+// workers that submit through it; workers writing to Postgres directly open
+// them themselves, so both are configured with the same key.
+package imagecreds
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Credentials authenticate to an image's registry.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Key seals and opens credentials with AES-256-GCM.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// ParseKey parses a base64-encoded 32-byte key, as generated by
+// `openssl rand -base64 32`.
+func ParseKey(s string) (*Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("key is %d bytes, want 32", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// Seal encrypts c for job jobID. The job ID is authenticated with it, so
+// sealed credentials only open for the job they were posted with.
+func (k *Key) Seal(jobID string, c Credentials) ([]byte, error) {
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plain)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plain, []byte(jobID)), nil
+}
+
+// Open decrypts credentials Seal sealed for jobID.
+func (k *Key) Open(jobID string, sealed []byte) (Credentials, error) {
+	var c Credentials
+	n := k.aead.NonceSize()
+	if len(sealed) < n {
+		return c, errors.New("sealed credentials are truncated")
+	}
+	plain, err := k.aead.Open(nil, sealed[:n], sealed[n:], []byte(jobID))
+	if err != nil {
+		return c, errors.New("sealed credentials do not open with this key")
+	}
+	err = json.Unmarshal(plain, &c)
+	return c, err
+}
@@ -0,0 +1,50 @@
+package imagecreds
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) *Key {
+	t.Helper()
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	k, err := ParseKey(base64.StdEncoding.EncodeToString(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	k := newKey(t)
+	want := Credentials{Username: "ci-bot", Password: "ghp_secret"}
+	sealed, err := k.Seal("job-1", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), want.Password) {
+		t.Fatal("password is readable in the sealed credentials")
+	}
+	if got, err := k.Open("job-1", sealed); err != nil || got != want {
+		t.Fatalf("Open = %+v, %v", got, err)
+	}
+	if _, err := k.Open("job-2", sealed); err == nil {
+		t.Error("credentials opened for another job")
+	}
+	if _, err := newKey(t).Open("job-1", sealed); err == nil {
+		t.Error("credentials opened with another key")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, s := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) accepted", s)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package jobsql
+
+import (
+	"context"
+	"time"
+)
+
+// Outbound webhook events. Whoever finishes a job writes them to the
+// webhook_deliveries outbox with EnqueueEvent; the API signs and delivers
+// them.
+const (
+	EventScanCompleted   = "scan.completed"
+	EventFindingCritical = "finding.critical"
+)
+
+// MaxEventFindings caps the findings listed in a finding.critical payload.
+const MaxEventFindings = 50
+
+// EnqueueEvent writes one pending delivery of event $1 with payload $2 per
+// enabled webhook subscribed to it, optionally scoped to org $3.
+const EnqueueEvent = `INSERT INTO webhook_deliveries (webhook_id, event, payload)
+	SELECT id, $1, $2 FROM webhooks WHERE enabled AND $1 = ANY(events) AND (org IS NULL OR org = $3)`
+
+// JobEvent is one event to enqueue for a finished job. Payload is a
+// ScanCompleted or a FindingCritical.
+type JobEvent struct {
+	Name, Org string
+	Payload   any
+}
+
+// ScanCompleted is the scan.completed payload, with the job's finding
+// counts by severity.
+type ScanCompleted struct {
+	Event    string         `json:"event"`
+	Job      EventJob       `json:"job"`
+	Repo     *EventRepo     `json:"repo"`
+	Severity map[string]int `json:"severity"`
+}
+
+// FindingCritical is the finding.critical payload.
+type FindingCritical struct {
+	Event    string         `json:"event"`
+	Job      EventJob       `json:"job"`
+	Repo     *EventRepo     `json:"repo"`
+	Findings []EventFinding `json:"findings"`
+}
+
+type EventJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Source     string     `json:"source"`
+	Error      *string    `json:"error,omitempty"`
+	CommitSHA  *string    `json:"commit_sha,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type EventRepo struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	Org          string            `json:"org"`
+	ExternalRefs map[string]string `json:"external_refs"`
+}
+
+type EventFinding struct {
+	ID        string  `json:"id"`
+	PublicID  string  `json:"public_id"`
+	Tool      string  `json:"tool"`
+	Title     string  `json:"title"`
+	FilePath  *string `json:"file_path,omitempty"`
+	LineStart *int    `json:"line_start,omitempty"`
+}
+
+// selectSeverityCounts selects job $1's finding counts by severity as a
+// JSON object.
+const selectSeverityCounts = `SELECT COALESCE(jsonb_object_agg(severity, n), '{}')
+	FROM (SELECT severity, count(*) AS n FROM findings WHERE job_id=$1 GROUP BY severity) c`
+
+// selectNewCriticalFindings selects up to $2 of job $1's CRITICAL findings
+// whose fingerprint no earlier job of the same repo reported, so rescans of
+// an unchanged repo stay quiet.
+const selectNewCriticalFindings = `SELECT COALESCE(json_agg(json_build_object('id', f.id::text, 'public_id', COALESCE(f.public_id,''), 'tool', f.tool::text,
+		'title', f.title, 'file_path', f.file_path, 'line_start', f.line_start) ORDER BY f.created_at), '[]')
+	FROM (SELECT * FROM findings WHERE job_id=$1 AND first_job_id=$1 AND severity='CRITICAL' ORDER BY created_at LIMIT $2) f`
+
+// BuildJobEvents builds the events of the finished job jobID: scan.completed,
+// and finding.critical when it found new CRITICAL findings.
+func BuildJobEvents(ctx context.Context, queryRow QueryRowFunc, jobID string) ([]JobEvent, error) {
+	job := EventJob{ID: jobID}
+	var repoID *string
+	err := queryRow(ctx, `SELECT status::text, source, error, commit_sha, started_at, finished_at, repo_id::text FROM jobs WHERE id=$1`, jobID).
+		Scan(&job.Status, &job.Source, &job.Error, &job.CommitSHA, &job.StartedAt, &job.FinishedAt, &repoID)
+	if err != nil {
+		return nil, err
+	}
+	var repo *EventRepo
+	if repoID != nil {
+		repo = &EventRepo{ID: *repoID}
+		if err := queryRow(ctx, `SELECT name, url, COALESCE(org,''), external_refs FROM repos WHERE id=$1`, *repoID).Scan(&repo.Name, &repo.URL, &repo.Org, &repo.ExternalRefs); err != nil {
+			repo = nil
+		}
+	}
+	org := ""
+	if repo != nil {
+		org = repo.Org
+	}
+
+	counts := map[string]int{}
+	if err := queryRow(ctx, selectSeverityCounts, jobID).Scan(&counts); err != nil {
+		return nil, err
+	}
+	events := []JobEvent{{Name: EventScanCompleted, Org: org,
+		Payload: ScanCompleted{Event: EventScanCompleted, Job: job, Repo: repo, Severity: counts}}}
+	if counts["CRITICAL"] == 0 {
+		return events, nil
+	}
+	var critical []EventFinding
+	if err := queryRow(ctx, selectNewCriticalFindings, jobID, MaxEventFindings).Scan(&critical); err != nil {
+		return nil, err
+	}
+	if len(critical) > 0 {
+		events = append(events, JobEvent{Name: EventFindingCritical, Org: org,
+			Payload: FindingCritical{Event: EventFindingCritical, Job: job, Repo: repo, Findings: critical}})
+	}
+	return events, nil
+}
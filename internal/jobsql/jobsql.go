@@ -0,0 +1,82 @@
+// Package jobsql holds the statements a worker runs against the database
+// directly, which the API's internal endpoints run for workers that submit
+// through it instead, and the job summary built from them. Both services
+// take them from here so the two result paths cannot drift apart.
+package jobsql
+
+// ResolveFlags resolves every feature flag for the repo $1 (a UUID, or NULL
+// for jobs without a repo) into a JSON object of names to booleans.
+const ResolveFlags = `SELECT COALESCE(jsonb_object_agg(f.name, COALESCE(ro.enabled, oo.enabled, f.enabled)), '{}')
+	FROM feature_flags f
+	LEFT JOIN repos rp ON rp.id = $1
+	LEFT JOIN feature_flag_overrides ro ON ro.flag = f.name AND ro.scope = 'repo' AND ro.target = rp.id::text
+	LEFT JOIN feature_flag_overrides oo ON oo.flag = f.name AND oo.scope = 'org' AND oo.target = rp.org`
+
+// PreviousScan finds the scan an unchanged commit of job $1 is skipped
+// against: the latest earlier scan of the repo with the same ref and
+// scanner selection that succeeded with every scanner. An incremental scan
+// does not stand in for a full one, and a scan started before the repo's
+// scan settings last changed ran with other scanners or paths, so it does
+// not stand in for any.
+const PreviousScan = `SELECT b.id::text, COALESCE(b.commit_sha,'') FROM jobs j
+	JOIN repos r ON r.id = j.repo_id
+	JOIN jobs b ON b.repo_id = j.repo_id AND b.id <> j.id AND b.status='succeeded' AND b.failed_scanners = '{}'
+		AND b.ref IS NOT DISTINCT FROM j.ref AND COALESCE(b.scanners, '{}') = COALESCE(j.scanners, '{}') AND (b.base_sha IS NULL OR j.base_sha IS NOT NULL)
+		AND (r.scan_settings_updated_at IS NULL OR b.started_at > r.scan_settings_updated_at)
+	WHERE j.id=$1 ORDER BY b.finished_at DESC LIMIT 1`
+
+// SetPackages inserts job $1's packages from the parallel arrays $2-$6:
+// ecosystem, name, version, purl and path.
+const SetPackages = `INSERT INTO packages (job_id, repo_id, ecosystem, name, version, purl, path)
+	SELECT j.id, j.repo_id, p.ecosystem, p.name, p.version, NULLIF(p.purl, ''), p.path
+	FROM jobs j, unnest($2::text[], $3::text[], $4::text[], $5::text[], $6::text[]) AS p(ecosystem, name, version, purl, path)
+	WHERE j.id=$1 AND j.repo_id IS NOT NULL
+	ON CONFLICT DO NOTHING`
+
+// InsertFinding stores one finding of job $2 in repo $1. A finding whose
+// fingerprint the repo already has updates that row instead, so it keeps
+// its public ID and first-seen time across rescans, points at the latest
+// job that reported it and reopens if it was fixed.
+const InsertFinding = `INSERT INTO findings (repo_id, job_id, first_job_id, tool, severity, title, file_path, line_start, line_end, fingerprint, description, evidence_json, public_id)
+	VALUES ($1,$2,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11, encode(gen_random_bytes(8), 'hex'))
+	ON CONFLICT (repo_id, fingerprint) DO UPDATE SET job_id=EXCLUDED.job_id, severity=EXCLUDED.severity, title=EXCLUDED.title,
+		file_path=EXCLUDED.file_path, line_start=EXCLUDED.line_start, line_end=EXCLUDED.line_end, description=EXCLUDED.description,
+		evidence_json=EXCLUDED.evidence_json, last_seen_at=now(), status='open', resolved_at=NULL`
+
+// NewFindings selects the findings job $1 reported first, most severe
+// first.
+const NewFindings = `SELECT tool::text, severity, title, file_path, line_start, line_end, fingerprint, description FROM findings
+	WHERE job_id=$1 AND first_job_id=$1
+	ORDER BY array_position(ARRAY['CRITICAL','HIGH','MEDIUM','LOW'], severity), file_path, line_start`
+
+// BranchFindings selects the findings of job $1 that the default branch
+// does not have, whichever job found them first, most severe first.
+const BranchFindings = `SELECT tool::text, severity, title, file_path, line_start, line_end, fingerprint, description FROM findings
+	WHERE job_id=$1 AND default_seen_at IS NULL
+	ORDER BY array_position(ARRAY['CRITICAL','HIGH','MEDIUM','LOW'], severity), file_path, line_start`
+
+// ResolveFindings records the findings job $2 reported as on repo $1's
+// default branch, and marks the repo's open findings of the tools $3 that
+// it did not report as fixed and no longer there.
+const ResolveFindings = `WITH seen AS (
+		UPDATE findings SET default_seen_at=now() WHERE repo_id=$1 AND job_id=$2
+	)
+	UPDATE findings SET status='fixed', resolved_at=now(), default_seen_at=NULL
+	WHERE repo_id=$1 AND status='open' AND job_id<>$2 AND tool::text = ANY($3)`
+
+// AppendJobLog inserts a batch of job $1's log lines from the parallel
+// arrays $2-$5, in order.
+const AppendJobLog = `INSERT INTO job_logs (job_id, attempt, logged_at, stream, line)
+	SELECT $1, l.attempt, l.logged_at, l.stream, l.line
+	FROM unnest($2::int[], $3::timestamptz[], $4::text[], $5::text[]) WITH ORDINALITY AS l(attempt, logged_at, stream, line, n)
+	ORDER BY l.n`
+
+// DiscardObjects deletes the artifact rows of the jobs $1 and clears their
+// archived logs when they are reset for another attempt, selecting the
+// artifact store keys both pointed to so they can be deleted once the
+// transaction commits.
+const DiscardObjects = `WITH d AS (DELETE FROM job_artifacts WHERE job_id::text = ANY($1) RETURNING object_key),
+	l AS (UPDATE jobs j SET log_object = NULL FROM jobs o
+		WHERE o.id = j.id AND j.id::text = ANY($1) AND j.log_object IS NOT NULL RETURNING o.log_object)
+	SELECT object_key FROM d WHERE object_key IS NOT NULL
+	UNION ALL SELECT log_object FROM l`
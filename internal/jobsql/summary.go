@@ -0,0 +1,244 @@
+package jobsql
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Every finished job gets a summary: a small JSON document with its counts,
+// what changed since the previous comparable scan, the gate verdict and
+// links, for CI systems to archive with build records. Workers writing to
+// Postgres build it with BuildSummary, and so does the API for workers that
+// submit through it and for the scans it skips itself.
+
+const (
+	// SummaryKind and SummaryName are the summary's artifact kind and name.
+	SummaryKind = "summary"
+	SummaryName = "summary.json"
+	// SummaryVersion is the document's schema_version.
+	SummaryVersion = 1
+	// DefaultFailOn is the gate of a repo in no group.
+	DefaultFailOn = "HIGH"
+)
+
+type Summary struct {
+	SchemaVersion int            `json:"schema_version"`
+	Job           SummaryJob     `json:"job"`
+	Counts        SummaryCounts  `json:"counts"`
+	Changes       *SummaryChange `json:"changes"`
+	Gate          SummaryGate    `json:"gate"`
+	Links         SummaryLinks   `json:"links"`
+}
+
+type SummaryJob struct {
+	ID             string     `json:"id"`
+	RepoID         *string    `json:"repo_id"`
+	Status         string     `json:"status"`
+	Source         string     `json:"source"`
+	Ref            *string    `json:"ref"`
+	CommitSHA      *string    `json:"commit_sha"`
+	Scanners       []string   `json:"scanners"`
+	StartedAt      *time.Time `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	Error          *string    `json:"error,omitempty"`
+	SkipReason     *string    `json:"skip_reason,omitempty"`
+	FailedScanners []string   `json:"failed_scanners,omitempty"`
+}
+
+type SummaryCounts struct {
+	Total      int            `json:"total"`
+	BySeverity map[string]int `json:"by_severity"`
+	ByTool     map[string]int `json:"by_tool"`
+}
+
+// SummaryChange compares the job with the latest earlier successful scan of
+// the same repo, ref and scanner selection.
+type SummaryChange struct {
+	BaselineJobID *string `json:"baseline_job_id"`
+	New           int     `json:"new"`
+	Resolved      int     `json:"resolved"`
+	// ToolChanges lists the scanner tools whose version differs from the
+	// baseline's, so new and resolved findings can be put down to an
+	// upgrade rather than the code.
+	ToolChanges map[string]ToolChange `json:"tool_changes,omitempty"`
+}
+
+type ToolChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SummaryGate applies the strictest fail_on of the repo's groups, or
+// DefaultFailOn.
+type SummaryGate struct {
+	FailOn   string `json:"fail_on"`
+	Pass     bool   `json:"pass"`
+	Blocking int    `json:"blocking"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SummaryLinks are API paths, relative to server.public_url.
+type SummaryLinks struct {
+	Job       string `json:"job"`
+	Findings  string `json:"findings"`
+	Artifacts string `json:"artifacts"`
+	Summary   string `json:"summary"`
+	Repo      string `json:"repo,omitempty"`
+}
+
+// ToolCount is the number of a job's findings of one tool and severity.
+type ToolCount struct {
+	Tool, Severity string
+	N              int
+}
+
+// Row is one row of a query's result, as pgx returns it.
+type Row interface {
+	Scan(dest ...any) error
+}
+
+// QueryRowFunc runs a statement that returns one row, like pgx's QueryRow.
+type QueryRowFunc func(ctx context.Context, sql string, args ...any) Row
+
+// selectSummaryJob selects the job $1 as SummaryJob has it.
+const selectSummaryJob = `SELECT id::text, repo_id::text, status::text, source, ref, commit_sha, scanners, started_at, finished_at, error, skip_reason, failed_scanners FROM jobs WHERE id=$1`
+
+// selectFindingCounts selects job $1's finding counts by tool and severity
+// as parallel arrays.
+const selectFindingCounts = `SELECT COALESCE(array_agg(tool), '{}'), COALESCE(array_agg(severity), '{}'), COALESCE(array_agg(n), '{}')
+	FROM (SELECT tool::text AS tool, severity, count(*) AS n FROM findings WHERE job_id=$1 GROUP BY 1, 2) c`
+
+// selectFailOnPolicies selects the fail_on of each group of repo $1.
+const selectFailOnPolicies = `SELECT COALESCE(array_agg(g.fail_on), '{}') FROM repo_groups g JOIN repos r ON g.tag = ANY(r.tags) WHERE r.id=$1`
+
+// selectBaseline selects the job job $1 is compared with, or NULL.
+const selectBaseline = `SELECT (SELECT b.id::text FROM jobs j JOIN jobs b ON b.repo_id = j.repo_id AND b.id <> j.id AND b.status='succeeded'
+		AND b.ref IS NOT DISTINCT FROM j.ref AND COALESCE(b.scanners, '{}') = COALESCE(j.scanners, '{}') AND b.finished_at <= j.finished_at
+	WHERE j.id=$1 ORDER BY b.finished_at DESC LIMIT 1)`
+
+// selectChanges counts job $1's findings first seen after baseline $2,
+// and the baseline's findings no later scan has reported.
+const selectChanges = `SELECT
+	(SELECT count(*) FROM findings f WHERE f.job_id=$1 AND (f.fingerprint IS NULL
+		OR f.created_at > COALESCE((SELECT b.finished_at FROM jobs b WHERE b.id=$2), '-infinity'))),
+	(SELECT count(*) FROM findings p WHERE p.job_id=$2 AND p.fingerprint IS NOT NULL)`
+
+// selectToolChanges selects the tools whose version differs between job $1
+// and baseline $2.
+const selectToolChanges = `SELECT COALESCE(jsonb_object_agg(k, jsonb_build_object('from', b.tool_versions->>k, 'to', j.tool_versions->>k)), '{}')
+	FROM jobs j, jobs b, jsonb_object_keys(j.tool_versions) k
+	WHERE j.id=$1 AND b.id=$2 AND b.tool_versions ? k AND b.tool_versions->>k <> j.tool_versions->>k`
+
+// BuildSummary builds the summary of the finished job jobID. The error is
+// the one Scan returns when there is no such job.
+func BuildSummary(ctx context.Context, queryRow QueryRowFunc, jobID string) (Summary, error) {
+	var job SummaryJob
+	err := queryRow(ctx, selectSummaryJob, jobID).
+		Scan(&job.ID, &job.RepoID, &job.Status, &job.Source, &job.Ref, &job.CommitSHA, &job.Scanners, &job.StartedAt, &job.FinishedAt, &job.Error, &job.SkipReason, &job.FailedScanners)
+	if err != nil {
+		return Summary{}, err
+	}
+	var tools, severities []string
+	var ns []int64
+	if err := queryRow(ctx, selectFindingCounts, jobID).Scan(&tools, &severities, &ns); err != nil {
+		return Summary{}, err
+	}
+	counts := make([]ToolCount, len(tools))
+	for i := range tools {
+		counts[i] = ToolCount{Tool: tools[i], Severity: severities[i], N: int(ns[i])}
+	}
+	var policies []string
+	if job.RepoID != nil {
+		if err := queryRow(ctx, selectFailOnPolicies, *job.RepoID).Scan(&policies); err != nil {
+			return Summary{}, err
+		}
+	}
+
+	sum := Summarize(job, counts, StrictestFailOn(policies))
+	if job.Status == "succeeded" && job.RepoID != nil {
+		c := &SummaryChange{}
+		if err := queryRow(ctx, selectBaseline, jobID).Scan(&c.BaselineJobID); err != nil {
+			return Summary{}, err
+		}
+		if err := queryRow(ctx, selectChanges, jobID, c.BaselineJobID).Scan(&c.New, &c.Resolved); err != nil {
+			return Summary{}, err
+		}
+		if err := queryRow(ctx, selectToolChanges, jobID, c.BaselineJobID).Scan(&c.ToolChanges); err != nil {
+			return Summary{}, err
+		}
+		sum.Changes = c
+	}
+	return sum, nil
+}
+
+// Summarize builds the parts of the summary that follow from the job and
+// its finding counts.
+func Summarize(job SummaryJob, counts []ToolCount, failOn string) Summary {
+	s := Summary{SchemaVersion: SummaryVersion, Job: job,
+		Counts: SummaryCounts{BySeverity: map[string]int{}, ByTool: map[string]int{}},
+		Gate:   SummaryGate{FailOn: failOn},
+		Links: SummaryLinks{
+			Job:       "/api/jobs/" + job.ID,
+			Findings:  "/api/jobs/" + job.ID + "/findings",
+			Artifacts: "/api/jobs/" + job.ID + "/artifacts",
+			Summary:   "/api/jobs/" + job.ID + "/summary",
+		}}
+	if s.Job.Scanners == nil {
+		s.Job.Scanners = []string{}
+	}
+	if job.RepoID != nil {
+		s.Links.Repo = "/api/repos/" + *job.RepoID
+	}
+	threshold := severityRank(failOn)
+	for _, c := range counts {
+		s.Counts.Total += c.N
+		s.Counts.BySeverity[c.Severity] += c.N
+		s.Counts.ByTool[c.Tool] += c.N
+		if severityRank(c.Severity) >= threshold {
+			s.Gate.Blocking += c.N
+		}
+	}
+	switch {
+	case job.Status == "skipped", job.Status == "skipped_unchanged":
+		s.Gate.Pass, s.Gate.Reason = true, "scan skipped"
+	case job.Status != "succeeded":
+		s.Gate.Reason = "scan " + job.Status
+	case s.Gate.Blocking > 0:
+		s.Gate.Reason = "findings at or above " + failOn
+	default:
+		s.Gate.Pass = true
+	}
+	return s
+}
+
+// StrictestFailOn returns the lowest of the groups' fail_on severities, or
+// DefaultFailOn when none is set.
+func StrictestFailOn(policies []string) string {
+	out := ""
+	for _, p := range policies {
+		if r := severityRank(p); r > 0 && (out == "" || r < severityRank(out)) {
+			out = p
+		}
+	}
+	if out == "" {
+		return DefaultFailOn
+	}
+	return out
+}
+
+// severityRank orders severities as the scanners report them, with the
+// aliases some use; unknown severities rank 0.
+func severityRank(sev string) int {
+	switch strings.ToUpper(strings.TrimSpace(sev)) {
+	case "CRITICAL":
+		return 4
+	case "HIGH", "ERROR":
+		return 3
+	case "MEDIUM", "WARNING", "MODERATE":
+		return 2
+	case "LOW", "INFO", "NOTE":
+		return 1
+	}
+	return 0
+}
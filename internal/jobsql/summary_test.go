@@ -0,0 +1,56 @@
+package jobsql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	repo := "r1"
+	counts := []ToolCount{{"semgrep", "HIGH", 2}, {"trivy", "HIGH", 1}, {"trivy", "LOW", 4}}
+	s := Summarize(SummaryJob{ID: "j1", RepoID: &repo, Status: "succeeded"}, counts, "HIGH")
+	if s.Counts.Total != 7 || !reflect.DeepEqual(s.Counts.BySeverity, map[string]int{"HIGH": 3, "LOW": 4}) ||
+		!reflect.DeepEqual(s.Counts.ByTool, map[string]int{"semgrep": 2, "trivy": 5}) {
+		t.Errorf("counts = %+v", s.Counts)
+	}
+	if s.Gate.Pass || s.Gate.Blocking != 3 {
+		t.Errorf("gate = %+v", s.Gate)
+	}
+	if s.Links.Repo != "/api/repos/r1" || s.Links.Summary != "/api/jobs/j1/summary" {
+		t.Errorf("links = %+v", s.Links)
+	}
+	if s := Summarize(SummaryJob{ID: "j1", Status: "succeeded"}, counts, "CRITICAL"); !s.Gate.Pass || s.Gate.Blocking != 0 {
+		t.Errorf("CRITICAL gate = %+v", s.Gate)
+	}
+	if s := Summarize(SummaryJob{ID: "j1", Status: "failed"}, nil, "HIGH"); s.Gate.Pass || s.Gate.Reason != "scan failed" {
+		t.Errorf("failed gate = %+v", s.Gate)
+	}
+	if s := Summarize(SummaryJob{ID: "j1", Status: "skipped"}, nil, "HIGH"); !s.Gate.Pass {
+		t.Errorf("skipped gate = %+v", s.Gate)
+	}
+
+	// The document's keys are a contract with CI systems.
+	b, _ := json.Marshal(Summarize(SummaryJob{ID: "j1", Status: "succeeded"}, nil, "HIGH"))
+	for _, key := range []string{`"schema_version":1`, `"scanners":[]`, `"changes":null`, `"by_severity":{}`} {
+		if !strings.Contains(string(b), key) {
+			t.Errorf("summary %s lacks %s", b, key)
+		}
+	}
+}
+
+func TestStrictestFailOn(t *testing.T) {
+	for _, tc := range []struct {
+		in   []string
+		want string
+	}{
+		{nil, "HIGH"},
+		{[]string{"CRITICAL"}, "CRITICAL"},
+		{[]string{"CRITICAL", "MEDIUM", "HIGH"}, "MEDIUM"},
+	} {
+		if got := StrictestFailOn(tc.in); got != tc.want {
+			t.Errorf("StrictestFailOn(%v) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package proxy routes outbound HTTP through an explicitly configured proxy
+// per destination, for Go clients and for the environment of subprocesses
+// such as git and the scanners.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Direct is the setting that bypasses any proxy, including one set in the
+// environment.
+const Direct = "direct"
+
+// Setting is the proxy for one destination. An empty URL keeps the ambient
+// HTTPS_PROXY/HTTP_PROXY/NO_PROXY variables in charge.
+type Setting struct {
+	URL string // "", Direct, or an http, https or socks5 proxy URL
+	// NoProxy lists hosts reached directly; an entry also covers its
+	// subdomains.
+	NoProxy []string
+}
+
+// Validate checks a proxy setting value.
+func Validate(v string) error {
+	if v == "" || v == Direct {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("must be %q or a proxy URL such as http://proxy.internal:3128", Direct)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return fmt.Errorf("proxy scheme must be http, https or socks5")
+}
+
+// Func returns the http.Transport proxy function for the setting.
+func (s Setting) Func() func(*http.Request) (*url.URL, error) {
+	switch s.URL {
+	case "":
+		return http.ProxyFromEnvironment
+	case Direct:
+		return nil
+	}
+	u, _ := url.Parse(s.URL)
+	return func(r *http.Request) (*url.URL, error) {
+		if s.bypass(r.URL.Hostname()) {
+			return nil, nil
+		}
+		return u, nil
+	}
+}
+
+// Transport is a clone of http.DefaultTransport using the setting.
+func (s Setting) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = s.Func()
+	return t
+}
+
+func (s Setting) bypass(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range s.NoProxy {
+		h = strings.TrimPrefix(strings.ToLower(h), ".")
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+var proxyVars = []string{"HTTPS_PROXY", "HTTP_PROXY", "ALL_PROXY", "NO_PROXY"}
+
+// Environ returns the current environment with its proxy variables replaced
+// by the setting's, for a subprocess talking to the setting's destination.
+// An empty URL returns the environment unchanged.
+func (s Setting) Environ() []string {
+	env := os.Environ()
+	if s.URL == "" {
+		return env
+	}
+	out := make([]string, 0, len(env)+8)
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !isProxyVar(name) {
+			out = append(out, kv)
+		}
+	}
+	if s.URL == Direct {
+		return out
+	}
+	noProxy := strings.Join(s.NoProxy, ",")
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "ALL_PROXY"} {
+		out = append(out, name+"="+s.URL, strings.ToLower(name)+"="+s.URL)
+	}
+	if noProxy != "" {
+		out = append(out, "NO_PROXY="+noProxy, "no_proxy="+noProxy)
+	}
+	return out
+}
+
+func isProxyVar(name string) bool {
+	for _, v := range proxyVars {
+		if strings.EqualFold(name, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted is the setting's URL without credentials, for logs and self-test
+// output.
+func (s Setting) Redacted() string {
+	if s.URL == "" {
+		return "environment"
+	}
+	if s.URL == Direct {
+		return Direct
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "invalid"
+	}
+	return u.Redacted()
+}
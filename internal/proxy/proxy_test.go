@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFunc(t *testing.T) {
+	s := Setting{URL: "http://proxy.internal:3128", NoProxy: []string{"corp.example", ".local"}}
+	f := s.Func()
+	for host, want := range map[string]string{
+		"api.github.com":     "http://proxy.internal:3128",
+		"corp.example":       "",
+		"ghe.corp.example":   "",
+		"build.local":        "",
+		"notcorp.example.io": "http://proxy.internal:3128",
+	} {
+		u, err := f(&http.Request{URL: &url.URL{Scheme: "https", Host: host}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != want {
+			t.Errorf("proxy for %s = %q, want %q", host, got, want)
+		}
+	}
+	if (Setting{URL: Direct}).Func() != nil {
+		t.Error("direct should not proxy")
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://ambient:8080")
+	t.Setenv("no_proxy", "ambient.example")
+
+	env := strings.Join((Setting{URL: "http://proxy.internal:3128", NoProxy: []string{"corp.example"}}).Environ(), "\n")
+	if strings.Contains(env, "ambient") {
+		t.Errorf("ambient proxy variables kept:\n%s", env)
+	}
+	for _, want := range []string{"HTTPS_PROXY=http://proxy.internal:3128", "https_proxy=http://proxy.internal:3128", "NO_PROXY=corp.example"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	direct := strings.Join((Setting{URL: Direct}).Environ(), "\n")
+	if strings.Contains(direct, "PROXY=") || strings.Contains(direct, "proxy=") {
+		t.Errorf("direct left proxy variables:\n%s", direct)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, v := range []string{"", Direct, "http://p:3128", "socks5://user:pw@p:1080"} {
+		if err := Validate(v); err != nil {
+			t.Errorf("Validate(%q) = %v", v, err)
+		}
+	}
+	for _, v := range []string{"proxy:3128", "ftp://p", "http://"} {
+		if Validate(v) == nil {
+			t.Errorf("Validate(%q) accepted", v)
+		}
+	}
+}
@@ -14,7 +14,7 @@ type Finding = {
 };
 
 type PRResponse = {
-  mode: "dry-run" | "created";
+  mode: "dry-run" | "created" | "updated" | "unchanged";
   diff: string;
   pr_url?: string;
   branch?: string;
@@ -116,6 +116,10 @@ export default function App() {
     setPRPreview(res);
     if (res.mode === "created") {
       setStatus(`PR created: ${res.pr_url}`);
+    } else if (res.mode === "updated") {
+      setStatus(`Fixes pushed to the open PR: ${res.pr_url}`);
+    } else if (res.mode === "unchanged") {
+      setStatus(`No new fixes for the open PR: ${res.pr_url}`);
     } else {
       setStatus("Dry-run patch preview generated.");
     }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxArchiveEntries = 200000
+
+var errArchiveTooLarge = errors.New("archive exceeds extracted size limit")
+
+// fetchUpload extracts the archive stored for an ad-hoc job into repoDir. The
+// stored copy stays until the job succeeds, so a retry, requeue or replay can
+// extract it again.
+func fetchUpload(ctx context.Context, st store, jobID, repoDir string, maxMB int) error {
+	format, data, err := st.Upload(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(repoDir, 0o755); err != nil {
+		return err
+	}
+	return extractArchive(data, format, repoDir, int64(maxMB)*1024*1024)
+}
+
+func extractArchive(data []byte, format, dest string, maxBytes int64) error {
+	switch format {
+	case "zip":
+		return extractZip(data, dest, maxBytes)
+	case "tar.gz":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return extractTar(zr, dest, maxBytes)
+	case "tar":
+		return extractTar(bytes.NewReader(data), dest, maxBytes)
+	}
+	return fmt.Errorf("unsupported archive format %q", format)
+}
+
+func extractTar(r io.Reader, dest string, maxBytes int64) error {
+	tr := tar.NewReader(r)
+	var written int64
+	for n := 0; ; n++ {
+		if n > maxArchiveEntries {
+			return fmt.Errorf("archive has too many entries")
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, ok := archiveTarget(dest, hdr.Name)
+		if !ok {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(target, tr, &written, maxBytes); err != nil {
+				return err
+			}
+		default:
+			// Links and device nodes are never materialized: they could point
+			// scanners outside the workspace.
+		}
+	}
+}
+
+func extractZip(data []byte, dest string, maxBytes int64) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	if len(zr.File) > maxArchiveEntries {
+		return fmt.Errorf("archive has too many entries")
+	}
+	var written int64
+	for _, f := range zr.File {
+		target, ok := archiveTarget(dest, f.Name)
+		if !ok {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveFile(target, rc, &written, maxBytes)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveTarget resolves an entry name inside dest, rejecting absolute paths
+// and anything that would escape the workspace.
+func archiveTarget(dest, name string) (string, bool) {
+	name = filepath.FromSlash(strings.TrimSpace(name))
+	if name == "" || filepath.IsAbs(name) {
+		return "", false
+	}
+	target := filepath.Join(dest, name)
+	if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+		return "", false
+	}
+	return target, true
+}
+
+func writeArchiveFile(target string, r io.Reader, written *int64, maxBytes int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, maxBytes-*written+1))
+	*written += n
+	if err != nil {
+		return err
+	}
+	if *written > maxBytes {
+		return errArchiveTooLarge
+	}
+	return nil
+}
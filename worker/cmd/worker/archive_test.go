@@ -0,0 +1,54 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildTar(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, body := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractTarSkipsEscapingEntries(t *testing.T) {
+	dest := t.TempDir()
+	data := buildTar(t, map[string]string{
+		"src/app.py":       "print('hi')\n",
+		"../../escape.txt": "nope",
+		"/etc/passwd":      "nope",
+	})
+	if err := extractArchive(data, "tar", dest, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "src", "app.py")); err != nil {
+		t.Fatalf("expected regular entry to be extracted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(filepath.Dir(dest)), "escape.txt")); err == nil {
+		t.Fatal("entry escaped the destination directory")
+	}
+}
+
+func TestExtractTarEnforcesSizeCap(t *testing.T) {
+	data := buildTar(t, map[string]string{"big.bin": string(make([]byte, 4096))})
+	err := extractArchive(data, "tar", t.TempDir(), 1024)
+	if !errors.Is(err, errArchiveTooLarge) {
+		t.Fatalf("expected size cap error, got %v", err)
+	}
+}
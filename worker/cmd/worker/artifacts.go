@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// artifactScannerOutput is the raw report of one scanner. The API lists
+// kinds verbatim, so new producers (SBOMs, logs, diffs) just add a kind.
+const artifactScannerOutput = "scanner_output"
+
+// saveArtifact stores a file produced by the job. Failures are logged rather
+// than failing the scan: artifacts are a convenience, findings are the
+// product. Oversized artifacts are skipped.
+//
+// With an artifact store configured, scanner output is uploaded there under
+// jobs/<job id>/<name>, whatever its size, and the job only records where.
+// Other kinds are read back by the API and stay in the database.
+func (wk *Worker) saveArtifact(ctx context.Context, jobID, kind, name, contentType string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	a := artifact{Kind: kind, Name: name, ContentType: contentType, Data: data}
+	if wk.objects != nil && kind == artifactScannerOutput {
+		a.Object = wk.objects.Key(artifactObjectName(jobID, name))
+		if err := wk.objects.Put(ctx, a.Object, contentType, data); err != nil {
+			slog.WarnContext(ctx, "artifact upload failed; storing it in the database", "name", name, "err", err)
+			a.Object = ""
+		}
+	}
+	if max := int64(wk.cfg.ArtifactMaxMB) * 1024 * 1024; a.Object == "" && int64(len(data)) > max {
+		slog.WarnContext(ctx, "artifact too large; not stored", "name", name, "size_bytes", len(data), "max_mb", wk.cfg.ArtifactMaxMB)
+		return
+	}
+	if err := wk.store.SaveArtifact(ctx, jobID, a); err != nil {
+		slog.WarnContext(ctx, "store artifact failed", "name", name, "err", err)
+	}
+}
+
+// artifactObjectName is the object store name of a job's artifact, before
+// the configured prefix.
+func artifactObjectName(jobID, name string) string {
+	return "jobs/" + jobID + "/" + name
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"argus/internal/objstore"
+	"argus/internal/proxy"
+	"argus/worker/internal/config"
+)
+
+type artifactStore struct {
+	store
+	saved []artifact
+}
+
+func (s *artifactStore) SaveArtifact(_ context.Context, _ string, a artifact) error {
+	s.saved = append(s.saved, a)
+	return nil
+}
+
+func TestSaveArtifactObjectStore(t *testing.T) {
+	var puts []string
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		puts = append(puts, r.URL.Path)
+	}))
+	defer srv.Close()
+	objects, err := objstore.New(objstore.Config{Endpoint: srv.URL, Bucket: "argus", Region: "us-east-1", AccessKeyID: "id", SecretAccessKey: "secret", PathStyle: true}, proxy.Setting{URL: proxy.Direct})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &artifactStore{}
+	wk := &Worker{store: st, objects: objects, cfg: config.Config{ArtifactMaxMB: 1}}
+	ctx := context.Background()
+
+	big := make([]byte, 2<<20)
+	wk.saveArtifact(ctx, "j1", artifactScannerOutput, "trivy.json", "application/json", big)
+	wk.saveArtifact(ctx, "j1", artifactSBOM, "sbom.json", "application/json", []byte(`{}`))
+	if len(puts) != 1 || puts[0] != "/argus/jobs/j1/trivy.json" {
+		t.Fatalf("uploads = %v, want only the scanner output", puts)
+	}
+	if len(st.saved) != 2 || st.saved[0].Object != "jobs/j1/trivy.json" || st.saved[1].Object != "" {
+		t.Fatalf("saved = %+v", st.saved)
+	}
+
+	// A failed upload falls back to the database, within its size limit.
+	fail, st.saved = true, nil
+	wk.saveArtifact(ctx, "j1", artifactScannerOutput, "semgrep.json", "application/json", []byte(`{}`))
+	wk.saveArtifact(ctx, "j1", artifactScannerOutput, "trivy.json", "application/json", big)
+	if len(st.saved) != 1 || st.saved[0].Name != "semgrep.json" || st.saved[0].Object != "" {
+		t.Fatalf("saved after a failed upload = %+v", st.saved)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"argus/worker/internal/severity"
+)
+
+// bandit looks for insecure patterns in the clone's Python code: shell
+// injection, unsafe deserialization, weak crypto, hardcoded bind addresses.
+// It only runs when the clone has Python files outside vendored
+// directories, which it also skips.
+
+type banditOut struct {
+	Results []struct {
+		Filename   string `json:"filename"`
+		Severity   string `json:"issue_severity"`
+		Confidence string `json:"issue_confidence"`
+		CWE        struct {
+			ID   int    `json:"id"`
+			Link string `json:"link"`
+		} `json:"issue_cwe"`
+		Text      string `json:"issue_text"`
+		Line      int    `json:"line_number"`
+		LineRange []int  `json:"line_range"`
+		MoreInfo  string `json:"more_info"`
+		TestID    string `json:"test_id"`
+		TestName  string `json:"test_name"`
+	} `json:"results"`
+}
+
+func (wk *Worker) runBandit(ctx context.Context, job *scanJob) error {
+	if !hasPythonFiles(job.dir) {
+		return nil
+	}
+	args := []string{"-r", ".", "--format", "json", "--quiet", "--exit-zero", "--exclude", banditExcludes()}
+	out, err := runCmdJSON(ctx, "bandit", args, job.dir, nil)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "bandit.json", "application/json", out)
+	var parsed banditOut
+	if perr := json.Unmarshal(out, &parsed); perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("bandit parse error: %v", perr)
+	}
+	job.addBanditResults(parsed)
+	return err
+}
+
+// banditExcludes lists skipDirs for --exclude. bandit matches each entry as
+// a substring of the path, so they are anchored by slashes.
+func banditExcludes() string {
+	dirs := make([]string, 0, len(skipDirs))
+	for d := range skipDirs {
+		dirs = append(dirs, "/"+d+"/")
+	}
+	sort.Strings(dirs)
+	return strings.Join(dirs, ",")
+}
+
+// hasPythonFiles reports whether dir has a .py file outside skipDirs.
+func hasPythonFiles(dir string) bool {
+	found, seen := false, 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles {
+			return filepath.SkipAll
+		}
+		if strings.EqualFold(filepath.Ext(d.Name()), ".py") {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+func (j *scanJob) addBanditResults(parsed banditOut) {
+	for _, r := range parsed.Results {
+		sev := severity.Normalize("bandit", r.Severity)
+		file := strings.TrimPrefix(filepath.ToSlash(r.Filename), "./")
+		parts := map[string]string{"rule_id": r.TestID, "test_name": r.TestName, "message": r.Text, "path": file, "line": strconv.Itoa(r.Line), "severity": sev}
+		title := j.titles.render(titleBandit, parts)
+		desc := r.Text
+		fpv := j.fp("bandit", r.TestID, file, strconv.Itoa(r.Line))
+		ls, le := r.Line, r.Line
+		if n := len(r.LineRange); n > 0 && r.LineRange[n-1] > le {
+			le = r.LineRange[n-1]
+		}
+		cwes := []string{}
+		if r.CWE.ID > 0 {
+			cwes = append(cwes, "CWE-"+strconv.Itoa(r.CWE.ID))
+		}
+		j.add(finding{Tool: "bandit", Severity: sev, label: r.Severity, Title: title, FilePath: &file, LineStart: &ls, LineEnd: &le, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"rule_id":     r.TestID,
+			"test_name":   r.TestName,
+			"confidence":  strings.ToUpper(r.Confidence),
+			"cwe":         cwes,
+			"url":         r.MoreInfo,
+			"title_parts": parts,
+		}})
+	}
+}
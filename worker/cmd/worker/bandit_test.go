@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHasPythonFiles(t *testing.T) {
+	dir := t.TempDir()
+	vendored := filepath.Join(dir, "node_modules", "x", "setup.py")
+	if err := os.MkdirAll(filepath.Dir(vendored), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(vendored, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if hasPythonFiles(dir) {
+		t.Fatal("a vendored Python file should not count")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.py"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !hasPythonFiles(dir) {
+		t.Fatal("app.py not detected")
+	}
+}
+
+func TestAddBanditResults(t *testing.T) {
+	var parsed banditOut
+	out := []byte(`{"errors":[],"results":[
+		{"code":"4 subprocess.call(cmd, shell=True)\n","filename":"./app/run.py","issue_confidence":"HIGH","issue_cwe":{"id":78,"link":"https://cwe.mitre.org/data/definitions/78.html"},
+		 "issue_severity":"HIGH","issue_text":"subprocess call with shell=True identified, security issue.","line_number":4,"line_range":[4,5],
+		 "more_info":"https://bandit.readthedocs.io/en/1.7.10/plugins/b602_subprocess_popen_with_shell_equals_true.html","test_id":"B602","test_name":"subprocess_popen_with_shell_equals_true"},
+		{"filename":"./app/x.py","issue_confidence":"MEDIUM","issue_cwe":{},"issue_severity":"UNDEFINED","issue_text":"x","line_number":1,"line_range":[1],"test_id":"B999","test_name":"x"}]}`)
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addBanditResults(parsed)
+	if len(job.findings) != 2 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	if f.Tool != "bandit" || f.Severity != "HIGH" || f.Title != "B602: subprocess call with shell=True identified, security issue." || *f.FilePath != "app/run.py" || *f.LineStart != 4 || *f.LineEnd != 5 {
+		t.Errorf("finding = %+v", f)
+	}
+	ev := f.Evidence.(map[string]any)
+	if ev["rule_id"] != "B602" || ev["confidence"] != "HIGH" || !reflect.DeepEqual(ev["cwe"], []string{"CWE-78"}) {
+		t.Errorf("evidence = %v", ev)
+	}
+	if f := job.findings[1]; f.Severity != "LOW" || len(f.Evidence.(map[string]any)["cwe"].([]string)) != 0 {
+		t.Errorf("finding = %+v", f)
+	}
+	if got := banditExcludes(); got != "/.git/,/node_modules/,/third_party/,/vendor/" {
+		t.Errorf("excludes = %q", got)
+	}
+}
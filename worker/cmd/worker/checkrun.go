@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"argus/worker/internal/githubapp"
+)
+
+// checkRunName is what Argus's check runs are called on GitHub.
+const checkRunName = "Argus"
+
+// maxCheckAnnotations caps the annotations of one check run; GitHub takes
+// them 50 to a request.
+const maxCheckAnnotations = 500
+
+var checkSeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}
+
+// postCheckRun reports a pull request scan as a check run on the commit it
+// scanned. The findings the default branch does not have that point at a
+// line become annotations, which GitHub shows in the pull request's Files
+// Changed tab.
+func (wk *Worker) postCheckRun(ctx context.Context, pr pullRequest, jobID string, job *scanJob, added []finding) {
+	run := checkRun(pr.sha, len(job.findings), added, job.failedScanners())
+	run.ExternalID = jobID
+	if wk.cfg.PublicURL != "" {
+		run.DetailsURL = strings.TrimSuffix(wk.cfg.PublicURL, "/") + "/api/jobs/" + jobID
+	}
+	checkID, err := wk.github.CreateCheckRun(ctx, pr.token, pr.owner, pr.repo, run)
+	if err != nil {
+		slog.WarnContext(ctx, "check run not posted", "pull_request", pr.number, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "check run posted", "pull_request", pr.number, "check_run_id", checkID,
+		"conclusion", run.Conclusion, "annotations", len(run.Output.Annotations))
+}
+
+// checkRun builds the check run of a scan of sha that reported total
+// findings, added of them not on the default branch. It judges the head as
+// a whole, so a finding a previous push brought in keeps failing it until
+// it is fixed: added CRITICAL or HIGH findings fail it, and scanners that
+// failed under the continue policy make it neutral.
+func checkRun(sha string, total int, added []finding, failedScanners []string) githubapp.CheckRun {
+	counts := map[string]int{}
+	var annotations []githubapp.Annotation
+	for _, f := range added {
+		counts[f.Severity]++
+		if f.FilePath == nil || f.LineStart == nil || len(annotations) == maxCheckAnnotations {
+			continue
+		}
+		end := *f.LineStart
+		if f.LineEnd != nil && *f.LineEnd >= end {
+			end = *f.LineEnd
+		}
+		msg := f.Severity + " finding from " + f.Tool + "."
+		if f.Description != nil && *f.Description != "" {
+			msg += "\n\n" + *f.Description
+		}
+		annotations = append(annotations, githubapp.Annotation{
+			Path:      *f.FilePath,
+			StartLine: *f.LineStart,
+			EndLine:   end,
+			Level:     annotationLevel(f.Severity),
+			Title:     f.Title,
+			Message:   msg,
+		})
+	}
+
+	conclusion := "success"
+	switch {
+	case counts["CRITICAL"]+counts["HIGH"] > 0:
+		conclusion = "failure"
+	case len(failedScanners) > 0:
+		conclusion = "neutral"
+	}
+
+	title := "No findings the default branch lacks"
+	switch len(added) {
+	case 0:
+	case 1:
+		title = "1 finding the default branch lacks"
+	default:
+		title = fmt.Sprintf("%d findings the default branch lacks", len(added))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Argus found %d findings in this commit, %d of them not on the default branch.\n", total, len(added))
+	if len(added) > 0 {
+		b.WriteString("\n| Severity | Not on the default branch |\n| --- | --- |\n")
+		for _, sev := range checkSeverities {
+			if counts[sev] > 0 {
+				fmt.Fprintf(&b, "| %s | %d |\n", sev, counts[sev])
+			}
+		}
+	}
+	if len(annotations) == maxCheckAnnotations {
+		fmt.Fprintf(&b, "\nOnly the first %d of them with a location are annotated.\n", maxCheckAnnotations)
+	}
+	if len(failedScanners) > 0 {
+		fmt.Fprintf(&b, "\nThese scanners failed, so their findings are missing: %s.\n", strings.Join(failedScanners, ", "))
+	}
+
+	return githubapp.CheckRun{
+		Name:       checkRunName,
+		HeadSHA:    sha,
+		Conclusion: conclusion,
+		Output:     githubapp.CheckOutput{Title: title, Summary: b.String(), Annotations: annotations},
+	}
+}
+
+func annotationLevel(sev string) string {
+	switch sev {
+	case "CRITICAL", "HIGH":
+		return githubapp.LevelFailure
+	case "MEDIUM":
+		return githubapp.LevelWarning
+	}
+	return githubapp.LevelNotice
+}
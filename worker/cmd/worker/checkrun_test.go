@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"argus/worker/internal/githubapp"
+)
+
+func TestCheckRun(t *testing.T) {
+	path, line, end := "app/main.go", 12, 14
+	desc := "Hard-coded credential."
+	added := []finding{
+		{Tool: "gitleaks", Severity: "HIGH", Title: "Secret detected", FilePath: &path, LineStart: &line, LineEnd: &end, Description: &desc},
+		{Tool: "trivy", Severity: "MEDIUM", Title: "CVE-2024-0001 in lodash"},
+	}
+	run := checkRun("abc123", 5, added, nil)
+	if run.Name != "Argus" || run.HeadSHA != "abc123" || run.Conclusion != "failure" || run.Output.Title != "2 findings the default branch lacks" {
+		t.Errorf("check run = %+v", run)
+	}
+	want := githubapp.Annotation{Path: path, StartLine: 12, EndLine: 14, Level: githubapp.LevelFailure, Title: "Secret detected",
+		Message: "HIGH finding from gitleaks.\n\nHard-coded credential."}
+	if len(run.Output.Annotations) != 1 || run.Output.Annotations[0] != want {
+		t.Errorf("annotations = %+v", run.Output.Annotations)
+	}
+	if s := run.Output.Summary; !strings.Contains(s, "5 findings in this commit, 2 of them not on the default branch") || !strings.Contains(s, "| MEDIUM | 1 |") {
+		t.Errorf("summary = %q", s)
+	}
+
+	if run := checkRun("abc123", 3, added[1:], []string{"semgrep"}); run.Conclusion != "neutral" || run.Output.Title != "1 finding the default branch lacks" {
+		t.Errorf("check run with a failed scanner = %+v", run)
+	}
+	if run := checkRun("abc123", 3, nil, nil); run.Conclusion != "success" || run.Output.Title != "No findings the default branch lacks" {
+		t.Errorf("check run without findings the default branch lacks = %+v", run)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+const (
+	cloneFiltered = "filtered"
+	cloneShallow  = "shallow"
+	cloneFull     = "full"
+)
+
+// cloneLadder is tried in order; each rung is more compatible and more
+// expensive than the previous one. Some hosts and proxies reject partial
+// clone filters, and a few servers refuse shallow fetches altogether.
+var cloneLadder = []string{cloneFiltered, cloneShallow, cloneFull}
+
+var errCloneFatal = errors.New("clone failed")
+
+// Partial-clone filters of the filtered rung. tree:0 also leaves out trees
+// until checkout needs them, which pays off with a sparse checkout.
+const (
+	filterBlobless = "blob:none"
+	filterTreeless = "tree:0"
+)
+
+// cloneOptions narrow what a clone fetches; see repoSettings.cloneOptions.
+type cloneOptions struct {
+	filter string // of the filtered rung; empty means filterBlobless
+	// sparse are the gitignore-style patterns of a sparse checkout; nil
+	// checks out the whole tree.
+	sparse []string
+	// singleBranch keeps a full clone to the history of ref.
+	singleBranch bool
+}
+
+// safeClone clones repoURL into repoDir, falling back down cloneLadder when a
+// strategy is unsupported, and returns the strategy that succeeded, marked
+// "+sparse" for a sparse checkout. The size lookup asks gh's API; both it
+// and git go through the GitHub proxy p.
+func safeClone(ctx context.Context, repoURL, ref, repoDir, token string, maxCloneMB int, opts cloneOptions, gh forge.GitHub, p proxy.Setting) (string, error) {
+	cloneURL := repoURL
+	if token != "" {
+		cloneURL = strings.Replace(repoURL, "https://", "https://x-access-token:"+token+"@", 1)
+	}
+
+	var lastErr error
+	for _, strategy := range cloneLadder {
+		if strategy == cloneFull {
+			// A full clone downloads all history, so make sure the repo is
+			// within budget before starting rather than after.
+			if err := precheckRepoSize(ctx, repoURL, token, maxCloneMB, gh, p); err != nil {
+				return "", fmt.Errorf("%w; full clone skipped: %v", lastErr, err)
+			}
+		}
+		_ = os.RemoveAll(repoDir)
+		err := gitClone(ctx, strategy, cloneURL, ref, repoDir, opts, p)
+		if err == nil && len(opts.sparse) > 0 {
+			strategy += "+sparse"
+		}
+		if err == nil {
+			if err := enforceCloneSize(repoDir, maxCloneMB); err != nil {
+				return strategy, err
+			}
+			return strategy, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || errors.Is(err, errCloneFatal) {
+			return "", err
+		}
+		slog.WarnContext(ctx, "clone strategy failed; falling back", "strategy", strategy, "err", err)
+	}
+	return "", lastErr
+}
+
+func gitClone(ctx context.Context, strategy, cloneURL, ref, repoDir string, opts cloneOptions, p proxy.Setting) error {
+	args := []string{"clone", "--no-tags"}
+	switch strategy {
+	case cloneFiltered:
+		filter := opts.filter
+		if filter == "" {
+			filter = filterBlobless
+		}
+		args = append(args, "--depth", "1", "--filter="+filter)
+	case cloneShallow:
+		args = append(args, "--depth", "1")
+	case cloneFull:
+		if opts.singleBranch {
+			args = append(args, "--single-branch")
+		}
+	}
+	if len(opts.sparse) > 0 {
+		// The checkout waits for the sparse patterns, so a partial clone
+		// only fetches the blobs inside them.
+		args = append(args, "--no-checkout")
+	}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, cloneURL, repoDir)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	recordCommand(ctx, "git", args, start, out, err)
+	if err != nil {
+		msg := redactToken(string(out))
+		// Auth and missing-ref errors will not improve with a different
+		// strategy, so stop instead of burning time on a full clone.
+		lower := strings.ToLower(msg)
+		if strings.Contains(lower, "authentication failed") || strings.Contains(lower, "repository not found") ||
+			strings.Contains(lower, "could not find remote branch") || strings.Contains(lower, "not found in upstream") {
+			return fmt.Errorf("%w: git clone (%s): %v: %s", errCloneFatal, strategy, err, msg)
+		}
+		return fmt.Errorf("git clone (%s): %v: %s", strategy, err, msg)
+	}
+	if len(opts.sparse) > 0 {
+		return sparseCheckout(ctx, repoDir, opts.sparse, p)
+	}
+	return nil
+}
+
+// sparseCheckout checks out only the paths the patterns select in a clone
+// made with --no-checkout. Non-cone mode takes gitignore-style patterns,
+// including negations, like the repo settings' globs.
+func sparseCheckout(ctx context.Context, repoDir string, patterns []string, p proxy.Setting) error {
+	set := []string{"-C", repoDir, "sparse-checkout", "set", "--no-cone", "--stdin"}
+	if err := gitStep(ctx, set, strings.Join(patterns, "\n")+"\n", p); err != nil {
+		return err
+	}
+	return gitStep(ctx, []string{"-C", repoDir, "checkout", "--quiet"}, "", p)
+}
+
+// gitStep runs one step of setting up a clone; a failure is fatal to it.
+func gitStep(ctx context.Context, args []string, stdin string, p proxy.Setting) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdin = strings.NewReader(stdin)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	recordCommand(ctx, "git", args, start, out, err)
+	if err != nil {
+		return fmt.Errorf("%w: git %s: %v: %s", errCloneFatal, args[2], err, redactToken(string(out)))
+	}
+	return nil
+}
+
+// checkoutCommit checks out sha in a clone of its ref, fetching it first
+// when the clone's shallow history does not include it. The fetch reuses
+// the clone's remote and partial-clone filter.
+func checkoutCommit(ctx context.Context, repoDir, sha string, p proxy.Setting) error {
+	if err := fetchCommit(ctx, repoDir, sha, p); err != nil {
+		return err
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "checkout", "--quiet", "--detach", sha).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git checkout %s: %v: %s", sha, err, out)
+	}
+	return nil
+}
+
+// fetchCommit fetches sha into the clone unless it is already there.
+func fetchCommit(ctx context.Context, repoDir, sha string, p proxy.Setting) error {
+	if exec.CommandContext(ctx, "git", "-C", repoDir, "cat-file", "-e", sha+"^{commit}").Run() == nil {
+		return nil
+	}
+	args := []string{"-C", repoDir, "fetch", "--no-tags", "--depth", "1", "origin", sha}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	recordCommand(ctx, "git", args, start, out, err)
+	if err != nil {
+		return fmt.Errorf("git fetch %s: %v: %s", sha, err, redactToken(string(out)))
+	}
+	return nil
+}
+
+// headCommit returns the full SHA checked out in repoDir.
+func headCommit(ctx context.Context, repoDir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// precheckRepoSize asks gh's API for the repository size. It fails closed:
+// if the size cannot be determined the full clone is not attempted.
+func precheckRepoSize(ctx context.Context, repoURL, token string, maxCloneMB int, gh forge.GitHub, p proxy.Setting) error {
+	owner, name, ok := parseGitHubRepo(repoURL)
+	if !ok || !gh.Owns(repoURL) {
+		return fmt.Errorf("cannot determine repo size for %s", repoURL)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, fmt.Sprintf("%s/repos/%s/%s", gh.APIURL(), owner, name), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := (&http.Client{Transport: p.Transport()}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("repo size lookup failed status=%d", resp.StatusCode)
+	}
+	var out struct {
+		Size int64 `json:"size"` // KB
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+	if out.Size > int64(maxCloneMB)*1024 {
+		return fmt.Errorf("repo is %d MB, exceeds size limit (%d MB)", out.Size/1024, maxCloneMB)
+	}
+	return nil
+}
+
+func enforceCloneSize(repoDir string, maxCloneMB int) error {
+	if dirSize(repoDir) > int64(maxCloneMB)*1024*1024 {
+		return fmt.Errorf("repo exceeds size limit (%d MB)", maxCloneMB)
+	}
+	return nil
+}
+
+// dirSize is the total size in bytes of the regular files under dir.
+func dirSize(dir string) int64 {
+	var sizeBytes int64
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil || info == nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			sizeBytes += info.Size()
+		}
+		return nil
+	})
+	return sizeBytes
+}
+
+// parseGitHubRepo splits a GitHub repo URL, on github.com or an Enterprise
+// Server, into owner and name.
+func parseGitHubRepo(raw string) (owner, repo string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), true
+}
+
+func redactToken(s string) string {
+	if i := strings.Index(s, "x-access-token:"); i >= 0 {
+		if j := strings.Index(s[i:], "@"); j >= 0 {
+			return s[:i] + "x-access-token:***" + s[i+j:]
+		}
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestRepoSettingsCloneOptions(t *testing.T) {
+	s := repoSettings{Include: []string{"services/api/**"}, Exclude: []string{"*.min.js"}}
+	if o := s.cloneOptions(); o.sparse != nil {
+		t.Errorf("sparse without clone.sparse: %q", o.sparse)
+	}
+	s.Clone = cloneSettings{Filter: filterTreeless, Sparse: true, SparsePaths: []string{"/go.mod"}}
+	o := s.cloneOptions()
+	if want := []string{"services/api/**", "/go.mod", "!*.min.js"}; !reflect.DeepEqual(o.sparse, want) || o.filter != filterTreeless {
+		t.Errorf("cloneOptions = %+v, want sparse %q", o, want)
+	}
+	if o := (repoSettings{Exclude: []string{"docs/**"}, Clone: cloneSettings{Sparse: true}}).cloneOptions(); !reflect.DeepEqual(o.sparse, []string{"/*", "!docs/**"}) {
+		t.Errorf("exclude only: sparse = %q", o.sparse)
+	}
+}
+
+func TestSparseClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	src := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", src, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	for _, name := range []string{"go.mod", "services/api/main.go", "services/api/app.min.js", "services/web/index.js", "docs/guide.md"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	git("config", "uploadpack.allowFilter", "true")
+	git("add", "-A")
+	git("commit", "-qm", "init")
+
+	dst := filepath.Join(t.TempDir(), "repo")
+	s := repoSettings{Include: []string{"services/api/**"}, Exclude: []string{"*.min.js"}, Clone: cloneSettings{Sparse: true, SparsePaths: []string{"/go.mod"}}}
+	strategy, err := safeClone(context.Background(), "file://"+src, "", dst, "", 10, s.cloneOptions(), forge.GitHubDotCom, proxy.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy != cloneFiltered+"+sparse" {
+		t.Errorf("strategy = %q", strategy)
+	}
+	var files []string
+	_ = filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dst, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	if want := []string{"go.mod", "services/api/main.go"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("checked out %s, want %s", strings.Join(files, ", "), strings.Join(want, ", "))
+	}
+}
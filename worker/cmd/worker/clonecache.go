@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+	"argus/worker/internal/config"
+)
+
+// The clone cache keeps a bare mirror of each repo under
+// <cache.dir>/git/<key>.git. A job fetches its ref into the mirror and
+// checks it out as a worktree in its workspace, so a repo scanned often only
+// downloads what changed since its last scan. Like the warm cache, one
+// cache.dir may be shared by every worker process on a node.
+//
+// Two flocks guard a mirror. <key>.use is held shared by every job whose
+// worktree points into the mirror, from checkout until its workspace is
+// gone. <key>.lock is held exclusively while a job fetches or adds its
+// worktree. Eviction removes the least recently used mirrors once they
+// exceed cache.clones_max_mb, skipping any a job holds either lock on.
+//
+// The token is passed to git as an HTTP header in the environment and is
+// never written into the mirror's config.
+
+const (
+	cloneCached = "cached"
+
+	cloneCacheDir     = "git"
+	cloneEvictLock    = "evict.lock"
+	cloneLockPoll     = 200 * time.Millisecond
+	cloneFetchedRefNS = "refs/argus/"
+)
+
+type cloneCache struct {
+	dir    string
+	budget int64 // bytes
+}
+
+// newCloneCache returns nil unless cache.dir and cache.clones_max_mb are set.
+func newCloneCache(cfg config.Config) *cloneCache {
+	if cfg.CacheDir == "" || cfg.CloneCacheMB <= 0 {
+		return nil
+	}
+	return &cloneCache{dir: filepath.Join(cfg.CacheDir, cloneCacheDir), budget: int64(cfg.CloneCacheMB) << 20}
+}
+
+// cloneRepo puts ref of repo into repoDir and returns the strategy used and
+// a release func to call once the job is done with repoDir. It checks out
+// from the clone cache when there is one, falling back to safeClone when
+// the cache fails; sparse checkouts always clone.
+func (wk *Worker) cloneRepo(ctx context.Context, repo RepoRow, ref, repoDir, token string, commits ...string) (string, func(), error) {
+	opts := repo.Settings.cloneOptions()
+	gh, p := forge.GitHub(wk.cfg.GitHubURL), wk.cfg.GitHubProxySetting()
+	if wk.clones != nil && len(opts.sparse) == 0 {
+		release, err := wk.clones.checkout(ctx, repo.URL, ref, repoDir, token, wk.cfg.MaxCloneMB, commits, gh, p)
+		if err == nil {
+			if err := enforceCloneSize(repoDir, wk.cfg.MaxCloneMB); err != nil {
+				return cloneCached, release, err
+			}
+			go wk.clones.evict()
+			return cloneCached, release, nil
+		}
+		if ctx.Err() != nil {
+			return "", func() {}, err
+		}
+		slog.WarnContext(ctx, "clone cache failed; cloning afresh", "err", err)
+	}
+	strategy, err := safeClone(ctx, repo.URL, ref, repoDir, token, wk.cfg.MaxCloneMB, opts, gh, p)
+	return strategy, func() {}, err
+}
+
+// mirrorKey names the mirror of repoURL.
+func mirrorKey(repoURL string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(strings.ToLower(repoURL), ".git")))
+	return hex.EncodeToString(sum[:12])
+}
+
+// checkout fetches ref, and any of commits the mirror lacks, into the
+// mirror of repoURL and checks ref out as a detached worktree at dst. The
+// returned func releases the mirror; dst must be removed by then or soon
+// after, which the workspace cleanup does.
+func (c *cloneCache) checkout(ctx context.Context, repoURL, ref, dst, token string, maxCloneMB int, commits []string, gh forge.GitHub, p proxy.Setting) (func(), error) {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, err
+	}
+	key := mirrorKey(repoURL)
+	mirror := filepath.Join(c.dir, key+".git")
+	use, err := flockFile(filepath.Join(c.dir, key+".use"), os.O_RDWR|os.O_CREATE, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	release := func() { use.Close() }
+	lock, err := flockWait(ctx, filepath.Join(c.dir, key+".lock"))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	defer lock.Close()
+
+	if err := c.update(ctx, mirror, repoURL, ref, token, maxCloneMB, commits, gh, p); err != nil {
+		release()
+		return nil, err
+	}
+	sha, err := gitOutput(ctx, mirror, "rev-parse", "--verify", fetchedRef(ref)+"^{commit}")
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if err := gitStep(ctx, []string{"-C", mirror, "worktree", "add", "--quiet", "--detach", dst, sha}, "", p); err != nil {
+		release()
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(c.dir, key+".use"), now, now)
+	return release, nil
+}
+
+// update creates the mirror if needed and fetches into it. The caller holds
+// the mirror's lock.
+func (c *cloneCache) update(ctx context.Context, mirror, repoURL, ref, token string, maxCloneMB int, commits []string, gh forge.GitHub, p proxy.Setting) error {
+	env := append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if token != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+		env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: Basic "+basic)
+	}
+	fresh := false
+	if _, err := os.Stat(mirror); errors.Is(err, os.ErrNotExist) {
+		// The first fetch downloads all history, so check the repo is
+		// within budget first, as a full clone would.
+		if gh.Owns(repoURL) {
+			if err := precheckRepoSize(ctx, repoURL, token, maxCloneMB, gh, p); err != nil {
+				return err
+			}
+		}
+		if err := gitStep(ctx, []string{"-C", c.dir, "init", "--quiet", "--bare", filepath.Base(mirror)}, "", p); err != nil {
+			_ = os.RemoveAll(mirror)
+			return err
+		}
+		if err := gitStep(ctx, []string{"-C", mirror, "remote", "add", "origin", repoURL}, "", p); err != nil {
+			_ = os.RemoveAll(mirror)
+			return err
+		}
+		fresh = true
+	}
+	// Worktrees of finished jobs are gone from disk; forget them.
+	_ = gitStep(ctx, []string{"-C", mirror, "worktree", "prune"}, "", p)
+
+	src := ref
+	if src == "" {
+		src = "HEAD"
+	}
+	if err := gitEnvStep(ctx, env, "-C", mirror, "fetch", "--quiet", "--no-tags", "origin", "+"+src+":"+fetchedRef(ref)); err != nil {
+		if fresh {
+			_ = os.RemoveAll(mirror)
+		}
+		return err
+	}
+	if fresh && dirSize(mirror) > int64(maxCloneMB)<<20 {
+		_ = os.RemoveAll(mirror)
+		return fmt.Errorf("repo exceeds size limit (%d MB)", maxCloneMB)
+	}
+	// Commits off ref, such as a pinned commit or an incremental scan's
+	// base, are fetched now while the token is at hand. One that cannot be
+	// fetched is left to the later checkout to report.
+	for _, sha := range commits {
+		if sha == "" || exec.CommandContext(ctx, "git", "-C", mirror, "cat-file", "-e", sha+"^{commit}").Run() == nil {
+			continue
+		}
+		if err := gitEnvStep(ctx, env, "-C", mirror, "fetch", "--quiet", "--no-tags", "origin", sha); err != nil {
+			slog.WarnContext(ctx, "commit not fetched into the clone cache", "sha", sha, "err", err)
+		}
+	}
+	return nil
+}
+
+// fetchedRef is where the mirror keeps what it fetched of ref, so the
+// history scans diff against stays reachable.
+func fetchedRef(ref string) string {
+	if ref == "" {
+		return cloneFetchedRefNS + "HEAD"
+	}
+	return cloneFetchedRefNS + strings.TrimPrefix(ref, "refs/")
+}
+
+// gitEnvStep runs a git command of the cache with env. Unlike gitStep, a
+// failure leaves the job free to clone afresh.
+func gitEnvStep(ctx context.Context, env []string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = env
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	recordCommand(ctx, "git", args, start, out, err)
+	if err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[2], err, redactToken(string(out)))
+	}
+	return nil
+}
+
+func gitOutput(ctx context.Context, repoDir string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", append([]string{"-C", repoDir}, args...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// flockWait takes an exclusive flock on path, polling until it is free or
+// ctx is done; another job may be fetching a large repo.
+func flockWait(ctx context.Context, path string) (*os.File, error) {
+	for {
+		f, err := flockFile(path, os.O_RDWR|os.O_CREATE, syscall.LOCK_EX|syscall.LOCK_NB)
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return f, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(cloneLockPoll):
+		}
+	}
+}
+
+// evict removes the least recently used mirrors until the rest fit the
+// budget. Mirrors in use are kept even when that leaves the cache over
+// budget. Only one process evicts at a time.
+func (c *cloneCache) evict() {
+	lock, err := flockFile(filepath.Join(c.dir, cloneEvictLock), os.O_RDWR|os.O_CREATE, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		return
+	}
+	defer lock.Close()
+
+	type mirror struct {
+		key  string
+		used time.Time
+		size int64
+	}
+	matches, _ := filepath.Glob(filepath.Join(c.dir, "*.git"))
+	var mirrors []mirror
+	var total int64
+	for _, path := range matches {
+		key := strings.TrimSuffix(filepath.Base(path), ".git")
+		m := mirror{key: key, size: dirSize(path)}
+		if info, err := os.Stat(filepath.Join(c.dir, key+".use")); err == nil {
+			m.used = info.ModTime()
+		}
+		mirrors = append(mirrors, m)
+		total += m.size
+	}
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].used.Before(mirrors[j].used) })
+	for _, m := range mirrors {
+		if total <= c.budget {
+			return
+		}
+		if c.remove(m.key) {
+			total -= m.size
+			slog.Info("clone cache evicted a mirror", "key", m.key, "mb", m.size>>20)
+		}
+	}
+}
+
+// remove deletes the mirror of key unless a job holds it. The lock files
+// stay, since another process may already have them open.
+func (c *cloneCache) remove(key string) bool {
+	lock, err := flockFile(filepath.Join(c.dir, key+".lock"), os.O_RDWR|os.O_CREATE, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		return false
+	}
+	defer lock.Close()
+	use, err := flockFile(filepath.Join(c.dir, key+".use"), os.O_RDWR|os.O_CREATE, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		return false
+	}
+	defer use.Close()
+	return os.RemoveAll(filepath.Join(c.dir, key+".git")) == nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestCloneCacheCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	src := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", src, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(content string) string {
+		if err := os.WriteFile(filepath.Join(src, "main.go"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-qm", content)
+		return git("rev-parse", "HEAD")
+	}
+	git("init", "-q")
+	first := commit("v1\n")
+
+	c := &cloneCache{dir: filepath.Join(t.TempDir(), "git"), budget: 1 << 30}
+	ctx := context.Background()
+	work := t.TempDir()
+	checkout := func(name string) (string, func()) {
+		dst := filepath.Join(work, name)
+		release, err := c.checkout(ctx, "file://"+src, "", dst, "", 10, nil, forge.GitHubDotCom, proxy.Setting{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sha, err := headCommit(ctx, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha, release
+	}
+
+	sha, release := checkout("one")
+	if sha != first {
+		t.Errorf("first checkout at %s, want %s", sha, first)
+	}
+	second := commit("v2\n")
+	// A second job checks out beside the first, which still holds the
+	// mirror, and sees the new commit along with the old history.
+	sha, release2 := checkout("two")
+	if sha != second {
+		t.Errorf("second checkout at %s, want %s", sha, second)
+	}
+	if b, _ := os.ReadFile(filepath.Join(work, "two", "main.go")); string(b) != "v2\n" {
+		t.Errorf("main.go = %q", b)
+	}
+	if err := fetchCommit(ctx, filepath.Join(work, "two"), first, proxy.Setting{}); err != nil {
+		t.Errorf("history of the mirror not reachable: %v", err)
+	}
+
+	// Eviction keeps mirrors in use, however far over budget.
+	c.budget = 0
+	c.evict()
+	mirrors, _ := filepath.Glob(filepath.Join(c.dir, "*.git"))
+	if len(mirrors) != 1 {
+		t.Fatalf("mirrors = %v, want the one in use", mirrors)
+	}
+	release()
+	release2()
+	_ = os.RemoveAll(work)
+	c.evict()
+	if _, err := os.Stat(mirrors[0]); !os.IsNotExist(err) {
+		t.Errorf("released mirror not evicted: %v", err)
+	}
+
+	// An evicted mirror is fetched again on the next checkout.
+	work = t.TempDir()
+	if sha, release := checkout("three"); sha != second {
+		t.Errorf("checkout after eviction at %s, want %s", sha, second)
+	} else {
+		release()
+	}
+}
+
+func TestCloneCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := &cloneCache{dir: t.TempDir(), budget: 150}
+	for i, key := range []string{"old", "new"} {
+		if err := os.MkdirAll(filepath.Join(c.dir, key+".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(c.dir, key+".git", "pack"), make([]byte, 100), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(time.Duration(i-2) * time.Hour)
+		if err := os.WriteFile(filepath.Join(c.dir, key+".use"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(filepath.Join(c.dir, key+".use"), used, used)
+	}
+	c.evict()
+	if _, err := os.Stat(filepath.Join(c.dir, "old.git")); !os.IsNotExist(err) {
+		t.Error("least recently used mirror kept")
+	}
+	if _, err := os.Stat(filepath.Join(c.dir, "new.git")); err != nil {
+		t.Errorf("recently used mirror evicted: %v", err)
+	}
+}
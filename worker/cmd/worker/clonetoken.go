@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"argus/internal/forge"
+)
+
+// cloneToken returns the token repo is cloned with. With a GitHub App
+// configured, a repo on github.url gets an installation token for its
+// own installation, or else github.installation_id, so private repos across
+// several installations can be scanned without one broad token. A project
+// on gitlab.url gets gitlab.token and an Azure Repos repo
+// azure_devops.token; both hosts accept them under any user name.
+// Everything else uses git.token, which may be empty for public repos.
+func (wk *Worker) cloneToken(ctx context.Context, repo RepoRow) (string, error) {
+	if wk.cfg.GitLabToken != "" && forge.GitLab(wk.cfg.GitLabURL).Owns(repo.URL) {
+		return wk.cfg.GitLabToken, nil
+	}
+	if _, ok := forge.ParseAzureRepo(repo.URL); ok && wk.cfg.AzureDevOpsToken != "" {
+		return wk.cfg.AzureDevOpsToken, nil
+	}
+	id := wk.installationID(repo)
+	if id == 0 {
+		return wk.cfg.GitToken, nil
+	}
+	if wk.github == nil {
+		if repo.InstallationID != 0 {
+			slog.WarnContext(ctx, "repo has a GitHub App installation but no app is configured; using git.token", "installation_id", id)
+		}
+		return wk.cfg.GitToken, nil
+	}
+	return wk.github.InstallationToken(ctx, id)
+}
+
+// installationID is the GitHub App installation repo's tokens are minted
+// for: its own, or else github.installation_id. It is 0 for repos not on
+// github.url.
+func (wk *Worker) installationID(repo RepoRow) int64 {
+	if !forge.GitHub(wk.cfg.GitHubURL).Owns(repo.URL) {
+		return 0
+	}
+	if repo.InstallationID != 0 {
+		return repo.InstallationID
+	}
+	return wk.cfg.GitHubInstallationID
+}
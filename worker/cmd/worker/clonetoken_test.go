@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"argus/worker/internal/config"
+)
+
+func TestCloneTokenFallsBackToGitToken(t *testing.T) {
+	wk := &Worker{cfg: config.Config{GitToken: "ghp_static"}}
+	for _, repo := range []RepoRow{
+		{URL: "https://github.com/acme/api.git"},
+		{URL: "https://github.com/acme/api.git", InstallationID: 42}, // no app configured
+		{URL: "https://git.example.com/acme/api.git", InstallationID: 42},
+	} {
+		token, err := wk.cloneToken(context.Background(), repo)
+		if err != nil || token != "ghp_static" {
+			t.Errorf("cloneToken(%+v) = %q, %v; want git.token", repo, token, err)
+		}
+	}
+}
+
+func TestCloneTokenForOtherHosts(t *testing.T) {
+	wk := &Worker{cfg: config.Config{GitToken: "ghp_static", GitLabURL: "https://gitlab.example.com", GitLabToken: "glpat-token", AzureDevOpsToken: "ado-pat"}}
+	for url, want := range map[string]string{
+		"https://gitlab.example.com/acme/platform/api.git": "glpat-token",
+		"https://dev.azure.com/acme/Platform/_git/api":     "ado-pat",
+		"https://gitlab.com/acme/api.git":                  "ghp_static",
+		"https://github.com/acme/api.git":                  "ghp_static",
+	} {
+		if token, err := wk.cloneToken(context.Background(), RepoRow{URL: url}); err != nil || token != want {
+			t.Errorf("cloneToken(%s) = %q, %v; want %q", url, token, err, want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// With github.code_scanning on, a scan of a GitHub repo uploads its findings
+// to the repo's code scanning as a SARIF 2.1.0 log, so they show up as
+// alerts in GitHub's Security tab. Each tool is its own run and category:
+// code scanning closes the alerts of a tool that no longer reports them, so
+// only tools whose scanners all ran cleanly are uploaded.
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// maxSARIFResults is how many results code scanning accepts in one run.
+const maxSARIFResults = 25000
+
+// securitySeverities are the scores GitHub maps back onto alert severities.
+var securitySeverities = map[string]float64{"CRITICAL": 9.5, "HIGH": 8, "MEDIUM": 5.5, "LOW": 2}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver sarifDriver `json:"driver"`
+	} `json:"tool"`
+	AutomationDetails struct {
+		ID string `json:"id"`
+	} `json:"automationDetails"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	Properties       struct {
+		Tags             []string `json:"tags"`
+		SecuritySeverity string   `json:"security-severity,omitempty"`
+	} `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// uploadCodeScanning uploads the findings of the tools a scan ran cleanly.
+// Scans of part of the repo are not uploaded, as code scanning would close
+// the alerts of the files they did not see. Nothing here fails the job;
+// problems are logged.
+func (wk *Worker) uploadCodeScanning(ctx context.Context, spec jobSpec, repo RepoRow, job *scanJob, tools []string) {
+	if !wk.cfg.GitHubCodeScanning || wk.github == nil || job.commit == "" || job.changedDir != "" {
+		return
+	}
+	ref := codeScanningRef(spec, repo)
+	id := wk.installationID(repo)
+	owner, name, ok := parseGitHubRepo(repo.URL)
+	if ref == "" || id == 0 || !ok {
+		return
+	}
+	data, err := json.Marshal(buildSARIF(tools, job.findings))
+	if err != nil {
+		slog.WarnContext(ctx, "code scanning not uploaded: sarif", "err", err)
+		return
+	}
+	token, err := wk.github.InstallationToken(ctx, id)
+	if err != nil {
+		slog.WarnContext(ctx, "code scanning not uploaded: installation token", "installation_id", id, "err", err)
+		return
+	}
+	if !strings.HasPrefix(ref, "refs/") {
+		full, err := wk.github.QualifyRef(ctx, token, owner, name, ref)
+		if err != nil || full == "" {
+			slog.WarnContext(ctx, "code scanning not uploaded: no branch or tag", "ref", ref, "err", err)
+			return
+		}
+		ref = full
+	}
+	uploadID, err := wk.github.UploadSARIF(ctx, token, owner, name, job.commit, ref, data)
+	if err != nil {
+		slog.WarnContext(ctx, "code scanning not uploaded", "ref", ref, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "code scanning uploaded", "ref", ref, "sarif_id", uploadID, "tools", len(tools))
+}
+
+// codeScanningRef is the ref code scanning files a scan under: the pull
+// request's head for pull request scans, else the ref scanned. That is a
+// bare branch or tag name unless the job gave a full ref, and
+// uploadCodeScanning asks GitHub which it is. It is empty when the ref is
+// not known.
+func codeScanningRef(spec jobSpec, repo RepoRow) string {
+	if spec.PullRequest != 0 {
+		return fmt.Sprintf("refs/pull/%d/head", spec.PullRequest)
+	}
+	return cmp.Or(spec.Ref, repo.DefaultRef)
+}
+
+// buildSARIF builds a log with a run for each of tools. Code scanning needs
+// a location, so findings without a file are left out.
+func buildSARIF(tools []string, findings []finding) sarifLog {
+	log := sarifLog{Schema: sarifSchema, Version: "2.1.0"}
+	for _, tool := range tools {
+		// syft inventories packages and reports no findings.
+		if tool == "syft" {
+			continue
+		}
+		run := sarifRun{Results: []sarifResult{}}
+		run.Tool.Driver = sarifDriver{Name: tool, Rules: []sarifRule{}}
+		run.AutomationDetails.ID = "argus/" + tool + "/"
+		rules, scores := map[string]int{}, map[string]float64{}
+		for _, f := range findings {
+			if f.Tool != tool || f.FilePath == nil || *f.FilePath == "" || len(run.Results) == maxSARIFResults {
+				continue
+			}
+			id := sarifRuleID(f)
+			i, ok := rules[id]
+			if !ok {
+				i = len(run.Tool.Driver.Rules)
+				rules[id] = i
+				rule := sarifRule{ID: id, ShortDescription: sarifMessage{f.Title}}
+				rule.Properties.Tags = []string{"security"}
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+			}
+			if score := securitySeverities[f.Severity]; score > scores[id] {
+				scores[id] = score
+				run.Tool.Driver.Rules[i].Properties.SecuritySeverity = strconv.FormatFloat(score, 'f', 1, 64)
+			}
+			run.Results = append(run.Results, sarifFinding(id, f))
+		}
+		log.Runs = append(log.Runs, run)
+	}
+	return log
+}
+
+func sarifFinding(ruleID string, f finding) sarifResult {
+	res := sarifResult{RuleID: ruleID, Level: sarifLevel(f.Severity), Message: sarifMessage{f.Title}}
+	if f.Description != nil && *f.Description != "" {
+		res.Message.Text += "\n\n" + *f.Description
+	}
+	var loc sarifLocation
+	loc.PhysicalLocation.ArtifactLocation.URI = strings.TrimPrefix(*f.FilePath, "./")
+	if f.LineStart != nil && *f.LineStart > 0 {
+		region := &sarifRegion{StartLine: *f.LineStart}
+		if f.LineEnd != nil && *f.LineEnd > *f.LineStart {
+			region.EndLine = *f.LineEnd
+		}
+		loc.PhysicalLocation.Region = region
+	}
+	res.Locations = []sarifLocation{loc}
+	if f.Fingerprint != nil && *f.Fingerprint != "" {
+		res.PartialFingerprints = map[string]string{"argusFingerprint/v1": *f.Fingerprint}
+	}
+	return res
+}
+
+// sarifRuleID is the scanner's own rule or advisory ID from the evidence,
+// falling back to the title.
+func sarifRuleID(f finding) string {
+	if ev, ok := f.Evidence.(map[string]any); ok {
+		for _, key := range []string{"rule_id", "check_id", "id"} {
+			if id, ok := ev[key].(string); ok && id != "" {
+				return id
+			}
+		}
+	}
+	return f.Title
+}
+
+func sarifLevel(sev string) string {
+	switch sev {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	}
+	return "note"
+}
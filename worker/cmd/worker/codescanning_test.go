@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestBuildSARIF(t *testing.T) {
+	path, other, line, end := "./app/main.go", "app/db.go", 12, 14
+	desc, fp := "Hard-coded credential.", "abc"
+	findings := []finding{
+		{Tool: "semgrep", Severity: "MEDIUM", Title: "SQL built from input", FilePath: &other, LineStart: &line, Evidence: map[string]any{"check_id": "go.sqli"}},
+		{Tool: "semgrep", Severity: "HIGH", Title: "SQL built from input", FilePath: &path, LineStart: &line, LineEnd: &end, Description: &desc,
+			Fingerprint: &fp, Evidence: map[string]any{"check_id": "go.sqli"}},
+		{Tool: "nuclei", Severity: "HIGH", Title: "Exposed admin panel"},
+		{Tool: "trivy", Severity: "LOW", Title: "CVE-2024-0001 in lodash", FilePath: &other},
+	}
+	log := buildSARIF([]string{"nuclei", "semgrep", "syft"}, findings)
+	if log.Version != "2.1.0" || len(log.Runs) != 2 {
+		t.Fatalf("log = %+v", log)
+	}
+	if run := log.Runs[0]; run.Tool.Driver.Name != "nuclei" || len(run.Results) != 0 || run.AutomationDetails.ID != "argus/nuclei/" {
+		t.Errorf("nuclei run = %+v", run)
+	}
+	run := log.Runs[1]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "go.sqli" || run.Tool.Driver.Rules[0].Properties.SecuritySeverity != "8.0" {
+		t.Errorf("semgrep rules = %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("semgrep results = %+v", run.Results)
+	}
+	res := run.Results[1]
+	loc := res.Locations[0].PhysicalLocation
+	if res.Level != "error" || res.Message.Text != "SQL built from input\n\nHard-coded credential." || res.PartialFingerprints["argusFingerprint/v1"] != "abc" ||
+		loc.ArtifactLocation.URI != "app/main.go" || *loc.Region != (sarifRegion{StartLine: 12, EndLine: 14}) {
+		t.Errorf("result = %+v", res)
+	}
+	if res := run.Results[0]; res.Level != "warning" || res.PartialFingerprints != nil {
+		t.Errorf("result without a fingerprint = %+v", res)
+	}
+}
+
+func TestCodeScanningRef(t *testing.T) {
+	repo := RepoRow{DefaultRef: "main"}
+	for _, tc := range []struct {
+		spec jobSpec
+		repo RepoRow
+		want string
+	}{
+		{jobSpec{}, repo, "main"},
+		{jobSpec{Ref: "v1.2.0"}, repo, "v1.2.0"},
+		{jobSpec{Ref: "refs/tags/v1"}, repo, "refs/tags/v1"},
+		{jobSpec{Ref: "feature", PullRequest: 7}, repo, "refs/pull/7/head"},
+		{jobSpec{}, RepoRow{}, ""},
+	} {
+		if got := codeScanningRef(tc.spec, tc.repo); got != tc.want {
+			t.Errorf("codeScanningRef(%+v, %+v) = %q, want %q", tc.spec, tc.repo, got, tc.want)
+		}
+	}
+}
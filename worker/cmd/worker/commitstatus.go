@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"argus/worker/internal/githubapp"
+)
+
+// commitStatusContext names Argus's commit statuses; branch protection
+// requires it by this name.
+const commitStatusContext = "argus/scan"
+
+// commitStatusTimeout bounds posting a status, which also happens while a
+// failing job is being torn down.
+const commitStatusTimeout = 15 * time.Second
+
+// postCommitStatus sets the status of the commit a scan is pinned to on
+// GitHub: pending when it starts, then its outcome, linking to the job.
+// Scans of a branch rather than a commit, and repos not on github.url, get
+// none. Nothing here fails the job; problems are logged.
+func (wk *Worker) postCommitStatus(ctx context.Context, jobID string, spec jobSpec, repo RepoRow, state, description string) {
+	if !wk.cfg.GitHubCommitStatuses || wk.github == nil || spec.CommitSHA == "" {
+		return
+	}
+	id := wk.installationID(repo)
+	owner, name, ok := parseGitHubRepo(repo.URL)
+	if id == 0 || !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitStatusTimeout)
+	defer cancel()
+	token, err := wk.github.InstallationToken(ctx, id)
+	if err != nil {
+		slog.WarnContext(ctx, "commit status not set: installation token", "installation_id", id, "err", err)
+		return
+	}
+	status := githubapp.CommitStatus{State: state, Description: description, Context: commitStatusContext}
+	if wk.cfg.PublicURL != "" {
+		status.TargetURL = strings.TrimSuffix(wk.cfg.PublicURL, "/") + "/api/jobs/" + jobID
+	}
+	if err := wk.github.CreateCommitStatus(ctx, token, owner, name, spec.CommitSHA, status); err != nil {
+		slog.WarnContext(ctx, "commit status not set", "state", state, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "commit status set", "state", state, "commit_sha", spec.CommitSHA)
+}
+
+// scanStatus is the commit status of a finished scan whose commit has the
+// added findings that the default branch lacks. Like the check run, added
+// CRITICAL or HIGH findings fail it, however many pushes ago they came in.
+func scanStatus(added []finding) (state, description string) {
+	blocking := 0
+	for _, f := range added {
+		if f.Severity == "CRITICAL" || f.Severity == "HIGH" {
+			blocking++
+		}
+	}
+	switch {
+	case blocking > 0:
+		return githubapp.StateFailure, fmt.Sprintf("%d findings not on the default branch, %d of them CRITICAL or HIGH", len(added), blocking)
+	case len(added) == 1:
+		return githubapp.StateSuccess, "1 finding not on the default branch, none CRITICAL or HIGH"
+	case len(added) > 0:
+		return githubapp.StateSuccess, fmt.Sprintf("%d findings not on the default branch, none CRITICAL or HIGH", len(added))
+	}
+	return githubapp.StateSuccess, "No findings beyond the default branch"
+}
+
+// reportCommitStatus sets the outcome of a finished scan as its commit's
+// status.
+func (wk *Worker) reportCommitStatus(ctx context.Context, jobID string, spec jobSpec, repo RepoRow) {
+	if !wk.cfg.GitHubCommitStatuses || wk.github == nil || spec.CommitSHA == "" {
+		return
+	}
+	added, err := wk.store.BranchFindings(ctx, jobID)
+	if err != nil {
+		// The job still succeeded; say so rather than leave it pending.
+		slog.WarnContext(ctx, "commit status without findings: branch findings", "err", err)
+		wk.postCommitStatus(ctx, jobID, spec, repo, githubapp.StateError, "Scan finished; its findings could not be read")
+		return
+	}
+	state, description := scanStatus(added)
+	wk.postCommitStatus(ctx, jobID, spec, repo, state, description)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestScanStatus(t *testing.T) {
+	for _, tc := range []struct {
+		added       []finding
+		state, desc string
+	}{
+		{nil, "success", "No findings beyond the default branch"},
+		{[]finding{{Severity: "LOW"}}, "success", "1 finding not on the default branch, none CRITICAL or HIGH"},
+		{[]finding{{Severity: "MEDIUM"}, {Severity: "LOW"}}, "success", "2 findings not on the default branch, none CRITICAL or HIGH"},
+		{[]finding{{Severity: "HIGH"}, {Severity: "LOW"}, {Severity: "CRITICAL"}}, "failure", "3 findings not on the default branch, 2 of them CRITICAL or HIGH"},
+	} {
+		if state, desc := scanStatus(tc.added); state != tc.state || desc != tc.desc {
+			t.Errorf("scanStatus(%v) = %q, %q, want %q, %q", tc.added, state, desc, tc.state, tc.desc)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"argus/worker/internal/severity"
+)
+
+// nuclei probes the preview deployment named on the scan request, so its
+// dynamic findings land on the same job as the static scan of the commit
+// the deployment was built from. It runs only for jobs with a deployment
+// URL on a host in dast.allowed_hosts.
+type nucleiResult struct {
+	TemplateID string `json:"template-id"`
+	Info       struct {
+		Name           string     `json:"name"`
+		Severity       string     `json:"severity"`
+		Description    string     `json:"description"`
+		Remediation    string     `json:"remediation"`
+		Reference      stringList `json:"reference"`
+		Classification struct {
+			CWEIDs      stringList `json:"cwe-id"`
+			CVSSMetrics string     `json:"cvss-metrics"`
+		} `json:"classification"`
+	} `json:"info"`
+	MatcherName string `json:"matcher-name"`
+	MatchedAt   string `json:"matched-at"`
+	Host        string `json:"host"`
+}
+
+// stringList accepts both a JSON string and a list of strings; nuclei
+// emits either depending on the template.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var one string
+	if json.Unmarshal(b, &one) == nil {
+		if one != "" {
+			*l = stringList{one}
+		}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// dastTarget reports whether the job has a deployment nuclei may probe.
+func (wk *Worker) dastTarget(ctx context.Context, job *scanJob) bool {
+	if job.deploymentURL == "" {
+		return false
+	}
+	u, err := url.Parse(job.deploymentURL)
+	if err != nil || !wk.cfg.DASTHostAllowed(u.Hostname()) {
+		slog.WarnContext(ctx, "deployment host is not in dast.allowed_hosts on this worker; skipping nuclei", "deployment_url", job.deploymentURL)
+		return false
+	}
+	return true
+}
+
+func (wk *Worker) runNuclei(ctx context.Context, job *scanJob) error {
+	// Templates that fuzz or stress the target are excluded: a preview
+	// environment is shared, and the scan must not take it down.
+	args := []string{"-u", job.deploymentURL, "-jsonl", "-silent", "-no-color", "-duc",
+		"-rl", strconv.Itoa(wk.cfg.NucleiRateLimit), "-etags", "dos,fuzz,intrusive"}
+	if wk.cfg.NucleiTemplates != "" {
+		args = append(args, "-t", wk.cfg.NucleiTemplates)
+	}
+	out, err := runCmdJSON(ctx, "nuclei", args, job.dir, wk.cfg.ScannerProxySetting().Environ())
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "nuclei.jsonl", "application/jsonl", out)
+	job.addNucleiResults(out)
+	return err
+}
+
+// addNucleiResults parses nuclei's JSON Lines output, skipping anything
+// else it printed.
+func (j *scanJob) addNucleiResults(out []byte) {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var res nucleiResult
+		if json.Unmarshal(line, &res) != nil || res.TemplateID == "" {
+			continue
+		}
+		j.addNucleiResult(res)
+	}
+}
+
+func (j *scanJob) addNucleiResult(res nucleiResult) {
+	sev := severity.Of("nuclei", res.Info.Severity, res.Info.Classification.CVSSMetrics)
+	// Preview deployments get a new host per change, so fingerprints use
+	// the location within the deployment to stay stable across them.
+	location := deploymentPath(j.deploymentURL, res.MatchedAt)
+	parts := map[string]string{"rule_id": res.TemplateID, "name": res.Info.Name, "matched_at": res.MatchedAt, "severity": sev}
+	title := j.titles.render(titleNuclei, parts)
+	cwes := make([]string, 0, len(res.Info.Classification.CWEIDs))
+	for _, c := range res.Info.Classification.CWEIDs {
+		cwes = append(cwes, strings.ToUpper(strings.TrimSpace(c)))
+	}
+	ref := ""
+	if len(res.Info.Reference) > 0 {
+		ref = res.Info.Reference[0]
+	}
+	desc := res.Info.Description
+	fpv := j.fp("nuclei", res.TemplateID, res.MatcherName, location)
+	j.add(finding{Tool: "nuclei", Severity: sev, label: res.Info.Severity, cvss: res.Info.Classification.CVSSMetrics, Title: title, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+		"matched_at":     res.MatchedAt,
+		"location":       location,
+		"matcher":        res.MatcherName,
+		"deployment_url": j.deploymentURL,
+		"remediation":    res.Info.Remediation,
+		"url":            ref,
+		"cwe":            cwes,
+		"title_parts":    parts,
+	}})
+}
+
+// deploymentPath strips the deployment's scheme and host from a matched
+// URL, leaving the path and query; anything else is returned unchanged.
+func deploymentPath(deploymentURL, matchedAt string) string {
+	d, err := url.Parse(deploymentURL)
+	if err != nil {
+		return matchedAt
+	}
+	m, err := url.Parse(matchedAt)
+	if err != nil || m.Host == "" || !strings.EqualFold(m.Host, d.Host) {
+		return matchedAt
+	}
+	loc := m.EscapedPath()
+	if loc == "" {
+		loc = "/"
+	}
+	if m.RawQuery != "" {
+		loc += "?" + m.RawQuery
+	}
+	return loc
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestAddNucleiResults(t *testing.T) {
+	out := []byte(`[INF] Using Nuclei Engine 3.3.0
+{"template-id":"missing-csp","info":{"name":"Missing Content-Security-Policy","severity":"info","reference":"https://developer.mozilla.org/docs/Web/HTTP/CSP","classification":{"cwe-id":["cwe-693"]}},"matcher-name":"csp","matched-at":"https://pr-12.preview.example.com/login?next=%2F","host":"pr-12.preview.example.com"}
+{"template-id":"git-config","info":{"name":"Git Config Disclosure","severity":"medium","reference":["https://example.com/a","https://example.com/b"],"classification":{"cvss-metrics":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N"}},"matched-at":"https://pr-12.preview.example.com/.git/config"}
+not json
+`)
+	job := &scanJob{deploymentURL: "https://pr-12.preview.example.com"}
+	job.addNucleiResults(out)
+	if len(job.findings) != 2 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	if f.Tool != "nuclei" || f.Severity != "LOW" || f.Title != "missing-csp: Missing Content-Security-Policy" {
+		t.Errorf("finding = %+v", f)
+	}
+	ev := f.Evidence.(map[string]any)
+	if ev["location"] != "/login?next=%2F" || ev["url"] != "https://developer.mozilla.org/docs/Web/HTTP/CSP" || ev["severity_original"] != "info" {
+		t.Errorf("evidence = %v", ev)
+	}
+	if cwes := ev["cwe"].([]string); len(cwes) != 1 || cwes[0] != "CWE-693" {
+		t.Errorf("cwe = %v", cwes)
+	}
+	// The template's CVSS vector outranks its own medium.
+	if f := job.findings[1]; f.Severity != "HIGH" || f.Evidence.(map[string]any)["cvss_score"] != 7.5 {
+		t.Errorf("finding = %+v", f)
+	}
+
+	// The same issue on another preview deployment keeps its fingerprint.
+	other := &scanJob{deploymentURL: "https://pr-13.preview.example.com"}
+	other.addNucleiResults([]byte(`{"template-id":"git-config","info":{"name":"Git Config Disclosure","severity":"medium"},"matched-at":"https://pr-13.preview.example.com/.git/config"}`))
+	if *other.findings[0].Fingerprint != *job.findings[1].Fingerprint {
+		t.Error("fingerprint depends on the deployment host")
+	}
+}
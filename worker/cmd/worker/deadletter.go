@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// Messages the worker gives up on are parked in the dead-letter list
+// (<queue>:dead in Redis) with the reason instead of being dropped. The API's admin endpoints list and replay them.
+const (
+	deadUnparseable = "unparseable"
+	deadMaxAttempts = "max_attempts"
+	// maxDeadLetters bounds the list; the oldest entries are trimmed.
+	maxDeadLetters = 10000
+)
+
+type deadLetter struct {
+	ID       string    `json:"id"`
+	Reason   string    `json:"reason"`
+	Error    string    `json:"error"`
+	Payload  string    `json:"payload"`
+	JobID    string    `json:"job_id,omitempty"`
+	Attempts int       `json:"attempts,omitempty"`
+	Worker   string    `json:"worker"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// deadLetter parks raw in the dead-letter list. The caller still acks it.
+func (wk *Worker) deadLetter(ctx context.Context, raw, reason string, msg JobMsg, cause error) {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	entry, err := json.Marshal(deadLetter{
+		ID:       hex.EncodeToString(id),
+		Reason:   reason,
+		Error:    cause.Error(),
+		Payload:  raw,
+		JobID:    msg.JobID,
+		Attempts: msg.Attempt + 1,
+		Worker:   wk.owner.name(),
+		FailedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "dead-letter encode failed", "err", err)
+		return
+	}
+	if err := wk.queue.Bury(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "dead-letter push failed; message dropped", "reason", reason, "payload", raw, "err", err)
+		return
+	}
+	slog.WarnContext(ctx, "message moved to dead-letter queue", "reason", reason, "err", cause)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// artifactDiagnostics is the bundle a failed job leaves behind: the commands
+// it ran, the worker's environment, stage timings and the disk and memory
+// left at the moment it failed. Users attach it to bug reports.
+const artifactDiagnostics = "diagnostics"
+
+const (
+	// maxCommandOutput keeps the tail of a failed command's output, where
+	// the error usually is.
+	maxCommandOutput       = 16 * 1024
+	diagnosticsSaveTimeout = 30 * time.Second
+)
+
+type commandRecord struct {
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	// Output is only kept for failed commands; scanner reports are saved as
+	// their own artifacts.
+	Output string `json:"output,omitempty"`
+}
+
+// commandLog collects the commands a job runs. runCmdJSON and the git
+// helpers record into the one on their context, if any.
+type commandLog struct {
+	mu   sync.Mutex
+	cmds []commandRecord
+}
+
+func withCommandLog(ctx context.Context, l *commandLog) context.Context {
+	return context.WithValue(ctx, commandLogKey, l)
+}
+
+// recordCommand records a finished command, and logs it with its output to
+// the job log.
+func recordCommand(ctx context.Context, name string, args []string, started time.Time, out []byte, err error) {
+	jl := jobLogFrom(ctx)
+	jl.command(name, args)
+	if len(out) > 0 {
+		jl.add(name, string(out))
+	}
+	jl.exit(name, started, err)
+	recordCommandResult(ctx, name, args, started, out, err)
+}
+
+// recordCommandResult only adds the command to the job's diagnostics.
+func recordCommandResult(ctx context.Context, name string, args []string, started time.Time, out []byte, err error) {
+	l, ok := ctx.Value(commandLogKey).(*commandLog)
+	if !ok {
+		return
+	}
+	rec := commandRecord{
+		Command:    strings.Join(append([]string{name}, args...), " "),
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		rec.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			rec.ExitCode = exitErr.ExitCode()
+		}
+		rec.Error = err.Error()
+		if len(out) > maxCommandOutput {
+			out = out[len(out)-maxCommandOutput:]
+		}
+		rec.Output = string(out)
+	}
+	l.mu.Lock()
+	l.cmds = append(l.cmds, rec)
+	l.mu.Unlock()
+}
+
+type diagnosticsBundle struct {
+	JobID        string           `json:"job_id"`
+	Attempt      int              `json:"attempt"`
+	Source       string           `json:"source"`
+	Error        string           `json:"error"`
+	FailedAt     time.Time        `json:"failed_at"`
+	ElapsedMS    int64            `json:"elapsed_ms"`
+	Stage        string           `json:"stage,omitempty"`
+	StageTimings map[string]int64 `json:"stage_timings,omitempty"`
+	Environment  map[string]any   `json:"environment"`
+	Resources    map[string]any   `json:"resources"`
+	Commands     []commandRecord  `json:"commands"`
+}
+
+// failJob marks the job failed and stores a diagnostics bundle for it. Both
+// use a fresh deadline so a job that failed by timing out still records why.
+func (wk *Worker) failJob(ctx context.Context, msg JobMsg, started time.Time, cmds *commandLog, prog *progress, reason string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticsSaveTimeout)
+	defer cancel()
+	if err := wk.store.FailJob(ctx, msg.JobID, reason); err != nil {
+		slog.ErrorContext(ctx, "mark job failed", "err", err)
+	}
+
+	b := diagnosticsBundle{
+		JobID:       msg.JobID,
+		Attempt:     msg.Attempt + 1,
+		Source:      msg.Source,
+		Error:       reason,
+		FailedAt:    time.Now().UTC(),
+		ElapsedMS:   time.Since(started).Milliseconds(),
+		Environment: wk.environmentSummary(),
+		Resources:   resourceSnapshot(wk.cfg.WorkspaceRoot),
+	}
+	if prog != nil {
+		b.Stage, b.StageTimings = prog.snapshot()
+	}
+	cmds.mu.Lock()
+	b.Commands = append([]commandRecord{}, cmds.cmds...)
+	cmds.mu.Unlock()
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		slog.WarnContext(ctx, "encode diagnostics failed", "err", err)
+		return
+	}
+	wk.saveArtifact(ctx, msg.JobID, artifactDiagnostics, "diagnostics.json", "application/json", []byte(wk.sanitize(string(data))))
+}
+
+var urlCredentials = regexp.MustCompile(`(://)[^/@\s"]+@`)
+
+// sanitize strips credentials from text bound for a diagnostics bundle: the
+// configured tokens and any user info embedded in URLs.
+func (wk *Worker) sanitize(s string) string {
+	for _, secret := range []string{wk.cfg.GitToken, wk.cfg.GitLabToken, wk.cfg.AzureDevOpsToken, wk.cfg.WorkerToken} {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "***")
+		}
+	}
+	return urlCredentials.ReplaceAllString(s, "${1}***@")
+}
+
+func (wk *Worker) environmentSummary() map[string]any {
+	host, _ := os.Hostname()
+	binaries := map[string]string{}
+	for _, bin := range requiredBinaries(wk.cfg.Scanners) {
+		if path, err := exec.LookPath(bin); err == nil {
+			binaries[bin] = path
+		} else {
+			binaries[bin] = "missing"
+		}
+	}
+	return map[string]any{
+		"worker":           wk.owner.name(),
+		"host":             host,
+		"go_version":       runtime.Version(),
+		"os":               runtime.GOOS,
+		"arch":             runtime.GOARCH,
+		"cpus":             runtime.NumCPU(),
+		"concurrency":      wk.cfg.Concurrency,
+		"scan_timeout_min": wk.cfg.ScanTimeoutMin,
+		"results_mode":     wk.cfg.ResultsMode,
+		"scanners":         wk.cfg.Scanners,
+		"binaries":         binaries,
+	}
+}
+
+// resourceSnapshot reports free disk under the workspace root and the
+// memory left to the host and, when running in a cgroup v2, the container.
+func resourceSnapshot(workspaceRoot string) map[string]any {
+	out := map[string]any{}
+	var fs syscall.Statfs_t
+	if syscall.Statfs(workspaceRoot, &fs) == nil {
+		out["disk_free_bytes"] = fs.Bavail * uint64(fs.Bsize)
+		out["disk_total_bytes"] = fs.Blocks * uint64(fs.Bsize)
+	}
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[0] {
+			case "MemTotal:":
+				out["memory_total_bytes"] = kb * 1024
+			case "MemAvailable:":
+				out["memory_available_bytes"] = kb * 1024
+			}
+		}
+		f.Close()
+	}
+	for key, file := range map[string]string{
+		"cgroup_memory_current": "/sys/fs/cgroup/memory.current",
+		"cgroup_memory_max":     "/sys/fs/cgroup/memory.max",
+	} {
+		if b, err := os.ReadFile(file); err == nil {
+			out[key] = strings.TrimSpace(string(b))
+		}
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	out["worker_heap_bytes"] = ms.HeapAlloc
+	return out
+}
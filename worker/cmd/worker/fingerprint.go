@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// fingerprint is the unsalted SHA-256 of a finding's identifying parts.
+func fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// saltFingerprint keys an unsalted fingerprint with the org's salt, so the
+// same finding in two orgs cannot be linked or predicted. It is an HMAC over
+// the unsalted value rather than the parts so the API can rekey stored
+// findings when an org turns salting on (pgcrypto's hmac() computes the same
+// value). An empty salt leaves the fingerprint unchanged.
+func saltFingerprint(salt, fp string) string {
+	if salt == "" {
+		return fp
+	}
+	m := hmac.New(sha256.New, []byte(salt))
+	m.Write([]byte(fp))
+	return hex.EncodeToString(m.Sum(nil))
+}
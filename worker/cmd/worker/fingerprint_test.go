@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestSaltFingerprint(t *testing.T) {
+	plain := fingerprint("gitleaks", "aws-key", ".env", "3")
+	if plain != "334143129646c9cce54106583f73223ed8da19d195b84a8a81086f83342d83c3" {
+		t.Fatalf("unsalted fingerprint changed: %s", plain)
+	}
+	if got := saltFingerprint("", plain); got != plain {
+		t.Fatalf("empty salt changed the fingerprint: %s", got)
+	}
+	// Must equal encode(hmac(fingerprint, salt, 'sha256'), 'hex') in
+	// Postgres, which the API uses to rekey stored findings.
+	if got := saltFingerprint("org-salt", plain); got != "cb89623de8258f2561931692f6b2b22ae7bb8f595793ac23de72102c56bad078" {
+		t.Fatalf("salted fingerprint = %s", got)
+	}
+	if saltFingerprint("other-salt", plain) == saltFingerprint("org-salt", plain) {
+		t.Fatal("different salts produced the same fingerprint")
+	}
+}
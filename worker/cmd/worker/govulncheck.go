@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// govulncheck reports the Go vulnerabilities a module is actually exposed
+// to: it follows the call graph from the module's code into its
+// dependencies, so a vulnerable function that is never called does not
+// rank like one that is. It runs once per go.mod in the clone.
+
+// maxCallStacks bounds the call stacks kept per finding.
+const maxCallStacks = 5
+
+// Reachability of a vulnerability, from govulncheck's scan levels.
+const (
+	reachCalled   = "called"   // a vulnerable symbol is reachable
+	reachImported = "imported" // a vulnerable package is imported
+	reachRequired = "required" // only the vulnerable module is required
+)
+
+var reachSeverity = map[string]string{reachCalled: "HIGH", reachImported: "MEDIUM", reachRequired: "LOW"}
+
+// govulnMessage is one message of govulncheck's -json stream.
+type govulnMessage struct {
+	OSV *struct {
+		ID      string   `json:"id"`
+		Summary string   `json:"summary"`
+		Details string   `json:"details"`
+		Aliases []string `json:"aliases"`
+	} `json:"osv"`
+	Finding *govulnFinding `json:"finding"`
+}
+
+type govulnFinding struct {
+	OSV          string        `json:"osv"`
+	FixedVersion string        `json:"fixed_version"`
+	Trace        []govulnFrame `json:"trace"`
+}
+
+// govulnFrame is one frame of a trace. The first frame is in the vulnerable
+// module, the last in the scanned module's own code.
+type govulnFrame struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Receiver string `json:"receiver"`
+	Position *struct {
+		Filename string `json:"filename"`
+		Line     int    `json:"line"`
+	} `json:"position"`
+}
+
+func (f govulnFrame) symbol() string {
+	switch {
+	case f.Function == "":
+		return f.Package
+	case f.Receiver != "":
+		return f.Package + "." + strings.TrimPrefix(f.Receiver, "*") + "." + f.Function
+	}
+	return f.Package + "." + f.Function
+}
+
+func (wk *Worker) runGovulncheck(ctx context.Context, job *scanJob) error {
+	env := append(wk.cfg.ScannerProxySetting().Environ(), "GOTOOLCHAIN=local")
+	args := []string{"-json"}
+	if wk.cfg.Offline {
+		// Modules must be vendored or already in the module cache.
+		env = append(env, "GOPROXY=off")
+		args = append(args, "-db", "file://"+wk.cfg.OfflineGovulncheckDB)
+	}
+	var errs []error
+	for i, mod := range findGoModules(job.dir) {
+		out, err := runCmdJSON(ctx, "govulncheck", append(args, "./..."), filepath.Join(job.dir, mod), env)
+		wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "govulncheck-"+strconv.Itoa(i)+".json", "application/json", out)
+		msgs, perr := parseGovulncheck(out)
+		if perr != nil {
+			if err == nil {
+				err = fmt.Errorf("govulncheck parse error in %s: %v", mod, perr)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		job.addGovulnFindings(job.dir, mod, msgs)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseGovulncheck decodes govulncheck's stream of JSON objects, skipping
+// any text printed before it.
+func parseGovulncheck(out []byte) ([]govulnMessage, error) {
+	i := bytes.IndexByte(out, '{')
+	if i < 0 {
+		return nil, errors.New("no JSON in output")
+	}
+	dec := json.NewDecoder(bytes.NewReader(out[i:]))
+	var msgs []govulnMessage
+	for {
+		var m govulnMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			return msgs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+}
+
+// addGovulnFindings reports one finding per vulnerability and module, at
+// the highest reachability govulncheck found, with up to maxCallStacks of
+// the call stacks that reach it.
+func (j *scanJob) addGovulnFindings(repoDir, mod string, msgs []govulnMessage) {
+	type entry struct {
+		osv, module, version, fixed, reach string
+		stacks                             [][]string
+		entry                              *govulnFrame
+	}
+	osvs := map[string]*govulnMessage{}
+	byKey := map[string]*entry{}
+	var keys []string
+	for i := range msgs {
+		m := &msgs[i]
+		if m.OSV != nil {
+			osvs[m.OSV.ID] = m
+		}
+		f := m.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+		vuln := f.Trace[0]
+		reach := reachRequired
+		switch {
+		case vuln.Function != "":
+			reach = reachCalled
+		case vuln.Package != "":
+			reach = reachImported
+		}
+		key := f.OSV + "\x00" + vuln.Module
+		e, ok := byKey[key]
+		if !ok {
+			e = &entry{osv: f.OSV, module: vuln.Module, version: vuln.Version, fixed: f.FixedVersion, reach: reach}
+			byKey[key] = e
+			keys = append(keys, key)
+		}
+		if severityRank(reachSeverity[reach]) > severityRank(reachSeverity[e.reach]) {
+			e.reach, e.stacks, e.entry = reach, nil, nil
+		}
+		if reach != e.reach || reach != reachCalled {
+			continue
+		}
+		if e.entry == nil {
+			last := f.Trace[len(f.Trace)-1]
+			e.entry = &last
+		}
+		if len(e.stacks) < maxCallStacks {
+			stack := make([]string, 0, len(f.Trace))
+			for k := len(f.Trace) - 1; k >= 0; k-- {
+				frame := f.Trace[k].symbol()
+				if p := f.Trace[k].Position; p != nil && p.Filename != "" {
+					frame += " (" + clonePath(repoDir, mod, p.Filename) + ":" + strconv.Itoa(p.Line) + ")"
+				}
+				stack = append(stack, frame)
+			}
+			e.stacks = append(e.stacks, stack)
+		}
+	}
+
+	for _, key := range keys {
+		e := byKey[key]
+		sev := reachSeverity[e.reach]
+		file := filepath.ToSlash(filepath.Join(mod, "go.mod"))
+		var line *int
+		if e.entry != nil && e.entry.Position != nil && e.entry.Position.Filename != "" {
+			file = clonePath(repoDir, mod, e.entry.Position.Filename)
+			l := e.entry.Position.Line
+			line = &l
+		}
+		var summary, desc string
+		var aliases []string
+		if m := osvs[e.osv]; m != nil {
+			summary, desc, aliases = m.OSV.Summary, m.OSV.Details, m.OSV.Aliases
+		}
+		parts := map[string]string{"id": e.osv, "module": e.module, "installed": e.version, "fixed": e.fixed, "reachability": e.reach, "path": file, "severity": sev}
+		title := j.titles.render(titleGovulncheck, parts)
+		if desc == "" {
+			desc = summary
+		}
+		if e.stacks == nil {
+			e.stacks = [][]string{}
+		}
+		fpv := j.fp("govulncheck", e.osv, e.module, mod)
+		j.add(finding{Tool: "govulncheck", Severity: sev, Title: title, FilePath: &file, LineStart: line, LineEnd: line, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"id":           e.osv,
+			"aliases":      aliases,
+			"summary":      summary,
+			"pkg":          e.module,
+			"installed":    e.version,
+			"fixed":        e.fixed,
+			"reachability": e.reach,
+			"call_stacks":  e.stacks,
+			"module_dir":   mod,
+			"url":          "https://pkg.go.dev/vuln/" + e.osv,
+			"title_parts":  parts,
+		}})
+	}
+}
+
+// clonePath makes a source position relative to the clone: govulncheck
+// reports absolute paths, or paths relative to the module it scanned.
+func clonePath(repoDir, mod, name string) string {
+	if filepath.IsAbs(name) {
+		if rel, err := filepath.Rel(repoDir, name); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+		return filepath.ToSlash(name)
+	}
+	return filepath.ToSlash(filepath.Join(mod, name))
+}
+
+// findGoModules returns the directories of the clone's go.mod files,
+// relative to dir and sorted.
+func findGoModules(dir string) []string {
+	var mods []string
+	seen := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && (skipDirs[d.Name()] || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles {
+			return filepath.SkipAll
+		}
+		if d.Name() == "go.mod" && d.Type().IsRegular() {
+			if rel, err := filepath.Rel(dir, filepath.Dir(path)); err == nil {
+				mods = append(mods, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	sort.Strings(mods)
+	return mods
+}
+
+// govulnDBModified returns when an offline copy of the Go vulnerability
+// database was last modified, from its index/db.json.
+func govulnDBModified(dir string) (time.Time, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "index", "db.json"))
+	if err != nil {
+		return time.Time{}, errors.New("vulnerability DB not found (<dir>/index/db.json)")
+	}
+	var meta struct {
+		Modified time.Time `json:"modified"`
+	}
+	if err := json.Unmarshal(b, &meta); err != nil || meta.Modified.IsZero() {
+		return time.Time{}, errors.New("index/db.json has no modified time")
+	}
+	return meta.Modified, nil
+}
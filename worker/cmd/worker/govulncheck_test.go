@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindGoModules(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"go.mod", "tools/go.mod", "vendor/x/go.mod", "pkg/testdata/mod/go.mod", "web/package.json"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("module x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := findGoModules(dir); !reflect.DeepEqual(got, []string{".", "tools"}) {
+		t.Fatalf("modules = %v", got)
+	}
+}
+
+func TestAddGovulnFindings(t *testing.T) {
+	out := []byte(`Scanning your code and 46 packages across 3 dependent modules for known vulnerabilities...
+{"config":{"protocol_version":"v1.0.0","scanner_name":"govulncheck"}}
+{"osv":{"id":"GO-2024-2687","summary":"HTTP/2 CONTINUATION flood in net/http","aliases":["CVE-2023-45288"]}}
+{"osv":{"id":"GO-2023-1988","summary":"Improper rendering of text nodes in golang.org/x/net/html"}}
+{"finding":{"osv":"GO-2024-2687","fixed_version":"v0.23.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0"}]}}
+{"finding":{"osv":"GO-2024-2687","fixed_version":"v0.23.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0","package":"golang.org/x/net/http2"}]}}
+{"finding":{"osv":"GO-2024-2687","fixed_version":"v0.23.0","trace":[
+  {"module":"golang.org/x/net","version":"v0.17.0","package":"golang.org/x/net/http2","function":"processHeaders","receiver":"*serverConn","position":{"filename":"/go/pkg/mod/golang.org/x/net@v0.17.0/http2/server.go","line":2210}},
+  {"module":"example.com/app","package":"example.com/app/server","function":"Serve","position":{"filename":"server/server.go","line":41}}]}}
+{"finding":{"osv":"GO-2023-1988","fixed_version":"v0.13.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0","package":"golang.org/x/net/html"}]}}
+`)
+	msgs, err := parseGovulncheck(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addGovulnFindings("/work/repo", "api", msgs)
+	if len(job.findings) != 2 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+
+	f := job.findings[0]
+	if f.Tool != "govulncheck" || f.Severity != "HIGH" || f.Title != "GO-2024-2687 in golang.org/x/net (called)" || *f.FilePath != "api/server/server.go" || *f.LineStart != 41 {
+		t.Errorf("finding = %+v", f)
+	}
+	ev := f.Evidence.(map[string]any)
+	want := [][]string{{"example.com/app/server.Serve (api/server/server.go:41)", "golang.org/x/net/http2.serverConn.processHeaders (/go/pkg/mod/golang.org/x/net@v0.17.0/http2/server.go:2210)"}}
+	if ev["reachability"] != reachCalled || ev["fixed"] != "v0.23.0" || ev["pkg"] != "golang.org/x/net" || !reflect.DeepEqual(ev["call_stacks"], want) {
+		t.Errorf("evidence = %v", ev)
+	}
+
+	f = job.findings[1]
+	if f.Severity != "MEDIUM" || *f.FilePath != "api/go.mod" || f.LineStart != nil {
+		t.Errorf("finding = %+v", f)
+	}
+	if ev := f.Evidence.(map[string]any); ev["reachability"] != reachImported || len(ev["call_stacks"].([][]string)) != 0 {
+		t.Errorf("evidence = %v", ev)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"argus/worker/internal/severity"
+)
+
+// grype is an alternative dependency vulnerability engine for deployments
+// standardized on Anchore tooling. It is not enabled by default; list it in
+// scanners.enabled, usually in place of trivy.
+type grypeOut struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID          string    `json:"id"`
+			DataSource  string    `json:"dataSource"`
+			Severity    string    `json:"severity"`
+			Description string    `json:"description"`
+			URLs        []string  `json:"urls"`
+			CVSS        grypeCVSS `json:"cvss"`
+			Fix         struct {
+				Versions []string `json:"versions"`
+				State    string   `json:"state"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		RelatedVulnerabilities []struct {
+			ID          string    `json:"id"`
+			Description string    `json:"description"`
+			CVSS        grypeCVSS `json:"cvss"`
+		} `json:"relatedVulnerabilities"`
+		Artifact struct {
+			Name      string `json:"name"`
+			Version   string `json:"version"`
+			Type      string `json:"type"`
+			PURL      string `json:"purl"`
+			Locations []struct {
+				Path string `json:"path"`
+			} `json:"locations"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+// grypeCVSS are the CVSS entries grype has for a vulnerability, one per
+// source and version.
+type grypeCVSS []struct {
+	Vector string `json:"vector"`
+}
+
+// v3 returns the first CVSS v3 vector.
+func (c grypeCVSS) v3() string {
+	for _, e := range c {
+		if strings.HasPrefix(e.Vector, "CVSS:3") {
+			return e.Vector
+		}
+	}
+	return ""
+}
+
+func (wk *Worker) runGrype(ctx context.Context, job *scanJob) error {
+	env := append(wk.cfg.ScannerProxySetting().Environ(), "GRYPE_CHECK_FOR_APP_UPDATE=false")
+	if wk.cfg.Offline {
+		env = append(env, offlineGrypeEnv(wk.cfg.OfflineGrypeDB)...)
+	}
+	out, err := runCmdJSON(ctx, "grype", []string{"dir:.", "--output", "json", "--quiet"}, job.dir, env)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "grype.json", "application/json", out)
+	var parsed grypeOut
+	if perr := json.Unmarshal(out, &parsed); perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("grype parse error: %v", perr)
+	}
+	job.addGrypeMatches(parsed)
+	return err
+}
+
+func (j *scanJob) addGrypeMatches(parsed grypeOut) {
+	for _, m := range parsed.Matches {
+		v, a := m.Vulnerability, m.Artifact
+		// A GHSA match often carries its CVSS only on the related CVE.
+		vector := v.CVSS.v3()
+		for _, rv := range m.RelatedVulnerabilities {
+			if vector != "" {
+				break
+			}
+			vector = rv.CVSS.v3()
+		}
+		sev := severity.Of("grype", v.Severity, vector)
+		// grype reports locations from the scanned root, with a leading slash.
+		target := ""
+		if len(a.Locations) > 0 {
+			target = strings.TrimPrefix(filepath.ToSlash(a.Locations[0].Path), "/")
+		}
+		fixed := strings.Join(v.Fix.Versions, ", ")
+		parts := map[string]string{"id": v.ID, "package": a.Name, "installed": a.Version, "fixed": fixed, "type": a.Type, "target": target, "severity": sev}
+		title := j.titles.render(titleGrype, parts)
+		desc := v.Description
+		for _, rv := range m.RelatedVulnerabilities {
+			if desc != "" {
+				break
+			}
+			desc = rv.Description
+		}
+		url := v.DataSource
+		if url == "" && len(v.URLs) > 0 {
+			url = v.URLs[0]
+		}
+		fpv := j.fp("grype:vuln", v.ID, a.Name, a.Version, target)
+		f := finding{Tool: "grype", Severity: sev, label: v.Severity, cvss: vector, Title: title, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"pkg":         a.Name,
+			"installed":   a.Version,
+			"fixed":       fixed,
+			"fix_state":   v.Fix.State,
+			"url":         url,
+			"type":        a.Type,
+			"purl":        a.PURL,
+			"title_parts": parts,
+		}}
+		if target != "" {
+			f.FilePath = &target
+		}
+		j.add(f)
+	}
+}
+
+// offlineGrypeEnv keeps grype off the network: it reads the vulnerability DB
+// from the pre-seeded cache and never updates it. The bundle's age is
+// checked at startup instead of by grype.
+func offlineGrypeEnv(dbDir string) []string {
+	return []string{"GRYPE_DB_CACHE_DIR=" + dbDir, "GRYPE_DB_AUTO_UPDATE=false", "GRYPE_DB_VALIDATE_AGE=false"}
+}
+
+// grypeDBBuiltAt returns when the newest DB under cacheDir was built: the
+// "built" time grype records in <schema>/metadata.json, or else the DB
+// file's modification time.
+func grypeDBBuiltAt(cacheDir string) (time.Time, error) {
+	dbs, _ := filepath.Glob(filepath.Join(cacheDir, "*", "vulnerability.db"))
+	var newest time.Time
+	for _, db := range dbs {
+		built := time.Time{}
+		if b, err := os.ReadFile(filepath.Join(filepath.Dir(db), "metadata.json")); err == nil {
+			var meta struct {
+				Built time.Time `json:"built"`
+			}
+			if json.Unmarshal(b, &meta) == nil {
+				built = meta.Built
+			}
+		}
+		if built.IsZero() {
+			info, err := os.Stat(db)
+			if err != nil {
+				continue
+			}
+			built = info.ModTime()
+		}
+		if built.After(newest) {
+			newest = built
+		}
+	}
+	if newest.IsZero() {
+		return time.Time{}, errors.New("grype DB not found (<dir>/<schema>/vulnerability.db)")
+	}
+	return newest, nil
+}
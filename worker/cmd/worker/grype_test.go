@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddGrypeMatches(t *testing.T) {
+	var parsed grypeOut
+	err := json.Unmarshal([]byte(`{"matches":[{
+		"vulnerability":{"id":"GHSA-p6mc-m468-83gw","dataSource":"https://github.com/advisories/GHSA-p6mc-m468-83gw","severity":"High","fix":{"versions":["4.17.19"],"state":"fixed"}},
+		"relatedVulnerabilities":[{"id":"CVE-2020-8203","description":"Prototype pollution in lodash.","cvss":[{"version":"3.1","vector":"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:H/A:H"}]}],
+		"artifact":{"name":"lodash","version":"4.17.15","type":"npm","purl":"pkg:npm/lodash@4.17.15","locations":[{"path":"/web/package-lock.json"}]}}]}`), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addGrypeMatches(parsed)
+	if len(job.findings) != 1 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	if f.Tool != "grype" || f.Severity != "HIGH" || f.Title != "GHSA-p6mc-m468-83gw in lodash" {
+		t.Errorf("finding = %+v", f)
+	}
+	if f.FilePath == nil || *f.FilePath != "web/package-lock.json" {
+		t.Errorf("file path = %v", f.FilePath)
+	}
+	if f.Description == nil || *f.Description != "Prototype pollution in lodash." {
+		t.Errorf("description = %v", f.Description)
+	}
+	if ev := f.Evidence.(map[string]any); ev["severity_original"] != "High" || ev["cvss_score"] != 7.4 {
+		t.Errorf("evidence = %v", ev)
+	}
+}
+
+func TestGrypeDBBuiltAt(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := grypeDBBuiltAt(dir); err == nil {
+		t.Fatal("empty cache dir accepted")
+	}
+	schema := filepath.Join(dir, "5")
+	if err := os.MkdirAll(schema, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(schema, "vulnerability.db"), []byte("db"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(schema, "metadata.json"), []byte(`{"built":"2024-05-01T00:00:00Z","version":5}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := grypeDBBuiltAt(dir)
+	if want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("grypeDBBuiltAt = %v, %v; want %v", got, err, want)
+	}
+}
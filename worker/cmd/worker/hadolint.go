@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"argus/worker/internal/severity"
+)
+
+// hadolint lints the clone's Dockerfiles for container hygiene issues:
+// unpinned package and base image versions, running as root, package
+// caches left in layers. It only runs when the clone has Dockerfiles.
+
+// maxDockerfiles bounds one hadolint run; monorepos with more are linted
+// in part rather than not at all.
+const maxDockerfiles = 200
+
+type hadolintOut []struct {
+	Code    string `json:"code"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func (wk *Worker) runHadolint(ctx context.Context, job *scanJob) error {
+	files := findDockerfiles(job.dir)
+	if len(files) == 0 {
+		return nil
+	}
+	out, err := runCmdJSON(ctx, "hadolint", append([]string{"--format", "json", "--no-fail", "--no-color"}, files...), job.dir, nil)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "hadolint.json", "application/json", out)
+	var parsed hadolintOut
+	if perr := json.Unmarshal(out, &parsed); perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("hadolint parse error: %v", perr)
+	}
+	job.addHadolintResults(parsed)
+	return err
+}
+
+func (j *scanJob) addHadolintResults(parsed hadolintOut) {
+	for _, r := range parsed {
+		sev := severity.Normalize("hadolint", r.Level)
+		file := filepath.ToSlash(r.File)
+		parts := map[string]string{"rule_id": r.Code, "message": r.Message, "path": file, "line": strconv.Itoa(r.Line), "severity": sev}
+		title := j.titles.render(titleHadolint, parts)
+		desc := r.Message
+		fpv := j.fp("hadolint", r.Code, file, strconv.Itoa(r.Line))
+		ls, le := r.Line, r.Line
+		j.add(finding{Tool: "hadolint", Severity: sev, label: r.Level, Title: title, FilePath: &file, LineStart: &ls, LineEnd: &le, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"rule_id":     r.Code,
+			"level":       r.Level,
+			"url":         hadolintRuleURL(r.Code),
+			"title_parts": parts,
+		}})
+	}
+}
+
+// hadolintRuleURL documents a rule: DL rules are hadolint's own, SC rules
+// come from the ShellCheck pass over RUN instructions.
+func hadolintRuleURL(code string) string {
+	switch {
+	case strings.HasPrefix(code, "DL"):
+		return "https://github.com/hadolint/hadolint/wiki/" + code
+	case strings.HasPrefix(code, "SC"):
+		return "https://www.shellcheck.net/wiki/" + code
+	}
+	return ""
+}
+
+// findDockerfiles returns the Dockerfiles under dir, relative to it and
+// sorted, skipping the same directories as language detection.
+func findDockerfiles(dir string) []string {
+	var files []string
+	seen := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles || len(files) >= maxDockerfiles {
+			return filepath.SkipAll
+		}
+		if d.Type().IsRegular() && isDockerfile(d.Name()) {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				files = append(files, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
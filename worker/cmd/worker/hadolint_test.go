@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindDockerfiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"Dockerfile", "build/Dockerfile.ci", "web/app.dockerfile", "Containerfile", "node_modules/x/Dockerfile", "docs/Dockerfile.md.txt/x", "main.go"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("FROM scratch\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"Containerfile", "Dockerfile", "build/Dockerfile.ci", "web/app.dockerfile"}
+	if got := findDockerfiles(dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("findDockerfiles = %v, want %v", got, want)
+	}
+}
+
+func TestAddHadolintResults(t *testing.T) {
+	var parsed hadolintOut
+	err := json.Unmarshal([]byte(`[{"code":"DL3008","column":1,"file":"build/Dockerfile.ci","level":"warning","line":4,"message":"Pin versions in apt get install."},
+		{"code":"SC2086","column":1,"file":"Dockerfile","level":"info","line":7,"message":"Double quote to prevent globbing and word splitting."}]`), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addHadolintResults(parsed)
+	if len(job.findings) != 2 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	if f.Tool != "hadolint" || f.Severity != "MEDIUM" || f.Title != "DL3008: Pin versions in apt get install." || *f.FilePath != "build/Dockerfile.ci" {
+		t.Errorf("finding = %+v", f)
+	}
+	if ev := job.findings[1].Evidence.(map[string]any); ev["url"] != "https://www.shellcheck.net/wiki/SC2086" || job.findings[1].Severity != "LOW" {
+		t.Errorf("finding = %+v", job.findings[1])
+	}
+}
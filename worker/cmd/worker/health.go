@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type componentHealth struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// serveHealth exposes GET /healthz for readiness probes and GET /connectivity
+// for proxy self-tests. The worker has no other HTTP surface, so this runs on
+// its own listener (health.listen).
+func (wk *Worker) serveHealth(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", wk.healthz)
+	mux.HandleFunc("/connectivity", wk.connectivity)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	slog.Info("worker health listening", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("health server stopped", "err", err)
+	}
+}
+
+// healthz checks Postgres (or the API, when results go through it), the queue
+// backend and that git plus every binary the enabled scanners run is on PATH.
+// Any failure returns 503 so the pod is not marked ready.
+func (wk *Worker) healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	results := "database"
+	if wk.cfg.ResultsMode == resultsAPI {
+		results = "api"
+	}
+	components := map[string]componentHealth{
+		results: timedCheck(func() error { return wk.store.Ping(ctx) }),
+	}
+	// A Postgres queue in db mode shares the database checked above.
+	if wk.queue.Name() != backendPostgres || results != "database" {
+		components[wk.queue.Name()] = timedCheck(func() error { return wk.queue.Ping(ctx) })
+	}
+	for _, bin := range requiredBinaries(wk.cfg.Scanners) {
+		c := componentHealth{Status: "ok"}
+		if path, err := exec.LookPath(bin); err != nil {
+			c.Status, c.Error = "fail", err.Error()
+		} else {
+			c.Path = path
+		}
+		components["bin:"+bin] = c
+	}
+
+	status, code := "ok", http.StatusOK
+	for _, c := range components {
+		if c.Status != "ok" {
+			status, code = "fail", http.StatusServiceUnavailable
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": code == http.StatusOK, "status": status, "components": components})
+}
+
+// scannerBinaries lists the executables a scanner runs where they are not
+// just its own name: terraform is scanned by trivy, npm-audit runs the
+// package manager of each lockfile, kube-linter renders charts with helm and
+// govulncheck loads packages through the go toolchain.
+var scannerBinaries = map[string][]string{
+	"terraform":   {"trivy"},
+	"npm-audit":   {"npm", "pnpm", "yarn"},
+	"kube-linter": {"kube-linter", "helm"},
+	"govulncheck": {"govulncheck", "go"},
+}
+
+// requiredBinaries returns git and the executables the scanners run, each
+// once, in order.
+func requiredBinaries(scanners []string) []string {
+	out := []string{"git"}
+	seen := map[string]bool{"git": true}
+	for _, s := range scanners {
+		bins, ok := scannerBinaries[s]
+		if !ok {
+			bins = []string{s}
+		}
+		for _, bin := range bins {
+			if !seen[bin] {
+				seen[bin] = true
+				out = append(out, bin)
+			}
+		}
+	}
+	return out
+}
+
+func timedCheck(check func() error) componentHealth {
+	start := time.Now()
+	err := check()
+	c := componentHealth{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		c.Status, c.Error = "fail", err.Error()
+	}
+	return c
+}
+
+const connectivityTimeout = 10 * time.Second
+
+type connectivityCheck struct {
+	Destination string `json:"destination"`
+	Target      string `json:"target"`
+	Proxy       string `json:"proxy"`
+	Status      string `json:"status"` // ok, fail or skipped
+	HTTPStatus  int    `json:"http_status,omitempty"`
+	LatencyMS   int64  `json:"latency_ms,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// connectivity probes each outbound destination through its configured
+// proxy: the GitHub API for clones, and the semgrep registry and trivy DB
+// registry for scanner downloads. It is not a readiness check, so it answers
+// 200 even when a probe fails; "ok" summarises.
+func (wk *Worker) connectivity(w http.ResponseWriter, r *http.Request) {
+	gh, sc := wk.cfg.GitHubProxySetting(), wk.cfg.ScannerProxySetting()
+	targets := []struct {
+		destination, target string
+		p                   proxy.Setting
+	}{
+		{"github", forge.GitHub(wk.cfg.GitHubURL).APIURL(), gh},
+		{"scanners", "https://semgrep.dev", sc},
+		{"scanners", "https://ghcr.io/v2/", sc},
+	}
+	checks := make([]connectivityCheck, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		if wk.cfg.Offline {
+			checks[i] = connectivityCheck{Destination: t.destination, Target: t.target, Proxy: t.p.Redacted(), Status: "skipped", Error: "offline mode"}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[i] = probe(r.Context(), t.destination, t.target, t.p)
+		}()
+	}
+	wg.Wait()
+
+	ok := true
+	for _, c := range checks {
+		ok = ok && c.Status != "fail"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": ok, "checks": checks})
+}
+
+// probe sends a HEAD request to target through p. Any HTTP response, whatever
+// its status, shows the destination is reachable.
+func probe(ctx context.Context, destination, target string, p proxy.Setting) connectivityCheck {
+	c := connectivityCheck{Destination: destination, Target: target, Proxy: p.Redacted()}
+	ctx, cancel := context.WithTimeout(ctx, connectivityTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		c.Status, c.Error = "fail", err.Error()
+		return c
+	}
+	start := time.Now()
+	resp, err := (&http.Client{Transport: p.Transport()}).Do(req)
+	c.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		c.Status, c.Error = "fail", err.Error()
+		return c
+	}
+	resp.Body.Close()
+	c.Status, c.HTTPStatus = "ok", resp.StatusCode
+	return c
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequiredBinaries(t *testing.T) {
+	got := requiredBinaries([]string{"semgrep", "terraform", "trivy", "npm-audit", "kube-linter"})
+	want := []string{"git", "semgrep", "trivy", "npm", "pnpm", "yarn", "kube-linter", "helm"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("requiredBinaries = %v, want %v", got, want)
+	}
+}
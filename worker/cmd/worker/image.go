@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Image scans run trivy against a container image in a registry instead of
+// scanning a checkout. The API only queues images from its
+// images.allowed_registries; workers check their own list before pulling.
+// Registry credentials reach trivy through its environment, never its
+// arguments, so they stay out of the command log.
+
+// sourceImage marks jobs that scan a container image.
+const sourceImage = "image"
+
+// imageRegistry returns the registry host of an image reference, as the
+// API normalized it (registry/repository[:tag][@digest]).
+func imageRegistry(image string) string {
+	registry, _, _ := strings.Cut(image, "/")
+	return strings.ToLower(registry)
+}
+
+func (wk *Worker) runTrivyImage(ctx context.Context, job *scanJob) error {
+	args := []string{"image", "--format", "json", "--quiet", "--scanners", "vuln", "--timeout", wk.cfg.TrivyTimeout}
+	if wk.cfg.Concurrency > 1 {
+		args = append(args, "--cache-backend", "memory")
+	}
+	if wk.cfg.Offline {
+		args = append(args, offlineTrivyArgs(wk.cfg)...)
+	} else if dir, release, ok := wk.cache.use(cacheTrivy); ok {
+		defer release()
+		args = append(args, "--cache-dir", dir, "--skip-db-update", "--skip-java-db-update")
+	}
+	env := wk.cfg.ScannerProxySetting().Environ()
+	username, password, err := wk.store.ImageCredentials(ctx, job.msg.JobID)
+	if err != nil {
+		return fmt.Errorf("image credentials: %w", err)
+	}
+	if username != "" {
+		env = append(env, "TRIVY_USERNAME="+username, "TRIVY_PASSWORD="+password)
+	}
+	out, err := runCmdJSON(ctx, "trivy", append(args, job.image), job.dir, env)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "trivy-image.json", "application/json", out)
+	var parsed trivyOut
+	if perr := json.Unmarshal(out, &parsed); perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("trivy parse error: %v", perr)
+	}
+	job.addTrivyResults(parsed)
+	return err
+}
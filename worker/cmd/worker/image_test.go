@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"argus/worker/internal/config"
+)
+
+func TestImageRegistry(t *testing.T) {
+	for image, want := range map[string]string{
+		"docker.io/library/alpine:3.19":             "docker.io",
+		"Registry.Example.com:5000/team/app:latest": "registry.example.com:5000",
+		"ghcr.io/acme/api@sha256:0123":              "ghcr.io",
+	} {
+		if got := imageRegistry(image); got != want {
+			t.Errorf("imageRegistry(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestJobScannersImage(t *testing.T) {
+	wk := &Worker{cfg: config.Config{Scanners: []string{"semgrep", "trivy", "syft"}}}
+	got := wk.jobScanners(context.Background(), &scanJob{image: "ghcr.io/acme/api:1.0", scanners: []string{"trivy"}})
+	if len(got) != 1 || got[0].name != "trivy" {
+		t.Fatalf("scanners = %v", got)
+	}
+	wk.cfg.Scanners = []string{"semgrep"}
+	if got := wk.jobScanners(context.Background(), &scanJob{image: "ghcr.io/acme/api:1.0"}); len(got) != 0 {
+		t.Errorf("trivy disabled, scanners = %v", got)
+	}
+}
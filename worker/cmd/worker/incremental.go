@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"argus/internal/proxy"
+)
+
+// Incremental scans carry the commit of the repo's last scan as base_sha.
+// The files changed between it and the checkout are staged into a separate
+// tree, and semgrep and gitleaks scan that instead of the clone; findings
+// keep their repo-relative paths. Other scanners still see the whole clone.
+// When the base cannot be fetched, say after a force push, the scan falls
+// back to the whole tree.
+
+// stagedConfigFiles are copied along with the changed files so the repo's
+// own ignores and settings still apply.
+var stagedConfigFiles = []string{".semgrepignore", ".gitleaks.toml", ".gitleaksignore"}
+
+// diffDir is where semgrep and gitleaks run: the staged changes of an
+// incremental scan, or the clone.
+func (j *scanJob) diffDir() string {
+	if j.changedDir != "" {
+		return j.changedDir
+	}
+	return j.dir
+}
+
+// stageIncremental stages the files changed since base under workRoot and
+// returns the staged tree, or "" to scan the whole clone.
+func (wk *Worker) stageIncremental(ctx context.Context, job *scanJob, base, workRoot string) string {
+	changed, err := changedFiles(ctx, job.dir, base, wk.cfg.GitHubProxySetting())
+	if err != nil {
+		slog.WarnContext(ctx, "incremental diff failed; scanning the whole tree", "base_sha", base, "err", err)
+		return ""
+	}
+	dst := filepath.Join(workRoot, "changed")
+	if err := stageFiles(job.dir, dst, append(changed, stagedConfigFiles...)); err != nil {
+		slog.WarnContext(ctx, "staging changed files failed; scanning the whole tree", "err", err)
+		return ""
+	}
+	slog.InfoContext(ctx, "incremental scan", "base_sha", base, "changed_files", len(changed))
+	return dst
+}
+
+// changedFiles lists the files that exist at HEAD and differ from base,
+// relative to the clone.
+func changedFiles(ctx context.Context, repoDir, base string, p proxy.Setting) ([]string, error) {
+	if err := fetchCommit(ctx, repoDir, base, p); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "diff", "--name-only", "-z", "--no-renames", "--diff-filter=d", base, "HEAD")
+	// A blob:none clone may need to fetch blobs to detect changes.
+	cmd.Env = append(p.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %w", base, err)
+	}
+	var files []string
+	for _, f := range bytes.Split(out, []byte{0}) {
+		if len(f) > 0 {
+			files = append(files, string(f))
+		}
+	}
+	return files, nil
+}
+
+// stageFiles links, or else copies, the regular files among paths from src
+// to the same paths under dst, once each. Missing files, symlinks and paths
+// leaving src are skipped.
+func stageFiles(src, dst string, paths []string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if !filepath.IsLocal(p) || seen[p] {
+			continue
+		}
+		seen[p] = true
+		from, to := filepath.Join(src, p), filepath.Join(dst, p)
+		info, err := os.Lstat(from)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return err
+		}
+		if os.Link(from, to) == nil {
+			continue
+		}
+		if err := copyFile(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"argus/internal/proxy"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return string(out)
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("keep.go", "package a\n")
+	write("edit.go", "package a\n")
+	write("gone.go", "package a\n")
+	git("add", "-A")
+	git("commit", "-qm", "base")
+	base := git("rev-parse", "HEAD")
+	write("edit.go", "package a\n\nvar x = 1\n")
+	write("src/new file.py", "print(1)\n")
+	if err := os.Remove(filepath.Join(dir, "gone.go")); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-qm", "next")
+
+	got, err := changedFiles(context.Background(), dir, base[:40], proxy.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"edit.go", "src/new file.py"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changedFiles = %q, want %q", got, want)
+	}
+}
+
+func TestStageFiles(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "changed")
+	for name, content := range map[string]string{"a.go": "a", "sub/b.go": "b", ".semgrepignore": "vendor/"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"a.go", "sub/b.go", "a.go", "missing.go", "link", "../outside", ".semgrepignore", ".gitleaks.toml"}
+	if err := stageFiles(src, dst, paths); err != nil {
+		t.Fatal(err)
+	}
+	var staged []string
+	_ = filepath.WalkDir(dst, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dst, path)
+			staged = append(staged, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if want := []string{".semgrepignore", "a.go", "sub/b.go"}; !reflect.DeepEqual(staged, want) {
+		t.Errorf("staged %q, want %q", staged, want)
+	}
+	if b, _ := os.ReadFile(filepath.Join(src, "a.go")); string(b) != "a" {
+		t.Errorf("source file changed to %q", b)
+	}
+}
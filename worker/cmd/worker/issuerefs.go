@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Code can name the issue that tracks a finding with an annotation in a
+// comment on the finding's line or the line above it:
+//
+//	# argus-issue: #123
+//	// argus-issue: PROJ-42, acme/api#7
+//
+// The references are stored in evidence_json.issue_refs, and fix PRs that
+// address the finding close or mention them.
+
+// maxAnnotatedFileBytes bounds the files read for annotations.
+const maxAnnotatedFileBytes = 2 << 20
+
+var (
+	issueAnnotation = regexp.MustCompile(`argus-issue:\s*(.+)`)
+	// issueRefPattern matches #123, owner/repo#123 and Jira keys.
+	issueRefPattern = regexp.MustCompile(`^(?:(?:[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)?#[0-9]+|[A-Z][A-Z0-9_]+-[0-9]+)$`)
+)
+
+// linkIssueRefs records the issue annotations next to each finding in its
+// evidence.
+func (j *scanJob) linkIssueRefs() {
+	files := map[string][]string{}
+	for _, f := range j.findings {
+		ev, ok := f.Evidence.(map[string]any)
+		if !ok || f.FilePath == nil || f.LineStart == nil || *f.LineStart < 1 || !filepath.IsLocal(*f.FilePath) {
+			continue
+		}
+		lines, seen := files[*f.FilePath]
+		if !seen {
+			lines = readAnnotationLines(filepath.Join(j.dir, *f.FilePath))
+			files[*f.FilePath] = lines
+		}
+		var refs []string
+		if n := *f.LineStart - 1; n < len(lines) {
+			refs = issueRefs(lines[n])
+			// The line above counts only when it is just the annotation,
+			// not a trailing comment on other code.
+			if n > 0 && isAnnotationLine(lines[n-1]) {
+				refs = append(refs, issueRefs(lines[n-1])...)
+			}
+		}
+		if len(refs) > 0 {
+			ev["issue_refs"] = refs
+		}
+	}
+}
+
+// readAnnotationLines returns the lines of a regular file under the size
+// bound; other files yield nil.
+func readAnnotationLines(path string) []string {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxAnnotatedFileBytes {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxAnnotatedFileBytes)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
+
+// isAnnotationLine reports whether line is a comment holding only an
+// argus-issue annotation.
+func isAnnotationLine(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t#/*-;<!{"), "argus-issue:")
+}
+
+// issueRefs returns the valid references in a line's argus-issue
+// annotation.
+func issueRefs(line string) []string {
+	m := issueAnnotation.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	var refs []string
+	for _, tok := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		if issueRefPattern.MatchString(tok) {
+			refs = append(refs, tok)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLinkIssueRefs(t *testing.T) {
+	dir := t.TempDir()
+	src := "import subprocess\n# argus-issue: #123, PROJ-42 see notes\nsubprocess.call(cmd, shell=True)  # argus-issue: acme/api#7\nprint(1)\n"
+	if err := os.WriteFile(filepath.Join(dir, "run.py"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, outside := "run.py", "../run.py"
+	three, four := 3, 4
+	job := &scanJob{dir: dir, findings: []finding{
+		{FilePath: &file, LineStart: &three, Evidence: map[string]any{}},
+		{FilePath: &file, LineStart: &four, Evidence: map[string]any{}},
+		{FilePath: &outside, LineStart: &three, Evidence: map[string]any{}},
+	}}
+	job.linkIssueRefs()
+	if got := job.findings[0].Evidence.(map[string]any)["issue_refs"]; !reflect.DeepEqual(got, []string{"acme/api#7", "#123", "PROJ-42"}) {
+		t.Errorf("issue_refs = %v", got)
+	}
+	for _, f := range job.findings[1:] {
+		if ev := f.Evidence.(map[string]any); ev["issue_refs"] != nil {
+			t.Errorf("unexpected issue_refs %v", ev["issue_refs"])
+		}
+	}
+}
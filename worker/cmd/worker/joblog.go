@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Every job keeps a log the API serves at /api/jobs/{id}/logs: the worker's
+// log records for the job, each command it runs with its output and exit
+// status, and the stderr of scanners as they write it. Scanner stdout is the
+// report, saved as an artifact instead. Lines are buffered and written to
+// the store every jobLogFlushEvery so the log can be followed while the job
+// runs. An attempt ends with a line on the logEnd stream.
+//
+// With an artifact store configured, a finished job's log is then moved
+// there as one NDJSON object and the rows are dropped.
+
+const (
+	jobLogFlushEvery = time.Second
+	// maxJobLogLines caps the lines one attempt keeps; a scanner stuck in
+	// a loop must not fill the database.
+	maxJobLogLines  = 20000
+	maxLogLineBytes = 4096
+
+	logWorker = "worker"
+	logEnd    = "end"
+)
+
+type logLine struct {
+	ID      int64     `json:"id,omitempty"` // set once stored
+	Attempt int       `json:"attempt"`
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Text    string    `json:"text"`
+}
+
+type jobLog struct {
+	st       store
+	jobID    string
+	attempt  int
+	sanitize func(string) string
+
+	mu        sync.Mutex
+	pending   []logLine
+	lines     int
+	truncated bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newJobLog(st store, jobID string, attempt int, sanitize func(string) string) *jobLog {
+	return &jobLog{st: st, jobID: jobID, attempt: attempt, sanitize: sanitize, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func withJobLog(ctx context.Context, l *jobLog) context.Context {
+	return context.WithValue(ctx, jobLogKey, l)
+}
+
+// jobLogFrom returns the job log on ctx, or nil; a nil log drops lines.
+func jobLogFrom(ctx context.Context) *jobLog {
+	l, _ := ctx.Value(jobLogKey).(*jobLog)
+	return l
+}
+
+// add appends text to stream, one line per line of text.
+func (l *jobLog) add(stream, text string) {
+	if l == nil {
+		return
+	}
+	text = l.sanitize(strings.ToValidUTF8(strings.ReplaceAll(text, "\x00", ""), "�"))
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if l.lines >= maxJobLogLines {
+			if !l.truncated {
+				l.truncated = true
+				l.pending = append(l.pending, logLine{Attempt: l.attempt, Time: now, Stream: logWorker, Text: fmt.Sprintf("log truncated after %d lines", maxJobLogLines)})
+			}
+			return
+		}
+		if len(line) > maxLogLineBytes {
+			line = strings.ToValidUTF8(line[:maxLogLineBytes], "") + " …"
+		}
+		l.pending = append(l.pending, logLine{Attempt: l.attempt, Time: now, Stream: stream, Text: strings.TrimRight(line, "\r")})
+		l.lines++
+	}
+}
+
+// command logs that a command is starting.
+func (l *jobLog) command(name string, args []string) {
+	l.add(name, "$ "+strings.Join(append([]string{name}, args...), " "))
+}
+
+// exit logs how a command ended.
+func (l *jobLog) exit(name string, started time.Time, err error) {
+	took := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		l.add(name, fmt.Sprintf("failed after %s: %v", took, err))
+		return
+	}
+	l.add(name, fmt.Sprintf("exited 0 after %s", took))
+}
+
+// writer returns a writer that logs each complete line written to it on
+// stream. Close logs what is left of the last line.
+func (l *jobLog) writer(stream string) *logWriter {
+	return &logWriter{log: l, stream: stream}
+}
+
+type logWriter struct {
+	log    *jobLog
+	stream string
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if w.log == nil {
+		return len(p), nil
+	}
+	w.buf = append(w.buf, p...)
+	if i := bytes.LastIndexByte(w.buf, '\n'); i >= 0 {
+		w.log.add(w.stream, string(w.buf[:i]))
+		w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	}
+	if len(w.buf) > maxLogLineBytes {
+		w.log.add(w.stream, string(w.buf))
+		w.buf = w.buf[:0]
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) Close() error {
+	if w.log != nil && len(w.buf) > 0 {
+		w.log.add(w.stream, string(w.buf))
+		w.buf = nil
+	}
+	return nil
+}
+
+// lockedBuffer collects a command's stdout and stderr, which exec copies
+// from separate goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// run flushes the log every jobLogFlushEvery until close.
+func (l *jobLog) run() {
+	defer close(l.done)
+	t := time.NewTicker(jobLogFlushEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			l.flush()
+		}
+	}
+}
+
+// close ends the attempt's log with outcome on the logEnd stream and writes
+// what is still buffered.
+func (l *jobLog) close(outcome string) {
+	l.add(logEnd, outcome)
+	close(l.stop)
+	<-l.done
+	l.flush()
+}
+
+// flush writes the buffered lines. It uses its own context and logs without
+// one, so neither the job's deadline nor its log gets in the way. Lines that
+// cannot be written are dropped.
+func (l *jobLog) flush() {
+	l.mu.Lock()
+	lines := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	if len(lines) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := l.st.AppendJobLog(ctx, l.jobID, lines); err != nil {
+		slog.Warn("job log lines dropped", "job_id", l.jobID, "lines", len(lines), "err", err)
+	}
+}
+
+// logRecord formats a log record for the job log: level, message and
+// attributes, without the time the line already carries.
+func logRecord(r slog.Record) string {
+	var b strings.Builder
+	b.WriteString(r.Level.String() + " " + r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value.Any())
+		return true
+	})
+	return b.String()
+}
+
+// archiveJobLog moves a finished job's log to the artifact store. The rows
+// stay when anything fails.
+func (wk *Worker) archiveJobLog(ctx context.Context, jobID string) {
+	lines, err := wk.store.JobLog(ctx, jobID)
+	if err != nil || len(lines) == 0 {
+		if err != nil {
+			slog.WarnContext(ctx, "job log not archived", "err", err)
+		}
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, line := range lines {
+		_ = enc.Encode(line)
+	}
+	key := wk.objects.Key(artifactObjectName(jobID, "job.log.ndjson"))
+	if err := wk.objects.Put(ctx, key, "application/x-ndjson", buf.Bytes()); err != nil {
+		slog.WarnContext(ctx, "job log not archived", "err", err)
+		return
+	}
+	if err := wk.store.ArchiveJobLog(ctx, jobID, key); err != nil {
+		slog.WarnContext(ctx, "job log not archived", "err", err)
+	}
+}
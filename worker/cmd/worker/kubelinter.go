@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kube-linter checks Kubernetes objects for risky settings: privileged or
+// root containers, missing resource requests and limits, host namespaces.
+// Plain manifests are linted as they are; Helm charts are first rendered
+// with `helm template` and their default values, and findings on rendered
+// objects point back at the chart's template. The stage only runs when the
+// clone has either.
+
+const (
+	// maxManifests bounds the plain manifests one run lints.
+	maxManifests = 500
+	// maxManifestBytes skips large YAML files, which are rarely manifests.
+	maxManifestBytes = 1 << 20
+)
+
+type kubeLinterOut struct {
+	Reports []struct {
+		Check       string `json:"Check"`
+		Remediation string `json:"Remediation"`
+		Diagnostic  struct {
+			Message string `json:"Message"`
+		} `json:"Diagnostic"`
+		Object struct {
+			Metadata struct {
+				FilePath string `json:"FilePath"`
+			} `json:"Metadata"`
+			K8sObject struct {
+				Namespace        string `json:"Namespace"`
+				Name             string `json:"Name"`
+				GroupVersionKind struct {
+					Kind string `json:"Kind"`
+				} `json:"GroupVersionKind"`
+			} `json:"K8sObject"`
+		} `json:"Object"`
+	} `json:"Reports"`
+}
+
+// kubeLinterSeverity ranks kube-linter's checks, which carry no severity of
+// their own. Checks that hand a container the node are HIGH, missing limits
+// and writable filesystems MEDIUM, the rest LOW.
+var kubeLinterSeverity = map[string]string{
+	"privileged-container":           "HIGH",
+	"privilege-escalation-container": "HIGH",
+	"host-network":                   "HIGH",
+	"host-pid":                       "HIGH",
+	"host-ipc":                       "HIGH",
+	"docker-sock":                    "HIGH",
+	"sensitive-host-mounts":          "HIGH",
+	"run-as-non-root":                "MEDIUM",
+	"unset-cpu-requirements":         "MEDIUM",
+	"unset-memory-requirements":      "MEDIUM",
+	"no-read-only-root-fs":           "MEDIUM",
+	"latest-tag":                     "MEDIUM",
+	"env-var-secret":                 "MEDIUM",
+}
+
+// renderedCharts maps the directories charts were rendered into back to
+// the charts' directories in the clone.
+type renderedCharts map[string]string
+
+func (wk *Worker) runKubeLinter(ctx context.Context, job *scanJob) error {
+	charts, manifests := findKubernetes(job.dir)
+	if len(charts) == 0 && len(manifests) == 0 {
+		return nil
+	}
+	rendered, err := renderCharts(ctx, job.dir, filepath.Join(filepath.Dir(job.dir), "helm"), charts)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 && len(rendered) == 0 {
+		return nil
+	}
+	args := append([]string{"lint", "--format", "json"}, manifests...)
+	for dir := range rendered {
+		args = append(args, dir)
+	}
+	out, err := runCmdJSON(ctx, "kube-linter", args, job.dir, nil)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "kube-linter.json", "application/json", out)
+	var parsed kubeLinterOut
+	if perr := decodeJSONObject(out, &parsed); perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("kube-linter parse error: %v", perr)
+	}
+	job.addKubeLinterReports(parsed, rendered)
+	// kube-linter exits non-zero whenever it reports anything.
+	return nil
+}
+
+// renderCharts renders each chart into its own directory under root. A
+// chart that does not render, typically one whose dependencies are not
+// vendored, is skipped with a warning.
+func renderCharts(ctx context.Context, repoDir, root string, charts []string) (renderedCharts, error) {
+	out := renderedCharts{}
+	for i, chart := range charts {
+		dir := filepath.Join(root, strconv.Itoa(i))
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+		args := []string{"template", "argus", "./" + chart, "--output-dir", dir}
+		env := append(os.Environ(), "HELM_CACHE_HOME="+root, "HELM_CONFIG_HOME="+root, "HELM_DATA_HOME="+root)
+		if _, err := runCmdJSON(ctx, "helm", args, repoDir, env); err != nil {
+			slog.WarnContext(ctx, "helm chart not rendered; skipping it", "chart", chart, "err", err)
+			continue
+		}
+		out[dir] = chart
+	}
+	return out, nil
+}
+
+// source returns the clone path of a file kube-linter read: rendered
+// templates map back to their chart. helm writes <out>/<chart name>/<path
+// within the chart>.
+func (rc renderedCharts) source(path string) (file, chart string) {
+	for dir, chart := range rc {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if i := strings.IndexByte(rel, '/'); i >= 0 {
+			rel = rel[i+1:]
+		}
+		return filepath.ToSlash(filepath.Join(chart, rel)), chart
+	}
+	return filepath.ToSlash(path), ""
+}
+
+func (j *scanJob) addKubeLinterReports(parsed kubeLinterOut, rendered renderedCharts) {
+	for _, r := range parsed.Reports {
+		sev := kubeLinterSeverity[r.Check]
+		if sev == "" {
+			sev = "LOW"
+		}
+		k := r.Object.K8sObject
+		object := k.GroupVersionKind.Kind + "/" + k.Name
+		if k.Namespace != "" {
+			object = k.GroupVersionKind.Kind + "/" + k.Namespace + "/" + k.Name
+		}
+		file, chart := rendered.source(r.Object.Metadata.FilePath)
+		parts := map[string]string{"check": r.Check, "message": r.Diagnostic.Message, "object": object, "path": file, "severity": sev}
+		title := j.titles.render(titleKubeLinter, parts)
+		desc := r.Diagnostic.Message
+		if r.Remediation != "" {
+			desc += "\n\n" + r.Remediation
+		}
+		// Keyed by object rather than line: rendered templates have no
+		// stable lines in the chart's source.
+		fpv := j.fp("kube-linter", r.Check, file, object)
+		j.add(finding{Tool: "kube-linter", Severity: sev, Title: title, FilePath: &file, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"rule_id":     r.Check,
+			"object":      object,
+			"kind":        k.GroupVersionKind.Kind,
+			"namespace":   k.Namespace,
+			"name":        k.Name,
+			"chart":       chart,
+			"remediation": r.Remediation,
+			"url":         "https://docs.kubelinter.io/#/generated/checks?id=" + r.Check,
+			"title_parts": parts,
+		}})
+	}
+}
+
+// findKubernetes returns the clone's Helm chart directories and the plain
+// manifests outside them, relative to dir and sorted. Charts nested in
+// another chart are its subcharts and render with it.
+func findKubernetes(dir string) (charts, manifests []string) {
+	seen := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err == nil {
+				if rel, err := filepath.Rel(dir, path); err == nil {
+					charts = append(charts, filepath.ToSlash(rel))
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles {
+			return filepath.SkipAll
+		}
+		ext := strings.ToLower(filepath.Ext(d.Name()))
+		if (ext != ".yaml" && ext != ".yml") || len(manifests) >= maxManifests || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxManifestBytes {
+			return nil
+		}
+		if isManifest(path) {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				manifests = append(manifests, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	sort.Strings(charts)
+	sort.Strings(manifests)
+	return charts, manifests
+}
+
+// isManifest reports whether a YAML file declares a Kubernetes object: a
+// top-level apiVersion and kind.
+func isManifest(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var apiVersion, kind bool
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxManifestBytes)
+	for sc.Scan() {
+		line := sc.Bytes()
+		apiVersion = apiVersion || bytes.HasPrefix(line, []byte("apiVersion:"))
+		kind = kind || bytes.HasPrefix(line, []byte("kind:"))
+		if apiVersion && kind {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSONObject decodes the first JSON object in out, skipping anything
+// a tool printed around it.
+func decodeJSONObject(out []byte, v any) error {
+	i := bytes.IndexByte(out, '{')
+	if i < 0 {
+		return fmt.Errorf("no JSON object in output")
+	}
+	return json.NewDecoder(bytes.NewReader(out[i:])).Decode(v)
+}
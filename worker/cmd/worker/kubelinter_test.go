@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindKubernetes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deploy/web.yaml":                      "apiVersion: apps/v1\nkind: Deployment\n",
+		"deploy/kustomization.yml":             "resources:\n  - web.yaml\n",
+		".github/workflows/ci.yml":             "on: push\n",
+		"charts/api/Chart.yaml":                "apiVersion: v2\nname: api\n",
+		"charts/api/templates/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\n",
+		"charts/api/charts/db/Chart.yaml":      "apiVersion: v2\nname: db\n",
+		"vendor/x/pod.yaml":                    "apiVersion: v1\nkind: Pod\n",
+	}
+	for f, body := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	charts, manifests := findKubernetes(dir)
+	if !reflect.DeepEqual(charts, []string{"charts/api"}) {
+		t.Errorf("charts = %v", charts)
+	}
+	if !reflect.DeepEqual(manifests, []string{"deploy/web.yaml"}) {
+		t.Errorf("manifests = %v", manifests)
+	}
+}
+
+func TestAddKubeLinterReports(t *testing.T) {
+	var parsed kubeLinterOut
+	out := []byte(`{"Reports":[
+		{"Check":"privileged-container","Remediation":"Do not run privileged containers.","Diagnostic":{"Message":"container \"app\" is privileged"},
+		 "Object":{"Metadata":{"FilePath":"/work/helm/0/api/templates/deployment.yaml"},"K8sObject":{"Namespace":"","Name":"argus-api","GroupVersionKind":{"Group":"apps","Version":"v1","Kind":"Deployment"}}}},
+		{"Check":"dangling-service","Diagnostic":{"Message":"no pods found matching service labels"},
+		 "Object":{"Metadata":{"FilePath":"deploy/web.yaml"},"K8sObject":{"Namespace":"web","Name":"web","GroupVersionKind":{"Kind":"Service"}}}}]}
+Error: found 2 lint errors
+`)
+	if err := decodeJSONObject(out, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addKubeLinterReports(parsed, renderedCharts{"/work/helm/0": "charts/api"})
+	if len(job.findings) != 2 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	if f.Tool != "kube-linter" || f.Severity != "HIGH" || f.Title != "privileged-container: Deployment/argus-api" || *f.FilePath != "charts/api/templates/deployment.yaml" {
+		t.Errorf("finding = %+v", f)
+	}
+	if ev := f.Evidence.(map[string]any); ev["chart"] != "charts/api" || ev["rule_id"] != "privileged-container" {
+		t.Errorf("evidence = %v", ev)
+	}
+	f = job.findings[1]
+	if f.Severity != "LOW" || *f.FilePath != "deploy/web.yaml" || f.Evidence.(map[string]any)["object"] != "Service/web/web" {
+		t.Errorf("finding = %+v", f)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxDetectFiles bounds the language walk on very large trees; the first
+// files seen are representative enough to choose rule packs.
+const maxDetectFiles = 50000
+
+// packsByExt maps file extensions to curated semgrep registry packs. Rule
+// selection stays deterministic, unlike --config auto.
+var packsByExt = map[string]string{
+	".go":    "p/golang",
+	".js":    "p/javascript",
+	".jsx":   "p/javascript",
+	".mjs":   "p/javascript",
+	".cjs":   "p/javascript",
+	".ts":    "p/typescript",
+	".tsx":   "p/typescript",
+	".py":    "p/python",
+	".java":  "p/java",
+	".kt":    "p/kotlin",
+	".scala": "p/scala",
+	".rb":    "p/ruby",
+	".php":   "p/php",
+	".cs":    "p/csharp",
+	".rs":    "p/rust",
+	".c":     "p/c",
+	".h":     "p/c",
+	".swift": "p/swift",
+	".tf":    "p/terraform",
+}
+
+// skipDirs are never walked: they hold VCS metadata or vendored third-party
+// code that semgrep ignores by default anyway.
+var skipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, "third_party": true}
+
+// detectSemgrepPacks returns the sorted rule packs for the languages present
+// under dir.
+func detectSemgrepPacks(dir string) []string {
+	found := map[string]bool{}
+	seen := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles {
+			return filepath.SkipAll
+		}
+		name := d.Name()
+		if isDockerfile(name) {
+			found["p/docker"] = true
+			return nil
+		}
+		if pack, ok := packsByExt[strings.ToLower(filepath.Ext(name))]; ok {
+			found[pack] = true
+		}
+		return nil
+	})
+
+	packs := make([]string, 0, len(found))
+	for p := range found {
+		packs = append(packs, p)
+	}
+	sort.Strings(packs)
+	return packs
+}
+
+// isDockerfile reports whether a file name is a Dockerfile by convention.
+func isDockerfile(name string) bool {
+	return name == "Dockerfile" || name == "Containerfile" || strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".dockerfile")
+}
+
+// repoProfile is what the API classifies a repo's jobs by: its size on disk
+// without VCS metadata, its file count and the languages detected.
+type repoProfile struct {
+	SizeKB    int64    `json:"size_kb"`
+	FileCount int      `json:"file_count"`
+	Languages []string `json:"languages"`
+}
+
+// profileRepo measures the tree under dir. Vendored directories count
+// towards size, since scanners such as trivy read them, but not towards
+// languages.
+func profileRepo(dir string) repoProfile {
+	var size int64
+	var p repoProfile
+	langs := map[string]bool{}
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		p.FileCount++
+		if pack, ok := packsByExt[strings.ToLower(filepath.Ext(d.Name()))]; ok && !inSkipDir(dir, path) {
+			langs[strings.TrimPrefix(pack, "p/")] = true
+		}
+		return nil
+	})
+	p.SizeKB = (size + 1023) / 1024
+	p.Languages = make([]string, 0, len(langs))
+	for l := range langs {
+		p.Languages = append(p.Languages, l)
+	}
+	sort.Strings(p.Languages)
+	return p
+}
+
+func inSkipDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if skipDirs[part] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectSemgrepPacks(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{
+		"main.go",
+		"web/src/App.tsx",
+		"web/src/index.js",
+		"deploy/Dockerfile",
+		"infra/main.tf",
+		"README.md",
+		"node_modules/lib/index.py",
+		"vendor/x/y.rb",
+	} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := detectSemgrepPacks(dir)
+	want := []string{"p/docker", "p/golang", "p/javascript", "p/terraform", "p/typescript"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("packs = %v, want %v", got, want)
+	}
+	if packs := detectSemgrepPacks(t.TempDir()); len(packs) != 0 {
+		t.Fatalf("empty tree should select no packs, got %v", packs)
+	}
+}
+
+func TestProfileRepo(t *testing.T) {
+	dir := t.TempDir()
+	for f, size := range map[string]int{
+		"main.go":             2048,
+		"web/app.ts":          100,
+		".git/objects/pack":   1 << 20,
+		"vendor/x/lib.rb":     500,
+		"node_modules/a/b.py": 10,
+	} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := profileRepo(dir)
+	if p.FileCount != 4 || p.SizeKB != 3 {
+		t.Errorf("files, size = %d, %dKB; want 4, 3KB", p.FileCount, p.SizeKB)
+	}
+	if want := []string{"golang", "typescript"}; !reflect.DeepEqual(p.Languages, want) {
+		t.Errorf("languages = %v, want %v", p.Languages, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	jobIDKey ctxKey = iota
+	requestIDKey
+	commandLogKey
+	jobLogKey
+)
+
+// contextHandler copies the job and originating API request IDs carried on
+// the context onto every record, and copies the record into the job's log.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	jobLogFrom(ctx).add(logWorker, logRecord(r))
+	if id, ok := ctx.Value(jobIDKey).(string); ok && id != "" {
+		r.AddAttrs(slog.String("job_id", id))
+	}
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// setupLogging installs the default logger. format "json" switches to JSON
+// lines; level accepts debug, info, warn or error.
+func setupLogging(level, format string) {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+	var h slog.Handler = slog.NewTextHandler(os.Stdout, opts)
+	if strings.EqualFold(format, "json") {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}).With("service", "worker"))
+}
+
+func parseLevel(v string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func withJob(ctx context.Context, msg JobMsg) context.Context {
+	ctx = context.WithValue(ctx, jobIDKey, msg.JobID)
+	return context.WithValue(ctx, requestIDKey, msg.RequestID)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
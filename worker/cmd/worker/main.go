@@ -3,11 +3,21 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"flag"
+	"log/slog"
 	"os"
-	"strconv"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"argus/internal/forge"
+	"argus/internal/objstore"
+	"argus/internal/proxy"
+	"argus/worker/internal/config"
+	"argus/worker/internal/githubapp"
+	"argus/worker/internal/gitlab"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 )
@@ -15,67 +25,233 @@ import (
 type JobMsg struct {
 	JobID  string `json:"job_id"`
 	RepoID string `json:"repo_id"`
+	Source string `json:"source,omitempty"`
+	// RequestID is the API request that enqueued the job, for log correlation.
+	RequestID string `json:"request_id,omitempty"`
+	// Attempt counts earlier transient failures of this job.
+	Attempt int `json:"attempt,omitempty"`
+	// Priority is high, normal (the default) or low.
+	Priority string `json:"priority,omitempty"`
+	// Org selects the fair-queuing list the message waits in.
+	Org string `json:"org,omitempty"`
+	// Size is the job's size class: small, medium (the default) or large.
+	Size string `json:"size,omitempty"`
+}
+
+// Worker holds the process-wide dependencies shared by every job.
+type Worker struct {
+	cfg   config.Config
+	store store
+	queue jobQueue
+	owner workspaceOwner
+	cache *warmCache // nil when cache.dir is unset
+	// clones keeps repo mirrors to check jobs out from; nil when
+	// cache.clones_max_mb is unset.
+	clones *cloneCache
+	// github mints installation tokens to clone with; nil without a
+	// GitHub App configured.
+	github *githubapp.Client
+	// gitlab comments on merge requests; nil without gitlab.token.
+	gitlab *gitlab.Client
+	// objects is the artifact store scanner output is uploaded to; nil
+	// keeps it in the database.
+	objects *objstore.Client
+	// versions caches each scanner tool's version; see versions.go.
+	versions sync.Map
 }
 
 func main() {
-	dbURL := os.Getenv("DATABASE_URL")
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if dbURL == "" || redisAddr == "" {
-		panic("DATABASE_URL and REDIS_ADDR are required")
-	}
+	configPath := flag.String("config", os.Getenv("ARGUS_CONFIG"), "path to a YAML or TOML config file")
+	flag.Parse()
 
-	maxCloneMB := envInt("MAX_CLONE_MB", 350)
-	timeoutMin := envInt("SCAN_TIMEOUT_MIN", 20)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+	setupLogging(cfg.LogLevel, cfg.LogFormat)
+	if cfg.Offline {
+		if err := checkOfflineBundles(cfg, time.Now()); err != nil {
+			fatal("offline bundles", err)
+		}
+		slog.Info("offline mode: scanners use local bundles only", "semgrep_rules", cfg.OfflineSemgrepRules, "trivy_cache_dir", cfg.OfflineTrivyCache)
+	}
 
 	ctx := context.Background()
-	db, err := pgxpool.New(ctx, dbURL)
-	if err != nil {
-		panic(err)
+	var db *pgxpool.Pool
+	if cfg.ResultsMode != resultsAPI || cfg.QueueBackend == backendPostgres {
+		if db, err = pgxpool.New(ctx, cfg.DatabaseURL); err != nil {
+			fatal("connect postgres", err)
+		}
+		defer db.Close()
+	}
+	var objects *objstore.Client
+	if cfg.ArtifactStore.Enabled() {
+		// The store is often on the local network, so it goes through the
+		// environment's proxy variables and proxy.no_proxy.
+		if objects, err = objstore.New(cfg.ArtifactStore, proxy.Setting{NoProxy: cfg.NoProxy}); err != nil {
+			fatal("artifact store", err)
+		}
+		slog.Info("scanner output is stored in the artifact store", "endpoint", cfg.ArtifactStore.Endpoint, "bucket", cfg.ArtifactStore.Bucket)
+	}
+	var st store
+	if cfg.ResultsMode == resultsAPI {
+		st = newAPIStore(cfg.ResultsAPIURL, cfg.WorkerToken)
+		slog.Info("results are submitted through the API", "api_url", cfg.ResultsAPIURL)
+	} else {
+		st = &dbStore{db: db, objects: objects, creds: cfg.ImageCredentialsKey}
+	}
+
+	owner := currentOwner(cfg.WorkerID)
+	var queue jobQueue
+	if cfg.QueueBackend == backendPostgres {
+		queue = newPGQueue(ctx, db, cfg.JobQueue, owner.name(), cfg.SizeClasses, cfg.Concurrency)
+	} else {
+		// Every consumer holds a connection while blocked in BLMOVE, so the
+		// pool must be larger than the default when concurrency is high.
+		rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, PoolSize: max(10, cfg.Concurrency+4)})
+		queue = newRedisQueue(rdb, cfg.JobQueue, owner.name(), cfg.SizeClasses)
+	}
+	defer queue.Close()
+	if err := queue.Ping(ctx); err != nil {
+		fatal("connect "+queue.Name(), err)
+	}
+
+	wk := &Worker{cfg: cfg, store: st, queue: queue, owner: owner, cache: newWarmCache(cfg), clones: newCloneCache(cfg), objects: objects}
+	if cfg.GitHubAppID != 0 {
+		if wk.github, err = githubapp.New(cfg.GitHubAppID, cfg.GitHubAppKey, forge.GitHub(cfg.GitHubURL), cfg.GitHubProxySetting()); err != nil {
+			fatal("github app", err)
+		}
+		slog.Info("clones use GitHub App installation tokens", "app_id", cfg.GitHubAppID)
+	}
+	if cfg.GitLabToken != "" {
+		wk.gitlab = gitlab.New(forge.GitLab(cfg.GitLabURL), cfg.GitLabToken, cfg.GitHubProxySetting())
+	}
+	removed, kept := reconcileWorkspaces(cfg.WorkspaceRoot, wk.owner)
+	slog.Info("workspaces reconciled", "root", cfg.WorkspaceRoot, "removed", removed, "kept", kept)
+	// Like workspaces, messages in flight under our own name belong to a
+	// previous incarnation (a container restart reuses PID 1).
+	if n, err := wk.queue.Reclaim(ctx, wk.owner.name()); err != nil {
+		fatal("requeue in-flight jobs", err)
+	} else if n > 0 {
+		slog.Warn("requeued jobs left in flight by a previous run", "jobs", n)
 	}
-	defer db.Close()
+	// The heartbeat outlives the stop signal: jobs still running during the
+	// shutdown grace must not look abandoned.
+	aliveCtx, stopAlive := context.WithCancel(ctx)
+	go wk.keepAlive(aliveCtx)
+	if err := wk.queue.ReapDead(ctx); err != nil {
+		slog.Warn("queue reaper failed", "err", err)
+	}
+
+	stop, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	jobsCtx, abortJobs := context.WithCancelCause(ctx)
+	defer abortJobs(nil)
+	go wk.runReaper(stop)
+	go wk.runRetryPromoter(stop)
+	go runWorkspaceSweeper(stop, cfg.WorkspaceRoot, wk.owner)
+	if wk.cache != nil {
+		go wk.cache.run(stop)
+	}
+	if cfg.HealthAddr != "" {
+		go wk.serveHealth(cfg.HealthAddr)
+	}
+	slog.Info("worker online; waiting for jobs", "worker_id", cfg.WorkerID, "pid", wk.owner.pid, "queue_backend", queue.Name(), "queues", queueKeys(cfg.JobQueue, cfg.SizeClasses), "scanners", cfg.Scanners, "concurrency", cfg.Concurrency)
 
-	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		panic(err)
+	var wg sync.WaitGroup
+	for slot := 1; slot <= cfg.Concurrency; slot++ {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			wk.consume(stop, jobsCtx, slot)
+		}(slot)
 	}
 
-	fmt.Println("Worker online. Waiting for jobs...")
+	<-stop.Done()
+	grace, _ := time.ParseDuration(cfg.ShutdownGrace)
+	slog.Info("shutdown signal received; finishing in-flight jobs", "grace", grace)
+	drain(&wg, grace, abortJobs)
+	stopAlive()
+	wk.shutdown()
+	slog.Info("worker stopped")
+}
 
+// consume takes jobs off the queue one at a time. The worker runs
+// worker.concurrency consumers; each job gets its own workspace and deadline,
+// so a long scan only occupies its own slot. It stops taking jobs once stop
+// is cancelled; jobs run under ctx.
+func (wk *Worker) consume(stop, ctx context.Context, slot int) {
 	for {
-		res, err := rdb.BRPop(ctx, 0, "ssao:jobs").Result()
+		raw, err := wk.queue.Take(stop)
+		if stop.Err() != nil {
+			// A message taken as the signal arrived stays in flight;
+			// shutdown returns it to the queue.
+			return
+		}
 		if err != nil {
-			fmt.Println("queue error:", err)
+			slog.Error("queue error", "slot", slot, "err", err)
 			time.Sleep(2 * time.Second)
 			continue
 		}
-		if len(res) != 2 {
-			continue
-		}
 
 		var msg JobMsg
-		if err := json.Unmarshal([]byte(res[1]), &msg); err != nil {
-			fmt.Println("bad job payload:", err)
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			wk.deadLetter(ctx, raw, deadUnparseable, msg, err)
+			wk.ack(ctx, raw)
 			continue
 		}
 
-		jobCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMin)*time.Minute)
-		if err := runJob(jobCtx, db, msg, maxCloneMB); err != nil {
-			fmt.Println("job failed:", msg.JobID, err)
-		} else {
-			fmt.Println("job done:", msg.JobID)
+		jl := newJobLog(wk.store, msg.JobID, msg.Attempt+1, wk.sanitize)
+		go jl.run()
+		logCtx := withJobLog(withJob(ctx, msg), jl)
+		jobCtx, cancel := context.WithTimeout(logCtx, time.Duration(wk.cfg.ScanTimeoutMin)*time.Minute)
+		slog.InfoContext(jobCtx, "job started", "repo_id", msg.RepoID, "source", msg.Source, "slot", slot, "worker", wk.owner.name())
+		err = wk.runJob(jobCtx, msg)
+		if err != nil && interrupted(jobCtx) {
+			cancel()
+			wk.requeueInterrupted(logCtx, msg)
+			jl.close("interrupted; job requeued")
+			return
 		}
 		cancel()
-	}
-}
+		if err != nil && isTransient(err) && msg.Attempt+1 < wk.cfg.MaxAttempts {
+			rerr := wk.scheduleRetry(logCtx, msg, err)
+			if rerr == nil {
+				jl.close("failed; retry scheduled: " + err.Error())
+				wk.ack(ctx, raw)
+				continue
+			}
+			slog.ErrorContext(jobCtx, "could not schedule retry", "err", rerr)
+		}
+		if err != nil {
+			slog.ErrorContext(jobCtx, "job failed", "err", err, "attempt", msg.Attempt+1)
+			if isTransient(err) && msg.Attempt+1 >= wk.cfg.MaxAttempts {
+				wk.deadLetter(logCtx, raw, deadMaxAttempts, msg, err)
+			}
+			jl.close("failed: " + err.Error())
+		} else {
+			slog.InfoContext(jobCtx, "job done")
+			jl.close("done")
+		}
 
-func envInt(k string, def int) int {
-	v := os.Getenv(k)
-	if v == "" {
-		return def
-	}
-	n, err := strconv.Atoi(v)
-	if err != nil {
-		return def
+		// Events are queued even when the scan timed out, so use a fresh
+		// deadline rather than the job's.
+		evCtx, evCancel := context.WithTimeout(withJob(ctx, msg), 10*time.Second)
+		if msg.Source == sourceImage {
+			// Succeeded or failed for good, the job needs its registry
+			// credentials no more.
+			wk.store.DeleteImageCredentials(evCtx, msg.JobID)
+		}
+		if err := wk.store.WriteSummary(evCtx, msg.JobID); err != nil {
+			slog.WarnContext(evCtx, "job summary not written", "err", err)
+		}
+		if err := wk.store.PublishJobEvents(evCtx, msg.JobID); err != nil {
+			slog.WarnContext(evCtx, "webhook events skipped", "err", err)
+		}
+		if wk.objects != nil {
+			wk.archiveJobLog(evCtx, msg.JobID)
+		}
+		evCancel()
+		wk.ack(ctx, raw)
 	}
-	return n
 }
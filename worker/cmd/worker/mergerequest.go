@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"argus/worker/internal/gitlab"
+)
+
+// reportMergeRequest comments on the lines of a GitLab merge request that
+// brought in new findings, one discussion each, with gitlab.token. The
+// fingerprint markers of earlier comments keep a finding from being
+// commented on twice. Nothing posted here fails the job; problems are
+// logged.
+func (wk *Worker) reportMergeRequest(ctx context.Context, jobID string, spec jobSpec, project string) {
+	if wk.gitlab == nil || !wk.cfg.GitLabReviewComments {
+		return
+	}
+	iid := spec.PullRequest
+	fresh, err := wk.store.NewFindings(ctx, jobID)
+	if err != nil {
+		slog.WarnContext(ctx, "merge request not reported: new findings", "err", err)
+		return
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	refs, err := wk.gitlab.MergeRequestDiffRefs(ctx, project, iid)
+	if err != nil {
+		slog.WarnContext(ctx, "merge request comments skipped: diff refs", "merge_request", iid, "err", err)
+		return
+	}
+	if refs.HeadSHA != spec.CommitSHA {
+		// The merge request has moved on; the scan of its new head
+		// comments instead.
+		slog.InfoContext(ctx, "merge request comments skipped: head changed", "merge_request", iid, "head_sha", refs.HeadSHA)
+		return
+	}
+	diffs, err := wk.gitlab.MergeRequestDiffs(ctx, project, iid)
+	if err != nil {
+		slog.WarnContext(ctx, "merge request comments skipped: diffs", "merge_request", iid, "err", err)
+		return
+	}
+	discussions, err := wk.gitlab.Discussions(ctx, project, iid)
+	if err != nil {
+		slog.WarnContext(ctx, "merge request comments skipped: existing discussions", "merge_request", iid, "err", err)
+		return
+	}
+
+	added := map[string]map[int]bool{}
+	oldPaths := map[string]string{}
+	for _, d := range diffs {
+		added[d.NewPath] = addedLines(d.Diff)
+		oldPaths[d.NewPath] = d.OldPath
+	}
+	var existing []string
+	for _, d := range discussions {
+		for _, n := range d.Notes {
+			existing = append(existing, n.Body)
+		}
+	}
+	posted := 0
+	for _, c := range lineComments(fresh, added, existing) {
+		pos := gitlab.Position{DiffRefs: refs, OldPath: oldPaths[c.path], NewPath: c.path, NewLine: c.line}
+		if err := wk.gitlab.CreateDiscussion(ctx, project, iid, c.body, pos); err != nil {
+			slog.WarnContext(ctx, "merge request comment not posted", "merge_request", iid, "path", c.path, "line", c.line, "err", err)
+			continue
+		}
+		posted++
+	}
+	if posted > 0 {
+		slog.InfoContext(ctx, "merge request comments posted", "merge_request", iid, "comments", posted)
+	}
+}
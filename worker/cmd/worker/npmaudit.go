@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"argus/worker/internal/severity"
+)
+
+// npm-audit runs the package manager's own audit against each JavaScript
+// lockfile in the clone: npm audit for package-lock.json and
+// npm-shrinkwrap.json, pnpm audit for pnpm-lock.yaml and yarn audit for
+// yarn.lock. Audits read the lockfile only; nothing is installed and no
+// lifecycle scripts run. The advisories come from the registry, or from
+// offline.npm_registry in offline mode.
+
+const (
+	// maxLockfiles bounds the lockfiles one run audits.
+	maxLockfiles = 50
+	// maxAdvisoryPaths bounds the dependency paths kept per finding.
+	maxAdvisoryPaths = 5
+)
+
+// Package managers, as recorded in evidence_json.manager.
+const (
+	managerNPM  = "npm"
+	managerPNPM = "pnpm"
+	managerYarn = "yarn"
+)
+
+var lockfileManagers = map[string]string{
+	"package-lock.json":   managerNPM,
+	"npm-shrinkwrap.json": managerNPM,
+	"pnpm-lock.yaml":      managerPNPM,
+	"yarn.lock":           managerYarn,
+}
+
+// jsLockfile is a lockfile to audit, relative to the clone.
+type jsLockfile struct {
+	Path    string
+	Manager string
+}
+
+func (l jsLockfile) dir() string { return filepath.Dir(l.Path) }
+
+// auditCommand returns the audit invocation for the lockfile's manager.
+func (l jsLockfile) auditCommand() (string, []string) {
+	switch l.Manager {
+	case managerPNPM:
+		return "pnpm", []string{"audit", "--json"}
+	case managerYarn:
+		return "yarn", []string{"audit", "--json", "--non-interactive"}
+	}
+	return "npm", []string{"audit", "--json", "--package-lock-only"}
+}
+
+// jsAdvisory is one advisory against one installed version of a package,
+// normalized from the managers' report formats.
+type jsAdvisory struct {
+	ID       string
+	Title    string
+	URL      string
+	Severity string
+	// Label is the registry's own severity and CVSS its CVSS vector.
+	Label      string
+	CVSS       string
+	Package    string
+	Installed  string
+	Vulnerable string // the vulnerable version range
+	Fixed      string // the lowest version outside it, when known
+	CWEs       []string
+	CVEs       []string
+	Paths      []string
+	// FixAvailable is npm's suggested fix, which may upgrade a parent
+	// package instead.
+	FixAvailable map[string]any
+}
+
+func (wk *Worker) runNPMAudit(ctx context.Context, job *scanJob) error {
+	locks := findJSLockfiles(job.dir)
+	if len(locks) == 0 {
+		return nil
+	}
+	env := append(wk.cfg.ScannerProxySetting().Environ(),
+		"npm_config_ignore_scripts=true", "npm_config_update_notifier=false", "npm_config_fund=false",
+		// pnpm would otherwise switch to the version packageManager names,
+		// and yarn to a yarnPath checked into the repo.
+		"npm_config_manage_package_manager_versions=false", "YARN_IGNORE_PATH=1")
+	if wk.cfg.Offline {
+		env = append(env, "npm_config_registry="+wk.cfg.OfflineNPMRegistry)
+	}
+	var errs []error
+	for i, l := range locks {
+		name, args := l.auditCommand()
+		out, err := runCmdJSON(ctx, name, args, filepath.Join(job.dir, l.dir()), env)
+		wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "npm-audit-"+strconv.Itoa(i)+".json", "application/json", out)
+		advisories, perr := parseJSAudit(out, npmLockVersions(filepath.Join(job.dir, l.Path)))
+		if perr != nil {
+			if err == nil {
+				err = fmt.Errorf("%s audit parse error in %s: %v", name, l.Path, perr)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		// Audits exit non-zero whenever they report anything.
+		job.addJSAdvisories(l, advisories)
+	}
+	return errors.Join(errs...)
+}
+
+// findJSLockfiles returns the clone's lockfiles that sit next to a
+// package.json, sorted. yarn 2+ lockfiles are skipped: their audit needs the
+// repo's own yarn release, which would run code from the clone.
+func findJSLockfiles(dir string) []jsLockfile {
+	var out []jsLockfile
+	seen := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles || len(out) >= maxLockfiles {
+			return filepath.SkipAll
+		}
+		manager, ok := lockfileManagers[d.Name()]
+		if !ok || !d.Type().IsRegular() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), "package.json")); err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		if manager == managerYarn && isYarnBerryLock(path) {
+			slog.Warn("yarn 2+ lockfile not audited", "path", filepath.ToSlash(rel))
+			return nil
+		}
+		out = append(out, jsLockfile{Path: filepath.ToSlash(rel), Manager: manager})
+		return nil
+	})
+	sort.Slice(out, func(i, k int) bool { return out[i].Path < out[k].Path })
+	return out
+}
+
+// isYarnBerryLock reports whether a yarn.lock was written by yarn 2 or
+// later, whose lockfiles start with a __metadata entry.
+func isYarnBerryLock(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 0; n < 20 && sc.Scan(); n++ {
+		if strings.HasPrefix(sc.Text(), "__metadata:") {
+			return true
+		}
+	}
+	return false
+}
+
+// npmLockVersions maps node_modules paths to versions from an npm lockfile
+// (lockfileVersion 2 and 3). npm audit names the affected nodes but not
+// their versions. Other lockfiles yield nil.
+func npmLockVersions(path string) map[string]string {
+	if !strings.HasSuffix(path, ".json") {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+		} `json:"packages"`
+	}
+	if json.Unmarshal(b, &lock) != nil {
+		return nil
+	}
+	out := make(map[string]string, len(lock.Packages))
+	for node, p := range lock.Packages {
+		out[node] = p.Version
+	}
+	return out
+}
+
+// npmAuditReport covers the JSON reports of npm 7+ (vulnerabilities) and
+// of npm 6 and pnpm (advisories).
+type npmAuditReport struct {
+	Vulnerabilities map[string]struct {
+		Name         string            `json:"name"`
+		Severity     string            `json:"severity"`
+		Via          []json.RawMessage `json:"via"`
+		Nodes        []string          `json:"nodes"`
+		FixAvailable json.RawMessage   `json:"fixAvailable"`
+	} `json:"vulnerabilities"`
+	Advisories map[string]npmAdvisory `json:"advisories"`
+}
+
+// npmVia is an advisory in an npm 7+ report. Other entries of via are the
+// names of vulnerable dependencies, reported under their own name.
+type npmVia struct {
+	Source   json.Number `json:"source"`
+	Name     string      `json:"name"`
+	Title    string      `json:"title"`
+	URL      string      `json:"url"`
+	Severity string      `json:"severity"`
+	Range    string      `json:"range"`
+	CWE      []string    `json:"cwe"`
+	CVSS     npmCVSS     `json:"cvss"`
+}
+
+// npmCVSS is an advisory's CVSS score and vector; npm leaves the vector
+// empty when the advisory has none.
+type npmCVSS struct {
+	VectorString string `json:"vectorString"`
+}
+
+type npmAdvisory struct {
+	ID                 json.Number     `json:"id"`
+	GHSA               string          `json:"github_advisory_id"`
+	Title              string          `json:"title"`
+	ModuleName         string          `json:"module_name"`
+	Severity           string          `json:"severity"`
+	URL                string          `json:"url"`
+	VulnerableVersions string          `json:"vulnerable_versions"`
+	PatchedVersions    string          `json:"patched_versions"`
+	CVEs               []string        `json:"cves"`
+	CWE                json.RawMessage `json:"cwe"`
+	CVSS               npmCVSS         `json:"cvss"`
+	Findings           []struct {
+		Version string   `json:"version"`
+		Paths   []string `json:"paths"`
+	} `json:"findings"`
+}
+
+// parseJSAudit reads any of the supported report formats: one JSON object
+// from npm or pnpm, or yarn's stream of auditAdvisory lines. versions maps
+// npm's node paths to installed versions.
+func parseJSAudit(out []byte, versions map[string]string) ([]jsAdvisory, error) {
+	i := bytes.IndexByte(out, '{')
+	if i < 0 {
+		return nil, errors.New("no JSON in output")
+	}
+	dec := json.NewDecoder(bytes.NewReader(out[i:]))
+	var first json.RawMessage
+	if err := dec.Decode(&first); err != nil {
+		return nil, err
+	}
+	var kind struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(first, &kind) == nil && kind.Type != "" {
+		return parseYarnAudit(out[i:])
+	}
+	var report npmAuditReport
+	if err := json.Unmarshal(first, &report); err != nil {
+		return nil, err
+	}
+	if report.Vulnerabilities == nil && report.Advisories == nil {
+		// npm prints {"error": ...} when the audit itself failed.
+		return nil, fmt.Errorf("no audit report in output: %s", bytes.TrimSpace(first))
+	}
+	var advisories []jsAdvisory
+	for _, a := range report.Advisories {
+		advisories = append(advisories, a.normalize()...)
+	}
+	names := make([]string, 0, len(report.Vulnerabilities))
+	for name := range report.Vulnerabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := report.Vulnerabilities[name]
+		var fix map[string]any
+		_ = json.Unmarshal(v.FixAvailable, &fix)
+		// Group the affected nodes by installed version.
+		byVersion := map[string][]string{}
+		var order []string
+		for _, node := range v.Nodes {
+			ver := versions[node]
+			if _, ok := byVersion[ver]; !ok {
+				order = append(order, ver)
+			}
+			byVersion[ver] = append(byVersion[ver], node)
+		}
+		if len(order) == 0 {
+			order = []string{""}
+		}
+		for _, raw := range v.Via {
+			var via npmVia
+			if json.Unmarshal(raw, &via) != nil || via.URL == "" {
+				continue
+			}
+			sev := via.Severity
+			if sev == "" {
+				sev = v.Severity
+			}
+			for _, ver := range order {
+				a := jsAdvisory{
+					ID: advisoryID(via.URL, via.Source.String()), Title: via.Title, URL: via.URL,
+					Severity: severity.Of("npm-audit", sev, via.CVSS.VectorString), Label: sev, CVSS: via.CVSS.VectorString, Package: v.Name, Installed: ver, Vulnerable: via.Range,
+					Fixed: fixedFromRange(via.Range, ver), CWEs: via.CWE, Paths: byVersion[ver], FixAvailable: fix,
+				}
+				if a.Fixed == "" && fix != nil && fix["name"] == v.Name {
+					a.Fixed, _ = fix["version"].(string)
+				}
+				advisories = append(advisories, a)
+			}
+		}
+	}
+	sort.Slice(advisories, func(i, k int) bool {
+		x, y := advisories[i], advisories[k]
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		if x.ID != y.ID {
+			return x.ID < y.ID
+		}
+		return x.Installed < y.Installed
+	})
+	return advisories, nil
+}
+
+// parseYarnAudit merges yarn's auditAdvisory lines, one per dependency
+// path, into advisories.
+func parseYarnAudit(out []byte) ([]jsAdvisory, error) {
+	dec := json.NewDecoder(bytes.NewReader(out))
+	byID := map[string]*npmAdvisory{}
+	var order []string
+	for dec.More() {
+		var line struct {
+			Type string `json:"type"`
+			Data struct {
+				Resolution struct {
+					Path string `json:"path"`
+				} `json:"resolution"`
+				Advisory npmAdvisory `json:"advisory"`
+			} `json:"data"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			return nil, err
+		}
+		if line.Type != "auditAdvisory" {
+			continue
+		}
+		a := line.Data.Advisory
+		key := a.ID.String()
+		if _, ok := byID[key]; !ok {
+			byID[key] = &a
+			order = append(order, key)
+		}
+	}
+	var advisories []jsAdvisory
+	for _, key := range order {
+		advisories = append(advisories, byID[key].normalize()...)
+	}
+	return advisories, nil
+}
+
+// normalize splits an npm 6 style advisory by installed version.
+func (a npmAdvisory) normalize() []jsAdvisory {
+	var cwes []string
+	var one string
+	if json.Unmarshal(a.CWE, &cwes) != nil && json.Unmarshal(a.CWE, &one) == nil && one != "" {
+		cwes = []string{one}
+	}
+	id := a.GHSA
+	if id == "" {
+		id = advisoryID(a.URL, a.ID.String())
+	}
+	fixed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(a.PatchedVersions), ">="))
+	if strings.ContainsAny(fixed, "<>=| ") {
+		// Anything but a plain lower bound, including <0.0.0 for "no fix".
+		fixed = ""
+	}
+	base := jsAdvisory{ID: id, Title: a.Title, URL: a.URL, Severity: severity.Of("npm-audit", a.Severity, a.CVSS.VectorString), Label: a.Severity, CVSS: a.CVSS.VectorString, Package: a.ModuleName,
+		Vulnerable: a.VulnerableVersions, Fixed: fixed, CWEs: cwes, CVEs: a.CVEs}
+	if len(a.Findings) == 0 {
+		return []jsAdvisory{base}
+	}
+	out := make([]jsAdvisory, 0, len(a.Findings))
+	for _, f := range a.Findings {
+		adv := base
+		adv.Installed, adv.Paths = f.Version, f.Paths
+		out = append(out, adv)
+	}
+	return out
+}
+
+var ghsaPattern = regexp.MustCompile(`GHSA(-[23456789cfghjmpqrvwx]{4}){3}`)
+
+// advisoryID prefers the GitHub advisory ID in the advisory's URL over the
+// registry's numeric ID, which differs between registries.
+func advisoryID(url, fallback string) string {
+	if id := ghsaPattern.FindString(url); id != "" {
+		return id
+	}
+	return fallback
+}
+
+// fixedFromRange derives the first fixed version from a vulnerable range
+// such as "<1.2.3" or ">=2.0.0 <2.1.4 || >=3.0.0 <3.0.2": the lowest upper
+// bound above the installed version. Ranges without an exclusive upper
+// bound have no known fix.
+func fixedFromRange(rng, installed string) string {
+	best := ""
+	for _, alt := range strings.Split(rng, "||") {
+		for _, tok := range strings.Fields(alt) {
+			if !strings.HasPrefix(tok, "<") || strings.HasPrefix(tok, "<=") {
+				continue
+			}
+			v := strings.TrimPrefix(tok, "<")
+			if installed != "" && compareVersions(v, installed) <= 0 {
+				continue
+			}
+			if best == "" || compareVersions(v, best) < 0 {
+				best = v
+			}
+		}
+	}
+	return best
+}
+
+// compareVersions orders dotted numeric versions, ignoring prerelease and
+// build suffixes.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) [3]int {
+	var out [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	for i, p := range strings.SplitN(v, ".", 3) {
+		out[i], _ = strconv.Atoi(p)
+	}
+	return out
+}
+
+func (j *scanJob) addJSAdvisories(l jsLockfile, advisories []jsAdvisory) {
+	for _, a := range advisories {
+		parts := map[string]string{"id": a.ID, "package": a.Package, "installed": a.Installed, "fixed": a.Fixed, "manager": l.Manager, "target": l.Path, "severity": a.Severity}
+		title := j.titles.render(titleNPMAudit, parts)
+		desc := a.Title
+		paths := a.Paths
+		if len(paths) > maxAdvisoryPaths {
+			paths = paths[:maxAdvisoryPaths]
+		}
+		if paths == nil {
+			paths = []string{}
+		}
+		target := l.Path
+		fpv := j.fp("npm-audit", a.ID, a.Package, a.Installed, l.Path)
+		j.add(finding{Tool: "npm-audit", Severity: a.Severity, label: a.Label, cvss: a.CVSS, Title: title, FilePath: &target, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"id":            a.ID,
+			"pkg":           a.Package,
+			"installed":     a.Installed,
+			"fixed":         a.Fixed,
+			"vulnerable":    a.Vulnerable,
+			"manager":       l.Manager,
+			"cwe":           a.CWEs,
+			"cve":           a.CVEs,
+			"url":           a.URL,
+			"paths":         paths,
+			"fix_available": a.FixAvailable,
+			"title_parts":   parts,
+		}})
+	}
+}
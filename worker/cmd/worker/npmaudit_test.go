@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindJSLockfiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json":                "{}",
+		"package-lock.json":           "{}",
+		"web/package.json":            "{}",
+		"web/pnpm-lock.yaml":          "lockfileVersion: '9.0'\n",
+		"legacy/package.json":         "{}",
+		"legacy/yarn.lock":            "# yarn lockfile v1\n",
+		"berry/package.json":          "{}",
+		"berry/yarn.lock":             "# This file is generated by running \"yarn install\"\n\n__metadata:\n  version: 8\n",
+		"orphan/package-lock.json":    "{}",
+		"node_modules/x/yarn.lock":    "",
+		"node_modules/x/package.json": "{}",
+	}
+	for f, body := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []jsLockfile{{"legacy/yarn.lock", managerYarn}, {"package-lock.json", managerNPM}, {"web/pnpm-lock.yaml", managerPNPM}}
+	if got := findJSLockfiles(dir); !reflect.DeepEqual(got, want) {
+		t.Fatalf("lockfiles = %v", got)
+	}
+}
+
+func TestParseNPMAudit(t *testing.T) {
+	out := []byte(`{"auditReportVersion":2,"vulnerabilities":{
+		"minimist":{"name":"minimist","severity":"critical","isDirect":false,
+			"via":[{"source":1096465,"name":"minimist","dependency":"minimist","title":"Prototype Pollution in minimist","url":"https://github.com/advisories/GHSA-xvch-5gv4-984h","severity":"critical","cwe":["CWE-1321"],"range":"<0.2.4 || >=1.0.0 <1.2.6"}],
+			"effects":["mkdirp"],"range":"<=0.2.3 || 1.0.0 - 1.2.5","nodes":["node_modules/minimist","node_modules/mkdirp/node_modules/minimist"],
+			"fixAvailable":{"name":"mkdirp","version":"0.5.6","isSemVerMajor":false}},
+		"mkdirp":{"name":"mkdirp","severity":"critical","isDirect":true,"via":["minimist"],"nodes":["node_modules/mkdirp"],"fixAvailable":true}}}`)
+	versions := map[string]string{"node_modules/minimist": "1.2.5", "node_modules/mkdirp/node_modules/minimist": "0.0.8", "node_modules/mkdirp": "0.5.1"}
+	got, err := parseJSAudit(out, versions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d advisories: %+v", len(got), got)
+	}
+	a := got[0]
+	if a.ID != "GHSA-xvch-5gv4-984h" || a.Package != "minimist" || a.Installed != "0.0.8" || a.Fixed != "0.2.4" || a.Severity != "CRITICAL" {
+		t.Errorf("advisory = %+v", a)
+	}
+	if got[1].Installed != "1.2.5" || got[1].Fixed != "1.2.6" || !reflect.DeepEqual(got[1].Paths, []string{"node_modules/minimist"}) {
+		t.Errorf("advisory = %+v", got[1])
+	}
+	if a.FixAvailable["name"] != "mkdirp" {
+		t.Errorf("fix = %v", a.FixAvailable)
+	}
+
+	if _, err := parseJSAudit([]byte(`{"error":{"code":"ENOLOCK","summary":"This command requires an existing lockfile."}}`), nil); err == nil {
+		t.Fatal("expected an npm error report to be rejected")
+	}
+}
+
+func TestParsePNPMAndYarnAudit(t *testing.T) {
+	advisory := `{"id":1097495,"github_advisory_id":"GHSA-p6mc-m468-83gw","title":"Prototype Pollution in lodash","module_name":"lodash","severity":"moderate",
+		"url":"https://github.com/advisories/GHSA-p6mc-m468-83gw","vulnerable_versions":">=3.7.0 <4.17.19","patched_versions":">=4.17.19",
+		"cves":["CVE-2020-8203"],"cwe":["CWE-770","CWE-1321"],"findings":[{"version":"4.17.15","paths":[".>lodash"]}]}`
+	pnpm := []byte(`{"actions":[],"advisories":{"1097495":` + advisory + `},"metadata":{}}`)
+	yarn := []byte(`{"type":"auditAdvisory","data":{"resolution":{"id":1097495,"path":"lodash","dev":false},"advisory":` + advisory + `}}
+{"type":"auditAdvisory","data":{"resolution":{"id":1097495,"path":"async>lodash","dev":false},"advisory":` + advisory + `}}
+{"type":"auditSummary","data":{"vulnerabilities":{"moderate":1}}}
+`)
+	for name, out := range map[string][]byte{"pnpm": pnpm, "yarn": yarn} {
+		got, err := parseJSAudit(out, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("%s: got %d advisories", name, len(got))
+		}
+		a := got[0]
+		if a.ID != "GHSA-p6mc-m468-83gw" || a.Installed != "4.17.15" || a.Fixed != "4.17.19" || a.Severity != "MEDIUM" || len(a.CWEs) != 2 {
+			t.Errorf("%s: advisory = %+v", name, a)
+		}
+	}
+
+	job := &scanJob{}
+	got, _ := parseJSAudit(pnpm, nil)
+	job.addJSAdvisories(jsLockfile{"web/pnpm-lock.yaml", managerPNPM}, got)
+	f := job.findings[0]
+	if f.Tool != "npm-audit" || f.Title != "GHSA-p6mc-m468-83gw in lodash" || *f.FilePath != "web/pnpm-lock.yaml" {
+		t.Errorf("finding = %+v", f)
+	}
+	if ev := f.Evidence.(map[string]any); ev["pkg"] != "lodash" || ev["fixed"] != "4.17.19" || ev["manager"] != "pnpm" {
+		t.Errorf("evidence = %v", ev)
+	}
+}
+
+func TestFixedFromRange(t *testing.T) {
+	cases := []struct{ rng, installed, want string }{
+		{"<1.2.3", "1.0.0", "1.2.3"},
+		{"<0.2.4 || >=1.0.0 <1.2.6", "1.2.5", "1.2.6"},
+		{"<0.2.4 || >=1.0.0 <1.2.6", "", "0.2.4"},
+		{"<=2.0.0", "1.0.0", ""},
+		{"*", "1.0.0", ""},
+	}
+	for _, c := range cases {
+		if got := fixedFromRange(c.rng, c.installed); got != c.want {
+			t.Errorf("fixedFromRange(%q, %q) = %q, want %q", c.rng, c.installed, got, c.want)
+		}
+	}
+}
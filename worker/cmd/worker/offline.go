@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+// checkOfflineBundles verifies at startup that the offline bundles exist and
+// are younger than offline.max_bundle_age, so an air-gapped worker fails fast
+// instead of scanning against a missing or years-old database.
+func checkOfflineBundles(cfg config.Config, now time.Time) error {
+	maxAge, _ := time.ParseDuration(cfg.OfflineMaxBundleAge)
+	fresh := func(name string, updated time.Time) error {
+		if maxAge > 0 && now.Sub(updated) > maxAge {
+			return fmt.Errorf("%s bundle last updated %s, older than offline.max_bundle_age (%s)", name, updated.UTC().Format(time.RFC3339), cfg.OfflineMaxBundleAge)
+		}
+		return nil
+	}
+
+	if cfg.ScannerEnabled("semgrep") {
+		updated, err := newestRuleFile(cfg.OfflineSemgrepRules)
+		if err != nil {
+			return fmt.Errorf("offline.semgrep_rules: %w", err)
+		}
+		if err := fresh("semgrep rules", updated); err != nil {
+			return err
+		}
+	}
+	// A DB mirror is pulled on every scan, so only a pre-seeded cache can
+	// go stale.
+	if cfg.ScannerEnabled("trivy") && cfg.OfflineTrivyDBRepository == "" {
+		updated, err := trivyDBUpdatedAt(cfg.OfflineTrivyCache)
+		if err != nil {
+			return fmt.Errorf("offline.trivy_cache_dir: %w", err)
+		}
+		if err := fresh("trivy DB", updated); err != nil {
+			return err
+		}
+	}
+	if cfg.ScannerEnabled("grype") {
+		built, err := grypeDBBuiltAt(cfg.OfflineGrypeDB)
+		if err != nil {
+			return fmt.Errorf("offline.grype_db_dir: %w", err)
+		}
+		if err := fresh("grype DB", built); err != nil {
+			return err
+		}
+	}
+	if cfg.ScannerEnabled("govulncheck") {
+		modified, err := govulnDBModified(cfg.OfflineGovulncheckDB)
+		if err != nil {
+			return fmt.Errorf("offline.govulncheck_db_dir: %w", err)
+		}
+		if err := fresh("Go vulnerability DB", modified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newestRuleFile returns the modification time of the newest YAML rule file
+// under path, which may be a single file or a directory.
+func newestRuleFile(path string) (time.Time, error) {
+	var newest time.Time
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if d.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if newest.IsZero() {
+		return time.Time{}, errors.New("no semgrep rule files (*.yml, *.yaml) found")
+	}
+	return newest, nil
+}
+
+// trivyDBUpdatedAt reads the build time trivy records next to its DB.
+func trivyDBUpdatedAt(cacheDir string) (time.Time, error) {
+	b, err := os.ReadFile(filepath.Join(cacheDir, "db", "metadata.json"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("trivy DB not found: %w", err)
+	}
+	var meta struct {
+		UpdatedAt time.Time `json:"UpdatedAt"`
+	}
+	if err := json.Unmarshal(b, &meta); err != nil || meta.UpdatedAt.IsZero() {
+		return time.Time{}, errors.New("trivy DB metadata.json is unreadable")
+	}
+	return meta.UpdatedAt, nil
+}
+
+// offlineSemgrepPacks maps registry packs onto the bundle: p/golang is read
+// from <offline.semgrep_rules>/golang.yml (or .yaml). Packs without a bundled
+// file are returned as missing.
+func offlineSemgrepPacks(dir string, packs []string) (found, missing []string) {
+	for _, p := range packs {
+		name := strings.TrimPrefix(p, "p/")
+		hit := ""
+		for _, ext := range []string{".yml", ".yaml"} {
+			path := filepath.Join(dir, name+ext)
+			if _, err := os.Stat(path); err == nil {
+				hit = path
+				break
+			}
+		}
+		if hit == "" {
+			missing = append(missing, p)
+			continue
+		}
+		found = append(found, hit)
+	}
+	return found, missing
+}
+
+// offlineTrivyArgs keeps trivy off the network: the vulnerability DB comes
+// from the local cache or the configured internal mirror.
+func offlineTrivyArgs(cfg config.Config) []string {
+	args := []string{"--cache-dir", cfg.OfflineTrivyCache, "--offline-scan", "--skip-java-db-update", "--skip-version-check"}
+	if cfg.OfflineTrivyDBRepository != "" {
+		return append(args, "--db-repository", cfg.OfflineTrivyDBRepository)
+	}
+	return append(args, "--skip-db-update")
+}
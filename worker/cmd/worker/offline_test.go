@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+func TestCheckOfflineBundles(t *testing.T) {
+	rules, cache, vulndb := t.TempDir(), t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(rules, "golang.yml"), []byte("rules: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(cache, "db"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	meta := `{"UpdatedAt":"` + now.Add(-48*time.Hour).UTC().Format(time.RFC3339) + `"}`
+	if err := os.WriteFile(filepath.Join(cache, "db", "metadata.json"), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(vulndb, "index"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	modified := `{"modified":"` + now.Add(-2*time.Hour).UTC().Format(time.RFC3339) + `"}`
+	if err := os.WriteFile(filepath.Join(vulndb, "index", "db.json"), []byte(modified), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Defaults()
+	cfg.Offline = true
+	cfg.OfflineSemgrepRules = rules
+	cfg.OfflineTrivyCache = cache
+	cfg.OfflineGovulncheckDB = vulndb
+	if err := checkOfflineBundles(cfg, now); err != nil {
+		t.Fatalf("fresh bundles rejected: %v", err)
+	}
+
+	cfg.OfflineMaxBundleAge = "24h"
+	if err := checkOfflineBundles(cfg, now); err == nil || !strings.Contains(err.Error(), "trivy DB") {
+		t.Fatalf("expected stale trivy DB, got %v", err)
+	}
+	cfg.OfflineTrivyDBRepository = "registry.internal/aquasec/trivy-db:2"
+	if err := checkOfflineBundles(cfg, now); err != nil {
+		t.Fatalf("a DB mirror should skip the cache age check: %v", err)
+	}
+
+	cfg.OfflineGovulncheckDB = t.TempDir()
+	if err := checkOfflineBundles(cfg, now); err == nil || !strings.Contains(err.Error(), "govulncheck_db_dir") {
+		t.Fatalf("expected missing Go vulnerability DB, got %v", err)
+	}
+	cfg.OfflineGovulncheckDB = vulndb
+
+	cfg.OfflineSemgrepRules = t.TempDir()
+	if err := checkOfflineBundles(cfg, now); err == nil {
+		t.Fatal("expected error for an empty rules bundle")
+	}
+}
+
+func TestOfflineSemgrepPacks(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"golang.yml", "docker.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, missing := offlineSemgrepPacks(dir, []string{"p/docker", "p/golang", "p/rust"})
+	want := []string{filepath.Join(dir, "docker.yaml"), filepath.Join(dir, "golang.yml")}
+	if !reflect.DeepEqual(found, want) || !reflect.DeepEqual(missing, []string{"p/rust"}) {
+		t.Fatalf("found=%v missing=%v", found, missing)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pip-audit checks the Python dependencies pinned in the clone's
+// requirements files and poetry.lock files against PyPI's advisories. Each
+// file's exact pins are copied into a requirements file outside the clone
+// and audited with pip disabled, so nothing is installed or resolved and no
+// setup.py runs. Unpinned requirements, includes and editable installs are
+// left out.
+
+// maxPythonLockfiles bounds the files one run audits.
+const maxPythonLockfiles = 50
+
+// pyPin is one exactly pinned package.
+type pyPin struct {
+	Name    string
+	Version string
+}
+
+func (wk *Worker) runPipAudit(ctx context.Context, job *scanJob) error {
+	files := findPythonLockfiles(job.dir)
+	if len(files) == 0 {
+		return nil
+	}
+	tmp := filepath.Join(filepath.Dir(job.dir), "pip-audit")
+	if err := os.MkdirAll(tmp, 0o700); err != nil {
+		return err
+	}
+	env := wk.cfg.ScannerProxySetting().Environ()
+	var errs []error
+	for i, f := range files {
+		pins, err := pythonPins(filepath.Join(job.dir, f))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+			continue
+		}
+		if len(pins) == 0 {
+			continue
+		}
+		reqs := filepath.Join(tmp, "requirements-"+strconv.Itoa(i)+".txt")
+		if err := writePins(reqs, pins); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		args := []string{"-r", reqs, "--disable-pip", "--no-deps", "--format", "json", "--progress-spinner", "off"}
+		out, err := runCmdJSON(ctx, "pip-audit", args, job.dir, env)
+		wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "pip-audit-"+strconv.Itoa(i)+".json", "application/json", out)
+		var parsed pipAuditOut
+		if perr := json.Unmarshal(out, &parsed); perr != nil {
+			if err == nil {
+				err = fmt.Errorf("pip-audit parse error in %s: %v", f, perr)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		// pip-audit exits non-zero whenever it reports anything.
+		job.addPipAuditResults(f, parsed)
+	}
+	return errors.Join(errs...)
+}
+
+// findPythonLockfiles returns the clone's requirements*.txt and poetry.lock
+// files, relative to it and sorted.
+func findPythonLockfiles(dir string) []string {
+	var out []string
+	seen := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > maxDetectFiles || len(out) >= maxPythonLockfiles {
+			return filepath.SkipAll
+		}
+		if !d.Type().IsRegular() || !isPythonLockfile(d.Name()) {
+			return nil
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out
+}
+
+func isPythonLockfile(name string) bool {
+	return name == "poetry.lock" || strings.HasPrefix(name, "requirements") && strings.HasSuffix(name, ".txt")
+}
+
+// pythonPins reads the exact pins from a requirements file or poetry.lock.
+func pythonPins(path string) ([]pyPin, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if filepath.Base(path) == "poetry.lock" {
+		return poetryPins(bufio.NewScanner(f))
+	}
+	return requirementPins(bufio.NewScanner(f))
+}
+
+// requirementPin matches name[extras]==version, before any marker or hash.
+var requirementPin = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*===?\s*([A-Za-z0-9][A-Za-z0-9.+!_-]*)\s*(?:;.*)?$`)
+
+func requirementPins(sc *bufio.Scanner) ([]pyPin, error) {
+	var pins []pyPin
+	var line string
+	for sc.Scan() {
+		text := sc.Text()
+		if i := strings.Index(text, " #"); i >= 0 {
+			text = text[:i]
+		}
+		if strings.HasSuffix(strings.TrimSpace(text), `\`) {
+			line += strings.TrimSuffix(strings.TrimSpace(text), `\`) + " "
+			continue
+		}
+		line += text
+		// Hashes follow the pin as options on the same logical line.
+		if i := strings.Index(line, " --"); i >= 0 {
+			line = line[:i]
+		}
+		if m := requirementPin.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			pins = append(pins, pyPin{m[1], m[2]})
+		}
+		line = ""
+	}
+	return pins, sc.Err()
+}
+
+// poetryPins reads the name and version of each [[package]] in a
+// poetry.lock; the lockfile pins every package, including transitive ones.
+func poetryPins(sc *bufio.Scanner) ([]pyPin, error) {
+	var pins []pyPin
+	var cur *pyPin
+	flush := func() {
+		if cur != nil && cur.Name != "" && cur.Version != "" {
+			pins = append(pins, *cur)
+		}
+		cur = nil
+	}
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "[[package]]":
+			flush()
+			cur = &pyPin{}
+		case strings.HasPrefix(line, "["):
+			// A sub-table such as [package.dependencies] ends the keys.
+			flush()
+		case cur != nil:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			value, err := strconv.Unquote(strings.TrimSpace(value))
+			if err != nil {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "name":
+				cur.Name = value
+			case "version":
+				cur.Version = value
+			}
+		}
+	}
+	flush()
+	return pins, sc.Err()
+}
+
+func writePins(path string, pins []pyPin) error {
+	var b strings.Builder
+	for _, p := range pins {
+		b.WriteString(p.Name + "==" + p.Version + "\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o600)
+}
+
+type pipAuditOut struct {
+	Dependencies []struct {
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		SkipReason string `json:"skip_reason"`
+		Vulns      []struct {
+			ID          string   `json:"id"`
+			FixVersions []string `json:"fix_versions"`
+			Aliases     []string `json:"aliases"`
+			Description string   `json:"description"`
+		} `json:"vulns"`
+	} `json:"dependencies"`
+}
+
+// addPipAuditResults adds one finding per advisory and pinned package.
+// PyPI's advisories carry no severity, so every finding is MEDIUM.
+func (j *scanJob) addPipAuditResults(file string, parsed pipAuditOut) {
+	for _, d := range parsed.Dependencies {
+		for _, v := range d.Vulns {
+			fixed := strings.Join(v.FixVersions, ", ")
+			parts := map[string]string{"id": v.ID, "package": d.Name, "installed": d.Version, "fixed": fixed, "target": file, "severity": "MEDIUM"}
+			title := j.titles.render(titlePipAudit, parts)
+			desc := v.Description
+			target := file
+			aliases := v.Aliases
+			if aliases == nil {
+				aliases = []string{}
+			}
+			fpv := j.fp("pip-audit", v.ID, d.Name, d.Version, file)
+			j.add(finding{Tool: "pip-audit", Severity: "MEDIUM", Title: title, FilePath: &target, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+				"id":          v.ID,
+				"pkg":         d.Name,
+				"installed":   d.Version,
+				"fixed":       fixed,
+				"aliases":     aliases,
+				"url":         pythonAdvisoryURL(v.ID),
+				"title_parts": parts,
+			}})
+		}
+	}
+}
+
+// pythonAdvisoryURL links an advisory ID to its record.
+func pythonAdvisoryURL(id string) string {
+	switch {
+	case strings.HasPrefix(id, "GHSA-"):
+		return "https://github.com/advisories/" + id
+	case strings.HasPrefix(id, "PYSEC-"), strings.HasPrefix(id, "OSV-"):
+		return "https://osv.dev/vulnerability/" + id
+	}
+	return ""
+}
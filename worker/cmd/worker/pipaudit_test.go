@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindPythonLockfiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"requirements.txt", "requirements-dev.txt", "svc/poetry.lock", "svc/pyproject.toml", "vendor/requirements.txt", "docs/requirements.md"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"requirements-dev.txt", "requirements.txt", "svc/poetry.lock"}
+	if got := findPythonLockfiles(dir); !reflect.DeepEqual(got, want) {
+		t.Fatalf("files = %v", got)
+	}
+}
+
+func TestPythonPins(t *testing.T) {
+	dir := t.TempDir()
+	reqs := filepath.Join(dir, "requirements.txt")
+	if err := os.WriteFile(reqs, []byte(`# app
+-r base.txt
+-e .
+--index-url https://pypi.internal/simple
+Django==4.2.1  # pinned
+requests[socks]==2.31.0 ; python_version >= "3.8"
+flask>=2.0
+urllib3==1.26.5 \
+    --hash=sha256:abc \
+    --hash=sha256:def
+git+https://github.com/example/lib.git@v1#egg=lib
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pythonPins(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pyPin{{"Django", "4.2.1"}, {"requests", "2.31.0"}, {"urllib3", "1.26.5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("pins = %v", got)
+	}
+
+	lock := filepath.Join(dir, "poetry.lock")
+	if err := os.WriteFile(lock, []byte(`[[package]]
+name = "jinja2"
+version = "3.1.2"
+description = "A very fast and expressive template engine."
+optional = false
+
+[package.dependencies]
+MarkupSafe = ">=2.0"
+
+[[package]]
+name = "markupsafe"
+version = "2.1.3"
+
+[metadata]
+lock-version = "2.0"
+content-hash = "abc"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = pythonPins(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []pyPin{{"jinja2", "3.1.2"}, {"markupsafe", "2.1.3"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("pins = %v", got)
+	}
+}
+
+func TestAddPipAuditResults(t *testing.T) {
+	var parsed pipAuditOut
+	out := []byte(`{"dependencies":[
+		{"name":"jinja2","version":"3.1.2","vulns":[{"id":"GHSA-h5c8-rqwp-cp95","fix_versions":["3.1.3"],"aliases":["CVE-2024-22195"],"description":"The xmlattr filter accepts keys containing spaces."}]},
+		{"name":"markupsafe","version":"2.1.3","vulns":[]},
+		{"name":"internal-lib","skip_reason":"Dependency not found on PyPI and could not be audited"}],"fixes":[]}`)
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addPipAuditResults("svc/poetry.lock", parsed)
+	if len(job.findings) != 1 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	if f.Tool != "pip-audit" || f.Severity != "MEDIUM" || f.Title != "GHSA-h5c8-rqwp-cp95 in jinja2" || *f.FilePath != "svc/poetry.lock" {
+		t.Errorf("finding = %+v", f)
+	}
+	ev := f.Evidence.(map[string]any)
+	if ev["pkg"] != "jinja2" || ev["fixed"] != "3.1.3" || ev["url"] != "https://github.com/advisories/GHSA-h5c8-rqwp-cp95" || !reflect.DeepEqual(ev["aliases"], []string{"CVE-2024-22195"}) {
+		t.Errorf("evidence = %v", ev)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job stages besides the scanner names.
+const (
+	stageCloning    = "cloning"
+	stageExtracting = "extracting"
+	stagePersisting = "persisting"
+	stageDone       = "done"
+)
+
+// progress records which stages a job is in and how long each finished stage
+// took. Scanners run concurrently, so several stages can be open at once;
+// the stage reported is then their names joined by commas. Progress is the
+// share of planned stages already finished. Failing to record it is logged,
+// never fatal to the job.
+type progress struct {
+	st      store
+	jobID   string
+	planned []string
+
+	mu      sync.Mutex
+	timings map[string]int64
+	running map[string]time.Time
+	current string
+}
+
+func newProgress(st store, jobID string, planned []string) *progress {
+	return &progress{st: st, jobID: jobID, planned: planned, timings: map[string]int64{}, running: map[string]time.Time{}}
+}
+
+// enter closes every open stage and starts stage.
+func (p *progress) enter(ctx context.Context, stage string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for s := range p.running {
+		p.close(s)
+	}
+	p.running[stage] = time.Now()
+	p.save(ctx)
+}
+
+// begin starts stage alongside any already open.
+func (p *progress) begin(ctx context.Context, stage string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running[stage] = time.Now()
+	p.save(ctx)
+}
+
+// end closes stage.
+func (p *progress) end(ctx context.Context, stage string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.close(stage)
+	p.save(ctx)
+}
+
+// done closes the open stages and records the job as complete.
+func (p *progress) done(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for s := range p.running {
+		p.close(s)
+	}
+	p.current = stageDone
+	if err := p.st.SetStage(ctx, p.jobID, p.current, 100, p.timings); err != nil {
+		slog.WarnContext(ctx, "could not record job stage", "stage", p.current, "err", err)
+	}
+}
+
+// snapshot returns the current stage and a copy of the timings.
+func (p *progress) snapshot() (string, map[string]int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	timings := make(map[string]int64, len(p.timings))
+	for s, ms := range p.timings {
+		timings[s] = ms
+	}
+	return p.current, timings
+}
+
+// close and save are called with mu held.
+func (p *progress) close(stage string) {
+	if started, ok := p.running[stage]; ok {
+		p.timings[stage] += time.Since(started).Milliseconds()
+		delete(p.running, stage)
+	}
+}
+
+func (p *progress) save(ctx context.Context) {
+	open := make([]string, 0, len(p.running))
+	for s := range p.running {
+		open = append(open, s)
+	}
+	sort.Strings(open)
+	if len(open) > 0 {
+		p.current = strings.Join(open, ",")
+	}
+	finished := 0
+	for _, s := range p.planned {
+		if _, ok := p.timings[s]; ok {
+			finished++
+		}
+	}
+	pct := finished * 100 / len(p.planned)
+	if err := p.st.SetStage(ctx, p.jobID, p.current, pct, p.timings); err != nil {
+		slog.WarnContext(ctx, "could not record job stage", "stage", p.current, "err", err)
+	}
+}
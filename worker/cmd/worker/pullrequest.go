@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"argus/internal/forge"
+)
+
+// pullRequest is the pull request a scan reports back to, with the
+// installation token to do it with.
+type pullRequest struct {
+	token, owner, repo string
+	number             int
+	sha                string // the head commit the job scanned
+}
+
+// reportPullRequest posts a pull request scan's results to GitHub through
+// the app installation the repo was cloned with: a check run of the
+// findings the default branch does not have, and review comments on the
+// lines that brought in findings no earlier scan reported. Merge requests on
+// gitlab.url are reported by reportMergeRequest. Nothing posted here fails
+// the job; problems are logged.
+func (wk *Worker) reportPullRequest(ctx context.Context, jobID string, spec jobSpec, repo RepoRow, job *scanJob) {
+	if spec.PullRequest == 0 || spec.CommitSHA == "" {
+		return
+	}
+	if project, ok := forge.GitLab(wk.cfg.GitLabURL).ProjectPath(repo.URL); ok {
+		wk.reportMergeRequest(ctx, jobID, spec, project)
+		return
+	}
+	if wk.github == nil || !(wk.cfg.GitHubCheckRuns || wk.cfg.GitHubReviewComments) {
+		return
+	}
+	id := wk.installationID(repo)
+	owner, name, ok := parseGitHubRepo(repo.URL)
+	if id == 0 || !ok {
+		return
+	}
+	var added, fresh []finding
+	var err error
+	if wk.cfg.GitHubCheckRuns {
+		if added, err = wk.store.BranchFindings(ctx, jobID); err != nil {
+			slog.WarnContext(ctx, "pull request not reported: branch findings", "err", err)
+			return
+		}
+	}
+	if wk.cfg.GitHubReviewComments {
+		if fresh, err = wk.store.NewFindings(ctx, jobID); err != nil {
+			slog.WarnContext(ctx, "pull request not reported: new findings", "err", err)
+			return
+		}
+	}
+	token, err := wk.github.InstallationToken(ctx, id)
+	if err != nil {
+		slog.WarnContext(ctx, "pull request not reported: installation token", "installation_id", id, "err", err)
+		return
+	}
+	pr := pullRequest{token: token, owner: owner, repo: name, number: spec.PullRequest, sha: spec.CommitSHA}
+	if wk.cfg.GitHubCheckRuns {
+		wk.postCheckRun(ctx, pr, jobID, job, added)
+	}
+	if wk.cfg.GitHubReviewComments && len(fresh) > 0 {
+		wk.postReviewComments(ctx, pr, fresh)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+// Jobs are moved from the priority queues into flight, owned by this worker
+// process, and dropped only once handled. A worker that dies leaves its
+// messages in flight; the reaper returns them to their queue once the
+// owner's heartbeat has expired. Workers also register with the queue, which
+// the API uses to list them and to recover jobs left running by a worker
+// that died.
+const (
+	aliveTTL   = 30 * time.Second
+	aliveEvery = 10 * time.Second
+	reapEvery  = time.Minute
+	// takeWait bounds how long an idle consumer blocks before checking
+	// every queue again.
+	takeWait = time.Second
+)
+
+// Queue backends, selected by queue.backend.
+const (
+	backendRedis    = config.QueueRedis
+	backendPostgres = config.QueuePostgres
+)
+
+// jobQueue is the transport jobs arrive on. Messages are the raw JSON of a
+// JobMsg; the API pushes them and the worker takes, acks, defers and
+// dead-letters them. Backends keep the same semantics: a taken message stays
+// in flight under its owner until acked, and reclaimed messages go to the
+// front of their queue.
+type jobQueue interface {
+	// Take blocks until a message is available and moves it in flight,
+	// preferring higher priorities.
+	Take(ctx context.Context) (string, error)
+	// Ack drops a handled in-flight message.
+	Ack(ctx context.Context, raw string) error
+	// Reclaim returns every message owner has in flight to its queue.
+	Reclaim(ctx context.Context, owner string) (int, error)
+	// Defer parks raw until due, then queues it.
+	Defer(ctx context.Context, raw string, due time.Time) error
+	// Promote queues deferred messages whose time has come.
+	Promote(ctx context.Context) (int, error)
+	// Bury adds an entry to the dead-letter list.
+	Bury(ctx context.Context, entry []byte) error
+	// Heartbeat registers info and keeps its owner alive for aliveTTL.
+	Heartbeat(ctx context.Context, info workerInfo) error
+	// Deregister drops a stopping worker's registration and liveness.
+	Deregister(ctx context.Context, name string) error
+	// ReapDead reclaims the messages of workers that are no longer alive
+	// and drops their registrations.
+	ReapDead(ctx context.Context) error
+	Ping(ctx context.Context) error
+	// Close releases the backend's connections.
+	Close() error
+	// Name is the backend's name, as in queue.backend.
+	Name() string
+}
+
+// Priorities, drained in this order. normal uses queue.jobs itself so
+// messages from older API versions keep working.
+const (
+	priorityHigh   = "high"
+	priorityNormal = "normal"
+	priorityLow    = "low"
+)
+
+// Jobs are also split by size class so workers can be pooled by capacity:
+// small and large jobs get their own queues, <queue>:small and
+// <queue>:large, each with the same priorities. medium uses queue.jobs, so
+// messages without a size class land there. A worker takes only the classes
+// in worker.size_classes.
+const (
+	sizeSmall  = "small"
+	sizeMedium = "medium"
+	sizeLarge  = "large"
+)
+
+var allSizeClasses = []string{sizeSmall, sizeMedium, sizeLarge}
+
+// queueKey is the queue for jobs of a size class and priority under base,
+// the configured queue.jobs.
+func queueKey(base, class, priority string) string {
+	key := base
+	if class == sizeSmall || class == sizeLarge {
+		key += ":" + class
+	}
+	if priority == priorityHigh || priority == priorityLow {
+		key += ":" + priority
+	}
+	return key
+}
+
+// queueKeys returns the queues for classes in drain order: by priority, then
+// by size class in the order given.
+func queueKeys(base string, classes []string) []string {
+	var keys []string
+	for _, p := range []string{priorityHigh, priorityNormal, priorityLow} {
+		for _, c := range classes {
+			keys = append(keys, queueKey(base, c, p))
+		}
+	}
+	return keys
+}
+
+// messageRoute returns the queue and org raw belongs in. Messages that do
+// not parse go to the normal queue, where the worker dead-letters them.
+func messageRoute(base, raw string) (key, org string) {
+	var msg JobMsg
+	if json.Unmarshal([]byte(raw), &msg) != nil {
+		return base, ""
+	}
+	return queueKey(base, msg.Size, msg.Priority), msg.Org
+}
+
+// workerInfo is a worker process's entry in the registry, keyed by its name.
+// StartedAt tells a restarted process apart from the one before it, which
+// may have had the same name.
+type workerInfo struct {
+	Name        string    `json:"name"`
+	Host        string    `json:"host"`
+	PID         int       `json:"pid"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	Concurrency int       `json:"concurrency"`
+	Scanners    []string  `json:"scanners"`
+	ResultsMode string    `json:"results_mode"`
+}
+
+// ack drops a handled message from flight.
+func (wk *Worker) ack(ctx context.Context, raw string) {
+	if err := wk.queue.Ack(ctx, raw); err != nil {
+		slog.WarnContext(ctx, "queue ack failed", "err", err)
+	}
+}
+
+// keepAlive refreshes this worker's heartbeat and registry entry until ctx
+// is cancelled.
+func (wk *Worker) keepAlive(ctx context.Context) {
+	t := time.NewTicker(aliveEvery)
+	defer t.Stop()
+	info := workerInfo{
+		Name:        wk.owner.name(),
+		Host:        wk.owner.hostname,
+		PID:         wk.owner.pid,
+		StartedAt:   time.Now().UTC(),
+		Concurrency: wk.cfg.Concurrency,
+		Scanners:    wk.cfg.Scanners,
+		ResultsMode: wk.cfg.ResultsMode,
+	}
+	for {
+		info.HeartbeatAt = time.Now().UTC()
+		if err := wk.queue.Heartbeat(ctx, info); err != nil && ctx.Err() == nil {
+			slog.Warn("worker liveness refresh failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// runReaper periodically requeues messages held by dead workers.
+func (wk *Worker) runReaper(ctx context.Context) {
+	t := time.NewTicker(reapEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := wk.queue.ReapDead(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("queue reaper failed", "err", err)
+		}
+	}
+}
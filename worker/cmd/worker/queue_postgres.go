@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgQueue keeps messages in the queue_messages table, for deployments
+// without Redis. A taken message is claimed with FOR UPDATE SKIP LOCKED and
+// stays in the table with owner set until acked; deferred messages wait with
+// available_at in the future. Pushes NOTIFY queueChannel, which wakes idle
+// consumers; they also poll every takeWait, so a missed notification only
+// delays a job.
+//
+// Within a priority, the next job goes to the org with the fewest jobs in
+// flight relative to its queue weight, read from the orgs table, and then
+// in order of arrival.
+type pgQueue struct {
+	db      *pgxpool.Pool
+	base    string   // queue.jobs
+	owner   string   // this worker process
+	classes []string // size classes this worker takes
+	wake    chan struct{}
+}
+
+// queueChannel is the LISTEN/NOTIFY channel pushes are announced on. The
+// API notifies it too.
+const queueChannel = "argus_queue"
+
+// newPGQueue starts listening for pushes until ctx is cancelled. consumers
+// is how many Take calls may block at once.
+func newPGQueue(ctx context.Context, db *pgxpool.Pool, base, owner string, classes []string, consumers int) *pgQueue {
+	q := &pgQueue{db: db, base: base, owner: owner, classes: classes, wake: make(chan struct{}, consumers)}
+	go q.listen(ctx)
+	return q
+}
+
+func (q *pgQueue) Name() string { return backendPostgres }
+
+// Close does nothing: the pool is the worker's and main closes it.
+func (q *pgQueue) Close() error { return nil }
+
+func (q *pgQueue) Ping(ctx context.Context) error {
+	return q.db.Ping(ctx)
+}
+
+// listen holds one connection in LISTEN and wakes a blocked consumer per
+// notification, reconnecting after errors.
+func (q *pgQueue) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := q.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("queue listener stopped; consumers poll until it reconnects", "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (q *pgQueue) listenOnce(ctx context.Context) error {
+	conn, err := q.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "LISTEN "+queueChannel); err != nil {
+		return err
+	}
+	for {
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			return err
+		}
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// takeSQL claims the next waiting message among the queues in $1, in their
+// order. Messages reclaimed from a dead worker keep taken_at and go first.
+// The jobs in flight are counted per org once, not once per waiting message.
+const takeSQL = `UPDATE queue_messages SET owner=$2, taken_at=now()
+	WHERE id = (
+		WITH inflight AS (SELECT org, count(*) AS n FROM queue_messages WHERE owner IS NOT NULL GROUP BY org)
+		SELECT c.id FROM queue_messages c LEFT JOIN orgs o ON o.name = c.org LEFT JOIN inflight f ON f.org = c.org
+		WHERE c.queue = ANY($1) AND c.owner IS NULL AND c.available_at <= now()
+		ORDER BY array_position($1, c.queue), c.taken_at IS NULL,
+			COALESCE(f.n, 0)::float8 / GREATEST(COALESCE(o.queue_weight, 1), 1),
+			c.id
+		LIMIT 1 FOR UPDATE OF c SKIP LOCKED)
+	RETURNING payload`
+
+func (q *pgQueue) Take(ctx context.Context) (string, error) {
+	keys := queueKeys(q.base, q.classes)
+	for {
+		var raw string
+		err := q.db.QueryRow(ctx, takeSQL, keys, q.owner).Scan(&raw)
+		if err == nil {
+			return raw, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-q.wake:
+		case <-time.After(takeWait):
+		}
+	}
+}
+
+func (q *pgQueue) Ack(ctx context.Context, raw string) error {
+	_, err := q.db.Exec(ctx, `DELETE FROM queue_messages WHERE id = (
+		SELECT id FROM queue_messages WHERE owner=$1 AND payload=$2 LIMIT 1)`, q.owner, raw)
+	return err
+}
+
+func (q *pgQueue) Reclaim(ctx context.Context, owner string) (int, error) {
+	tag, err := q.db.Exec(ctx, `UPDATE queue_messages SET owner=NULL, available_at=now() WHERE owner=$1`, owner)
+	if err != nil || tag.RowsAffected() == 0 {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), q.notify(ctx)
+}
+
+// notify wakes idle consumers after messages became available.
+func (q *pgQueue) notify(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, `SELECT pg_notify($1, '')`, queueChannel)
+	return err
+}
+
+func (q *pgQueue) Defer(ctx context.Context, raw string, due time.Time) error {
+	key, org := messageRoute(q.base, raw)
+	_, err := q.db.Exec(ctx, `INSERT INTO queue_messages (queue, org, payload, available_at) VALUES ($1, $2, $3, $4)`, key, org, raw, due)
+	return err
+}
+
+// Promote has nothing to do: deferred rows become available to Take when
+// their time comes.
+func (q *pgQueue) Promote(context.Context) (int, error) {
+	return 0, nil
+}
+
+func (q *pgQueue) Bury(ctx context.Context, entry []byte) error {
+	tx, err := q.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `INSERT INTO queue_dead_letters (queue, entry) VALUES ($1, $2)`, q.base, string(entry)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM queue_dead_letters WHERE queue=$1 AND id <= (
+		SELECT id FROM queue_dead_letters WHERE queue=$1 ORDER BY id DESC OFFSET $2 LIMIT 1)`, q.base, maxDeadLetters); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Heartbeat stamps the entry with the database's clock, which the reaper
+// and the API compare against, so worker clock skew does not matter.
+func (q *pgQueue) Heartbeat(ctx context.Context, info workerInfo) error {
+	entry, _ := json.Marshal(info)
+	_, err := q.db.Exec(ctx, `INSERT INTO queue_workers (queue, name, info, heartbeat_at) VALUES ($1, $2, $3, now())
+		ON CONFLICT (queue, name) DO UPDATE SET info=EXCLUDED.info, heartbeat_at=now()`, q.base, info.Name, entry)
+	return err
+}
+
+func (q *pgQueue) Deregister(ctx context.Context, name string) error {
+	_, err := q.db.Exec(ctx, `DELETE FROM queue_workers WHERE queue=$1 AND name=$2`, q.base, name)
+	return err
+}
+
+func (q *pgQueue) ReapDead(ctx context.Context) error {
+	rows, err := q.db.Query(ctx, `UPDATE queue_messages m SET owner=NULL, available_at=now()
+		WHERE m.queue = ANY($1) AND m.owner IS NOT NULL AND m.owner <> $3 AND NOT EXISTS (
+			SELECT 1 FROM queue_workers w WHERE w.queue=$2 AND w.name=m.owner AND w.heartbeat_at > now() - make_interval(secs => $4))
+		RETURNING m.owner`, queueKeys(q.base, allSizeClasses), q.base, q.owner, aliveTTL.Seconds())
+	if err != nil {
+		return err
+	}
+	requeued := map[string]int{}
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			rows.Close()
+			return err
+		}
+		requeued[owner]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for owner, n := range requeued {
+		slog.Warn("requeued jobs from dead worker", "owner", owner, "jobs", n)
+	}
+	if len(requeued) > 0 {
+		if err := q.notify(ctx); err != nil {
+			return err
+		}
+	}
+	_, err = q.db.Exec(ctx, `DELETE FROM queue_workers WHERE queue=$1 AND heartbeat_at <= now() - make_interval(secs => $2)`, q.base, aliveTTL.Seconds())
+	return err
+}
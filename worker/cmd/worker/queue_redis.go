@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisQueue keeps each queue in a Redis list. Taken messages move to
+// <queue>:processing:<owner>; each worker refreshes <queue>:alive:<owner>
+// and its entry in the <queue>:workers hash.
+type redisQueue struct {
+	rdb     *redis.Client
+	base    string   // queue.jobs
+	owner   string   // this worker process
+	classes []string // size classes this worker takes
+}
+
+func newRedisQueue(rdb *redis.Client, base, owner string, classes []string) *redisQueue {
+	return &redisQueue{rdb: rdb, base: base, owner: owner, classes: classes}
+}
+
+func (q *redisQueue) Name() string { return backendRedis }
+
+func (q *redisQueue) Close() error { return q.rdb.Close() }
+
+func (q *redisQueue) Ping(ctx context.Context) error {
+	return q.rdb.Ping(ctx).Err()
+}
+
+// Within each priority, jobs wait in one list per org, <queue>:org:<org>, so
+// one org's backlog cannot starve the others. <queue>:orgs is a sorted set of
+// the orgs with waiting jobs, scored by virtual time: taking a job from an
+// org advances its score by 1/weight, and the org with the lowest score goes
+// next. An org that becomes active starts at the score of the org served
+// last (<queue>:vclock), so it neither jumps the line nor waits behind the
+// others' backlog. Weights are set by admins through the API and kept in
+// <queue.jobs>:org_weights; the default is 1. The priority list itself
+// still takes messages without an org (from older API versions) and is
+// drained before the org lists.
+
+func (q *redisQueue) weightsKey() string {
+	return q.base + ":org_weights"
+}
+
+// takeNext moves the next message into the processing list (the last key).
+// KEYS are the priority lists in drain order, then the weights hash. Org
+// lists are derived from the priority list names.
+var takeNext = redis.NewScript(`
+local processing, weights = KEYS[#KEYS], KEYS[#KEYS - 1]
+for i = 1, #KEYS - 2 do
+  local m = redis.call('LMOVE', KEYS[i], processing, 'RIGHT', 'LEFT')
+  if m then return m end
+  local orgs = KEYS[i] .. ':orgs'
+  while true do
+    local head = redis.call('ZRANGE', orgs, 0, 0, 'WITHSCORES')
+    if #head == 0 then break end
+    local org, score = head[1], tonumber(head[2])
+    local list = KEYS[i] .. ':org:' .. org
+    m = redis.call('LMOVE', list, processing, 'RIGHT', 'LEFT')
+    if m then
+      redis.call('SET', KEYS[i] .. ':vclock', score)
+      if redis.call('LLEN', list) == 0 then
+        redis.call('ZREM', orgs, org)
+      else
+        local w = tonumber(redis.call('HGET', weights, org))
+        if not w or w <= 0 then w = 1 end
+        redis.call('ZADD', orgs, score + 1 / w, org)
+      end
+      return m
+    end
+    redis.call('ZREM', orgs, org)
+  end
+end
+return false
+`)
+
+// priorityKey is Lua that sets key to the list for message m: its org's list
+// in the queue for its size class and priority, derived like queueKey from
+// queue.jobs in KEYS[1]. A message for an org that had no waiting jobs
+// activates the org at the current virtual time.
+const priorityKey = `
+local key = KEYS[1]
+local ok, msg = pcall(cjson.decode, m)
+if ok and type(msg) == 'table' then
+  if msg.size == 'small' or msg.size == 'large' then key = key .. ':' .. msg.size end
+  if msg.priority == 'high' or msg.priority == 'low' then key = key .. ':' .. msg.priority end
+  if type(msg.org) == 'string' and msg.org ~= '' then
+    redis.call('ZADD', key .. ':orgs', 'NX', tonumber(redis.call('GET', key .. ':vclock') or '0'), msg.org)
+    key = key .. ':org:' .. msg.org
+  end
+end
+`
+
+// reclaimOne moves one message from the list KEYS[2] back onto the consuming
+// end of its queue. The move is atomic, so concurrent reapers never
+// duplicate or lose a message.
+var reclaimOne = redis.NewScript(`
+local m = redis.call('LPOP', KEYS[2])
+if not m then return false end` + priorityKey + `
+redis.call('RPUSH', key, m)
+return 1
+`)
+
+// promoteDue moves due retries from the delayed set (KEYS[2]) onto their
+// queue in one atomic step, so a message is never lost or duplicated between
+// the keys.
+var promoteDue = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, m in ipairs(items) do` + priorityKey + `
+  redis.call('ZREM', KEYS[2], m)
+  redis.call('LPUSH', key, m)
+end
+return #items
+`)
+
+func (q *redisQueue) processingKey(owner string) string {
+	return q.base + ":processing:" + owner
+}
+
+func (q *redisQueue) aliveKey(owner string) string {
+	return q.base + ":alive:" + owner
+}
+
+func (q *redisQueue) registryKey() string {
+	return q.base + ":workers"
+}
+
+func (q *redisQueue) delayedKey() string {
+	return q.base + ":delayed"
+}
+
+func (q *redisQueue) deadKey() string {
+	return q.base + ":dead"
+}
+
+// Take moves the next message to this worker's processing list. Redis cannot
+// block on several lists with a move, and org lists come and go, so an idle
+// consumer blocks on the plain high queue for takeWait and then checks all
+// of them again.
+func (q *redisQueue) Take(ctx context.Context) (string, error) {
+	processing := q.processingKey(q.owner)
+	keys := append(queueKeys(q.base, q.classes), q.weightsKey(), processing)
+	for {
+		raw, err := takeNext.Run(ctx, q.rdb, keys).Text()
+		if err == nil {
+			return raw, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return "", err
+		}
+		raw, err = q.rdb.BLMove(ctx, keys[0], processing, "RIGHT", "LEFT", takeWait).Result()
+		if err == nil {
+			return raw, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return "", err
+		}
+	}
+}
+
+func (q *redisQueue) Ack(ctx context.Context, raw string) error {
+	return q.rdb.LRem(ctx, q.processingKey(q.owner), 1, raw).Err()
+}
+
+// Reclaim moves every message in owner's processing list back onto the
+// consuming end of its priority queue, so recovered jobs run next.
+func (q *redisQueue) Reclaim(ctx context.Context, owner string) (int, error) {
+	keys := []string{q.base, q.processingKey(owner)}
+	n := 0
+	for {
+		err := reclaimOne.Run(ctx, q.rdb, keys).Err()
+		if errors.Is(err, redis.Nil) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func (q *redisQueue) Defer(ctx context.Context, raw string, due time.Time) error {
+	return q.rdb.ZAdd(ctx, q.delayedKey(), redis.Z{Score: float64(due.Unix()), Member: raw}).Err()
+}
+
+// Promote is safe to run on every worker at once; the script makes each
+// move atomic.
+func (q *redisQueue) Promote(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	return promoteDue.Run(ctx, q.rdb, []string{q.base, q.delayedKey()}, now).Int()
+}
+
+func (q *redisQueue) Bury(ctx context.Context, entry []byte) error {
+	_, err := q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.LPush(ctx, q.deadKey(), entry)
+		p.LTrim(ctx, q.deadKey(), 0, maxDeadLetters-1)
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) Heartbeat(ctx context.Context, info workerInfo) error {
+	entry, _ := json.Marshal(info)
+	_, err := q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, q.aliveKey(info.Name), info.HeartbeatAt.Unix(), aliveTTL)
+		p.HSet(ctx, q.registryKey(), info.Name, entry)
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) Deregister(ctx context.Context, name string) error {
+	_, err := q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HDel(ctx, q.registryKey(), name)
+		p.Del(ctx, q.aliveKey(name))
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) ReapDead(ctx context.Context) error {
+	prefix := q.processingKey("")
+	iter := q.rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		owner := strings.TrimPrefix(iter.Val(), prefix)
+		if owner == q.owner {
+			continue
+		}
+		alive, err := q.rdb.Exists(ctx, q.aliveKey(owner)).Result()
+		if err != nil {
+			return err
+		}
+		if alive > 0 {
+			continue
+		}
+		n, err := q.Reclaim(ctx, owner)
+		if n > 0 {
+			slog.Warn("requeued jobs from dead worker", "owner", owner, "jobs", n)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return q.pruneRegistry(ctx)
+}
+
+// pruneRegistry drops registry entries of workers whose liveness key has
+// expired.
+func (q *redisQueue) pruneRegistry(ctx context.Context) error {
+	names, err := q.rdb.HKeys(ctx, q.registryKey()).Result()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		alive, err := q.rdb.Exists(ctx, q.aliveKey(name)).Result()
+		if err != nil {
+			return err
+		}
+		if alive == 0 {
+			if err := q.rdb.HDel(ctx, q.registryKey(), name).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueueKeysDrainOrder(t *testing.T) {
+	got := queueKeys("q", []string{sizeLarge, sizeMedium})
+	want := []string{"q:large:high", "q:high", "q:large", "q", "q:large:low", "q:low"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("queueKeys = %v, want %v", got, want)
+	}
+}
+
+func TestMessageRoute(t *testing.T) {
+	for _, tc := range []struct {
+		raw, key, org string
+	}{
+		{`{"job_id":"j","priority":"low","size":"small","org":"acme"}`, "q:small:low", "acme"},
+		{`{"job_id":"j"}`, "q", ""},
+		{`{"job_id":"j","priority":"urgent","size":"huge"}`, "q", ""},
+		{`not json`, "q", ""},
+	} {
+		key, org := messageRoute("q", tc.raw)
+		if key != tc.key || org != tc.org {
+			t.Errorf("messageRoute(%s) = %q, %q; want %q, %q", tc.raw, key, org, tc.key, tc.org)
+		}
+	}
+}
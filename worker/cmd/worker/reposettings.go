@@ -0,0 +1,178 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// repoSettings are a repo's own scan settings, edited through the API's
+// PATCH /api/repos/{id}/settings. Zero values defer to the worker config.
+type repoSettings struct {
+	// Scanners narrows the scanners the repo's jobs run.
+	Scanners []string `json:"scanners"`
+	// ScannerEnabled turns scanners on or off by name or "*", ahead of
+	// Scanners.
+	ScannerEnabled map[string]bool `json:"scanner_enabled"`
+	// SemgrepConfig replaces scanners.semgrep.config.
+	SemgrepConfig string `json:"semgrep_config"`
+	// Include keeps only findings whose path matches one of these globs.
+	Include []string `json:"include"`
+	// Exclude drops findings whose path matches one of these globs.
+	Exclude []string `json:"exclude"`
+	// MinSeverity drops findings ranked below it.
+	MinSeverity string `json:"min_severity"`
+	// ScannerTimeouts and ScannerOnFailure replace scanners.timeouts and
+	// scanners.on_failure, keyed the same way.
+	ScannerTimeouts  map[string]string `json:"scanner_timeouts"`
+	ScannerOnFailure map[string]string `json:"scanner_on_failure"`
+	// Clone narrows what is fetched for repos near limits.max_clone_mb.
+	Clone cloneSettings `json:"clone"`
+}
+
+// cloneSettings are a repo's clone strategy settings.
+type cloneSettings struct {
+	// Filter is the partial-clone filter: blob:none (the default) or
+	// tree:0.
+	Filter string `json:"filter"`
+	// Sparse checks out only the paths the include and exclude globs
+	// select, plus SparsePaths.
+	Sparse      bool     `json:"sparse"`
+	SparsePaths []string `json:"sparse_paths"`
+	// SingleBranch keeps a full clone to the scanned ref's history.
+	SingleBranch bool `json:"single_branch"`
+}
+
+// allows reports whether the settings let the repo run scanner name. The
+// worker's scanners.enabled is checked separately, so a toggle cannot run a
+// scanner the worker does not have.
+func (s repoSettings) allows(name string) bool {
+	if on, ok := s.ScannerEnabled[name]; ok {
+		return on
+	}
+	if on, ok := s.ScannerEnabled["*"]; ok {
+		return on
+	}
+	if len(s.Scanners) == 0 {
+		return true
+	}
+	for _, sc := range s.Scanners {
+		if sc == name {
+			return true
+		}
+	}
+	return false
+}
+
+// keeps reports whether a finding passes the path globs and severity
+// threshold. Findings without a path, such as a deployment's headers, are
+// not filtered by path.
+func (s repoSettings) keeps(f finding) bool {
+	if s.MinSeverity != "" && severityRank(f.Severity) < severityRank(s.MinSeverity) {
+		return false
+	}
+	if f.FilePath == nil {
+		return true
+	}
+	if len(s.Include) > 0 && !matchAny(s.Include, *f.FilePath) {
+		return false
+	}
+	return !matchAny(s.Exclude, *f.FilePath)
+}
+
+func matchAny(globs []string, p string) bool {
+	for _, g := range globs {
+		if matchGlob(g, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneOptions turns the clone settings into safeClone's options. A sparse
+// checkout covers the include globs, or the whole tree without any, less
+// the exclude globs. git reads them with gitignore rules, which match
+// matchGlob's closely enough for the findings filter to do the rest.
+func (s repoSettings) cloneOptions() cloneOptions {
+	o := cloneOptions{filter: s.Clone.Filter, singleBranch: s.Clone.SingleBranch}
+	if !s.Clone.Sparse {
+		return o
+	}
+	if len(s.Include) == 0 {
+		o.sparse = append(o.sparse, "/*")
+	}
+	o.sparse = append(o.sparse, s.Include...)
+	o.sparse = append(o.sparse, s.Clone.SparsePaths...)
+	for _, g := range s.Exclude {
+		o.sparse = append(o.sparse, "!"+g)
+	}
+	return o
+}
+
+// semgrepPathArgs passes the path globs to semgrep, whose --include and
+// --exclude follow the same gitignore-like rules as matchGlob, so files
+// outside them are not scanned at all.
+func (s repoSettings) semgrepPathArgs() []string {
+	var args []string
+	for _, g := range s.Include {
+		args = append(args, "--include="+g)
+	}
+	for _, g := range s.Exclude {
+		args = append(args, "--exclude="+g)
+	}
+	return args
+}
+
+// trivySkipArgs turns the exclude globs into trivy's --skip-dirs and
+// --skip-files, which match the path from the scan root. Includes have no
+// trivy equivalent and are applied to its findings afterwards.
+func (s repoSettings) trivySkipArgs() []string {
+	var args []string
+	for _, g := range s.Exclude {
+		if dir, ok := strings.CutSuffix(g, "/**"); ok {
+			args = append(args, "--skip-dirs="+dir)
+			continue
+		}
+		if !strings.Contains(g, "/") {
+			g = "**/" + g
+		}
+		args = append(args, "--skip-dirs="+g, "--skip-files="+g)
+	}
+	return args
+}
+
+// matchGlob matches a path relative to the repo root against an exclude
+// glob. "dir/**" matches everything under dir, a glob with a slash matches
+// the whole path, and one without matches any path element, so "testdata"
+// or "*.min.js" work anywhere in the tree.
+func matchGlob(glob, p string) bool {
+	p = strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(p, "./")), "/")
+	if dir, ok := strings.CutSuffix(glob, "/**"); ok {
+		return p == dir || strings.HasPrefix(p, dir+"/")
+	}
+	if strings.Contains(glob, "/") {
+		ok, _ := path.Match(glob, p)
+		return ok
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if ok, _ := path.Match(glob, elem); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// severityRank orders scanner severities the same way as the API: semgrep's
+// ERROR/WARNING/INFO fold onto HIGH/MEDIUM/LOW and unknown values rank 0.
+func severityRank(sev string) int {
+	switch strings.ToUpper(strings.TrimSpace(sev)) {
+	case "CRITICAL":
+		return 4
+	case "HIGH", "ERROR":
+		return 3
+	case "MEDIUM", "WARNING", "MODERATE":
+		return 2
+	case "LOW", "INFO", "NOTE":
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		glob, path string
+		want       bool
+	}{
+		{"testdata", "pkg/testdata/fixture.go", true},
+		{"*.min.js", "web/static/app.min.js", true},
+		{"docs/**", "docs/guide/index.md", true},
+		{"docs/**", "docsite/index.md", false},
+		{"internal/*.go", "internal/db.go", true},
+		{"internal/*.go", "cmd/internal/db.go", false},
+		{"vendor", "./vendor/x/y.go", true},
+		{"*.go", "README.md", false},
+	} {
+		if got := matchGlob(tc.glob, tc.path); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tc.glob, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRepoSettingsKeeps(t *testing.T) {
+	s := repoSettings{Exclude: []string{"testdata"}, MinSeverity: "HIGH"}
+	path := func(p string) *string { return &p }
+	for _, tc := range []struct {
+		f    finding
+		want bool
+	}{
+		{finding{Severity: "CRITICAL", FilePath: path("main.go")}, true},
+		{finding{Severity: "ERROR", FilePath: path("main.go")}, true},
+		{finding{Severity: "MEDIUM", FilePath: path("main.go")}, false},
+		{finding{Severity: "HIGH", FilePath: path("testdata/key.pem")}, false},
+		{finding{Severity: "HIGH"}, true},
+	} {
+		if got := s.keeps(tc.f); got != tc.want {
+			t.Errorf("keeps(%+v) = %v, want %v", tc.f, got, tc.want)
+		}
+	}
+}
+
+func TestRepoSettingsInclude(t *testing.T) {
+	s := repoSettings{Include: []string{"services/payments/**"}, Exclude: []string{"vendor"}}
+	path := func(p string) *string { return &p }
+	for _, tc := range []struct {
+		f    finding
+		want bool
+	}{
+		{finding{Severity: "HIGH", FilePath: path("services/payments/api.go")}, true},
+		{finding{Severity: "HIGH", FilePath: path("services/billing/api.go")}, false},
+		{finding{Severity: "HIGH", FilePath: path("services/payments/vendor/x/y.go")}, false},
+		{finding{Severity: "HIGH"}, true},
+	} {
+		if got := s.keeps(tc.f); got != tc.want {
+			t.Errorf("keeps(%+v) = %v, want %v", tc.f, got, tc.want)
+		}
+	}
+}
+
+func TestRepoSettingsPathArgs(t *testing.T) {
+	s := repoSettings{Include: []string{"services/payments/**"}, Exclude: []string{"third_party/**", "*.min.js", "web/gen/*.ts"}}
+	want := []string{"--include=services/payments/**", "--exclude=third_party/**", "--exclude=*.min.js", "--exclude=web/gen/*.ts"}
+	if got := s.semgrepPathArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("semgrepPathArgs() = %q, want %q", got, want)
+	}
+	want = []string{"--skip-dirs=third_party", "--skip-dirs=**/*.min.js", "--skip-files=**/*.min.js", "--skip-dirs=web/gen/*.ts", "--skip-files=web/gen/*.ts"}
+	if got := s.trivySkipArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("trivySkipArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestRepoSettingsAllows(t *testing.T) {
+	for _, tc := range []struct {
+		s    repoSettings
+		name string
+		want bool
+	}{
+		{repoSettings{}, "trivy", true},
+		{repoSettings{ScannerEnabled: map[string]bool{"trivy": false}}, "trivy", false},
+		{repoSettings{ScannerEnabled: map[string]bool{"trivy": false}}, "semgrep", true},
+		{repoSettings{Scanners: []string{"semgrep"}}, "trivy", false},
+		{repoSettings{Scanners: []string{"semgrep"}, ScannerEnabled: map[string]bool{"trivy": true}}, "trivy", true},
+		{repoSettings{ScannerEnabled: map[string]bool{"*": false, "gitleaks": true}}, "gitleaks", true},
+		{repoSettings{ScannerEnabled: map[string]bool{"*": false, "gitleaks": true}}, "semgrep", false},
+	} {
+		if got := tc.s.allows(tc.name); got != tc.want {
+			t.Errorf("%+v.allows(%q) = %v, want %v", tc.s, tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "sort"
+
+// A successful scan of a repo's default branch resolves the open findings it
+// no longer reports: they are marked fixed with a resolved_at time, and a
+// later scan that reports one reopens it. Only tools whose scanners all ran
+// cleanly resolve, so a scanner that failed or was not run leaves its
+// findings open. Scans that see part of the repo resolve nothing: other
+// refs, pinned commits and incremental scans.
+
+// scannerTools maps scanners onto the tool their findings carry where the
+// two differ.
+var scannerTools = map[string]string{"terraform": "trivy"}
+
+// resolvesFindings reports whether the scan saw the repo's default branch
+// in full.
+func (j *scanJob) resolvesFindings(spec jobSpec, repo RepoRow) bool {
+	if j.msg.RepoID == "" || j.msg.Source == "upload" || j.msg.Source == sourceImage {
+		return false
+	}
+	return (spec.Ref == "" || spec.Ref == repo.DefaultRef) && spec.CommitSHA == "" && j.changedDir == ""
+}
+
+// resolvedTools returns the tools of the scanners that ran, leaving out any
+// tool one of its scanners failed for.
+func (j *scanJob) resolvedTools(ran []jobScanner) []string {
+	failed := map[string]bool{}
+	for _, name := range j.failedScanners() {
+		failed[scannerTool(name)] = true
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range ran {
+		tool := scannerTool(s.name)
+		if failed[tool] || seen[tool] {
+			continue
+		}
+		seen[tool] = true
+		out = append(out, tool)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func scannerTool(name string) string {
+	if tool, ok := scannerTools[name]; ok {
+		return tool
+	}
+	return name
+}
+
+func (j *scanJob) scannerFailed(name string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.failed = append(j.failed, name)
+}
+
+func (j *scanJob) failedScanners() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.failed...)
+}
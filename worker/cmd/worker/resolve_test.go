@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolvesFindings(t *testing.T) {
+	repo := RepoRow{DefaultRef: "main"}
+	for _, tc := range []struct {
+		name string
+		job  *scanJob
+		spec jobSpec
+		want bool
+	}{
+		{"default branch", &scanJob{msg: JobMsg{RepoID: "r"}}, jobSpec{}, true},
+		{"default ref by name", &scanJob{msg: JobMsg{RepoID: "r"}}, jobSpec{Ref: "main"}, true},
+		{"other ref", &scanJob{msg: JobMsg{RepoID: "r"}}, jobSpec{Ref: "release/1"}, false},
+		{"pinned commit", &scanJob{msg: JobMsg{RepoID: "r"}}, jobSpec{CommitSHA: "abc"}, false},
+		{"incremental", &scanJob{msg: JobMsg{RepoID: "r"}, changedDir: "/tmp/changed"}, jobSpec{BaseSHA: "abc"}, false},
+		{"upload", &scanJob{msg: JobMsg{Source: "upload"}}, jobSpec{}, false},
+	} {
+		if got := tc.job.resolvesFindings(tc.spec, repo); got != tc.want {
+			t.Errorf("%s: resolvesFindings = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestResolvedTools(t *testing.T) {
+	job := &scanJob{}
+	job.scannerFailed("terraform")
+	ran := []jobScanner{{name: "semgrep"}, {name: "trivy"}, {name: "terraform"}, {name: "gitleaks"}}
+	if got, want := job.resolvedTools(ran), []string{"gitleaks", "semgrep"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolvedTools = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	retryPollEvery = 5 * time.Second
+	maxRetryDelay  = 30 * time.Minute
+)
+
+// transientError marks a job failure worth retrying: the same job is likely
+// to succeed on another attempt.
+type transientError struct{ err error }
+
+func (e transientError) Error() string { return e.err.Error() }
+func (e transientError) Unwrap() error { return e.err }
+
+func isTransient(err error) bool {
+	var t transientError
+	return errors.As(err, &t)
+}
+
+// transientGitMessages are git failures caused by the network or the remote
+// rather than by the repository or credentials.
+var transientGitMessages = []string{
+	"could not resolve host", "connection timed out", "operation timed out", "connection reset",
+	"connection refused", "early eof", "rpc failed", "remote end hung up unexpectedly",
+	"returned error: 5", "tls handshake timeout", "temporary failure",
+}
+
+// transientClone reports whether a clone failure is worth retrying: the clone
+// ran out of time or hit a network error.
+func transientClone(ctx context.Context, err error) bool {
+	if errors.Is(err, errCloneFatal) {
+		return false
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range transientGitMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// killedByOOM reports whether a scanner process was SIGKILLed by something
+// other than our own deadline, which in a container is almost always the
+// OOM killer.
+func killedByOOM(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGKILL {
+		return true
+	}
+	return exitErr.ExitCode() == 137
+}
+
+// retryDelay doubles base for every attempt already made, capped at
+// maxRetryDelay.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
+
+// scheduleRetry resets the job to queued, discarding partial results, and
+// defers the next attempt until its backoff has passed.
+func (wk *Worker) scheduleRetry(ctx context.Context, msg JobMsg, cause error) error {
+	backoff, _ := time.ParseDuration(wk.cfg.RetryBackoff)
+	next := msg
+	next.Attempt++
+	delay := retryDelay(backoff, next.Attempt)
+	payload, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("attempt %d/%d failed, retrying in %s: %v", next.Attempt, wk.cfg.MaxAttempts, delay, cause)
+	if err := wk.store.RequeueJob(ctx, msg.JobID, note); err != nil {
+		return err
+	}
+
+	if err := wk.queue.Defer(ctx, string(payload), time.Now().Add(delay)); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "job scheduled for retry", "attempt", next.Attempt+1, "max_attempts", wk.cfg.MaxAttempts, "delay", delay, "err", cause)
+	return nil
+}
+
+// runRetryPromoter releases delayed retries until ctx is cancelled. Every
+// worker runs it; backends make that safe.
+func (wk *Worker) runRetryPromoter(ctx context.Context) {
+	t := time.NewTicker(retryPollEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := wk.queue.Promote(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("retry promotion failed", "err", err)
+		} else if n > 0 {
+			slog.Info("retries released to the queue", "jobs", n)
+		}
+	}
+}
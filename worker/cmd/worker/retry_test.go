@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		12: maxRetryDelay,
+	} {
+		if got := retryDelay(30*time.Second, attempt); got != want {
+			t.Errorf("attempt %d: delay %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestTransientClone(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("git clone (filtered): exit status 128: fatal: unable to access: Could not resolve host: github.com"), true},
+		{errors.New("git clone (full): exit status 128: error: RPC failed; curl 56 GnuTLS recv error"), true},
+		{fmt.Errorf("%w: git clone (filtered): exit status 128: remote: Repository not found.", errCloneFatal), false},
+		{errors.New("repo is 900 MB, exceeds size limit (350 MB)"), false},
+	} {
+		if got := transientClone(ctx, tc.err); got != tc.want {
+			t.Errorf("transientClone(%q) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	if !transientClone(expired, errors.New("signal: killed")) {
+		t.Error("a clone cut off by the deadline should be retried")
+	}
+}
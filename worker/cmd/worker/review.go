@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"argus/worker/internal/githubapp"
+)
+
+// reviewMarker starts the hidden line that ties a review comment to a
+// finding's fingerprint, so each finding is commented on once per pull
+// request however often it is pushed to.
+const reviewMarker = "<!-- argus:finding "
+
+// maxReviewComments caps the comments posted for one scan.
+const maxReviewComments = 50
+
+// postReviewComments comments on the diff lines of the pull request that
+// brought in new findings, as one review. Findings outside the lines the
+// pull request adds, and those an earlier push was commented on, are left to
+// the check run.
+func (wk *Worker) postReviewComments(ctx context.Context, pr pullRequest, fresh []finding) {
+	files, err := wk.github.PullRequestFiles(ctx, pr.token, pr.owner, pr.repo, pr.number)
+	if err != nil {
+		slog.WarnContext(ctx, "review comments skipped: pull request files", "pull_request", pr.number, "err", err)
+		return
+	}
+	existing, err := wk.github.ReviewComments(ctx, pr.token, pr.owner, pr.repo, pr.number)
+	if err != nil {
+		slog.WarnContext(ctx, "review comments skipped: existing comments", "pull_request", pr.number, "err", err)
+		return
+	}
+	added := map[string]map[int]bool{}
+	for _, f := range files {
+		added[f.Filename] = addedLines(f.Patch)
+	}
+	bodies := make([]string, len(existing))
+	for i, c := range existing {
+		bodies[i] = c.Body
+	}
+	var comments []githubapp.ReviewComment
+	for _, c := range lineComments(fresh, added, bodies) {
+		comments = append(comments, githubapp.ReviewComment{Path: c.path, Line: c.line, Side: "RIGHT", Body: c.body})
+	}
+	if len(comments) == 0 {
+		return
+	}
+	if err := wk.github.CreateReview(ctx, pr.token, pr.owner, pr.repo, pr.number, githubapp.Review{CommitID: pr.sha, Comments: comments}); err != nil {
+		slog.WarnContext(ctx, "review comments not posted", "pull_request", pr.number, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "review comments posted", "pull_request", pr.number, "comments", len(comments))
+}
+
+// lineComment is a comment on a line a pull or merge request adds.
+type lineComment struct {
+	path string
+	line int
+	body string
+}
+
+// lineComments picks the new findings to comment on: those with a
+// fingerprint not yet in the bodies of existing comments, on a line the
+// request adds. added holds the head-side line numbers each changed file
+// adds. Each finding gets a comment on the first added line it covers.
+func lineComments(fresh []finding, added map[string]map[int]bool, existing []string) []lineComment {
+	commented := map[string]bool{}
+	for _, body := range existing {
+		if fp := reviewFingerprint(body); fp != "" {
+			commented[fp] = true
+		}
+	}
+	var out []lineComment
+	for _, f := range fresh {
+		if f.Fingerprint == nil || f.FilePath == nil || f.LineStart == nil || commented[*f.Fingerprint] {
+			continue
+		}
+		end := *f.LineStart
+		if f.LineEnd != nil && *f.LineEnd > end {
+			end = *f.LineEnd
+		}
+		lines := added[*f.FilePath]
+		for line := *f.LineStart; line <= end; line++ {
+			if lines[line] {
+				out = append(out, lineComment{path: *f.FilePath, line: line, body: reviewBody(f)})
+				commented[*f.Fingerprint] = true
+				break
+			}
+		}
+		if len(out) == maxReviewComments {
+			break
+		}
+	}
+	return out
+}
+
+func reviewBody(f finding) string {
+	var b strings.Builder
+	b.WriteString("**" + f.Severity + "** " + f.Title + " (" + f.Tool + ")")
+	if f.Description != nil && *f.Description != "" {
+		b.WriteString("\n\n" + *f.Description)
+	}
+	b.WriteString("\n\n" + reviewMarker + *f.Fingerprint + " -->")
+	return b.String()
+}
+
+// reviewFingerprint returns the fingerprint a comment Argus posted is
+// marked with, or "".
+func reviewFingerprint(body string) string {
+	_, rest, ok := strings.Cut(body, reviewMarker)
+	if !ok {
+		return ""
+	}
+	fp, _, _ := strings.Cut(rest, " -->")
+	return fp
+}
+
+// addedLines returns the head-side line numbers a unified diff adds.
+func addedLines(patch string) map[int]bool {
+	added := map[int]bool{}
+	line := 0
+	for _, l := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			// @@ -a,b +c,d @@: the hunk's head side starts at line c.
+			fields := strings.Fields(l)
+			if len(fields) < 3 {
+				line = 0
+				continue
+			}
+			start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			line, _ = strconv.Atoi(start)
+		case line == 0:
+		case strings.HasPrefix(l, "+"):
+			added[line] = true
+			line++
+		case strings.HasPrefix(l, "-"), strings.HasPrefix(l, `\`):
+			// Removed lines and "\ No newline at end of file" are not in
+			// the head.
+		default:
+			line++
+		}
+	}
+	return added
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddedLines(t *testing.T) {
+	patch := "@@ -1,3 +1,4 @@\n package main\n-var a = 1\n+var a = 2\n+var b = 3\n func main() {}\n@@ -20,2 +21,3 @@ func f() {\n \treturn\n+\t// done\n }\n\\ No newline at end of file"
+	want := map[int]bool{2: true, 3: true, 22: true}
+	if got := addedLines(patch); !reflect.DeepEqual(got, want) {
+		t.Errorf("addedLines = %v, want %v", got, want)
+	}
+	if got := addedLines(""); len(got) != 0 {
+		t.Errorf("addedLines of an empty patch = %v", got)
+	}
+}
+
+func TestLineComments(t *testing.T) {
+	at := func(fp, path string, start, end int) finding {
+		return finding{Tool: "semgrep", Severity: "HIGH", Title: "SQL injection", Fingerprint: &fp, FilePath: &path, LineStart: &start, LineEnd: &end}
+	}
+	added := map[string]map[int]bool{"db/query.go": addedLines("@@ -10,2 +10,4 @@\n func q() {\n+\trows := db.Query(sql)\n+\t_ = rows\n }")}
+	existing := []string{"**HIGH** SQL injection (semgrep)\n\n" + reviewMarker + "fp-old -->"}
+	fresh := []finding{
+		at("fp-new", "db/query.go", 9, 12), // spans the added lines 11 and 12
+		at("fp-old", "db/query.go", 11, 11),
+		at("fp-context", "db/query.go", 10, 10),
+		at("fp-other", "main.go", 3, 3),
+	}
+	got := lineComments(fresh, added, existing)
+	if len(got) != 1 {
+		t.Fatalf("comments = %+v", got)
+	}
+	if c := got[0]; c.path != "db/query.go" || c.line != 11 || reviewFingerprint(c.body) != "fp-new" {
+		t.Errorf("comment = %+v", c)
+	}
+}
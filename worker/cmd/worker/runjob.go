@@ -2,136 +2,454 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"argus/internal/forge"
+	"argus/worker/internal/githubapp"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type RepoRow struct {
-	URL  string
-	Name string
+	URL        string
+	Name       string
+	DefaultRef string
+	Settings   repoSettings
+	// InstallationID is the GitHub App installation that can read the
+	// repo; 0 when none is recorded.
+	InstallationID int64
 }
 
-func runJob(ctx context.Context, db *pgxpool.Pool, msg JobMsg, maxCloneMB int) error {
-	if _, err := db.Exec(ctx, `UPDATE jobs SET status='running', started_at=now(), error=NULL WHERE id=$1`, msg.JobID); err != nil {
-		return err
+// scanJob is the per-job state handed to each scanner.
+type scanJob struct {
+	msg JobMsg
+	dir string
+	// scanners is the selection from the scan request; empty means all.
+	scanners []string
+	titles   titleTemplates
+	// salt keys the job's fingerprints; empty for unsalted orgs.
+	salt string
+	// settings are the repo's own scan settings; zero for uploads.
+	settings repoSettings
+	// semgrepRules are the org's rule files, run as semgrepRulesMode says.
+	semgrepRules     []orgRuleFile
+	semgrepRulesMode string
+	// deploymentURL is the preview deployment nuclei probes, if any.
+	deploymentURL string
+	// image is the container image of an image scan, which runs trivy
+	// against it instead of the usual scanners.
+	image string
+	// changedDir holds the files an incremental scan changed, staged for
+	// semgrep and gitleaks; empty for full scans. See incremental.go.
+	changedDir string
+	// flags are the feature flags as the job's repo sees them.
+	flags featureFlags
+	// commit is the commit a cloned repo was scanned at; empty for uploads
+	// and images.
+	commit string
+	// findings collects the scanners' results until they are stored;
+	// scanners add to it concurrently. dropped counts those the repo
+	// settings filtered out, and failed names the scanners that returned
+	// an error.
+	mu       sync.Mutex
+	findings []finding
+	dropped  int
+	failed   []string
+}
+
+func (j *scanJob) add(f finding) {
+	f.recordSeverity()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.settings.keeps(f) {
+		j.dropped++
+		return
 	}
+	j.findings = append(j.findings, f)
+}
 
-	var repo RepoRow
-	err := db.QueryRow(ctx, `SELECT url, name FROM repos WHERE id=$1`, msg.RepoID).Scan(&repo.URL, &repo.Name)
+// fp fingerprints a finding from its identifying parts, salted for the org.
+func (j *scanJob) fp(parts ...string) string {
+	return saltFingerprint(j.salt, fingerprint(parts...))
+}
+
+// wants reports whether the scan request selected scanner name.
+func (j *scanJob) wants(name string) bool {
+	if len(j.scanners) == 0 {
+		return true
+	}
+	for _, s := range j.scanners {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (wk *Worker) runJob(ctx context.Context, msg JobMsg) (err error) {
+	st := wk.store
+	spec, err := st.StartJob(ctx, msg.JobID, msg.Attempt+1, wk.owner.name())
 	if err != nil {
-		_ = failJob(ctx, db, msg.JobID, "repo not found")
 		return err
 	}
-	if !isSafeRepoURL(repo.URL) {
-		_ = failJob(ctx, db, msg.JobID, "repo url rejected by policy")
-		return errors.New("repo url rejected by policy")
+	started, cmds := time.Now(), &commandLog{}
+	ctx = withCommandLog(ctx, cmds)
+	var prog *progress
+	var repo RepoRow
+	// A pending commit status always gets an outcome: failure from fail,
+	// error from any other return with an error.
+	failed := false
+	fail := func(reason string) {
+		failed = true
+		wk.failJob(ctx, msg, started, cmds, prog, reason)
+		wk.postCommitStatus(ctx, msg.JobID, spec, repo, githubapp.StateFailure, "Scan failed")
 	}
+	defer func() {
+		if err != nil && !failed {
+			wk.postCommitStatus(ctx, msg.JobID, spec, repo, githubapp.StateError, "Scan did not finish")
+		}
+	}()
 
-	workRoot := filepath.Join(os.TempDir(), "argus", msg.JobID)
-	_ = os.RemoveAll(workRoot)
-	if err := os.MkdirAll(workRoot, 0o755); err != nil {
-		_ = failJob(ctx, db, msg.JobID, "cannot create workdir")
+	workRoot, err := createWorkspace(wk.cfg.WorkspaceRoot, wk.owner, msg)
+	if err != nil {
+		fail("cannot create workdir")
 		return err
 	}
 	defer os.RemoveAll(workRoot)
 
+	if msg.Source != "upload" && msg.Source != sourceImage {
+		if repo, err = st.Repo(ctx, msg.RepoID); err != nil {
+			fail("repo not found")
+			return err
+		}
+		wk.postCommitStatus(ctx, msg.JobID, spec, repo, githubapp.StatePending, "Scan running")
+	}
+
 	repoDir := filepath.Join(workRoot, "repo")
-	if err := safeClone(ctx, repo.URL, repoDir, maxCloneMB); err != nil {
-		_ = failJob(ctx, db, msg.JobID, "clone failed: "+err.Error())
+	org := st.OrgSettings(ctx, msg.RepoID)
+	job := &scanJob{msg: msg, dir: repoDir, scanners: spec.Scanners, titles: org.TitleTemplates, salt: org.FingerprintSalt, settings: repo.Settings,
+		semgrepRules: org.SemgrepRules, semgrepRulesMode: org.SemgrepRulesMode, deploymentURL: spec.DeploymentURL, image: spec.Image, flags: spec.Flags}
+	scanners := wk.jobScanners(ctx, job)
+
+	var planned []string
+	switch msg.Source {
+	case "upload":
+		planned = append(planned, stageExtracting)
+	case sourceImage:
+		// trivy pulls the image itself.
+	default:
+		planned = append(planned, stageCloning)
+	}
+	for _, s := range scanners {
+		planned = append(planned, s.name)
+	}
+	prog = newProgress(st, msg.JobID, append(planned, stagePersisting))
+
+	switch msg.Source {
+	case sourceImage:
+		if !wk.cfg.ImageRegistryAllowed(imageRegistry(job.image)) {
+			fail("image registry not in images.allowed_registries on this worker")
+			return fmt.Errorf("image registry %q not allowed", imageRegistry(job.image))
+		}
+		if len(scanners) == 0 {
+			fail("trivy is disabled on this worker")
+			return errors.New("trivy is disabled on this worker")
+		}
+		if err := os.MkdirAll(repoDir, 0o755); err != nil {
+			fail("cannot create workdir")
+			return err
+		}
+	case "upload":
+		prog.enter(ctx, stageExtracting)
+		if err := fetchUpload(ctx, st, msg.JobID, repoDir, wk.cfg.MaxCloneMB); err != nil {
+			fail("archive extraction failed: " + err.Error())
+			return err
+		}
+	default:
+		prog.enter(ctx, stageCloning)
+		if !wk.isSafeRepoURL(repo.URL) {
+			fail("repo url rejected by policy")
+			return errors.New("repo url rejected by policy")
+		}
+		ref := repo.DefaultRef
+		if spec.Ref != "" {
+			ref = spec.Ref
+		}
+		token, err := wk.cloneToken(ctx, repo)
+		if err != nil {
+			fail("installation token: " + err.Error())
+			return transientError{err}
+		}
+		strategy, release, err := wk.cloneRepo(ctx, repo, ref, repoDir, token, spec.CommitSHA, spec.BaseSHA)
+		defer release()
+		if err != nil {
+			fail("clone failed: " + err.Error())
+			if transientClone(ctx, err) {
+				return transientError{err}
+			}
+			return err
+		}
+		if spec.CommitSHA != "" {
+			if err := checkoutCommit(ctx, repoDir, spec.CommitSHA, wk.cfg.GitHubProxySetting()); err != nil {
+				fail("commit checkout failed: " + err.Error())
+				return err
+			}
+		}
+		sha, err := headCommit(ctx, repoDir)
+		if err != nil {
+			slog.WarnContext(ctx, "could not resolve scanned commit", "err", err)
+		}
+		job.commit = sha
+		if err := st.SetCloneResult(ctx, msg.JobID, strategy, sha); err != nil {
+			return err
+		}
+		if prev := unchangedSince(ctx, st, msg.JobID, spec, sha); prev != "" {
+			prog.done(ctx)
+			slog.InfoContext(ctx, "scan skipped: commit unchanged", "commit_sha", sha, "previous_job_id", prev)
+			return st.SkipJob(ctx, msg.JobID, "commit "+sha+" unchanged since job "+prev)
+		}
+		if err := st.SetRepoProfile(ctx, msg.JobID, profileRepo(repoDir)); err != nil {
+			slog.WarnContext(ctx, "repo profile not recorded", "err", err)
+		}
+		if spec.BaseSHA != "" {
+			job.changedDir = wk.stageIncremental(ctx, job, spec.BaseSHA, workRoot)
+		}
+	}
+
+	wk.recordToolVersions(ctx, job, scanners)
+
+	if job.image != "" {
+		// With trivy the only scanner, an image it could not pull or scan
+		// fails the job rather than passing it without findings.
+		s := scanners[0]
+		prog.begin(ctx, s.name)
+		err := wk.runScanner(ctx, job, s)
+		prog.end(ctx, s.name)
+		if err != nil {
+			fail("image scan failed: " + err.Error())
+			return err
+		}
+	} else if err := wk.runScanners(ctx, job, scanners, prog); err != nil {
+		var oom scannerKilled
+		if errors.As(err, &oom) {
+			fail(oom.name + " was killed, likely out of memory")
+			return transientError{err}
+		}
+		var failed scannerError
+		if errors.As(err, &failed) {
+			fail(failed.Error())
+		}
 		return err
 	}
 
-	if err := runSemgrep(ctx, db, msg, repoDir); err != nil {
-		fmt.Println("semgrep error:", err)
+	job.linkIssueRefs()
+	if job.dropped > 0 {
+		slog.InfoContext(ctx, "findings dropped by repo settings", "dropped", job.dropped)
 	}
-	if err := runGitleaks(ctx, db, msg, repoDir); err != nil {
-		fmt.Println("gitleaks error:", err)
+
+	// Findings are submitted once every scanner has run, so the persisting
+	// stage shows how long storing them takes.
+	prog.enter(ctx, stagePersisting)
+	if err := st.AddFindings(ctx, msg.RepoID, msg.JobID, job.findings); err != nil {
+		// A scan whose findings are lost must not pass.
+		slog.ErrorContext(ctx, "store findings failed", "findings", len(job.findings), "err", err)
+		fail("store findings failed: " + err.Error())
+		return transientError{err}
 	}
-	if err := runTrivy(ctx, db, msg, repoDir); err != nil {
-		fmt.Println("trivy error:", err)
+	if job.resolvesFindings(spec, repo) {
+		// Without the findings stored, every open one would look fixed.
+		if n, err := st.ResolveFindings(ctx, msg.RepoID, msg.JobID, job.resolvedTools(scanners)); err != nil {
+			slog.WarnContext(ctx, "resolve findings failed", "err", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "findings resolved", "resolved", n)
+		}
 	}
+	prog.done(ctx)
 
-	if _, err := db.Exec(ctx, `UPDATE jobs SET status='succeeded', finished_at=now() WHERE id=$1`, msg.JobID); err != nil {
+	if err := st.FinishJob(ctx, msg.JobID, job.failedScanners()); err != nil {
 		return err
 	}
+	wk.reportCommitStatus(ctx, msg.JobID, spec, repo)
+	wk.reportPullRequest(ctx, msg.JobID, spec, repo, job)
+	wk.uploadCodeScanning(ctx, spec, repo, job, job.resolvedTools(scanners))
+	if msg.Source == "upload" {
+		st.DeleteUpload(ctx, msg.JobID)
+	}
 	return nil
 }
 
-func failJob(ctx context.Context, db *pgxpool.Pool, jobID string, e string) error {
-	_, err := db.Exec(ctx, `UPDATE jobs SET status='failed', finished_at=now(), error=$2 WHERE id=$1`, jobID, e)
-	return err
+// unchangedSince returns the previous successful scan of the job's ref and
+// scanner selection when it saw the same commit, so the job has nothing new
+// to scan; otherwise "". Forced jobs, pinned commits and preview
+// deployments always run.
+func unchangedSince(ctx context.Context, st store, jobID string, spec jobSpec, sha string) string {
+	if spec.Force || spec.CommitSHA != "" || spec.DeploymentURL != "" || sha == "" {
+		return ""
+	}
+	prev, prevSHA, err := st.PreviousScan(ctx, jobID)
+	if err != nil {
+		slog.WarnContext(ctx, "previous scan lookup failed", "err", err)
+		return ""
+	}
+	if prevSHA != sha {
+		return ""
+	}
+	return prev
 }
 
-func isSafeRepoURL(raw string) bool {
-	raw = strings.TrimSpace(strings.ToLower(raw))
-	if !strings.HasPrefix(raw, "https://") || !strings.HasSuffix(raw, ".git") {
-		return false
-	}
-	return strings.HasPrefix(raw, "https://github.com/")
+// scannerKilled is a scanner killed by the kernel, most likely out of memory.
+type scannerKilled struct {
+	name string
+	err  error
 }
 
-func safeClone(ctx context.Context, repoURL, repoDir string, maxCloneMB int) error {
-	token := strings.TrimSpace(os.Getenv("GIT_TOKEN"))
-	cloneURL := repoURL
-	if token != "" {
-		cloneURL = strings.Replace(repoURL, "https://", "https://x-access-token:"+token+"@", 1)
-	}
+func (e scannerKilled) Error() string { return e.name + " killed: " + e.err.Error() }
+func (e scannerKilled) Unwrap() error { return e.err }
 
-	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--filter=blob:none", "--no-tags", cloneURL, repoDir)
-	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git clone: %v: %s", err, string(out))
-	}
+// scannerError is a scanner failure that fails the job under
+// scanners.on_failure.
+type scannerError struct {
+	name string
+	err  error
+}
+
+func (e scannerError) Error() string { return e.name + " failed: " + e.err.Error() }
+func (e scannerError) Unwrap() error { return e.err }
 
-	var sizeBytes int64
-	_ = filepath.Walk(repoDir, func(path string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil || info == nil {
+// runScanners runs the job's scanners concurrently, at most
+// scanners.parallelism at a time; they only read the cloned tree. A scanner
+// that fails is logged and the others carry on, unless its failure policy
+// fails the job or it was killed out of memory: either cancels the rest, and
+// the latter lets the job be retried.
+func (wk *Worker) runScanners(ctx context.Context, job *scanJob, scanners []jobScanner, prog *progress) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(wk.cfg.ScannerWorkers)
+	for _, s := range scanners {
+		g.Go(func() error {
+			prog.begin(gctx, s.name)
+			defer prog.end(gctx, s.name)
+			err := wk.runScanner(gctx, job, s)
+			if killedByOOM(gctx, err) {
+				return scannerKilled{s.name, err}
+			}
+			if err != nil {
+				slog.ErrorContext(gctx, "scanner failed", "scanner", s.name, "err", err)
+				job.scannerFailed(s.name)
+				if wk.failsJob(job, s.name) {
+					return scannerError{s.name, err}
+				}
+			}
 			return nil
+		})
+	}
+	return g.Wait()
+}
+
+type jobScanner struct {
+	name string
+	run  func(context.Context, *scanJob) error
+}
+
+// jobScanners returns the scanners the job will run, in order: those it
+// selected (all by default) that are enabled on this worker and not turned
+// off by a feature flag. nuclei also needs a deployment to probe. Image
+// scans only run trivy, against the image.
+func (wk *Worker) jobScanners(ctx context.Context, job *scanJob) []jobScanner {
+	candidates := []jobScanner{
+		{"semgrep", wk.runSemgrep},
+		{"gitleaks", wk.runGitleaks},
+		{"trufflehog", wk.runTrufflehog},
+		{"trivy", wk.runTrivy},
+		{"terraform", wk.runTerraform},
+		{"hadolint", wk.runHadolint},
+		{"kube-linter", wk.runKubeLinter},
+		{"govulncheck", wk.runGovulncheck},
+		{"npm-audit", wk.runNPMAudit},
+		{"pip-audit", wk.runPipAudit},
+		{"bandit", wk.runBandit},
+		{"grype", wk.runGrype},
+		{"syft", wk.runSyft},
+		{"nuclei", wk.runNuclei},
+	}
+	if job.image != "" {
+		candidates = []jobScanner{{"trivy", wk.runTrivyImage}}
+	}
+	var out []jobScanner
+	for _, s := range candidates {
+		if !job.wants(s.name) {
+			continue
 		}
-		if info.Mode().IsRegular() {
-			sizeBytes += info.Size()
+		if s.name == "nuclei" && !wk.dastTarget(ctx, job) {
+			continue
 		}
-		return nil
-	})
-
-	if sizeBytes > int64(maxCloneMB)*1024*1024 {
-		return fmt.Errorf("repo exceeds size limit (%d MB)", maxCloneMB)
+		if !job.flags.enabled("scanner." + s.name) {
+			slog.InfoContext(ctx, "scanner turned off by feature flag", "scanner", s.name)
+			continue
+		}
+		if !job.settings.allows(s.name) {
+			slog.InfoContext(ctx, "scanner disabled by repo settings", "scanner", s.name)
+			continue
+		}
+		if !wk.cfg.ScannerEnabled(s.name) {
+			if len(job.scanners) > 0 {
+				slog.WarnContext(ctx, "requested scanner is disabled on this worker", "scanner", s.name)
+			}
+			continue
+		}
+		out = append(out, s)
 	}
-	return nil
+	return out
 }
 
-func insertFinding(ctx context.Context, db *pgxpool.Pool, repoID, jobID, tool, severity, title string, filePath *string, lineStart, lineEnd *int, fingerprint *string, desc *string, evidence any) error {
-	ev, _ := json.Marshal(evidence)
-	_, err := db.Exec(ctx, `INSERT INTO findings (repo_id, job_id, tool, severity, title, file_path, line_start, line_end, fingerprint, description, evidence_json) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
-		repoID, jobID, tool, severity, title, filePath, lineStart, lineEnd, fingerprint, desc, ev)
-	return err
+func (wk *Worker) isSafeRepoURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	if _, azure := forge.ParseAzureRepo(raw); !azure && !strings.HasSuffix(strings.ToLower(u.Path), ".git") {
+		return false
+	}
+	return wk.cfg.HostAllowed(u.Hostname())
 }
 
-func fp(parts ...string) string {
-	h := sha256.New()
-	for _, p := range parts {
-		h.Write([]byte(p))
-		h.Write([]byte{0})
+func nullIfEmpty(v string) any {
+	if strings.TrimSpace(v) == "" {
+		return nil
 	}
-	return hex.EncodeToString(h.Sum(nil))
+	return v
 }
 
-func runCmdJSON(ctx context.Context, name string, args []string, workdir string) ([]byte, error) {
+// runCmdJSON runs a scanner in workdir. A nil env inherits the worker's
+// environment.
+func runCmdJSON(ctx context.Context, name string, args []string, workdir string, env []string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = workdir
-	out, err := cmd.CombinedOutput()
+	cmd.Env = env
+	// Stderr reaches the job log as it is written; stdout is the report.
+	jl := jobLogFrom(ctx)
+	jl.command(name, args)
+	var combined lockedBuffer
+	stderr := jl.writer(name)
+	cmd.Stdout = &combined
+	cmd.Stderr = io.MultiWriter(&combined, stderr)
+	start := time.Now()
+	err := cmd.Run()
+	stderr.Close()
+	out := combined.Bytes()
+	jl.exit(name, start, err)
+	recordCommandResult(ctx, name, args, start, out, err)
 	if err != nil {
-		return out, fmt.Errorf("%s %v: %v: %s", name, args, err, string(out))
+		return out, fmt.Errorf("%s %v: %w: %s", name, args, err, string(out))
 	}
 	return out, nil
 }
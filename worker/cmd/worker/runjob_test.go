@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+// stageStore records SetStage calls; other store methods are not used.
+type stageStore struct {
+	store
+	mu     sync.Mutex
+	stages []string
+}
+
+func (s *stageStore) SetStage(_ context.Context, _, stage string, _ int, _ map[string]int64) error {
+	s.mu.Lock()
+	s.stages = append(s.stages, stage)
+	s.mu.Unlock()
+	return nil
+}
+
+func TestRunScannersConcurrently(t *testing.T) {
+	wk := &Worker{cfg: config.Config{ScannerWorkers: 2}}
+	st := &stageStore{}
+	prog := newProgress(st, "job", []string{"a", "b"})
+	job := &scanJob{}
+
+	// Each scanner waits for the other to start, so the test only finishes
+	// if they run at the same time.
+	started := make(chan struct{}, 2)
+	scan := func(ctx context.Context, j *scanJob) error {
+		started <- struct{}{}
+		for len(started) < 2 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Millisecond):
+			}
+		}
+		j.add(finding{Tool: "x"})
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := wk.runScanners(ctx, job, []jobScanner{{"a", scan}, {"b", scan}}, prog); err != nil {
+		t.Fatal(err)
+	}
+	if len(job.findings) != 2 {
+		t.Errorf("got %d findings, want 2", len(job.findings))
+	}
+	if _, timings := prog.snapshot(); len(timings) != 2 {
+		t.Errorf("timings = %v, want both scanners", timings)
+	}
+	if ctx.Err() != nil {
+		t.Error("scanners did not run concurrently")
+	}
+}
+
+func TestJobScannersFeatureFlags(t *testing.T) {
+	wk := &Worker{cfg: config.Config{Scanners: []string{"gitleaks", "trufflehog", "syft"}}}
+	job := &scanJob{flags: featureFlags{"scanner.trufflehog": false, "scanner.syft": true}}
+	var names []string
+	for _, s := range wk.jobScanners(context.Background(), job) {
+		names = append(names, s.name)
+	}
+	if len(names) != 2 || names[0] != "gitleaks" || names[1] != "syft" {
+		t.Fatalf("scanners = %v", names)
+	}
+}
+
+// previousScanStore answers PreviousScan; other store methods are not used.
+type previousScanStore struct {
+	store
+	id, sha string
+}
+
+func (s previousScanStore) PreviousScan(context.Context, string) (string, string, error) {
+	return s.id, s.sha, nil
+}
+
+func TestUnchangedSince(t *testing.T) {
+	st := previousScanStore{id: "prev", sha: "abc"}
+	for _, tc := range []struct {
+		name string
+		spec jobSpec
+		sha  string
+		want string
+	}{
+		{"unchanged", jobSpec{}, "abc", "prev"},
+		{"moved", jobSpec{}, "def", ""},
+		{"forced", jobSpec{Force: true}, "abc", ""},
+		{"pinned commit", jobSpec{CommitSHA: "abc"}, "abc", ""},
+		{"preview deployment", jobSpec{DeploymentURL: "https://pr-1.preview.example.com"}, "abc", ""},
+		{"unknown head", jobSpec{}, "", ""},
+	} {
+		if got := unchangedSince(context.Background(), st, "job", tc.spec, tc.sha); got != tc.want {
+			t.Errorf("%s: unchangedSince = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if got := unchangedSince(context.Background(), previousScanStore{}, "job", jobSpec{}, "abc"); got != "" {
+		t.Errorf("without a previous scan: unchangedSince = %q, want empty", got)
+	}
+}
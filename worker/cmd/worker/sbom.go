@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// syft inventories the checkout's packages into a CycloneDX SBOM, stored as
+// the job's "sbom" artifact and served by the API per repo. The packages are
+// also recorded one per row, so the API can find every repo that pins one.
+// It reports no findings.
+const (
+	artifactSBOM    = "sbom"
+	sbomContentType = "application/vnd.cyclonedx+json"
+	// maxJobPackages bounds the packages recorded per scan.
+	maxJobPackages = 20000
+)
+
+// pkg is one package found by syft.
+type pkg struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	PURL      string `json:"purl,omitempty"`
+	Path      string `json:"path"`
+}
+
+func (wk *Worker) runSyft(ctx context.Context, job *scanJob) error {
+	// The SBOM goes next to the checkout, not into it, so the scanners
+	// running alongside do not see it.
+	path := filepath.Join(filepath.Dir(job.dir), "sbom.cdx.json")
+	defer os.Remove(path)
+	env := append(wk.cfg.ScannerProxySetting().Environ(), "SYFT_CHECK_FOR_APP_UPDATE=false")
+	if _, err := runCmdJSON(ctx, "syft", []string{"scan", "dir:.", "--quiet", "--output", "cyclonedx-json=" + path}, job.dir, env); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := checkCycloneDX(data); err != nil {
+		return err
+	}
+	wk.saveArtifact(ctx, job.msg.JobID, artifactSBOM, "sbom.cdx.json", sbomContentType, data)
+	pkgs, err := sbomPackages(data)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) > maxJobPackages {
+		slog.WarnContext(ctx, "too many packages; recording the first ones", "packages", len(pkgs), "max", maxJobPackages)
+		pkgs = pkgs[:maxJobPackages]
+	}
+	return wk.store.SetPackages(ctx, job.msg.JobID, pkgs)
+}
+
+// checkCycloneDX rejects output that is not a CycloneDX JSON document, so a
+// misbehaving syft cannot replace a repo's last good SBOM.
+func checkCycloneDX(data []byte) error {
+	var doc struct {
+		BOMFormat string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("syft parse error: %v", err)
+	}
+	if doc.BOMFormat != "CycloneDX" {
+		return fmt.Errorf("syft output is not a CycloneDX SBOM (bomFormat %q)", doc.BOMFormat)
+	}
+	return nil
+}
+
+// sbomPackages lists the SBOM's library and application components, deduped
+// by ecosystem, name, version and path. The ecosystem is the purl type, such
+// as npm or maven.
+func sbomPackages(data []byte) ([]pkg, error) {
+	var doc struct {
+		Components []struct {
+			Type       string `json:"type"`
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			PURL       string `json:"purl"`
+			Properties []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"properties"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("syft parse error: %v", err)
+	}
+	pkgs := []pkg{}
+	seen := map[pkg]bool{}
+	for _, c := range doc.Components {
+		if c.Name == "" || c.Type != "library" && c.Type != "application" && c.Type != "framework" {
+			continue
+		}
+		p := pkg{Name: c.Name, Version: c.Version, PURL: c.PURL}
+		if rest, ok := strings.CutPrefix(c.PURL, "pkg:"); ok {
+			p.Ecosystem, _, _ = strings.Cut(rest, "/")
+		}
+		for _, prop := range c.Properties {
+			if prop.Name == "syft:location:0:path" {
+				p.Path = strings.TrimPrefix(prop.Value, "/")
+				break
+			}
+		}
+		key := p
+		key.PURL = ""
+		if !seen[key] {
+			seen[key] = true
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestCheckCycloneDX(t *testing.T) {
+	if err := checkCycloneDX([]byte(`{"bomFormat":"CycloneDX","specVersion":"1.5","components":[]}`)); err != nil {
+		t.Errorf("valid SBOM rejected: %v", err)
+	}
+	for _, bad := range []string{`{"spdxVersion":"SPDX-2.3"}`, `not json`, ``} {
+		if checkCycloneDX([]byte(bad)) == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestSBOMPackages(t *testing.T) {
+	doc := `{"bomFormat":"CycloneDX","components":[
+		{"type":"library","name":"log4j-core","version":"2.14.1","purl":"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1",
+		 "properties":[{"name":"syft:package:type","value":"java-archive"},{"name":"syft:location:0:path","value":"/lib/log4j-core-2.14.1.jar"}]},
+		{"type":"library","name":"log4j-core","version":"2.14.1","purl":"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1?type=jar",
+		 "properties":[{"name":"syft:location:0:path","value":"/lib/log4j-core-2.14.1.jar"}]},
+		{"type":"library","name":"lodash","version":"4.17.20","purl":"pkg:npm/lodash@4.17.20"},
+		{"type":"file","name":"/etc/passwd"},
+		{"type":"library","name":""}
+	]}`
+	pkgs, err := sbomPackages([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pkg{
+		{Ecosystem: "maven", Name: "log4j-core", Version: "2.14.1", PURL: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1", Path: "lib/log4j-core-2.14.1.jar"},
+		{Ecosystem: "npm", Name: "lodash", Version: "4.17.20", PURL: "pkg:npm/lodash@4.17.20"},
+	}
+	if len(pkgs) != len(want) {
+		t.Fatalf("packages = %+v", pkgs)
+	}
+	for i := range want {
+		if pkgs[i] != want[i] {
+			t.Errorf("package %d = %+v, want %+v", i, pkgs[i], want[i])
+		}
+	}
+	if _, err := sbomPackages([]byte(`not json`)); err == nil {
+		t.Error("accepted invalid JSON")
+	}
+}
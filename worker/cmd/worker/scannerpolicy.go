@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+// Each scanner runs under a timeout and a failure policy, taken from the
+// repo's settings and then the worker's scanners.timeouts and
+// scanners.on_failure. Both are keyed by scanner name, with "*" covering
+// the scanners not named. Without a timeout a scanner is only bounded by
+// limits.scan_timeout_min; without a policy its failure is recorded and the
+// job still succeeds.
+
+// scannerSetting returns the first value for name, or failing that "*", in
+// each of the maps in turn.
+func scannerSetting(name string, maps ...map[string]string) string {
+	for _, m := range maps {
+		if v, ok := m[name]; ok {
+			return v
+		}
+		if v, ok := m["*"]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// scannerTimeout is how long scanner name may run for the job; 0 means no
+// limit of its own.
+func (wk *Worker) scannerTimeout(job *scanJob, name string) time.Duration {
+	d, err := time.ParseDuration(scannerSetting(name, job.settings.ScannerTimeouts, wk.cfg.ScannerTimeouts))
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// failsJob reports whether scanner name failing fails the job.
+func (wk *Worker) failsJob(job *scanJob, name string) bool {
+	return scannerSetting(name, job.settings.ScannerOnFailure, wk.cfg.ScannerOnFailure) == config.ScannerFailJob
+}
+
+// runScanner runs one scanner under its timeout. Running out of time is an
+// error of its own, whatever the scanner made of being cancelled, so it is
+// not mistaken for the OOM killer.
+func (wk *Worker) runScanner(ctx context.Context, job *scanJob, s jobScanner) error {
+	d := wk.scannerTimeout(job, s.name)
+	if d == 0 {
+		return s.run(ctx, job)
+	}
+	sctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	err := s.run(sctx, job)
+	if errors.Is(sctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("timed out after %s", d)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+func TestScannerSetting(t *testing.T) {
+	repo := map[string]string{"semgrep": "30m"}
+	cfg := map[string]string{"*": "10m", "trivy": "20m"}
+	for name, want := range map[string]string{"semgrep": "30m", "trivy": "20m", "gitleaks": "10m"} {
+		if got := scannerSetting(name, repo, cfg); got != want {
+			t.Errorf("scannerSetting(%s) = %q, want %q", name, got, want)
+		}
+	}
+	// A repo's "*" outranks the worker's setting for a named scanner.
+	if got := scannerSetting("trivy", map[string]string{"*": "5m"}, cfg); got != "5m" {
+		t.Errorf("repo default = %q, want 5m", got)
+	}
+	if got := scannerSetting("trivy", nil, nil); got != "" {
+		t.Errorf("unset = %q, want empty", got)
+	}
+}
+
+func TestRunScannersFailurePolicy(t *testing.T) {
+	wk := &Worker{cfg: config.Config{
+		ScannerWorkers:   2,
+		ScannerTimeouts:  map[string]string{"slow": "10ms"},
+		ScannerOnFailure: map[string]string{"*": config.ScannerContinue},
+	}}
+	slow := func(ctx context.Context, _ *scanJob) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	broken := func(context.Context, *scanJob) error { return errors.New("exit status 2") }
+	scanners := []jobScanner{{"slow", slow}, {"broken", broken}}
+
+	job := &scanJob{}
+	if err := wk.runScanners(context.Background(), job, scanners, newProgress(&stageStore{}, "job", []string{"slow", "broken"})); err != nil {
+		t.Fatalf("continue policy: %v", err)
+	}
+	if got := job.failedScanners(); len(got) != 2 {
+		t.Errorf("failed scanners = %v, want both", got)
+	}
+
+	job = &scanJob{settings: repoSettings{ScannerOnFailure: map[string]string{"slow": config.ScannerFailJob}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := wk.runScanners(ctx, job, scanners, newProgress(&stageStore{}, "job", []string{"slow", "broken"}))
+	var failed scannerError
+	if !errors.As(err, &failed) || failed.name != "slow" {
+		t.Fatalf("fail policy: err = %v, want slow to fail the job", err)
+	}
+	if failed.Error() != "slow failed: timed out after 10ms" {
+		t.Errorf("error = %q", failed.Error())
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"argus/worker/internal/config"
+	"argus/worker/internal/severity"
 )
 
 type semgrepOut struct {
@@ -28,8 +31,54 @@ type semgrepOut struct {
 	} `json:"results"`
 }
 
-func runSemgrep(ctx context.Context, db *pgxpool.Pool, msg JobMsg, repoDir string) error {
-	out, err := runCmdJSON(ctx, "semgrep", []string{"scan", "--config", "auto", "--json", "--quiet", "--timeout", "120", "."}, repoDir)
+func (wk *Worker) runSemgrep(ctx context.Context, job *scanJob) error {
+	semgrepConfig := wk.cfg.SemgrepConfig
+	if c := job.settings.SemgrepConfig; c != "" {
+		if wk.cfg.Offline && c != config.SemgrepDetect {
+			slog.WarnContext(ctx, "repo semgrep config needs the registry; using detect in offline mode", "semgrep_config", c)
+			c = config.SemgrepDetect
+		}
+		semgrepConfig = c
+	}
+	packs := []string{semgrepConfig}
+	if semgrepConfig == config.SemgrepDetect {
+		packs = detectSemgrepPacks(job.dir)
+		if wk.cfg.Offline {
+			var missing []string
+			packs, missing = offlineSemgrepPacks(wk.cfg.OfflineSemgrepRules, packs)
+			if len(missing) > 0 {
+				slog.WarnContext(ctx, "semgrep rule packs missing from offline bundle", "packs", missing)
+			}
+		}
+	}
+	configs, recorded, err := semgrepConfigs(job.dir, packs, job.semgrepRules, job.semgrepRulesMode)
+	if err != nil {
+		return err
+	}
+	if err := wk.store.SetRulePacks(ctx, job.msg.JobID, recorded); err != nil {
+		return err
+	}
+	if len(configs) == 0 {
+		slog.InfoContext(ctx, "semgrep skipped: no supported languages detected")
+		return nil
+	}
+	slog.InfoContext(ctx, "semgrep rule packs selected", "packs", recorded)
+
+	if dir, release, ok := wk.cache.use(cacheSemgrep); ok {
+		defer release()
+		configs = cachedSemgrepConfigs(dir, configs)
+	}
+	args := []string{"scan"}
+	for _, c := range configs {
+		args = append(args, "--config", c)
+	}
+	if wk.cfg.Offline {
+		args = append(args, "--metrics", "off", "--disable-version-check")
+	}
+	args = append(args, job.settings.semgrepPathArgs()...)
+	args = append(args, "--json", "--quiet", "--timeout", strconv.Itoa(wk.cfg.SemgrepTimeout), ".")
+	out, err := runCmdJSON(ctx, "semgrep", args, job.diffDir(), wk.cfg.ScannerProxySetting().Environ())
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "semgrep.json", "application/json", out)
 	var parsed semgrepOut
 	if perr := json.Unmarshal(out, &parsed); perr != nil {
 		if err != nil {
@@ -39,19 +88,18 @@ func runSemgrep(ctx context.Context, db *pgxpool.Pool, msg JobMsg, repoDir strin
 	}
 
 	for _, r := range parsed.Results {
-		sev := strings.ToUpper(strings.TrimSpace(r.Extra.Severity))
-		if sev == "" {
-			sev = "MEDIUM"
-		}
-		title := r.CheckID
+		sev := severity.Normalize("semgrep", r.Extra.Severity)
+		parts := map[string]string{"rule_id": r.CheckID, "message": r.Extra.Message, "path": r.Path, "line": strconv.Itoa(r.Start.Line), "severity": sev}
+		title := job.titles.render(titleSemgrep, parts)
 		desc := r.Extra.Message
-		fpv := fp("semgrep", r.CheckID, r.Path, fmt.Sprintf("%d", r.Start.Line), desc)
+		fpv := job.fp("semgrep", r.CheckID, r.Path, fmt.Sprintf("%d", r.Start.Line), desc)
 		filePath := r.Path
 		ls, le := r.Start.Line, r.End.Line
-		_ = insertFinding(ctx, db, msg.RepoID, msg.JobID, "semgrep", sev, title, &filePath, &ls, &le, &fpv, &desc, map[string]any{
-			"check_id": r.CheckID,
-			"metadata": r.Extra.Metadata,
-		})
+		job.add(finding{Tool: "semgrep", Severity: sev, label: r.Extra.Severity, Title: title, FilePath: &filePath, LineStart: &ls, LineEnd: &le, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"check_id":    r.CheckID,
+			"metadata":    r.Extra.Metadata,
+			"title_parts": parts,
+		}})
 	}
 	return err
 }
@@ -65,8 +113,9 @@ type gitleaksOut []struct {
 	Severity    string `json:"Severity"`
 }
 
-func runGitleaks(ctx context.Context, db *pgxpool.Pool, msg JobMsg, repoDir string) error {
-	out, err := runCmdJSON(ctx, "gitleaks", []string{"detect", "--source", ".", "--no-git", "--report-format", "json", "--redact"}, repoDir)
+func (wk *Worker) runGitleaks(ctx context.Context, job *scanJob) error {
+	out, err := runCmdJSON(ctx, "gitleaks", []string{"detect", "--source", ".", "--no-git", "--report-format", "json", "--redact"}, job.diffDir(), nil)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "gitleaks.json", "application/json", out)
 	raw := strings.TrimSpace(string(out))
 	if raw == "" {
 		return err
@@ -81,19 +130,18 @@ func runGitleaks(ctx context.Context, db *pgxpool.Pool, msg JobMsg, repoDir stri
 	}
 
 	for _, f := range parsed {
-		sev := strings.ToUpper(strings.TrimSpace(f.Severity))
-		if sev == "" {
-			sev = "HIGH"
-		}
-		title := "Secret detected: " + f.RuleID
+		sev := severity.Normalize("gitleaks", f.Severity)
+		parts := map[string]string{"rule_id": f.RuleID, "description": f.Description, "path": f.File, "line": strconv.Itoa(f.StartLine), "severity": sev}
+		title := job.titles.render(titleGitleaks, parts)
 		desc := f.Description
-		fpv := fp("gitleaks", f.RuleID, f.File, fmt.Sprintf("%d", f.StartLine))
+		fpv := job.fp("gitleaks", f.RuleID, f.File, fmt.Sprintf("%d", f.StartLine))
 		filePath := f.File
 		ls, le := f.StartLine, f.EndLine
-		_ = insertFinding(ctx, db, msg.RepoID, msg.JobID, "gitleaks", sev, title, &filePath, &ls, &le, &fpv, &desc, map[string]any{
-			"rule_id":  f.RuleID,
-			"redacted": true,
-		})
+		job.add(finding{Tool: "gitleaks", Severity: sev, label: f.Severity, Title: title, FilePath: &filePath, LineStart: &ls, LineEnd: &le, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+			"rule_id":     f.RuleID,
+			"redacted":    true,
+			"title_parts": parts,
+		}})
 	}
 	return err
 }
@@ -104,14 +152,21 @@ type trivyOut struct {
 		Class           string `json:"Class"`
 		Type            string `json:"Type"`
 		Vulnerabilities []struct {
-			VulnerabilityID  string `json:"VulnerabilityID"`
-			PkgName          string `json:"PkgName"`
-			InstalledVersion string `json:"InstalledVersion"`
-			FixedVersion     string `json:"FixedVersion"`
-			Severity         string `json:"Severity"`
-			Title            string `json:"Title"`
-			Description      string `json:"Description"`
-			PrimaryURL       string `json:"PrimaryURL"`
+			VulnerabilityID  string   `json:"VulnerabilityID"`
+			PkgName          string   `json:"PkgName"`
+			InstalledVersion string   `json:"InstalledVersion"`
+			FixedVersion     string   `json:"FixedVersion"`
+			Severity         string   `json:"Severity"`
+			Title            string   `json:"Title"`
+			Description      string   `json:"Description"`
+			PrimaryURL       string   `json:"PrimaryURL"`
+			CweIDs           []string `json:"CweIDs"`
+			// SeveritySource names the CVSS entry trivy took Severity
+			// from, such as nvd or ghsa.
+			SeveritySource string `json:"SeveritySource"`
+			CVSS           map[string]struct {
+				V3Vector string `json:"V3Vector"`
+			} `json:"CVSS"`
 		} `json:"Vulnerabilities"`
 		Misconfigurations []struct {
 			ID            string `json:"ID"`
@@ -130,8 +185,25 @@ type trivyOut struct {
 	} `json:"Results"`
 }
 
-func runTrivy(ctx context.Context, db *pgxpool.Pool, msg JobMsg, repoDir string) error {
-	out, err := runCmdJSON(ctx, "trivy", []string{"fs", "--format", "json", "--quiet", "--scanners", "vuln,misconfig,secret", "--timeout", "8m", "."}, repoDir)
+func (wk *Worker) runTrivy(ctx context.Context, job *scanJob) error {
+	args := []string{"fs", "--format", "json", "--quiet", "--scanners", "vuln,misconfig,secret", "--timeout", wk.cfg.TrivyTimeout}
+	if wk.cfg.Concurrency > 1 {
+		// Parallel trivy processes contend for the lock on the on-disk scan
+		// cache; fs scans gain little from it anyway.
+		args = append(args, "--cache-backend", "memory")
+	}
+	if wk.cfg.ScannerEnabled("terraform") && job.wants("terraform") && job.settings.allows("terraform") {
+		args = append(args, "--misconfig-scanners", trivyMisconfigScanners)
+	}
+	if wk.cfg.Offline {
+		args = append(args, offlineTrivyArgs(wk.cfg)...)
+	} else if dir, release, ok := wk.cache.use(cacheTrivy); ok {
+		defer release()
+		args = append(args, "--cache-dir", dir, "--skip-db-update", "--skip-java-db-update")
+	}
+	args = append(args, job.settings.trivySkipArgs()...)
+	out, err := runCmdJSON(ctx, "trivy", append(args, "."), job.dir, wk.cfg.ScannerProxySetting().Environ())
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "trivy.json", "application/json", out)
 	var parsed trivyOut
 	if perr := json.Unmarshal(out, &parsed); perr != nil {
 		if err != nil {
@@ -139,48 +211,56 @@ func runTrivy(ctx context.Context, db *pgxpool.Pool, msg JobMsg, repoDir string)
 		}
 		return fmt.Errorf("trivy parse error: %v", perr)
 	}
+	job.addTrivyResults(parsed)
+	return err
+}
 
+// addTrivyResults adds trivy's vulnerabilities and misconfigurations, from
+// a filesystem or an image scan.
+func (j *scanJob) addTrivyResults(parsed trivyOut) {
 	for _, r := range parsed.Results {
 		for _, v := range r.Vulnerabilities {
-			sev := strings.ToUpper(strings.TrimSpace(v.Severity))
-			if sev == "" {
-				sev = "MEDIUM"
+			vector := v.CVSS[v.SeveritySource].V3Vector
+			if vector == "" {
+				vector = v.CVSS["nvd"].V3Vector
 			}
-			title := v.VulnerabilityID + " in " + v.PkgName
+			sev := severity.Of("trivy", v.Severity, vector)
+			target := filepath.ToSlash(r.Target)
+			parts := map[string]string{"id": v.VulnerabilityID, "package": v.PkgName, "installed": v.InstalledVersion, "fixed": v.FixedVersion, "title": v.Title, "target": target, "severity": sev}
+			title := j.titles.render(titleTrivyVuln, parts)
 			desc := v.Title
 			if desc == "" {
 				desc = v.Description
 			}
-			fpv := fp("trivy:vuln", v.VulnerabilityID, v.PkgName, v.InstalledVersion, r.Target)
-			target := filepath.ToSlash(r.Target)
-			_ = insertFinding(ctx, db, msg.RepoID, msg.JobID, "trivy", sev, title, &target, nil, nil, &fpv, &desc, map[string]any{
-				"pkg":       v.PkgName,
-				"installed": v.InstalledVersion,
-				"fixed":     v.FixedVersion,
-				"url":       v.PrimaryURL,
-				"class":     r.Class,
-				"type":      r.Type,
-			})
+			fpv := j.fp("trivy:vuln", v.VulnerabilityID, v.PkgName, v.InstalledVersion, r.Target)
+			j.add(finding{Tool: "trivy", Severity: sev, label: v.Severity, cvss: vector, Title: title, FilePath: &target, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+				"pkg":         v.PkgName,
+				"installed":   v.InstalledVersion,
+				"fixed":       v.FixedVersion,
+				"url":         v.PrimaryURL,
+				"cwe":         v.CweIDs,
+				"class":       r.Class,
+				"type":        r.Type,
+				"title_parts": parts,
+			}})
 		}
 
 		for _, m := range r.Misconfigurations {
-			sev := strings.ToUpper(strings.TrimSpace(m.Severity))
-			if sev == "" {
-				sev = "MEDIUM"
-			}
-			title := m.ID + ": " + m.Title
-			desc := m.Description
-			fpv := fp("trivy:misconfig", m.ID, r.Target, fmt.Sprintf("%d", m.CauseMetadata.StartLine))
+			sev := severity.Normalize("trivy", m.Severity)
 			target := filepath.ToSlash(r.Target)
+			parts := map[string]string{"id": m.ID, "title": m.Title, "target": target, "line": strconv.Itoa(m.CauseMetadata.StartLine), "resource": m.CauseMetadata.Resource, "severity": sev}
+			title := j.titles.render(titleTrivyMisconfig, parts)
+			desc := m.Description
+			fpv := j.fp("trivy:misconfig", m.ID, r.Target, fmt.Sprintf("%d", m.CauseMetadata.StartLine))
 			ls, le := m.CauseMetadata.StartLine, m.CauseMetadata.EndLine
-			_ = insertFinding(ctx, db, msg.RepoID, msg.JobID, "trivy", sev, title, &target, &ls, &le, &fpv, &desc, map[string]any{
-				"id":       m.ID,
-				"url":      m.PrimaryURL,
-				"resource": m.CauseMetadata.Resource,
-				"provider": m.CauseMetadata.Provider,
-				"service":  m.CauseMetadata.Service,
-			})
+			j.add(finding{Tool: "trivy", Severity: sev, label: m.Severity, Title: title, FilePath: &target, LineStart: &ls, LineEnd: &le, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+				"id":          m.ID,
+				"url":         m.PrimaryURL,
+				"resource":    m.CauseMetadata.Resource,
+				"provider":    m.CauseMetadata.Provider,
+				"service":     m.CauseMetadata.Service,
+				"title_parts": parts,
+			}})
 		}
 	}
-	return err
 }
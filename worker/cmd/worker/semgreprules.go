@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Org Semgrep rule modes (orgs.semgrep_rules_mode).
+const (
+	semgrepRulesAdd     = "add"
+	semgrepRulesReplace = "replace"
+)
+
+// orgRuleFile is one of the org's enabled Semgrep rule files.
+type orgRuleFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// semgrepRulesDir holds a job's org rule files, next to the checkout rather
+// than inside it so the other scanners do not scan them.
+const semgrepRulesDir = "semgrep-rules"
+
+// semgrepConfigs writes the org rule files beside repoDir and returns the
+// --config values for semgrep plus the names to record as the job's rule
+// packs. In replace mode the org's files stand in for packs, unless the org
+// has none.
+func semgrepConfigs(repoDir string, packs []string, rules []orgRuleFile, mode string) (configs, recorded []string, err error) {
+	if mode == semgrepRulesReplace && len(rules) > 0 {
+		packs = nil
+	}
+	configs = append(configs, packs...)
+	recorded = append(recorded, packs...)
+	if len(rules) == 0 {
+		return configs, recorded, nil
+	}
+	dir := filepath.Join(filepath.Dir(repoDir), semgrepRulesDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	for _, r := range rules {
+		name := r.Name + ".yml"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(r.Content), 0o644); err != nil {
+			return nil, nil, fmt.Errorf("write semgrep rules %s: %w", r.Name, err)
+		}
+		// semgrep prefixes rule IDs with the config path, so the path is
+		// relative to the checkout (semgrep's working directory) to keep
+		// IDs, and the fingerprints built from them, the same across jobs.
+		configs = append(configs, filepath.Join("..", semgrepRulesDir, name))
+		recorded = append(recorded, "org:"+r.Name)
+	}
+	return configs, recorded, nil
+}
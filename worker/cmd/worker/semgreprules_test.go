@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSemgrepConfigs(t *testing.T) {
+	rules := []orgRuleFile{{Name: "no-eval", Content: "rules: []\n"}}
+	for _, tc := range []struct {
+		mode     string
+		rules    []orgRuleFile
+		configs  []string
+		recorded []string
+	}{
+		{semgrepRulesAdd, rules, []string{"p/golang", "../semgrep-rules/no-eval.yml"}, []string{"p/golang", "org:no-eval"}},
+		{semgrepRulesReplace, rules, []string{"../semgrep-rules/no-eval.yml"}, []string{"org:no-eval"}},
+		{semgrepRulesReplace, nil, []string{"p/golang"}, []string{"p/golang"}},
+	} {
+		repoDir := filepath.Join(t.TempDir(), "repo")
+		configs, recorded, err := semgrepConfigs(repoDir, []string{"p/golang"}, tc.rules, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(configs, tc.configs) || !reflect.DeepEqual(recorded, tc.recorded) {
+			t.Errorf("%s: got %v %v, want %v %v", tc.mode, configs, recorded, tc.configs, tc.recorded)
+		}
+		if len(tc.rules) == 0 {
+			continue
+		}
+		got, err := os.ReadFile(filepath.Join(repoDir, tc.configs[len(tc.configs)-1]))
+		if err != nil || string(got) != rules[0].Content {
+			t.Errorf("%s: rule file = %q, %v", tc.mode, got, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "argus/worker/internal/severity"
+
+// recordSeverity keeps what a finding's severity was rated from: the
+// scanner's own label, and the CVSS vector and base score when it had one.
+// Findings whose evidence is not a JSON object are left as they are.
+func (f *finding) recordSeverity() {
+	ev, ok := f.Evidence.(map[string]any)
+	if !ok {
+		return
+	}
+	if f.label != "" {
+		ev["severity_original"] = f.label
+	}
+	if score, err := severity.Score(f.cvss); err == nil {
+		ev["cvss_vector"] = f.cvss
+		ev["cvss_score"] = score
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// On SIGTERM or SIGINT the worker stops taking jobs and gives in-flight jobs
+// worker.shutdown_grace to finish. Jobs still running after that are
+// cancelled and requeued, and their messages go back to the queue, so a
+// rollout never leaves a job running on a worker that is gone.
+
+// errShutdown is the cancellation cause of jobs cut short by shutdown.
+var errShutdown = errors.New("worker shutting down")
+
+const shutdownOpTimeout = 10 * time.Second
+
+// interrupted reports whether jobCtx was cancelled by shutdown.
+func interrupted(jobCtx context.Context) bool {
+	return errors.Is(context.Cause(jobCtx), errShutdown)
+}
+
+// drain waits for the consumers to return, cancelling their jobs with
+// errShutdown once grace has passed.
+func drain(wg *sync.WaitGroup, grace time.Duration, abort context.CancelCauseFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(grace):
+	}
+	slog.Warn("shutdown grace expired; requeueing in-flight jobs", "grace", grace)
+	abort(errShutdown)
+	<-done
+}
+
+// requeueInterrupted resets a job cut short by shutdown. Its message stays in
+// flight until shutdown returns it to the queue; the attempt is
+// not counted against retries.max_attempts.
+func (wk *Worker) requeueInterrupted(ctx context.Context, msg JobMsg) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownOpTimeout)
+	defer cancel()
+	if err := wk.store.RequeueJob(ctx, msg.JobID, "worker shut down during the scan; requeued"); err != nil {
+		slog.ErrorContext(ctx, "requeue interrupted job failed", "err", err)
+		return
+	}
+	slog.WarnContext(ctx, "job interrupted by shutdown; requeued")
+}
+
+// shutdown returns whatever this worker has left in flight to the queue, removes the worker from the registry and deletes its workspaces.
+// It runs after the consumers have returned and the heartbeat has stopped.
+func (wk *Worker) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownOpTimeout)
+	defer cancel()
+	name := wk.owner.name()
+	if n, err := wk.queue.Reclaim(ctx, name); err != nil {
+		slog.Error("requeue in-flight messages failed; the reaper will recover them", "err", err)
+	} else if n > 0 {
+		slog.Info("returned in-flight messages to the queue", "jobs", n)
+	}
+	if err := wk.queue.Deregister(ctx, name); err != nil {
+		slog.Warn("worker deregistration failed", "err", err)
+	}
+	if err := os.RemoveAll(wk.owner.dir(wk.cfg.WorkspaceRoot)); err != nil {
+		slog.Warn("workspace cleanup failed", "err", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import "context"
+
+// Result submission modes (results.mode).
+const (
+	resultsDB  = "db"
+	resultsAPI = "api"
+)
+
+// store persists job state and results. dbStore writes to Postgres directly;
+// apiStore submits everything through the API's internal endpoints so a
+// worker can run without database credentials.
+type store interface {
+	// StartJob marks the job running on worker and returns the parameters
+	// it was queued with.
+	StartJob(ctx context.Context, jobID string, attempt int, worker string) (jobSpec, error)
+	// FinishJob marks the job succeeded, naming the scanners that failed
+	// under the continue policy.
+	FinishJob(ctx context.Context, jobID string, failedScanners []string) error
+	// PreviousScan returns the latest earlier successful scan of the job's
+	// repo with the same ref and scanner selection, and the commit it
+	// scanned; empty without one.
+	PreviousScan(ctx context.Context, jobID string) (id, commitSHA string, err error)
+	// SkipJob finishes a job as skipped_unchanged with the reason.
+	SkipJob(ctx context.Context, jobID, reason string) error
+	FailJob(ctx context.Context, jobID, reason string) error
+	// RequeueJob resets a job for another attempt, discarding partial
+	// findings, artifacts and the archived log, with their objects in the
+	// artifact store.
+	RequeueJob(ctx context.Context, jobID, note string) error
+
+	Repo(ctx context.Context, repoID string) (RepoRow, error)
+	// OrgSettings returns the scan settings of repoID's org; jobs without a
+	// repo, and repos without an org, get the zero value.
+	OrgSettings(ctx context.Context, repoID string) orgSettings
+	Upload(ctx context.Context, jobID string) (format string, data []byte, err error)
+	DeleteUpload(ctx context.Context, jobID string)
+	// ImageCredentials returns an image scan's registry credentials, empty
+	// for public images.
+	ImageCredentials(ctx context.Context, jobID string) (username, password string, err error)
+	DeleteImageCredentials(ctx context.Context, jobID string)
+
+	SetCloneResult(ctx context.Context, jobID, strategy, commitSHA string) error
+	SetRulePacks(ctx context.Context, jobID string, packs []string) error
+	// SetRepoProfile records the size and languages of the job's repo, which
+	// the API uses to size its later jobs.
+	SetRepoProfile(ctx context.Context, jobID string, p repoProfile) error
+	// SetPackages replaces the packages recorded for the job. Jobs without
+	// a repo record none.
+	SetPackages(ctx context.Context, jobID string, pkgs []pkg) error
+	// SetToolVersions records the versions of the tools the job runs.
+	SetToolVersions(ctx context.Context, jobID string, versions map[string]string) error
+	// SetStage records the job's current stage, its progress percentage and
+	// the milliseconds spent in each finished stage.
+	SetStage(ctx context.Context, jobID, stage string, progress int, timings map[string]int64) error
+	AddFindings(ctx context.Context, repoID, jobID string, findings []finding) error
+	// NewFindings returns the job's findings that no earlier job of its
+	// repo reported, most severe first.
+	NewFindings(ctx context.Context, jobID string) ([]finding, error)
+	// BranchFindings returns the job's findings that the repo's default
+	// branch does not have, most severe first.
+	BranchFindings(ctx context.Context, jobID string) ([]finding, error)
+	// ResolveFindings records that the job, a full scan of the default
+	// branch, saw its findings there, marks the repo's open findings of
+	// tools that it did not report as fixed, and returns how many.
+	ResolveFindings(ctx context.Context, repoID, jobID string, tools []string) (int64, error)
+	SaveArtifact(ctx context.Context, jobID string, a artifact) error
+	// AppendJobLog stores lines of the job's log; JobLog reads them all
+	// back. ArchiveJobLog records that the log was moved to object in the
+	// artifact store and drops the lines.
+	AppendJobLog(ctx context.Context, jobID string, lines []logLine) error
+	JobLog(ctx context.Context, jobID string) ([]logLine, error)
+	ArchiveJobLog(ctx context.Context, jobID, object string) error
+	// WriteSummary stores a finished job's summary document as an artifact.
+	WriteSummary(ctx context.Context, jobID string) error
+	// PublishJobEvents queues webhook deliveries for a finished job.
+	PublishJobEvents(ctx context.Context, jobID string) error
+
+	Ping(ctx context.Context) error
+}
+
+// jobSpec holds the parameters a job was queued with.
+type jobSpec struct {
+	// Scanners is the selection from the scan request; empty means all.
+	Scanners []string `json:"scanners"`
+	// Ref overrides the repo's default_ref; empty means the default.
+	Ref string `json:"ref"`
+	// CommitSHA pins the scan to a commit instead of Ref's head.
+	CommitSHA string `json:"commit_sha"`
+	// DeploymentURL is a preview deployment of the commit for nuclei.
+	DeploymentURL string `json:"deployment_url"`
+	// Image is the container image an image scan runs trivy against.
+	Image string `json:"image"`
+	// BaseSHA makes the scan incremental: semgrep and gitleaks only see
+	// the files changed since this commit.
+	BaseSHA string `json:"base_sha"`
+	// Force scans even when the commit is unchanged since the previous
+	// successful scan.
+	Force bool `json:"force"`
+	// Flags are the feature flags as the job's repo sees them.
+	Flags featureFlags `json:"flags"`
+	// PullRequest is the number of the pull request whose head the job
+	// scans, or 0.
+	PullRequest int `json:"pull_request"`
+}
+
+// featureFlags gate scanners per org and repo: a scanner whose
+// scanner.<name> flag is off does not run. Anything without a flag is not
+// gated.
+type featureFlags map[string]bool
+
+func (f featureFlags) enabled(name string) bool {
+	on, ok := f[name]
+	return !ok || on
+}
+
+// finding is one scanner result, in the shape the API's internal findings
+// endpoint accepts.
+type finding struct {
+	Tool        string  `json:"tool"`
+	Severity    string  `json:"severity"`
+	Title       string  `json:"title"`
+	FilePath    *string `json:"file_path,omitempty"`
+	LineStart   *int    `json:"line_start,omitempty"`
+	LineEnd     *int    `json:"line_end,omitempty"`
+	Fingerprint *string `json:"fingerprint,omitempty"` // salted for the org
+	Description *string `json:"description,omitempty"`
+	Evidence    any     `json:"evidence,omitempty"`
+
+	// label is the scanner's own severity and cvss its CVSS vector, if
+	// any; scanJob.add records them in the evidence.
+	label string
+	cvss  string
+}
+
+type orgSettings struct {
+	TitleTemplates  titleTemplates `json:"title_templates"`
+	FingerprintSalt string         `json:"fingerprint_salt"`
+	// SemgrepRules are the org's enabled rule files; SemgrepRulesMode says
+	// whether they run alongside the selected packs or instead of them.
+	SemgrepRules     []orgRuleFile `json:"semgrep_rules"`
+	SemgrepRulesMode string        `json:"semgrep_rules_mode"`
+}
+
+type artifact struct {
+	Kind        string
+	Name        string
+	ContentType string
+	Data        []byte
+	// Object is the key Data was uploaded under to the artifact store; the
+	// row then records it instead of the data.
+	Object string
+}
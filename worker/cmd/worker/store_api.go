@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// findingBatchSize caps the findings sent in one request so a noisy
+	// scanner does not produce a single huge body.
+	findingBatchSize = 500
+	logBatchSize     = 1000
+	apiStoreTimeout  = 2 * time.Minute
+)
+
+// apiStore submits job state and results to the API's /internal endpoints,
+// authenticated with the worker token. The API derives the repo of every
+// write from the job, so a worker cannot write into another repo through a
+// job's endpoints. Every worker shares the token, though, and the API does
+// not check which worker holds a job, so the token reaches every job.
+type apiStore struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func newAPIStore(baseURL, token string) *apiStore {
+	return &apiStore{
+		base:   strings.TrimRight(baseURL, "/") + "/internal",
+		token:  token,
+		client: &http.Client{Timeout: apiStoreTimeout},
+	}
+}
+
+// apiError is a non-2xx answer from the API.
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("api: status %d: %s", e.status, e.msg)
+}
+
+// send performs one request. body is sent as-is with contentType; a non-nil
+// out receives the decoded JSON response.
+func (s *apiStore) send(ctx context.Context, method, path string, body io.Reader, contentType string, out any) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&e)
+		if e.Error == "" {
+			e.Error = http.StatusText(resp.StatusCode)
+		}
+		return nil, &apiError{status: resp.StatusCode, msg: e.Error}
+	}
+	if out != nil {
+		defer resp.Body.Close()
+		return resp, json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp, nil
+}
+
+// call sends in as JSON and decodes the response into out when non-nil.
+func (s *apiStore) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(b), "application/json"
+	}
+	resp, err := s.send(ctx, method, path, body, contentType, out)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+func jobPath(jobID, suffix string) string {
+	return "/jobs/" + url.PathEscape(jobID) + suffix
+}
+
+func (s *apiStore) StartJob(ctx context.Context, jobID string, attempt int, worker string) (jobSpec, error) {
+	var spec jobSpec
+	err := s.call(ctx, http.MethodPost, jobPath(jobID, "/start"), map[string]any{"attempt": attempt, "worker": worker}, &spec)
+	return spec, err
+}
+
+func (s *apiStore) FinishJob(ctx context.Context, jobID string, failedScanners []string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/finish"), map[string]any{"failed_scanners": failedScanners}, nil)
+}
+
+func (s *apiStore) PreviousScan(ctx context.Context, jobID string) (string, string, error) {
+	var out struct {
+		JobID     string `json:"job_id"`
+		CommitSHA string `json:"commit_sha"`
+	}
+	err := s.call(ctx, http.MethodGet, jobPath(jobID, "/previous-scan"), nil, &out)
+	return out.JobID, out.CommitSHA, err
+}
+
+func (s *apiStore) SkipJob(ctx context.Context, jobID, reason string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/skip"), map[string]string{"reason": reason}, nil)
+}
+
+func (s *apiStore) FailJob(ctx context.Context, jobID, reason string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/fail"), map[string]string{"error": reason}, nil)
+}
+
+func (s *apiStore) RequeueJob(ctx context.Context, jobID, note string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/requeue"), map[string]string{"note": note}, nil)
+}
+
+type apiRepo struct {
+	URL            string       `json:"url"`
+	Name           string       `json:"name"`
+	DefaultRef     string       `json:"default_ref"`
+	Settings       repoSettings `json:"settings"`
+	InstallationID int64        `json:"installation_id"`
+	orgSettings
+}
+
+func (s *apiStore) repo(ctx context.Context, repoID string) (apiRepo, error) {
+	var out apiRepo
+	err := s.call(ctx, http.MethodGet, "/repos/"+url.PathEscape(repoID), nil, &out)
+	return out, err
+}
+
+func (s *apiStore) Repo(ctx context.Context, repoID string) (RepoRow, error) {
+	r, err := s.repo(ctx, repoID)
+	return RepoRow{URL: r.URL, Name: r.Name, DefaultRef: r.DefaultRef, Settings: r.Settings, InstallationID: r.InstallationID}, err
+}
+
+func (s *apiStore) OrgSettings(ctx context.Context, repoID string) orgSettings {
+	if repoID == "" {
+		return orgSettings{}
+	}
+	r, err := s.repo(ctx, repoID)
+	if err != nil {
+		return orgSettings{}
+	}
+	return r.orgSettings
+}
+
+// uploadFormatHeader carries the archive format of a stored upload.
+const uploadFormatHeader = "X-Argus-Upload-Format"
+
+func (s *apiStore) Upload(ctx context.Context, jobID string) (string, []byte, error) {
+	resp, err := s.send(ctx, http.MethodGet, jobPath(jobID, "/upload"), nil, "", nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("upload not found: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Header.Get(uploadFormatHeader), data, nil
+}
+
+func (s *apiStore) DeleteUpload(ctx context.Context, jobID string) {
+	_ = s.call(ctx, http.MethodDelete, jobPath(jobID, "/upload"), nil, nil)
+}
+
+func (s *apiStore) ImageCredentials(ctx context.Context, jobID string) (string, string, error) {
+	var out struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	err := s.call(ctx, http.MethodGet, jobPath(jobID, "/image-credentials"), nil, &out)
+	return out.Username, out.Password, err
+}
+
+func (s *apiStore) DeleteImageCredentials(ctx context.Context, jobID string) {
+	_ = s.call(ctx, http.MethodDelete, jobPath(jobID, "/image-credentials"), nil, nil)
+}
+
+func (s *apiStore) SetCloneResult(ctx context.Context, jobID, strategy, commitSHA string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/clone"), map[string]string{"strategy": strategy, "commit_sha": commitSHA}, nil)
+}
+
+func (s *apiStore) SetRulePacks(ctx context.Context, jobID string, packs []string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/rule-packs"), map[string]any{"packs": packs}, nil)
+}
+
+func (s *apiStore) SetRepoProfile(ctx context.Context, jobID string, p repoProfile) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/profile"), p, nil)
+}
+
+func (s *apiStore) SetPackages(ctx context.Context, jobID string, pkgs []pkg) error {
+	return s.call(ctx, http.MethodPut, jobPath(jobID, "/packages"), map[string]any{"packages": pkgs}, nil)
+}
+
+func (s *apiStore) SetToolVersions(ctx context.Context, jobID string, versions map[string]string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/tool-versions"), map[string]any{"versions": versions}, nil)
+}
+
+func (s *apiStore) SetStage(ctx context.Context, jobID, stage string, progress int, timings map[string]int64) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/stage"), map[string]any{"stage": stage, "progress": progress, "timings": timings}, nil)
+}
+
+// AddFindings ignores repoID: the API attaches findings to the job's repo.
+func (s *apiStore) AddFindings(ctx context.Context, _, jobID string, findings []finding) error {
+	for len(findings) > 0 {
+		n := min(len(findings), findingBatchSize)
+		if err := s.call(ctx, http.MethodPost, jobPath(jobID, "/findings"), map[string]any{"findings": findings[:n]}, nil); err != nil {
+			return err
+		}
+		findings = findings[n:]
+	}
+	return nil
+}
+
+func (s *apiStore) NewFindings(ctx context.Context, jobID string) ([]finding, error) {
+	var out struct {
+		Findings []finding `json:"findings"`
+	}
+	err := s.call(ctx, http.MethodGet, jobPath(jobID, "/new-findings"), nil, &out)
+	return out.Findings, err
+}
+
+func (s *apiStore) BranchFindings(ctx context.Context, jobID string) ([]finding, error) {
+	var out struct {
+		Findings []finding `json:"findings"`
+	}
+	err := s.call(ctx, http.MethodGet, jobPath(jobID, "/branch-findings"), nil, &out)
+	return out.Findings, err
+}
+
+// ResolveFindings ignores repoID like AddFindings.
+func (s *apiStore) ResolveFindings(ctx context.Context, _, jobID string, tools []string) (int64, error) {
+	var out struct {
+		Resolved int64 `json:"resolved"`
+	}
+	err := s.call(ctx, http.MethodPost, jobPath(jobID, "/resolve-findings"), map[string]any{"tools": tools}, &out)
+	return out.Resolved, err
+}
+
+func (s *apiStore) SaveArtifact(ctx context.Context, jobID string, a artifact) error {
+	q := url.Values{"kind": {a.Kind}}
+	body := a.Data
+	if a.Object != "" {
+		// Only the row is sent; the data is already in the artifact store.
+		sum := sha256.Sum256(a.Data)
+		q.Set("object", a.Object)
+		q.Set("size", strconv.Itoa(len(a.Data)))
+		q.Set("sha256", hex.EncodeToString(sum[:]))
+		body = nil
+	}
+	path := jobPath(jobID, "/artifacts/"+url.PathEscape(a.Name)) + "?" + q.Encode()
+	resp, err := s.send(ctx, http.MethodPut, path, bytes.NewReader(body), a.ContentType, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *apiStore) AppendJobLog(ctx context.Context, jobID string, lines []logLine) error {
+	for len(lines) > 0 {
+		n := min(len(lines), logBatchSize)
+		if err := s.call(ctx, http.MethodPost, jobPath(jobID, "/logs"), map[string]any{"lines": lines[:n]}, nil); err != nil {
+			return err
+		}
+		lines = lines[n:]
+	}
+	return nil
+}
+
+func (s *apiStore) JobLog(ctx context.Context, jobID string) ([]logLine, error) {
+	var out struct {
+		Lines []logLine `json:"lines"`
+	}
+	err := s.call(ctx, http.MethodGet, jobPath(jobID, "/logs"), nil, &out)
+	return out.Lines, err
+}
+
+func (s *apiStore) ArchiveJobLog(ctx context.Context, jobID, object string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/logs/archive"), map[string]string{"object": object}, nil)
+}
+
+func (s *apiStore) WriteSummary(ctx context.Context, jobID string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/summary"), nil, nil)
+}
+
+func (s *apiStore) PublishJobEvents(ctx context.Context, jobID string) error {
+	return s.call(ctx, http.MethodPost, jobPath(jobID, "/events"), nil, nil)
+}
+
+// Ping checks both that the API is reachable and that it accepts the token.
+func (s *apiStore) Ping(ctx context.Context) error {
+	return s.call(ctx, http.MethodGet, "/ping", nil, nil)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"argus/internal/imagecreds"
+	"argus/internal/jobsql"
+	"argus/internal/objstore"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// dbStore is the default store: the worker writes to Postgres itself.
+type dbStore struct {
+	db *pgxpool.Pool
+	// objects is the artifact store, whose objects go with the rows that
+	// point at them; nil when it is not configured.
+	objects *objstore.Client
+	// creds opens image scans' registry credentials; nil when
+	// images.credentials_key is not set.
+	creds *imagecreds.Key
+}
+
+// StartJob marks the job running and resolves its flags in one transaction,
+// so a job whose flags cannot be read is not left running.
+func (s *dbStore) StartJob(ctx context.Context, jobID string, attempt int, worker string) (jobSpec, error) {
+	var spec jobSpec
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return spec, err
+	}
+	defer tx.Rollback(ctx)
+	var repoID *string
+	err = tx.QueryRow(ctx, `UPDATE jobs SET status='running', started_at=now(), error=NULL, attempt=$2, worker=$3, stage=NULL, progress=0, stage_timings='{}' WHERE id=$1
+		RETURNING scanners, COALESCE(ref,''), COALESCE(target_sha,''), COALESCE(deployment_url,''), COALESCE(image,''), COALESCE(base_sha,''), force, COALESCE(pull_request,0), repo_id::text`, jobID, attempt, worker).
+		Scan(&spec.Scanners, &spec.Ref, &spec.CommitSHA, &spec.DeploymentURL, &spec.Image, &spec.BaseSHA, &spec.Force, &spec.PullRequest, &repoID)
+	if err != nil {
+		return spec, err
+	}
+	if err := tx.QueryRow(ctx, jobsql.ResolveFlags, repoID).Scan(&spec.Flags); err != nil {
+		return spec, err
+	}
+	return spec, tx.Commit(ctx)
+}
+
+func (s *dbStore) FinishJob(ctx context.Context, jobID string, failedScanners []string) error {
+	if failedScanners == nil {
+		failedScanners = []string{}
+	}
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET status='succeeded', finished_at=now(), failed_scanners=$2 WHERE id=$1`, jobID, failedScanners)
+	return err
+}
+
+func (s *dbStore) PreviousScan(ctx context.Context, jobID string) (string, string, error) {
+	var id, sha string
+	err := s.db.QueryRow(ctx, jobsql.PreviousScan, jobID).Scan(&id, &sha)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", nil
+	}
+	return id, sha, err
+}
+
+func (s *dbStore) SkipJob(ctx context.Context, jobID, reason string) error {
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET status='skipped_unchanged', finished_at=now(), skip_reason=$2 WHERE id=$1`, jobID, reason)
+	return err
+}
+
+func (s *dbStore) FailJob(ctx context.Context, jobID, reason string) error {
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET status='failed', finished_at=now(), error=$2 WHERE id=$1`, jobID, reason)
+	return err
+}
+
+func (s *dbStore) RequeueJob(ctx context.Context, jobID, note string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `UPDATE jobs SET status='queued', started_at=NULL, finished_at=NULL, error=$2, worker=NULL, stage=NULL, progress=0, stage_timings='{}' WHERE id=$1`, jobID, note); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM findings WHERE job_id=$1 AND (repo_id IS NULL OR first_job_id=$1)`, jobID); err != nil {
+		return err
+	}
+	// The attempt's archived log goes with its artifacts, so the next
+	// attempt's log is not hidden behind it.
+	rows, err := tx.Query(ctx, jobsql.DiscardObjects, []string{jobID})
+	if err != nil {
+		return err
+	}
+	objects, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	s.deleteObjects(ctx, objects)
+	return nil
+}
+
+// deleteObjects removes keys from the artifact store once no row points at
+// them. A failure only orphans the object, so it is logged, not returned.
+func (s *dbStore) deleteObjects(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if s.objects == nil {
+		slog.WarnContext(ctx, "artifact objects not deleted: artifacts.s3 is not configured", "keys", len(keys))
+		return
+	}
+	for _, key := range keys {
+		if err := s.objects.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "artifact object not deleted", "key", key, "err", err)
+		}
+	}
+}
+
+func (s *dbStore) Repo(ctx context.Context, repoID string) (RepoRow, error) {
+	var repo RepoRow
+	var settings []byte
+	err := s.db.QueryRow(ctx, `SELECT url, name, COALESCE(default_ref,''), scan_settings, COALESCE(installation_id, 0) FROM repos WHERE id=$1`, repoID).
+		Scan(&repo.URL, &repo.Name, &repo.DefaultRef, &settings, &repo.InstallationID)
+	if err == nil {
+		_ = json.Unmarshal(settings, &repo.Settings)
+	}
+	return repo, err
+}
+
+func (s *dbStore) OrgSettings(ctx context.Context, repoID string) orgSettings {
+	var o orgSettings
+	if repoID == "" {
+		return o
+	}
+	var raw []byte
+	var salt *string
+	var org string
+	err := s.db.QueryRow(ctx, `SELECT o.name, o.title_templates, o.fingerprint_salt, o.semgrep_rules_mode FROM repos r JOIN orgs o ON o.name = r.org WHERE r.id=$1`, repoID).
+		Scan(&org, &raw, &salt, &o.SemgrepRulesMode)
+	if err != nil {
+		return o
+	}
+	_ = json.Unmarshal(raw, &o.TitleTemplates)
+	if salt != nil {
+		o.FingerprintSalt = *salt
+	}
+	rows, err := s.db.Query(ctx, `SELECT name, content FROM semgrep_rules WHERE org=$1 AND enabled ORDER BY name`, org)
+	if err != nil {
+		return o
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f orgRuleFile
+		if rows.Scan(&f.Name, &f.Content) == nil {
+			o.SemgrepRules = append(o.SemgrepRules, f)
+		}
+	}
+	return o
+}
+
+func (s *dbStore) Upload(ctx context.Context, jobID string) (string, []byte, error) {
+	var format string
+	var data []byte
+	if err := s.db.QueryRow(ctx, `SELECT format, data FROM uploads WHERE job_id=$1`, jobID).Scan(&format, &data); err != nil {
+		return "", nil, fmt.Errorf("upload not found")
+	}
+	return format, data, nil
+}
+
+func (s *dbStore) DeleteUpload(ctx context.Context, jobID string) {
+	_, _ = s.db.Exec(ctx, `DELETE FROM uploads WHERE job_id=$1`, jobID)
+}
+
+func (s *dbStore) ImageCredentials(ctx context.Context, jobID string) (string, string, error) {
+	var sealed []byte
+	err := s.db.QueryRow(ctx, `SELECT sealed FROM image_credentials WHERE job_id=$1`, jobID).Scan(&sealed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if s.creds == nil {
+		return "", "", errors.New("sealed but images.credentials_key is not set")
+	}
+	c, err := s.creds.Open(jobID, sealed)
+	return c.Username, c.Password, err
+}
+
+func (s *dbStore) DeleteImageCredentials(ctx context.Context, jobID string) {
+	_, _ = s.db.Exec(ctx, `DELETE FROM image_credentials WHERE job_id=$1`, jobID)
+}
+
+func (s *dbStore) SetCloneResult(ctx context.Context, jobID, strategy, commitSHA string) error {
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET clone_strategy=$2, commit_sha=$3 WHERE id=$1`, jobID, strategy, nullIfEmpty(commitSHA))
+	return err
+}
+
+func (s *dbStore) SetRulePacks(ctx context.Context, jobID string, packs []string) error {
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET rule_packs=$2 WHERE id=$1`, jobID, packs)
+	return err
+}
+
+func (s *dbStore) SetRepoProfile(ctx context.Context, jobID string, p repoProfile) error {
+	_, err := s.db.Exec(ctx, `UPDATE repos SET size_kb=$2, file_count=$3, languages=$4, profiled_at=now()
+		WHERE id=(SELECT repo_id FROM jobs WHERE id=$1)`, jobID, p.SizeKB, p.FileCount, p.Languages)
+	return err
+}
+
+func (s *dbStore) SetPackages(ctx context.Context, jobID string, pkgs []pkg) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM packages WHERE job_id=$1`, jobID); err != nil {
+		return err
+	}
+	cols := make([][]string, 5)
+	for _, p := range pkgs {
+		for i, v := range []string{p.Ecosystem, p.Name, p.Version, p.PURL, p.Path} {
+			cols[i] = append(cols[i], v)
+		}
+	}
+	if _, err := tx.Exec(ctx, jobsql.SetPackages, jobID, cols[0], cols[1], cols[2], cols[3], cols[4]); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *dbStore) SetToolVersions(ctx context.Context, jobID string, versions map[string]string) error {
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET tool_versions=$2 WHERE id=$1`, jobID, versions)
+	return err
+}
+
+func (s *dbStore) SetStage(ctx context.Context, jobID, stage string, progress int, timings map[string]int64) error {
+	_, err := s.db.Exec(ctx, `UPDATE jobs SET stage=$2, progress=$3, stage_timings=$4 WHERE id=$1`, jobID, stage, progress, timings)
+	return err
+}
+
+func (s *dbStore) AddFindings(ctx context.Context, repoID, jobID string, findings []finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	b := &pgx.Batch{}
+	for _, f := range findings {
+		ev, _ := json.Marshal(f.Evidence)
+		b.Queue(jobsql.InsertFinding,
+			nullIfEmpty(repoID), jobID, f.Tool, f.Severity, f.Title, f.FilePath, f.LineStart, f.LineEnd, f.Fingerprint, f.Description, ev)
+	}
+	return s.db.SendBatch(ctx, b).Close()
+}
+
+func (s *dbStore) NewFindings(ctx context.Context, jobID string) ([]finding, error) {
+	return s.jobFindings(ctx, jobsql.NewFindings, jobID)
+}
+
+func (s *dbStore) BranchFindings(ctx context.Context, jobID string) ([]finding, error) {
+	return s.jobFindings(ctx, jobsql.BranchFindings, jobID)
+}
+
+func (s *dbStore) jobFindings(ctx context.Context, sql, jobID string) ([]finding, error) {
+	rows, err := s.db.Query(ctx, sql, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (finding, error) {
+		var f finding
+		err := row.Scan(&f.Tool, &f.Severity, &f.Title, &f.FilePath, &f.LineStart, &f.LineEnd, &f.Fingerprint, &f.Description)
+		return f, err
+	})
+}
+
+func (s *dbStore) ResolveFindings(ctx context.Context, repoID, jobID string, tools []string) (int64, error) {
+	tag, err := s.db.Exec(ctx, jobsql.ResolveFindings, repoID, jobID, tools)
+	return tag.RowsAffected(), err
+}
+
+func (s *dbStore) SaveArtifact(ctx context.Context, jobID string, a artifact) error {
+	sum := sha256.Sum256(a.Data)
+	var data []byte
+	var object *string
+	if a.Object != "" {
+		object = &a.Object
+	} else {
+		data = a.Data
+	}
+	_, err := s.db.Exec(ctx, `INSERT INTO job_artifacts (job_id, kind, name, content_type, size_bytes, sha256, data, object_key) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT (job_id, name) DO UPDATE SET kind=EXCLUDED.kind, content_type=EXCLUDED.content_type, size_bytes=EXCLUDED.size_bytes, sha256=EXCLUDED.sha256, data=EXCLUDED.data, object_key=EXCLUDED.object_key, created_at=now()`,
+		jobID, a.Kind, a.Name, a.ContentType, len(a.Data), hex.EncodeToString(sum[:]), data, object)
+	return err
+}
+
+func (s *dbStore) AppendJobLog(ctx context.Context, jobID string, lines []logLine) error {
+	attempts, times, streams, texts := make([]int, len(lines)), make([]time.Time, len(lines)), make([]string, len(lines)), make([]string, len(lines))
+	for i, l := range lines {
+		attempts[i], times[i], streams[i], texts[i] = l.Attempt, l.Time, l.Stream, l.Text
+	}
+	_, err := s.db.Exec(ctx, jobsql.AppendJobLog, jobID, attempts, times, streams, texts)
+	return err
+}
+
+func (s *dbStore) JobLog(ctx context.Context, jobID string) ([]logLine, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, attempt, logged_at, stream, line FROM job_logs WHERE job_id=$1 ORDER BY id`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (logLine, error) {
+		var l logLine
+		err := row.Scan(&l.ID, &l.Attempt, &l.Time, &l.Stream, &l.Text)
+		return l, err
+	})
+}
+
+func (s *dbStore) ArchiveJobLog(ctx context.Context, jobID, object string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `UPDATE jobs SET log_object=$2 WHERE id=$1`, jobID, object); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM job_logs WHERE job_id=$1`, jobID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *dbStore) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
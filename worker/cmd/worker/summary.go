@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"argus/internal/jobsql"
+)
+
+// WriteSummary builds the finished job's summary and stores it as an
+// artifact.
+func (s *dbStore) WriteSummary(ctx context.Context, jobID string) error {
+	sum, err := jobsql.BuildSummary(ctx, func(ctx context.Context, sql string, args ...any) jobsql.Row {
+		return s.db.QueryRow(ctx, sql, args...)
+	}, jobID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+	return s.SaveArtifact(ctx, jobID, artifact{Kind: jobsql.SummaryKind, Name: jobsql.SummaryName, ContentType: "application/json", Data: b})
+}
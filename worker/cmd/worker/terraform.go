@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"argus/worker/internal/severity"
+)
+
+// The terraform stage is a dedicated `trivy config` pass over Terraform
+// that resolves modules, so findings inside a module are reported against
+// the resource address that instantiates them (module.vpc.aws_s3_bucket.logs)
+// and fixes can target that block. While it is enabled, the trivy stage
+// leaves Terraform out of its own misconfiguration scan.
+
+// trivyMisconfigScanners are trivy's misconfiguration scanners other than
+// the ones the terraform stage covers.
+const trivyMisconfigScanners = "azure-arm,cloudformation,dockerfile,helm,kubernetes"
+
+type trivyConfigOut struct {
+	Results []struct {
+		Target            string `json:"Target"`
+		Misconfigurations []struct {
+			ID            string `json:"ID"`
+			Title         string `json:"Title"`
+			Description   string `json:"Description"`
+			Resolution    string `json:"Resolution"`
+			Severity      string `json:"Severity"`
+			PrimaryURL    string `json:"PrimaryURL"`
+			CauseMetadata struct {
+				Resource    string `json:"Resource"`
+				Provider    string `json:"Provider"`
+				Service     string `json:"Service"`
+				StartLine   int    `json:"StartLine"`
+				EndLine     int    `json:"EndLine"`
+				Occurrences []struct {
+					Resource string `json:"Resource"`
+					Filename string `json:"Filename"`
+				} `json:"Occurrences"`
+			} `json:"CauseMetadata"`
+		} `json:"Misconfigurations"`
+	} `json:"Results"`
+}
+
+func (wk *Worker) runTerraform(ctx context.Context, job *scanJob) error {
+	args := []string{"config", "--format", "json", "--quiet", "--misconfig-scanners", "terraform", "--timeout", wk.cfg.TrivyTimeout}
+	if wk.cfg.Offline {
+		args = append(args, "--cache-dir", wk.cfg.OfflineTrivyCache, "--skip-check-update", "--skip-version-check")
+	}
+	if wk.cfg.Offline || !wk.cfg.TerraformDownloadModules {
+		// Only local modules and those already under .terraform/modules
+		// are resolved; remote module sources are not fetched.
+		args = append(args, "--offline-scan")
+	}
+	out, err := runCmdJSON(ctx, "trivy", append(args, "."), job.dir, wk.cfg.ScannerProxySetting().Environ())
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "terraform.json", "application/json", out)
+	var parsed trivyConfigOut
+	if perr := json.Unmarshal(out, &parsed); perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("trivy config parse error: %v", perr)
+	}
+	job.addTerraformResults(parsed)
+	return err
+}
+
+func (j *scanJob) addTerraformResults(parsed trivyConfigOut) {
+	for _, r := range parsed.Results {
+		for _, m := range r.Misconfigurations {
+			sev := severity.Normalize("trivy", m.Severity)
+			target := filepath.ToSlash(r.Target)
+			address := m.CauseMetadata.Resource
+			var modules []string
+			for _, o := range m.CauseMetadata.Occurrences {
+				if strings.HasPrefix(o.Resource, "module.") {
+					modules = append(modules, o.Resource)
+				}
+			}
+			if address != "" && len(modules) > 0 {
+				address = terraformAddress(modules, address)
+			}
+			parts := map[string]string{"id": m.ID, "title": m.Title, "address": address, "target": target, "line": strconv.Itoa(m.CauseMetadata.StartLine), "severity": sev}
+			title := j.titles.render(titleTerraform, parts)
+			desc := m.Description
+			// Keyed by address rather than line, so edits elsewhere in the
+			// file keep the finding's identity. The file tells apart root
+			// modules that reuse resource names (envs/dev, envs/prod).
+			key := address
+			if key == "" {
+				key = strconv.Itoa(m.CauseMetadata.StartLine)
+			}
+			fpv := j.fp("trivy:terraform", m.ID, target, key)
+			ls, le := m.CauseMetadata.StartLine, m.CauseMetadata.EndLine
+			j.add(finding{Tool: "trivy", Severity: sev, label: m.Severity, Title: title, FilePath: &target, LineStart: &ls, LineEnd: &le, Fingerprint: &fpv, Description: &desc, Evidence: map[string]any{
+				"id":          m.ID,
+				"url":         m.PrimaryURL,
+				"resolution":  m.Resolution,
+				"address":     address,
+				"resource":    m.CauseMetadata.Resource,
+				"provider":    m.CauseMetadata.Provider,
+				"service":     m.CauseMetadata.Service,
+				"title_parts": parts,
+			}})
+		}
+	}
+}
+
+// terraformAddress prefixes a resource with the module calls that reach
+// it. trivy lists the calls from the innermost outwards, each by its local
+// name ("module.subnets").
+func terraformAddress(modules []string, resource string) string {
+	if strings.HasPrefix(resource, "module.") {
+		return resource
+	}
+	var b strings.Builder
+	for i := len(modules) - 1; i >= 0; i-- {
+		b.WriteString(modules[i] + ".")
+	}
+	return b.String() + resource
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAddTerraformResults(t *testing.T) {
+	var parsed trivyConfigOut
+	err := json.Unmarshal([]byte(`{"Results":[{"Target":"modules/bucket/main.tf","Misconfigurations":[{
+		"ID":"AVD-AWS-0086","Title":"S3 Access block should block public ACL","Severity":"HIGH",
+		"CauseMetadata":{"Resource":"aws_s3_bucket_public_access_block.this","StartLine":12,"EndLine":18,
+			"Occurrences":[{"Resource":"module.bucket","Filename":"modules/logs/main.tf"},{"Resource":"module.logs","Filename":"main.tf"}]}}]}]}`), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	job.addTerraformResults(parsed)
+	if len(job.findings) != 1 {
+		t.Fatalf("got %d findings", len(job.findings))
+	}
+	f := job.findings[0]
+	ev := f.Evidence.(map[string]any)
+	if ev["address"] != "module.logs.module.bucket.aws_s3_bucket_public_access_block.this" {
+		t.Errorf("address = %v", ev["address"])
+	}
+	if f.Title != "AVD-AWS-0086: S3 Access block should block public ACL (module.logs.module.bucket.aws_s3_bucket_public_access_block.this)" {
+		t.Errorf("title = %q", f.Title)
+	}
+
+	// Moving the block keeps its fingerprint; another address does not.
+	moved := parsed
+	moved.Results[0].Misconfigurations[0].CauseMetadata.StartLine = 40
+	again := &scanJob{}
+	again.addTerraformResults(moved)
+	if *again.findings[0].Fingerprint != *f.Fingerprint {
+		t.Error("fingerprint depends on the line")
+	}
+}
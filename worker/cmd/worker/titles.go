@@ -0,0 +1,77 @@
+package main
+
+import "strings"
+
+// Title template kinds. Trivy reports two unrelated finding shapes, so each
+// gets its own template.
+const (
+	titleSemgrep        = "semgrep"
+	titleGitleaks       = "gitleaks"
+	titleTrufflehog     = "trufflehog"
+	titleTrivyVuln      = "trivy_vuln"
+	titleTrivyMisconfig = "trivy_misconfig"
+	titleGrype          = "grype"
+	titleNuclei         = "nuclei"
+	titleTerraform      = "terraform"
+	titleHadolint       = "hadolint"
+	titleKubeLinter     = "kube-linter"
+	titleGovulncheck    = "govulncheck"
+	titleNPMAudit       = "npm-audit"
+	titlePipAudit       = "pip-audit"
+	titleBandit         = "bandit"
+)
+
+// defaultTitleTemplates reproduce the titles Argus has always produced.
+var defaultTitleTemplates = map[string]string{
+	titleSemgrep:        "{rule_id}",
+	titleGitleaks:       "Secret detected: {rule_id}",
+	titleTrufflehog:     "Secret detected: {detector} ({verification})",
+	titleTrivyVuln:      "{id} in {package}",
+	titleTrivyMisconfig: "{id}: {title}",
+	titleGrype:          "{id} in {package}",
+	titleNuclei:         "{rule_id}: {name}",
+	titleTerraform:      "{id}: {title} ({address})",
+	titleHadolint:       "{rule_id}: {message}",
+	titleKubeLinter:     "{check}: {object}",
+	titleGovulncheck:    "{id} in {module} ({reachability})",
+	titleNPMAudit:       "{id} in {package}",
+	titlePipAudit:       "{id} in {package}",
+	titleBandit:         "{rule_id}: {message}",
+}
+
+type titleTemplates map[string]string
+
+// render fills kind's template with parts. Unknown placeholders are left as
+// written, and an empty result falls back to the default template.
+func (t titleTemplates) render(kind string, parts map[string]string) string {
+	if tmpl := strings.TrimSpace(t[kind]); tmpl != "" {
+		if out := strings.TrimSpace(expandTitle(tmpl, parts)); out != "" {
+			return out
+		}
+	}
+	return strings.TrimSpace(expandTitle(defaultTitleTemplates[kind], parts))
+}
+
+func expandTitle(tmpl string, parts map[string]string) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			break
+		}
+		name := tmpl[open+1 : open+end]
+		b.WriteString(tmpl[:open])
+		if v, ok := parts[name]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(tmpl[open : open+end+1])
+		}
+		tmpl = tmpl[open+end+1:]
+	}
+	b.WriteString(tmpl)
+	return b.String()
+}
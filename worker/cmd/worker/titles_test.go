@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestTitleTemplatesRender(t *testing.T) {
+	parts := map[string]string{"id": "CVE-2024-1", "package": "openssl", "fixed": "3.0.14", "severity": "HIGH"}
+
+	var none titleTemplates
+	if got := none.render(titleTrivyVuln, parts); got != "CVE-2024-1 in openssl" {
+		t.Fatalf("default title = %q", got)
+	}
+
+	org := titleTemplates{titleTrivyVuln: "[{severity}] {package}: {id} (fix {fixed}) {unknown}"}
+	if got := org.render(titleTrivyVuln, parts); got != "[HIGH] openssl: CVE-2024-1 (fix 3.0.14) {unknown}" {
+		t.Fatalf("org title = %q", got)
+	}
+
+	// A template that renders to nothing falls back to the default.
+	empty := titleTemplates{titleTrivyVuln: "{missing_field_value}"}
+	parts["missing_field_value"] = ""
+	if got := empty.render(titleTrivyVuln, parts); got != "CVE-2024-1 in openssl" {
+		t.Fatalf("fallback title = %q", got)
+	}
+}
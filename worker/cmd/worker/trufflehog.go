@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trufflehog finds secrets in the clone like gitleaks, and with
+// scanners.trufflehog.verify checks each one against its provider (an
+// authenticated call to AWS, GitHub, Slack, ...) to tell live credentials
+// from dead or fake ones. Its output holds the secrets in clear, so it is
+// kept out of the command log and the stored artifact is re-encoded
+// without them.
+
+// Verification states, as recorded in evidence_json.verification.
+const (
+	secretVerified   = "verified"   // the provider accepted the secret
+	secretUnverified = "unverified" // the provider rejected it
+	secretUnknown    = "unknown"    // verification failed, e.g. a timeout
+	secretSkipped    = "skipped"    // verification is off
+)
+
+// trufflehogResult is a result line without its Raw and RawV2 secrets.
+type trufflehogResult struct {
+	SourceMetadata struct {
+		Data struct {
+			Filesystem struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			} `json:"Filesystem"`
+		} `json:"Data"`
+	} `json:"SourceMetadata"`
+	DetectorName      string         `json:"DetectorName"`
+	DecoderName       string         `json:"DecoderName"`
+	Verified          bool           `json:"Verified"`
+	VerificationError string         `json:"VerificationError,omitempty"`
+	ExtraData         map[string]any `json:"ExtraData,omitempty"`
+}
+
+func (wk *Worker) runTrufflehog(ctx context.Context, job *scanJob) error {
+	excludes := filepath.Join(filepath.Dir(job.dir), "trufflehog-exclude.txt")
+	if err := os.WriteFile(excludes, []byte(`(^|/)\.git/`+"\n"), 0o600); err != nil {
+		return err
+	}
+	args := []string{"filesystem", ".", "--json", "--no-update", "--exclude-paths", excludes}
+	if !wk.cfg.TrufflehogVerify {
+		args = append(args, "--no-verification")
+	}
+	out, err := runCmdStdout(ctx, "trufflehog", args, job.dir, wk.cfg.ScannerProxySetting().Environ())
+	results, perr := parseTrufflehog(out)
+	redacted, _ := json.Marshal(results)
+	wk.saveArtifact(ctx, job.msg.JobID, artifactScannerOutput, "trufflehog.json", "application/json", redacted)
+	if perr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("trufflehog parse error: %v", perr)
+	}
+	job.addTrufflehogResults(results, wk.cfg.TrufflehogVerify)
+	return err
+}
+
+// runCmdStdout runs a scanner like runCmdJSON but returns only its stdout;
+// the command log and the error keep stderr alone.
+func runCmdStdout(ctx context.Context, name string, args []string, workdir string, env []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = workdir
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	out, err := cmd.Output()
+	recordCommand(ctx, name, args, start, stderr.Bytes(), err)
+	if err != nil {
+		return out, fmt.Errorf("%s %v: %w: %s", name, args, err, stderr.String())
+	}
+	return out, nil
+}
+
+// parseTrufflehog reads trufflehog's JSON lines, skipping anything that is
+// not a result.
+func parseTrufflehog(out []byte) ([]trufflehogResult, error) {
+	results := []trufflehogResult{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var r trufflehogResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			return results, err
+		}
+		if r.DetectorName != "" {
+			results = append(results, r)
+		}
+	}
+	return results, sc.Err()
+}
+
+func (j *scanJob) addTrufflehogResults(results []trufflehogResult, verify bool) {
+	for _, r := range results {
+		state := trufflehogState(r, verify)
+		sev := trufflehogSeverity(state)
+		fs := r.SourceMetadata.Data.Filesystem
+		file := strings.TrimPrefix(filepath.ToSlash(fs.File), "./")
+		parts := map[string]string{"detector": r.DetectorName, "verification": state, "path": file, "line": strconv.Itoa(fs.Line), "severity": sev}
+		title := j.titles.render(titleTrufflehog, parts)
+		desc := r.DetectorName + " secret, " + state
+		if r.VerificationError != "" {
+			desc += ": " + r.VerificationError
+		}
+		fpv := j.fp("trufflehog", r.DetectorName, file, strconv.Itoa(fs.Line))
+		ls, le := fs.Line, fs.Line
+		ev := map[string]any{
+			"rule_id":      r.DetectorName,
+			"decoder":      r.DecoderName,
+			"verification": state,
+			"redacted":     true,
+			"title_parts":  parts,
+		}
+		if r.VerificationError != "" {
+			ev["verification_error"] = r.VerificationError
+		}
+		if len(r.ExtraData) > 0 {
+			ev["extra_data"] = r.ExtraData
+		}
+		f := finding{Tool: "trufflehog", Severity: sev, Title: title, FilePath: &file, Fingerprint: &fpv, Description: &desc, Evidence: ev}
+		if fs.Line > 0 {
+			f.LineStart, f.LineEnd = &ls, &le
+		}
+		j.add(f)
+	}
+}
+
+func trufflehogState(r trufflehogResult, verify bool) string {
+	switch {
+	case !verify:
+		return secretSkipped
+	case r.Verified:
+		return secretVerified
+	case r.VerificationError != "":
+		return secretUnknown
+	}
+	return secretUnverified
+}
+
+// trufflehogSeverity ranks a live secret above gitleaks' HIGH and one its
+// provider rejected below it.
+func trufflehogSeverity(state string) string {
+	switch state {
+	case secretVerified:
+		return "CRITICAL"
+	case secretUnverified:
+		return "MEDIUM"
+	}
+	return "HIGH"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAddTrufflehogResults(t *testing.T) {
+	out := []byte(`{"level":"info-0","ts":"2024-10-01T10:00:00Z","logger":"trufflehog","msg":"running source","source_manager_worker_id":"x"}
+{"SourceMetadata":{"Data":{"Filesystem":{"file":"config/aws.env","line":3}}},"SourceID":1,"SourceType":15,"SourceName":"trufflehog - filesystem","DetectorType":2,"DetectorName":"AWS","DecoderName":"PLAIN","Verified":true,"Raw":"AKIAEXAMPLE","RawV2":"AKIAEXAMPLEsecret","Redacted":"AKIAEXAMPLE","ExtraData":{"account":"123456789012"}}
+{"SourceMetadata":{"Data":{"Filesystem":{"file":"./app/slack.py","line":10}}},"DetectorName":"Slack","DecoderName":"BASE64","Verified":false,"Raw":"xoxb-secret"}
+{"SourceMetadata":{"Data":{"Filesystem":{"file":"app/db.py","line":0}}},"DetectorName":"Postgres","DecoderName":"PLAIN","Verified":false,"VerificationError":"dial tcp: i/o timeout","Raw":"postgres://u:p@db"}
+`)
+	results, err := parseTrufflehog(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if b, _ := json.Marshal(results); bytes.Contains(b, []byte("secret")) || bytes.Contains(b, []byte("AKIAEXAMPLE")) {
+		t.Fatalf("re-encoded results keep the secrets: %s", b)
+	}
+
+	job := &scanJob{}
+	job.addTrufflehogResults(results, true)
+	want := []struct{ sev, state, title string }{
+		{"CRITICAL", secretVerified, "Secret detected: AWS (verified)"},
+		{"MEDIUM", secretUnverified, "Secret detected: Slack (unverified)"},
+		{"HIGH", secretUnknown, "Secret detected: Postgres (unknown)"},
+	}
+	for i, w := range want {
+		f := job.findings[i]
+		ev := f.Evidence.(map[string]any)
+		if f.Tool != "trufflehog" || f.Severity != w.sev || f.Title != w.title || ev["verification"] != w.state {
+			t.Errorf("finding %d = %+v, evidence %v", i, f, ev)
+		}
+	}
+	if f := job.findings[1]; *f.FilePath != "app/slack.py" || *f.LineStart != 10 {
+		t.Errorf("finding = %+v", f)
+	}
+	if f := job.findings[2]; f.LineStart != nil || f.Evidence.(map[string]any)["verification_error"] != "dial tcp: i/o timeout" {
+		t.Errorf("finding = %+v", f)
+	}
+
+	job = &scanJob{}
+	job.addTrufflehogResults(results, false)
+	if f := job.findings[0]; f.Severity != "HIGH" || f.Evidence.(map[string]any)["verification"] != secretSkipped {
+		t.Errorf("unverified scan finding = %+v", f)
+	}
+}
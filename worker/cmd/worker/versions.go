@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// Each job records the versions of the scanner tools it ran in
+// jobs.tool_versions, so a change in findings can be traced to a tool
+// upgrade rather than the code. The binaries only change with the worker's
+// image, so each is asked once per process, including those that could not
+// say.
+
+// versionCommands asks each scanner's binary for its version; terraform
+// runs trivy and shares its entry through scannerTool. npm-audit has none
+// of its own: it records the package managers it audits with.
+var versionCommands = map[string][]string{
+	"semgrep":     {"semgrep", "--version"},
+	"gitleaks":    {"gitleaks", "version"},
+	"trufflehog":  {"trufflehog", "--version"},
+	"trivy":       {"trivy", "--version"},
+	"hadolint":    {"hadolint", "--version"},
+	"kube-linter": {"kube-linter", "version"},
+	"govulncheck": {"govulncheck", "-version"},
+	"npm":         {"npm", "--version"},
+	"pnpm":        {"pnpm", "--version"},
+	"yarn":        {"yarn", "--version"},
+	"pip-audit":   {"pip-audit", "--version"},
+	"bandit":      {"bandit", "--version"},
+	"grype":       {"grype", "version"},
+	"syft":        {"syft", "version"},
+	"nuclei":      {"nuclei", "-version"},
+}
+
+// versionTimeout bounds one version probe.
+const versionTimeout = 20 * time.Second
+
+// versionPattern finds the first version number that starts a word, so
+// "Version: 0.50.1", "v8.18.2" and "govulncheck@v1.0.4" match but the Go
+// release in "go1.22.1" does not.
+var versionPattern = regexp.MustCompile(`(?:^|[^\w.])v?(\d+\.\d+[\w.+-]*)`)
+
+// recordToolVersions stores the versions of the tools behind the job's
+// scanners. A tool that cannot say is left out.
+func (wk *Worker) recordToolVersions(ctx context.Context, job *scanJob, scanners []jobScanner) {
+	versions := map[string]string{}
+	for _, tool := range versionedTools(job, scanners) {
+		if _, done := versions[tool]; done {
+			continue
+		}
+		if v := wk.toolVersion(ctx, tool); v != "" {
+			versions[tool] = v
+		}
+	}
+	if len(versions) == 0 {
+		return
+	}
+	if err := wk.store.SetToolVersions(ctx, job.msg.JobID, versions); err != nil {
+		slog.WarnContext(ctx, "tool versions not recorded", "err", err)
+	}
+}
+
+// versionedTools lists the tools the job's scanners run. npm-audit runs
+// the package manager of each lockfile in the clone.
+func versionedTools(job *scanJob, scanners []jobScanner) []string {
+	var tools []string
+	for _, s := range scanners {
+		if s.name != "npm-audit" {
+			tools = append(tools, scannerTool(s.name))
+			continue
+		}
+		for _, l := range findJSLockfiles(job.dir) {
+			tools = append(tools, l.Manager)
+		}
+	}
+	return tools
+}
+
+// toolVersion asks tool for its version once. A probe that fails is
+// remembered as "" unless the job was canceled while it ran.
+func (wk *Worker) toolVersion(ctx context.Context, tool string) string {
+	if v, ok := wk.versions.Load(tool); ok {
+		return v.(string)
+	}
+	args, ok := versionCommands[tool]
+	if !ok {
+		return ""
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	// Some tools print their version on stderr.
+	out, err := exec.CommandContext(probeCtx, args[0], args[1:]...).CombinedOutput()
+	v := parseVersion(out)
+	if ctx.Err() != nil {
+		return ""
+	}
+	if err != nil || v == "" {
+		slog.WarnContext(ctx, "could not read tool version", "tool", tool, "err", err)
+		v = ""
+	}
+	wk.versions.Store(tool, v)
+	return v
+}
+
+func parseVersion(out []byte) string {
+	if m := versionPattern.FindSubmatch(out); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
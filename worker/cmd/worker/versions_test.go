@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	for out, want := range map[string]string{
+		"1.60.0\n":                           "1.60.0",
+		"Version: 0.50.1\nVulnerability DB:": "0.50.1",
+		"v8.18.2":                            "8.18.2",
+		"trufflehog 3.63.2-rc1":              "3.63.2-rc1",
+		"Go: go1.22.1\nScanner: govulncheck@v1.0.4\nDB: https://vuln.go.dev": "1.0.4",
+		"command not found": "",
+	} {
+		if got := parseVersion([]byte(out)); got != want {
+			t.Errorf("parseVersion(%q) = %q, want %q", out, got, want)
+		}
+	}
+}
+
+type versionStore struct {
+	store
+	versions map[string]string
+}
+
+func (s *versionStore) SetToolVersions(_ context.Context, _ string, versions map[string]string) error {
+	s.versions = versions
+	return nil
+}
+
+func TestRecordToolVersions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"package.json", "pnpm-lock.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := &versionStore{}
+	wk := &Worker{store: st}
+	wk.versions.Store("semgrep", "1.60.0")
+	wk.versions.Store("trivy", "0.50.1")
+	wk.versions.Store("pnpm", "8.15.1")
+	wk.versions.Store("npm", "10.2.4")
+	job := &scanJob{msg: JobMsg{JobID: "job"}, dir: dir}
+	wk.recordToolVersions(context.Background(), job, []jobScanner{{name: "semgrep"}, {name: "trivy"}, {name: "terraform"}, {name: "npm-audit"}, {name: "no-such-tool"}})
+	if want := map[string]string{"semgrep": "1.60.0", "trivy": "0.50.1", "pnpm": "8.15.1"}; !reflect.DeepEqual(st.versions, want) {
+		t.Errorf("recorded %v, want %v", st.versions, want)
+	}
+}
+
+func TestToolVersionCachesFailures(t *testing.T) {
+	versionCommands["argus-test-tool"] = []string{"argus-no-such-binary", "--version"}
+	t.Cleanup(func() { delete(versionCommands, "argus-test-tool") })
+
+	wk := &Worker{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if v := wk.toolVersion(ctx, "argus-test-tool"); v != "" {
+		t.Fatalf("toolVersion = %q", v)
+	}
+	if _, ok := wk.versions.Load("argus-test-tool"); ok {
+		t.Error("probe of a canceled job cached")
+	}
+	if v := wk.toolVersion(context.Background(), "argus-test-tool"); v != "" {
+		t.Fatalf("toolVersion = %q", v)
+	}
+	if v, ok := wk.versions.Load("argus-test-tool"); !ok || v != "" {
+		t.Errorf("failed probe not cached: %v, %v", v, ok)
+	}
+}
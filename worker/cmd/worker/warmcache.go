@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"argus/worker/internal/config"
+)
+
+// The warm cache keeps semgrep registry rule packs and the trivy databases on
+// local disk, refreshed in the background, so a restarted worker does not
+// spend its first scans downloading them. Every worker process on a node
+// may share one cache.dir.
+//
+// Each refresh fills a new version directory, <cache.dir>/<kind>/<stamp>/,
+// then points <kind>/current at it. A scan holds a shared flock on the
+// version it reads for as long as its scanner runs, and a version is only
+// removed once it is no longer current and nobody holds it. Refreshes of a
+// kind are serialized across processes by <kind>/refresh.lock.
+
+const (
+	cacheSemgrep = "semgrep"
+	cacheTrivy   = "trivy"
+
+	cacheCurrent     = "current"
+	cacheVersionLock = ".lock"
+	cacheRefreshLock = "refresh.lock"
+
+	// cacheCheckEvery is how often the refresher looks for stale kinds; a
+	// kind is refreshed once its version is cache.refresh_interval old.
+	cacheCheckEvery = 5 * time.Minute
+
+	semgrepRegistryURL = "https://semgrep.dev/c/"
+	maxRulePackBytes   = 32 << 20
+)
+
+type warmCache struct {
+	dir      string
+	interval time.Duration
+	packs    []string // semgrep registry packs to keep; nil skips semgrep
+	trivy    bool
+	client   *http.Client
+	env      []string // scanner environment, with the scanner proxy
+}
+
+// newWarmCache returns nil when cache.dir is unset or the worker is offline,
+// where scanners read their bundles instead.
+func newWarmCache(cfg config.Config) *warmCache {
+	if cfg.CacheDir == "" || cfg.Offline {
+		return nil
+	}
+	interval, _ := time.ParseDuration(cfg.CacheRefreshInterval)
+	c := &warmCache{
+		dir:      cfg.CacheDir,
+		interval: interval,
+		trivy:    cfg.ScannerEnabled("trivy"),
+		client:   &http.Client{Timeout: 2 * time.Minute, Transport: cfg.ScannerProxySetting().Transport()},
+		env:      cfg.ScannerProxySetting().Environ(),
+	}
+	if cfg.ScannerEnabled("semgrep") {
+		c.packs = warmSemgrepPacks(cfg.SemgrepConfig)
+	}
+	return c
+}
+
+// warmSemgrepPacks lists the registry packs language detection can select,
+// plus the configured pack when scanners.semgrep.config names one.
+func warmSemgrepPacks(semgrepConfig string) []string {
+	set := map[string]bool{"p/docker": true}
+	for _, p := range packsByExt {
+		set[p] = true
+	}
+	if isRegistryPack(semgrepConfig) {
+		set[semgrepConfig] = true
+	}
+	packs := make([]string, 0, len(set))
+	for p := range set {
+		packs = append(packs, p)
+	}
+	sort.Strings(packs)
+	return packs
+}
+
+func isRegistryPack(v string) bool {
+	return strings.HasPrefix(v, "p/") || strings.HasPrefix(v, "r/")
+}
+
+// rulePackFile is the cached file name of a registry pack: p/golang is
+// p_golang.yml.
+func rulePackFile(pack string) string {
+	return strings.ReplaceAll(pack, "/", "_") + ".yml"
+}
+
+// run refreshes stale kinds at startup and then every cacheCheckEvery until
+// ctx is cancelled.
+func (c *warmCache) run(ctx context.Context) {
+	every := min(cacheCheckEvery, c.interval)
+	for {
+		if c.packs != nil {
+			c.refreshLogged(ctx, cacheSemgrep, c.fillSemgrep)
+		}
+		if c.trivy {
+			c.refreshLogged(ctx, cacheTrivy, c.fillTrivy)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(every):
+		}
+	}
+}
+
+func (c *warmCache) refreshLogged(ctx context.Context, kind string, fill func(context.Context, string) error) {
+	start := time.Now()
+	refreshed, err := c.refresh(ctx, kind, fill)
+	switch {
+	case err != nil && ctx.Err() == nil:
+		slog.Warn("warm cache refresh failed; scans use the previous version or download", "kind", kind, "err", err)
+	case refreshed:
+		slog.Info("warm cache refreshed", "kind", kind, "took", time.Since(start).Round(time.Second))
+	}
+}
+
+// refresh fills a new version of kind when the current one is older than
+// the interval. It reports whether it did; a refresh already running in
+// another process is left to finish.
+func (c *warmCache) refresh(ctx context.Context, kind string, fill func(context.Context, string) error) (bool, error) {
+	root := filepath.Join(c.dir, kind)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return false, err
+	}
+	lock, err := flockFile(filepath.Join(root, cacheRefreshLock), os.O_RDWR|os.O_CREATE, syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer lock.Close()
+	if info, err := os.Stat(filepath.Join(root, cacheCurrent)); err == nil && time.Since(info.ModTime()) < c.interval {
+		return false, nil
+	}
+
+	tmp, err := os.MkdirTemp(root, time.Now().UTC().Format("20060102T150405Z")+"-*.tmp")
+	if err != nil {
+		return false, err
+	}
+	stamp := strings.TrimSuffix(filepath.Base(tmp), ".tmp")
+	if err := os.WriteFile(filepath.Join(tmp, cacheVersionLock), nil, 0o644); err != nil {
+		_ = os.RemoveAll(tmp)
+		return false, err
+	}
+	if err := fill(ctx, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, filepath.Join(root, stamp)); err != nil {
+		_ = os.RemoveAll(tmp)
+		return false, err
+	}
+	if err := os.WriteFile(filepath.Join(root, cacheCurrent+".tmp"), []byte(stamp+"\n"), 0o644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(filepath.Join(root, cacheCurrent+".tmp"), filepath.Join(root, cacheCurrent)); err != nil {
+		return false, err
+	}
+	pruneCacheVersions(root, stamp)
+	return true, nil
+}
+
+// pruneCacheVersions removes the versions of a kind other than current
+// that no scan holds. The caller holds the kind's refresh lock, so leftover
+// .tmp directories are from refreshes that died.
+func pruneCacheVersions(root, current string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == current {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		f, err := flockFile(filepath.Join(dir, cacheVersionLock), os.O_RDONLY, syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			continue
+		}
+		_ = os.RemoveAll(dir)
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// use returns the current version of kind, held until release is called.
+// ok is false when the cache is off or kind has not been filled yet.
+func (c *warmCache) use(kind string) (dir string, release func(), ok bool) {
+	if c == nil {
+		return "", nil, false
+	}
+	root := filepath.Join(c.dir, kind)
+	// A refresh can remove the version read from current before it is
+	// locked; read current again when that happens.
+	for attempt := 0; attempt < 3; attempt++ {
+		stamp, err := os.ReadFile(filepath.Join(root, cacheCurrent))
+		if err != nil {
+			return "", nil, false
+		}
+		dir = filepath.Join(root, strings.TrimSpace(string(stamp)))
+		f, err := flockFile(filepath.Join(dir, cacheVersionLock), os.O_RDONLY, syscall.LOCK_SH)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, cacheVersionLock)); err != nil {
+			f.Close()
+			continue
+		}
+		return dir, func() { f.Close() }, true
+	}
+	return "", nil, false
+}
+
+// flockFile opens path and takes a flock on it; closing the file releases
+// the lock.
+func flockFile(path string, flag, how int) (*os.File, error) {
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// fillSemgrep downloads each registry pack. A pack that fails is left out
+// with a warning; scans fetch it from the registry as before.
+func (c *warmCache) fillSemgrep(ctx context.Context, dir string) error {
+	saved := 0
+	for _, pack := range c.packs {
+		b, err := c.fetchRulePack(ctx, pack)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Warn("semgrep rule pack not cached", "pack", pack, "err", err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, rulePackFile(pack)), b, 0o644); err != nil {
+			return err
+		}
+		saved++
+	}
+	if saved == 0 {
+		return errors.New("no semgrep rule pack could be downloaded")
+	}
+	return nil
+}
+
+func (c *warmCache) fetchRulePack(ctx context.Context, pack string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, semgrepRegistryURL+pack, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRulePackBytes))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(b, []byte("rules:")) {
+		return nil, errors.New("registry response is not a rule file")
+	}
+	return b, nil
+}
+
+// fillTrivy downloads trivy's vulnerability and Java DBs into dir, which
+// scans then pass as --cache-dir.
+func (c *warmCache) fillTrivy(ctx context.Context, dir string) error {
+	for _, only := range []string{"--download-db-only", "--download-java-db-only"} {
+		if _, err := runCmdJSON(ctx, "trivy", []string{"image", only, "--cache-dir", dir, "--quiet"}, dir, c.env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cachedSemgrepConfigs replaces the registry packs among configs with their
+// cached files. Packs the cache lacks stay registry references.
+func cachedSemgrepConfigs(dir string, configs []string) []string {
+	out := make([]string, len(configs))
+	for i, c := range configs {
+		out[i] = c
+		if !isRegistryPack(c) {
+			continue
+		}
+		if path := filepath.Join(dir, rulePackFile(c)); fileExists(path) {
+			out[i] = path
+		}
+	}
+	return out
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWarmCacheRefresh(t *testing.T) {
+	c := &warmCache{dir: t.TempDir(), interval: time.Hour}
+	if _, _, ok := c.use(cacheSemgrep); ok {
+		t.Fatal("an empty cache should not be usable")
+	}
+	fills := 0
+	fill := func(_ context.Context, dir string) error {
+		fills++
+		return os.WriteFile(filepath.Join(dir, "p_golang.yml"), []byte("rules: []\n"), 0o644)
+	}
+	ctx := context.Background()
+	if ok, err := c.refresh(ctx, cacheSemgrep, fill); !ok || err != nil {
+		t.Fatalf("refresh = %v, %v", ok, err)
+	}
+	if ok, err := c.refresh(ctx, cacheSemgrep, fill); ok || err != nil || fills != 1 {
+		t.Fatalf("a fresh cache was refreshed again: %v, %v, fills=%d", ok, err, fills)
+	}
+
+	first, release, ok := c.use(cacheSemgrep)
+	if !ok {
+		t.Fatal("cache not usable after a refresh")
+	}
+	// A held version survives the next refresh; a released one is pruned.
+	c.interval = time.Nanosecond
+	if ok, err := c.refresh(ctx, cacheSemgrep, fill); !ok || err != nil {
+		t.Fatalf("refresh = %v, %v", ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(first, "p_golang.yml")); err != nil {
+		t.Fatalf("held version removed: %v", err)
+	}
+	second, release2, _ := c.use(cacheSemgrep)
+	if second == first {
+		t.Fatal("current still points at the old version")
+	}
+	release()
+	release2()
+	if ok, err := c.refresh(ctx, cacheSemgrep, fill); !ok || err != nil {
+		t.Fatalf("refresh = %v, %v", ok, err)
+	}
+	for _, dir := range []string{first, second} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("released version %s not pruned", dir)
+		}
+	}
+
+	// A failed refresh keeps the current version.
+	third, release, _ := c.use(cacheSemgrep)
+	release()
+	if ok, err := c.refresh(ctx, cacheSemgrep, func(context.Context, string) error { return context.Canceled }); ok || err == nil {
+		t.Fatalf("refresh = %v, %v", ok, err)
+	}
+	if dir, release, ok := c.use(cacheSemgrep); !ok || dir != third {
+		t.Fatalf("use after a failed refresh = %q, %v", dir, ok)
+	} else {
+		release()
+	}
+}
+
+func TestCachedSemgrepConfigs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "p_golang.yml"), []byte("rules: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := cachedSemgrepConfigs(dir, []string{"p/golang", "p/rust", "/work/semgrep-rules/org.yml"})
+	want := []string{filepath.Join(dir, "p_golang.yml"), "p/rust", "/work/semgrep-rules/org.yml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("configs = %v", got)
+	}
+	var off *warmCache
+	if _, _, ok := off.use(cacheTrivy); ok {
+		t.Fatal("a nil cache should not be usable")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Job workspaces live at <workspace.root>/<worker-id>-<pid>/<job-id>/ so
+// several workers sharing a node (and a temp volume) never touch each
+// other's directories. Each workspace carries a manifest naming its owner,
+// down to when the owning process started, since a restarted container
+// reuses its PID.
+const manifestName = "manifest.json"
+
+// orphanGrace is how long a workspace without a manifest is left alone; a
+// live worker writes the manifest immediately after creating the directory.
+const orphanGrace = time.Hour
+
+type workspaceManifest struct {
+	WorkerID  string    `json:"worker_id"`
+	Hostname  string    `json:"hostname"`
+	PID       int       `json:"pid"`
+	JobID     string    `json:"job_id"`
+	RepoID    string    `json:"repo_id,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// StartedAt is when the owning process started.
+	StartedAt time.Time `json:"started_at"`
+}
+
+type workspaceOwner struct {
+	id       string
+	hostname string
+	pid      int
+	started  time.Time
+}
+
+func currentOwner(workerID string) workspaceOwner {
+	host, _ := os.Hostname()
+	return workspaceOwner{id: workerID, hostname: host, pid: os.Getpid(), started: time.Now().UTC()}
+}
+
+// name identifies this worker process in workspace paths and queue keys.
+func (o workspaceOwner) name() string {
+	return fmt.Sprintf("%s-%d", o.id, o.pid)
+}
+
+func (o workspaceOwner) dir(root string) string {
+	return filepath.Join(root, o.name())
+}
+
+// createWorkspace makes a fresh workspace for msg and records its manifest.
+func createWorkspace(root string, owner workspaceOwner, msg JobMsg) (string, error) {
+	dir := filepath.Join(owner.dir(root), msg.JobID)
+	_ = os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	m := workspaceManifest{
+		WorkerID:  owner.id,
+		Hostname:  owner.hostname,
+		PID:       owner.pid,
+		JobID:     msg.JobID,
+		RepoID:    msg.RepoID,
+		Source:    msg.Source,
+		CreatedAt: time.Now().UTC(),
+		StartedAt: owner.started,
+	}
+	b, _ := json.MarshalIndent(m, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, manifestName), b, 0o644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// reconcileWorkspaces removes workspaces left behind by dead workers on this
+// host. Workspaces owned by live processes, including self, or by another
+// host sharing the volume, are preserved. It runs at startup and then
+// periodically from runWorkspaceSweeper.
+func reconcileWorkspaces(root string, self workspaceOwner) (removed, kept int) {
+	owners, err := os.ReadDir(root)
+	if err != nil {
+		return 0, 0
+	}
+	for _, o := range owners {
+		ownerPath := filepath.Join(root, o.Name())
+		if !o.IsDir() {
+			continue
+		}
+		if _, ok := parseOwnerDir(o.Name()); !ok {
+			// Pre-namespacing layout: <root>/<job-id>.
+			if stale(ownerPath) {
+				_ = os.RemoveAll(ownerPath)
+				removed++
+			} else {
+				kept++
+			}
+			continue
+		}
+		jobs, _ := os.ReadDir(ownerPath)
+		for _, j := range jobs {
+			jobPath := filepath.Join(ownerPath, j.Name())
+			if workspaceDead(jobPath, self) {
+				_ = os.RemoveAll(jobPath)
+				removed++
+			} else {
+				kept++
+			}
+		}
+		// Drops the owner directory only once it is empty.
+		_ = os.Remove(ownerPath)
+	}
+	return removed, kept
+}
+
+// workspaceSweepEvery is how often a running worker removes workspaces of
+// other processes on this host that died since it started.
+const workspaceSweepEvery = 15 * time.Minute
+
+// runWorkspaceSweeper repeats reconcileWorkspaces until ctx is cancelled.
+func runWorkspaceSweeper(ctx context.Context, root string, self workspaceOwner) {
+	t := time.NewTicker(workspaceSweepEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if removed, _ := reconcileWorkspaces(root, self); removed > 0 {
+			slog.Info("removed workspaces of dead workers", "root", root, "removed", removed)
+		}
+	}
+}
+
+// workspaceDead reports whether the workspace at path belongs to a process
+// on this host that is gone. A workspace with self's PID is self's only if
+// its owner started when self did; otherwise it is from an earlier process
+// that had the PID, such as the worker before a container restart.
+func workspaceDead(path string, self workspaceOwner) bool {
+	b, err := os.ReadFile(filepath.Join(path, manifestName))
+	if err != nil {
+		return stale(path)
+	}
+	var m workspaceManifest
+	if err := json.Unmarshal(b, &m); err != nil || m.PID <= 0 {
+		return stale(path)
+	}
+	if m.Hostname != self.hostname {
+		return false
+	}
+	if m.PID == self.pid {
+		return !m.StartedAt.Equal(self.started)
+	}
+	return !processAlive(m.PID)
+}
+
+func parseOwnerDir(name string) (pid int, ok bool) {
+	i := strings.LastIndexByte(name, '-')
+	if i <= 0 {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(name[i+1:])
+	return pid, err == nil && pid > 0
+}
+
+func stale(path string) bool {
+	info, err := os.Stat(path)
+	return err != nil || time.Since(info.ModTime()) > orphanGrace
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
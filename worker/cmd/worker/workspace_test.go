@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconcileWorkspaces(t *testing.T) {
+	root := t.TempDir()
+	host, _ := os.Hostname()
+	self := workspaceOwner{id: "w1", hostname: host, pid: 1, started: time.Now().UTC()}
+
+	mk := func(owner workspaceOwner, job string) string {
+		dir, err := createWorkspace(root, owner, JobMsg{JobID: job})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	// Our own PID with another start time is an earlier incarnation of this
+	// worker; with ours it is a job we are running.
+	earlier := self
+	earlier.started = self.started.Add(-time.Hour)
+	previous := mk(earlier, "job-restarted")
+	running := mk(self, "job-running")
+	dead := mk(workspaceOwner{id: "w2", hostname: host, pid: 1 << 30}, "job-dead")
+	live := mk(workspaceOwner{id: "w3", hostname: host, pid: os.Getpid()}, "job-live")
+	// Liveness cannot be checked for another host's processes.
+	remote := mk(workspaceOwner{id: "w4", hostname: host + "-other", pid: 1 << 30}, "job-remote")
+
+	removed, kept := reconcileWorkspaces(root, self)
+	if removed != 2 || kept != 3 {
+		t.Fatalf("removed=%d kept=%d", removed, kept)
+	}
+	for _, dir := range []string{previous, dead} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("%s should have been removed", dir)
+		}
+	}
+	for _, dir := range []string{running, live, remote} {
+		if _, err := os.Stat(filepath.Join(dir, manifestName)); err != nil {
+			t.Fatalf("%s should have been kept: %v", dir, err)
+		}
+	}
+}
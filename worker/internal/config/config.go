@@ -0,0 +1,641 @@
+// Package config loads worker settings from an optional YAML or TOML file and
+// the environment. Precedence, lowest first: built-in defaults, the config
+// file, ARGUS_<SECTION>_<KEY> variables, then the legacy variable names
+// (DATABASE_URL, MAX_CLONE_MB, ...) that existing deployments already set.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/internal/configfile"
+	"argus/internal/forge"
+	"argus/internal/imagecreds"
+	"argus/internal/objstore"
+	"argus/internal/proxy"
+)
+
+type Config struct {
+	DatabaseURL  string
+	RedisAddr    string
+	JobQueue     string
+	QueueBackend string // QueueRedis, or QueuePostgres for deployments without Redis
+	AllowedHosts []string
+	GitToken     string
+	// GitHubAppID and GitHubAppKey let the worker mint an installation
+	// token for each clone instead of using GitToken. A repo's own
+	// installation comes first, then GitHubInstallationID.
+	GitHubAppID          int64
+	GitHubAppKey         string // PEM
+	GitHubInstallationID int64
+	GitHubURL            string // web URL of github.com or a GitHub Enterprise Server
+	MaxCloneMB           int
+	ScanTimeoutMin       int
+	Scanners             []string
+	ScannerWorkers       int    // scanners one job runs at the same time
+	SemgrepConfig        string // SemgrepDetect, or a value passed to semgrep --config verbatim
+	SemgrepTimeout       int    // seconds per rule/file, passed to semgrep --timeout
+	TrivyTimeout         string
+	// ScannerTimeouts bound each scanner's run, keyed by scanner name or
+	// "*" for the rest; unset leaves only limits.scan_timeout_min.
+	ScannerTimeouts map[string]string
+	// ScannerOnFailure says what a scanner's failure does to its job,
+	// ScannerContinue or ScannerFailJob, keyed like ScannerTimeouts.
+	ScannerOnFailure map[string]string
+	// TrufflehogVerify lets trufflehog check the secrets it finds against
+	// their providers, to tell live ones apart.
+	TrufflehogVerify bool
+	// TerraformDownloadModules lets the terraform stage fetch remote module
+	// sources; otherwise only local and already-downloaded modules resolve.
+	TerraformDownloadModules bool
+	LogLevel                 string
+	LogFormat                string
+	HealthAddr               string // empty disables the health listener
+	WorkerID                 string
+	Concurrency              int      // jobs processed in parallel by one worker process
+	ShutdownGrace            string   // duration in-flight jobs may run after SIGTERM before being requeued
+	SizeClasses              []string // job size classes this worker takes: small, medium, large
+	WorkspaceRoot            string
+	ArtifactMaxMB            int
+	MaxAttempts              int    // runs per job, counting the first, for transient failures
+	RetryBackoff             string // duration before the first retry; doubles each time
+	// ArtifactStore is the S3-compatible bucket scanner output goes to
+	// instead of the database; unset keeps it in the database.
+	ArtifactStore objstore.Config
+	// CacheDir holds semgrep rule packs and trivy DBs kept warm in the
+	// background; empty turns the warm cache off.
+	CacheDir             string
+	CacheRefreshInterval string // duration between refreshes
+	// CloneCacheMB is the disk budget of the per-repo git mirrors kept
+	// under CacheDir; 0 clones every job afresh.
+	CloneCacheMB int
+
+	// Offline runs scanners against local bundles only; see offline.*.
+	Offline                  bool
+	OfflineSemgrepRules      string
+	OfflineTrivyCache        string
+	OfflineTrivyDBRepository string // internal OCI mirror of the trivy DB, if any
+	OfflineGrypeDB           string // grype DB cache directory
+	OfflineGovulncheckDB     string // copy of the Go vulnerability database
+	OfflineNPMRegistry       string // internal npm registry serving audit requests
+	OfflineMaxBundleAge      string // duration; 0 disables the freshness check
+
+	// ResultsMode is "db" (write to Postgres directly) or "api" (submit
+	// through the API's internal endpoints, authenticated with WorkerToken).
+	ResultsMode   string
+	ResultsAPIURL string
+	WorkerToken   string
+
+	// Outbound proxies per destination: "" uses HTTPS_PROXY and friends,
+	// "direct" none, anything else is the proxy URL.
+	GitHubProxy  string // GitHub and GitLab APIs and git remotes
+	ScannerProxy string // semgrep registry and trivy DB downloads
+	NoProxy      []string
+
+	// DAST: nuclei probes preview deployments named on scan requests.
+	DASTAllowedHosts []string // deployment hosts nuclei may probe
+	NucleiTemplates  string   // template directory; empty uses nuclei's own
+	NucleiRateLimit  int      // requests per second against the deployment
+
+	// ImageRegistries are the registries image scans may pull from.
+	ImageRegistries []string
+	// ImageCredentialsKey opens image scans' registry credentials, sealed
+	// by the API with the same key. Only results.mode db reads them itself.
+	ImageCredentialsKey *imagecreds.Key
+
+	// GitHubCheckRuns posts a check run with the results of each pull
+	// request scan through the GitHub App; PublicURL is the API's external
+	// base URL, which the check runs link to. GitHubReviewComments comments
+	// on the diff lines where a pull request scan found something new.
+	// GitHubCodeScanning uploads each scan's findings to the repo's code
+	// scanning as SARIF. GitHubCommitStatuses sets a commit status on the
+	// commit of each scan pinned to one, pending while it runs.
+	GitHubCheckRuns      bool
+	GitHubReviewComments bool
+	GitHubCodeScanning   bool
+	GitHubCommitStatuses bool
+	PublicURL            string
+
+	// GitLabURL is the web URL of gitlab.com or a self-managed GitLab.
+	// GitLabToken clones its projects and comments on the merge requests
+	// scanned; GitLabReviewComments turns the comments off.
+	GitLabURL            string
+	GitLabToken          string
+	GitLabReviewComments bool
+
+	// AzureDevOpsToken is a personal access token that clones Azure Repos
+	// on dev.azure.com.
+	AzureDevOpsToken string
+}
+
+// GitHubProxySetting is the proxy for the GitHub and GitLab APIs and git
+// remotes.
+func (c Config) GitHubProxySetting() proxy.Setting {
+	return proxy.Setting{URL: c.GitHubProxy, NoProxy: c.NoProxy}
+}
+
+// ScannerProxySetting is the proxy for scanner rule and database downloads.
+func (c Config) ScannerProxySetting() proxy.Setting {
+	return proxy.Setting{URL: c.ScannerProxy, NoProxy: c.NoProxy}
+}
+
+// SemgrepDetect selects curated semgrep rule packs from the languages found in
+// the scanned tree instead of passing a fixed --config.
+const SemgrepDetect = "detect"
+
+// Queue backends for queue.backend.
+const (
+	QueueRedis    = "redis"
+	QueuePostgres = "postgres"
+)
+
+// Scanner failure policies for scanners.on_failure.
+const (
+	ScannerContinue = "continue" // record the failure; the job still succeeds
+	ScannerFailJob  = "fail"     // fail the job
+)
+
+func Defaults() Config {
+	return Config{
+		JobQueue:       "ssao:jobs",
+		QueueBackend:   QueueRedis,
+		AllowedHosts:   []string{"github.com"},
+		GitHubURL:      "https://github.com",
+		MaxCloneMB:     350,
+		ScanTimeoutMin: 20,
+		Scanners:       []string{"semgrep", "gitleaks", "trivy", "hadolint", "kube-linter", "govulncheck", "bandit", "syft"},
+		ScannerWorkers: 3,
+		SemgrepConfig:  SemgrepDetect,
+		SemgrepTimeout: 120,
+		TrivyTimeout:   "8m",
+		LogLevel:       "info",
+		LogFormat:      "text",
+		HealthAddr:     ":8081",
+		WorkerID:       hostname(),
+		Concurrency:    1,
+		ShutdownGrace:  "25s",
+		SizeClasses:    []string{"small", "medium", "large"},
+		WorkspaceRoot:  filepath.Join(os.TempDir(), "argus"),
+		ArtifactMaxMB:  25,
+		ArtifactStore:  objstore.Config{Region: "us-east-1"},
+		MaxAttempts:    3,
+		RetryBackoff:   "30s",
+
+		TrufflehogVerify:     true,
+		GitHubCheckRuns:      true,
+		GitHubReviewComments: true,
+		GitLabURL:            "https://gitlab.com",
+		GitLabReviewComments: true,
+
+		CacheRefreshInterval: "6h",
+
+		OfflineMaxBundleAge: "720h",
+		ResultsMode:         "db",
+		NucleiRateLimit:     50,
+	}
+}
+
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		return "worker"
+	}
+	return h
+}
+
+// setters bind each of configfile.WorkerKeys to the field it sets.
+func (c *Config) setters() map[string]func(string) error {
+	return map[string]func(string) error{
+		"database.url":                        str(&c.DatabaseURL),
+		"redis.addr":                          str(&c.RedisAddr),
+		"queue.jobs":                          str(&c.JobQueue),
+		"queue.backend":                       str(&c.QueueBackend),
+		"git.allowed_hosts":                   list(&c.AllowedHosts),
+		"git.token":                           str(&c.GitToken),
+		"github.app_id":                       id64(&c.GitHubAppID),
+		"github.private_key":                  str(&c.GitHubAppKey),
+		"github.installation_id":              id64(&c.GitHubInstallationID),
+		"github.url":                          str(&c.GitHubURL),
+		"github.check_runs":                   boolean(&c.GitHubCheckRuns),
+		"github.review_comments":              boolean(&c.GitHubReviewComments),
+		"github.code_scanning":                boolean(&c.GitHubCodeScanning),
+		"github.commit_statuses":              boolean(&c.GitHubCommitStatuses),
+		"gitlab.url":                          str(&c.GitLabURL),
+		"gitlab.token":                        str(&c.GitLabToken),
+		"gitlab.review_comments":              boolean(&c.GitLabReviewComments),
+		"azure_devops.token":                  str(&c.AzureDevOpsToken),
+		"server.public_url":                   str(&c.PublicURL),
+		"limits.max_clone_mb":                 positive(&c.MaxCloneMB),
+		"limits.scan_timeout_min":             positive(&c.ScanTimeoutMin),
+		"scanners.enabled":                    list(&c.Scanners),
+		"scanners.parallelism":                positive(&c.ScannerWorkers),
+		"scanners.semgrep.config":             str(&c.SemgrepConfig),
+		"scanners.semgrep.timeout_sec":        positive(&c.SemgrepTimeout),
+		"scanners.trivy.timeout":              duration(&c.TrivyTimeout),
+		"scanners.timeouts":                   perScanner(&c.ScannerTimeouts, positiveDuration),
+		"scanners.on_failure":                 perScanner(&c.ScannerOnFailure, failurePolicy),
+		"scanners.terraform.download_modules": boolean(&c.TerraformDownloadModules),
+		"scanners.trufflehog.verify":          boolean(&c.TrufflehogVerify),
+		"log.level":                           str(&c.LogLevel),
+		"log.format":                          str(&c.LogFormat),
+		"health.listen":                       str(&c.HealthAddr),
+		"worker.id":                           str(&c.WorkerID),
+		"worker.concurrency":                  positive(&c.Concurrency),
+		"worker.shutdown_grace":               duration(&c.ShutdownGrace),
+		"worker.size_classes":                 list(&c.SizeClasses),
+		"workspace.root":                      str(&c.WorkspaceRoot),
+		"limits.artifact_max_mb":              positive(&c.ArtifactMaxMB),
+		"artifacts.s3.endpoint":               str(&c.ArtifactStore.Endpoint),
+		"artifacts.s3.bucket":                 str(&c.ArtifactStore.Bucket),
+		"artifacts.s3.region":                 str(&c.ArtifactStore.Region),
+		"artifacts.s3.access_key_id":          str(&c.ArtifactStore.AccessKeyID),
+		"artifacts.s3.secret_access_key":      str(&c.ArtifactStore.SecretAccessKey),
+		"artifacts.s3.path_style":             boolean(&c.ArtifactStore.PathStyle),
+		"artifacts.s3.prefix":                 str(&c.ArtifactStore.Prefix),
+		"retries.max_attempts":                positive(&c.MaxAttempts),
+		"retries.backoff":                     duration(&c.RetryBackoff),
+		"cache.dir":                           str(&c.CacheDir),
+		"cache.refresh_interval":              duration(&c.CacheRefreshInterval),
+		"cache.clones_max_mb":                 positive(&c.CloneCacheMB),
+		"offline.enabled":                     boolean(&c.Offline),
+		"offline.semgrep_rules":               str(&c.OfflineSemgrepRules),
+		"offline.trivy_cache_dir":             str(&c.OfflineTrivyCache),
+		"offline.trivy_db_repository":         str(&c.OfflineTrivyDBRepository),
+		"offline.grype_db_dir":                str(&c.OfflineGrypeDB),
+		"offline.govulncheck_db_dir":          str(&c.OfflineGovulncheckDB),
+		"offline.npm_registry":                str(&c.OfflineNPMRegistry),
+		"offline.max_bundle_age":              duration(&c.OfflineMaxBundleAge),
+		"results.mode":                        str(&c.ResultsMode),
+		"results.api_url":                     str(&c.ResultsAPIURL),
+		"proxy.github":                        proxyURL(&c.GitHubProxy),
+		"proxy.scanners":                      proxyURL(&c.ScannerProxy),
+		"proxy.no_proxy":                      list(&c.NoProxy),
+		"auth.worker_token":                   str(&c.WorkerToken),
+		"dast.allowed_hosts":                  list(&c.DASTAllowedHosts),
+		"scanners.nuclei.templates":           str(&c.NucleiTemplates),
+		"scanners.nuclei.rate_limit":          positive(&c.NucleiRateLimit),
+		"images.allowed_registries":           list(&c.ImageRegistries),
+		"images.credentials_key":              credentialsKey(&c.ImageCredentialsKey),
+	}
+}
+
+// Load reads path (if non-empty), applies environment overrides and
+// validates the result; see configfile.Load.
+func Load(path string) (Config, error) {
+	c := Defaults()
+	if err := configfile.Load(path, configfile.WorkerKeys, configfile.APIKeys, c.setters()); err != nil {
+		return Config{}, err
+	}
+	switch c.QueueBackend {
+	case QueueRedis:
+		if c.RedisAddr == "" {
+			return Config{}, fmt.Errorf("redis.addr (REDIS_ADDR) is required")
+		}
+	case QueuePostgres:
+		if c.DatabaseURL == "" {
+			return Config{}, fmt.Errorf("database.url (DATABASE_URL) is required when queue.backend is postgres")
+		}
+	default:
+		return Config{}, fmt.Errorf("queue.backend must be %s or %s", QueueRedis, QueuePostgres)
+	}
+	switch c.ResultsMode {
+	case "db":
+		if c.DatabaseURL == "" {
+			return Config{}, fmt.Errorf("database.url (DATABASE_URL) is required")
+		}
+	case "api":
+		if c.ResultsAPIURL == "" || c.WorkerToken == "" {
+			return Config{}, fmt.Errorf("results.api_url and auth.worker_token (WORKER_API_TOKEN) are required when results.mode is api")
+		}
+		if u, err := url.Parse(c.ResultsAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("results.api_url must be an http(s) URL")
+		}
+	default:
+		return Config{}, fmt.Errorf("results.mode must be db or api")
+	}
+	if len(c.AllowedHosts) == 0 {
+		return Config{}, fmt.Errorf("git.allowed_hosts must list at least one host")
+	}
+	// The GitHub instance's repos are always allowed, so a GitHub
+	// Enterprise Server needs only github.url.
+	host, err := githubHost(c.GitHubURL)
+	if err != nil {
+		return Config{}, err
+	}
+	c.GitHubURL = strings.TrimSuffix(c.GitHubURL, "/")
+	if !c.HostAllowed(host) {
+		c.AllowedHosts = append(c.AllowedHosts, host)
+	}
+	// Likewise the GitLab instance's, once there is a token for it.
+	if host, err = gitlabHost(c.GitLabURL); err != nil {
+		return Config{}, err
+	}
+	c.GitLabURL = strings.TrimSuffix(c.GitLabURL, "/")
+	if c.GitLabToken != "" && !c.HostAllowed(host) {
+		c.AllowedHosts = append(c.AllowedHosts, host)
+	}
+	if c.AzureDevOpsToken != "" && !c.HostAllowed(forge.AzureDevOps) {
+		c.AllowedHosts = append(c.AllowedHosts, forge.AzureDevOps)
+	}
+	if s := &c.ArtifactStore; s.Enabled() {
+		if s.Endpoint == "" {
+			s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+		}
+		if u, err := url.Parse(s.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("artifacts.s3.endpoint must be an http(s) URL")
+		}
+		if s.Region == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return Config{}, fmt.Errorf("artifacts.s3.bucket needs artifacts.s3.region, artifacts.s3.access_key_id (AWS_ACCESS_KEY_ID) and artifacts.s3.secret_access_key (AWS_SECRET_ACCESS_KEY)")
+		}
+	}
+	if (c.GitHubAppID != 0) != (c.GitHubAppKey != "") {
+		return Config{}, fmt.Errorf("github.app_id (GITHUB_APP_ID) and github.private_key (GITHUB_PRIVATE_KEY_PEM) must be set together")
+	}
+	if !validWorkerID(c.WorkerID) {
+		return Config{}, fmt.Errorf("worker.id must be non-empty and use only letters, digits, '.', '_' or '-'")
+	}
+	if c.WorkspaceRoot == "" {
+		return Config{}, fmt.Errorf("workspace.root must not be empty")
+	}
+	if c.CacheDir != "" {
+		if d, _ := time.ParseDuration(c.CacheRefreshInterval); d <= 0 {
+			return Config{}, fmt.Errorf("cache.refresh_interval must be positive")
+		}
+		// The workspace sweeper removes whatever it does not recognize
+		// under workspace.root.
+		if rel, err := filepath.Rel(c.WorkspaceRoot, c.CacheDir); err == nil && !strings.HasPrefix(rel, "..") {
+			return Config{}, fmt.Errorf("cache.dir must not be inside workspace.root")
+		}
+	} else if c.CloneCacheMB > 0 {
+		return Config{}, fmt.Errorf("cache.clones_max_mb needs cache.dir")
+	}
+	for _, s := range c.Scanners {
+		if !knownScanners[s] {
+			return Config{}, fmt.Errorf("scanners.enabled: unknown scanner %q", s)
+		}
+	}
+	if len(c.SizeClasses) == 0 {
+		return Config{}, fmt.Errorf("worker.size_classes must list at least one class")
+	}
+	for _, s := range c.SizeClasses {
+		if !knownSizeClasses[s] {
+			return Config{}, fmt.Errorf("worker.size_classes: unknown class %q (want small, medium or large)", s)
+		}
+	}
+	if c.Offline {
+		if err := c.validateOffline(); err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+// validateOffline checks that every enabled scanner has a local bundle. The
+// bundles' contents and age are checked by the worker at startup.
+func (c Config) validateOffline() error {
+	if c.ScannerEnabled("semgrep") {
+		if c.OfflineSemgrepRules == "" {
+			return fmt.Errorf("offline.semgrep_rules is required in offline mode")
+		}
+		if c.SemgrepConfig != SemgrepDetect && !isLocalPath(c.SemgrepConfig) {
+			return fmt.Errorf("scanners.semgrep.config %q needs the semgrep registry; use detect or a local path in offline mode", c.SemgrepConfig)
+		}
+	}
+	if (c.ScannerEnabled("trivy") || c.ScannerEnabled("terraform")) && c.OfflineTrivyCache == "" {
+		return fmt.Errorf("offline.trivy_cache_dir is required in offline mode")
+	}
+	if c.ScannerEnabled("terraform") && c.TerraformDownloadModules {
+		return fmt.Errorf("scanners.terraform.download_modules needs the network; turn it off in offline mode")
+	}
+	if c.ScannerEnabled("trufflehog") && c.TrufflehogVerify {
+		return fmt.Errorf("scanners.trufflehog.verify needs the network; turn it off in offline mode")
+	}
+	if c.ScannerEnabled("grype") && c.OfflineGrypeDB == "" {
+		return fmt.Errorf("offline.grype_db_dir is required in offline mode")
+	}
+	if c.ScannerEnabled("govulncheck") && c.OfflineGovulncheckDB == "" {
+		return fmt.Errorf("offline.govulncheck_db_dir is required in offline mode")
+	}
+	if c.ScannerEnabled("npm-audit") && c.OfflineNPMRegistry == "" {
+		return fmt.Errorf("offline.npm_registry is required in offline mode")
+	}
+	if c.ScannerEnabled("pip-audit") {
+		return fmt.Errorf("pip-audit needs PyPI's advisory API; remove it from scanners.enabled in offline mode")
+	}
+	if c.ScannerEnabled("nuclei") && c.NucleiTemplates == "" {
+		return fmt.Errorf("scanners.nuclei.templates is required in offline mode")
+	}
+	return nil
+}
+
+func isLocalPath(v string) bool {
+	return strings.HasPrefix(v, "/") || strings.HasPrefix(v, "./") || strings.HasPrefix(v, "../")
+}
+
+// validWorkerID keeps worker IDs safe to embed in workspace paths.
+func validWorkerID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return id != "." && id != ".."
+}
+
+var knownScanners = map[string]bool{"semgrep": true, "gitleaks": true, "trufflehog": true, "trivy": true, "terraform": true, "hadolint": true, "kube-linter": true, "govulncheck": true, "npm-audit": true, "pip-audit": true, "bandit": true, "grype": true, "syft": true, "nuclei": true}
+
+var knownSizeClasses = map[string]bool{"small": true, "medium": true, "large": true}
+
+// githubHost checks github.url and returns its host.
+func githubHost(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil || strings.Trim(u.Path, "/") != "" {
+		return "", fmt.Errorf("github.url must be an https URL without a path, such as https://github.example.com")
+	}
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// gitlabHost checks gitlab.url and returns its host. Unlike github.url it
+// may have a path, for an instance under a relative URL root.
+func gitlabHost(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("gitlab.url must be an https URL, such as https://gitlab.example.com")
+	}
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// HostAllowed reports whether host is one of the configured git hosts.
+func (c Config) HostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range c.AllowedHosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// ScannerEnabled reports whether name is listed in scanners.enabled.
+func (c Config) ScannerEnabled(name string) bool {
+	for _, s := range c.Scanners {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DASTHostAllowed reports whether host may be probed by nuclei. An entry
+// with a leading dot matches every subdomain of the rest.
+func (c Config) DASTHostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range c.DASTAllowedHosts {
+		p = strings.ToLower(p)
+		if host == p || strings.HasPrefix(p, ".") && strings.HasSuffix(host, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageRegistryAllowed reports whether image scans may pull from registry,
+// a host with an optional port.
+func (c Config) ImageRegistryAllowed(registry string) bool {
+	registry = strings.ToLower(registry)
+	for _, r := range c.ImageRegistries {
+		if strings.ToLower(r) == registry {
+			return true
+		}
+	}
+	return false
+}
+
+func str(p *string) func(string) error {
+	return func(v string) error {
+		*p = strings.TrimSpace(v)
+		return nil
+	}
+}
+
+func credentialsKey(p **imagecreds.Key) func(string) error {
+	return func(v string) error {
+		k, err := imagecreds.ParseKey(strings.TrimSpace(v))
+		*p = k
+		return err
+	}
+}
+
+func proxyURL(p *string) func(string) error {
+	return func(v string) error {
+		v = strings.TrimSpace(v)
+		if err := proxy.Validate(v); err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	}
+}
+
+func positive(p *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive integer, got %q", v)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func id64(p *int64) func(string) error {
+	return func(v string) error {
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a numeric ID, got %q", v)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func boolean(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", v)
+		}
+		*p = b
+		return nil
+	}
+}
+
+func duration(p *string) func(string) error {
+	return func(v string) error {
+		v = strings.TrimSpace(v)
+		if _, err := time.ParseDuration(v); err != nil {
+			return fmt.Errorf("must be a duration such as 8m, got %q", v)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func list(p *[]string) func(string) error {
+	return func(v string) error {
+		var out []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+				out = append(out, item)
+			}
+		}
+		*p = out
+		return nil
+	}
+}
+
+// perScanner reads name=value pairs such as "*=15m,trivy=30m", where name
+// is a scanner or "*" for every other one.
+func perScanner(p *map[string]string, check func(string) error) func(string) error {
+	return func(v string) error {
+		out := map[string]string{}
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(item, "=")
+			name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+			if !ok || name != "*" && !knownScanners[name] {
+				return fmt.Errorf("want scanner=value pairs with a known scanner or *, got %q", item)
+			}
+			if err := check(value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			out[name] = value
+		}
+		*p = out
+		return nil
+	}
+}
+
+func positiveDuration(v string) error {
+	if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+		return fmt.Errorf("must be a positive duration such as 15m, got %q", v)
+	}
+	return nil
+}
+
+func failurePolicy(v string) error {
+	if v != ScannerContinue && v != ScannerFailJob {
+		return fmt.Errorf("must be %s or %s, got %q", ScannerContinue, ScannerFailJob, v)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MaxAnnotations is how many annotations GitHub accepts in one check run
+// request; CreateCheckRun sends the rest in follow-up updates.
+const MaxAnnotations = 50
+
+// Annotation levels.
+const (
+	LevelNotice  = "notice"
+	LevelWarning = "warning"
+	LevelFailure = "failure"
+)
+
+// CheckRun is a completed check run on a commit.
+type CheckRun struct {
+	Name       string      `json:"name"`
+	HeadSHA    string      `json:"head_sha"`
+	Conclusion string      `json:"conclusion"` // success, neutral or failure
+	DetailsURL string      `json:"details_url,omitempty"`
+	ExternalID string      `json:"external_id,omitempty"`
+	Output     CheckOutput `json:"output"`
+}
+
+// CheckOutput is what the check run shows on its page and in the pull
+// request's Files Changed tab.
+type CheckOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"` // markdown
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// Annotation marks lines of a file in the commit.
+type Annotation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Level     string `json:"annotation_level"`
+	Title     string `json:"title,omitempty"`
+	Message   string `json:"message"`
+}
+
+// CreateCheckRun creates a completed check run on owner/repo, in as many
+// requests as its annotations need, and returns its ID. The installation
+// needs the Checks: Read & write permission.
+func (c *Client) CreateCheckRun(ctx context.Context, token, owner, repo string, run CheckRun) (int64, error) {
+	annotations := run.Output.Annotations
+	run.Output.Annotations = annotations[:min(len(annotations), MaxAnnotations)]
+	body := struct {
+		CheckRun
+		Status string `json:"status"`
+	}{run, "completed"}
+	var out struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/check-runs", owner, repo), token, body, &out); err != nil {
+		return 0, err
+	}
+	path := fmt.Sprintf("/repos/%s/%s/check-runs/%d", owner, repo, out.ID)
+	for i := MaxAnnotations; i < len(annotations); i += MaxAnnotations {
+		// Annotations sent in an update are added to the earlier ones.
+		output := run.Output
+		output.Annotations = annotations[i:min(len(annotations), i+MaxAnnotations)]
+		if err := c.call(ctx, http.MethodPatch, path, token, map[string]any{"output": output}, nil); err != nil {
+			return out.ID, err
+		}
+	}
+	return out.ID, nil
+}
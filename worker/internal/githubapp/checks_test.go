@@ -0,0 +1,59 @@
+package githubapp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"argus/internal/proxy"
+)
+
+func TestCreateCheckRunBatchesAnnotations(t *testing.T) {
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghs_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Status  string      `json:"status"`
+			HeadSHA string      `json:"head_sha"`
+			Output  CheckOutput `json:"output"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/web/check-runs":
+			if body.Status != "completed" || body.HeadSHA != "abc123" {
+				t.Errorf("create body = %+v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":99}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/acme/web/check-runs/99":
+			if body.Output.Summary == "" {
+				t.Error("update dropped the summary")
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		batches = append(batches, len(body.Output.Annotations))
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: &http.Client{Transport: proxy.Setting{URL: proxy.Direct}.Transport()}, baseURL: srv.URL}
+	run := CheckRun{Name: "Argus", HeadSHA: "abc123", Conclusion: "failure", Output: CheckOutput{Title: "120 new findings", Summary: "summary"}}
+	for i := 0; i < 120; i++ {
+		run.Output.Annotations = append(run.Output.Annotations, Annotation{Path: "main.go", StartLine: i + 1, EndLine: i + 1, Level: LevelWarning, Message: "m"})
+	}
+	id, err := c.CreateCheckRun(context.Background(), "ghs_token", "acme", "web", run)
+	if err != nil || id != 99 {
+		t.Fatalf("CreateCheckRun = %d, %v", id, err)
+	}
+	if len(batches) != 3 || batches[0] != 50 || batches[1] != 50 || batches[2] != 20 {
+		t.Errorf("annotation batches = %v", batches)
+	}
+}
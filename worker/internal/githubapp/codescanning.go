@@ -0,0 +1,61 @@
+package githubapp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// MaxSARIF is the largest gzipped SARIF log code scanning accepts.
+const MaxSARIF = 10 << 20
+
+// UploadSARIF uploads a SARIF log of an analysis of commitSHA on ref, such
+// as refs/heads/main or refs/pull/7/head, to owner/repo's code scanning and
+// returns the upload's ID. GitHub processes it after responding. The
+// installation needs the Code scanning alerts: Read & write permission.
+func (c *Client) UploadSARIF(ctx context.Context, token, owner, repo, commitSHA, ref string, sarif []byte) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(sarif); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if buf.Len() > MaxSARIF {
+		return "", fmt.Errorf("sarif is %d bytes gzipped, over the %d code scanning accepts", buf.Len(), MaxSARIF)
+	}
+	body := map[string]string{
+		"commit_sha": commitSHA,
+		"ref":        ref,
+		"sarif":      base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := c.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/code-scanning/sarifs", owner, repo), token, body, &out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+// QualifyRef returns the full name of the branch or tag name in
+// owner/repo, as code scanning needs it: refs/heads/name when there is
+// such a branch, else refs/tags/name when there is such a tag, else "".
+func (c *Client) QualifyRef(ctx context.Context, token, owner, repo, name string) (string, error) {
+	for _, ns := range []string{"heads", "tags"} {
+		err := c.call(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/git/ref/%s/%s", owner, repo, ns, name), token, nil, nil)
+		var se *StatusError
+		switch {
+		case err == nil:
+			return "refs/" + ns + "/" + name, nil
+		case !errors.As(err, &se) || se.StatusCode != http.StatusNotFound:
+			return "", err
+		}
+	}
+	return "", nil
+}
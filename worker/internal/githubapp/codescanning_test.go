@@ -0,0 +1,76 @@
+package githubapp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"argus/internal/proxy"
+)
+
+func TestUploadSARIF(t *testing.T) {
+	sarif := []byte(`{"version":"2.1.0","runs":[]}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/web/code-scanning/sarifs" || r.Header.Get("Authorization") != "Bearer ghs_token" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["commit_sha"] != "abc123" || body["ref"] != "refs/heads/main" {
+			t.Errorf("body = %v", body)
+		}
+		gz, err := base64.StdEncoding.DecodeString(body["sarif"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(zr); !bytes.Equal(got, sarif) {
+			t.Errorf("sarif = %s", got)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"id":"47177e22","url":"https://api.github.com/repos/acme/web/code-scanning/sarifs/47177e22"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: &http.Client{Transport: proxy.Setting{URL: proxy.Direct}.Transport()}, baseURL: srv.URL}
+	id, err := c.UploadSARIF(context.Background(), "ghs_token", "acme", "web", "abc123", "refs/heads/main", sarif)
+	if err != nil || id != "47177e22" {
+		t.Fatalf("UploadSARIF = %q, %v", id, err)
+	}
+}
+
+func TestQualifyRef(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/web/git/ref/heads/main", "/repos/acme/web/git/ref/tags/v1":
+			_, _ = w.Write([]byte(`{}`))
+		case "/repos/acme/web/git/ref/heads/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: &http.Client{Transport: proxy.Setting{URL: proxy.Direct}.Transport()}, baseURL: srv.URL}
+	for name, want := range map[string]string{"main": "refs/heads/main", "v1": "refs/tags/v1", "gone": ""} {
+		if got, err := c.QualifyRef(context.Background(), "ghs_token", "acme", "web", name); err != nil || got != want {
+			t.Errorf("QualifyRef(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := c.QualifyRef(context.Background(), "ghs_token", "acme", "web", "broken"); err == nil {
+		t.Error("QualifyRef ignored a server error")
+	}
+}
@@ -0,0 +1,136 @@
+// Package githubapp mints GitHub App installation tokens for cloning and
+// reports pull request scans with them.
+package githubapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"argus/internal/apptoken"
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+// tokenMargin is how long before it expires a cached installation token
+// is replaced, long enough for a clone or a PR report started with it.
+const tokenMargin = 10 * time.Minute
+
+// Client mints installation tokens for one GitHub App and calls the REST API
+// with them.
+type Client struct {
+	httpClient *http.Client
+	appID      int64
+	key        *rsa.PrivateKey
+	baseURL    string
+	tokens     apptoken.Cache[int64] // by installation
+}
+
+// New parses the app's PEM private key and returns a client for the app on
+// host that reaches it through p.
+func New(appID int64, privateKeyPEM string, host forge.GitHub, p proxy.Setting) (*Client, error) {
+	key, err := apptoken.ParseKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 25 * time.Second, Transport: p.Transport()},
+		appID:      appID,
+		key:        key,
+		baseURL:    host.APIURL(),
+		tokens:     apptoken.Cache[int64]{Margin: tokenMargin},
+	}, nil
+}
+
+// InstallationToken returns a token for the installation. GitHub issues them
+// for an hour with the installation's permissions; one is reused across jobs
+// until it is within tokenMargin of expiring.
+func (c *Client) InstallationToken(ctx context.Context, installationID int64) (string, error) {
+	if token, ok := c.tokens.Get(installationID, time.Now()); ok {
+		return token, nil
+	}
+	tok, err := apptoken.Mint(ctx, c.httpClient, c.baseURL, c.appID, c.key, installationID)
+	if err != nil {
+		return "", fmt.Errorf("installation %d: %w", installationID, err)
+	}
+	c.tokens.Put(installationID, tok)
+	return tok.Value, nil
+}
+
+// StatusError is a call GitHub answered with an error status.
+type StatusError struct {
+	Method, Path string
+	StatusCode   int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("github %s %s failed: status=%d", e.Method, e.Path, e.StatusCode)
+}
+
+// call sends in, when non-nil, as JSON to the REST API with an installation
+// token and decodes the response into out when non-nil. A cached token
+// GitHub refuses, say because it was revoked, is dropped and the call
+// retried once with a fresh one.
+func (c *Client) call(ctx context.Context, method, path, token string, in, out any) error {
+	var payload []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+	status, respBody, err := c.send(ctx, method, path, token, payload)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusUnauthorized {
+		if id, ok := c.tokens.Drop(token); ok {
+			if token, err = c.InstallationToken(ctx, id); err != nil {
+				return err
+			}
+			if status, respBody, err = c.send(ctx, method, path, token, payload); err != nil {
+				return err
+			}
+		}
+	}
+	if status >= 300 {
+		return &StatusError{Method: method, Path: path, StatusCode: status}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// send makes one request with token, sending payload as JSON when non-nil,
+// and returns the response's status and body.
+func (c *Client) send(ctx context.Context, method, path, token string, payload []byte) (int, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	return resp.StatusCode, respBody, nil
+}
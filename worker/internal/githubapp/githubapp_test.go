@@ -0,0 +1,127 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestInstallationToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	var minted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			t.Errorf("authorization is not a JWT: %q", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, h[:], sig); err != nil || !strings.Contains(string(payload), `"iss":"7"`) {
+			t.Errorf("bad JWT %s: %v", payload, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		minted.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"token":"ghs_minted","expires_at":"2030-01-01T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(7, keyPEM, forge.GitHubDotCom, proxy.Setting{URL: proxy.Direct})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = srv.URL
+	for range 2 {
+		token, err := c.InstallationToken(context.Background(), 42)
+		if err != nil || token != "ghs_minted" {
+			t.Fatalf("InstallationToken = %q, %v", token, err)
+		}
+	}
+	if minted.Load() != 1 {
+		t.Errorf("minted %d tokens, want the first one reused", minted.Load())
+	}
+	if _, err := c.InstallationToken(context.Background(), 43); err == nil {
+		t.Error("expected an unknown installation to fail")
+	}
+}
+
+func TestCallRefreshesRevokedToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	var minted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			n := minted.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, n, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case "/repos/acme/web/issues/1/comments":
+			if r.Header.Get("Authorization") != "Bearer ghs_2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(7, keyPEM, forge.GitHubDotCom, proxy.Setting{URL: proxy.Direct})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = srv.URL
+	token, err := c.InstallationToken(context.Background(), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.call(context.Background(), http.MethodPost, "/repos/acme/web/issues/1/comments", token, map[string]string{"body": "hi"}, nil); err != nil {
+		t.Fatalf("call with a revoked token: %v", err)
+	}
+	if minted.Load() != 2 {
+		t.Errorf("minted %d tokens, want 2", minted.Load())
+	}
+	// A token the client did not mint is not retried.
+	if err := c.call(context.Background(), http.MethodPost, "/repos/acme/web/issues/1/comments", "ghp_user", nil, nil); err == nil {
+		t.Error("expected an unknown token's 401 to fail")
+	}
+}
+
+func TestNewRejectsBadKey(t *testing.T) {
+	if _, err := New(7, "not a key", forge.GitHubDotCom, proxy.Setting{}); err == nil {
+		t.Error("expected an invalid key to be rejected")
+	}
+}
@@ -0,0 +1,67 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// perPage is the largest page GitHub's list endpoints return.
+const perPage = 100
+
+// PullRequestFile is a file a pull request changes. Patch is its unified
+// diff, empty for binary files and diffs too large for GitHub to show.
+type PullRequestFile struct {
+	Filename string `json:"filename"`
+	Patch    string `json:"patch"`
+}
+
+// ReviewComment is a comment on one line of a pull request's diff.
+type ReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side"` // RIGHT: the line as the head has it
+	Body string `json:"body"`
+}
+
+// Review is a pull request review that only comments.
+type Review struct {
+	CommitID string          `json:"commit_id"`
+	Body     string          `json:"body,omitempty"`
+	Comments []ReviewComment `json:"comments"`
+}
+
+// PullRequestFiles lists the files pull request number changes.
+func (c *Client) PullRequestFiles(ctx context.Context, token, owner, repo string, number int) ([]PullRequestFile, error) {
+	return list[PullRequestFile](ctx, c, fmt.Sprintf("/repos/%s/%s/pulls/%d/files", owner, repo, number), token)
+}
+
+// ReviewComments lists the review comments on pull request number.
+func (c *Client) ReviewComments(ctx context.Context, token, owner, repo string, number int) ([]ReviewComment, error) {
+	return list[ReviewComment](ctx, c, fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", owner, repo, number), token)
+}
+
+// CreateReview posts a review with line comments on pull request number.
+// The installation needs the Pull requests: Read & write permission.
+func (c *Client) CreateReview(ctx context.Context, token, owner, repo string, number int, review Review) error {
+	body := struct {
+		Review
+		Event string `json:"event"`
+	}{review, "COMMENT"}
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", owner, repo, number), token, body, nil)
+}
+
+// list reads every page of a list endpoint, stopping at a short page.
+func list[T any](ctx context.Context, c *Client, path, token string) ([]T, error) {
+	var all []T
+	for page := 1; ; page++ {
+		var items []T
+		if err := c.call(ctx, http.MethodGet, fmt.Sprintf("%s?per_page=%d&page=%d", path, perPage, page), token, nil, &items); err != nil {
+			return all, err
+		}
+		all = append(all, items...)
+		if len(items) < perPage {
+			return all, nil
+		}
+	}
+}
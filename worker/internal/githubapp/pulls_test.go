@@ -0,0 +1,37 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"argus/internal/proxy"
+)
+
+func TestReviewCommentsPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/web/pulls/7/comments" || r.URL.Query().Get("per_page") != "100" {
+			http.NotFound(w, r)
+			return
+		}
+		n := 100
+		if r.URL.Query().Get("page") == "2" {
+			n = 3
+		}
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"path":"a.go","line":%d,"body":"b"}`, i+1)
+		}
+		_, _ = w.Write([]byte("[" + strings.Join(items, ",") + "]"))
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: &http.Client{Transport: proxy.Setting{URL: proxy.Direct}.Transport()}, baseURL: srv.URL}
+	comments, err := c.ReviewComments(context.Background(), "ghs_token", "acme", "web", 7)
+	if err != nil || len(comments) != 103 {
+		t.Fatalf("ReviewComments = %d comments, %v", len(comments), err)
+	}
+}
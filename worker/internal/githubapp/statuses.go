@@ -0,0 +1,38 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Commit status states.
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// maxStatusDescription is the longest description GitHub accepts on a
+// commit status.
+const maxStatusDescription = 140
+
+// CommitStatus is a status on a commit. Branch protection can require the
+// latest status of a Context to be success before merging.
+type CommitStatus struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+// CreateCommitStatus sets status on commit sha of owner/repo, replacing
+// the last one with the same context. The installation needs the Commit
+// statuses: Read & write permission.
+func (c *Client) CreateCommitStatus(ctx context.Context, token, owner, repo, sha string, status CommitStatus) error {
+	if len(status.Description) > maxStatusDescription {
+		status.Description = status.Description[:maxStatusDescription-3] + "..."
+	}
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/statuses/%s", owner, repo, sha), token, status, nil)
+}
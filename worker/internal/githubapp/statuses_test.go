@@ -0,0 +1,36 @@
+package githubapp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"argus/internal/proxy"
+)
+
+func TestCreateCommitStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/web/statuses/abc123" || r.Header.Get("Authorization") != "Bearer ghs_token" {
+			http.NotFound(w, r)
+			return
+		}
+		var got CommitStatus
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.State != StatePending || got.Context != "argus/scan" || got.TargetURL != "https://argus.example.com/api/jobs/j1" || len(got.Description) != maxStatusDescription {
+			t.Errorf("status = %+v", got)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := &Client{httpClient: &http.Client{Transport: proxy.Setting{URL: proxy.Direct}.Transport()}, baseURL: srv.URL}
+	status := CommitStatus{State: StatePending, Context: "argus/scan", TargetURL: "https://argus.example.com/api/jobs/j1", Description: strings.Repeat("x", 200)}
+	if err := c.CreateCommitStatus(context.Background(), "ghs_token", "acme", "web", "abc123", status); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package gitlab calls the REST API of gitlab.com or a self-managed GitLab
+// with an access token, to comment on the merge requests Argus scans. It
+// mirrors the API's client, which the worker cannot import.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+// perPage is the largest page GitLab's list endpoints return.
+const perPage = 100
+
+// Client calls one instance's REST API with a personal, group or project
+// access token.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// New returns a client for host that authenticates with token and reaches
+// the instance through p.
+func New(host forge.GitLab, token string, p proxy.Setting) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 25 * time.Second, Transport: p.Transport()},
+		baseURL:    host.APIURL(),
+		token:      token,
+	}
+}
+
+// projectPath is the API path of a project, which GitLab takes as its
+// URL-encoded full path.
+func projectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+// call sends in, when non-nil, as JSON to the REST API and decodes the
+// response into out when non-nil.
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gitlab %s %s failed: status=%d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// list reads every page of a list endpoint, stopping at a short page.
+func list[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var all []T
+	for page := 1; ; page++ {
+		var items []T
+		if err := c.call(ctx, http.MethodGet, fmt.Sprintf("%s?per_page=%d&page=%d", path, perPage, page), nil, &items); err != nil {
+			return all, err
+		}
+		all = append(all, items...)
+		if len(items) < perPage {
+			return all, nil
+		}
+	}
+}
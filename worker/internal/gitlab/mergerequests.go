@@ -0,0 +1,74 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// DiffRefs are the commits a merge request's diff is taken between; a
+// line comment names all three.
+type DiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	StartSHA string `json:"start_sha"`
+	HeadSHA  string `json:"head_sha"`
+}
+
+// MergeRequestDiff is a file a merge request changes. Diff is its unified
+// diff without the file headers, empty for binary and collapsed files.
+type MergeRequestDiff struct {
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
+	Diff    string `json:"diff"`
+}
+
+// Discussion is a thread of notes on a merge request.
+type Discussion struct {
+	Notes []struct {
+		Body string `json:"body"`
+	} `json:"notes"`
+}
+
+// Position places a comment on a line of a merge request's diff as the
+// head has it.
+type Position struct {
+	DiffRefs
+	PositionType string `json:"position_type"` // text
+	OldPath      string `json:"old_path"`
+	NewPath      string `json:"new_path"`
+	NewLine      int    `json:"new_line"`
+}
+
+// MergeRequestDiffRefs returns the commits merge request iid's current
+// diff is between.
+func (c *Client) MergeRequestDiffRefs(ctx context.Context, project string, iid int) (DiffRefs, error) {
+	var out struct {
+		DiffRefs DiffRefs `json:"diff_refs"`
+	}
+	if err := c.call(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", projectPath(project), iid), nil, &out); err != nil {
+		return DiffRefs{}, err
+	}
+	return out.DiffRefs, nil
+}
+
+// MergeRequestDiffs lists the files merge request iid changes. The
+// endpoint needs GitLab 15.7 or later.
+func (c *Client) MergeRequestDiffs(ctx context.Context, project string, iid int) ([]MergeRequestDiff, error) {
+	return list[MergeRequestDiff](ctx, c, fmt.Sprintf("%s/merge_requests/%d/diffs", projectPath(project), iid))
+}
+
+// Discussions lists the discussions on merge request iid.
+func (c *Client) Discussions(ctx context.Context, project string, iid int) ([]Discussion, error) {
+	return list[Discussion](ctx, c, fmt.Sprintf("%s/merge_requests/%d/discussions", projectPath(project), iid))
+}
+
+// CreateDiscussion starts a discussion on a line of merge request iid. The
+// token needs the api scope and at least the Reporter role.
+func (c *Client) CreateDiscussion(ctx context.Context, project string, iid int, body string, pos Position) error {
+	pos.PositionType = "text"
+	in := struct {
+		Body     string   `json:"body"`
+		Position Position `json:"position"`
+	}{body, pos}
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("%s/merge_requests/%d/discussions", projectPath(project), iid), in, nil)
+}
@@ -0,0 +1,40 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"argus/internal/forge"
+	"argus/internal/proxy"
+)
+
+func TestCreateDiscussion(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/v4/projects/acme%2Fplatform%2Fapi/merge_requests/7/discussions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer glpat-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := New(forge.GitLab(srv.URL), "glpat-token", proxy.Setting{URL: proxy.Direct})
+	refs := DiffRefs{BaseSHA: "base", StartSHA: "start", HeadSHA: "head"}
+	pos := Position{DiffRefs: refs, OldPath: "db/query.go", NewPath: "db/query.go", NewLine: 11}
+	if err := c.CreateDiscussion(context.Background(), "acme/platform/api", 7, "SQL injection", pos); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := got["position"].(map[string]any)
+	if got["body"] != "SQL injection" || p["position_type"] != "text" || p["head_sha"] != "head" || p["new_line"] != 11.0 {
+		t.Errorf("request = %v", got)
+	}
+}
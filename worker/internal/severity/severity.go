@@ -0,0 +1,176 @@
+// Package severity puts the scanners' severity labels on Argus's one scale,
+// CRITICAL, HIGH, MEDIUM and LOW. Scanners disagree: semgrep says ERROR and
+// WARNING, trivy CRITICAL to LOW, npm "moderate", grype "Negligible", and
+// gitleaks nothing at all. A CVSS v3 vector, where a scanner reports one,
+// is rated by its base score instead.
+package severity
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The scale, from most to least severe.
+const (
+	Critical = "CRITICAL"
+	High     = "HIGH"
+	Medium   = "MEDIUM"
+	Low      = "LOW"
+)
+
+// labels maps the scanners' own labels onto the scale. Labels not listed,
+// such as UNKNOWN or an empty one, take the tool's default.
+var labels = map[string]string{
+	"CRITICAL":   Critical,
+	"HIGH":       High,
+	"ERROR":      High,
+	"MEDIUM":     Medium,
+	"MODERATE":   Medium,
+	"WARNING":    Medium,
+	"LOW":        Low,
+	"INFO":       Low,
+	"NOTE":       Low,
+	"NEGLIGIBLE": Low,
+	"STYLE":      Low,
+	"UNDEFINED":  Low,
+}
+
+// defaults are the severities of unlabelled findings by tool. Secrets
+// scanners rate an unlabelled leak HIGH; npm reports advisories without a
+// severity as informational.
+var defaults = map[string]string{
+	"gitleaks":   High,
+	"trufflehog": High,
+	"npm-audit":  Low,
+	"bandit":     Low,
+	"hadolint":   Low,
+}
+
+// Default is the severity of tool's findings that carry no usable label.
+func Default(tool string) string {
+	if sev, ok := defaults[tool]; ok {
+		return sev
+	}
+	return Medium
+}
+
+// Normalize maps tool's severity label onto the scale.
+func Normalize(tool, label string) string {
+	if sev, ok := labels[strings.ToUpper(strings.TrimSpace(label))]; ok {
+		return sev
+	}
+	return Default(tool)
+}
+
+// Of rates a finding by its CVSS vector when it has one that parses, and
+// by tool's label otherwise.
+func Of(tool, label, vector string) string {
+	if score, err := Score(vector); err == nil {
+		return FromScore(score)
+	}
+	return Normalize(tool, label)
+}
+
+// FromScore rates a CVSS base score with the CVSS v3 qualitative ratings.
+// The scale has no "none", so a score of 0 is LOW.
+func FromScore(score float64) string {
+	switch {
+	case score >= 9:
+		return Critical
+	case score >= 7:
+		return High
+	case score >= 4:
+		return Medium
+	}
+	return Low
+}
+
+// v3Weights are the CVSS v3 base metric weights. PR's weights depend on
+// scope and are in scoreV3.
+var v3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// Score computes the base score of a CVSS v3.0 or v3.1 vector such as
+// CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H. Temporal and environmental
+// metrics in the vector are ignored.
+func Score(vector string) (float64, error) {
+	vector = strings.TrimSpace(vector)
+	rest, ok := strings.CutPrefix(vector, "CVSS:3.1/")
+	if !ok {
+		if rest, ok = strings.CutPrefix(vector, "CVSS:3.0/"); !ok {
+			return 0, fmt.Errorf("not a CVSS v3 vector: %q", vector)
+		}
+	}
+	metrics := map[string]string{}
+	for _, m := range strings.Split(rest, "/") {
+		k, v, ok := strings.Cut(m, ":")
+		if !ok {
+			return 0, fmt.Errorf("malformed CVSS metric %q", m)
+		}
+		metrics[k] = v
+	}
+	return scoreV3(metrics)
+}
+
+func scoreV3(m map[string]string) (float64, error) {
+	w := map[string]float64{}
+	for k, weights := range v3Weights {
+		v, ok := weights[m[k]]
+		if !ok {
+			return 0, fmt.Errorf("missing or invalid CVSS metric %s", k)
+		}
+		w[k] = v
+	}
+	changed := m["S"] == "C"
+	if !changed && m["S"] != "U" {
+		return 0, fmt.Errorf("missing or invalid CVSS metric S")
+	}
+	switch m["PR"] {
+	case "N":
+		w["PR"] = 0.85
+	case "L":
+		w["PR"] = 0.62
+		if changed {
+			w["PR"] = 0.68
+		}
+	case "H":
+		w["PR"] = 0.27
+		if changed {
+			w["PR"] = 0.5
+		}
+	default:
+		return 0, fmt.Errorf("missing or invalid CVSS metric PR")
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp is CVSS v3.1's Roundup: the smallest number with one decimal
+// place not below x, computed so floating point error does not push exact
+// values up a tenth.
+func roundUp(x float64) float64 {
+	n := int64(math.Round(x * 100000))
+	if n%10000 == 0 {
+		return float64(n) / 100000
+	}
+	return float64(n/10000+1) / 10
+}
@@ -0,0 +1,61 @@
+package severity
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		tool, label, want string
+	}{
+		{"semgrep", "ERROR", High},
+		{"semgrep", "WARNING", Medium},
+		{"semgrep", "INFO", Low},
+		{"semgrep", "", Medium},
+		{"trivy", "critical", Critical},
+		{"trivy", "UNKNOWN", Medium},
+		{"gitleaks", "", High},
+		{"grype", "Negligible", Low},
+		{"grype", "Unknown", Medium},
+		{"npm-audit", "moderate", Medium},
+		{"npm-audit", "info", Low},
+		{"npm-audit", "", Low},
+		{"hadolint", "style", Low},
+		{"bandit", "UNDEFINED", Low},
+		{"nuclei", "info", Low},
+	} {
+		if got := Normalize(tc.tool, tc.label); got != tc.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", tc.tool, tc.label, got, tc.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	for vector, want := range map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N": 6.1,
+		"CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N": 5.5,
+		"CVSS:3.1/AV:N/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N": 2.0,
+		"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H": 9.9,
+		"CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:N/I:N/A:N": 0,
+		// Temporal metrics do not change the base score.
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/E:P/RL:O": 7.5,
+	} {
+		got, err := Score(vector)
+		if err != nil || got != want {
+			t.Errorf("Score(%q) = %v, %v; want %v", vector, got, err, want)
+		}
+	}
+	for _, vector := range []string{"", "AV:N/AC:L/Au:N/C:P/I:P/A:P", "CVSS:3.1/AV:N/AC:L", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N"} {
+		if _, err := Score(vector); err == nil {
+			t.Errorf("Score(%q) succeeded", vector)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	if got := Of("trivy", "LOW", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"); got != Critical {
+		t.Errorf("Of with a vector = %q, want %q", got, Critical)
+	}
+	if got := Of("trivy", "HIGH", "garbage"); got != High {
+		t.Errorf("Of with a bad vector = %q, want %q", got, High)
+	}
+}